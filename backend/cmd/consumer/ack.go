@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kafka-notify/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxAckBatch caps the notification IDs one acknowledgement may carry; it
+// matches the max in ackRequest's binding
+const maxAckBatch = 100
+
+// Acknowledger records that a user's client received a notification
+type Acknowledger interface {
+	MarkDelivered(ctx context.Context, userID, notificationID uuid.UUID) error
+}
+
+// ProducerError is a non-2xx answer from the producer API
+type ProducerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProducerError) Error() string {
+	return fmt.Sprintf("producer returned %d: %s", e.StatusCode, e.Message)
+}
+
+// ProducerClient calls the producer's notification API
+type ProducerClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewProducerClient creates a client for the producer API at baseURL that
+// authenticates with apiKey, one of the producer's INTERNAL_API_KEYS
+func NewProducerClient(baseURL, apiKey string, timeout time.Duration) *ProducerClient {
+	return &ProducerClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// MarkDelivered calls PUT /api/v1/notifications/:id/delivered for the user
+func (c *ProducerClient) MarkDelivered(ctx context.Context, userID, notificationID uuid.UUID) error {
+	endpoint := fmt.Sprintf("%s/api/v1/notifications/%s/delivered?userID=%s",
+		c.baseURL, notificationID, url.QueryEscape(userID.String()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build delivered request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(middleware.APIKeyHeader, c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call producer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var body struct {
+		Error   string `json:"error"`
+		Details string `json:"details"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		message = body.Error
+		if body.Details != "" {
+			message += ": " + body.Details
+		}
+	}
+	return &ProducerError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// ackRequest is the body of POST /notifications/:userID/ack
+type ackRequest struct {
+	NotificationIDs []uuid.UUID `json:"notification_ids" binding:"required,min=1,max=100"`
+}
+
+// handleAck serves POST /notifications/:userID/ack, marking each acknowledged
+// notification delivered. Failures are reported per ID; the response is 502
+// only when none of them could be marked.
+func handleAck(ctx *gin.Context, acker Acknowledger) {
+	userID, err := uuid.Parse(ctx.Param("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+
+	var req ackRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	acknowledged := []uuid.UUID{}
+	failed := map[string]string{}
+	seen := make(map[uuid.UUID]bool, len(req.NotificationIDs))
+	for _, id := range req.NotificationIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if err := acker.MarkDelivered(ctx.Request.Context(), userID, id); err != nil {
+			failed[id.String()] = err.Error()
+			continue
+		}
+		acknowledged = append(acknowledged, id)
+	}
+
+	data := gin.H{"acknowledged": acknowledged, "failed": failed}
+	if len(acknowledged) == 0 {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"error": "Failed to acknowledge notifications",
+			"data":  data,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Notifications acknowledged",
+		"data":    data,
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProducer answers PUT /api/v1/notifications/:id/delivered, rejecting
+// the IDs in missing with a 404 and callers without one of keys, if any, with a 401
+type fakeProducer struct {
+	mu      sync.Mutex
+	calls   []string
+	missing map[string]bool
+	keys    []string
+}
+
+func (p *fakeProducer) start(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/v1/notifications/:id/delivered", middleware.APIKeyAuth(p.keys), func(c *gin.Context) {
+		p.mu.Lock()
+		p.calls = append(p.calls, c.Param("id")+"?"+c.Query("userID"))
+		p.mu.Unlock()
+		if p.missing[c.Param("id")] {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to mark notification as delivered", "details": "notification not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as delivered successfully"})
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newAckRouter(acker Acknowledger) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/notifications/:userID/ack", func(ctx *gin.Context) {
+		handleAck(ctx, acker)
+	})
+	return router
+}
+
+func postAck(t *testing.T, router *gin.Engine, userID string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/notifications/"+userID+"/ack", bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w, response
+}
+
+func TestProducerClient_MarkDelivered(t *testing.T) {
+	producer := &fakeProducer{missing: map[string]bool{}}
+	server := producer.start(t)
+	client := NewProducerClient(server.URL+"/", "", time.Second)
+	userID, id := uuid.New(), uuid.New()
+
+	err := client.MarkDelivered(context.Background(), userID, id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{id.String() + "?" + userID.String()}, producer.calls)
+}
+
+func TestProducerClient_SendsAPIKey(t *testing.T) {
+	producer := &fakeProducer{missing: map[string]bool{}, keys: []string{"consumer-key-0001"}}
+	server := producer.start(t)
+
+	err := NewProducerClient(server.URL, "consumer-key-0001", time.Second).MarkDelivered(context.Background(), uuid.New(), uuid.New())
+	require.NoError(t, err)
+
+	err = NewProducerClient(server.URL, "revoked-key-0001", time.Second).MarkDelivered(context.Background(), uuid.New(), uuid.New())
+	var producerErr *ProducerError
+	require.True(t, errors.As(err, &producerErr))
+	assert.Equal(t, http.StatusUnauthorized, producerErr.StatusCode)
+	assert.Len(t, producer.calls, 1)
+}
+
+func TestProducerClient_ErrorResponse(t *testing.T) {
+	id := uuid.New()
+	producer := &fakeProducer{missing: map[string]bool{id.String(): true}}
+	server := producer.start(t)
+
+	err := NewProducerClient(server.URL, "", time.Second).MarkDelivered(context.Background(), uuid.New(), id)
+
+	var producerErr *ProducerError
+	require.True(t, errors.As(err, &producerErr))
+	assert.Equal(t, http.StatusNotFound, producerErr.StatusCode)
+	assert.Equal(t, "Failed to mark notification as delivered: notification not found", producerErr.Message)
+}
+
+func TestProducerClient_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := NewProducerClient(server.URL, "", time.Second).MarkDelivered(context.Background(), uuid.New(), uuid.New())
+
+	assert.ErrorContains(t, err, "failed to call producer")
+}
+
+func TestHandleAck_MarksEachNotificationOnce(t *testing.T) {
+	producer := &fakeProducer{missing: map[string]bool{}}
+	server := producer.start(t)
+	router := newAckRouter(NewProducerClient(server.URL, "", time.Second))
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+
+	w, body := postAck(t, router, userID.String(), gin.H{"notification_ids": []uuid.UUID{first, second, first}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	data := body["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{first.String(), second.String()}, data["acknowledged"])
+	assert.Empty(t, data["failed"])
+	assert.Equal(t, []string{first.String() + "?" + userID.String(), second.String() + "?" + userID.String()}, producer.calls)
+}
+
+func TestHandleAck_ReportsFailuresPerID(t *testing.T) {
+	missing := uuid.New()
+	producer := &fakeProducer{missing: map[string]bool{missing.String(): true}}
+	server := producer.start(t)
+	router := newAckRouter(NewProducerClient(server.URL, "", time.Second))
+	found := uuid.New()
+
+	w, body := postAck(t, router, uuid.NewString(), gin.H{"notification_ids": []uuid.UUID{found, missing}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	data := body["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{found.String()}, data["acknowledged"])
+	assert.Contains(t, data["failed"].(map[string]interface{})[missing.String()], "producer returned 404")
+}
+
+func TestHandleAck_AllFailedIsBadGateway(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	router := newAckRouter(NewProducerClient(server.URL, "", time.Second))
+
+	w, body := postAck(t, router, uuid.NewString(), gin.H{"notification_ids": []uuid.UUID{uuid.New()}})
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Failed to acknowledge notifications", body["error"])
+}
+
+func TestHandleAck_InvalidRequests(t *testing.T) {
+	router := newAckRouter(NewProducerClient("http://127.0.0.1:1", "", time.Second))
+	tooMany := make([]uuid.UUID, maxAckBatch+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		body   interface{}
+		error  string
+	}{
+		{"invalid user", "alice", gin.H{"notification_ids": []uuid.UUID{uuid.New()}}, "Invalid user ID format"},
+		{"missing ids", uuid.NewString(), gin.H{}, "Invalid request body"},
+		{"empty ids", uuid.NewString(), gin.H{"notification_ids": []uuid.UUID{}}, "Invalid request body"},
+		{"invalid id", uuid.NewString(), gin.H{"notification_ids": []string{"nope"}}, "Invalid request body"},
+		{"too many ids", uuid.NewString(), gin.H{"notification_ids": tooMany}, "Invalid request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, body := postAck(t, router, tt.userID, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.error, body["error"])
+		})
+	}
+}
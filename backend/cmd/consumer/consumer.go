@@ -2,41 +2,32 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"kafka-notify/internal/channels/email"
+	"kafka-notify/internal/channels/push"
+	"kafka-notify/internal/channels/webhook"
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/internal/kafka"
+	"kafka-notify/internal/metrics"
+	"kafka-notify/internal/middleware"
 	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
 
 	"github.com/IBM/sarama"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	ConsumerGroup = "notifications-group"
-	ConsumerTopic = "notifications"
-	ConsumerPort  = ":8081"
-)
-
-func getKafkaBroker() string {
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		return "kafka:9092"
-	}
-	if strings.Contains(brokers, ",") {
-		parts := strings.SplitN(brokers, ",", 2)
-		return strings.TrimSpace(parts[0])
-	}
-	return strings.TrimSpace(brokers)
-}
-
 // ============== HELPER FUNCTIONS ==============
 var ErrNoMessagesFound = errors.New("no messages found")
 
@@ -51,83 +42,362 @@ func getUserIDFromRequest(ctx *gin.Context) (string, error) {
 // Real-time WebSocket functionality removed
 
 // ====== NOTIFICATION STORAGE ======
-type UserNotifications map[string][]models.Notification
 
+// DefaultMaxPerUser is how many notifications a user's in-memory inbox keeps
+const DefaultMaxPerUser = 200
+
+// inboxEntry is a stored notification and when it was first stored
+type inboxEntry struct {
+	notification models.Notification
+	storedAt     time.Time
+}
+
+// NotificationStore is the in-memory Store; it is emptied by every restart.
+// Each user keeps at most maxPerUser notifications, and ones older than ttl
+// are removed by Evict.
 type NotificationStore struct {
-	data UserNotifications
-	mu   sync.RWMutex
+	// data holds each user's notifications, oldest first
+	data map[string][]inboxEntry
+	// versions is a per-user sequence bumped on every change to the user's list
+	versions   map[string]uint64
+	maxPerUser int
+	ttl        time.Duration
+	now        func() time.Time
+	mu         sync.RWMutex
+}
+
+// StoreOption configures a NotificationStore
+type StoreOption func(*NotificationStore)
+
+// WithMaxPerUser caps each user's notifications, dropping the oldest; 0 keeps all
+func WithMaxPerUser(max int) StoreOption {
+	return func(ns *NotificationStore) {
+		if max >= 0 {
+			ns.maxPerUser = max
+		}
+	}
+}
+
+// WithTTL makes Evict remove notifications stored longer than ttl; 0 keeps them
+func WithTTL(ttl time.Duration) StoreOption {
+	return func(ns *NotificationStore) {
+		if ttl >= 0 {
+			ns.ttl = ttl
+		}
+	}
+}
+
+func NewNotificationStore(opts ...StoreOption) *NotificationStore {
+	ns := &NotificationStore{
+		data:       make(map[string][]inboxEntry),
+		versions:   make(map[string]uint64),
+		maxPerUser: DefaultMaxPerUser,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(ns)
+	}
+	return ns
 }
 
-func (ns *NotificationStore) Add(userID string,
-	notification models.Notification) {
+// Add stores a notification in the user's list, dropping the oldest once the
+// list is over its cap. A notification already in the list, such as one Kafka
+// redelivered after a rebalance, is replaced in place rather than added again.
+func (ns *NotificationStore) Add(ctx context.Context, userID string, notification models.Notification) error {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
-	ns.data[userID] = append(ns.data[userID], notification)
+
+	entries := ns.data[userID]
+	ns.versions[userID]++
+	for i := range entries {
+		if entries[i].notification.ID == notification.ID {
+			entries[i].notification = notification
+			return nil
+		}
+	}
+
+	entries = append(entries, inboxEntry{notification: notification, storedAt: ns.now()})
+	if ns.maxPerUser > 0 && len(entries) > ns.maxPerUser {
+		entries = entries[len(entries)-ns.maxPerUser:]
+	}
+	ns.data[userID] = entries
+	return nil
 }
 
+// Get returns the user's notifications, newest first
 func (ns *NotificationStore) Get(userID string) []models.Notification {
+	notes, _ := ns.snapshot(userID)
+	return notes
+}
+
+// Version returns the user's change sequence; it is 0 until the first Add
+func (ns *NotificationStore) Version(userID string) uint64 {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.versions[userID]
+}
+
+// Query returns the page of the user's notifications, newest first, that opts
+// selects. The ETag is the user's version, which every change to the list bumps.
+func (ns *NotificationStore) Query(ctx context.Context, userID string, opts QueryOptions) (QueryResult, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	result := QueryResult{
+		Notifications: []models.Notification{},
+		ETag:          notificationsETag(ns.versions[userID]),
+	}
+	entries := ns.data[userID]
+	for i := len(entries) - 1; i >= 0; i-- {
+		n := entries[i].notification
+		if !opts.matches(n) {
+			continue
+		}
+		result.Total++
+		if result.Total > opts.Offset && (opts.Limit <= 0 || len(result.Notifications) < opts.Limit) {
+			result.Notifications = append(result.Notifications, n)
+		}
+	}
+	return result, nil
+}
+
+// snapshot returns a copy of the user's notifications, newest first, together
+// with the version they belong to
+func (ns *NotificationStore) snapshot(userID string) ([]models.Notification, uint64) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	entries := ns.data[userID]
+	notes := make([]models.Notification, len(entries))
+	for i, entry := range entries {
+		notes[len(entries)-1-i] = entry.notification
+	}
+	return notes, ns.versions[userID]
+}
+
+// Evict removes notifications stored longer than the TTL and returns how many
+// it removed. Users left with none are dropped; their versions are kept so
+// ETags issued before stay distinct from later ones.
+func (ns *NotificationStore) Evict() int {
+	if ns.ttl <= 0 {
+		return 0
+	}
+	cutoff := ns.now().Add(-ns.ttl)
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	removed := 0
+	for userID, entries := range ns.data {
+		// Entries are in the order they were stored, so the expired ones lead
+		keep := sort.Search(len(entries), func(i int) bool { return !entries[i].storedAt.Before(cutoff) })
+		if keep == 0 {
+			continue
+		}
+		removed += keep
+		ns.versions[userID]++
+		if keep == len(entries) {
+			delete(ns.data, userID)
+			continue
+		}
+		ns.data[userID] = append([]inboxEntry(nil), entries[keep:]...)
+	}
+	return removed
+}
+
+// RunEviction calls Evict every interval until ctx is cancelled
+func (ns *NotificationStore) RunEviction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if removed := ns.Evict(); removed > 0 {
+				log.Printf("Evicted %d expired in-app notifications", removed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// StoreStats reports the size of the in-memory store
+type StoreStats struct {
+	Users         int `json:"users"`
+	Notifications int `json:"notifications"`
+}
+
+// Stats returns the number of users and notifications held
+func (ns *NotificationStore) Stats() StoreStats {
 	ns.mu.RLock()
 	defer ns.mu.RUnlock()
-	return ns.data[userID]
+
+	stats := StoreStats{Users: len(ns.data)}
+	for _, entries := range ns.data {
+		stats.Notifications += len(entries)
+	}
+	return stats
+}
+
+// errInboxWrite marks a delivery that failed because the inbox could not be
+// written; the message is left uncommitted so Kafka delivers it again
+var errInboxWrite = errors.New("failed to write inbox")
+
+// inAppSender delivers in-app notifications to the user's inbox in the store
+// and to the user's open streams
+type inAppSender struct {
+	store  Store
+	broker *Broker
+}
+
+func (s *inAppSender) Name() string { return "inbox" }
+
+func (s *inAppSender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	userID := n.UserID.String()
+	if err := s.store.Add(ctx, userID, *n); err != nil {
+		return "", fmt.Errorf("%w: %v", errInboxWrite, err)
+	}
+	if s.broker != nil {
+		s.broker.Publish(userID, *n)
+	}
+	return n.ID.String(), nil
 }
 
 // ============== KAFKA RELATED FUNCTIONS ==============
 type Consumer struct {
-	store *NotificationStore
+	worker *delivery.Worker
+	dlq    *kafka.DLQPublisher
+	state  *GroupState
 }
 
-func (*Consumer) Setup(sarama.ConsumerGroupSession) error   { return nil }
-func (*Consumer) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (consumer *Consumer) Setup(sess sarama.ConsumerGroupSession) error {
+	consumer.state.SessionStarted(sess.Claims())
+	return nil
+}
+
+func (consumer *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	consumer.state.SessionEnded()
+	return nil
+}
 
 func (consumer *Consumer) ConsumeClaim(
 	sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
-		userID := string(msg.Key)
-		var notification models.Notification
-		err := json.Unmarshal(msg.Value, &notification)
+		consumer.state.RecordLag(msg.Topic, msg.Partition, claim.HighWaterMarkOffset()-msg.Offset-1)
+		if !wantsMessage(msg, consumer.worker.Handles) {
+			sess.MarkMessage(msg, "")
+			continue
+		}
+
+		notification, err := models.DecodeNotificationEvent(msg.Value)
 		if err != nil {
-			log.Printf("failed to unmarshal notification: %v", err)
+			log.Printf("failed to decode notification: %v", err)
+			err = consumer.dlq.Publish(sess.Context(), kafka.DLQMessage{
+				Key:         msg.Key,
+				Value:       msg.Value,
+				Reason:      err.Error(),
+				SourceTopic: msg.Topic,
+				Partition:   msg.Partition,
+				Offset:      msg.Offset,
+				FailedAt:    time.Now(),
+			})
+			if err != nil && !errors.Is(err, kafka.ErrDLQDropped) {
+				// Blocked on a full DLQ buffer until the session ended; leave the
+				// offset uncommitted so the message is redelivered
+				return err
+			}
+			sess.MarkMessage(msg, "")
 			continue
 		}
-		consumer.store.Add(userID, notification)
+
+		// Messages without headers are only known to be for another channel once decoded
+		if consumer.worker.Handles(notification.Channel) {
+			if err := consumer.worker.Deliver(sess.Context(), &notification); err != nil {
+				if sess.Context().Err() != nil {
+					// The session ended mid-delivery; redeliver the message
+					return err
+				}
+				if errors.Is(err, errInboxWrite) {
+					// Committing would lose the notification; end the session so
+					// the message is consumed again
+					return err
+				}
+				log.Printf("failed to deliver notification %s: %v", notification.ID, err)
+			}
+		}
 		sess.MarkMessage(msg, "")
 	}
 	return nil
 }
 
-func initializeConsumerGroup() (sarama.ConsumerGroup, error) {
-	config := sarama.NewConfig()
+// wantsMessage reports from the headers alone whether a message is for a
+// channel this consumer delivers. Messages without a channel header come from
+// producers that predate headers, so they are decoded as before.
+func wantsMessage(msg *sarama.ConsumerMessage, handles func(models.NotificationChannel) bool) bool {
+	channel, ok := kafka.HeaderValue(msg.Headers, kafka.HeaderChannel)
+	return !ok || handles(models.NotificationChannel(channel))
+}
 
-	broker := getKafkaBroker()
-	consumerGroup, err := sarama.NewConsumerGroup(
-		[]string{broker}, ConsumerGroup, config)
+// connectDLQProducer keeps trying to create the DLQ producer, backing off
+// per retry, until it succeeds or ctx ends
+func connectDLQProducer(ctx context.Context, dlq *kafka.DLQPublisher, clients *kafka.ClientManager, retry config.ConnectRetryConfig) {
+	retry.Deadline = 0
+	var producer sarama.SyncProducer
+	err := kafka.ConnectWithRetry(ctx, retry, "DLQ producer", func() error {
+		var err error
+		producer, err = newDLQProducer(clients)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize consumer group: %w", err)
+		return
 	}
 
-	return consumerGroup, nil
+	dlq.SetProducer(producer)
+	go func() {
+		<-ctx.Done()
+		_ = producer.Close()
+	}()
 }
 
-func setupConsumerGroup(ctx context.Context, store *NotificationStore) {
-	backoff := 5 * time.Second
+// newDLQProducer creates a producer that waits for every replica to store a DLQ message
+func newDLQProducer(clients *kafka.ClientManager) (sarama.SyncProducer, error) {
+	config, err := clients.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	return sarama.NewSyncProducer(clients.Brokers(), config)
+}
+
+// setupConsumerGroup consumes every routed topic until ctx is cancelled. The
+// group is created with retry's exponential backoff, with no deadline since
+// readiness already reports the outage, and recreated after Consume fails.
+func setupConsumerGroup(ctx context.Context, newGroup func() (sarama.ConsumerGroup, error), retry config.ConnectRetryConfig, worker *delivery.Worker, dlq *kafka.DLQPublisher, state *GroupState, topics []string) {
+	retry.Deadline = 0
 	for {
-		cg, err := initializeConsumerGroup()
-		if err != nil {
-			log.Printf("initialization error: %v", err)
-			select {
-			case <-time.After(backoff):
-				continue
-			case <-ctx.Done():
-				return
+		var cg sarama.ConsumerGroup
+		err := kafka.ConnectWithRetry(ctx, retry, "Kafka consumer group", func() error {
+			var err error
+			if cg, err = newGroup(); err != nil {
+				state.RecordError(err)
 			}
+			return err
+		})
+		if err != nil {
+			return
 		}
 
 		consumer := &Consumer{
-			store: store,
+			worker: worker,
+			dlq:    dlq,
+			state:  state,
 		}
 
 		for {
-			err = cg.Consume(ctx, []string{ConsumerTopic}, consumer)
+			err = cg.Consume(ctx, topics, consumer)
+			state.ConsumeReturned(err)
 			if err != nil {
 				log.Printf("error from consumer: %v", err)
 				break
@@ -139,80 +409,266 @@ func setupConsumerGroup(ctx context.Context, store *NotificationStore) {
 		}
 		_ = cg.Close()
 		select {
-		case <-time.After(backoff):
-			// retry
+		case <-time.After(kafka.RetryBackoff(retry, 1)):
+			// recreate the group
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func handleNotifications(ctx *gin.Context, store *NotificationStore) {
+// storeEpoch distinguishes ETags issued by this process from ones issued before
+// a restart, when the in-memory versions start again from zero
+var storeEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// notificationsETag builds the ETag for a user's notification list at a version
+func notificationsETag(version uint64) string {
+	return fmt.Sprintf(`"%s-%d"`, storeEpoch, version)
+}
+
+// etagMatches reports whether an If-None-Match header contains the ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Query parameter bounds for GET /notifications/:userID
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = DefaultMaxPerUser
+)
+
+// parseQueryOptions reads limit, offset, unread and since (RFC 3339) from the query
+func parseQueryOptions(ctx *gin.Context) (QueryOptions, error) {
+	var opts QueryOptions
+	var err error
+
+	if opts.Limit, err = strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(defaultQueryLimit))); err != nil {
+		return opts, fmt.Errorf("invalid limit: %w", err)
+	}
+	if opts.Limit < 1 || opts.Limit > maxQueryLimit {
+		return opts, fmt.Errorf("invalid limit: must be between 1 and %d", maxQueryLimit)
+	}
+	if opts.Offset, err = strconv.Atoi(ctx.DefaultQuery("offset", "0")); err != nil {
+		return opts, fmt.Errorf("invalid offset: %w", err)
+	}
+	if opts.Offset < 0 {
+		return opts, errors.New("invalid offset: must not be negative")
+	}
+	if raw := ctx.Query("unread"); raw != "" {
+		if opts.UnreadOnly, err = strconv.ParseBool(raw); err != nil {
+			return opts, fmt.Errorf("invalid unread: %w", err)
+		}
+	}
+	if raw := ctx.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid since: %w", err)
+		}
+		opts.Since = &since
+	}
+	return opts, nil
+}
+
+func handleNotifications(ctx *gin.Context, store Store) {
 	userID, err := getUserIDFromRequest(ctx)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
 		return
 	}
 
-	notes := store.Get(userID)
+	opts, err := parseQueryOptions(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := store.Query(ctx.Request.Context(), userID, opts)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load notifications",
+			"details": err.Error(),
+		})
+		return
+	}
+	ctx.Header("ETag", result.ETag)
+
+	if match := ctx.GetHeader("If-None-Match"); match != "" && etagMatches(match, result.ETag) {
+		metrics.PollNotModified.Inc()
+		metrics.PollItemsReturned.Observe(0)
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	notes := result.Notifications
+	meta := gin.H{
+		"limit":    opts.Limit,
+		"offset":   opts.Offset,
+		"count":    len(notes),
+		"total":    result.Total,
+		"has_more": opts.Offset+len(notes) < result.Total,
+		"filter":   opts,
+	}
+
+	metrics.PollItemsReturned.Observe(float64(len(notes)))
 	if len(notes) == 0 {
 		ctx.JSON(http.StatusOK,
 			gin.H{
 				"message":       "No notifications found for user",
 				"notifications": []models.Notification{},
+				"meta":          meta,
 			})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"notifications": notes})
+	ctx.JSON(http.StatusOK, gin.H{"notifications": notes, "meta": meta})
 }
 
 // WebSocket handler removed
 
 func main() {
-	store := &NotificationStore{
-		data: make(UserNotifications),
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	dlq, err := kafka.NewDLQPublisher(cfg.Kafka.DLQ, nil)
+	if err != nil {
+		log.Fatalf("Failed to initialize DLQ publisher: %v", err)
+	}
+
+	// Delivery attempts and statuses are recorded on the notification rows
+	dbManager, err := database.NewConnectionManager(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer dbManager.Close()
+
+	repo := repository.NewPostgresNotificationRepository(dbManager.GetDB())
+	store, err := newStore(cfg.Inbox, repo)
+	if err != nil {
+		log.Fatalf("Failed to initialize notification store: %v", err)
+	}
+	var emailSender delivery.Sender = delivery.NoopSender{Provider: "noop"}
+	if cfg.Email.Host != "" {
+		emailSender = email.NewSender(cfg.Email, repo)
+	}
+	var pushSender delivery.Sender = delivery.NoopSender{Provider: "noop"}
+	if cfg.Push.ProjectID != "" {
+		tokens, err := push.LoadServiceAccount(cfg.Push.CredentialsFile, &http.Client{Timeout: cfg.Push.Timeout})
+		if err != nil {
+			log.Fatalf("Failed to load FCM credentials: %v", err)
+		}
+		pushSender = push.NewSender(cfg.Push, tokens, repository.NewPostgresDeviceRepository(dbManager.GetDB()))
+	}
+
+	broker := NewBroker()
+	worker := delivery.NewWorker(delivery.WorkerConfig{
+		MaxAttempts: cfg.Delivery.MaxAttempts,
+		Backoff:     cfg.Delivery.RetryBackoff,
+		Timeout:     cfg.Delivery.ProviderTimeout,
+	}, repo, map[models.NotificationChannel]delivery.Sender{
+		models.ChannelInApp:   &inAppSender{store: store, broker: broker},
+		models.ChannelEmail:   emailSender,
+		models.ChannelPush:    pushSender,
+		models.ChannelWebhook: webhook.NewSender(cfg.Webhook, repository.NewPostgresWebhookRepository(dbManager.GetDB())),
+	})
+
+	// Fail now on unreadable TLS files rather than retrying them forever
+	clients := kafka.NewClientManager(&cfg.Kafka)
+	if _, err := clients.NewConfig(); err != nil {
+		log.Fatalf("Failed to configure Kafka clients: %v", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	go setupConsumerGroup(ctx, store)
+	if cfg.Kafka.AutoCreateTopics {
+		// The consumer group keeps retrying until the topics exist, so a
+		// broker that isn't up yet is not fatal here
+		go func() {
+			err := kafka.ConnectWithRetry(ctx, cfg.Kafka.ConnectRetry, "Kafka topic creation", func() error {
+				return clients.EnsureTopics(ctx)
+			})
+			if err != nil {
+				log.Printf("Failed to create Kafka topics: %v", err)
+			}
+		}()
+	}
+	go connectDLQProducer(ctx, dlq, clients, cfg.Kafka.ConnectRetry)
+	go dlq.Run(ctx)
+	groupState := NewGroupState()
+	newGroup := func() (sarama.ConsumerGroup, error) {
+		return clients.NewConsumerGroup(cfg.Kafka.ConsumerGroup)
+	}
+	go setupConsumerGroup(ctx, newGroup, cfg.Kafka.ConnectRetry, worker, dlq, groupState, cfg.Kafka.SubscribedTopics())
+	memoryStore, isMemory := store.(*NotificationStore)
+	if isMemory {
+		go memoryStore.RunEviction(ctx, cfg.Inbox.EvictionInterval)
+	}
 	defer cancel()
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
 	// Add CORS middleware for HTTP routes only
-	corsMiddleware := cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		AllowCredentials: true,
-	})
+	corsMiddleware := middleware.CORS(cfg.CORS)
 
 	// HTTP API routes with CORS
 	router.GET("/notifications/:userID", corsMiddleware, func(ctx *gin.Context) {
 		handleNotifications(ctx, store)
 	})
+	router.GET("/notifications/:userID/stream", corsMiddleware, func(ctx *gin.Context) {
+		handleStream(ctx, store, broker, cfg.ConsumerServer.StreamHeartbeat)
+	})
+	if cfg.Inbox.ProducerAPIKey == "" && cfg.Environment != config.EnvDevelopment {
+		log.Println("Warning: PRODUCER_API_KEY is unset; the producer will reject acknowledgements")
+	}
+	producer := NewProducerClient(cfg.Inbox.ProducerURL, cfg.Inbox.ProducerAPIKey, cfg.Inbox.ProducerTimeout)
+	router.POST("/notifications/:userID/ack", corsMiddleware, func(ctx *gin.Context) {
+		handleAck(ctx, producer)
+	})
+	// JSON POSTs are preflighted; the CORS middleware answers them
+	router.OPTIONS("/notifications/:userID/ack", corsMiddleware)
 
 	// WebSocket route removed
 
-	// Health check endpoint
-	router.GET("/health", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, gin.H{
-			"status":             "healthy",
-			"service":            "kafka-consumer",
-			"timestamp":          time.Now().Format(time.RFC3339),
-			"active_connections": 0,
-		})
+	// Health check endpoints
+	registerHealthRoutes(router, groupState, cfg.ConsumerServer.ReadyGracePeriod, func(payload gin.H) {
+		// A DLQ backlog means poison messages are not reaching the DLQ topic
+		if !dlq.Healthy() {
+			payload["status"] = "degraded"
+		}
+		payload["active_connections"] = broker.Count()
+		payload["dlq"] = dlq.Stats()
+		if isMemory {
+			payload["store"] = memoryStore.Stats()
+		}
+	})
+
+	router.GET("/metrics", metrics.Handler())
+
+	router.GET("/metrics/dlq", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, dlq.Stats())
+	})
+
+	router.GET("/metrics/kafka", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, clients.Metrics())
 	})
 
 	// WebSocket test endpoint removed
 
-	fmt.Printf("Kafka CONSUMER (Group: %s) 👥📥 "+
-		"started at http://localhost%s\n", ConsumerGroup, ConsumerPort)
+	fmt.Printf("Kafka CONSUMER (Group: %s, brokers: %v) 👥📥 "+
+		"started at http://localhost%s\n", cfg.Kafka.ConsumerGroup, cfg.Kafka.Brokers, cfg.ConsumerServer.Port)
 	// WebSocket endpoint removed
 
-	if err := router.Run(ConsumerPort); err != nil {
+	if err := router.Run(cfg.ConsumerServer.Port); err != nil {
 		log.Printf("failed to run the server: %v", err)
 	}
 }
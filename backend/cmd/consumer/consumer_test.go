@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/delivery"
+	"kafka-notify/internal/kafka"
+	"kafka-notify/internal/metrics"
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPollRouter(store Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/notifications/:userID", func(ctx *gin.Context) {
+		handleNotifications(ctx, store)
+	})
+	return router
+}
+
+func poll(router *gin.Engine, userID, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/notifications/"+userID, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNotificationStore_VersionBumpsPerUser(t *testing.T) {
+	store := NewNotificationStore()
+
+	assert.Equal(t, uint64(0), store.Version("alice"))
+
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+	store.Add(context.Background(), "bob", models.Notification{ID: uuid.New()})
+
+	assert.Equal(t, uint64(2), store.Version("alice"))
+	assert.Equal(t, uint64(1), store.Version("bob"))
+}
+
+func TestNotificationStore_ConcurrentAddsAndReads(t *testing.T) {
+	const writers, perWriter = 8, 50
+	store := NewNotificationStore(WithMaxPerUser(writers * perWriter))
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				notes, version := store.snapshot("alice")
+				assert.Equal(t, uint64(len(notes)), version, "snapshot list and version must match")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(writers*perWriter), store.Version("alice"))
+	assert.Len(t, store.Get("alice"), writers*perWriter)
+}
+
+func TestHandleNotifications_NotModifiedWhenUnchanged(t *testing.T) {
+	store := NewNotificationStore()
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "Time to practice"})
+	router := newPollRouter(store)
+
+	first := poll(router, "alice", "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	notModified := testutil.ToFloat64(metrics.PollNotModified)
+	second := poll(router, "alice", etag)
+
+	assert.Equal(t, http.StatusNotModified, second.Code)
+	assert.Empty(t, second.Body.String())
+	assert.Equal(t, etag, second.Header().Get("ETag"))
+	assert.Equal(t, notModified+1, testutil.ToFloat64(metrics.PollNotModified))
+}
+
+func TestHandleNotifications_FreshDataAfterAdd(t *testing.T) {
+	store := NewNotificationStore()
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "first"})
+	router := newPollRouter(store)
+
+	etag := poll(router, "alice", "").Header().Get("ETag")
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "second"})
+
+	w := poll(router, "alice", etag)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "second")
+}
+
+func TestHandleNotifications_OtherUsersChangesKeepETag(t *testing.T) {
+	store := NewNotificationStore()
+	router := newPollRouter(store)
+
+	etag := poll(router, "alice", "").Header().Get("ETag")
+	store.Add(context.Background(), "bob", models.Notification{ID: uuid.New()})
+
+	assert.Equal(t, http.StatusNotModified, poll(router, "alice", etag).Code)
+}
+
+// seedPollStore adds five notifications for alice created a minute apart,
+// the first two of them read
+func seedPollStore(t *testing.T) (*NotificationStore, time.Time) {
+	t.Helper()
+	store := NewNotificationStore()
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		n := models.Notification{ID: uuid.New(), Message: strconv.Itoa(i), CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if i < 2 {
+			readAt := n.CreatedAt
+			n.ReadAt = &readAt
+		}
+		require.NoError(t, store.Add(context.Background(), "alice", n))
+	}
+	return store, base
+}
+
+type pollResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	Meta          struct {
+		Limit   int  `json:"limit"`
+		Offset  int  `json:"offset"`
+		Count   int  `json:"count"`
+		Total   int  `json:"total"`
+		HasMore bool `json:"has_more"`
+	} `json:"meta"`
+}
+
+func pollQuery(t *testing.T, router *gin.Engine, query string) pollResponse {
+	t.Helper()
+	w := poll(router, "alice?"+query, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body pollResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func messages(notes []models.Notification) []string {
+	out := make([]string, len(notes))
+	for i, n := range notes {
+		out[i] = n.Message
+	}
+	return out
+}
+
+func TestHandleNotifications_Pagination(t *testing.T) {
+	store, _ := seedPollStore(t)
+	router := newPollRouter(store)
+
+	first := pollQuery(t, router, "limit=2")
+	last := pollQuery(t, router, "limit=2&offset=4")
+
+	assert.Equal(t, []string{"4", "3"}, messages(first.Notifications))
+	assert.Equal(t, 2, first.Meta.Limit)
+	assert.Equal(t, 2, first.Meta.Count)
+	assert.Equal(t, 5, first.Meta.Total)
+	assert.True(t, first.Meta.HasMore)
+	assert.Equal(t, []string{"0"}, messages(last.Notifications))
+	assert.Equal(t, 4, last.Meta.Offset)
+	assert.False(t, last.Meta.HasMore)
+}
+
+func TestHandleNotifications_DefaultsToNewestFirst(t *testing.T) {
+	store, _ := seedPollStore(t)
+
+	body := pollQuery(t, newPollRouter(store), "")
+
+	assert.Equal(t, []string{"4", "3", "2", "1", "0"}, messages(body.Notifications))
+	assert.Equal(t, defaultQueryLimit, body.Meta.Limit)
+	assert.Equal(t, 5, body.Meta.Total)
+}
+
+func TestHandleNotifications_UnreadAndSince(t *testing.T) {
+	store, base := seedPollStore(t)
+	router := newPollRouter(store)
+	since := base.Add(90 * time.Second).Format(time.RFC3339)
+
+	unread := pollQuery(t, router, "unread=true")
+	newer := pollQuery(t, router, "since="+since)
+	both := pollQuery(t, router, "unread=true&since="+base.Add(3*time.Minute).Format(time.RFC3339))
+
+	assert.Equal(t, []string{"4", "3", "2"}, messages(unread.Notifications))
+	assert.Equal(t, 3, unread.Meta.Total)
+	assert.Equal(t, []string{"4", "3", "2"}, messages(newer.Notifications))
+	assert.Equal(t, []string{"4", "3"}, messages(both.Notifications))
+}
+
+func TestHandleNotifications_SinceAfterEverythingIsEmpty(t *testing.T) {
+	store, base := seedPollStore(t)
+
+	body := pollQuery(t, newPollRouter(store), "since="+base.Add(time.Hour).Format(time.RFC3339))
+
+	assert.Empty(t, body.Notifications)
+	assert.Zero(t, body.Meta.Total)
+	assert.False(t, body.Meta.HasMore)
+}
+
+func TestHandleNotifications_InvalidParameters(t *testing.T) {
+	store, _ := seedPollStore(t)
+	router := newPollRouter(store)
+
+	for _, query := range []string{
+		"limit=abc",
+		"limit=0",
+		"limit=201",
+		"offset=-1",
+		"offset=x",
+		"unread=maybe",
+		"since=yesterday",
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := poll(router, "alice?"+query, "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid query parameter")
+		})
+	}
+}
+
+func TestHandleNotifications_StoreErrorIs500(t *testing.T) {
+	w := poll(newPollRouter(failingInbox{}), "alice", "")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to load notifications")
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc-3"`
+
+	assert.True(t, etagMatches(`"abc-3"`, etag))
+	assert.True(t, etagMatches(`W/"abc-3"`, etag))
+	assert.True(t, etagMatches(`"abc-2", "abc-3"`, etag))
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(`"abc-2"`, etag))
+	assert.False(t, etagMatches(`"old-3"`, etag))
+}
+
+// consumerMessage builds the message a consumer receives for an outbox payload
+func consumerMessage(payload models.JSONMap, value []byte) *sarama.ConsumerMessage {
+	produced := kafka.NotificationHeaders(payload)
+	headers := make([]*sarama.RecordHeader, len(produced))
+	for i := range produced {
+		headers[i] = &produced[i]
+	}
+	return &sarama.ConsumerMessage{Headers: headers, Value: value}
+}
+
+func TestWantsMessage_FiltersOnChannelHeader(t *testing.T) {
+	handles := func(channel models.NotificationChannel) bool { return channel == models.ChannelInApp }
+	payload := models.JSONMap{"id": uuid.NewString(), "type": "order_shipped", "priority": "medium"}
+
+	payload["channel"] = models.ChannelInApp
+	assert.True(t, wantsMessage(consumerMessage(payload, nil), handles))
+
+	// The body is never decoded for other channels, so even garbage is skipped
+	payload["channel"] = models.ChannelSMS
+	assert.False(t, wantsMessage(consumerMessage(payload, []byte("not json")), handles))
+}
+
+func TestWantsMessage_AcceptsMessagesWithoutHeaders(t *testing.T) {
+	handles := func(models.NotificationChannel) bool { return false }
+	assert.True(t, wantsMessage(&sarama.ConsumerMessage{Value: []byte(`{"channel":"in_app"}`)}, handles))
+}
+
+func TestInAppSender_StoresUnderUser(t *testing.T) {
+	store := NewNotificationStore()
+	n := &models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelInApp, Message: "Time to practice"}
+
+	messageID, err := (&inAppSender{store: store}).Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, n.ID.String(), messageID)
+	require.Len(t, store.Get(n.UserID.String()), 1)
+	assert.Equal(t, "Time to practice", store.Get(n.UserID.String())[0].Message)
+}
+
+func TestNotificationStore_ReplacesRedeliveredNotification(t *testing.T) {
+	store := NewNotificationStore()
+	id := uuid.New()
+	store.Add(context.Background(), "alice", models.Notification{ID: id, Message: "first copy"})
+	snapshot, _ := store.snapshot("alice")
+
+	store.Add(context.Background(), "alice", models.Notification{ID: id, Message: "second copy"})
+
+	require.Len(t, store.Get("alice"), 1)
+	assert.Equal(t, "second copy", store.Get("alice")[0].Message)
+	assert.Equal(t, "first copy", snapshot[0].Message, "earlier snapshots are not changed")
+}
+
+// fakeClock is a settable clock for the store
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newClockedStore(opts ...StoreOption) (*NotificationStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	store := NewNotificationStore(opts...)
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestNotificationStore_GetReturnsNewestFirst(t *testing.T) {
+	store := NewNotificationStore()
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "first"})
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "second"})
+
+	notes := store.Get("alice")
+
+	require.Len(t, notes, 2)
+	assert.Equal(t, "second", notes[0].Message)
+	assert.Equal(t, "first", notes[1].Message)
+}
+
+func TestNotificationStore_CapDropsOldest(t *testing.T) {
+	store := NewNotificationStore(WithMaxPerUser(3))
+	for i := 1; i <= 5; i++ {
+		store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: strconv.Itoa(i)})
+	}
+	store.Add(context.Background(), "bob", models.Notification{ID: uuid.New()})
+
+	notes := store.Get("alice")
+
+	require.Len(t, notes, 3)
+	assert.Equal(t, []string{"5", "4", "3"}, []string{notes[0].Message, notes[1].Message, notes[2].Message})
+	assert.Len(t, store.Get("bob"), 1)
+	assert.Equal(t, StoreStats{Users: 2, Notifications: 4}, store.Stats())
+}
+
+func TestNotificationStore_DefaultCap(t *testing.T) {
+	store := NewNotificationStore()
+	for i := 0; i < DefaultMaxPerUser+10; i++ {
+		store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+	}
+
+	assert.Len(t, store.Get("alice"), DefaultMaxPerUser)
+}
+
+func TestNotificationStore_EvictRemovesExpired(t *testing.T) {
+	store, clock := newClockedStore(WithTTL(time.Hour))
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "old"})
+	store.Add(context.Background(), "bob", models.Notification{ID: uuid.New(), Message: "old"})
+	clock.Advance(45 * time.Minute)
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New(), Message: "recent"})
+	clock.Advance(30 * time.Minute)
+	version := store.Version("alice")
+
+	removed := store.Evict()
+
+	assert.Equal(t, 2, removed)
+	notes := store.Get("alice")
+	require.Len(t, notes, 1)
+	assert.Equal(t, "recent", notes[0].Message)
+	assert.Greater(t, store.Version("alice"), version, "eviction changes the ETag")
+	assert.Empty(t, store.Get("bob"))
+	assert.Equal(t, StoreStats{Users: 1, Notifications: 1}, store.Stats())
+}
+
+func TestNotificationStore_EvictKeepsRedeliveredAge(t *testing.T) {
+	store, clock := newClockedStore(WithTTL(time.Hour))
+	n := models.Notification{ID: uuid.New(), Message: "first copy"}
+	store.Add(context.Background(), "alice", n)
+	clock.Advance(59 * time.Minute)
+	n.Message = "second copy"
+	store.Add(context.Background(), "alice", n)
+	clock.Advance(2 * time.Minute)
+
+	assert.Equal(t, 1, store.Evict())
+	assert.Empty(t, store.Get("alice"))
+}
+
+func TestNotificationStore_NoTTLKeepsEverything(t *testing.T) {
+	store, clock := newClockedStore()
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+	clock.Advance(365 * 24 * time.Hour)
+
+	assert.Zero(t, store.Evict())
+	assert.Len(t, store.Get("alice"), 1)
+}
+
+func TestNotificationStore_ConcurrentEviction(t *testing.T) {
+	store, clock := newClockedStore(WithTTL(time.Minute), WithMaxPerUser(50))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+				clock.Advance(time.Second)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := store.Query(context.Background(), "alice", QueryOptions{})
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(result.Notifications), 50)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Evict()
+				store.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	clock.Advance(time.Hour)
+	store.Evict()
+	assert.Equal(t, StoreStats{}, store.Stats())
+}
+
+func TestNotificationStore_RunEvictionStopsWithContext(t *testing.T) {
+	store, clock := newClockedStore(WithTTL(time.Minute))
+	store.Add(context.Background(), "alice", models.Notification{ID: uuid.New()})
+	clock.Advance(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		store.RunEviction(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return store.Stats().Notifications == 0 }, time.Second, time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunEviction did not stop after cancel")
+	}
+}
+
+// fakeSession records the messages a ConsumeClaim marks
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return context.Background() }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+// fakeClaim hands out a fixed list of messages
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages  chan *sarama.ConsumerMessage
+	highWater int64
+}
+
+func newFakeClaim(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.messages <- msg
+	}
+	close(claim.messages)
+	return claim
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func (c *fakeClaim) HighWaterMarkOffset() int64 { return c.highWater }
+
+// statusStore accepts delivery attempts and statuses without storing them
+type statusStore struct{}
+
+func (statusStore) CreateDeliveryAttempt(context.Context, *models.NotificationDeliveryAttempt) error {
+	return nil
+}
+func (statusStore) MarkAsDelivered(context.Context, uuid.UUID) error { return nil }
+func (statusStore) MarkAsFailed(context.Context, uuid.UUID) error    { return nil }
+
+// failingInbox rejects every write
+type failingInbox struct{}
+
+func (failingInbox) Add(context.Context, string, models.Notification) error {
+	return errors.New("connection refused")
+}
+func (failingInbox) Query(context.Context, string, QueryOptions) (QueryResult, error) {
+	return QueryResult{}, errors.New("connection refused")
+}
+
+func newTestConsumer(store Store) *Consumer {
+	worker := delivery.NewWorker(delivery.WorkerConfig{MaxAttempts: 1}, statusStore{},
+		map[models.NotificationChannel]delivery.Sender{models.ChannelInApp: &inAppSender{store: store}})
+	return &Consumer{worker: worker, state: NewGroupState()}
+}
+
+func inAppMessage(t *testing.T, n models.Notification, offset int64) *sarama.ConsumerMessage {
+	value, err := json.Marshal(models.NewNotificationEvent(&n))
+	require.NoError(t, err)
+	return &sarama.ConsumerMessage{Value: value, Offset: offset}
+}
+
+func TestConsumeClaim_ReplayedMessageStoredOnce(t *testing.T) {
+	store := NewNotificationStore()
+	n := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelInApp, Message: "Time to practice"}
+	session := &fakeSession{}
+
+	err := newTestConsumer(store).ConsumeClaim(session, newFakeClaim(inAppMessage(t, n, 7), inAppMessage(t, n, 7)))
+
+	require.NoError(t, err)
+	assert.Len(t, store.Get(n.UserID.String()), 1)
+	assert.Equal(t, []int64{7, 7}, session.marked)
+}
+
+func TestConsumeClaim_ProducerMessagesIndexedByKey(t *testing.T) {
+	store := NewNotificationStore()
+	userID := uuid.New()
+	var msgs []*sarama.ConsumerMessage
+	for i, message := range []string{"Your streak is at risk", "Streak saved!"} {
+		entry, err := models.BuildOutboxEntry(&models.Notification{
+			ID: models.NewNotificationID(), UserID: userID, Channel: models.ChannelInApp, Message: message,
+		}, "notifications")
+		require.NoError(t, err)
+		key, value, err := models.EncodeOutboxPayload(entry.Payload)
+		require.NoError(t, err)
+		msgs = append(msgs, &sarama.ConsumerMessage{Key: []byte(key), Value: value, Offset: int64(i)})
+	}
+
+	err := newTestConsumer(store).ConsumeClaim(&fakeSession{}, newFakeClaim(msgs...))
+
+	require.NoError(t, err)
+	stored := store.Get(string(msgs[0].Key))
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Streak saved!", stored[0].Message, "newest first, in the order produced")
+	assert.Equal(t, "Your streak is at risk", stored[1].Message)
+}
+
+func TestConsumeClaim_InboxWriteFailureLeavesMessageUnmarked(t *testing.T) {
+	n := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelInApp, Message: "Time to practice"}
+	session := &fakeSession{}
+
+	err := newTestConsumer(failingInbox{}).ConsumeClaim(session, newFakeClaim(inAppMessage(t, n, 3), inAppMessage(t, n, 4)))
+
+	assert.ErrorIs(t, err, errInboxWrite)
+	assert.Empty(t, session.marked)
+}
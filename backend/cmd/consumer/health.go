@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupState tracks the consumer group's connection to Kafka for the health checks
+type GroupState struct {
+	mu  sync.RWMutex
+	now func() time.Time
+
+	startedAt         time.Time
+	sessionActive     bool
+	sessionSince      time.Time
+	lastSessionEnd    time.Time
+	lastConsumeReturn time.Time
+	lastError         string
+	lastErrorAt       time.Time
+	claims            map[string][]int32
+	lag               map[string]map[int32]int64
+}
+
+// NewGroupState creates a tracker for a consumer that has not connected yet
+func NewGroupState() *GroupState {
+	return newGroupState(time.Now)
+}
+
+func newGroupState(now func() time.Time) *GroupState {
+	return &GroupState{now: now, startedAt: now()}
+}
+
+// SessionStarted records a new consumer group session and its claimed partitions
+func (s *GroupState) SessionStarted(claims map[string][]int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionActive = true
+	s.sessionSince = s.now()
+	s.claims = claims
+	s.lag = make(map[string]map[int32]int64)
+}
+
+// SessionEnded records that the current session ended, e.g. for a rebalance
+func (s *GroupState) SessionEnded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionActive = false
+	s.lastSessionEnd = s.now()
+}
+
+// ConsumeReturned records a return from Consume; a nil error is a clean
+// return after a rebalance
+func (s *GroupState) ConsumeReturned(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastConsumeReturn = s.now()
+	if err != nil {
+		s.recordErrorLocked(err)
+	}
+}
+
+// RecordError records a failure to reach Kafka, such as creating the group
+func (s *GroupState) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordErrorLocked(err)
+}
+
+func (s *GroupState) recordErrorLocked(err error) {
+	s.lastError = err.Error()
+	s.lastErrorAt = s.now()
+}
+
+// RecordLag records how many messages of a claimed partition are left to consume
+func (s *GroupState) RecordLag(topic string, partition int32, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lag == nil {
+		s.lag = make(map[string]map[int32]int64)
+	}
+	if s.lag[topic] == nil {
+		s.lag[topic] = make(map[int32]int64)
+	}
+	s.lag[topic][partition] = lag
+}
+
+// GroupHealth is the consumer group state reported by the health checks
+type GroupHealth struct {
+	Ready             bool                       `json:"ready"`
+	Reason            string                     `json:"reason,omitempty"`
+	SessionActive     bool                       `json:"session_active"`
+	SessionSince      *time.Time                 `json:"session_since,omitempty"`
+	LastSessionEnd    *time.Time                 `json:"last_session_end,omitempty"`
+	LastConsumeReturn *time.Time                 `json:"last_consume_return,omitempty"`
+	LastError         string                     `json:"last_error,omitempty"`
+	LastErrorAt       *time.Time                 `json:"last_error_at,omitempty"`
+	Claims            map[string][]int32         `json:"claims,omitempty"`
+	Lag               map[string]map[int32]int64 `json:"lag,omitempty"`
+}
+
+// Health reports the group state. The consumer is ready while a session is
+// active, and for grace after starting or after its last session ended;
+// past that it is not ready until a session is established again.
+func (s *GroupState) Health(grace time.Duration) GroupHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	health := GroupHealth{
+		SessionActive:     s.sessionActive,
+		SessionSince:      timeOrNil(s.sessionSince),
+		LastSessionEnd:    timeOrNil(s.lastSessionEnd),
+		LastConsumeReturn: timeOrNil(s.lastConsumeReturn),
+		LastError:         s.lastError,
+		LastErrorAt:       timeOrNil(s.lastErrorAt),
+		Claims:            s.claims,
+		Lag:               copyLag(s.lag),
+	}
+
+	if s.sessionActive {
+		health.Ready = true
+		return health
+	}
+
+	since := s.startedAt
+	if s.lastSessionEnd.After(since) {
+		since = s.lastSessionEnd
+	}
+	if s.now().Sub(since) <= grace {
+		health.Ready = true
+		return health
+	}
+
+	if s.sessionSince.IsZero() {
+		health.Reason = "no consumer group session established since start"
+	} else {
+		health.Reason = "no consumer group session since " + s.lastSessionEnd.Format(time.RFC3339)
+	}
+	return health
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func copyLag(lag map[string]map[int32]int64) map[string]map[int32]int64 {
+	if len(lag) == 0 {
+		return nil
+	}
+	out := make(map[string]map[int32]int64, len(lag))
+	for topic, partitions := range lag {
+		out[topic] = make(map[int32]int64, len(partitions))
+		for partition, n := range partitions {
+			out[topic][partition] = n
+		}
+	}
+	return out
+}
+
+// healthDetails adds component details to the health payloads
+type healthDetails func(payload gin.H)
+
+// registerHealthRoutes adds /health, /health/live and /health/ready. Live only
+// says the process serves HTTP, so Kubernetes restarts it when it does not;
+// ready and /health answer 503 once the consumer group has had no session for
+// longer than grace, so it is taken out of rotation instead.
+func registerHealthRoutes(router gin.IRoutes, state *GroupState, grace time.Duration, details healthDetails) {
+	router.GET("/health/live", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":    "alive",
+			"service":   "kafka-consumer",
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+
+	ready := func(ctx *gin.Context) {
+		group := state.Health(grace)
+		payload := gin.H{
+			"status":    "healthy",
+			"service":   "kafka-consumer",
+			"timestamp": time.Now().Format(time.RFC3339),
+			"kafka":     group,
+		}
+		if details != nil {
+			details(payload)
+		}
+
+		if !group.Ready {
+			payload["status"] = "unhealthy"
+			ctx.JSON(http.StatusServiceUnavailable, payload)
+			return
+		}
+		ctx.JSON(http.StatusOK, payload)
+	}
+	router.GET("/health", ready)
+	router.GET("/health/ready", ready)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClockedGroupState() (*GroupState, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	return newGroupState(clock.Now), clock
+}
+
+func TestGroupState_ReadyDuringStartupGrace(t *testing.T) {
+	state, clock := newClockedGroupState()
+	clock.Advance(30 * time.Second)
+
+	assert.True(t, state.Health(time.Minute).Ready)
+}
+
+func TestGroupState_NotReadyWithoutSessionAfterGrace(t *testing.T) {
+	state, clock := newClockedGroupState()
+	state.RecordError(errors.New("kafka: client has run out of available brokers"))
+	clock.Advance(2 * time.Minute)
+
+	health := state.Health(time.Minute)
+
+	assert.False(t, health.Ready)
+	assert.Equal(t, "no consumer group session established since start", health.Reason)
+	assert.Equal(t, "kafka: client has run out of available brokers", health.LastError)
+	require.NotNil(t, health.LastErrorAt)
+}
+
+func TestGroupState_ActiveSessionIsReady(t *testing.T) {
+	state, clock := newClockedGroupState()
+	clock.Advance(5 * time.Minute)
+	state.SessionStarted(map[string][]int32{"notifications": {0, 1}})
+	state.RecordLag("notifications", 1, 42)
+	clock.Advance(time.Hour)
+
+	health := state.Health(time.Minute)
+
+	assert.True(t, health.Ready)
+	assert.True(t, health.SessionActive)
+	assert.Equal(t, map[string][]int32{"notifications": {0, 1}}, health.Claims)
+	assert.Equal(t, int64(42), health.Lag["notifications"][1])
+}
+
+func TestGroupState_LostSessionFailsAfterGrace(t *testing.T) {
+	state, clock := newClockedGroupState()
+	state.SessionStarted(map[string][]int32{"notifications": {0}})
+	clock.Advance(time.Hour)
+	state.SessionEnded()
+	state.ConsumeReturned(errors.New("broker went away"))
+
+	clock.Advance(30 * time.Second)
+	assert.True(t, state.Health(time.Minute).Ready, "a rebalance within the grace period stays ready")
+
+	clock.Advance(time.Minute)
+	health := state.Health(time.Minute)
+	assert.False(t, health.Ready)
+	assert.Contains(t, health.Reason, "no consumer group session since")
+	assert.Equal(t, "broker went away", health.LastError)
+	require.NotNil(t, health.LastConsumeReturn)
+}
+
+func TestGroupState_NegativeLagIsZero(t *testing.T) {
+	state, _ := newClockedGroupState()
+
+	state.RecordLag("notifications", 0, -1)
+
+	assert.Equal(t, int64(0), state.Health(time.Minute).Lag["notifications"][0])
+}
+
+func newHealthRouter(state *GroupState) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerHealthRoutes(router, state, time.Minute, func(payload gin.H) {
+		payload["active_connections"] = 3
+	})
+	return router
+}
+
+func getHealth(t *testing.T, router *gin.Engine, path string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func TestHealthRoutes_Unready(t *testing.T) {
+	state, clock := newClockedGroupState()
+	state.RecordError(errors.New("connection refused"))
+	clock.Advance(time.Hour)
+	router := newHealthRouter(state)
+
+	for _, path := range []string{"/health", "/health/ready"} {
+		code, body := getHealth(t, router, path)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code, path)
+		assert.Equal(t, "unhealthy", body["status"])
+		kafka := body["kafka"].(map[string]interface{})
+		assert.Equal(t, false, kafka["ready"])
+		assert.Equal(t, "connection refused", kafka["last_error"])
+		assert.Equal(t, float64(3), body["active_connections"])
+	}
+
+	code, body := getHealth(t, router, "/health/live")
+	assert.Equal(t, http.StatusOK, code, "liveness does not depend on Kafka")
+	assert.Equal(t, "alive", body["status"])
+}
+
+func TestHealthRoutes_Ready(t *testing.T) {
+	state, clock := newClockedGroupState()
+	state.SessionStarted(map[string][]int32{"notifications": {0}})
+	clock.Advance(time.Hour)
+
+	code, body := getHealth(t, newHealthRouter(state), "/health/ready")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "healthy", body["status"])
+	assert.Equal(t, true, body["kafka"].(map[string]interface{})["session_active"])
+}
+
+// claimsSession is a fakeSession reporting claimed partitions
+type claimsSession struct {
+	fakeSession
+	claims map[string][]int32
+}
+
+func (s *claimsSession) Claims() map[string][]int32 { return s.claims }
+
+func TestConsumer_TracksSessionAndLag(t *testing.T) {
+	consumer := newTestConsumer(NewNotificationStore())
+	session := &claimsSession{claims: map[string][]int32{"notifications": {2}}}
+	n := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelInApp}
+	msg := inAppMessage(t, n, 10)
+	msg.Topic, msg.Partition = "notifications", 2
+	claim := newFakeClaim(msg)
+	claim.highWater = 15
+
+	require.NoError(t, consumer.Setup(session))
+	require.NoError(t, consumer.ConsumeClaim(session, claim))
+	active := consumer.state.Health(time.Minute)
+	require.NoError(t, consumer.Cleanup(session))
+
+	assert.True(t, active.SessionActive)
+	assert.Equal(t, map[string][]int32{"notifications": {2}}, active.Claims)
+	assert.Equal(t, int64(4), active.Lag["notifications"][2])
+	assert.False(t, consumer.state.Health(time.Minute).SessionActive)
+}
+
+var _ sarama.ConsumerGroupSession = (*claimsSession)(nil)
+
+// fakeGroup is a consumer group whose Consume blocks until ctx ends
+type fakeGroup struct {
+	consumed chan struct{}
+	closed   bool
+}
+
+func (g *fakeGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	close(g.consumed)
+	<-ctx.Done()
+	return nil
+}
+
+func (g *fakeGroup) Errors() <-chan error                 { return nil }
+func (g *fakeGroup) Close() error                         { g.closed = true; return nil }
+func (g *fakeGroup) Pause(partitions map[string][]int32)  {}
+func (g *fakeGroup) Resume(partitions map[string][]int32) {}
+func (g *fakeGroup) PauseAll()                            {}
+func (g *fakeGroup) ResumeAll()                           {}
+
+func TestSetupConsumerGroup_RetriesCreationWithBackoff(t *testing.T) {
+	state := NewGroupState()
+	group := &fakeGroup{consumed: make(chan struct{})}
+	calls := 0
+	newGroup := func() (sarama.ConsumerGroup, error) {
+		calls++
+		if calls <= 3 {
+			return nil, sarama.ErrOutOfBrokers
+		}
+		return group, nil
+	}
+	retry := config.ConnectRetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Deadline: time.Nanosecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		setupConsumerGroup(ctx, newGroup, retry, nil, nil, state, []string{"notifications"})
+	}()
+
+	select {
+	case <-group.consumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer group was never created")
+	}
+	cancel()
+	<-done
+
+	// The deadline is ignored so the consumer outlasts any broker outage
+	assert.Equal(t, 4, calls)
+	assert.True(t, group.closed)
+	assert.Equal(t, sarama.ErrOutOfBrokers.Error(), state.Health(time.Minute).LastError)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// StoreMemory keeps in-app notifications in the consumer process
+	StoreMemory = "memory"
+	// StorePostgres serves in-app notifications from the notifications table
+	StorePostgres = "postgres"
+)
+
+// inboxLimit is how many of a user's latest in-app notifications the
+// Postgres store returns when a query sets no limit
+const inboxLimit = 200
+
+// Store holds the in-app notifications the consumer serves per user
+type Store interface {
+	// Add stores a delivered notification; adding one again replaces it
+	Add(ctx context.Context, userID string, notification models.Notification) error
+	// Query returns the page of the user's notifications, newest first, that
+	// opts selects, with an ETag that changes whenever the page does
+	Query(ctx context.Context, userID string, opts QueryOptions) (QueryResult, error)
+}
+
+// QueryOptions selects a page of a user's notifications
+type QueryOptions struct {
+	// Limit is the page size; 0 returns every match
+	Limit  int `json:"-"`
+	Offset int `json:"-"`
+	// UnreadOnly skips notifications that have been read
+	UnreadOnly bool `json:"unread_only"`
+	// Since skips notifications created before it
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// matches reports whether the notification passes the unread and since filters
+func (o QueryOptions) matches(n models.Notification) bool {
+	if o.UnreadOnly && n.ReadAt != nil {
+		return false
+	}
+	return o.Since == nil || !n.CreatedAt.Before(*o.Since)
+}
+
+// QueryResult is a page of a user's notifications
+type QueryResult struct {
+	Notifications []models.Notification
+	// Total counts every match, ignoring the limit and offset
+	Total int
+	ETag  string
+}
+
+// inboxRepository is the part of the notification repository the Postgres store uses
+type inboxRepository interface {
+	MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error
+	QueryUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error)
+	CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error)
+}
+
+// newStore returns the store selected by the inbox config
+func newStore(cfg config.InboxConfig, repo inboxRepository) (Store, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewNotificationStore(WithMaxPerUser(cfg.MaxPerUser), WithTTL(cfg.TTL)), nil
+	case StorePostgres:
+		return NewPostgresStore(repo), nil
+	default:
+		return nil, fmt.Errorf("unknown consumer store %q", cfg.Store)
+	}
+}
+
+// PostgresStore serves in-app notifications from the notifications table the
+// producer writes, so they survive consumer restarts and are shared by every
+// consumer replica
+type PostgresStore struct {
+	repo inboxRepository
+}
+
+// NewPostgresStore creates a store over the notification repository
+func NewPostgresStore(repo inboxRepository) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+// Add marks the notification's row delivered. The row is keyed on the
+// notification ID, so a redelivered message updates it instead of adding one.
+func (s *PostgresStore) Add(ctx context.Context, userID string, notification models.Notification) error {
+	return s.repo.MarkAsDelivered(ctx, notification.ID)
+}
+
+// Query returns a page of the user's delivered or read in-app notifications.
+// The ETag is a hash of the page and the total, so it survives restarts and
+// is the same on every replica.
+func (s *PostgresStore) Query(ctx context.Context, userID string, opts QueryOptions) (QueryResult, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		// No user has this ID, so there is nothing to show
+		return QueryResult{ETag: contentETag(nil, 0)}, nil
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = inboxLimit
+	}
+	filter := models.NotificationFilter{
+		Channels:   []models.NotificationChannel{models.ChannelInApp},
+		Statuses:   []models.DeliveryStatus{models.StatusDelivered, models.StatusRead},
+		UnreadOnly: opts.UnreadOnly,
+		Since:      opts.Since,
+		Sort:       models.SortNewest,
+		Limit:      limit,
+		Offset:     opts.Offset,
+	}
+
+	notes, err := s.repo.QueryUserNotifications(ctx, id, filter)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	total, err := s.repo.CountUserNotifications(ctx, id, filter)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	return QueryResult{Notifications: notes, Total: int(total), ETag: contentETag(notes, total)}, nil
+}
+
+// contentETag hashes what a poll shows of each notification and the total
+func contentETag(notes []models.Notification, total int64) string {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(total, 10)))
+	for _, n := range notes {
+		h.Write(n.ID[:])
+		h.Write([]byte(n.Status))
+		if n.ReadAt != nil {
+			h.Write([]byte(strconv.FormatInt(n.ReadAt.UnixNano(), 10)))
+		}
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+var (
+	_ Store = (*NotificationStore)(nil)
+	_ Store = (*PostgresStore)(nil)
+)
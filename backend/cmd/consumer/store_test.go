@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeFixture is a Store under test. seed records a notification the way the
+// producer does before it is delivered; stores that need no record ignore it.
+type storeFixture struct {
+	store   Store
+	newUser func(t *testing.T) string
+	seed    func(t *testing.T, userID string, n models.Notification)
+}
+
+// runStoreSuite checks the behaviour every Store implementation shares
+func runStoreSuite(t *testing.T, newFixture func(t *testing.T) storeFixture) {
+	ctx := context.Background()
+	base := time.Now().Truncate(time.Second)
+	newNote := func(t *testing.T, f storeFixture, userID string, offset int, message string) models.Notification {
+		n := models.Notification{
+			ID:        uuid.New(),
+			UserID:    uuid.MustParse(userID),
+			Type:      models.DailyReminder,
+			Channel:   models.ChannelInApp,
+			Message:   message,
+			Status:    models.StatusSent,
+			CreatedAt: base.Add(time.Duration(offset) * time.Minute),
+		}
+		f.seed(t, userID, n)
+		return n
+	}
+
+	t.Run("unknown user is empty", func(t *testing.T) {
+		f := newFixture(t)
+
+		result, err := f.store.Query(ctx, f.newUser(t), QueryOptions{})
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Notifications)
+		assert.Zero(t, result.Total)
+		assert.NotEmpty(t, result.ETag)
+	})
+
+	t.Run("added notifications are listed newest first", func(t *testing.T) {
+		f := newFixture(t)
+		userID := f.newUser(t)
+		first := newNote(t, f, userID, 0, "first")
+		second := newNote(t, f, userID, 1, "second")
+
+		require.NoError(t, f.store.Add(ctx, userID, first))
+		require.NoError(t, f.store.Add(ctx, userID, second))
+		result, err := f.store.Query(ctx, userID, QueryOptions{})
+
+		require.NoError(t, err)
+		notes := result.Notifications
+		require.Len(t, notes, 2)
+		assert.Equal(t, second.ID, notes[0].ID)
+		assert.Equal(t, first.ID, notes[1].ID)
+	})
+
+	t.Run("redelivered notification is listed once", func(t *testing.T) {
+		f := newFixture(t)
+		userID := f.newUser(t)
+		n := newNote(t, f, userID, 0, "Time to practice")
+
+		require.NoError(t, f.store.Add(ctx, userID, n))
+		require.NoError(t, f.store.Add(ctx, userID, n))
+		result, err := f.store.Query(ctx, userID, QueryOptions{})
+
+		require.NoError(t, err)
+		require.Len(t, result.Notifications, 1)
+		assert.Equal(t, n.ID, result.Notifications[0].ID)
+	})
+
+	t.Run("etag changes only when the list does", func(t *testing.T) {
+		f := newFixture(t)
+		userID := f.newUser(t)
+		require.NoError(t, f.store.Add(ctx, userID, newNote(t, f, userID, 0, "first")))
+
+		before, err := f.store.Query(ctx, userID, QueryOptions{})
+		require.NoError(t, err)
+		unchanged, err := f.store.Query(ctx, userID, QueryOptions{})
+		require.NoError(t, err)
+		require.NoError(t, f.store.Add(ctx, userID, newNote(t, f, userID, 1, "second")))
+		after, err := f.store.Query(ctx, userID, QueryOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, before.ETag, unchanged.ETag)
+		assert.NotEqual(t, before.ETag, after.ETag)
+	})
+
+	t.Run("limit and offset page through newest first", func(t *testing.T) {
+		f := newFixture(t)
+		userID := f.newUser(t)
+		var added []models.Notification
+		for i := 0; i < 5; i++ {
+			n := newNote(t, f, userID, i, strconv.Itoa(i))
+			require.NoError(t, f.store.Add(ctx, userID, n))
+			added = append(added, n)
+		}
+
+		result, err := f.store.Query(ctx, userID, QueryOptions{Limit: 2, Offset: 1})
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, result.Total)
+		require.Len(t, result.Notifications, 2)
+		assert.Equal(t, added[3].ID, result.Notifications[0].ID)
+		assert.Equal(t, added[2].ID, result.Notifications[1].ID)
+	})
+
+	t.Run("unread and since filter", func(t *testing.T) {
+		f := newFixture(t)
+		userID := f.newUser(t)
+		old := newNote(t, f, userID, 0, "old")
+		readAt := base.Add(3 * time.Minute)
+		read := models.Notification{
+			ID: uuid.New(), UserID: uuid.MustParse(userID), Type: models.DailyReminder, Channel: models.ChannelInApp,
+			Message: "read", Status: models.StatusRead, CreatedAt: base.Add(2 * time.Minute), ReadAt: &readAt,
+		}
+		f.seed(t, userID, read)
+		recent := newNote(t, f, userID, 4, "recent")
+		for _, n := range []models.Notification{old, read, recent} {
+			require.NoError(t, f.store.Add(ctx, userID, n))
+		}
+		since := base.Add(time.Minute)
+
+		unread, err := f.store.Query(ctx, userID, QueryOptions{UnreadOnly: true})
+		require.NoError(t, err)
+		newer, err := f.store.Query(ctx, userID, QueryOptions{Since: &since})
+		require.NoError(t, err)
+		both, err := f.store.Query(ctx, userID, QueryOptions{UnreadOnly: true, Since: &since})
+		require.NoError(t, err)
+
+		assert.Equal(t, []uuid.UUID{recent.ID, old.ID}, notificationIDs(unread.Notifications))
+		assert.Equal(t, []uuid.UUID{recent.ID, read.ID}, notificationIDs(newer.Notifications))
+		assert.Equal(t, []uuid.UUID{recent.ID}, notificationIDs(both.Notifications))
+		assert.Equal(t, 1, both.Total)
+	})
+
+	t.Run("users do not see each other's notifications", func(t *testing.T) {
+		f := newFixture(t)
+		alice, bob := f.newUser(t), f.newUser(t)
+		require.NoError(t, f.store.Add(ctx, alice, newNote(t, f, alice, 0, "for alice")))
+
+		result, err := f.store.Query(ctx, bob, QueryOptions{})
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Notifications)
+	})
+}
+
+func newUserID(*testing.T) string { return uuid.NewString() }
+
+func notificationIDs(notes []models.Notification) []uuid.UUID {
+	ids := make([]uuid.UUID, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func TestStore_Memory(t *testing.T) {
+	runStoreSuite(t, func(t *testing.T) storeFixture {
+		return storeFixture{
+			store:   NewNotificationStore(),
+			newUser: newUserID,
+			seed:    func(*testing.T, string, models.Notification) {},
+		}
+	})
+}
+
+// fakeInboxRepository keeps notification rows in memory
+type fakeInboxRepository struct {
+	mu   sync.Mutex
+	rows map[uuid.UUID]models.Notification
+}
+
+func (r *fakeInboxRepository) MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n, ok := r.rows[notificationID]; ok {
+		n.Status = models.StatusDelivered
+		r.rows[notificationID] = n
+	}
+	return nil
+}
+
+func (r *fakeInboxRepository) QueryUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error) {
+	notes := r.matching(userID, filter)
+	if filter.Offset >= len(notes) {
+		return nil, nil
+	}
+	notes = notes[filter.Offset:]
+	if len(notes) > filter.Limit {
+		notes = notes[:filter.Limit]
+	}
+	return notes, nil
+}
+
+func (r *fakeInboxRepository) CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error) {
+	return int64(len(r.matching(userID, filter))), nil
+}
+
+// matching returns the user's rows the filter selects, newest first
+func (r *fakeInboxRepository) matching(userID uuid.UUID, filter models.NotificationFilter) []models.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notes []models.Notification
+	for _, n := range r.rows {
+		if n.UserID != userID || !containsChannel(filter.Channels, n.Channel) || !containsStatus(filter.Statuses, n.Status) {
+			continue
+		}
+		if (filter.UnreadOnly && n.ReadAt != nil) || (filter.Since != nil && n.CreatedAt.Before(*filter.Since)) {
+			continue
+		}
+		notes = append(notes, n)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].CreatedAt.After(notes[j].CreatedAt) })
+	return notes
+}
+
+func containsChannel(channels []models.NotificationChannel, channel models.NotificationChannel) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(statuses []models.DeliveryStatus, status models.DeliveryStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStore_PostgresWithFakeRepository(t *testing.T) {
+	runStoreSuite(t, func(t *testing.T) storeFixture {
+		repo := &fakeInboxRepository{rows: make(map[uuid.UUID]models.Notification)}
+		return storeFixture{
+			store:   NewPostgresStore(repo),
+			newUser: newUserID,
+			seed: func(_ *testing.T, _ string, n models.Notification) {
+				repo.mu.Lock()
+				defer repo.mu.Unlock()
+				repo.rows[n.ID] = n
+			},
+		}
+	})
+}
+
+// consumerIntegrationDB connects to a real, migrated Postgres using the DB_*
+// settings, skipping the test unless CONSUMER_INTEGRATION_DB is set
+func consumerIntegrationDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if os.Getenv("CONSUMER_INTEGRATION_DB") == "" {
+		t.Skip("set CONSUMER_INTEGRATION_DB to run against Postgres")
+	}
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	conn, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.GetDB()
+}
+
+func TestStore_Postgres(t *testing.T) {
+	db := consumerIntegrationDB(t)
+	repo := repository.NewPostgresNotificationRepository(db)
+
+	runStoreSuite(t, func(t *testing.T) storeFixture {
+		return storeFixture{
+			store: NewPostgresStore(repo),
+			newUser: func(t *testing.T) string {
+				userID := uuid.New()
+				_, err := db.Exec(`INSERT INTO users (user_id, name, email) VALUES ($1, 'Seeded', $2)`,
+					userID, userID.String()+"@example.com")
+				require.NoError(t, err)
+				t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE user_id = $1`, userID) })
+				return userID.String()
+			},
+			seed: func(t *testing.T, userID string, n models.Notification) {
+				_, err := db.Exec(`
+					INSERT INTO notifications (id, user_id, type, channel, message, status, created_at, read_at)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				`, n.ID, userID, n.Type, n.Channel, n.Message, n.Status, n.CreatedAt, n.ReadAt)
+				require.NoError(t, err)
+			},
+		}
+	})
+}
+
+func TestNewStore_SelectsImplementation(t *testing.T) {
+	repo := &fakeInboxRepository{}
+
+	memory, err := newStore(config.InboxConfig{}, repo)
+	require.NoError(t, err)
+	assert.IsType(t, &NotificationStore{}, memory)
+
+	postgres, err := newStore(config.InboxConfig{Store: StorePostgres}, repo)
+	require.NoError(t, err)
+	assert.IsType(t, &PostgresStore{}, postgres)
+
+	_, err = newStore(config.InboxConfig{Store: "redis"}, repo)
+	assert.ErrorContains(t, err, `unknown consumer store "redis"`)
+}
+
+func TestPostgresStore_InvalidUserIDIsEmpty(t *testing.T) {
+	store := NewPostgresStore(&fakeInboxRepository{})
+
+	result, err := store.Query(context.Background(), "alice", QueryOptions{})
+
+	require.NoError(t, err)
+	assert.Empty(t, result.Notifications)
+	assert.NotEmpty(t, result.ETag)
+}
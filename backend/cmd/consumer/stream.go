@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// subscriberBuffer is how many notifications a subscriber may fall behind
+// before it is disconnected
+const subscriberBuffer = 32
+
+// Broker fans in-app notifications out to the streams open for their user
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.Notification]struct{}
+}
+
+// NewBroker creates a broker with no subscribers
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[chan models.Notification]struct{})}
+}
+
+// Subscribe registers a stream for the user. The returned channel receives
+// the user's notifications until unsubscribe is called, or is closed if the
+// stream falls more than subscriberBuffer notifications behind.
+func (b *Broker) Subscribe(userID string) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan models.Notification]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.remove(userID, ch)
+		})
+	}
+}
+
+// Publish sends the notification to every stream open for the user without
+// waiting; a stream whose buffer is full is disconnected so the client
+// reconnects and catches up with Last-Event-ID
+func (b *Broker) Publish(userID string, n models.Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[userID] {
+		select {
+		case ch <- n:
+		default:
+			log.Printf("Disconnecting slow notification stream for user %s", userID)
+			b.remove(userID, ch)
+		}
+	}
+}
+
+// Count returns the number of open streams
+func (b *Broker) Count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, subs := range b.subscribers {
+		count += len(subs)
+	}
+	return count
+}
+
+// remove closes and forgets a subscriber that is still registered; b.mu must be held
+func (b *Broker) remove(userID string, ch chan models.Notification) {
+	subs := b.subscribers[userID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, userID)
+	}
+}
+
+// handleStream serves GET /notifications/:userID/stream as Server-Sent Events.
+// Each notification is an `event: notification` frame whose id is the
+// notification ID. A client reconnecting with Last-Event-ID first gets the
+// stored notifications newer than that one; when it is no longer stored, every
+// stored notification is replayed.
+func handleStream(ctx *gin.Context, store Store, broker *Broker, heartbeat time.Duration) {
+	userID, err := getUserIDFromRequest(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Subscribe before replaying so nothing stored in between is missed
+	live, unsubscribe := broker.Subscribe(userID)
+	defer unsubscribe()
+
+	var replay []models.Notification
+	if lastID := ctx.GetHeader("Last-Event-ID"); lastID != "" {
+		result, err := store.Query(ctx.Request.Context(), userID, QueryOptions{})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to load notifications",
+				"details": err.Error(),
+			})
+			return
+		}
+		replay = newerThan(result.Notifications, lastID)
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	sent := make(map[string]bool, len(replay))
+	for _, n := range replay {
+		if err := writeEvent(ctx.Writer, n); err != nil {
+			return
+		}
+		sent[n.ID.String()] = true
+	}
+	ctx.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n, ok := <-live:
+			if !ok {
+				return
+			}
+			if sent[n.ID.String()] {
+				continue
+			}
+			if err := writeEvent(ctx.Writer, n); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Request.Context().Done():
+			return
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// newerThan returns the notifications listed before lastID in a newest-first
+// list, oldest first; all of them when lastID is not in the list
+func newerThan(notes []models.Notification, lastID string) []models.Notification {
+	var newer []models.Notification
+	for _, n := range notes {
+		if n.ID.String() == lastID {
+			break
+		}
+		newer = append(newer, n)
+	}
+	for i, j := 0, len(newer)-1; i < j; i, j = i+1, j-1 {
+		newer[i], newer[j] = newer[j], newer[i]
+	}
+	return newer
+}
+
+// writeEvent writes the notification as an SSE frame
+func writeEvent(w io.Writer, n models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification %s: %w", n.ID, err)
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", n.ID, data)
+	return err
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseFrame is one event read off a stream; comments have only comment set
+type sseFrame struct {
+	id, event, data, comment string
+}
+
+// sseReader reads frames from a streaming response body
+type sseReader struct {
+	frames chan sseFrame
+}
+
+func newSSEReader(t *testing.T, resp *http.Response) *sseReader {
+	t.Helper()
+	r := &sseReader{frames: make(chan sseFrame, 64)}
+	go func() {
+		defer close(r.frames)
+		scanner := bufio.NewScanner(resp.Body)
+		var frame sseFrame
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				r.frames <- frame
+				frame = sseFrame{}
+			case strings.HasPrefix(line, ":"):
+				frame.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				frame.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				frame.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				frame.data = line[len("data: "):]
+			}
+		}
+	}()
+	return r
+}
+
+// next returns the next frame, failing the test if none arrives in time
+func (r *sseReader) next(t *testing.T) sseFrame {
+	t.Helper()
+	select {
+	case frame, ok := <-r.frames:
+		require.True(t, ok, "stream closed")
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an SSE frame")
+		return sseFrame{}
+	}
+}
+
+// nextEvent skips heartbeats and returns the next notification frame
+func (r *sseReader) nextEvent(t *testing.T) sseFrame {
+	t.Helper()
+	for {
+		if frame := r.next(t); frame.event != "" {
+			return frame
+		}
+	}
+}
+
+func newStreamServer(t *testing.T, store Store, broker *Broker, heartbeat time.Duration) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/notifications/:userID/stream", func(ctx *gin.Context) {
+		handleStream(ctx, store, broker, heartbeat)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openStream connects to the user's stream, returning once it is subscribed
+func openStream(t *testing.T, server *httptest.Server, broker *Broker, userID, lastEventID string) (*sseReader, context.CancelFunc) {
+	t.Helper()
+	subscribed := broker.Count()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/notifications/"+userID+"/stream", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	require.Eventually(t, func() bool { return broker.Count() > subscribed }, time.Second, time.Millisecond)
+	return newSSEReader(t, resp), cancel
+}
+
+func decodeFrame(t *testing.T, frame sseFrame) models.Notification {
+	t.Helper()
+	var n models.Notification
+	require.NoError(t, json.Unmarshal([]byte(frame.data), &n))
+	return n
+}
+
+func TestStream_DeliversStoredNotifications(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, time.Hour)
+	sender := &inAppSender{store: store, broker: broker}
+	userID := uuid.New()
+	stream, _ := openStream(t, server, broker, userID.String(), "")
+
+	n := &models.Notification{ID: uuid.New(), UserID: userID, Channel: models.ChannelInApp, Message: "Time to practice"}
+	_, err := sender.Send(context.Background(), n)
+	require.NoError(t, err)
+
+	frame := stream.nextEvent(t)
+	assert.Equal(t, "notification", frame.event)
+	assert.Equal(t, n.ID.String(), frame.id)
+	assert.Equal(t, "Time to practice", decodeFrame(t, frame).Message)
+}
+
+func TestStream_OnlyReceivesOwnNotifications(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, time.Hour)
+	sender := &inAppSender{store: store, broker: broker}
+	alice, bob := uuid.New(), uuid.New()
+	stream, _ := openStream(t, server, broker, alice.String(), "")
+
+	_, err := sender.Send(context.Background(), &models.Notification{ID: uuid.New(), UserID: bob, Message: "for bob"})
+	require.NoError(t, err)
+	_, err = sender.Send(context.Background(), &models.Notification{ID: uuid.New(), UserID: alice, Message: "for alice"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "for alice", decodeFrame(t, stream.nextEvent(t)).Message)
+}
+
+func TestStream_SendsHeartbeats(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, 10*time.Millisecond)
+	stream, _ := openStream(t, server, broker, "alice", "")
+
+	frame := stream.next(t)
+
+	assert.Equal(t, "heartbeat", frame.comment)
+	assert.Empty(t, frame.event)
+}
+
+func TestStream_ReplaysFromLastEventID(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, time.Hour)
+	var ids []uuid.UUID
+	for i := 0; i < 4; i++ {
+		id := uuid.New()
+		ids = append(ids, id)
+		require.NoError(t, store.Add(context.Background(), "alice", models.Notification{ID: id}))
+	}
+
+	stream, _ := openStream(t, server, broker, "alice", ids[1].String())
+
+	assert.Equal(t, ids[2].String(), stream.nextEvent(t).id)
+	assert.Equal(t, ids[3].String(), stream.nextEvent(t).id)
+}
+
+func TestStream_UnknownLastEventIDReplaysEverything(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, time.Hour)
+	first, second := uuid.New(), uuid.New()
+	require.NoError(t, store.Add(context.Background(), "alice", models.Notification{ID: first}))
+	require.NoError(t, store.Add(context.Background(), "alice", models.Notification{ID: second}))
+
+	stream, _ := openStream(t, server, broker, "alice", uuid.NewString())
+
+	assert.Equal(t, first.String(), stream.nextEvent(t).id)
+	assert.Equal(t, second.String(), stream.nextEvent(t).id)
+}
+
+func TestStream_DisconnectUnsubscribes(t *testing.T) {
+	store, broker := NewNotificationStore(), NewBroker()
+	server := newStreamServer(t, store, broker, time.Hour)
+	_, cancel := openStream(t, server, broker, "alice", "")
+	require.Equal(t, 1, broker.Count())
+
+	cancel()
+
+	assert.Eventually(t, func() bool { return broker.Count() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestBroker_DisconnectsSlowSubscriber(t *testing.T) {
+	broker := NewBroker()
+	slow, unsubscribe := broker.Subscribe("alice")
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish("alice", models.Notification{ID: uuid.New()})
+	}
+
+	assert.Zero(t, broker.Count())
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "buffered notifications are drained before the channel closes")
+}
+
+func TestBroker_UnsubscribeIsIdempotent(t *testing.T) {
+	broker := NewBroker()
+	_, unsubscribe := broker.Subscribe("alice")
+	_, other := broker.Subscribe("alice")
+	defer other()
+
+	unsubscribe()
+	unsubscribe()
+
+	assert.Equal(t, 1, broker.Count())
+}
+
+func TestNewerThan(t *testing.T) {
+	a, b, c := models.Notification{ID: uuid.New()}, models.Notification{ID: uuid.New()}, models.Notification{ID: uuid.New()}
+	newestFirst := []models.Notification{c, b, a}
+
+	assert.Equal(t, []models.Notification{b, c}, newerThan(newestFirst, a.ID.String()))
+	assert.Empty(t, newerThan(newestFirst, c.ID.String()))
+	assert.Equal(t, []models.Notification{a, b, c}, newerThan(newestFirst, "unknown"))
+}
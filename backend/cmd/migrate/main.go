@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+	"kafka-notify/internal/schema"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// consistencyCheckLimit caps how many mismatched notifications are reported
+const consistencyCheckLimit = 1000
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  verify       Compare the database schema with the expected schema and print drift as JSON.
+               Exits 1 when drift is found.
+  consistency  Check that denormalized notification fields match their source rows and print
+               mismatches as JSON. Exits 1 when mismatches are found.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "verify":
+		os.Exit(runVerify())
+	case "consistency":
+		os.Exit(runConsistency())
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runVerify prints the schema verification report and returns the process exit code
+func runVerify() int {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 2
+	}
+
+	dbManager, err := database.NewConnectionManager(&cfg.Database)
+	if err != nil {
+		log.Printf("Failed to initialize database: %v", err)
+		return 2
+	}
+	defer dbManager.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := schema.Verify(ctx, dbManager.GetDB())
+	if err != nil {
+		log.Printf("Failed to verify schema: %v", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("Failed to encode report: %v", err)
+		return 2
+	}
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// runConsistency prints notifications whose denormalized attempt summary is stale
+func runConsistency() int {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 2
+	}
+
+	dbManager, err := database.NewConnectionManager(&cfg.Database)
+	if err != nil {
+		log.Printf("Failed to initialize database: %v", err)
+		return 2
+	}
+	defer dbManager.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	repo := repository.NewPostgresNotificationRepository(dbManager.GetDB())
+	mismatches, err := repo.GetAttemptSummaryMismatches(ctx, consistencyCheckLimit)
+	if err != nil {
+		log.Printf("Failed to check attempt summaries: %v", err)
+		return 2
+	}
+	if mismatches == nil {
+		mismatches = []uuid.UUID{}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{
+		"ok":                         len(mismatches) == 0,
+		"attempt_summary_mismatches": mismatches,
+	}); err != nil {
+		log.Printf("Failed to encode report: %v", err)
+		return 2
+	}
+
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,19 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"sync"
 	"time"
 
+	"kafka-notify/internal/auth"
 	"kafka-notify/internal/config"
 	"kafka-notify/internal/database"
+	"kafka-notify/internal/events"
 	"kafka-notify/internal/kafka"
+	"kafka-notify/internal/leader"
+	"kafka-notify/internal/maintenance"
+	"kafka-notify/internal/metrics"
+	"kafka-notify/internal/middleware"
+	"kafka-notify/internal/schema"
 	"kafka-notify/internal/server"
 	"kafka-notify/internal/services"
+	"kafka-notify/internal/slo"
 	"kafka-notify/pkg/handlers"
 	"kafka-notify/pkg/repository"
+
+	"github.com/IBM/sarama"
+	"github.com/gin-gonic/gin"
 )
 
+// kafkaHealthTimeout bounds the Kafka check behind /health
+const kafkaHealthTimeout = 2 * time.Second
+
 func main() {
+	check := flag.Bool("check", false, "verify the database schema, print drift as JSON and exit")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -28,33 +50,170 @@ func main() {
 	}
 	defer dbManager.Close()
 
+	// Preflight: report schema drift and exit without starting the service
+	if *check {
+		os.Exit(runSchemaCheck(dbManager))
+	}
+
 	// Initialize Kafka client manager
 	kafkaManager := kafka.NewClientManager(&cfg.Kafka)
+	if _, err := kafkaManager.NewConfig(); err != nil {
+		log.Fatalf("Failed to configure Kafka clients: %v", err)
+	}
+	defer kafkaManager.Close()
 
-	// Create Kafka producer
-	producer, err := kafkaManager.NewProducer()
-	if err != nil {
-		log.Fatalf("Failed to create Kafka producer: %v", err)
+	// The producer connects in the background so the API serves reads while
+	// Kafka is still starting; the outbox waits until it is ready. In async
+	// mode the outbox is published through an async producer instead.
+	var producer sarama.SyncProducer
+	var connector producerConnector
+	var producerOpts []services.Option
+	if cfg.Kafka.ProducerConfig.Mode == config.ProducerModeAsync {
+		asyncProducer := kafkaManager.NewPendingAsyncProducer()
+		defer asyncProducer.Close()
+		connector = asyncProducer
+		producerOpts = append(producerOpts, services.WithAsyncProducer(asyncProducer.Producer))
+	} else {
+		managedProducer := kafkaManager.NewPendingManagedProducer()
+		defer kafkaManager.CloseProducer(managedProducer)
+		producer, connector = managedProducer, managedProducer
 	}
-	defer kafkaManager.CloseProducer(producer)
 
 	// Initialize repository
 	notificationRepo := repository.NewPostgresNotificationRepository(dbManager.GetDB())
 
 	// Initialize notification service
-	notificationService := services.NewNotificationService(notificationRepo, producer, cfg.Kafka.Topic)
+	notificationService := services.NewNotificationService(notificationRepo, producer, cfg.Kafka.Topic, append(producerOpts,
+		services.WithPriorityTopics(cfg.Kafka.Topics),
+		services.WithMaxMessageBytes(cfg.Kafka.ProducerConfig.MaxMessageBytes),
+		services.WithAttachmentHosts(cfg.Attachments.AllowedHosts),
+		services.WithOutboxBatchSize(cfg.Outbox.BatchSize),
+		services.WithOutboxRetention(cfg.Outbox.Retention),
+		services.WithOutboxMaxAttempts(cfg.Outbox.MaxAttempts),
+		services.WithOutboxBackoff(cfg.Outbox.BackoffBase, cfg.Outbox.BackoffMax),
+		services.WithDedupeWindow(cfg.Dedupe.Window),
+		services.WithDeliveryRetry(cfg.Delivery.RequeueMaxAttempts, cfg.Delivery.RequeueBackoffBase, cfg.Delivery.RequeueBackoffMax),
+	)...)
+
+	// Initialize delivery latency SLO monitor
+	sloCalculator, err := slo.NewCalculator(cfg.SLO)
+	if err != nil {
+		log.Fatalf("Invalid SLO configuration: %v", err)
+	}
+	sloMonitor := slo.NewMonitor(sloCalculator, notificationRepo, cfg.SLO.RefreshInterval, slo.LogAlertHook{})
+
+	// Initialize maintenance mode flag
+	maintenanceFlag := maintenance.NewFlag(repository.NewPostgresSystemSettingsRepository(dbManager.GetDB()), maintenance.DefaultCacheTTL)
 
 	// Initialize HTTP handlers
 	notificationHandlers := handlers.NewNotificationHandlers(notificationService)
+	streakService := services.NewStreakService(notificationRepo)
+	eventHandlers := handlers.NewEventHandlers(newEventRegistry(notificationService, streakService))
+	streakHandlers := handlers.NewStreakHandlers(streakService)
+	sloHandlers := handlers.NewSLOHandlers(sloMonitor)
+	maintenanceHandlers := handlers.NewMaintenanceHandlers(maintenanceFlag)
+	deviceHandlers := handlers.NewDeviceHandlers(repository.NewPostgresDeviceRepository(dbManager.GetDB()))
+	webhookHandlers := handlers.NewWebhookHandlers(repository.NewPostgresWebhookRepository(dbManager.GetDB()))
+	goalHandlers := handlers.NewGoalHandlers(repository.NewPostgresGoalRepository(dbManager.GetDB()))
+	announcementHandlers := handlers.NewAnnouncementHandlers(repository.NewPostgresAnnouncementRepository(dbManager.GetDB()))
+	configHandlers := handlers.NewConfigHandlers(cfg)
+
+	// User routes need a JWT; config only allows running without one in development
+	var verifier *auth.Verifier
+	if cfg.Auth.Enabled() {
+		if verifier, err = auth.NewVerifier(cfg.Auth); err != nil {
+			log.Fatalf("Failed to set up authentication: %v", err)
+		}
+	} else {
+		log.Println("Warning: AUTH_JWT_SECRET and AUTH_JWKS_URL are unset; user routes are unauthenticated")
+	}
 
 	// Initialize HTTP server
-	httpServer := server.NewServer(&cfg.Server)
+	httpServer := server.NewServer(&cfg.Server, cfg.CORS)
+	httpServer.AddHealthDetail("maintenance", func(ctx context.Context) interface{} {
+		return maintenanceFlag.State(ctx)
+	})
+
+	// Only the replica holding the advisory lock runs the outbox processor
+	outboxLeader := leader.NewElector(dbManager.GetDB(), leader.OutboxProcessorKey, "outbox processor")
+	httpServer.AddHealthDetail("outbox_leader", func(context.Context) interface{} {
+		return outboxLeader.Leading()
+	})
+	httpServer.AddHealthDetail("kafka", func(ctx context.Context) interface{} {
+		ctx, cancel := context.WithTimeout(ctx, kafkaHealthTimeout)
+		defer cancel()
+		if err := kafkaManager.HealthCheck(ctx); err != nil {
+			return "down"
+		}
+		return "up"
+	})
+	httpServer.AddHealthDetail("kafka_producer_ready", func(context.Context) interface{} {
+		return connector.Ready()
+	})
 
 	// Setup routes
-	setupRoutes(httpServer, notificationHandlers)
+	setupRoutes(httpServer, notificationHandlers, eventHandlers, streakHandlers, sloHandlers, maintenanceHandlers, deviceHandlers, webhookHandlers, goalHandlers, announcementHandlers, configHandlers, middleware.Auth(verifier), middleware.APIKeyAuth(cfg.Server.InternalAPIKeys), cfg.Server.AdminToken)
+
+	// Sarama's own client metrics: request latency, batch sizes, send rates
+	httpServer.AddRoute("GET", "/metrics/kafka", func(c *gin.Context) {
+		c.JSON(http.StatusOK, kafkaManager.Metrics())
+	})
+
+	// Background workers run until the HTTP server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var workers sync.WaitGroup
+
+	// Connect to Kafka, creating the topics first when asked to
+	go connectKafka(ctx, kafkaManager, connector, cfg.Kafka)
 
 	// Start outbox processor in background
-	go startOutboxProcessor(notificationService)
+	outboxProcessor := services.NewOutboxProcessor(notificationService, cfg.Outbox.Interval, cfg.Outbox.Jitter, maintenanceFlag, outboxLeader)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outboxProcessor.Run(ctx)
+	}()
+
+	// Start outbox purge job in background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		startOutboxPurger(ctx, notificationService, cfg.Outbox.PurgeInterval)
+	}()
+
+	// Start outbox stats logger in background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		startOutboxStatsLogger(ctx, notificationService, cfg.Outbox.StatsInterval)
+	}()
+
+	// Start scheduled notification dispatcher in background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		startScheduledDispatcher(ctx, notificationService, cfg.Scheduler.DispatchInterval)
+	}()
+
+	// Start failed delivery retrier in background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		startFailedRetrier(ctx, notificationService, cfg.Delivery.RequeueInterval)
+	}()
+
+	// Start SLO monitor in background
+	go sloMonitor.Run(ctx)
+
+	// Stop the workers, letting an in-flight outbox batch finish, before the
+	// deferred producer and database closes run
+	httpServer.OnShutdown(func() {
+		cancel()
+		workers.Wait()
+		outboxLeader.Release()
+		log.Println("Background workers stopped")
+	})
 
 	// Start HTTP server
 	log.Printf("Starting producer service on port %s", cfg.Server.Port)
@@ -63,45 +222,275 @@ func main() {
 	}
 }
 
+// runSchemaCheck prints the schema verification report and returns the process exit code
+func runSchemaCheck(dbManager *database.ConnectionManager) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := schema.Verify(ctx, dbManager.GetDB())
+	if err != nil {
+		log.Printf("Schema check failed: %v", err)
+		return 2
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+		log.Printf("Failed to encode schema report: %v", err)
+		return 2
+	}
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// newEventRegistry maps each product event type accepted by POST /events to
+// the notifications and streak updates it causes
+func newEventRegistry(notificationService services.NotificationService, streakService services.StreakService) *events.Registry {
+	registry := events.NewRegistry(events.Services{Notifications: notificationService, Streaks: streakService})
+	registry.Register(events.PracticeCompleted, events.HandlePracticeCompleted)
+	registry.Register(events.LessonCompleted, events.HandleLessonCompleted)
+	registry.Register(events.LevelUp, events.HandleLevelUp)
+	registry.Register(events.CourseEnrolled, events.HandleCourseEnrolled)
+	return registry
+}
+
 // setupRoutes configures the HTTP routes
-func setupRoutes(server *server.Server, handlers *handlers.NotificationHandlers) {
+func setupRoutes(server *server.Server, handlers *handlers.NotificationHandlers, eventHandlers *handlers.EventHandlers, streakHandlers *handlers.StreakHandlers, sloHandlers *handlers.SLOHandlers, maintenanceHandlers *handlers.MaintenanceHandlers, deviceHandlers *handlers.DeviceHandlers, webhookHandlers *handlers.WebhookHandlers, goalHandlers *handlers.GoalHandlers, announcementHandlers *handlers.AnnouncementHandlers, configHandlers *handlers.ConfigHandlers, requireUser, requireService gin.HandlerFunc, adminToken string) {
 	// Health check is already set up in the server
 
+	// Prometheus metrics
+	server.AddRoute("GET", "/metrics", metrics.Handler())
+
 	// API routes
 	api := server.AddGroup("/api/v1")
 
 	// Notification routes
-	api.POST("/notifications", handlers.CreateNotification)
-	api.GET("/notifications/:userID", handlers.GetUserNotifications)
-	api.PUT("/notifications/:id/read", handlers.MarkAsRead)
+	api.POST("/notifications/:id/republish", middleware.AdminToken(adminToken), handlers.RepublishNotification)
+	api.POST("/notifications/:id/retry", middleware.AdminToken(adminToken), handlers.RetryNotification)
+
+	// Announcement routes; the scheduler fans queued announcements out
+	api.POST("/announcements", middleware.AdminToken(adminToken), announcementHandlers.CreateAnnouncement)
+	api.GET("/announcements/:id", middleware.AdminToken(adminToken), announcementHandlers.GetAnnouncement)
+
+	// User routes act for the user in the path and need their bearer token;
+	// each handler checks it with middleware.AuthorizeUser. The group keeps
+	// their paths.
+	user := api.Group("", requireUser)
+	user.GET("/notifications/by-dedupe-key", handlers.GetNotificationByDedupeKey)
+	// The static id segment keeps single lookups apart from :userID
+	user.GET("/notifications/id/:id", handlers.GetNotification)
+	user.GET("/notifications/:userID", handlers.GetUserNotifications)
+	user.GET("/notifications/:userID/unread-count", handlers.GetUnreadCount)
+	user.PUT("/notifications/:id/read", handlers.MarkAsRead)
+	// :id is the user here; gin requires one wildcard name per segment
+	user.PUT("/notifications/:id/read-all", handlers.MarkAllAsRead)
+	user.DELETE("/notifications/:id", handlers.CancelNotification)
 
 	// Preference routes
-	api.PUT("/preferences/:userID", handlers.UpdateUserPreferences)
-	api.GET("/preferences/:userID", handlers.GetUserPreferences)
+	user.PUT("/preferences/:userID", handlers.UpdateUserPreferences)
+	user.PATCH("/preferences/:userID", handlers.PatchUserPreferences)
+	user.PUT("/preferences/:userID/bulk", handlers.UpdateUserPreferencesBulk)
+	user.GET("/preferences/:userID", handlers.GetUserPreferences)
+	user.GET("/preferences/:userID/:type/:channel", handlers.GetUserPreference)
+	user.DELETE("/preferences/:userID", handlers.DeleteUserPreferences)
+
+	// Streak routes
+	user.GET("/streaks/:userID", streakHandlers.GetStreak)
+	user.POST("/streaks/:userID/activity", streakHandlers.RecordActivity)
+
+	// Push device routes
+	user.POST("/devices/:userID", deviceHandlers.RegisterDevice)
+	user.DELETE("/devices/:userID", deviceHandlers.UnregisterDevice)
 
-	// Reminder routes
-	api.POST("/reminders/daily", handlers.CreateDailyReminder)
-	api.POST("/reminders/streak", handlers.CreateStreakReminder)
+	// Goal routes
+	user.POST("/goals/:userID", goalHandlers.SetGoal)
+	user.GET("/goals/:userID", goalHandlers.GetGoals)
+	user.PUT("/goals/:userID/:goalID", goalHandlers.UpdateGoal)
+	user.DELETE("/goals/:userID/:goalID", goalHandlers.DeleteGoal)
 
-	// Event routes (POC)
-	api.POST("/events/practice-completed", handlers.PracticeCompleted)
+	// Webhook routes
+	user.POST("/webhooks/:userID", webhookHandlers.RegisterWebhook)
 
 	// Outbox processing
-	api.POST("/outbox/process", handlers.ProcessOutbox)
+	api.POST("/outbox/purge", middleware.AdminToken(adminToken), handlers.PurgeOutbox)
+	api.GET("/outbox/stats", middleware.AdminToken(adminToken), handlers.GetOutboxStats)
+	api.GET("/outbox/dead", middleware.AdminToken(adminToken), handlers.ListDeadOutbox)
+	api.POST("/outbox/dead/:id/retry", middleware.AdminToken(adminToken), handlers.RetryDeadOutbox)
+
+	// Internal routes for other services and cron jobs, keyed by INTERNAL_API_KEYS;
+	// the group keeps their paths
+	internal := api.Group("", requireService)
+	internal.POST("/notifications", handlers.CreateNotification)
+	internal.POST("/notifications/from-template", handlers.CreateFromTemplate)
+	internal.POST("/events", eventHandlers.IngestEvent)
+	internal.POST("/events/practice-completed", eventHandlers.PracticeCompleted)
+	internal.PUT("/notifications/:id/delivered", handlers.MarkAsDelivered)
+	internal.POST("/reminders/daily", handlers.CreateDailyReminder)
+	internal.POST("/reminders/streak", handlers.CreateStreakReminder)
+	internal.POST("/outbox/process", handlers.ProcessOutbox)
+
+	// Admin routes
+	admin := api.Group("/admin", middleware.AdminToken(adminToken))
+	admin.POST("/notifications/backfill", handlers.BackfillNotification)
+	admin.GET("/slo", sloHandlers.GetSLO)
+	admin.POST("/debug/replay-decisions", handlers.ReplayDecisions)
+	admin.GET("/maintenance", maintenanceHandlers.GetMaintenance)
+	admin.POST("/maintenance", maintenanceHandlers.SetMaintenance)
+	admin.GET("/config", configHandlers.GetConfig)
 }
 
-// startOutboxProcessor starts the background outbox processor
-func startOutboxProcessor(notificationService services.NotificationService) {
-	ticker := time.NewTicker(30 * time.Second) // Process every 30 seconds
+// producerConnector is the outbox producer for the configured KAFKA_PRODUCER_MODE
+type producerConnector interface {
+	Connect(ctx context.Context, policy config.ConnectRetryConfig) error
+	Ready() bool
+}
+
+// connectKafka creates the topics when KAFKA_AUTO_CREATE_TOPICS is set and then
+// connects the producer, both retried with backoff. Giving up after the
+// KAFKA_CONNECT_DEADLINE is fatal so an orchestrator can restart the service.
+func connectKafka(ctx context.Context, manager *kafka.ClientManager, producer producerConnector, cfg config.KafkaConfig) {
+	if cfg.AutoCreateTopics {
+		err := kafka.ConnectWithRetry(ctx, cfg.ConnectRetry, "Kafka topic creation", func() error {
+			return manager.EnsureTopics(ctx)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Fatalf("Failed to create Kafka topics: %v", err)
+			}
+			return
+		}
+	}
+
+	if err := producer.Connect(ctx, cfg.ConnectRetry); err != nil {
+		if ctx.Err() == nil {
+			log.Fatalf("Failed to create Kafka producer: %v", err)
+		}
+		return
+	}
+	log.Printf("Kafka producer connected")
+}
+
+// startOutboxPurger periodically deletes published outbox items older than the
+// retention window and idempotency keys older than services.IdempotencyKeyTTL
+func startOutboxPurger(ctx context.Context, notificationService services.NotificationService, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Outbox purge disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Starting outbox purger (every %s)...", interval)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		purgeCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		deleted, err := notificationService.PurgeOutbox(purgeCtx, time.Time{})
+		if err != nil {
+			log.Printf("Outbox purge error after deleting %d rows: %v", deleted, err)
+		} else if deleted > 0 {
+			log.Printf("Outbox purged: deleted=%d", deleted)
+		}
+		deleted, err = notificationService.PurgeIdempotencyKeys(purgeCtx)
+		if err != nil {
+			log.Printf("Idempotency key purge error after deleting %d rows: %v", deleted, err)
+		} else if deleted > 0 {
+			log.Printf("Idempotency keys purged: deleted=%d", deleted)
+		}
+		cancel()
+	}
+}
+
+// startScheduledDispatcher periodically releases notifications whose
+// scheduled_for has arrived to the outbox
+func startScheduledDispatcher(ctx context.Context, notificationService services.NotificationService, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Scheduled dispatch disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Starting scheduled dispatcher (every %s)...", interval)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		dispatchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if err := notificationService.DispatchScheduled(dispatchCtx); err != nil {
+			log.Printf("Scheduled dispatch error: %v", err)
+		}
+		cancel()
+	}
+}
+
+// startFailedRetrier periodically requeues failed notifications whose
+// delivery may succeed on another attempt
+func startFailedRetrier(ctx context.Context, notificationService services.NotificationService, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Failed delivery retry disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Println("Starting outbox processor...")
+	log.Printf("Starting failed delivery retrier (every %s)...", interval)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
 
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		if err := notificationService.ProcessOutbox(ctx); err != nil {
-			log.Printf("Outbox processing error: %v", err)
+		retryCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if _, err := notificationService.RetryFailed(retryCtx); err != nil {
+			log.Printf("Failed delivery retry error: %v", err)
 		}
 		cancel()
 	}
 }
+
+// startOutboxStatsLogger periodically logs the outbox backlog so a stalled
+// outbox shows up in the logs even without scraping /outbox/stats
+func startOutboxStatsLogger(ctx context.Context, notificationService services.NotificationService, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Outbox stats logging disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		statsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		stats, err := notificationService.GetOutboxStats(statsCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Outbox stats error: %v", err)
+			continue
+		}
+		log.Printf("Outbox stats: pending=%d dead=%d published_last_hour=%d oldest_pending_age=%s",
+			stats.Pending, stats.Dead, stats.PublishedLastHour,
+			time.Duration(stats.OldestPendingAgeSeconds*float64(time.Second)).Round(time.Second))
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/middleware"
+	"kafka-notify/internal/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRunsLimit and maxRunsLimit bound how many runs GET /admin/jobs/runs returns
+const (
+	defaultRunsLimit = 20
+	maxRunsLimit     = 100
+)
+
+// namedJob is a scheduler job with the name its loop logs and locks under
+type namedJob struct {
+	// name is the scheduler the job belongs to, whose lock it runs under
+	name string
+	run  schedulerJob
+}
+
+// schedulerJobs returns every scheduler job by its short name, the name the
+// admin API runs it by and its run history is recorded under
+func (s *SchedulerService) schedulerJobs() map[string]namedJob {
+	return map[string]namedJob{
+		"daily":        {"Daily reminder", s.processDailyReminders},
+		"streak":       {"Streak reminder", s.processStreakReminders},
+		"last_chance":  {"Last chance alert", s.processLastChanceAlerts},
+		"weekly":       {"Weekly recap", s.processWeeklyRecaps},
+		"nudge":        {"Engagement nudge", s.processEngagementNudges},
+		"xp_goal":      {"XP goal reminder", s.processXPGoalReminders},
+		"announcement": {"Announcement fanout", s.processAnnouncements},
+		"typical_hour": {"Typical practice hour", s.processTypicalPracticeHours},
+	}
+}
+
+// startAdminServer serves the admin API until ctx ends; an empty admin port
+// disables it
+func (s *SchedulerService) startAdminServer(ctx context.Context) {
+	if s.config.AdminPort == "" {
+		log.Println("Scheduler admin API disabled")
+		return
+	}
+
+	// Jobs can take a while, so responses have no write timeout. The admin
+	// API is for operators, not browsers, so no origin is allowed.
+	srv := server.NewServer(&config.ServerConfig{
+		Port:        s.config.AdminPort,
+		ReadTimeout: 30 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	}, config.CORSConfig{})
+	s.registerAdminRoutes(srv.GetRouter())
+
+	if err := srv.Run(ctx); err != nil {
+		log.Printf("Scheduler admin API error: %v", err)
+	}
+}
+
+// registerAdminRoutes adds the admin API behind the scheduler's admin token
+func (s *SchedulerService) registerAdminRoutes(router gin.IRouter) {
+	admin := router.Group("/admin", middleware.AdminToken(s.config.AdminToken))
+	admin.POST("/jobs/:name/run", s.runJob)
+	admin.GET("/jobs/runs", s.listRuns)
+}
+
+// runJob handles POST /admin/jobs/:name/run, running a scheduler job now.
+// With dry_run=true it only reports the users the job would notify.
+func (s *SchedulerService) runJob(c *gin.Context) {
+	job, ok := s.schedulerJobs()[c.Param("name")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown job"})
+		return
+	}
+
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid dry_run parameter",
+				"details": err.Error(),
+			})
+			return
+		}
+		dryRun = parsed
+	}
+
+	// A dry run only reads, so it does not wait for the job's lock
+	var result *jobResult
+	var err error
+	ran := true
+	if dryRun {
+		result, err = job.run(c.Request.Context(), true)
+	} else {
+		result, ran, err = s.runExclusive(c.Request.Context(), job.name, job.run, false)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Job failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !ran {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is already running on another replica"})
+		return
+	}
+
+	log.Printf("%s job run through the admin API (dry run: %t): %d users", job.name, dryRun, len(result.UserIDs))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Job completed",
+		"data":    result,
+	})
+}
+
+// listRuns handles GET /admin/jobs/runs, listing recent scheduler runs newest
+// first, optionally only those of the job given by name
+func (s *SchedulerService) listRuns(c *gin.Context) {
+	if s.runs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Run history is not recorded"})
+		return
+	}
+
+	name := c.Query("name")
+	if _, ok := s.schedulerJobs()[name]; name != "" && !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown job"})
+		return
+	}
+
+	limit := defaultRunsLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxRunsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit parameter",
+				"details": fmt.Sprintf("limit must be between 1 and %d", maxRunsLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := s.runs.ListSchedulerRuns(c.Request.Context(), name, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list scheduler runs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": runs})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAdminRouter serves the admin API of a scheduler over repo
+func newAdminRouter(repo *reminderRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	s := newPagedScheduler(repo, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC))
+	s.config.AdminToken = "secret"
+	router := gin.New()
+	s.registerAdminRoutes(router)
+	return router
+}
+
+func runAdminJob(t *testing.T, router *gin.Engine, path, token string) (*httptest.ResponseRecorder, jobResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data jobResult `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return w, body.Data
+}
+
+// userIDs returns the IDs of the users in order
+func userIDs(users []models.User) []uuid.UUID {
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+func TestRunJob_DryRunCreatesNothing(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(3)
+	repo := &reminderRepository{users: users}
+	router := newAdminRouter(repo)
+
+	// Act
+	w, result := runAdminJob(t, router, "/admin/jobs/daily/run?dry_run=true", "secret")
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 3, result.Selected)
+	assert.Equal(t, userIDs(users), result.UserIDs)
+	assert.Empty(t, repo.queued)
+	assert.Empty(t, repo.outbox)
+	assert.Empty(t, repo.stored)
+}
+
+func TestRunJob_RealRunQueuesReminders(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(3)
+	repo := &reminderRepository{users: users}
+	router := newAdminRouter(repo)
+
+	// Act
+	w, result := runAdminJob(t, router, "/admin/jobs/daily/run", "secret")
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, result.DryRun)
+	assert.Equal(t, userIDs(users), result.UserIDs)
+	assert.Len(t, repo.queued, 3)
+	assert.Len(t, repo.outbox, 3)
+}
+
+func TestRunJob_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{name: "missing token", path: "/admin/jobs/daily/run", status: http.StatusUnauthorized},
+		{name: "wrong token", path: "/admin/jobs/daily/run", token: "guess", status: http.StatusUnauthorized},
+		{name: "unknown job", path: "/admin/jobs/monthly/run", token: "secret", status: http.StatusNotFound},
+		{name: "invalid dry_run", path: "/admin/jobs/daily/run?dry_run=maybe", token: "secret", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &reminderRepository{users: eligibleUsers(1)}
+
+			w, _ := runAdminJob(t, newAdminRouter(repo), tt.path, tt.token)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Empty(t, repo.queued)
+		})
+	}
+}
+
+// listedRuns answers run history queries with fixed runs
+type listedRuns struct {
+	runHistory
+	runs    []models.SchedulerRun
+	jobName string
+	limit   int
+}
+
+func (h *listedRuns) ListSchedulerRuns(ctx context.Context, jobName string, limit int) ([]models.SchedulerRun, error) {
+	h.jobName, h.limit = jobName, limit
+	return h.runs, nil
+}
+
+func TestListRuns(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		status  int
+		jobName string
+		limit   int
+	}{
+		{name: "defaults", query: "", status: http.StatusOK, limit: 20},
+		{name: "one job", query: "?name=daily&limit=5", status: http.StatusOK, jobName: "daily", limit: 5},
+		{name: "unknown job", query: "?name=monthly", status: http.StatusNotFound},
+		{name: "limit too large", query: "?limit=1000", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			gin.SetMode(gin.TestMode)
+			history := &listedRuns{runs: []models.SchedulerRun{{ID: 1, JobName: "daily"}}}
+			s := newPagedScheduler(&reminderRepository{}, time.Now())
+			s.config.AdminToken = "secret"
+			s.runs = history
+			router := gin.New()
+			s.registerAdminRoutes(router)
+
+			// Act
+			req := httptest.NewRequest(http.MethodGet, "/admin/jobs/runs"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.jobName, history.jobName)
+			assert.Equal(t, tt.limit, history.limit)
+			assert.Contains(t, w.Body.String(), `"job_name":"daily"`)
+		})
+	}
+}
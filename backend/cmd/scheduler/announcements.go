@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// startAnnouncementScheduler starts the announcement fanout scheduler
+func (s *SchedulerService) startAnnouncementScheduler(ctx context.Context) {
+	s.runScheduler(ctx, "Announcement fanout", s.config.AnnouncementInterval, s.processAnnouncements)
+}
+
+// processAnnouncements fans out every unfinished announcement, oldest first.
+// A dry run only walks the recipients of the next one.
+func (s *SchedulerService) processAnnouncements(ctx context.Context, dryRun bool) (*jobResult, error) {
+	result := &jobResult{DryRun: dryRun, UserIDs: []uuid.UUID{}}
+	if s.announcements == nil {
+		return result, nil
+	}
+
+	for {
+		announcement, err := s.announcements.GetNextUnfinishedAnnouncement(ctx)
+		if errors.Is(err, repository.ErrAnnouncementNotFound) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to get next announcement: %w", err)
+		}
+
+		if err := s.fanoutAnnouncement(ctx, announcement, dryRun, result); err != nil {
+			return result, err
+		}
+		if dryRun {
+			return result, nil
+		}
+	}
+}
+
+// fanoutAnnouncement notifies the announcement's segment a page of users at
+// a time, resuming after the last user an earlier fanout stored a page for,
+// and marks it completed after the last page. Each page is stored together
+// with the fanout's progress, so a crash between pages neither skips nor
+// repeats anyone.
+func (s *SchedulerService) fanoutAnnouncement(ctx context.Context, announcement *models.Announcement, dryRun bool, result *jobResult) error {
+	pageSize := s.batchSize()
+	after := uuid.Nil
+	if announcement.LastUserID != nil {
+		after = *announcement.LastUserID
+	}
+
+	if !dryRun {
+		if err := s.announcements.StartAnnouncement(ctx, announcement.ID, s.now()); err != nil {
+			return err
+		}
+	}
+
+	for page := 1; ; page++ {
+		users, err := s.announcements.GetAnnouncementRecipients(ctx, announcement, pageSize, after)
+		if err != nil {
+			return fmt.Errorf("failed to get recipients for announcement %d: %w", announcement.ID, err)
+		}
+		result.Selected += len(users)
+
+		if dryRun {
+			for _, user := range users {
+				result.UserIDs = append(result.UserIDs, user.ID)
+			}
+		} else if len(users) > 0 {
+			notifications, outbox, err := s.buildAnnouncementPage(announcement, users)
+			if err != nil {
+				return err
+			}
+			last := users[len(users)-1].ID
+			if err := s.announcements.SaveAnnouncementPage(ctx, announcement.ID, notifications, outbox, last); err != nil {
+				return fmt.Errorf("failed to store announcement %d page %d: %w", announcement.ID, page, err)
+			}
+			for _, user := range users {
+				result.UserIDs = append(result.UserIDs, user.ID)
+			}
+			result.Created += len(notifications)
+			log.Printf("Fanned out announcement %d page %d: %d users, through user %s", announcement.ID, page, len(users), last)
+		}
+
+		if len(users) < pageSize {
+			if dryRun {
+				return nil
+			}
+			return s.announcements.CompleteAnnouncement(ctx, announcement.ID, s.now())
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+// buildAnnouncementPage creates the announcement's new_course notification and
+// outbox entry for each user, tagged with the announcement's ID
+func (s *SchedulerService) buildAnnouncementPage(announcement *models.Announcement, users []models.User) ([]*models.Notification, []*models.OutboxNotification, error) {
+	notifications := make([]*models.Notification, 0, len(users))
+	outbox := make([]*models.OutboxNotification, 0, len(users))
+	for _, user := range users {
+		metadata := models.JSONMap{}
+		for key, value := range announcement.Metadata {
+			metadata[key] = value
+		}
+		metadata["announcement_id"] = announcement.ID
+
+		notification := &models.Notification{
+			ID:        models.NewNotificationID(),
+			UserID:    user.ID,
+			Type:      models.NewCourse,
+			Channel:   models.ChannelInApp,
+			Priority:  models.PriorityLow,
+			Title:     stringPtr(announcement.Title),
+			Message:   announcement.Message,
+			Metadata:  metadata,
+			Status:    models.StatusQueued,
+			CreatedAt: s.now(),
+		}
+
+		outboxItem, err := models.BuildOutboxEntry(notification, s.kafka.TopicFor(notification.Priority))
+		if err != nil {
+			return nil, nil, err
+		}
+		notifications = append(notifications, notification)
+		outbox = append(outbox, outboxItem)
+	}
+	return notifications, outbox, nil
+}
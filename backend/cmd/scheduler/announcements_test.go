@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// announcementStore keeps announcements in memory, with every user in each
+// announcement's segment. The failOnSave'th page save fails, standing in for
+// a crash mid-fanout.
+type announcementStore struct {
+	repository.AnnouncementRepository
+	announcements []*models.Announcement
+	recipients    []models.User
+	failOnSave    int
+	saves         int
+
+	notified []*models.Notification
+	outbox   []*models.OutboxNotification
+}
+
+func (a *announcementStore) GetNextUnfinishedAnnouncement(ctx context.Context) (*models.Announcement, error) {
+	for _, announcement := range a.announcements {
+		if announcement.Status != models.AnnouncementCompleted {
+			copied := *announcement
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrAnnouncementNotFound
+}
+
+func (a *announcementStore) find(id int64) *models.Announcement {
+	for _, announcement := range a.announcements {
+		if announcement.ID == id {
+			return announcement
+		}
+	}
+	return nil
+}
+
+func (a *announcementStore) StartAnnouncement(ctx context.Context, id int64, startedAt time.Time) error {
+	a.find(id).Status = models.AnnouncementSending
+	return nil
+}
+
+func (a *announcementStore) GetAnnouncementRecipients(ctx context.Context, announcement *models.Announcement, limit int, after uuid.UUID) ([]models.User, error) {
+	start := sort.Search(len(a.recipients), func(i int) bool { return a.recipients[i].ID.String() > after.String() })
+	return a.recipients[start:min(start+limit, len(a.recipients))], nil
+}
+
+func (a *announcementStore) SaveAnnouncementPage(ctx context.Context, id int64, notifications []*models.Notification, outboxItems []*models.OutboxNotification, lastUserID uuid.UUID) error {
+	a.saves++
+	if a.saves == a.failOnSave {
+		return errors.New("connection reset")
+	}
+	a.notified = append(a.notified, notifications...)
+	a.outbox = append(a.outbox, outboxItems...)
+	announcement := a.find(id)
+	announcement.LastUserID = &lastUserID
+	announcement.NotificationsCreated += len(notifications)
+	return nil
+}
+
+func (a *announcementStore) CompleteAnnouncement(ctx context.Context, id int64, completedAt time.Time) error {
+	announcement := a.find(id)
+	announcement.Status = models.AnnouncementCompleted
+	announcement.CompletedAt = &completedAt
+	return nil
+}
+
+func newAnnouncementScheduler(store *announcementStore) *SchedulerService {
+	s := newPagedScheduler(&reminderRepository{}, time.Now())
+	s.announcements = store
+	return s
+}
+
+func TestProcessAnnouncements_FansOutInPages(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(5)
+	store := &announcementStore{
+		announcements: []*models.Announcement{{
+			ID: 9, Title: "New Course: Spanish", Message: "Spanish is here!",
+			Metadata: models.JSONMap{"course_id": "es-101"}, Segment: models.SegmentAll, Status: models.AnnouncementPending,
+		}},
+		recipients: users,
+	}
+	s := newAnnouncementScheduler(store)
+
+	// Act
+	result, err := s.processAnnouncements(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Created)
+	require.Len(t, store.notified, 5)
+	assert.Len(t, store.outbox, 5)
+	for i, n := range store.notified {
+		assert.Equal(t, users[i].ID, n.UserID)
+		assert.Equal(t, models.NewCourse, n.Type)
+		assert.Equal(t, int64(9), n.Metadata["announcement_id"])
+		assert.Equal(t, "es-101", n.Metadata["course_id"])
+	}
+	assert.Equal(t, models.AnnouncementCompleted, store.announcements[0].Status)
+	assert.Equal(t, 5, store.announcements[0].NotificationsCreated)
+}
+
+func TestProcessAnnouncements_ResumesAfterCrash(t *testing.T) {
+	// Arrange: the second page fails to save, as if the scheduler crashed there
+	users := eligibleUsers(5)
+	store := &announcementStore{
+		announcements: []*models.Announcement{{ID: 1, Title: "New Course", Message: "Go is here!", Segment: models.SegmentAll, Status: models.AnnouncementPending}},
+		recipients:    users,
+		failOnSave:    2,
+	}
+	s := newAnnouncementScheduler(store)
+
+	// Act
+	crashed, crashErr := s.processAnnouncements(context.Background(), false)
+	resumed, resumeErr := s.processAnnouncements(context.Background(), false)
+
+	// Assert
+	assert.ErrorContains(t, crashErr, "failed to store announcement 1 page 2")
+	assert.Equal(t, 2, crashed.Created, "the first page was stored before the crash")
+	require.NoError(t, resumeErr)
+	assert.Equal(t, 3, resumed.Created, "the fanout resumes after the stored page")
+
+	notified := make(map[uuid.UUID]int)
+	for _, n := range store.notified {
+		notified[n.UserID]++
+	}
+	for _, user := range users {
+		assert.Equal(t, 1, notified[user.ID], "user %s is notified once", user.ID)
+	}
+	assert.Equal(t, models.AnnouncementCompleted, store.announcements[0].Status)
+	assert.Equal(t, 5, store.announcements[0].NotificationsCreated)
+}
+
+func TestProcessAnnouncements_DryRunLeavesAnnouncementPending(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(3)
+	store := &announcementStore{
+		announcements: []*models.Announcement{{ID: 1, Title: "New Course", Message: "Go is here!", Segment: models.SegmentAll, Status: models.AnnouncementPending}},
+		recipients:    users,
+	}
+	s := newAnnouncementScheduler(store)
+
+	// Act
+	result, err := s.processAnnouncements(context.Background(), true)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Selected)
+	assert.Equal(t, userIDs(users), result.UserIDs)
+	assert.Empty(t, store.notified)
+	assert.Equal(t, models.AnnouncementPending, store.announcements[0].Status)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// dbRoundTrip approximates one statement over a pooled remote connection
+const dbRoundTrip = 50 * time.Microsecond
+
+// roundTripRepository charges one round trip per statement: BEGIN, each
+// INSERT and COMMIT
+type roundTripRepository struct {
+	repository.NotificationRepository
+}
+
+func (r *roundTripRepository) CreateNotificationWithOutbox(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification) error {
+	time.Sleep(4 * dbRoundTrip)
+	return nil
+}
+
+func (r *roundTripRepository) CreateNotificationsWithOutbox(ctx context.Context, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error {
+	time.Sleep(4 * dbRoundTrip)
+	return nil
+}
+
+func benchmarkBatch(size int) *reminderBatch {
+	batch := &reminderBatch{}
+	for i := 0; i < size; i++ {
+		n := newReminder(uuid.New(), time.Now())
+		batch.add(n, &models.OutboxNotification{NotificationID: n.ID, Topic: "notifications"}, nil)
+	}
+	return batch
+}
+
+func BenchmarkStoreReminders(b *testing.B) {
+	ctx := context.Background()
+	s := newSchedulerService(testSchedulerConfig(), &roundTripRepository{}, nil)
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	b.Run("OneByOne", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := s.storeRemindersOneByOne(ctx, benchmarkBatch(defaultBatchSize)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := s.storeReminders(ctx, benchmarkBatch(defaultBatchSize)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
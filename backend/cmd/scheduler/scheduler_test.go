@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/leader"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maintenanceSwitch is a maintenance checker toggled by the test
+type maintenanceSwitch struct {
+	active bool
+}
+
+func (m *maintenanceSwitch) Active(ctx context.Context) bool {
+	return m.active
+}
+
+func testSchedulerConfig() *config.Config {
+	return &config.Config{
+		Kafka: config.KafkaConfig{
+			Topic:  "notifications",
+			Topics: map[models.PriorityLevel]string{models.PriorityLow: "notifications-low"},
+		},
+		Scheduler: config.SchedulerConfig{
+			DailyReminderInterval:   time.Minute,
+			StreakReminderInterval:  2 * time.Minute,
+			WeeklyRecapInterval:     12 * time.Hour,
+			EngagementNudgeInterval: 3 * time.Hour,
+			StreakReminderBuffer:    30 * time.Minute,
+			DefaultPracticeHour:     19,
+			LastChanceStartHour:     20,
+			LastChanceEndHour:       23,
+			XPGoalReminderHour:      18,
+			XPGoalThreshold:         0.7,
+		},
+	}
+}
+
+func TestNewSchedulerService_UsesInjectedConfig(t *testing.T) {
+	cfg := testSchedulerConfig()
+	maintenance := &maintenanceSwitch{}
+
+	s := newSchedulerService(cfg, &reminderRepository{}, maintenance)
+
+	assert.Equal(t, time.Minute, s.config.DailyReminderInterval)
+	assert.Equal(t, 2*time.Minute, s.config.StreakReminderInterval)
+	assert.Equal(t, 12*time.Hour, s.config.WeeklyRecapInterval)
+	assert.Equal(t, 3*time.Hour, s.config.EngagementNudgeInterval)
+	assert.Equal(t, 30*time.Minute, s.streakPolicy.Buffer)
+	assert.Equal(t, 19, s.streakPolicy.DefaultHour)
+	assert.Same(t, maintenance, s.maintenance)
+}
+
+func TestRunScheduler_ZeroIntervalDisablesScheduler(t *testing.T) {
+	s := newSchedulerService(testSchedulerConfig(), &reminderRepository{}, nil)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		s.runScheduler(context.Background(), "Weekly recap", 0, func(context.Context, bool) (*jobResult, error) {
+			t.Error("a disabled scheduler must not run")
+			return nil, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runScheduler kept running with a zero interval")
+	}
+}
+
+// countingMaintenance reports maintenance mode on every tick, so no pass
+// touches the database, and counts the ticks it was asked about
+type countingMaintenance struct {
+	ticks atomic.Int64
+}
+
+func (m *countingMaintenance) Active(ctx context.Context) bool {
+	m.ticks.Add(1)
+	return true
+}
+
+func TestRun_ShutdownStopsEveryLoop(t *testing.T) {
+	cfg := testSchedulerConfig()
+	cfg.Scheduler.DailyReminderInterval = time.Millisecond
+	cfg.Scheduler.StreakReminderInterval = time.Millisecond
+	cfg.Scheduler.WeeklyRecapInterval = time.Millisecond
+	cfg.Scheduler.EngagementNudgeInterval = time.Millisecond
+	cfg.Scheduler.LastChanceInterval = time.Millisecond
+	cfg.Scheduler.XPGoalInterval = time.Millisecond
+	cfg.Scheduler.AnnouncementInterval = time.Millisecond
+	cfg.Scheduler.TypicalHourInterval = time.Millisecond
+	maintenance := &countingMaintenance{}
+	s := newSchedulerService(cfg, &reminderRepository{}, maintenance)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- s.Run(ctx) }()
+	require.Eventually(t, func() bool { return maintenance.ticks.Load() >= 10 }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after shutdown")
+	}
+	ticks := maintenance.ticks.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, ticks, maintenance.ticks.Load(), "a scheduler loop kept ticking after shutdown")
+}
+
+func TestCreateEngagementNudge_UsesConfiguredTopic(t *testing.T) {
+	user := models.User{ID: uuid.New(), Name: "Ada"}
+	repo := &reminderRepository{streaks: inactiveStreaks([]models.User{user}, 7)}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+
+	batch := &reminderBatch{}
+	err := s.createEngagementNudge(context.Background(), batch, user)
+	require.NoError(t, err)
+	err = s.storeReminders(context.Background(), batch)
+
+	require.NoError(t, err)
+	require.Len(t, repo.outbox, 1)
+	assert.Equal(t, "notifications-low", repo.outbox[0].Topic)
+	assert.Equal(t, models.WeMissYou, repo.queued[0].Type)
+}
+
+func TestRunTick_SkipsEverySchedulerDuringMaintenance(t *testing.T) {
+	maintenance := &maintenanceSwitch{active: true}
+	s := &SchedulerService{maintenance: maintenance}
+
+	runs := make(map[string]int)
+	schedulers := []string{"Daily reminder", "Streak reminder", "Weekly recap", "Engagement nudge", "Typical practice hour"}
+	tickAll := func() {
+		for _, name := range schedulers {
+			name := name
+			s.runTick(context.Background(), name, func(context.Context, bool) (*jobResult, error) {
+				runs[name]++
+				return &jobResult{}, nil
+			})
+		}
+	}
+
+	tickAll()
+	tickAll()
+	assert.Empty(t, runs, "no scheduler runs while maintenance mode is on")
+
+	maintenance.active = false
+	tickAll()
+	for _, name := range schedulers {
+		assert.Equal(t, 1, runs[name], "%s resumes after maintenance", name)
+	}
+}
+
+func TestRunTick_WithoutMaintenanceFlag(t *testing.T) {
+	s := &SchedulerService{}
+	ran := false
+
+	s.runTick(context.Background(), "Daily reminder", func(context.Context, bool) (*jobResult, error) {
+		ran = true
+		return &jobResult{}, nil
+	})
+
+	assert.True(t, ran)
+}
+
+// runHistory records scheduler runs in memory
+type runHistory struct {
+	repository.SchedulerRunRepository
+	started  []models.SchedulerRun
+	finished []models.SchedulerRun
+}
+
+func (h *runHistory) StartSchedulerRun(ctx context.Context, run *models.SchedulerRun) error {
+	run.ID = int64(len(h.started) + 1)
+	h.started = append(h.started, *run)
+	return nil
+}
+
+func (h *runHistory) FinishSchedulerRun(ctx context.Context, run *models.SchedulerRun) error {
+	h.finished = append(h.finished, *run)
+	return nil
+}
+
+func TestRunTick_RecordsSuccessfulRun(t *testing.T) {
+	// Arrange
+	repo := &reminderRepository{users: eligibleUsers(3)}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC))
+	history := &runHistory{}
+	s.runs = history
+
+	// Act
+	s.runTick(context.Background(), "Daily reminder", s.processDailyReminders)
+
+	// Assert
+	require.Len(t, history.started, 1)
+	assert.Equal(t, "daily", history.started[0].JobName)
+	assert.Nil(t, history.started[0].FinishedAt)
+	require.Len(t, history.finished, 1)
+	run := history.finished[0]
+	assert.Equal(t, int64(1), run.ID)
+	require.NotNil(t, run.FinishedAt)
+	assert.Equal(t, 3, run.UsersProcessed)
+	assert.Equal(t, 3, run.NotificationsCreated)
+	assert.Nil(t, run.Error)
+}
+
+func TestRunTick_RecordsFailedRun(t *testing.T) {
+	// Arrange: the second page fails to load after the first page was stored
+	repo := &reminderRepository{users: eligibleUsers(3)}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC))
+	history := &runHistory{}
+	s.runs = history
+	failSecondPage := func(ctx context.Context, dryRun bool) (*jobResult, error) {
+		fetch := func(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+			if after != uuid.Nil {
+				return nil, errors.New("connection refused")
+			}
+			return repo.page(limit, after), nil
+		}
+		return s.processUserPages(ctx, "daily reminders", dryRun, fetch, s.createDailyReminder)
+	}
+
+	// Act
+	s.runTick(context.Background(), "Daily reminder", failSecondPage)
+
+	// Assert
+	require.Len(t, history.finished, 1)
+	run := history.finished[0]
+	require.NotNil(t, run.FinishedAt)
+	assert.Equal(t, 2, run.UsersProcessed)
+	assert.Equal(t, 2, run.NotificationsCreated)
+	require.NotNil(t, run.Error)
+	assert.Contains(t, *run.Error, "connection refused")
+}
+
+func TestRunTick_ConcurrentReplicasCreateOneSetOfReminders(t *testing.T) {
+	// Arrange: two replicas share one database and repository; the database
+	// grants the job lock to whichever asks first
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	lockQuery := regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")
+	for _, acquired := range []bool{true, false} {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(leader.JobKey("Engagement nudge")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(acquired))
+		mock.ExpectRollback()
+	}
+
+	users := []models.User{{ID: uuid.New(), Name: "Ada"}, {ID: uuid.New(), Name: "Grace"}}
+	repo := &reminderRepository{streaks: inactiveStreaks(users, 7)}
+	release := make(chan struct{})
+	done := make(chan struct{}, 2)
+
+	for i := 0; i < 2; i++ {
+		s := newSchedulerService(testSchedulerConfig(), repo, nil)
+		s.jobs = leader.NewJobLock(db)
+		go func() {
+			defer func() { done <- struct{}{} }()
+			s.runTick(context.Background(), "Engagement nudge", func(ctx context.Context, dryRun bool) (*jobResult, error) {
+				// Hold the lock until the other replica has given up
+				<-release
+				batch := &reminderBatch{}
+				for _, user := range users {
+					if err := s.createEngagementNudge(ctx, batch, user); err != nil {
+						return nil, err
+					}
+				}
+				return &jobResult{}, s.storeReminders(ctx, batch)
+			})
+		}()
+	}
+
+	// Act
+	<-done
+	close(release)
+	<-done
+
+	// Assert
+	assert.Len(t, repo.queued, len(users), "only one replica creates reminders")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// errBadRow is the insert error for a bad user's notification row
+var errBadRow = errors.New("violates check constraint")
+
+// reminderRepository records how the scheduler stores reminders, answering the
+// daily limit lookups with a fixed preference and count
+type reminderRepository struct {
+	repository.NotificationRepository
+	prefs     []models.UserNotificationPreferences
+	sentToday int
+	since     time.Time
+	stored    []*models.Notification
+	queued    []*models.Notification
+	outbox    []*models.OutboxNotification
+	lastSent  []time.Time
+	batches   int
+
+	// users are the eligible users every page query walks, in user ID order
+	users     []models.User
+	pageAfter []uuid.UUID
+	streaks   map[uuid.UUID]*models.UserEngagementStreak
+
+	practiceStreaks []models.UserEngagementStreak
+	timestamps      []time.Time
+	typicalHours    map[uuid.UUID]*int
+
+	summary   models.WeeklyActivitySummary
+	weekStart time.Time
+
+	// nudgeTiers is the last engagement nudge tier sent to each user
+	nudgeTiers map[uuid.UUID]int
+
+	// badUsers are users whose notification rows fail to insert, failing
+	// any batch they are part of
+	badUsers map[uuid.UUID]bool
+}
+
+// page returns up to limit users after the given user ID
+func (r *reminderRepository) page(limit int, after uuid.UUID) []models.User {
+	r.pageAfter = append(r.pageAfter, after)
+	start := sort.Search(len(r.users), func(i int) bool { return r.users[i].ID.String() > after.String() })
+	return r.users[start:min(start+limit, len(r.users))]
+}
+
+func (r *reminderRepository) GetUsersNeedingDailyReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetUsersNeedingStreakReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetUsersNeedingLastChanceAlerts(ctx context.Context, startHour, endHour, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetUsersNeedingXPGoalReminders(ctx context.Context, reminderHour int, threshold float64, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetActiveUsersForWeeklyRecap(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetInactiveUsersForEngagement(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	return r.page(limit, after), nil
+}
+
+func (r *reminderRepository) GetLastEngagementNudgeTier(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	return r.nudgeTiers[userID], nil
+}
+
+func (r *reminderRepository) GetUserEngagementStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error) {
+	if streak, ok := r.streaks[userID]; ok {
+		return streak, nil
+	}
+	return nil, repository.ErrStreakNotFound
+}
+
+func (r *reminderRepository) GetWeeklyActivitySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (*models.WeeklyActivitySummary, error) {
+	r.weekStart = weekStart
+	summary := r.summary
+	summary.WeekStart = weekStart
+	return &summary, nil
+}
+
+func (r *reminderRepository) GetPracticeStreaks(ctx context.Context) ([]models.UserEngagementStreak, error) {
+	return r.practiceStreaks, nil
+}
+
+func (r *reminderRepository) GetPracticeTimestamps(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error) {
+	return r.timestamps, nil
+}
+
+func (r *reminderRepository) UpdateStreakTypicalHour(ctx context.Context, userID uuid.UUID, streakType string, typicalHour *int, confidence float64) error {
+	if r.typicalHours == nil {
+		r.typicalHours = make(map[uuid.UUID]*int)
+	}
+	r.typicalHours[userID] = typicalHour
+	return nil
+}
+
+func (r *reminderRepository) GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error) {
+	return r.prefs, nil
+}
+
+func (r *reminderRepository) CountNotificationsForUserTypeSince(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, since time.Time) (int, error) {
+	r.since = since
+	return r.sentToday, nil
+}
+
+func (r *reminderRepository) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	r.stored = append(r.stored, notification)
+	return nil
+}
+
+func (r *reminderRepository) CreateNotificationWithOutbox(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification) error {
+	if r.badUsers[notification.UserID] {
+		return errBadRow
+	}
+	r.queued = append(r.queued, notification)
+	r.outbox = append(r.outbox, outboxItem)
+	return nil
+}
+
+func (r *reminderRepository) CreateNotificationsWithOutbox(ctx context.Context, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error {
+	for _, n := range notifications {
+		if r.badUsers[n.UserID] {
+			return errBadRow
+		}
+	}
+	r.batches++
+	r.queued = append(r.queued, notifications...)
+	r.outbox = append(r.outbox, outboxItems...)
+	return nil
+}
+
+func (r *reminderRepository) MarkPreferenceSent(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, sentAt time.Time) error {
+	r.lastSent = append(r.lastSent, sentAt)
+	return nil
+}
+
+func newReminder(userID uuid.UUID, createdAt time.Time) *models.Notification {
+	return &models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      models.StreakReminder,
+		Channel:   models.ChannelInApp,
+		Priority:  models.PriorityHigh,
+		Message:   "Don't break your streak",
+		Status:    models.StatusQueued,
+		CreatedAt: createdAt,
+	}
+}
+
+func TestSaveReminder_SuppressedAtDailyLimit(t *testing.T) {
+	userID := uuid.New()
+	limit := 1
+	repo := &reminderRepository{
+		prefs:     []models.UserNotificationPreferences{{UserID: userID, Type: models.StreakReminder, Channel: models.ChannelInApp, Enabled: true, MaxPerDay: &limit}},
+		sentToday: 1,
+	}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+
+	// 02:00 UTC is still the previous day in New York, so the count starts at its local midnight
+	createdAt := time.Date(2024, 3, 12, 2, 0, 0, 0, time.UTC)
+	batch := &reminderBatch{}
+	err := s.saveReminder(context.Background(), batch, newReminder(userID, createdAt), &models.UserEngagementStreak{Timezone: "America/New_York"})
+
+	require.NoError(t, err)
+	assert.Empty(t, batch.notifications)
+	assert.True(t, repo.since.Equal(time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC)))
+	require.Len(t, repo.stored, 1)
+	assert.Equal(t, models.StatusSuppressed, repo.stored[0].Status)
+	assert.Equal(t, "max_per_day", repo.stored[0].Metadata["decision_rule"])
+	assert.Empty(t, repo.queued)
+	assert.Empty(t, repo.lastSent)
+}
+
+func TestSaveReminder_QueuesAndRecordsLastSent(t *testing.T) {
+	userID := uuid.New()
+	limit := 2
+	repo := &reminderRepository{
+		prefs:     []models.UserNotificationPreferences{{UserID: userID, Type: models.StreakReminder, Channel: models.ChannelInApp, Enabled: true, MaxPerDay: &limit}},
+		sentToday: 1,
+	}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+
+	createdAt := time.Date(2024, 3, 12, 18, 0, 0, 0, time.UTC)
+	batch := &reminderBatch{}
+	err := s.saveReminder(context.Background(), batch, newReminder(userID, createdAt), nil)
+	require.NoError(t, err)
+	assert.Empty(t, repo.lastSent, "last sent is only recorded once the batch is stored")
+	require.NoError(t, s.storeReminders(context.Background(), batch))
+
+	assert.Len(t, batch.notifications, 1)
+	assert.Len(t, repo.queued, 1)
+	assert.Empty(t, repo.stored)
+	assert.Equal(t, []time.Time{createdAt}, repo.lastSent)
+}
+
+func TestSaveReminder_OptedOutUserGetsNoRow(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	repo := &reminderRepository{
+		prefs: []models.UserNotificationPreferences{{UserID: userID, Type: models.StreakReminder, Channel: models.ChannelInApp, Enabled: false}},
+	}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+	batch := &reminderBatch{}
+
+	// Act
+	err := s.saveReminder(context.Background(), batch, newReminder(userID, time.Now()), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.storeReminders(context.Background(), batch))
+
+	// Assert
+	assert.Empty(t, batch.notifications)
+	assert.Empty(t, repo.stored)
+	assert.Empty(t, repo.queued)
+}
+
+func TestSaveReminder_DefersDuringQuietHours(t *testing.T) {
+	// Arrange: quiet hours 22:00-07:00 in New York, and 03:00 there now
+	userID := uuid.New()
+	start, end := "22:00", "07:00"
+	repo := &reminderRepository{
+		prefs: []models.UserNotificationPreferences{{
+			UserID: userID, Type: models.StreakReminder, Channel: models.ChannelInApp, Enabled: true,
+			QuietHoursStart: &start, QuietHoursEnd: &end,
+		}},
+	}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+	createdAt := time.Date(2024, 3, 12, 7, 0, 0, 0, time.UTC)
+	batch := &reminderBatch{}
+
+	// Act
+	err := s.saveReminder(context.Background(), batch, newReminder(userID, createdAt), &models.UserEngagementStreak{Timezone: "America/New_York"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, batch.notifications, "a deferred reminder gets no outbox entry")
+	require.Len(t, repo.stored, 1)
+	n := repo.stored[0]
+	assert.Equal(t, models.StatusQueued, n.Status)
+	require.NotNil(t, n.ScheduledFor)
+	assert.True(t, n.ScheduledFor.After(createdAt))
+	assert.Equal(t, time.Date(2024, 3, 12, 11, 0, 0, 0, time.UTC), *n.ScheduledFor, "07:00 in New York")
+	assert.Equal(t, []time.Time{createdAt}, repo.lastSent)
+}
+
+func TestProcessWeeklyRecaps_SkipsOptedOutUsers(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(2)
+	repo := &reminderRepository{
+		users: users,
+		prefs: []models.UserNotificationPreferences{{Type: models.WeeklyRecap, Channel: models.ChannelInApp, Enabled: false}},
+	}
+	s := newPagedScheduler(repo, time.Now())
+
+	// Act
+	result, err := s.processWeeklyRecaps(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Selected)
+	assert.Equal(t, 0, result.Created)
+	assert.Empty(t, repo.stored)
+	assert.Empty(t, repo.queued)
+}
+
+func TestStoreReminders_RetriesFailedBatchRowByRow(t *testing.T) {
+	// Arrange: one user's row fails, which fails the whole multi-row insert
+	users := eligibleUsers(3)
+	repo := &reminderRepository{badUsers: map[uuid.UUID]bool{users[1].ID: true}}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+	batch := &reminderBatch{}
+	for _, user := range users {
+		n := newReminder(user.ID, time.Now())
+		outboxItem, err := models.BuildOutboxEntry(n, "notifications")
+		require.NoError(t, err)
+		batch.add(n, outboxItem, &models.UserNotificationPreferences{UserID: user.ID})
+	}
+
+	// Act
+	err := s.storeReminders(context.Background(), batch)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 0, repo.batches)
+	require.Len(t, repo.queued, 2)
+	assert.Equal(t, users[0].ID, repo.queued[0].UserID)
+	assert.Equal(t, users[2].ID, repo.queued[1].UserID)
+	assert.Len(t, batch.notifications, 2, "the batch keeps only the stored reminders")
+	assert.Len(t, repo.lastSent, 2, "last sent is only recorded for stored reminders")
+}
+
+func TestStoreReminders_FailsWhenNoRowCanBeStored(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(2)
+	repo := &reminderRepository{badUsers: map[uuid.UUID]bool{users[0].ID: true, users[1].ID: true}}
+	s := newSchedulerService(testSchedulerConfig(), repo, nil)
+	batch := &reminderBatch{}
+	for _, user := range users {
+		n := newReminder(user.ID, time.Now())
+		batch.add(n, &models.OutboxNotification{NotificationID: n.ID}, nil)
+	}
+
+	// Act
+	err := s.storeReminders(context.Background(), batch)
+
+	// Assert
+	assert.ErrorIs(t, err, errBadRow)
+	assert.Empty(t, repo.queued)
+}
+
+func TestProcessDailyReminders_BadRowDoesNotBlockPage(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(4)
+	repo := &reminderRepository{users: users, badUsers: map[uuid.UUID]bool{users[0].ID: true}}
+	s := newPagedScheduler(repo, time.Now())
+
+	// Act
+	result, err := s.processDailyReminders(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Created)
+	assert.Equal(t, userIDs(users[1:]), result.UserIDs)
+	assert.Equal(t, 1, repo.batches, "only the page without the bad row is stored in one batch")
+}
+
+// eligibleUsers returns n users in the user ID order the page queries walk
+func eligibleUsers(n int) []models.User {
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = models.User{ID: uuid.New(), Name: "User", Email: "user@example.com"}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	return users
+}
+
+// newPagedScheduler creates a scheduler loading two users per page at the given time
+func newPagedScheduler(repo *reminderRepository, now time.Time) *SchedulerService {
+	cfg := testSchedulerConfig()
+	cfg.Scheduler.BatchSize = 2
+	s := newSchedulerService(cfg, repo, nil)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestProcessDailyReminders_QueuesOneReminderPerUser(t *testing.T) {
+	// Arrange
+	users := eligibleUsers(3)
+	repo := &reminderRepository{
+		users:   users,
+		streaks: map[uuid.UUID]*models.UserEngagementStreak{users[0].ID: {UserID: users[0].ID, CurrentStreak: 4}},
+	}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC))
+
+	// Act
+	_, err := s.processDailyReminders(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, repo.queued, 3)
+	for i, n := range repo.queued {
+		assert.Equal(t, users[i].ID, n.UserID)
+		assert.Equal(t, models.DailyReminder, n.Type)
+	}
+	assert.Contains(t, repo.queued[0].Message, "4-day streak")
+	assert.Contains(t, repo.queued[1].Message, "0-day streak", "users without a streak still get a reminder")
+	assert.Equal(t, []uuid.UUID{uuid.Nil, users[1].ID}, repo.pageAfter)
+}
+
+func TestProcessStreakReminders_QueuesDueUsersOnly(t *testing.T) {
+	// Arrange: at 20:00 UTC a user who practices at 08:00 is due, one who
+	// practices at 21:00 is not yet
+	users := eligibleUsers(2)
+	early, late := 8, 21
+	repo := &reminderRepository{
+		users: users,
+		streaks: map[uuid.UUID]*models.UserEngagementStreak{
+			users[0].ID: {UserID: users[0].ID, CurrentStreak: 3, Timezone: "UTC", TypicalHour: &early, TypicalHourConfidence: 1},
+			users[1].ID: {UserID: users[1].ID, CurrentStreak: 9, Timezone: "UTC", TypicalHour: &late, TypicalHourConfidence: 1},
+		},
+	}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC))
+
+	// Act
+	_, err := s.processStreakReminders(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, repo.queued, 1)
+	assert.Equal(t, users[0].ID, repo.queued[0].UserID)
+	assert.Equal(t, models.StreakReminder, repo.queued[0].Type)
+	assert.Contains(t, repo.queued[0].Message, "3-day streak")
+}
+
+func TestProcessLastChanceAlerts_UsesLocalEvening(t *testing.T) {
+	// Arrange: at 01:30 UTC on the 13th it is 21:30 on the 12th in New York,
+	// but 01:30 in London and 10:30 in Tokyo
+	users := eligibleUsers(4)
+	yesterday := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	repo := &reminderRepository{
+		users: users,
+		streaks: map[uuid.UUID]*models.UserEngagementStreak{
+			users[0].ID: {UserID: users[0].ID, CurrentStreak: 6, Timezone: "America/New_York", LastActivityDate: &yesterday},
+			users[1].ID: {UserID: users[1].ID, CurrentStreak: 6, Timezone: "Europe/London", LastActivityDate: &yesterday},
+			users[2].ID: {UserID: users[2].ID, CurrentStreak: 6, Timezone: "Asia/Tokyo", LastActivityDate: &yesterday},
+			users[3].ID: {UserID: users[3].ID, CurrentStreak: 6, Timezone: "America/New_York", LastActivityDate: &today},
+		},
+	}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 13, 1, 30, 0, 0, time.UTC))
+
+	// Act
+	_, err := s.processLastChanceAlerts(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, repo.queued, 1)
+	alert := repo.queued[0]
+	assert.Equal(t, users[0].ID, alert.UserID)
+	assert.Equal(t, models.LastChanceAlert, alert.Type)
+	assert.Equal(t, models.PriorityUrgent, alert.Priority)
+	assert.Contains(t, alert.Message, "6-day streak")
+	assert.Equal(t, []uuid.UUID{uuid.Nil, users[1].ID, users[3].ID}, repo.pageAfter)
+}
+
+func TestProcessWeeklyRecaps_OnlyOnMondays(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    time.Time
+		queued int
+	}{
+		{name: "monday", now: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), queued: 2},
+		{name: "tuesday", now: time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), queued: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := &reminderRepository{users: eligibleUsers(2)}
+			s := newPagedScheduler(repo, tt.now)
+
+			// Act
+			_, err := s.onMondays(s.processWeeklyRecaps)(context.Background(), false)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Len(t, repo.queued, tt.queued)
+			for _, n := range repo.queued {
+				assert.Equal(t, models.WeeklyRecap, n.Type)
+			}
+		})
+	}
+}
+
+func TestCreateWeeklyRecap_RendersWeeklyStatistics(t *testing.T) {
+	// Arrange
+	user := models.User{ID: uuid.New(), Name: "Ada"}
+	repo := &reminderRepository{
+		streaks: map[uuid.UUID]*models.UserEngagementStreak{user.ID: {UserID: user.ID, Timezone: "Europe/Berlin"}},
+		summary: models.WeeklyActivitySummary{
+			SessionsCompleted: 6,
+			XPEarned:          120,
+			ActiveDays:        4,
+			LongestStreak:     3,
+			DailySessions:     [7]int{2, 1, 1, 0, 0, 2, 0},
+		},
+	}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC))
+	batch := &reminderBatch{}
+
+	// Act
+	err := s.createWeeklyRecap(context.Background(), batch, user)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, batch.notifications, 1)
+	n := batch.notifications[0]
+	assert.Equal(t, "Your Weekly Progress Report", *n.Title)
+	assert.Equal(t, "Great week Ada! You completed 6 practice sessions on 4 of 7 days and earned 120 XP. "+
+		"Your longest run was 3 days in a row. Keep it up! 🎉", n.Message)
+	assert.Equal(t, "2024-03-04", n.Metadata["week_start"])
+	assert.Equal(t, 6, n.Metadata["sessions_completed"])
+	assert.Equal(t, 120, n.Metadata["xp_earned"])
+	assert.Equal(t, []int{2, 1, 1, 0, 0, 2, 0}, n.Metadata["daily_sessions"])
+	assert.Equal(t, "Europe/Berlin", repo.weekStart.Location().String())
+}
+
+func TestCreateWeeklyRecap_RestartVariantWithoutActivity(t *testing.T) {
+	// Arrange
+	user := models.User{ID: uuid.New(), Name: "Ada"}
+	repo := &reminderRepository{}
+	s := newPagedScheduler(repo, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC))
+	batch := &reminderBatch{}
+
+	// Act
+	err := s.createWeeklyRecap(context.Background(), batch, user)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, batch.notifications, 1)
+	n := batch.notifications[0]
+	assert.Equal(t, "Let's Get Back on Track", *n.Title)
+	assert.Contains(t, n.Message, "Hey Ada, last week was a quiet one")
+	assert.Equal(t, 0, n.Metadata["sessions_completed"])
+	assert.Equal(t, time.UTC, repo.weekStart.Location(), "users without a streak are summarized in UTC")
+}
+
+func TestRecapWeekStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{
+			name: "monday morning covers the week just ended",
+			now:  time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC),
+			loc:  time.UTC,
+			want: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "sunday covers the previous full week",
+			now:  time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC),
+			loc:  time.UTC,
+			want: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "still sunday in the user's timezone",
+			now:  time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC),
+			loc:  newYork,
+			want: time.Date(2024, 2, 26, 0, 0, 0, 0, newYork),
+		},
+		{
+			name: "week spanning a daylight saving change",
+			now:  time.Date(2024, 3, 18, 14, 0, 0, 0, time.UTC),
+			loc:  newYork,
+			want: time.Date(2024, 3, 11, 0, 0, 0, 0, newYork),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recapWeekStart(tt.now, tt.loc)
+
+			assert.True(t, tt.want.Equal(got), "want %v, got %v", tt.want, got)
+			assert.Equal(t, time.Monday, got.Weekday())
+		})
+	}
+
+	// The week holding the spring-forward Sunday is an hour short but still
+	// ends at local midnight
+	start := recapWeekStart(time.Date(2024, 3, 11, 14, 0, 0, 0, time.UTC), newYork)
+	end := start.AddDate(0, 0, 7)
+	assert.Equal(t, 167*time.Hour, end.Sub(start))
+	assert.Equal(t, 0, end.Hour())
+}
+
+func TestProcessEngagementNudges_PagesThroughEveryUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		users   int
+		queries int
+	}{
+		{name: "last page is short", users: 5, queries: 3},
+		{name: "last page is full", users: 4, queries: 3},
+		{name: "no eligible users", users: 0, queries: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange: the repository pages through users two at a time
+			users := eligibleUsers(tt.users)
+			repo := &reminderRepository{users: users, streaks: inactiveStreaks(users, 7)}
+			s := newPagedScheduler(repo, time.Now())
+
+			// Act
+			_, err := s.processEngagementNudges(context.Background(), false)
+
+			// Assert: every user gets exactly one nudge, stored one page at a time
+			require.NoError(t, err)
+			require.Len(t, repo.queued, tt.users)
+			for i, n := range repo.queued {
+				assert.Equal(t, users[i].ID, n.UserID)
+				assert.Equal(t, models.WeMissYou, n.Type)
+			}
+			assert.Len(t, repo.pageAfter, tt.queries)
+			assert.Equal(t, (tt.users+1)/2, repo.batches)
+		})
+	}
+}
+
+// inactiveStreaks returns practice streaks for the users last active the given
+// number of days before today in UTC
+func inactiveStreaks(users []models.User, days int) map[uuid.UUID]*models.UserEngagementStreak {
+	year, month, day := time.Now().UTC().Date()
+	last := time.Date(year, month, day-days, 0, 0, 0, 0, time.UTC)
+	streaks := make(map[uuid.UUID]*models.UserEngagementStreak, len(users))
+	for _, user := range users {
+		streaks[user.ID] = &models.UserEngagementStreak{UserID: user.ID, Timezone: "UTC", LongestStreak: 12, LastActivityDate: &last}
+	}
+	return streaks
+}
+
+func TestCreateEngagementNudge_EscalatesThroughTiers(t *testing.T) {
+	tests := []struct {
+		name         string
+		inactiveDays int
+		lastTier     int
+		title        string
+		message      string
+		offer        interface{}
+	}{
+		{name: "first week", inactiveDays: 7, title: "We Miss You!", message: "It's been a week"},
+		{name: "two weeks mentions the longest streak", inactiveDays: 14, lastTier: 1, title: "Your Streak Record Is Waiting", message: "12 days in a row"},
+		{name: "a month offers a streak restore", inactiveDays: 30, lastTier: 2, title: "Restore Your Streak", message: "restore your streak", offer: "streak_restore"},
+		{name: "nothing between tiers", inactiveDays: 20, lastTier: 2},
+		{name: "stops after the last tier", inactiveDays: 60, lastTier: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			user := models.User{ID: uuid.New(), Name: "Ada"}
+			repo := &reminderRepository{
+				streaks:    inactiveStreaks([]models.User{user}, tt.inactiveDays),
+				nudgeTiers: map[uuid.UUID]int{user.ID: tt.lastTier},
+			}
+			s := newSchedulerService(testSchedulerConfig(), repo, nil)
+			batch := &reminderBatch{}
+
+			// Act
+			err := s.createEngagementNudge(context.Background(), batch, user)
+
+			// Assert
+			require.NoError(t, err)
+			if tt.title == "" {
+				assert.Empty(t, batch.notifications)
+				return
+			}
+			require.Len(t, batch.notifications, 1)
+			n := batch.notifications[0]
+			assert.Equal(t, tt.title, *n.Title)
+			assert.Contains(t, n.Message, tt.message)
+			assert.Equal(t, tt.lastTier+1, n.Metadata["nudge_tier"])
+			assert.Equal(t, tt.offer, n.Metadata["offer"])
+		})
+	}
+}
+
+// weeklyGoals answers goal lookups with one weekly XP target for every user
+type weeklyGoals struct {
+	repository.GoalRepository
+	target    int
+	weekStart time.Time
+}
+
+func (g *weeklyGoals) GetGoalForWeek(ctx context.Context, userID uuid.UUID, goalType models.GoalType, weekStart time.Time) (*models.UserGoal, error) {
+	g.weekStart = weekStart
+	if g.target == 0 {
+		return nil, repository.ErrGoalNotFound
+	}
+	return &models.UserGoal{UserID: userID, GoalType: goalType, Target: g.target, WeekStart: weekStart}, nil
+}
+
+func TestCreateXPGoalReminder(t *testing.T) {
+	// Thursday 7 March 2024
+	thursdayEvening := time.Date(2024, 3, 7, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		now       time.Time
+		earned    int
+		target    int
+		remaining interface{}
+		wantErr   bool
+	}{
+		{name: "behind on Thursday evening", now: thursdayEvening, earned: 200, target: 500, remaining: 300},
+		{name: "on track", now: thursdayEvening, earned: 400, target: 500},
+		{name: "before the reminder hour", now: thursdayEvening.Add(-2 * time.Hour), earned: 0, target: 500},
+		{name: "not on Wednesday", now: thursdayEvening.AddDate(0, 0, -1), earned: 0, target: 500},
+		{name: "no goal this week", now: thursdayEvening, earned: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			user := models.User{ID: uuid.New(), Name: "Ada"}
+			repo := &reminderRepository{summary: models.WeeklyActivitySummary{XPEarned: tt.earned}}
+			goals := &weeklyGoals{target: tt.target}
+			s := newPagedScheduler(repo, tt.now)
+			s.goals = goals
+			batch := &reminderBatch{}
+
+			// Act
+			err := s.createXPGoalReminder(context.Background(), batch, user)
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorIs(t, err, repository.ErrGoalNotFound)
+				return
+			}
+			require.NoError(t, err)
+			if tt.remaining == nil {
+				assert.Empty(t, batch.notifications)
+				return
+			}
+			require.Len(t, batch.notifications, 1)
+			n := batch.notifications[0]
+			assert.Equal(t, models.XPGoalReminder, n.Type)
+			assert.Contains(t, n.Message, "300 XP away")
+			assert.Equal(t, tt.remaining, n.Metadata["remaining"])
+			assert.Equal(t, "2024-03-04", n.Metadata["week_start"])
+			assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), goals.weekStart)
+			assert.Equal(t, goals.weekStart, repo.weekStart)
+		})
+	}
+}
+
+func TestProcessTypicalPracticeHours_UpdatesEveryStreak(t *testing.T) {
+	// Arrange: every session was at 20:00 UTC, which is 15:00 in New York in March
+	users := eligibleUsers(2)
+	var timestamps []time.Time
+	for day := 1; day <= 5; day++ {
+		timestamps = append(timestamps, time.Date(2024, 3, day, 20, 0, 0, 0, time.UTC))
+	}
+	repo := &reminderRepository{
+		practiceStreaks: []models.UserEngagementStreak{
+			{UserID: users[0].ID, StreakType: "practice", Timezone: "UTC"},
+			{UserID: users[1].ID, StreakType: "practice", Timezone: "America/New_York"},
+		},
+		timestamps: timestamps,
+	}
+	s := newPagedScheduler(repo, time.Now())
+
+	// Act
+	_, err := s.processTypicalPracticeHours(context.Background(), false)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, repo.typicalHours, 2)
+	assert.Equal(t, 20, *repo.typicalHours[users[0].ID])
+	assert.Equal(t, 15, *repo.typicalHours[users[1].ID])
+}
@@ -0,0 +1,114 @@
+// Package auth verifies the JWT bearer tokens user API requests carry
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, signed
+// with an unknown key or issued for another service
+var ErrInvalidToken = errors.New("invalid token")
+
+// leeway absorbs clock skew between the token issuer and this service
+const leeway = 30 * time.Second
+
+// hmacMethods and keySetMethods are the signing algorithms accepted with a
+// shared secret and with a JWKS; never both, so a public key can't be used as
+// an HMAC secret
+var (
+	hmacMethods   = []string{"HS256", "HS384", "HS512"}
+	keySetMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// Principal is the user an authenticated request acts for
+type Principal struct {
+	UserID uuid.UUID
+	// Admin is set when the token carries the configured admin role
+	Admin bool
+}
+
+// CanActFor reports whether the principal may read or change userID's data
+func (p *Principal) CanActFor(userID uuid.UUID) bool {
+	return p.Admin || p.UserID == userID
+}
+
+// claims are the registered claims plus the role claims; issuers use either
+// a single role or a list of roles
+type claims struct {
+	jwt.RegisteredClaims
+	Role  string   `json:"role,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+func (c *claims) hasRole(role string) bool {
+	if c.Role == role {
+		return true
+	}
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier checks bearer tokens against the configured secret or key set
+type Verifier struct {
+	cfg     config.AuthConfig
+	keyFunc jwt.Keyfunc
+	parser  *jwt.Parser
+}
+
+// NewVerifier creates a verifier for cfg, which must have a JWT secret or a
+// JWKS URL. Keys are fetched from the JWKS URL on first use.
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	v := &Verifier{cfg: cfg}
+	switch {
+	case cfg.JWTSecret != "":
+		secret := []byte(cfg.JWTSecret)
+		v.keyFunc = func(*jwt.Token) (interface{}, error) { return secret, nil }
+		opts = append(opts, jwt.WithValidMethods(hmacMethods))
+	case cfg.JWKSURL != "":
+		keys := newKeySet(cfg.JWKSURL, cfg.JWKSRefreshInterval, &http.Client{Timeout: 10 * time.Second})
+		v.keyFunc = keys.keyFunc
+		opts = append(opts, jwt.WithValidMethods(keySetMethods))
+	default:
+		return nil, fmt.Errorf("auth needs AUTH_JWT_SECRET or AUTH_JWKS_URL")
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify checks the token's signature, expiry, issuer and audience and returns
+// the user named by its subject, which must be a user ID
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: subject %q is not a user ID", ErrInvalidToken, c.Subject)
+	}
+	return &Principal{UserID: userID, Admin: c.hasRole(v.cfg.AdminRole)}, nil
+}
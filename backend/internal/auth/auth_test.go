@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func userClaims(userID uuid.UUID) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": userID.String(),
+		"iss": "https://auth.example.com",
+		"aud": "kafka-notify",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifier_HMAC(t *testing.T) {
+	userID := uuid.New()
+	verifier, err := NewVerifier(config.AuthConfig{
+		JWTSecret: testSecret,
+		Issuer:    "https://auth.example.com",
+		Audience:  "kafka-notify",
+		AdminRole: "admin",
+	})
+	require.NoError(t, err)
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := userClaims(userID)
+		claims[key] = value
+		return claims
+	}
+	without := func(key string) jwt.MapClaims {
+		claims := userClaims(userID)
+		delete(claims, key)
+		return claims
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		token string
+		admin bool
+		valid bool
+	}{
+		"valid":           {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", userClaims(userID)), valid: true},
+		"admin role":      {token: sign(t, jwt.SigningMethodHS512, []byte(testSecret), "", with("role", "admin")), valid: true, admin: true},
+		"admin in roles":  {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("roles", []string{"reader", "admin"})), valid: true, admin: true},
+		"other role":      {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("role", "reader")), valid: true},
+		"wrong secret":    {token: sign(t, jwt.SigningMethodHS256, []byte("other"), "", userClaims(userID))},
+		"expired":         {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("exp", time.Now().Add(-time.Hour).Unix()))},
+		"no expiry":       {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", without("exp"))},
+		"wrong issuer":    {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("iss", "https://evil.example.com"))},
+		"wrong audience":  {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("aud", "billing"))},
+		"subject not id":  {token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("sub", "ada"))},
+		"unsigned":        {token: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", userClaims(userID))},
+		"asymmetric algo": {token: sign(t, jwt.SigningMethodRS256, rsaKey, "", userClaims(userID))},
+		"garbage":         {token: "not.a.jwt"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			principal, err := verifier.Verify(tt.token)
+
+			if !tt.valid {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, userID, principal.UserID)
+			assert.Equal(t, tt.admin, principal.Admin)
+		})
+	}
+}
+
+func TestPrincipal_CanActFor(t *testing.T) {
+	userID := uuid.New()
+
+	assert.True(t, (&Principal{UserID: userID}).CanActFor(userID))
+	assert.False(t, (&Principal{UserID: userID}).CanActFor(uuid.New()))
+	assert.True(t, (&Principal{UserID: userID, Admin: true}).CanActFor(uuid.New()))
+}
+
+// jwksServer publishes keys as a JSON Web Key Set and counts the fetches
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Value
+	fetches atomic.Int32
+}
+
+func startJWKS(t *testing.T, keys ...map[string]string) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.keys.Store(keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys.Load()})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kid": kid, "kty": "RSA", "use": "sig", "alg": "RS256",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kid": kid, "kty": "EC", "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func TestVerifier_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := startJWKS(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey))
+
+	verifier, err := NewVerifier(config.AuthConfig{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour, AdminRole: "admin"})
+	require.NoError(t, err)
+	userID := uuid.New()
+
+	principal, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", userClaims(userID)))
+	require.NoError(t, err)
+	assert.Equal(t, userID, principal.UserID)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodES256, ecKey, "ec-1", userClaims(userID)))
+	require.NoError(t, err)
+
+	// A secret-signed token must not verify against the key set
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "rsa-1", userClaims(userID)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// Nor may a key sign under another key's ID
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "ec-1", userClaims(userID)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "", userClaims(userID)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	assert.Equal(t, int32(1), server.fetches.Load(), "keys are cached between tokens")
+}
+
+func TestKeySet_RefetchesForRolledKey(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	server := startJWKS(t, rsaJWK("old", &oldKey.PublicKey))
+
+	verifier, err := NewVerifier(config.AuthConfig{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour, AdminRole: "admin"})
+	require.NoError(t, err)
+	keys := newKeySet(server.URL, time.Hour, http.DefaultClient)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	verifier.keyFunc = keys.keyFunc
+	userID := uuid.New()
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, oldKey, "old", userClaims(userID)))
+	require.NoError(t, err)
+
+	server.keys.Store([]map[string]string{rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey)})
+	rolled := sign(t, jwt.SigningMethodRS256, newKey, "new", userClaims(userID))
+
+	// Unknown key IDs don't refetch more than once a minute
+	_, err = verifier.Verify(rolled)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(1), server.fetches.Load())
+
+	now = now.Add(minRefetch)
+	_, err = verifier.Verify(rolled)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), server.fetches.Load())
+}
+
+func TestKeySet_KeepsKeysWhileIssuerIsDown(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	server := startJWKS(t, rsaJWK("k1", &key.PublicKey))
+	keys := newKeySet(server.URL, time.Hour, http.DefaultClient)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	token, _, err := jwt.NewParser().ParseUnverified(sign(t, jwt.SigningMethodRS256, key, "k1", userClaims(uuid.New())), jwt.MapClaims{})
+	require.NoError(t, err)
+
+	_, err = keys.keyFunc(token)
+	require.NoError(t, err)
+
+	server.Close()
+	now = now.Add(2 * time.Hour)
+	got, err := keys.keyFunc(token)
+
+	require.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, got)
+}
@@ -0,0 +1,238 @@
+// Package email delivers email notifications over SMTP
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// defaultSubject is used for notifications without a title
+const defaultSubject = "New notification"
+
+// UserLookup finds the user a notification is addressed to; the notification
+// repository implements it
+type UserLookup interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+// bodyTemplate renders the HTML body from the notification title and message
+var bodyTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; line-height: 1.5;">
+{{- if .Title}}
+<h2>{{.Title}}</h2>
+{{- end}}
+<p>{{.Message}}</p>
+{{- if .UnsubscribeURL}}
+<p style="font-size: 12px; color: #888;"><a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// Sender sends notifications as HTML email over SMTP
+type Sender struct {
+	cfg   config.EmailConfig
+	users UserLookup
+	now   func() time.Time
+}
+
+// NewSender creates an SMTP sender addressing mail to the user's stored email
+func NewSender(cfg config.EmailConfig, users UserLookup) *Sender {
+	return &Sender{cfg: cfg, users: users, now: time.Now}
+}
+
+// Name identifies the provider in delivery attempts
+func (s *Sender) Name() string { return "smtp" }
+
+// Send emails the notification to its user and returns the Message-ID it was
+// sent with. Unknown users, invalid addresses and recipients the server rejects
+// (5xx) fail with a 4xx ProviderError so they are not retried; temporary
+// server errors (4xx) fail with a 503 and connection failures are returned
+// as they are, both of which are retried.
+func (s *Sender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	user, err := s.users.GetUserByID(ctx, n.UserID)
+	if err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			return "", &delivery.ProviderError{StatusCode: 404, Err: err}
+		}
+		return "", err
+	}
+
+	to, err := mail.ParseAddress(user.Email)
+	if err != nil {
+		return "", &delivery.ProviderError{StatusCode: 400, Err: fmt.Errorf("invalid recipient %q: %w", user.Email, err)}
+	}
+	if to.Name == "" {
+		to.Name = user.Name
+	}
+
+	messageID := s.messageID()
+	msg, err := s.buildMessage(n, to, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.deliver(ctx, to.Address, msg); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// deliver hands the message to the SMTP server
+func (s *Sender) deliver(ctx context.Context, to string, msg []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := net.Dialer{Timeout: s.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return classify(fmt.Errorf("failed to greet SMTP server: %w", err))
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return classify(fmt.Errorf("failed to start TLS: %w", err))
+		}
+	}
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return classify(fmt.Errorf("failed to authenticate: %w", err))
+		}
+	}
+
+	if err := client.Mail(s.cfg.FromAddress); err != nil {
+		return classify(fmt.Errorf("sender rejected: %w", err))
+	}
+	if err := client.Rcpt(to); err != nil {
+		return classify(fmt.Errorf("recipient rejected: %w", err))
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return classify(fmt.Errorf("failed to start message: %w", err))
+	}
+	if _, err := w.Write(msg); err != nil {
+		return classify(fmt.Errorf("failed to write message: %w", err))
+	}
+	if err := w.Close(); err != nil {
+		return classify(fmt.Errorf("message rejected: %w", err))
+	}
+
+	// The server accepted the message; a failed QUIT does not undo that
+	_ = client.Quit()
+	return nil
+}
+
+// buildMessage renders the notification as an HTML email
+func (s *Sender) buildMessage(n *models.Notification, to *mail.Address, messageID string) ([]byte, error) {
+	subject := defaultSubject
+	if n.Title != nil && *n.Title != "" {
+		subject = *n.Title
+	}
+	unsubscribe := s.unsubscribeURL(n)
+
+	var body bytes.Buffer
+	err := bodyTemplate.Execute(&body, map[string]interface{}{
+		"Title":          n.Title,
+		"Message":        n.Message,
+		"UnsubscribeURL": unsubscribe,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email body: %w", err)
+	}
+
+	from := mail.Address{Name: s.cfg.FromName, Address: s.cfg.FromAddress}
+	var msg bytes.Buffer
+	writeHeader(&msg, "From", from.String())
+	writeHeader(&msg, "To", to.String())
+	writeHeader(&msg, "Subject", mime.QEncoding.Encode("utf-8", subject))
+	writeHeader(&msg, "Date", s.now().Format(time.RFC1123Z))
+	writeHeader(&msg, "Message-ID", messageID)
+	if unsubscribe != "" {
+		writeHeader(&msg, "List-Unsubscribe", "<"+unsubscribe+">")
+	} else {
+		writeHeader(&msg, "List-Unsubscribe", "<mailto:"+s.cfg.FromAddress+"?subject=unsubscribe>")
+	}
+	writeHeader(&msg, "MIME-Version", "1.0")
+	writeHeader(&msg, "Content-Type", `text/html; charset="UTF-8"`)
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
+
+// unsubscribeURL returns the unsubscribe link for the notification's user and
+// type, or "" when none is configured
+func (s *Sender) unsubscribeURL(n *models.Notification) string {
+	if s.cfg.UnsubscribeURL == "" {
+		return ""
+	}
+	u, err := url.Parse(s.cfg.UnsubscribeURL)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Set("user_id", n.UserID.String())
+	query.Set("type", string(n.Type))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// messageID returns a new Message-ID in the sender address's domain
+func (s *Sender) messageID() string {
+	domain := "localhost"
+	if at := strings.LastIndex(s.cfg.FromAddress, "@"); at >= 0 && at < len(s.cfg.FromAddress)-1 {
+		domain = s.cfg.FromAddress[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+// classify maps an SMTP reply onto a ProviderError: permanent 5xx replies are
+// rejections (400) and temporary 4xx replies an unavailable server (503).
+// Anything else, such as a dropped connection, is returned as it is.
+func classify(err error) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return err
+	}
+	if reply.Code >= 500 {
+		return &delivery.ProviderError{StatusCode: 400, Err: err}
+	}
+	return &delivery.ProviderError{StatusCode: 503, Err: err}
+}
@@ -0,0 +1,215 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpd is a minimal SMTP server that records the messages it accepts
+type smtpd struct {
+	listener net.Listener
+	// rcptReply answers RCPT TO; empty accepts every recipient
+	rcptReply string
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func startSMTPD(t *testing.T) *smtpd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &smtpd{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (d *smtpd) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost test smtpd")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			if d.rcptReply != "" {
+				tp.PrintfLine("%s", d.rcptReply)
+			} else {
+				tp.PrintfLine("250 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.messages = append(d.messages, string(body))
+			d.mu.Unlock()
+			tp.PrintfLine("250 OK queued")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (d *smtpd) port() int {
+	return d.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (d *smtpd) received() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.messages...)
+}
+
+// fakeUsers looks users up in a map
+type fakeUsers map[uuid.UUID]*models.User
+
+func (u fakeUsers) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, ok := u[userID]
+	if !ok {
+		return nil, apperr.New(apperr.ErrNotFound, "user not found")
+	}
+	return user, nil
+}
+
+func newTestSender(port int, email string) (*Sender, *models.Notification) {
+	user := &models.User{ID: uuid.New(), Name: "Ada", Email: email}
+	sender := NewSender(config.EmailConfig{
+		Host:           "127.0.0.1",
+		Port:           port,
+		FromAddress:    "notifications@example.com",
+		FromName:       "Practice Bot",
+		UnsubscribeURL: "https://example.com/unsubscribe",
+		Timeout:        time.Second,
+	}, fakeUsers{user.ID: user})
+
+	title := "Your weekly recap"
+	n := &models.Notification{
+		ID:      uuid.New(),
+		UserID:  user.ID,
+		Type:    models.WeeklyRecap,
+		Channel: models.ChannelEmail,
+		Title:   &title,
+		Message: "You practiced 5 days <this> week",
+	}
+	return sender, n
+}
+
+func assertProviderStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var providerErr *delivery.ProviderError
+	require.True(t, errors.As(err, &providerErr), "expected a ProviderError, got %v", err)
+	assert.Equal(t, status, providerErr.StatusCode)
+}
+
+func TestSend_DeliversHTMLEmail(t *testing.T) {
+	server := startSMTPD(t)
+	sender, n := newTestSender(server.port(), "ada@example.com")
+
+	messageID, err := sender.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(messageID, "<") && strings.HasSuffix(messageID, "@example.com>"))
+
+	received := server.received()
+	require.Len(t, received, 1)
+	msg := received[0]
+	assert.Contains(t, msg, "Message-ID: "+messageID)
+	assert.Contains(t, msg, "To: \"Ada\" <ada@example.com>")
+	assert.Contains(t, msg, "Subject: Your weekly recap")
+	assert.Contains(t, msg, "List-Unsubscribe: <https://example.com/unsubscribe?type=weekly_recap&user_id="+n.UserID.String()+">")
+	assert.Contains(t, msg, "Content-Type: text/html")
+	assert.Contains(t, msg, "<h2>Your weekly recap</h2>")
+	assert.Contains(t, msg, "You practiced 5 days &lt;this&gt; week")
+}
+
+func TestSend_RejectedRecipientIsPermanent(t *testing.T) {
+	server := startSMTPD(t)
+	server.rcptReply = "550 5.1.1 No such user"
+	sender, n := newTestSender(server.port(), "gone@example.com")
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, 400)
+	assert.Empty(t, server.received())
+}
+
+func TestSend_TemporaryServerErrorIsRetryable(t *testing.T) {
+	server := startSMTPD(t)
+	server.rcptReply = "451 4.3.0 Try again later"
+	sender, n := newTestSender(server.port(), "ada@example.com")
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, 503)
+}
+
+func TestSend_InvalidAddressIsPermanent(t *testing.T) {
+	sender, n := newTestSender(1, "not-an-address")
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, 400)
+}
+
+func TestSend_UnknownUserIsPermanent(t *testing.T) {
+	sender, n := newTestSender(1, "ada@example.com")
+	n.UserID = uuid.New()
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, 404)
+}
+
+func TestSend_ConnectionFailureIsRetryable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	sender, n := newTestSender(port, "ada@example.com")
+
+	_, err = sender.Send(context.Background(), n)
+
+	require.Error(t, err)
+	var providerErr *delivery.ProviderError
+	assert.False(t, errors.As(err, &providerErr), "connection failures must not look like rejections")
+	assert.ErrorContains(t, err, "failed to connect to SMTP server")
+}
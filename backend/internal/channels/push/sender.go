@@ -0,0 +1,227 @@
+// Package push delivers push notifications through Firebase Cloud Messaging
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// errUnregistered is FCM's error code for a token that is no longer valid
+const errUnregistered = "UNREGISTERED"
+
+// ErrNoDevices is returned when a user has no device that accepts pushes
+var ErrNoDevices = errors.New("no active devices")
+
+// errTokenUnregistered marks a send rejected because the device token is gone
+var errTokenUnregistered = errors.New("device token unregistered")
+
+// Devices lists a user's push tokens and deactivates the ones FCM rejects;
+// the device repository implements it
+type Devices interface {
+	GetActiveDevices(ctx context.Context, userID uuid.UUID) ([]models.UserDevice, error)
+	DeactivateDevice(ctx context.Context, token string) error
+}
+
+// Sender sends notifications to every active device of their user through
+// the FCM HTTP v1 API
+type Sender struct {
+	cfg     config.PushConfig
+	tokens  TokenSource
+	devices Devices
+	client  *http.Client
+}
+
+// NewSender creates an FCM sender authorizing requests with tokens
+func NewSender(cfg config.PushConfig, tokens TokenSource, devices Devices) *Sender {
+	return &Sender{
+		cfg:     cfg,
+		tokens:  tokens,
+		devices: devices,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name identifies the provider in delivery attempts
+func (s *Sender) Name() string { return "fcm" }
+
+// fcmMessage is the body of a messages:send request
+type fcmMessage struct {
+	Message struct {
+		Token        string            `json:"token"`
+		Notification fcmNotification   `json:"notification"`
+		Data         map[string]string `json:"data,omitempty"`
+	} `json:"message"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+}
+
+// fcmError is the error body FCM answers failed sends with
+type fcmError struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// Send pushes the notification to each of the user's active devices and
+// returns the FCM message name of the first accepted send. Tokens FCM reports
+// as unregistered are deactivated. The send succeeds if any device accepted
+// it; otherwise a throttled, unavailable or unreachable FCM is returned as a
+// retryable error, and a user without any valid token fails with a 404
+// ProviderError so it is not retried.
+func (s *Sender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	devices, err := s.devices.GetActiveDevices(ctx, n.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	accessToken, err := s.tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to authorize with FCM: %w", err)
+	}
+
+	data := dataPayload(n)
+	var messageName string
+	var retryErr, rejectErr error
+	for _, device := range devices {
+		name, err := s.sendTo(ctx, accessToken, device.Token, n, data)
+		switch {
+		case err == nil:
+			if messageName == "" {
+				messageName = name
+			}
+		case errors.Is(err, errTokenUnregistered):
+			if err := s.devices.DeactivateDevice(ctx, device.Token); err != nil {
+				log.Printf("Failed to deactivate device %d for user %s: %v", device.ID, n.UserID, err)
+			}
+		case isRetryable(err):
+			retryErr = err
+		default:
+			rejectErr = err
+		}
+	}
+
+	switch {
+	case messageName != "":
+		return messageName, nil
+	case retryErr != nil:
+		return "", retryErr
+	case rejectErr != nil:
+		return "", rejectErr
+	}
+	return "", &delivery.ProviderError{StatusCode: http.StatusNotFound, Err: fmt.Errorf("%w for user %s", ErrNoDevices, n.UserID)}
+}
+
+// sendTo posts the notification to one device token
+func (s *Sender) sendTo(ctx context.Context, accessToken, token string, n *models.Notification, data map[string]string) (string, error) {
+	var msg fcmMessage
+	msg.Message.Token = token
+	msg.Message.Notification = fcmNotification{Body: n.Message}
+	if n.Title != nil {
+		msg.Message.Notification.Title = *n.Title
+	}
+	msg.Message.Data = data
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode FCM message: %w", err)
+	}
+
+	url := strings.TrimRight(s.cfg.Endpoint, "/") + "/v1/projects/" + s.cfg.ProjectID + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create FCM request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach FCM: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var sent struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+			return "", fmt.Errorf("failed to decode FCM response: %w", err)
+		}
+		return sent.Name, nil
+	}
+	return "", classify(resp)
+}
+
+// classify maps a failed FCM response onto an error: unregistered tokens on
+// errTokenUnregistered, throttling and server errors on a 503 ProviderError,
+// and other rejections on a ProviderError with FCM's status
+func classify(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body fcmError
+	_ = json.Unmarshal(raw, &body)
+
+	code := body.Error.Status
+	for _, detail := range body.Error.Details {
+		if detail.ErrorCode != "" {
+			code = detail.ErrorCode
+		}
+	}
+	err := fmt.Errorf("FCM %s: %s", code, body.Error.Message)
+
+	switch {
+	case code == errUnregistered || resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %v", errTokenUnregistered, err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return &delivery.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: err}
+	}
+	return &delivery.ProviderError{StatusCode: resp.StatusCode, Err: err}
+}
+
+// isRetryable reports whether a failed send may succeed when tried again
+func isRetryable(err error) bool {
+	var providerErr *delivery.ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode >= 500
+	}
+	return true
+}
+
+// dataPayload returns the notification's metadata plus its ID and type as FCM
+// data, which only carries string values; other values are JSON encoded
+func dataPayload(n *models.Notification) map[string]string {
+	data := make(map[string]string, len(n.Metadata)+2)
+	for key, value := range n.Metadata {
+		if str, ok := value.(string); ok {
+			data[key] = str
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		data[key] = string(encoded)
+	}
+	data["notification_id"] = n.ID.String()
+	data["type"] = string(n.Type)
+	return data
+}
@@ -0,0 +1,250 @@
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFCM is an httptest FCM HTTP v1 server; tokens in unregistered are
+// answered with UNREGISTERED and status, when set, overrides every response
+type fakeFCM struct {
+	server       *httptest.Server
+	unregistered map[string]bool
+	status       int
+
+	mu       sync.Mutex
+	received []fcmMessage
+	auth     []string
+}
+
+func startFakeFCM(t *testing.T) *fakeFCM {
+	t.Helper()
+	fake := &fakeFCM{unregistered: make(map[string]bool)}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (f *fakeFCM) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/projects/practice-app/messages:send" {
+		http.NotFound(w, r)
+		return
+	}
+	var msg fcmMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.received = append(f.received, msg)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	index := len(f.received)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case f.status != 0:
+		w.WriteHeader(f.status)
+		fmt.Fprintf(w, `{"error": {"code": %d, "message": "try later", "status": "UNAVAILABLE"}}`, f.status)
+	case f.unregistered[msg.Message.Token]:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": 404, "message": "Requested entity was not found.", "status": "NOT_FOUND",
+			"details": [{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError", "errorCode": "UNREGISTERED"}]}}`)
+	default:
+		fmt.Fprintf(w, `{"name": "projects/practice-app/messages/%d"}`, index)
+	}
+}
+
+func (f *fakeFCM) messages() []fcmMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fcmMessage(nil), f.received...)
+}
+
+// fakeDevices keeps device tokens in memory
+type fakeDevices struct {
+	devices     []models.UserDevice
+	deactivated []string
+}
+
+func (d *fakeDevices) GetActiveDevices(ctx context.Context, userID uuid.UUID) ([]models.UserDevice, error) {
+	var active []models.UserDevice
+	for _, device := range d.devices {
+		if device.UserID == userID && device.Active {
+			active = append(active, device)
+		}
+	}
+	return active, nil
+}
+
+func (d *fakeDevices) DeactivateDevice(ctx context.Context, token string) error {
+	d.deactivated = append(d.deactivated, token)
+	for i := range d.devices {
+		if d.devices[i].Token == token {
+			d.devices[i].Active = false
+		}
+	}
+	return nil
+}
+
+// staticToken always returns the same access token
+type staticToken string
+
+func (s staticToken) Token(ctx context.Context) (string, error) { return string(s), nil }
+
+func newTestSender(fake *fakeFCM, tokens ...string) (*Sender, *fakeDevices, *models.Notification) {
+	userID := uuid.New()
+	devices := &fakeDevices{}
+	for i, token := range tokens {
+		devices.devices = append(devices.devices, models.UserDevice{ID: int64(i + 1), UserID: userID, Token: token, Active: true})
+	}
+	sender := NewSender(config.PushConfig{
+		ProjectID: "practice-app",
+		Endpoint:  fake.server.URL,
+		Timeout:   time.Second,
+	}, staticToken("access-token"), devices)
+
+	title := "Keep your streak going"
+	n := &models.Notification{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelPush,
+		Title:    &title,
+		Message:  "Practice for 5 minutes today",
+		Metadata: models.JSONMap{"deep_link": "app://practice", "streak": 12},
+	}
+	return sender, devices, n
+}
+
+func assertProviderStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var providerErr *delivery.ProviderError
+	require.True(t, errors.As(err, &providerErr), "expected a ProviderError, got %v", err)
+	assert.Equal(t, status, providerErr.StatusCode)
+}
+
+func TestSend_FansOutToEveryDevice(t *testing.T) {
+	fake := startFakeFCM(t)
+	sender, devices, n := newTestSender(fake, "phone", "tablet")
+
+	name, err := sender.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, "projects/practice-app/messages/1", name)
+	received := fake.messages()
+	require.Len(t, received, 2)
+	assert.Equal(t, "phone", received[0].Message.Token)
+	assert.Equal(t, "tablet", received[1].Message.Token)
+	assert.Equal(t, "Keep your streak going", received[0].Message.Notification.Title)
+	assert.Equal(t, "Practice for 5 minutes today", received[0].Message.Notification.Body)
+	assert.Equal(t, map[string]string{
+		"deep_link":       "app://practice",
+		"streak":          "12",
+		"notification_id": n.ID.String(),
+		"type":            "streak_reminder",
+	}, received[0].Message.Data)
+	assert.Equal(t, "Bearer access-token", fake.auth[0])
+	assert.Empty(t, devices.deactivated)
+}
+
+func TestSend_DeactivatesUnregisteredToken(t *testing.T) {
+	fake := startFakeFCM(t)
+	fake.unregistered["old-phone"] = true
+	sender, devices, n := newTestSender(fake, "old-phone", "new-phone")
+
+	name, err := sender.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, "projects/practice-app/messages/2", name)
+	assert.Equal(t, []string{"old-phone"}, devices.deactivated)
+}
+
+func TestSend_AllTokensUnregisteredIsPermanent(t *testing.T) {
+	fake := startFakeFCM(t)
+	fake.unregistered["old-phone"] = true
+	sender, devices, n := newTestSender(fake, "old-phone")
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, http.StatusNotFound)
+	assert.ErrorIs(t, err, ErrNoDevices)
+	assert.Equal(t, []string{"old-phone"}, devices.deactivated)
+}
+
+func TestSend_NoDevicesIsPermanent(t *testing.T) {
+	fake := startFakeFCM(t)
+	sender, _, n := newTestSender(fake)
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, http.StatusNotFound)
+	assert.Empty(t, fake.messages())
+}
+
+func TestSend_ThrottledIsRetryable(t *testing.T) {
+	fake := startFakeFCM(t)
+	fake.status = http.StatusTooManyRequests
+	sender, devices, n := newTestSender(fake, "phone")
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, http.StatusServiceUnavailable)
+	assert.Empty(t, devices.deactivated)
+}
+
+func TestServiceAccountTokenSource_CachesToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var requests int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+		assert.Len(t, strings.Split(r.PostForm.Get("assertion"), "."), 3)
+		fmt.Fprint(w, `{"access_token": "ya29.token", "expires_in": 3600, "token_type": "Bearer"}`)
+	}))
+	defer tokenServer.Close()
+
+	credentials, err := json.Marshal(map[string]string{
+		"client_email": "notifier@practice-app.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenServer.URL,
+	})
+	require.NoError(t, err)
+	tokens, err := NewServiceAccountTokenSource(credentials, nil)
+	require.NoError(t, err)
+
+	first, err := tokens.Token(context.Background())
+	require.NoError(t, err)
+	second, err := tokens.Token(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "ya29.token", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, requests)
+}
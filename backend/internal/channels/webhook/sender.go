@@ -0,0 +1,115 @@
+// Package webhook delivers notifications by POSTing them to user webhooks
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256, keyed with the
+	// user's secret, of the timestamp header, a dot and the body
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the Unix time the request was signed at
+	TimestampHeader = "X-Webhook-Timestamp"
+	// IDHeader carries the notification ID so receivers can drop retried requests
+	IDHeader = "X-Webhook-Id"
+)
+
+// Endpoints finds the webhook a user registered; the webhook repository implements it
+type Endpoints interface {
+	GetWebhook(ctx context.Context, userID uuid.UUID) (*models.UserWebhook, error)
+}
+
+// Sender POSTs notifications as JSON to the user's webhook
+type Sender struct {
+	cfg       config.WebhookConfig
+	endpoints Endpoints
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewSender creates a webhook sender
+func NewSender(cfg config.WebhookConfig, endpoints Endpoints) *Sender {
+	return &Sender{cfg: cfg, endpoints: endpoints, client: &http.Client{}, now: time.Now}
+}
+
+// Name identifies the provider in delivery attempts
+func (s *Sender) Name() string { return "webhook" }
+
+// Sign returns the signature header value for a body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs the signed notification to the user's webhook within the
+// configured timeout. Any 2xx response delivers it. Timeouts, unreachable
+// endpoints, 429 and 5xx responses are returned as retryable errors; other
+// responses and users without a webhook fail with a 4xx ProviderError so
+// they are not retried.
+func (s *Sender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	endpoint, err := s.endpoints.GetWebhook(ctx, n.UserID)
+	if err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			return "", &delivery.ProviderError{StatusCode: http.StatusNotFound, Err: err}
+		}
+		return "", err
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", &delivery.ProviderError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("invalid webhook URL: %w", err)}
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+	req.Header.Set(IDHeader, n.ID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.Header.Get("X-Request-Id"), nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return "", &delivery.ProviderError{StatusCode: http.StatusServiceUnavailable, Err: fmt.Errorf("webhook returned %s", resp.Status)}
+	}
+	return "", &delivery.ProviderError{StatusCode: resp.StatusCode, Err: fmt.Errorf("webhook returned %s", resp.Status)}
+}
@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/delivery"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef"
+
+// fakeEndpoints looks webhooks up in a map
+type fakeEndpoints map[uuid.UUID]*models.UserWebhook
+
+func (e fakeEndpoints) GetWebhook(ctx context.Context, userID uuid.UUID) (*models.UserWebhook, error) {
+	webhook, ok := e[userID]
+	if !ok {
+		return nil, apperr.New(apperr.ErrNotFound, "webhook not found")
+	}
+	return webhook, nil
+}
+
+func newTestSender(url string) (*Sender, *models.Notification) {
+	n := &models.Notification{
+		ID:      uuid.New(),
+		UserID:  uuid.New(),
+		Type:    models.AchievementUnlock,
+		Channel: models.ChannelWebhook,
+		Message: "You unlocked Night Owl",
+	}
+	sender := NewSender(config.WebhookConfig{Timeout: 200 * time.Millisecond},
+		fakeEndpoints{n.UserID: {UserID: n.UserID, URL: url, Secret: testSecret}})
+	sender.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return sender, n
+}
+
+func assertProviderStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var providerErr *delivery.ProviderError
+	require.True(t, errors.As(err, &providerErr), "expected a ProviderError, got %v", err)
+	assert.Equal(t, status, providerErr.StatusCode)
+}
+
+func TestSend_SignsNotification(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	sender, n := newTestSender(server.URL)
+
+	messageID, err := sender.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, "req-42", messageID)
+	assert.Equal(t, "1700000000", received.Header.Get(TimestampHeader))
+	assert.Equal(t, n.ID.String(), received.Header.Get(IDHeader))
+	assert.Equal(t, Sign(testSecret, "1700000000", body), received.Header.Get(SignatureHeader))
+	assert.NotEqual(t, Sign("another-secret!!", "1700000000", body), received.Header.Get(SignatureHeader))
+
+	var decoded models.Notification
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, n.ID, decoded.ID)
+	assert.Equal(t, "You unlocked Night Owl", decoded.Message)
+}
+
+func TestSign_KnownVector(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac 0123456789abcdef
+	assert.Equal(t, "sha256=e4f8e2ecae2295b2ddb2f0b5584c8275e226c0ebe9b3b819e70156bb67122e3e",
+		Sign(testSecret, "1700000000", []byte("{}")))
+}
+
+func TestSend_Classification(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+	}{
+		{name: "server error is retryable", status: http.StatusBadGateway, wantStatus: http.StatusServiceUnavailable},
+		{name: "throttled is retryable", status: http.StatusTooManyRequests, wantStatus: http.StatusServiceUnavailable},
+		{name: "rejected is permanent", status: http.StatusGone, wantStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			sender, n := newTestSender(server.URL)
+
+			_, err := sender.Send(context.Background(), n)
+
+			assertProviderStatus(t, err, tt.wantStatus)
+		})
+	}
+}
+
+func TestSend_TimeoutIsRetryable(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	sender, n := newTestSender(server.URL)
+
+	_, err := sender.Send(context.Background(), n)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var providerErr *delivery.ProviderError
+	assert.False(t, errors.As(err, &providerErr), "timeouts must not look like rejections")
+}
+
+func TestSend_NoWebhookIsPermanent(t *testing.T) {
+	sender, n := newTestSender("http://127.0.0.1:1")
+	n.UserID = uuid.New()
+
+	_, err := sender.Send(context.Background(), n)
+
+	assertProviderStatus(t, err, http.StatusNotFound)
+}
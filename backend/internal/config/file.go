@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in Dump
+const redacted = "[REDACTED]"
+
+// loadFile overlays the YAML or JSON file at path onto c; keys mirror the
+// yaml tags on Config, e.g. database.max_open_conns or kafka.dlq.topic, and
+// durations are strings such as 30s. Settings the file leaves out keep their
+// current values. It returns the dotted paths of keys with no matching
+// setting, which are ignored.
+func (c *Config) loadFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if err := doc.Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+	}
+	return unknownKeys(doc.Content[0], reflect.TypeOf(*c), ""), nil
+}
+
+// unknownKeys returns the dotted paths of the keys in a mapping node that
+// have no yaml-tagged field in struct type t, recursing into nested structs
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	if node.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" && name != "-" {
+			fields[name] = field.Type
+		}
+	}
+
+	var unknown []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fieldType, ok := fields[node.Content[i].Value]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		unknown = append(unknown, unknownKeys(node.Content[i+1], fieldType, key)...)
+	}
+	return unknown
+}
+
+// Dump returns the effective configuration keyed like CONFIG_FILE, with
+// passwords and tokens redacted, for debugging what a service is running with
+func (c *Config) Dump() (map[string]interface{}, error) {
+	safe := *c
+	for _, secret := range []*string{
+		&safe.Server.AdminToken,
+		&safe.Database.Password,
+		&safe.Kafka.SASLPassword,
+		&safe.Scheduler.AdminToken,
+		&safe.Email.Password,
+		&safe.Auth.JWTSecret,
+		&safe.Inbox.ProducerAPIKey,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	// A new slice, so the running config keeps its keys
+	safe.Server.InternalAPIKeys = make([]string, len(c.Server.InternalAPIKeys))
+	for i := range safe.Server.InternalAPIKeys {
+		safe.Server.InternalAPIKeys[i] = redacted
+	}
+
+	data, err := yaml.Marshal(&safe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	var dump map[string]interface{}
+	if err := yaml.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	return dump, nil
+}
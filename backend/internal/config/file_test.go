@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes a CONFIG_FILE and points the environment at it
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+	return path
+}
+
+const layeredYAML = `
+database:
+  host: db.internal
+  port: 6432
+  max_open_conns: 50
+kafka:
+  brokers: [kafka-1:9092, kafka-2:9092]
+  producer_config:
+    compression: GZIP
+  dlq:
+    topic: notifications-dead
+scheduler:
+  daily_reminder_interval: 10m
+`
+
+func TestLoad_ConfigFileOnly(t *testing.T) {
+	writeConfigFile(t, "config.yaml", layeredYAML)
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, 6432, cfg.Database.Port)
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5, cfg.Database.MaxIdleConns, "settings the file leaves out keep their defaults")
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.Kafka.Brokers)
+	assert.Equal(t, "gzip", cfg.Kafka.ProducerConfig.Compression, "file values are normalized like env values")
+	assert.Equal(t, "notifications-dead", cfg.Kafka.DLQ.Topic)
+	assert.Equal(t, 10*time.Minute, cfg.Scheduler.DailyReminderInterval)
+	assert.Equal(t, 5*time.Minute, cfg.Scheduler.StreakReminderInterval)
+}
+
+func TestLoad_ConfigFileJSON(t *testing.T) {
+	writeConfigFile(t, "config.json", `{"server": {"port": ":9090", "read_timeout": "5s"}, "logging": {"level": "debug"}}`)
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, ":9090", cfg.Server.Port)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+}
+
+func TestLoad_EnvOverridesConfigFile(t *testing.T) {
+	writeConfigFile(t, "config.yaml", layeredYAML)
+	t.Setenv("DB_PORT", "7432")
+	t.Setenv("SCHEDULER_DAILY_INTERVAL", "1m")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 7432, cfg.Database.Port, "env wins over the file")
+	assert.Equal(t, time.Minute, cfg.Scheduler.DailyReminderInterval)
+	assert.Equal(t, "db.internal", cfg.Database.Host, "file wins over defaults")
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+}
+
+func TestLoad_ConfigFileIsValidated(t *testing.T) {
+	writeConfigFile(t, "config.yaml", "database:\n  ssl_mode: on-ish\n")
+
+	_, err := Load()
+
+	assert.ErrorContains(t, err, `unknown DB_SSLMODE "on-ish"`)
+}
+
+func TestLoad_ConfigFileRejected(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		want     string
+	}{
+		"malformed":  {"database: [", "failed to parse CONFIG_FILE"},
+		"wrong type": {"database:\n  port: fifty\n", "failed to parse CONFIG_FILE"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			writeConfigFile(t, "config.yaml", tt.contents)
+
+			_, err := Load()
+
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err := Load()
+	assert.ErrorContains(t, err, "failed to read CONFIG_FILE")
+}
+
+func TestConfig_LoadFileReportsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+databse:
+  host: typo
+database:
+  hots: typo
+  host: db.internal
+kafka:
+  dlq:
+    topik: typo
+  topics:
+    urgent: notifications-urgent
+`)
+	cfg := defaults()
+
+	unknown, err := cfg.loadFile(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"databse", "database.hots", "kafka.dlq.topik"}, unknown)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, "notifications-urgent", cfg.Kafka.TopicFor("urgent"))
+}
+
+func TestConfig_DumpRedactsSecrets(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "db-secret")
+	t.Setenv("ADMIN_API_TOKEN", "admin-secret")
+	t.Setenv("SMTP_PASSWORD", "")
+	t.Setenv("AUTH_JWT_SECRET", "jwt-secret")
+	t.Setenv("INTERNAL_API_KEYS", "new-key-00000001,old-key-00000001")
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	dump, err := cfg.Dump()
+
+	require.NoError(t, err)
+	database := dump["database"].(map[string]interface{})
+	assert.Equal(t, redacted, database["password"])
+	assert.Equal(t, "localhost", database["host"])
+	assert.Equal(t, redacted, dump["server"].(map[string]interface{})["admin_token"])
+	assert.Equal(t, redacted, dump["auth"].(map[string]interface{})["jwt_secret"])
+	assert.Equal(t, []interface{}{redacted, redacted}, dump["server"].(map[string]interface{})["internal_api_keys"])
+	assert.Equal(t, "new-key-00000001", cfg.Server.InternalAPIKeys[0], "dumping leaves the keys untouched")
+	assert.Equal(t, "", dump["email"].(map[string]interface{})["password"], "unset secrets stay empty")
+	assert.Equal(t, "30s", dump["server"].(map[string]interface{})["read_timeout"])
+	assert.Equal(t, "db-secret", cfg.Database.Password, "dumping leaves the config untouched")
+}
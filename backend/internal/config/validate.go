@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvDevelopment is the APP_ENV value for local development, where default
+// credentials are expected
+const EnvDevelopment = "development"
+
+// Default database credentials, warned about outside development
+const (
+	defaultDBUser     = "postgres"
+	defaultDBPassword = "postgres"
+)
+
+// minAPIKeyLength is the shortest INTERNAL_API_KEYS entry accepted
+const minAPIKeyLength = 16
+
+// SSLModes lists the allowed DB_SSLMODE values
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// LogLevels lists the allowed LOG_LEVEL values
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// ValidationError lists every problem found in the configuration, so a bad
+// deployment can be fixed in one go rather than one restart per setting
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid configuration: " + e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the individual problems for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks ports, Kafka brokers and acks, durations, the database SSL
+// mode and the log level, along with the Kafka and scheduler settings, and
+// returns every problem it finds as a *ValidationError
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.listenAddr("SERVER_PORT", c.Server.Port)
+	v.listenAddr("CONSUMER_PORT", c.ConsumerServer.Port)
+	if c.Scheduler.AdminPort != "" {
+		v.listenAddr("SCHEDULER_ADMIN_PORT", c.Scheduler.AdminPort)
+	}
+	v.port("DB_PORT", c.Database.Port)
+	v.port("SMTP_PORT", c.Email.Port)
+
+	if len(c.Kafka.Brokers) == 0 {
+		v.add(fmt.Errorf("KAFKA_BROKERS must list at least one broker, e.g. localhost:9092"))
+	}
+	for _, broker := range c.Kafka.Brokers {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			v.add(fmt.Errorf("KAFKA_BROKERS entry %q must be host:port", broker))
+		}
+	}
+	switch c.Kafka.ProducerConfig.RequiredAcks {
+	case -1, 0, 1:
+	default:
+		v.add(fmt.Errorf("KAFKA_PRODUCER_REQUIRED_ACKS must be -1 (all replicas), 0 (none) or 1 (leader), got %d",
+			c.Kafka.ProducerConfig.RequiredAcks))
+	}
+
+	v.oneOf("DB_SSLMODE", c.Database.SSLMode, SSLModes)
+	v.oneOf("LOG_LEVEL", c.Logging.Level, LogLevels)
+
+	v.positive("SERVER_READ_TIMEOUT", c.Server.ReadTimeout)
+	v.positive("SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout)
+	v.positive("SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout)
+	v.positive("DB_CONN_MAX_LIFETIME", c.Database.ConnMaxLifetime)
+	v.positive("DB_CONN_MAX_IDLE_TIME", c.Database.ConnMaxIdleTime)
+	v.positive("KAFKA_PRODUCER_TIMEOUT", c.Kafka.ProducerConfig.Timeout)
+	v.positive("KAFKA_METADATA_REFRESH_FREQUENCY", c.Kafka.ProducerConfig.MetadataRefreshFrequency)
+	v.positive("KAFKA_CONSUMER_SESSION_TIMEOUT", c.Kafka.ConsumerConfig.SessionTimeout)
+	v.positive("KAFKA_CONSUMER_HEARTBEAT_INTERVAL", c.Kafka.ConsumerConfig.HeartbeatInterval)
+	v.positive("KAFKA_DLQ_RETRY_INTERVAL", c.Kafka.DLQ.RetryInterval)
+	v.positive("KAFKA_CONNECT_BACKOFF", c.Kafka.ConnectRetry.InitialBackoff)
+	v.positive("KAFKA_CONNECT_MAX_BACKOFF", c.Kafka.ConnectRetry.MaxBackoff)
+	v.positive("OUTBOX_INTERVAL", c.Outbox.Interval)
+	v.positive("OUTBOX_RETENTION", c.Outbox.Retention)
+	v.positive("OUTBOX_PURGE_INTERVAL", c.Outbox.PurgeInterval)
+	v.positive("OUTBOX_BACKOFF_BASE", c.Outbox.BackoffBase)
+	v.positive("OUTBOX_BACKOFF_MAX", c.Outbox.BackoffMax)
+	v.positive("SCHEDULED_DISPATCH_INTERVAL", c.Scheduler.DispatchInterval)
+	v.positive("SLO_REFRESH_INTERVAL", c.SLO.RefreshInterval)
+	v.positive("DEDUPE_WINDOW", c.Dedupe.Window)
+	v.positive("DELIVERY_ERROR_WINDOW", c.Delivery.ErrorWindow)
+	v.positive("DELIVERY_PROBE_INTERVAL", c.Delivery.ProbeInterval)
+	v.positive("SMTP_TIMEOUT", c.Email.Timeout)
+	v.positive("FCM_TIMEOUT", c.Push.Timeout)
+	v.positive("WEBHOOK_TIMEOUT", c.Webhook.Timeout)
+	v.positive("CONSUMER_READY_GRACE_PERIOD", c.ConsumerServer.ReadyGracePeriod)
+	v.positive("CONSUMER_STREAM_HEARTBEAT", c.ConsumerServer.StreamHeartbeat)
+	v.positive("CONSUMER_STORE_EVICTION_INTERVAL", c.Inbox.EvictionInterval)
+	v.positive("PRODUCER_API_TIMEOUT", c.Inbox.ProducerTimeout)
+	v.positive("AUTH_JWKS_REFRESH_INTERVAL", c.Auth.JWKSRefreshInterval)
+
+	// These may be 0 to disable a loop or a limit, but never negative
+	v.notNegative("SCHEDULER_DAILY_INTERVAL", c.Scheduler.DailyReminderInterval)
+	v.notNegative("SCHEDULER_STREAK_INTERVAL", c.Scheduler.StreakReminderInterval)
+	v.notNegative("SCHEDULER_WEEKLY_INTERVAL", c.Scheduler.WeeklyRecapInterval)
+	v.notNegative("SCHEDULER_NUDGE_INTERVAL", c.Scheduler.EngagementNudgeInterval)
+	v.notNegative("SCHEDULER_LAST_CHANCE_INTERVAL", c.Scheduler.LastChanceInterval)
+	v.notNegative("SCHEDULER_XP_GOAL_INTERVAL", c.Scheduler.XPGoalInterval)
+	v.notNegative("SCHEDULER_ANNOUNCEMENT_INTERVAL", c.Scheduler.AnnouncementInterval)
+	v.notNegative("STREAK_TYPICAL_HOUR_INTERVAL", c.Scheduler.TypicalHourInterval)
+	v.notNegative("KAFKA_PRODUCER_MAX_AGE", c.Kafka.ProducerConfig.MaxAge)
+	v.notNegative("KAFKA_CONNECT_DEADLINE", c.Kafka.ConnectRetry.Deadline)
+	v.notNegative("OUTBOX_STATS_INTERVAL", c.Outbox.StatsInterval)
+	v.notNegative("DELIVERY_REQUEUE_INTERVAL", c.Delivery.RequeueInterval)
+	v.notNegative("DELIVERY_PROVIDER_TIMEOUT", c.Delivery.ProviderTimeout)
+	v.notNegative("CONSUMER_STORE_TTL", c.Inbox.TTL)
+	v.notNegative("CORS_MAX_AGE", c.CORS.MaxAge)
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		v.corsOrigin(origin)
+	}
+
+	// Keys are never echoed back, only their position
+	if len(c.Server.InternalAPIKeys) == 0 && c.Environment != EnvDevelopment {
+		v.add(fmt.Errorf("INTERNAL_API_KEYS is required in APP_ENV=%s", c.Environment))
+	}
+	for i, key := range c.Server.InternalAPIKeys {
+		if len(key) < minAPIKeyLength {
+			v.add(fmt.Errorf("INTERNAL_API_KEYS entry %d is shorter than %d characters", i+1, minAPIKeyLength))
+		}
+	}
+
+	if err := c.Auth.validate(c.Environment); err != nil {
+		v.add(fmt.Errorf("invalid auth configuration: %w", err))
+	}
+	if err := c.Kafka.ValidateSecurity(); err != nil {
+		v.add(fmt.Errorf("invalid Kafka configuration: %w", err))
+	}
+	if err := c.Kafka.ProducerConfig.Validate(); err != nil {
+		v.add(fmt.Errorf("invalid Kafka configuration: %w", err))
+	}
+	if err := c.Scheduler.Validate(); err != nil {
+		v.add(fmt.Errorf("invalid scheduler configuration: %w", err))
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validate checks exactly one way of verifying tokens is configured; only
+// development may run the user routes unauthenticated
+func (a AuthConfig) validate(env string) error {
+	switch {
+	case a.JWTSecret != "" && a.JWKSURL != "":
+		return fmt.Errorf("set AUTH_JWT_SECRET or AUTH_JWKS_URL, not both")
+	case !a.Enabled() && env != EnvDevelopment:
+		return fmt.Errorf("AUTH_JWT_SECRET or AUTH_JWKS_URL is required in APP_ENV=%s", env)
+	case a.JWKSURL != "":
+		u, err := url.Parse(a.JWKSURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("AUTH_JWKS_URL must be an http or https URL")
+		}
+	}
+	if a.AdminRole == "" {
+		return fmt.Errorf("AUTH_ADMIN_ROLE must not be empty")
+	}
+	return nil
+}
+
+// CredentialWarnings returns a warning for each credential left at its
+// development default outside APP_ENV=development
+func (c *Config) CredentialWarnings() []string {
+	if c.Environment == EnvDevelopment {
+		return nil
+	}
+	var warnings []string
+	if c.Database.User == defaultDBUser && c.Database.Password == defaultDBPassword {
+		warnings = append(warnings, fmt.Sprintf(
+			"DB_USER and DB_PASSWORD are the development defaults in APP_ENV=%s; set real database credentials", c.Environment))
+	}
+	return warnings
+}
+
+// validator collects configuration problems
+type validator struct {
+	problems []error
+}
+
+func (v *validator) add(err error) {
+	v.problems = append(v.problems, err)
+}
+
+// listenAddr checks an address to listen on, such as :8082 or 0.0.0.0:8082
+func (v *validator) listenAddr(key, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.add(fmt.Errorf("%s=%q must be [host]:port, e.g. :8082", key, addr))
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.add(fmt.Errorf("%s=%q must have a port between 1 and 65535", key, addr))
+	}
+}
+
+// corsOrigin checks an allowed origin is *, scheme://host[:port] or
+// scheme://*.domain[:port], without a path
+func (v *validator) corsOrigin(origin string) {
+	if origin == "*" {
+		return
+	}
+	u, err := url.Parse(origin)
+	host := ""
+	if err == nil {
+		host = strings.TrimPrefix(u.Hostname(), "*.")
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || host == "" || strings.Contains(host, "*") ||
+		u.User != nil || u.Path != "" || u.RawQuery != "" {
+		v.add(fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must be *, scheme://host[:port] or scheme://*.domain[:port]", origin))
+	}
+}
+
+func (v *validator) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		v.add(fmt.Errorf("%s must be between 1 and 65535, got %d", key, port))
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(fmt.Errorf("unknown %s %q: want one of %s", key, value, strings.Join(allowed, ", ")))
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.add(fmt.Errorf("%s must be positive, got %s", key, d))
+	}
+}
+
+func (v *validator) notNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.add(fmt.Errorf("%s must not be negative, got %s", key, d))
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_RejectsInvalidSettings(t *testing.T) {
+	tests := map[string]struct {
+		key, value string
+		want       string
+	}{
+		"unparsable port":      {"DB_PORT", "54r32", `DB_PORT="54r32" is not an integer`},
+		"port out of range":    {"DB_PORT", "70000", "DB_PORT must be between 1 and 65535, got 70000"},
+		"listen address":       {"SERVER_PORT", "8082", `SERVER_PORT="8082" must be [host]:port`},
+		"listen port":          {"CONSUMER_PORT", ":http", `CONSUMER_PORT=":http" must have a port between 1 and 65535`},
+		"broker without port":  {"KAFKA_BROKERS", "kafka-1", `KAFKA_BROKERS entry "kafka-1" must be host:port`},
+		"no brokers":           {"KAFKA_BROKERS", ",", "KAFKA_BROKERS must list at least one broker"},
+		"required acks":        {"KAFKA_PRODUCER_REQUIRED_ACKS", "2", "KAFKA_PRODUCER_REQUIRED_ACKS must be -1 (all replicas), 0 (none) or 1 (leader), got 2"},
+		"unparsable duration":  {"SERVER_READ_TIMEOUT", "30", `SERVER_READ_TIMEOUT="30" is not a duration`},
+		"zero duration":        {"OUTBOX_INTERVAL", "0s", "OUTBOX_INTERVAL must be positive, got 0s"},
+		"negative interval":    {"SCHEDULER_DAILY_INTERVAL", "-5m", "SCHEDULER_DAILY_INTERVAL must not be negative"},
+		"ssl mode":             {"DB_SSLMODE", "on", `unknown DB_SSLMODE "on": want one of disable, allow, prefer, require, verify-ca, verify-full`},
+		"log level":            {"LOG_LEVEL", "verbose", `unknown LOG_LEVEL "verbose"`},
+		"unparsable bool":      {"KAFKA_AUTO_CREATE_TOPICS", "yes", `KAFKA_AUTO_CREATE_TOPICS="yes" is not true or false`},
+		"unparsable threshold": {"XP_GOAL_THRESHOLD", "70%", `XP_GOAL_THRESHOLD="70%" is not a number`},
+		"cors origin path":     {"CORS_ALLOWED_ORIGINS", "https://app.example.com/", `CORS_ALLOWED_ORIGINS entry "https://app.example.com/" must be *`},
+		"cors origin scheme":   {"CORS_ALLOWED_ORIGINS", "app.example.com", `CORS_ALLOWED_ORIGINS entry "app.example.com"`},
+		"cors inner wildcard":  {"CORS_ALLOWED_ORIGINS", "https://app.*.com", `CORS_ALLOWED_ORIGINS entry "https://app.*.com"`},
+		"short api key":        {"INTERNAL_API_KEYS", "0123456789abcdef,short", "INTERNAL_API_KEYS entry 2 is shorter than 16 characters"},
+		"jwks url":             {"AUTH_JWKS_URL", "auth.example.com/jwks", "invalid auth configuration: AUTH_JWKS_URL must be an http or https URL"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := Load()
+
+			var invalid *ValidationError
+			require.True(t, errors.As(err, &invalid), "expected a ValidationError, got %v", err)
+			require.Len(t, invalid.Problems, 1)
+			assert.ErrorContains(t, err, "invalid configuration: "+tt.want)
+		})
+	}
+}
+
+func TestLoad_ProductionRequiresCredentials(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+
+	_, err := Load()
+
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid), "expected a ValidationError, got %v", err)
+	assert.ErrorContains(t, err, "INTERNAL_API_KEYS is required in APP_ENV=production")
+	assert.ErrorContains(t, err, "AUTH_JWT_SECRET or AUTH_JWKS_URL is required in APP_ENV=production")
+}
+
+func TestLoad_AuthSettings(t *testing.T) {
+	t.Run("secret in production", func(t *testing.T) {
+		t.Setenv("APP_ENV", "production")
+		t.Setenv("AUTH_JWT_SECRET", "s3cret")
+		t.Setenv("INTERNAL_API_KEYS", "0123456789abcdef")
+
+		cfg, err := Load()
+
+		require.NoError(t, err)
+		assert.True(t, cfg.Auth.Enabled())
+	})
+
+	t.Run("secret and key set", func(t *testing.T) {
+		t.Setenv("AUTH_JWT_SECRET", "s3cret")
+		t.Setenv("AUTH_JWKS_URL", "https://auth.example.com/.well-known/jwks.json")
+
+		_, err := Load()
+
+		assert.ErrorContains(t, err, "set AUTH_JWT_SECRET or AUTH_JWKS_URL, not both")
+	})
+
+	t.Run("disabled in development", func(t *testing.T) {
+		cfg, err := Load()
+
+		require.NoError(t, err)
+		assert.False(t, cfg.Auth.Enabled())
+	})
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("DB_PORT", "54r32")
+	t.Setenv("DB_SSLMODE", "on")
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("KAFKA_PRODUCER_MODE", "batch")
+
+	_, err := Load()
+
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	assert.Len(t, invalid.Problems, 4)
+	msg := err.Error()
+	assert.Contains(t, msg, "invalid configuration (4 problems):")
+	assert.Contains(t, msg, "\n  - DB_PORT=\"54r32\" is not an integer")
+	assert.Contains(t, msg, "\n  - unknown DB_SSLMODE")
+	assert.Contains(t, msg, "\n  - unknown LOG_LEVEL")
+	assert.Contains(t, msg, "\n  - invalid Kafka configuration: unknown KAFKA_PRODUCER_MODE")
+}
+
+func TestConfig_CredentialWarnings(t *testing.T) {
+	tests := map[string]struct {
+		env      string
+		password string
+		warns    bool
+	}{
+		"defaults in development": {env: EnvDevelopment, password: defaultDBPassword},
+		"defaults in production":  {env: "production", password: defaultDBPassword, warns: true},
+		"own password":            {env: "production", password: "s3cret"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{Environment: tt.env, Database: DatabaseConfig{User: defaultDBUser, Password: tt.password}}
+
+			warnings := cfg.CredentialWarnings()
+
+			if tt.warns {
+				require.Len(t, warnings, 1)
+				assert.Contains(t, warnings[0], "APP_ENV=production")
+			} else {
+				assert.Empty(t, warnings)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"kafka-notify/internal/config"
@@ -27,12 +28,14 @@ func NewConnectionManager(cfg *config.DatabaseConfig) (*ConnectionManager, error
 	if hostaddr != "" {
 		dsn = fmt.Sprintf(
 			"host=%s hostaddr=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, hostaddr, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode,
+			quoteDSNValue(cfg.Host), quoteDSNValue(hostaddr), cfg.Port, quoteDSNValue(cfg.User),
+			quoteDSNValue(cfg.Password), quoteDSNValue(cfg.Database), quoteDSNValue(cfg.SSLMode),
 		)
 	} else {
 		dsn = fmt.Sprintf(
 			"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode,
+			quoteDSNValue(cfg.Host), cfg.Port, quoteDSNValue(cfg.User),
+			quoteDSNValue(cfg.Password), quoteDSNValue(cfg.Database), quoteDSNValue(cfg.SSLMode),
 		)
 	}
 
@@ -97,3 +100,13 @@ func (cm *ConnectionManager) startHealthCheck() {
 func (cm *ConnectionManager) Stats() sql.DBStats {
 	return cm.db.Stats()
 }
+
+// quoteDSNValue quotes a key=value connection string value, so passwords from
+// DATABASE_URL with spaces, quotes or backslashes reach the server intact
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteDSNValue(t *testing.T) {
+	tests := map[string]string{
+		"secret":       "secret",
+		"":             "''",
+		"two words":    "'two words'",
+		"it's":         `'it\'s'`,
+		`back\slash`:   `'back\\slash'`,
+		"p@ss:w/rd=1#": "p@ss:w/rd=1#",
+	}
+
+	for value, want := range tests {
+		assert.Equal(t, want, quoteDSNValue(value), value)
+	}
+}
@@ -0,0 +1,333 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/metrics"
+	"kafka-notify/pkg/models"
+)
+
+// Sender delivers a notification through a single provider
+type Sender interface {
+	// Name identifies the provider in delivery attempts, config and metrics
+	Name() string
+	// Send delivers the notification and returns the provider's message ID
+	Send(ctx context.Context, n *models.Notification) (string, error)
+}
+
+// ProviderError is a provider response with an HTTP-style status code
+type ProviderError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider returned %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// isProviderFailure reports whether a send failed because the provider is
+// unhealthy (5xx, timeout, unreachable) rather than because it rejected the
+// notification itself (4xx)
+func isProviderFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode >= 500
+	}
+	return true
+}
+
+// AttemptRecorder persists delivery attempts; the notification repository implements it
+type AttemptRecorder interface {
+	CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error
+}
+
+// ChainConfig controls when a provider fails over and fails back
+type ChainConfig struct {
+	// ErrorRate is the share of failed sends in Window that takes a provider out of rotation
+	ErrorRate float64
+	// MinRequests is the number of sends in Window needed before ErrorRate applies
+	MinRequests int
+	// Window is how far back sends are counted
+	Window time.Duration
+	// ProbeInterval is how often a failed-over provider gets one send to prove it has recovered
+	ProbeInterval time.Duration
+	// Timeout bounds a single provider send; zero means no limit
+	Timeout time.Duration
+}
+
+// sendOutcome is one send counted towards a provider's rolling error rate
+type sendOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// provider is a sender and its health within a chain
+type provider struct {
+	sender    Sender
+	outcomes  []sendOutcome
+	down      bool
+	nextProbe time.Time
+}
+
+// errorRate drops outcomes older than the window and returns the remaining
+// send count and failure share
+func (p *provider) errorRate(now time.Time, window time.Duration) (int, float64) {
+	cutoff := now.Add(-window)
+	kept := p.outcomes[:0]
+	failed := 0
+	for _, o := range p.outcomes {
+		if o.at.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, o)
+		if o.failed {
+			failed++
+		}
+	}
+	p.outcomes = kept
+
+	if len(kept) == 0 {
+		return 0, 0
+	}
+	return len(kept), float64(failed) / float64(len(kept))
+}
+
+// ProviderChain sends a channel's notifications through an ordered list of
+// providers. Providers whose rolling error rate crosses the threshold are
+// skipped until a periodic probe succeeds, so traffic fails over to the next
+// provider and fails back once the primary recovers.
+type ProviderChain struct {
+	channel   models.NotificationChannel
+	cfg       ChainConfig
+	recorder  AttemptRecorder
+	now       func() time.Time
+	mu        sync.Mutex
+	providers []*provider
+}
+
+// NewProviderChain creates a chain over senders in failover order, primary first
+func NewProviderChain(channel models.NotificationChannel, cfg ChainConfig, recorder AttemptRecorder, senders ...Sender) (*ProviderChain, error) {
+	if len(senders) == 0 {
+		return nil, fmt.Errorf("no providers configured for channel %s", channel)
+	}
+
+	chain := &ProviderChain{
+		channel:  channel,
+		cfg:      cfg,
+		recorder: recorder,
+		now:      time.Now,
+	}
+	for _, sender := range senders {
+		chain.providers = append(chain.providers, &provider{sender: sender})
+	}
+	return chain, nil
+}
+
+// NewChains builds the provider chain for every channel configured in cfg,
+// looking providers up by name in the registry
+func NewChains(cfg config.DeliveryConfig, recorder AttemptRecorder, registry map[string]Sender) (map[models.NotificationChannel]*ProviderChain, error) {
+	chainCfg := ChainConfig{
+		ErrorRate:     cfg.FailoverErrorRate,
+		MinRequests:   cfg.FailoverMinRequests,
+		Window:        cfg.ErrorWindow,
+		ProbeInterval: cfg.ProbeInterval,
+		Timeout:       cfg.ProviderTimeout,
+	}
+
+	chains := make(map[models.NotificationChannel]*ProviderChain)
+	for channel, names := range cfg.Chains {
+		if len(names) == 0 {
+			continue
+		}
+
+		senders := make([]Sender, 0, len(names))
+		for _, name := range names {
+			sender, ok := registry[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown provider %q for channel %s", name, channel)
+			}
+			senders = append(senders, sender)
+		}
+
+		chain, err := NewProviderChain(models.NotificationChannel(channel), chainCfg, recorder, senders...)
+		if err != nil {
+			return nil, err
+		}
+		chains[models.NotificationChannel(channel)] = chain
+	}
+	return chains, nil
+}
+
+// Send delivers the notification through the first available provider. When a
+// provider fails with a 5xx or timeout the next one is tried right away; a
+// rejected notification (4xx) is not retried elsewhere. Every provider try is
+// recorded as its own delivery attempt, and n.LastAttemptNo is advanced.
+func (c *ProviderChain) Send(ctx context.Context, n *models.Notification) (*models.NotificationDeliveryAttempt, error) {
+	var lastAttempt *models.NotificationDeliveryAttempt
+	var lastErr error
+
+	for _, p := range c.candidates() {
+		if err := ctx.Err(); err != nil {
+			return lastAttempt, err
+		}
+
+		attempt, err := c.try(ctx, p, n)
+		c.observe(p, err)
+		if recordErr := c.recorder.CreateDeliveryAttempt(ctx, attempt); recordErr != nil {
+			log.Printf("Failed to record %s delivery attempt %d for notification %s: %v",
+				p.sender.Name(), attempt.AttemptNo, n.ID, recordErr)
+		}
+
+		if err == nil {
+			return attempt, nil
+		}
+		lastAttempt, lastErr = attempt, err
+		if !isProviderFailure(err) {
+			break
+		}
+	}
+
+	return lastAttempt, fmt.Errorf("failed to deliver notification %s via %s: %w", n.ID, c.channel, lastErr)
+}
+
+// Active returns the name of the provider new sends go to first
+func (c *ProviderChain) Active() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range c.providers {
+		if !p.down {
+			return p.sender.Name()
+		}
+	}
+	return c.providers[0].sender.Name()
+}
+
+// candidates returns the providers to try in order: healthy ones, plus any
+// failed-over provider that is due a probe. When every provider is down they
+// are all tried rather than dropping the notification.
+func (c *ProviderChain) candidates() []*provider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var candidates []*provider
+	for _, p := range c.providers {
+		if p.down {
+			if now.Before(p.nextProbe) {
+				continue
+			}
+			// Only one send probes; the rest keep skipping until the next interval
+			p.nextProbe = now.Add(c.cfg.ProbeInterval)
+		}
+		candidates = append(candidates, p)
+	}
+
+	if len(candidates) == 0 {
+		return append(candidates, c.providers...)
+	}
+	return candidates
+}
+
+// try sends through one provider and builds the delivery attempt for it
+func (c *ProviderChain) try(ctx context.Context, p *provider, n *models.Notification) (*models.NotificationDeliveryAttempt, error) {
+	return sendAttempt(ctx, p.sender, n, c.cfg.Timeout, c.now)
+}
+
+// sendAttempt sends the notification through one sender, bounded by timeout
+// when it is set, and builds the delivery attempt for it. The attempt number
+// follows n.LastAttemptNo, which is advanced.
+func sendAttempt(ctx context.Context, sender Sender, n *models.Notification, timeout time.Duration, now func() time.Time) (*models.NotificationDeliveryAttempt, error) {
+	attemptNo := 1
+	if n.LastAttemptNo != nil {
+		attemptNo = *n.LastAttemptNo + 1
+	}
+	n.LastAttemptNo = &attemptNo
+
+	sendCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		sendCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	name := sender.Name()
+	start := now()
+	messageID, err := sender.Send(sendCtx, n)
+	latencyMs := int(now().Sub(start).Milliseconds())
+
+	attempt := &models.NotificationDeliveryAttempt{
+		NotificationID: n.ID,
+		AttemptNo:      attemptNo,
+		Status:         models.StatusSent,
+		Provider:       &name,
+		LatencyMs:      &latencyMs,
+		CreatedAt:      now(),
+	}
+	if messageID != "" {
+		attempt.ProviderMessageID = &messageID
+	}
+	if err != nil {
+		attempt.Status = models.StatusFailed
+		errMsg := err.Error()
+		attempt.ErrorMessage = &errMsg
+
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) {
+			code := strconv.Itoa(providerErr.StatusCode)
+			attempt.ErrorCode = &code
+		}
+	}
+	return attempt, err
+}
+
+// observe counts a send towards the provider's error rate, failing it over
+// when the rate crosses the threshold and failing it back after a good probe
+func (c *ProviderChain) observe(p *provider, err error) {
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up; this says nothing about the provider
+		return
+	}
+	// A rejected notification (4xx) still means the provider answered
+	failed := isProviderFailure(err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	name := p.sender.Name()
+
+	if p.down {
+		if !failed {
+			p.down = false
+			p.outcomes = nil
+			metrics.DeliveryProviderFailbacks.WithLabelValues(string(c.channel), name).Inc()
+			log.Printf("Provider %s for %s recovered, failing back", name, c.channel)
+		}
+		return
+	}
+
+	p.outcomes = append(p.outcomes, sendOutcome{at: now, failed: failed})
+	sends, rate := p.errorRate(now, c.cfg.Window)
+	if failed && sends >= c.cfg.MinRequests && rate >= c.cfg.ErrorRate {
+		p.down = true
+		p.nextProbe = now.Add(c.cfg.ProbeInterval)
+		metrics.DeliveryProviderFailovers.WithLabelValues(string(c.channel), name).Inc()
+		log.Printf("Provider %s for %s failed over: %.0f%% errors over last %d sends", name, c.channel, rate*100, sends)
+	}
+}
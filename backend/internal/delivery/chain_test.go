@@ -0,0 +1,239 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender returns the configured error, or a message ID when it is nil
+type fakeSender struct {
+	name  string
+	err   error
+	block bool
+	calls int
+}
+
+func (s *fakeSender) Name() string { return s.name }
+
+func (s *fakeSender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	s.calls++
+	if s.block {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.name + "-msg", nil
+}
+
+// attemptLog records delivery attempts in order
+type attemptLog struct {
+	mu       sync.Mutex
+	attempts []*models.NotificationDeliveryAttempt
+}
+
+func (l *attemptLog) CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts = append(l.attempts, attempt)
+	return nil
+}
+
+func (l *attemptLog) providers() []string {
+	var names []string
+	for _, a := range l.attempts {
+		names = append(names, *a.Provider)
+	}
+	return names
+}
+
+var testChainConfig = ChainConfig{
+	ErrorRate:     0.5,
+	MinRequests:   3,
+	Window:        time.Minute,
+	ProbeInterval: 30 * time.Second,
+}
+
+func newTestChain(t *testing.T, cfg ChainConfig, senders ...Sender) (*ProviderChain, *attemptLog, *time.Time) {
+	t.Helper()
+	log := &attemptLog{}
+	chain, err := NewProviderChain(models.ChannelEmail, cfg, log, senders...)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	chain.now = func() time.Time { return now }
+	return chain, log, &now
+}
+
+func newEmail() *models.Notification {
+	return &models.Notification{ID: uuid.New(), Channel: models.ChannelEmail, Message: "Your weekly recap"}
+}
+
+func TestProviderChain_FailsOverAfterRepeatedServerErrors(t *testing.T) {
+	primary := &fakeSender{name: "smtp", err: &ProviderError{StatusCode: 503, Err: errors.New("service unavailable")}}
+	secondary := &fakeSender{name: "ses"}
+	chain, attempts, _ := newTestChain(t, testChainConfig, primary, secondary)
+
+	// Each failed primary send falls through to the secondary within the same call
+	for i := 0; i < 3; i++ {
+		attempt, err := chain.Send(context.Background(), newEmail())
+		require.NoError(t, err)
+		assert.Equal(t, "ses", *attempt.Provider)
+	}
+	assert.Equal(t, "ses", chain.Active())
+
+	// Once failed over, the primary is skipped entirely
+	n := newEmail()
+	attempt, err := chain.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.calls)
+	assert.Equal(t, 1, attempt.AttemptNo)
+	assert.Equal(t, "ses-msg", *attempt.ProviderMessageID)
+	assert.Equal(t, []string{"smtp", "ses", "smtp", "ses", "smtp", "ses", "ses"}, attempts.providers())
+}
+
+func TestProviderChain_RecordsEveryProviderAttempt(t *testing.T) {
+	primary := &fakeSender{name: "smtp", err: &ProviderError{StatusCode: 502, Err: errors.New("bad gateway")}}
+	secondary := &fakeSender{name: "ses"}
+	chain, attempts, _ := newTestChain(t, testChainConfig, primary, secondary)
+
+	lastAttempt := 2
+	n := newEmail()
+	n.LastAttemptNo = &lastAttempt
+
+	_, err := chain.Send(context.Background(), n)
+
+	require.NoError(t, err)
+	require.Len(t, attempts.attempts, 2)
+	failed, sent := attempts.attempts[0], attempts.attempts[1]
+	assert.Equal(t, 3, failed.AttemptNo)
+	assert.Equal(t, models.StatusFailed, failed.Status)
+	assert.Equal(t, "smtp", *failed.Provider)
+	assert.Equal(t, "502", *failed.ErrorCode)
+	assert.Equal(t, 4, sent.AttemptNo)
+	assert.Equal(t, models.StatusSent, sent.Status)
+	assert.Equal(t, "ses", *sent.Provider)
+	assert.Equal(t, 4, *n.LastAttemptNo)
+}
+
+func TestProviderChain_RejectedNotificationDoesNotFailOver(t *testing.T) {
+	primary := &fakeSender{name: "smtp", err: &ProviderError{StatusCode: 400, Err: errors.New("invalid recipient")}}
+	secondary := &fakeSender{name: "ses"}
+	chain, _, _ := newTestChain(t, testChainConfig, primary, secondary)
+
+	for i := 0; i < 5; i++ {
+		_, err := chain.Send(context.Background(), newEmail())
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 0, secondary.calls)
+	assert.Equal(t, "smtp", chain.Active())
+}
+
+func TestProviderChain_TimeoutCountsAsFailure(t *testing.T) {
+	cfg := testChainConfig
+	cfg.MinRequests = 1
+	cfg.Timeout = 10 * time.Millisecond
+	primary := &fakeSender{name: "smtp", block: true}
+	secondary := &fakeSender{name: "ses"}
+	chain, _, _ := newTestChain(t, cfg, primary, secondary)
+
+	attempt, err := chain.Send(context.Background(), newEmail())
+
+	require.NoError(t, err)
+	assert.Equal(t, "ses", *attempt.Provider)
+	assert.Equal(t, "ses", chain.Active())
+}
+
+func TestProviderChain_FailsBackAfterSuccessfulProbe(t *testing.T) {
+	primary := &fakeSender{name: "smtp", err: &ProviderError{StatusCode: 500, Err: errors.New("internal error")}}
+	secondary := &fakeSender{name: "ses"}
+	chain, attempts, now := newTestChain(t, testChainConfig, primary, secondary)
+
+	for i := 0; i < 3; i++ {
+		_, err := chain.Send(context.Background(), newEmail())
+		require.NoError(t, err)
+	}
+	require.Equal(t, "ses", chain.Active())
+
+	// A probe before the interval is not due
+	*now = now.Add(10 * time.Second)
+	_, err := chain.Send(context.Background(), newEmail())
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.calls)
+
+	// The primary is still broken at the first probe, so it stays failed over
+	*now = now.Add(30 * time.Second)
+	_, err = chain.Send(context.Background(), newEmail())
+	require.NoError(t, err)
+	assert.Equal(t, 4, primary.calls)
+	assert.Equal(t, "ses", chain.Active())
+
+	// It has recovered by the next probe and takes traffic again
+	primary.err = nil
+	*now = now.Add(30 * time.Second)
+	attempt, err := chain.Send(context.Background(), newEmail())
+	require.NoError(t, err)
+	assert.Equal(t, "smtp", *attempt.Provider)
+	assert.Equal(t, "smtp", chain.Active())
+
+	attempt, err = chain.Send(context.Background(), newEmail())
+	require.NoError(t, err)
+	assert.Equal(t, "smtp", *attempt.Provider)
+	assert.Equal(t, "smtp", attempts.providers()[len(attempts.providers())-1])
+}
+
+func TestProviderChain_AllProvidersDown(t *testing.T) {
+	cfg := testChainConfig
+	cfg.MinRequests = 1
+	unavailable := &ProviderError{StatusCode: 503, Err: errors.New("service unavailable")}
+	primary := &fakeSender{name: "smtp", err: unavailable}
+	secondary := &fakeSender{name: "ses", err: unavailable}
+	chain, _, _ := newTestChain(t, cfg, primary, secondary)
+
+	_, err := chain.Send(context.Background(), newEmail())
+	require.Error(t, err)
+
+	// Both are failed over, but sends still go out rather than being dropped
+	_, err = chain.Send(context.Background(), newEmail())
+
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 2, secondary.calls)
+}
+
+func TestNewChains_BuildsConfiguredChannels(t *testing.T) {
+	registry := map[string]Sender{
+		"smtp":   &fakeSender{name: "smtp"},
+		"ses":    &fakeSender{name: "ses"},
+		"twilio": &fakeSender{name: "twilio"},
+	}
+	cfg := config.DeliveryConfig{Chains: map[string][]string{
+		"email": {"smtp", "ses"},
+		"sms":   {"twilio"},
+		"push":  nil,
+	}}
+
+	chains, err := NewChains(cfg, &attemptLog{}, registry)
+
+	require.NoError(t, err)
+	assert.Len(t, chains, 2)
+	assert.Equal(t, "smtp", chains[models.ChannelEmail].Active())
+	assert.Equal(t, "twilio", chains[models.ChannelSMS].Active())
+
+	cfg.Chains["sms"] = []string{"sns"}
+	_, err = NewChains(cfg, &attemptLog{}, registry)
+	assert.ErrorContains(t, err, `unknown provider "sns"`)
+}
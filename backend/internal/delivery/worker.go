@@ -0,0 +1,148 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoSender is returned for a notification on a channel the worker has no sender for
+var ErrNoSender = errors.New("no sender for channel")
+
+// Store records delivery attempts and the final delivery status; the
+// notification repository implements it
+type Store interface {
+	AttemptRecorder
+	MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error
+	MarkAsFailed(ctx context.Context, notificationID uuid.UUID) error
+}
+
+// WorkerConfig controls how often a notification is retried
+type WorkerConfig struct {
+	// MaxAttempts is the number of sends before a notification is marked failed
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles for every retry after
+	Backoff time.Duration
+	// Timeout bounds a single send; zero means no limit
+	Timeout time.Duration
+}
+
+// Worker delivers notifications through the sender for their channel,
+// recording every send as a delivery attempt
+type Worker struct {
+	cfg     WorkerConfig
+	store   Store
+	senders map[models.NotificationChannel]Sender
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewWorker creates a worker over one sender per channel
+func NewWorker(cfg WorkerConfig, store Store, senders map[models.NotificationChannel]Sender) *Worker {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &Worker{
+		cfg:     cfg,
+		store:   store,
+		senders: senders,
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+}
+
+// Handles reports whether the worker has a sender for the channel
+func (w *Worker) Handles(channel models.NotificationChannel) bool {
+	_, ok := w.senders[channel]
+	return ok
+}
+
+// Deliver sends the notification through its channel's sender and marks it
+// delivered, or failed once a send is rejected (4xx) or MaxAttempts transient
+// failures (5xx, timeout, unreachable) have been used up. When ctx ends first
+// the notification is left as it is and ctx's error returned, so it can be
+// delivered again.
+func (w *Worker) Deliver(ctx context.Context, n *models.Notification) error {
+	sender, ok := w.senders[n.Channel]
+	if !ok {
+		w.markFailed(ctx, n)
+		return fmt.Errorf("%w %s", ErrNoSender, n.Channel)
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		record, err := sendAttempt(ctx, sender, n, w.cfg.Timeout, w.now)
+		if recordErr := w.store.CreateDeliveryAttempt(ctx, record); recordErr != nil {
+			log.Printf("Failed to record %s delivery attempt %d for notification %s: %v",
+				sender.Name(), record.AttemptNo, n.ID, recordErr)
+		}
+
+		if err == nil {
+			return w.store.MarkAsDelivered(ctx, n.ID)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		lastErr = err
+		if !isProviderFailure(err) || attempt >= w.cfg.MaxAttempts {
+			break
+		}
+		if err := w.sleep(ctx, w.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+
+	w.markFailed(ctx, n)
+	return fmt.Errorf("failed to deliver notification %s via %s: %w", n.ID, n.Channel, lastErr)
+}
+
+// backoff returns the wait after the given attempt
+func (w *Worker) backoff(attempt int) time.Duration {
+	return w.cfg.Backoff * time.Duration(1<<(attempt-1))
+}
+
+// markFailed marks the notification failed; failures are only logged since the
+// attempts are already recorded
+func (w *Worker) markFailed(ctx context.Context, n *models.Notification) {
+	if err := w.store.MarkAsFailed(ctx, n.ID); err != nil {
+		log.Printf("Failed to mark notification %s as failed: %v", n.ID, err)
+	}
+}
+
+// sleepContext waits for d or until ctx ends
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NoopSender accepts every notification without sending it anywhere, standing
+// in for a channel whose provider is not integrated yet
+type NoopSender struct {
+	// Provider is the name recorded on delivery attempts
+	Provider string
+}
+
+// Name returns the provider name recorded on delivery attempts
+func (s NoopSender) Name() string { return s.Provider }
+
+// Send accepts the notification, returning no provider message ID
+func (NoopSender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,162 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedSender fails with errs in order, then succeeds
+type scriptedSender struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedSender) Name() string { return "inbox" }
+
+func (s *scriptedSender) Send(ctx context.Context, n *models.Notification) (string, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return "", err
+	}
+	return "msg-" + n.ID.String(), nil
+}
+
+// statusStore records delivery attempts and the final status per notification
+type statusStore struct {
+	attemptLog
+	status map[uuid.UUID]models.DeliveryStatus
+}
+
+func (s *statusStore) MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error {
+	s.status[notificationID] = models.StatusDelivered
+	return nil
+}
+
+func (s *statusStore) MarkAsFailed(ctx context.Context, notificationID uuid.UUID) error {
+	s.status[notificationID] = models.StatusFailed
+	return nil
+}
+
+func newTestWorker(sender Sender) (*Worker, *statusStore, *[]time.Duration) {
+	store := &statusStore{status: make(map[uuid.UUID]models.DeliveryStatus)}
+	worker := NewWorker(WorkerConfig{MaxAttempts: 3, Backoff: time.Second}, store,
+		map[models.NotificationChannel]Sender{models.ChannelInApp: sender})
+
+	var waits []time.Duration
+	worker.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return worker, store, &waits
+}
+
+func newInApp() *models.Notification {
+	return &models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelInApp, Message: "Time to practice"}
+}
+
+var errUnavailable = &ProviderError{StatusCode: 503, Err: errors.New("service unavailable")}
+
+func TestWorker_Delivers(t *testing.T) {
+	sender := &scriptedSender{}
+	worker, store, waits := newTestWorker(sender)
+	n := newInApp()
+
+	err := worker.Deliver(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusDelivered, store.status[n.ID])
+	require.Len(t, store.attempts, 1)
+	assert.Equal(t, 1, store.attempts[0].AttemptNo)
+	assert.Equal(t, models.StatusSent, store.attempts[0].Status)
+	assert.Equal(t, "msg-"+n.ID.String(), *store.attempts[0].ProviderMessageID)
+	assert.NotNil(t, store.attempts[0].LatencyMs)
+	assert.Empty(t, *waits)
+}
+
+func TestWorker_RetriesTransientFailure(t *testing.T) {
+	sender := &scriptedSender{errs: []error{errUnavailable, context.DeadlineExceeded}}
+	worker, store, waits := newTestWorker(sender)
+	n := newInApp()
+
+	err := worker.Deliver(context.Background(), n)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, sender.calls)
+	assert.Equal(t, models.StatusDelivered, store.status[n.ID])
+	require.Len(t, store.attempts, 3)
+	assert.Equal(t, "503", *store.attempts[0].ErrorCode)
+	assert.Equal(t, models.StatusFailed, store.attempts[1].Status)
+	assert.Equal(t, 3, store.attempts[2].AttemptNo)
+	assert.Equal(t, models.StatusSent, store.attempts[2].Status)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+}
+
+func TestWorker_FailsAfterMaxAttempts(t *testing.T) {
+	sender := &scriptedSender{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}
+	worker, store, _ := newTestWorker(sender)
+	n := newInApp()
+
+	err := worker.Deliver(context.Background(), n)
+
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Equal(t, 3, sender.calls)
+	assert.Equal(t, models.StatusFailed, store.status[n.ID])
+	assert.Len(t, store.attempts, 3)
+}
+
+func TestWorker_PermanentFailureIsNotRetried(t *testing.T) {
+	rejected := &ProviderError{StatusCode: 400, Err: errors.New("invalid recipient")}
+	sender := &scriptedSender{errs: []error{rejected}}
+	worker, store, waits := newTestWorker(sender)
+	n := newInApp()
+
+	err := worker.Deliver(context.Background(), n)
+
+	assert.ErrorIs(t, err, rejected)
+	assert.Equal(t, 1, sender.calls)
+	assert.Equal(t, models.StatusFailed, store.status[n.ID])
+	require.Len(t, store.attempts, 1)
+	assert.Equal(t, "400", *store.attempts[0].ErrorCode)
+	assert.Equal(t, "provider returned 400: invalid recipient", *store.attempts[0].ErrorMessage)
+	assert.Empty(t, *waits)
+}
+
+func TestWorker_CancelledDuringBackoffLeavesStatus(t *testing.T) {
+	sender := &scriptedSender{errs: []error{errUnavailable}}
+	worker, store, _ := newTestWorker(sender)
+	ctx, cancel := context.WithCancel(context.Background())
+	worker.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+	n := newInApp()
+
+	err := worker.Deliver(ctx, n)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotContains(t, store.status, n.ID)
+	assert.Len(t, store.attempts, 1)
+}
+
+func TestWorker_UnknownChannel(t *testing.T) {
+	worker, store, _ := newTestWorker(&scriptedSender{})
+	n := newInApp()
+	n.Channel = models.ChannelSMS
+
+	err := worker.Deliver(context.Background(), n)
+
+	assert.ErrorIs(t, err, ErrNoSender)
+	assert.False(t, worker.Handles(models.ChannelSMS))
+	assert.Equal(t, models.StatusFailed, store.status[n.ID])
+	assert.Empty(t, store.attempts)
+}
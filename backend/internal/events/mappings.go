@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/models"
+)
+
+// Event types with a mapping in this package
+const (
+	PracticeCompleted = "practice-completed"
+	LessonCompleted   = "lesson-completed"
+	LevelUp           = "level-up"
+	CourseEnrolled    = "course-enrolled"
+)
+
+// HandlePracticeCompleted counts the session towards the practice streak and
+// congratulates the user with the new streak. Payload: {"points": 20}.
+func HandlePracticeCompleted(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+	var payload struct {
+		Points *int `json:"points"`
+	}
+	if err := decodePayload(event, &payload); err != nil {
+		return nil, err
+	}
+
+	streak, err := svc.Streaks.RecordActivity(ctx, event.UserID, services.PracticeStreakType, event.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The notification doubles as the session's activity record; weekly recaps
+	// sum its points
+	metadata := models.JSONMap{"event": "practice_completed", "streak": streak.CurrentStreak}
+	if payload.Points != nil {
+		metadata["points"] = *payload.Points
+	}
+
+	message := "Great job on completing your practice session." + streakMessage(streak.CurrentStreak) + pointsMessage(payload.Points)
+	return notify(ctx, svc, &models.CreateNotificationRequest{
+		UserID:   event.UserID,
+		Type:     models.AchievementUnlock,
+		Channel:  models.ChannelInApp,
+		Priority: models.PriorityMedium,
+		Title:    stringPtr("Practice Completed!"),
+		Message:  message,
+		Metadata: metadata,
+	})
+}
+
+// HandleLessonCompleted counts the lesson as practice and congratulates the
+// user on it. Payload: {"lesson_id": "...", "lesson_title": "...", "points": 20}.
+func HandleLessonCompleted(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+	var payload struct {
+		LessonID    string `json:"lesson_id"`
+		LessonTitle string `json:"lesson_title"`
+		Points      *int   `json:"points"`
+	}
+	if err := decodePayload(event, &payload); err != nil {
+		return nil, err
+	}
+	if payload.LessonTitle == "" {
+		return nil, fmt.Errorf("%w: lesson_title is required", ErrInvalidPayload)
+	}
+
+	streak, err := svc.Streaks.RecordActivity(ctx, event.UserID, services.PracticeStreakType, event.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("You completed %q.", payload.LessonTitle) + streakMessage(streak.CurrentStreak) + pointsMessage(payload.Points)
+	return notify(ctx, svc, &models.CreateNotificationRequest{
+		UserID:   event.UserID,
+		Type:     models.AchievementUnlock,
+		Channel:  models.ChannelInApp,
+		Priority: models.PriorityMedium,
+		Title:    stringPtr("Lesson Completed!"),
+		Message:  message,
+		Metadata: models.JSONMap{"event": "lesson_completed", "lesson_id": payload.LessonID, "streak": streak.CurrentStreak},
+	})
+}
+
+// HandleLevelUp announces the level the user reached. Payload: {"level": 5}.
+func HandleLevelUp(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+	var payload struct {
+		Level int `json:"level"`
+	}
+	if err := decodePayload(event, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Level < 1 {
+		return nil, fmt.Errorf("%w: level must be at least 1", ErrInvalidPayload)
+	}
+
+	return notify(ctx, svc, &models.CreateNotificationRequest{
+		UserID:   event.UserID,
+		Type:     models.AchievementUnlock,
+		Channel:  models.ChannelInApp,
+		Priority: models.PriorityHigh,
+		Title:    stringPtr("Level Up!"),
+		Message:  fmt.Sprintf("You reached level %d. Keep going!", payload.Level),
+		Metadata: models.JSONMap{"event": "level_up", "level": payload.Level},
+	})
+}
+
+// HandleCourseEnrolled welcomes the user to a course. Payload:
+// {"course_id": "...", "course_title": "..."}.
+func HandleCourseEnrolled(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+	var payload struct {
+		CourseID    string `json:"course_id"`
+		CourseTitle string `json:"course_title"`
+	}
+	if err := decodePayload(event, &payload); err != nil {
+		return nil, err
+	}
+	if payload.CourseTitle == "" {
+		return nil, fmt.Errorf("%w: course_title is required", ErrInvalidPayload)
+	}
+
+	return notify(ctx, svc, &models.CreateNotificationRequest{
+		UserID:   event.UserID,
+		Type:     models.EventNotification,
+		Channel:  models.ChannelInApp,
+		Priority: models.PriorityMedium,
+		Title:    stringPtr("Welcome to " + payload.CourseTitle),
+		Message:  fmt.Sprintf("You're enrolled in %s. Your first lesson is ready when you are.", payload.CourseTitle),
+		Metadata: models.JSONMap{"event": "course_enrolled", "course_id": payload.CourseID},
+	})
+}
+
+// notify creates a single notification
+func notify(ctx context.Context, svc Services, req *models.CreateNotificationRequest) ([]*models.Notification, error) {
+	n, err := svc.Notifications.CreateNotification(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return []*models.Notification{n}, nil
+}
+
+func streakMessage(streak int) string {
+	if streak > 1 {
+		return fmt.Sprintf(" You're on a %d day streak, keep it up!", streak)
+	}
+	return " Practice again tomorrow to start a streak!"
+}
+
+func pointsMessage(points *int) string {
+	if points == nil {
+		return ""
+	}
+	return fmt.Sprintf(" You earned %d XP.", *points)
+}
+
+func stringPtr(s string) *string { return &s }
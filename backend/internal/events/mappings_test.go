@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier records the notifications it is asked to create
+type recordingNotifier struct {
+	created []*models.CreateNotificationRequest
+}
+
+func (n *recordingNotifier) CreateNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error) {
+	n.created = append(n.created, req)
+	return &models.Notification{ID: uuid.New(), UserID: req.UserID, Type: req.Type, Message: req.Message}, nil
+}
+
+// fixedStreaks reports the same streak for every activity and records its calls
+type fixedStreaks struct {
+	current    int
+	streakType string
+	at         time.Time
+}
+
+func (s *fixedStreaks) RecordActivity(ctx context.Context, userID uuid.UUID, streakType string, at time.Time) (*models.UserEngagementStreak, error) {
+	s.streakType, s.at = streakType, at
+	return &models.UserEngagementStreak{UserID: userID, StreakType: streakType, CurrentStreak: s.current}, nil
+}
+
+func dispatch(t *testing.T, streak int, eventType, payload string) (*recordingNotifier, *fixedStreaks, []*models.Notification, error) {
+	t.Helper()
+	notifier, streaks := &recordingNotifier{}, &fixedStreaks{current: streak}
+	registry := NewRegistry(Services{Notifications: notifier, Streaks: streaks})
+	registry.Register(PracticeCompleted, HandlePracticeCompleted)
+	registry.Register(LessonCompleted, HandleLessonCompleted)
+	registry.Register(LevelUp, HandleLevelUp)
+	registry.Register(CourseEnrolled, HandleCourseEnrolled)
+
+	notifications, err := registry.Dispatch(context.Background(), Event{
+		Type: eventType, UserID: uuid.New(), Payload: json.RawMessage(payload),
+		ReceivedAt: time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC),
+	})
+	return notifier, streaks, notifications, err
+}
+
+func TestHandlePracticeCompleted(t *testing.T) {
+	tests := []struct {
+		name        string
+		streak      int
+		payload     string
+		wantMessage string
+		wantPoints  interface{}
+	}{
+		{"streak extended", 5, `{"points":20}`, "Great job on completing your practice session. You're on a 5 day streak, keep it up! You earned 20 XP.", 20},
+		{"streak started", 1, `{"points":20}`, "Great job on completing your practice session. Practice again tomorrow to start a streak! You earned 20 XP.", 20},
+		{"no payload", 2, ``, "Great job on completing your practice session. You're on a 2 day streak, keep it up!", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, streaks, notifications, err := dispatch(t, tt.streak, PracticeCompleted, tt.payload)
+
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			require.Len(t, notifier.created, 1)
+			assert.Equal(t, tt.wantMessage, notifier.created[0].Message)
+			assert.Equal(t, models.AchievementUnlock, notifier.created[0].Type)
+			assert.Equal(t, tt.streak, notifier.created[0].Metadata["streak"])
+			assert.Equal(t, tt.wantPoints, notifier.created[0].Metadata["points"])
+			assert.Equal(t, services.PracticeStreakType, streaks.streakType)
+			assert.Equal(t, time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC), streaks.at)
+		})
+	}
+}
+
+func TestHandleLessonCompleted(t *testing.T) {
+	notifier, streaks, _, err := dispatch(t, 3, LessonCompleted, `{"lesson_id":"l-42","lesson_title":"Past tense"}`)
+
+	require.NoError(t, err)
+	require.Len(t, notifier.created, 1)
+	assert.Equal(t, `You completed "Past tense". You're on a 3 day streak, keep it up!`, notifier.created[0].Message)
+	assert.Equal(t, "l-42", notifier.created[0].Metadata["lesson_id"])
+	assert.Equal(t, services.PracticeStreakType, streaks.streakType, "lessons count as practice")
+}
+
+func TestHandleLevelUp(t *testing.T) {
+	notifier, streaks, _, err := dispatch(t, 0, LevelUp, `{"level":7}`)
+
+	require.NoError(t, err)
+	require.Len(t, notifier.created, 1)
+	assert.Equal(t, "You reached level 7. Keep going!", notifier.created[0].Message)
+	assert.Equal(t, models.PriorityHigh, notifier.created[0].Priority)
+	assert.Empty(t, streaks.streakType, "levelling up is not an activity")
+}
+
+func TestHandleCourseEnrolled(t *testing.T) {
+	notifier, _, _, err := dispatch(t, 0, CourseEnrolled, `{"course_id":"es-101","course_title":"Spanish for Beginners"}`)
+
+	require.NoError(t, err)
+	require.Len(t, notifier.created, 1)
+	assert.Equal(t, models.EventNotification, notifier.created[0].Type)
+	assert.Equal(t, "Welcome to Spanish for Beginners", *notifier.created[0].Title)
+	assert.Equal(t, "es-101", notifier.created[0].Metadata["course_id"])
+}
+
+func TestMappings_InvalidPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+	}{
+		{"malformed json", PracticeCompleted, `{"points":`},
+		{"wrong field type", PracticeCompleted, `{"points":"lots"}`},
+		{"lesson without title", LessonCompleted, `{"lesson_id":"l-42"}`},
+		{"level below 1", LevelUp, `{"level":0}`},
+		{"course without title", CourseEnrolled, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, streaks, _, err := dispatch(t, 1, tt.eventType, tt.payload)
+
+			assert.ErrorIs(t, err, ErrInvalidPayload)
+			assert.Empty(t, notifier.created)
+			assert.Empty(t, streaks.streakType, "nothing is recorded for a rejected event")
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownEventType is returned when no handler is registered for an event type
+	ErrUnknownEventType = apperr.New(apperr.ErrInvalidInput, "unknown event type")
+	// ErrInvalidPayload is returned when an event's payload does not fit its type
+	ErrInvalidPayload = apperr.New(apperr.ErrInvalidInput, "invalid event payload")
+)
+
+// Event is a product event reported by a client
+type Event struct {
+	Type    string          `json:"event_type" binding:"required"`
+	UserID  uuid.UUID       `json:"user_id" binding:"required"`
+	Payload json.RawMessage `json:"payload"`
+
+	// ReceivedAt is when the event reached the service; Dispatch sets it when zero
+	ReceivedAt time.Time `json:"-"`
+}
+
+// Notifier creates notifications
+type Notifier interface {
+	CreateNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error)
+}
+
+// StreakRecorder counts activity towards users' engagement streaks
+type StreakRecorder interface {
+	RecordActivity(ctx context.Context, userID uuid.UUID, streakType string, at time.Time) (*models.UserEngagementStreak, error)
+}
+
+// Services are what event handlers act through
+type Services struct {
+	Notifications Notifier
+	Streaks       StreakRecorder
+}
+
+// HandlerFunc handles one event, returning the notifications it created, if any
+type HandlerFunc func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error)
+
+// Registry maps event types to their handlers
+type Registry struct {
+	services Services
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+// NewRegistry creates an empty registry whose handlers act through svc
+func NewRegistry(svc Services) *Registry {
+	return &Registry{
+		services: svc,
+		now:      time.Now,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Register adds the handler for an event type. Registering a type twice is a
+// programming error and panics.
+func (r *Registry) Register(eventType string, handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[eventType]; exists {
+		panic(fmt.Sprintf("events: handler for %q registered twice", eventType))
+	}
+	r.handlers[eventType] = handler
+}
+
+// Types returns the registered event types in alphabetical order
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for eventType := range r.handlers {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Dispatch runs the handler registered for the event's type
+func (r *Registry) Dispatch(ctx context.Context, event Event) ([]*models.Notification, error) {
+	r.mu.RLock()
+	handler, ok := r.handlers[event.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, event.Type)
+	}
+
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = r.now()
+	}
+
+	notifications, err := handler(ctx, r.services, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to handle %s event: %w", event.Type, err)
+	}
+	return notifications, nil
+}
+
+// decodePayload unmarshals the event's payload into v; a missing payload
+// leaves v at its zero value
+func decodePayload(event Event, v interface{}) error {
+	if len(event.Payload) == 0 || string(event.Payload) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(event.Payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return nil
+}
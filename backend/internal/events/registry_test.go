@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_DispatchesToRegisteredHandler(t *testing.T) {
+	registry := NewRegistry(Services{})
+	receivedAt := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return receivedAt }
+
+	var got Event
+	registry.Register("quiz-passed", func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+		got = event
+		return []*models.Notification{{UserID: event.UserID}}, nil
+	})
+	registry.Register("profile-viewed", func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+		t.Fatal("dispatched to the wrong handler")
+		return nil, nil
+	})
+
+	userID := uuid.New()
+	notifications, err := registry.Dispatch(context.Background(), Event{
+		Type: "quiz-passed", UserID: userID, Payload: json.RawMessage(`{"score":9}`),
+	})
+
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, userID, got.UserID)
+	assert.JSONEq(t, `{"score":9}`, string(got.Payload))
+	assert.Equal(t, receivedAt, got.ReceivedAt)
+}
+
+func TestRegistry_HandlerMayCreateNoNotifications(t *testing.T) {
+	registry := NewRegistry(Services{})
+	registry.Register("profile-viewed", func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+		return nil, nil
+	})
+
+	notifications, err := registry.Dispatch(context.Background(), Event{Type: "profile-viewed", UserID: uuid.New()})
+
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestRegistry_UnknownEventType(t *testing.T) {
+	registry := NewRegistry(Services{})
+
+	_, err := registry.Dispatch(context.Background(), Event{Type: "quiz-passed", UserID: uuid.New()})
+
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	assert.ErrorContains(t, err, `"quiz-passed"`)
+}
+
+func TestRegistry_HandlerErrorKeepsItsClass(t *testing.T) {
+	registry := NewRegistry(Services{})
+	registry.Register("level-up", func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+		return nil, errors.New("connection refused")
+	})
+	registry.Register("quiz-passed", func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) {
+		return nil, ErrInvalidPayload
+	})
+
+	_, err := registry.Dispatch(context.Background(), Event{Type: "level-up", UserID: uuid.New()})
+	assert.EqualError(t, err, "failed to handle level-up event: connection refused")
+
+	_, err = registry.Dispatch(context.Background(), Event{Type: "quiz-passed", UserID: uuid.New()})
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+}
+
+func TestRegistry_RegisterTwicePanics(t *testing.T) {
+	registry := NewRegistry(Services{})
+	handler := func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) { return nil, nil }
+	registry.Register(LevelUp, handler)
+
+	assert.Panics(t, func() { registry.Register(LevelUp, handler) })
+}
+
+func TestRegistry_Types(t *testing.T) {
+	registry := NewRegistry(Services{})
+	handler := func(ctx context.Context, svc Services, event Event) ([]*models.Notification, error) { return nil, nil }
+	registry.Register(LevelUp, handler)
+	registry.Register(CourseEnrolled, handler)
+
+	assert.Equal(t, []string{CourseEnrolled, LevelUp}, registry.Types())
+}
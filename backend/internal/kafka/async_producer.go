@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+)
+
+// AsyncProducerFactory builds a fresh async producer
+type AsyncProducerFactory func() (sarama.AsyncProducer, error)
+
+// PendingAsyncProducer holds an async producer that is connected after
+// startup, so the outbox can report "not ready" instead of the service exiting
+// while Kafka is unreachable. Unlike ManagedProducer it is never rebuilt:
+// sarama's async producer reconnects on its own and reports failed messages
+// on its Errors channel.
+type PendingAsyncProducer struct {
+	factory AsyncProducerFactory
+
+	mu       sync.RWMutex
+	producer sarama.AsyncProducer
+	closed   bool
+}
+
+// NewPendingAsyncProducer wraps factory without building a producer yet
+func NewPendingAsyncProducer(factory AsyncProducerFactory) *PendingAsyncProducer {
+	return &PendingAsyncProducer{factory: factory}
+}
+
+// Connect builds the producer, retrying failures with policy's backoff. It
+// returns nil at once when a producer is already in place.
+func (p *PendingAsyncProducer) Connect(ctx context.Context, policy config.ConnectRetryConfig) error {
+	return ConnectWithRetry(ctx, policy, "Kafka async producer", func() error {
+		if p.Ready() {
+			return nil
+		}
+		producer, err := p.factory()
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.closed || p.producer != nil {
+			producer.AsyncClose()
+			return nil
+		}
+		p.producer = producer
+		return nil
+	})
+}
+
+// Ready reports whether the producer has been built
+func (p *PendingAsyncProducer) Ready() bool {
+	return p.Producer() != nil
+}
+
+// Producer returns the async producer, or nil until Connect succeeds
+func (p *PendingAsyncProducer) Producer() sarama.AsyncProducer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.producer
+}
+
+// Close flushes and closes the producer if one was built
+func (p *PendingAsyncProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.producer == nil {
+		return nil
+	}
+	return p.producer.Close()
+}
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingAsyncProducer_ConnectsAfterFailures(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	mock := mocks.NewAsyncProducer(t, config)
+	calls := 0
+	producer := NewPendingAsyncProducer(func() (sarama.AsyncProducer, error) {
+		calls++
+		if calls <= 2 {
+			return nil, sarama.ErrOutOfBrokers
+		}
+		return mock, nil
+	})
+
+	assert.False(t, producer.Ready())
+	assert.Nil(t, producer.Producer())
+
+	err := producer.Connect(context.Background(), testRetryPolicy())
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.True(t, producer.Ready())
+	assert.Same(t, mock, producer.Producer())
+	assert.NoError(t, producer.Close())
+}
+
+func TestPendingAsyncProducer_CloseBeforeConnect(t *testing.T) {
+	producer := NewPendingAsyncProducer(func() (sarama.AsyncProducer, error) {
+		return nil, sarama.ErrOutOfBrokers
+	})
+
+	assert.NoError(t, producer.Close())
+	assert.False(t, producer.Ready())
+}
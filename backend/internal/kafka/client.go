@@ -3,28 +3,99 @@ package kafka
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"kafka-notify/internal/config"
 
 	"github.com/IBM/sarama"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
+// newConsumerGroup creates sarama consumer groups; tests replace it to inspect the arguments
+var newConsumerGroup = sarama.NewConsumerGroup
+
+// newSyncProducer creates sarama producers; tests replace it to inspect the config
+var newSyncProducer = sarama.NewSyncProducer
+
+// newAsyncProducer creates sarama async producers; tests replace it to inspect the config
+var newAsyncProducer = sarama.NewAsyncProducer
+
 // ClientManager manages Kafka clients
 type ClientManager struct {
 	config *config.KafkaConfig
+	// registry collects the sarama metrics of every client created here
+	registry gometrics.Registry
+
+	// healthClient is the persistent client HealthCheck refreshes metadata over
+	healthMu     sync.Mutex
+	healthClient sarama.Client
 }
 
 // NewClientManager creates a new Kafka client manager
 func NewClientManager(cfg *config.KafkaConfig) *ClientManager {
 	return &ClientManager{
-		config: cfg,
+		config:   cfg,
+		registry: gometrics.NewRegistry(),
 	}
 }
 
+// Brokers returns the broker addresses clients connect to
+func (cm *ClientManager) Brokers() []string {
+	return cm.config.Brokers
+}
+
+// NewConfig returns a sarama config with the security protocol's SASL and
+// TLS settings applied, reporting metrics to the manager's registry
+func (cm *ClientManager) NewConfig() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.MetricRegistry = cm.registry
+	if err := applySecurity(config, cm.config); err != nil {
+		return nil, fmt.Errorf("invalid Kafka security configuration: %w", err)
+	}
+	return config, nil
+}
+
 // NewProducer creates a new Kafka producer
 func (cm *ClientManager) NewProducer() (sarama.SyncProducer, error) {
-	config := sarama.NewConfig()
+	config, err := cm.producerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := newSyncProducer(cm.config.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+
+	log.Printf("Kafka producer created successfully, connected to brokers: %v", cm.config.Brokers)
+	return producer, nil
+}
+
+// NewAsyncProducer creates a Kafka async producer with the same settings as
+// NewProducer. Successes and errors are both returned, so callers must drain
+// both channels.
+func (cm *ClientManager) NewAsyncProducer() (sarama.AsyncProducer, error) {
+	config, err := cm.producerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := newAsyncProducer(cm.config.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka async producer: %w", err)
+	}
+
+	log.Printf("Kafka async producer created successfully, connected to brokers: %v", cm.config.Brokers)
+	return producer, nil
+}
+
+// producerConfig returns the sarama config shared by the sync and async producers
+func (cm *ClientManager) producerConfig() (*sarama.Config, error) {
+	config, err := cm.NewConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	// Producer configuration
 	config.Producer.RequiredAcks = sarama.RequiredAcks(cm.config.ProducerConfig.RequiredAcks)
@@ -33,25 +104,65 @@ func (cm *ClientManager) NewProducer() (sarama.SyncProducer, error) {
 	config.Producer.Return.Successes = true
 	config.Producer.Return.Errors = true
 
-	// Compression
+	// Refresh metadata often enough to notice leader moves during broker restarts
+	if cm.config.ProducerConfig.MetadataRefreshFrequency > 0 {
+		config.Metadata.RefreshFrequency = cm.config.ProducerConfig.MetadataRefreshFrequency
+	}
+
+	// Messages are keyed by user ID; hashing the key keeps each user's
+	// notifications on one partition, in order
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+
+	// Compression and batching; Snappy unless configured otherwise
 	config.Producer.Compression = sarama.CompressionSnappy
+	if codec := cm.config.ProducerConfig.Compression; codec != "" {
+		if err := config.Producer.Compression.UnmarshalText([]byte(codec)); err != nil {
+			return nil, fmt.Errorf("invalid Kafka producer compression: %w", err)
+		}
+	}
+	if cm.config.ProducerConfig.MaxMessageBytes > 0 {
+		config.Producer.MaxMessageBytes = cm.config.ProducerConfig.MaxMessageBytes
+	}
+	config.Producer.Flush.Frequency = cm.config.ProducerConfig.FlushFrequency
+	config.Producer.Flush.Messages = cm.config.ProducerConfig.FlushMessages
 
 	// Idempotent producer for exactly-once semantics
 	config.Producer.Idempotent = true
 	config.Net.MaxOpenRequests = 1
 
-	producer, err := sarama.NewSyncProducer(cm.config.Brokers, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
-	}
+	return config, nil
+}
 
-	log.Printf("Kafka producer created successfully, connected to brokers: %v", cm.config.Brokers)
-	return producer, nil
+// NewManagedProducer creates a producer that is rebuilt after the configured max
+// age or streak of connection errors
+func (cm *ClientManager) NewManagedProducer() (*ManagedProducer, error) {
+	return NewManagedProducer(cm.NewProducer,
+		cm.config.ProducerConfig.MaxAge,
+		cm.config.ProducerConfig.RebuildErrorThreshold,
+	)
+}
+
+// NewPendingManagedProducer creates a managed producer that connects when
+// Connect is called, so callers can start before the brokers are reachable
+func (cm *ClientManager) NewPendingManagedProducer() *ManagedProducer {
+	return NewPendingManagedProducer(cm.NewProducer,
+		cm.config.ProducerConfig.MaxAge,
+		cm.config.ProducerConfig.RebuildErrorThreshold,
+	)
+}
+
+// NewPendingAsyncProducer creates an async producer holder that connects when
+// Connect is called
+func (cm *ClientManager) NewPendingAsyncProducer() *PendingAsyncProducer {
+	return NewPendingAsyncProducer(cm.NewAsyncProducer)
 }
 
 // NewConsumerGroup creates a new Kafka consumer group
 func (cm *ClientManager) NewConsumerGroup(groupID string) (sarama.ConsumerGroup, error) {
-	config := sarama.NewConfig()
+	config, err := cm.NewConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	// Consumer group configuration
 	config.Consumer.Group.Session.Timeout = cm.config.ConsumerConfig.SessionTimeout
@@ -68,7 +179,7 @@ func (cm *ClientManager) NewConsumerGroup(groupID string) (sarama.ConsumerGroup,
 	config.Net.ReadTimeout = 30 * time.Second
 	config.Net.WriteTimeout = 30 * time.Second
 
-	consumerGroup, err := sarama.NewConsumerGroup(cm.config.Brokers, groupID, config)
+	consumerGroup, err := newConsumerGroup(cm.config.Brokers, groupID, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
 	}
@@ -106,16 +217,3 @@ func getOffsetReset(offsetReset string) int64 {
 		return sarama.OffsetNewest
 	}
 }
-
-// HealthCheck performs a health check on Kafka connectivity
-func (cm *ClientManager) HealthCheck() error {
-	// Try to create a temporary producer to test connectivity
-	producer, err := cm.NewProducer()
-	if err != nil {
-		return fmt.Errorf("Kafka health check failed: %w", err)
-	}
-	defer cm.CloseProducer(producer)
-
-	log.Println("Kafka health check passed")
-	return nil
-}
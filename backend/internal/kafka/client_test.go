@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConsumerGroup_PassesEveryBroker(t *testing.T) {
+	var gotAddrs []string
+	var gotGroup string
+	var gotConfig *sarama.Config
+	original := newConsumerGroup
+	newConsumerGroup = func(addrs []string, groupID string, cfg *sarama.Config) (sarama.ConsumerGroup, error) {
+		gotAddrs, gotGroup, gotConfig = addrs, groupID, cfg
+		return nil, errors.New("not connecting in tests")
+	}
+	t.Cleanup(func() { newConsumerGroup = original })
+
+	manager := NewClientManager(&config.KafkaConfig{
+		Brokers: []string{"kafka-1:9092", "kafka-2:9092", "kafka-3:9092"},
+		ConsumerConfig: config.ConsumerConfig{
+			AutoOffsetReset:   "earliest",
+			SessionTimeout:    20 * time.Second,
+			HeartbeatInterval: 2 * time.Second,
+		},
+	})
+
+	_, err := manager.NewConsumerGroup("inbox-group")
+
+	require.ErrorContains(t, err, "failed to create Kafka consumer group")
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092", "kafka-3:9092"}, gotAddrs)
+	assert.Equal(t, "inbox-group", gotGroup)
+	assert.Equal(t, sarama.OffsetOldest, gotConfig.Consumer.Offsets.Initial)
+	assert.Equal(t, 20*time.Second, gotConfig.Consumer.Group.Session.Timeout)
+	assert.Equal(t, 2*time.Second, gotConfig.Consumer.Group.Heartbeat.Interval)
+}
+
+func TestNewProducer_HashesKeysToPartitions(t *testing.T) {
+	var gotConfig *sarama.Config
+	original := newSyncProducer
+	newSyncProducer = func(addrs []string, cfg *sarama.Config) (sarama.SyncProducer, error) {
+		gotConfig = cfg
+		return nil, errors.New("not connecting in tests")
+	}
+	t.Cleanup(func() { newSyncProducer = original })
+
+	_, err := NewClientManager(&config.KafkaConfig{Brokers: []string{"kafka-1:9092"}}).NewProducer()
+
+	require.ErrorContains(t, err, "failed to create Kafka producer")
+	partitioner := gotConfig.Producer.Partitioner("notifications")
+	assert.True(t, partitioner.RequiresConsistency())
+	first, err := partitioner.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("user-1")}, 12)
+	require.NoError(t, err)
+	second, err := partitioner.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder("user-1")}, 12)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+}
+
+func TestNewAsyncProducer_SharesProducerSettings(t *testing.T) {
+	var gotConfig *sarama.Config
+	original := newAsyncProducer
+	newAsyncProducer = func(addrs []string, cfg *sarama.Config) (sarama.AsyncProducer, error) {
+		gotConfig = cfg
+		return nil, errors.New("not connecting in tests")
+	}
+	t.Cleanup(func() { newAsyncProducer = original })
+
+	_, err := NewClientManager(&config.KafkaConfig{
+		Brokers:        []string{"kafka-1:9092"},
+		ProducerConfig: config.ProducerConfig{RequiredAcks: -1, RetryMax: 3},
+	}).NewAsyncProducer()
+
+	require.ErrorContains(t, err, "failed to create Kafka async producer")
+	assert.True(t, gotConfig.Producer.Return.Successes)
+	assert.True(t, gotConfig.Producer.Return.Errors)
+	assert.True(t, gotConfig.Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, gotConfig.Producer.RequiredAcks)
+	assert.True(t, gotConfig.Producer.Partitioner("notifications").RequiresConsistency())
+}
+
+func TestNewProducer_MapsCompressionAndBatching(t *testing.T) {
+	var gotConfig *sarama.Config
+	original := newSyncProducer
+	newSyncProducer = func(addrs []string, cfg *sarama.Config) (sarama.SyncProducer, error) {
+		gotConfig = cfg
+		return nil, errors.New("not connecting in tests")
+	}
+	t.Cleanup(func() { newSyncProducer = original })
+
+	_, err := NewClientManager(&config.KafkaConfig{
+		Brokers: []string{"kafka-1:9092"},
+		ProducerConfig: config.ProducerConfig{
+			RequiredAcks:    -1,
+			RetryMax:        3,
+			Timeout:         10 * time.Second,
+			Compression:     "zstd",
+			MaxMessageBytes: 4 << 20,
+			FlushFrequency:  5 * time.Millisecond,
+			FlushMessages:   50,
+		},
+	}).NewProducer()
+
+	require.ErrorContains(t, err, "failed to create Kafka producer")
+	assert.Equal(t, sarama.CompressionZSTD, gotConfig.Producer.Compression)
+	assert.Equal(t, 4<<20, gotConfig.Producer.MaxMessageBytes)
+	assert.Equal(t, 5*time.Millisecond, gotConfig.Producer.Flush.Frequency)
+	assert.Equal(t, 50, gotConfig.Producer.Flush.Messages)
+	assert.NoError(t, gotConfig.Validate())
+}
+
+func TestNewProducer_DefaultsToSnappy(t *testing.T) {
+	var gotConfig *sarama.Config
+	original := newSyncProducer
+	newSyncProducer = func(addrs []string, cfg *sarama.Config) (sarama.SyncProducer, error) {
+		gotConfig = cfg
+		return nil, errors.New("not connecting in tests")
+	}
+	t.Cleanup(func() { newSyncProducer = original })
+
+	_, _ = NewClientManager(&config.KafkaConfig{Brokers: []string{"kafka-1:9092"}}).NewProducer()
+
+	assert.Equal(t, sarama.CompressionSnappy, gotConfig.Producer.Compression)
+	assert.Equal(t, sarama.NewConfig().Producer.MaxMessageBytes, gotConfig.Producer.MaxMessageBytes)
+}
+
+func TestNewProducer_RejectsUnknownCompression(t *testing.T) {
+	_, err := NewClientManager(&config.KafkaConfig{
+		Brokers:        []string{"kafka-1:9092"},
+		ProducerConfig: config.ProducerConfig{Compression: "brotli"},
+	}).NewProducer()
+
+	assert.ErrorContains(t, err, "invalid Kafka producer compression")
+}
@@ -0,0 +1,379 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+)
+
+// DLQOverflowPolicy decides what happens when the DLQ buffer and spill file are full
+type DLQOverflowPolicy string
+
+const (
+	// DLQPolicyBlock pauses the caller until buffer space frees up
+	DLQPolicyBlock DLQOverflowPolicy = "block"
+	// DLQPolicyDrop discards the message and records it in the dropped counter
+	DLQPolicyDrop DLQOverflowPolicy = "drop"
+)
+
+var (
+	// ErrDLQUnavailable is returned when no DLQ producer is connected
+	ErrDLQUnavailable = errors.New("DLQ producer unavailable")
+	// ErrDLQDropped is returned when a message was discarded under the drop policy
+	ErrDLQDropped = errors.New("DLQ buffer full, message dropped")
+)
+
+// DLQMessage is a message routed to the dead letter topic
+type DLQMessage struct {
+	Key         []byte    `json:"key"`
+	Value       []byte    `json:"value"`
+	Reason      string    `json:"reason"`
+	SourceTopic string    `json:"source_topic"`
+	Partition   int32     `json:"partition"`
+	Offset      int64     `json:"offset"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// DLQStats is a snapshot of the DLQ buffer state
+type DLQStats struct {
+	Topic           string            `json:"topic"`
+	Policy          DLQOverflowPolicy `json:"policy"`
+	Connected       bool              `json:"connected"`
+	Buffered        int               `json:"buffered"`
+	BufferCapacity  int               `json:"buffer_capacity"`
+	Spilled         int               `json:"spilled"`
+	SpillBytes      int64             `json:"spill_bytes"`
+	Blocked         int               `json:"blocked"`
+	Published       int64             `json:"published"`
+	PublishFailures int64             `json:"publish_failures"`
+	Dropped         int64             `json:"dropped"`
+	LastError       string            `json:"last_error,omitempty"`
+}
+
+// DLQPublisher publishes to the dead letter topic, buffering in memory and on
+// disk while the topic cannot be produced to
+type DLQPublisher struct {
+	cfg    config.DLQConfig
+	policy DLQOverflowPolicy
+
+	mu         sync.Mutex
+	producer   sarama.SyncProducer
+	buffer     []DLQMessage
+	spilled    int
+	spillBytes int64
+	space      chan struct{}
+	stats      DLQStats
+
+	flushMu sync.Mutex
+}
+
+// NewDLQPublisher creates a DLQ publisher. The producer may be nil until
+// SetProducer is called; messages are buffered in the meantime. Messages left
+// in the spill file by a previous run are picked up.
+func NewDLQPublisher(cfg config.DLQConfig, producer sarama.SyncProducer) (*DLQPublisher, error) {
+	policy := DLQOverflowPolicy(cfg.OverflowPolicy)
+	if policy != DLQPolicyBlock && policy != DLQPolicyDrop {
+		return nil, fmt.Errorf("invalid DLQ overflow policy %q", cfg.OverflowPolicy)
+	}
+	if cfg.BufferSize <= 0 {
+		return nil, fmt.Errorf("DLQ buffer size must be positive, got %d", cfg.BufferSize)
+	}
+
+	p := &DLQPublisher{
+		cfg:      cfg,
+		policy:   policy,
+		producer: producer,
+		space:    make(chan struct{}),
+	}
+
+	if cfg.SpillPath != "" {
+		messages, size, err := readSpill(cfg.SpillPath)
+		if err != nil {
+			return nil, err
+		}
+		p.spilled = len(messages)
+		p.spillBytes = size
+		if p.spilled > 0 {
+			log.Printf("Recovered %d DLQ messages from spill file %s", p.spilled, cfg.SpillPath)
+		}
+	}
+
+	return p, nil
+}
+
+// SetProducer attaches the producer used to publish to the DLQ topic
+func (p *DLQPublisher) SetProducer(producer sarama.SyncProducer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.producer = producer
+}
+
+// Publish sends a message to the DLQ topic, buffering it if the topic is
+// unavailable. Under the block policy it waits for buffer space until ctx is
+// done; under the drop policy it returns ErrDLQDropped when full.
+func (p *DLQPublisher) Publish(ctx context.Context, msg DLQMessage) error {
+	p.mu.Lock()
+	producer := p.producer
+	pending := len(p.buffer) > 0 || p.spilled > 0
+	p.mu.Unlock()
+
+	// Only bypass the buffer when nothing is queued, so retries keep their order
+	if !pending && producer != nil {
+		if err := p.send(producer, msg); err == nil {
+			return nil
+		}
+	}
+
+	for {
+		p.mu.Lock()
+		err := p.enqueueLocked(msg)
+		if err == nil {
+			p.mu.Unlock()
+			return nil
+		}
+		if !errors.Is(err, ErrDLQDropped) {
+			log.Printf("DLQ spill failed, treating buffer as full: %v", err)
+		}
+
+		if p.policy == DLQPolicyDrop {
+			p.stats.Dropped++
+			dropped := p.stats.Dropped
+			p.mu.Unlock()
+			log.Printf("ALERT: DLQ buffer full, dropped message from %s[%d]@%d (total dropped: %d)",
+				msg.SourceTopic, msg.Partition, msg.Offset, dropped)
+			return ErrDLQDropped
+		}
+
+		space := p.space
+		p.stats.Blocked++
+		p.mu.Unlock()
+
+		select {
+		case <-space:
+			p.mu.Lock()
+			p.stats.Blocked--
+			p.mu.Unlock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.stats.Blocked--
+			p.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// Run retries buffered messages every RetryInterval until ctx is done
+func (p *DLQPublisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Flush(); err != nil {
+				log.Printf("DLQ flush incomplete: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush publishes buffered messages in order, refilling the memory buffer from
+// the spill file. It stops at the first publish failure.
+func (p *DLQPublisher) Flush() error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	for {
+		p.mu.Lock()
+		if len(p.buffer) == 0 && p.spilled > 0 {
+			if err := p.loadSpillLocked(); err != nil {
+				p.mu.Unlock()
+				return err
+			}
+		}
+		if len(p.buffer) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		producer := p.producer
+		msg := p.buffer[0]
+		p.mu.Unlock()
+
+		if producer == nil {
+			return ErrDLQUnavailable
+		}
+		if err := p.send(producer, msg); err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		p.buffer = p.buffer[1:]
+		p.signalSpaceLocked()
+		p.mu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of the buffer state and counters
+func (p *DLQPublisher) Stats() DLQStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Topic = p.cfg.Topic
+	stats.Policy = p.policy
+	stats.Connected = p.producer != nil
+	stats.Buffered = len(p.buffer)
+	stats.BufferCapacity = p.cfg.BufferSize
+	stats.Spilled = p.spilled
+	stats.SpillBytes = p.spillBytes
+	return stats
+}
+
+// Healthy reports whether the DLQ has nothing waiting to be published
+func (p *DLQPublisher) Healthy() bool {
+	stats := p.Stats()
+	return stats.Buffered == 0 && stats.Spilled == 0
+}
+
+// send publishes a single message and records the outcome
+func (p *DLQPublisher) send(producer sarama.SyncProducer, msg DLQMessage) error {
+	_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+		Topic: p.cfg.Topic,
+		Key:   sarama.ByteEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("dlq_reason"), Value: []byte(msg.Reason)},
+			{Key: []byte("dlq_source_topic"), Value: []byte(msg.SourceTopic)},
+			{Key: []byte("dlq_source_partition"), Value: []byte(fmt.Sprint(msg.Partition))},
+			{Key: []byte("dlq_source_offset"), Value: []byte(fmt.Sprint(msg.Offset))},
+		},
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.stats.PublishFailures++
+		p.stats.LastError = err.Error()
+		return fmt.Errorf("failed to publish to DLQ topic %s: %w", p.cfg.Topic, err)
+	}
+	p.stats.Published++
+	return nil
+}
+
+// enqueueLocked stores msg in memory, or in the spill file once memory is full
+func (p *DLQPublisher) enqueueLocked(msg DLQMessage) error {
+	// Spilled messages are older than anything new, so memory only takes new
+	// messages while the spill file is empty
+	if len(p.buffer) < p.cfg.BufferSize && p.spilled == 0 {
+		p.buffer = append(p.buffer, msg)
+		return nil
+	}
+
+	if p.cfg.SpillPath == "" {
+		return ErrDLQDropped
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode DLQ message: %w", err)
+	}
+	line = append(line, '\n')
+	if p.spillBytes+int64(len(line)) > p.cfg.SpillMaxBytes {
+		return ErrDLQDropped
+	}
+
+	f, err := os.OpenFile(p.cfg.SpillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open DLQ spill file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to write DLQ spill file: %w", err)
+	}
+
+	p.spilled++
+	p.spillBytes += int64(len(line))
+	return nil
+}
+
+// loadSpillLocked moves up to BufferSize messages from the spill file into memory
+func (p *DLQPublisher) loadSpillLocked() error {
+	messages, _, err := readSpill(p.cfg.SpillPath)
+	if err != nil {
+		return err
+	}
+
+	n := p.cfg.BufferSize
+	if n > len(messages) {
+		n = len(messages)
+	}
+
+	var remaining bytes.Buffer
+	for _, msg := range messages[n:] {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to encode DLQ message: %w", err)
+		}
+		remaining.Write(line)
+		remaining.WriteByte('\n')
+	}
+	if err := os.WriteFile(p.cfg.SpillPath, remaining.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to rewrite DLQ spill file: %w", err)
+	}
+
+	p.buffer = append(p.buffer, messages[:n]...)
+	p.spilled = len(messages) - n
+	p.spillBytes = int64(remaining.Len())
+	p.signalSpaceLocked()
+	return nil
+}
+
+// signalSpaceLocked wakes publishers blocked on a full buffer
+func (p *DLQPublisher) signalSpaceLocked() {
+	close(p.space)
+	p.space = make(chan struct{})
+}
+
+// readSpill reads all messages from a spill file, which may not exist yet
+func readSpill(path string) ([]DLQMessage, int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read DLQ spill file: %w", err)
+	}
+
+	var messages []DLQMessage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var msg DLQMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode DLQ spill file: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to scan DLQ spill file: %w", err)
+	}
+
+	return messages, int64(len(data)), nil
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTopicUnavailable = errors.New("topic authorization failed")
+
+func dlqConfig(policy DLQOverflowPolicy, bufferSize int, spillPath string) config.DLQConfig {
+	return config.DLQConfig{
+		Topic:          "notifications-dlq",
+		BufferSize:     bufferSize,
+		SpillPath:      spillPath,
+		SpillMaxBytes:  1024 * 1024,
+		RetryInterval:  time.Second,
+		OverflowPolicy: string(policy),
+	}
+}
+
+func dlqMessage(offset int64) DLQMessage {
+	return DLQMessage{
+		Key:         []byte("user-1"),
+		Value:       []byte("not json"),
+		Reason:      "unmarshal failed",
+		SourceTopic: "notifications",
+		Offset:      offset,
+	}
+}
+
+// failingProducer returns a mock producer that rejects the next n sends
+func failingProducer(t *testing.T, n int) *mocks.SyncProducer {
+	producer := mocks.NewSyncProducer(t, nil)
+	for i := 0; i < n; i++ {
+		producer.ExpectSendMessageAndFail(errTopicUnavailable)
+	}
+	return producer
+}
+
+// offsetChecker asserts that a DLQ message carries the given source offset header
+func offsetChecker(offset int64) mocks.MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		for _, h := range msg.Headers {
+			if string(h.Key) == "dlq_source_offset" {
+				if string(h.Value) != strconv.FormatInt(offset, 10) {
+					return fmt.Errorf("expected offset %d, got %s", offset, h.Value)
+				}
+				return nil
+			}
+		}
+		return errors.New("missing dlq_source_offset header")
+	}
+}
+
+func TestDLQPublisher_PublishesDirectlyWhenAvailable(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+
+	p, err := NewDLQPublisher(dlqConfig(DLQPolicyBlock, 2, ""), producer)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Publish(context.Background(), dlqMessage(1)))
+
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.Published)
+	assert.Equal(t, 0, stats.Buffered)
+	assert.True(t, p.Healthy())
+	require.NoError(t, producer.Close())
+}
+
+func TestDLQPublisher_DropPolicyDiscardsWhenFull(t *testing.T) {
+	// Only the first publish tries the producer directly; later ones queue behind it
+	producer := failingProducer(t, 1)
+
+	p, err := NewDLQPublisher(dlqConfig(DLQPolicyDrop, 2, ""), producer)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, dlqMessage(1)))
+	require.NoError(t, p.Publish(ctx, dlqMessage(2)))
+	assert.ErrorIs(t, p.Publish(ctx, dlqMessage(3)), ErrDLQDropped)
+
+	stats := p.Stats()
+	assert.Equal(t, 2, stats.Buffered)
+	assert.Equal(t, int64(1), stats.Dropped)
+	assert.Equal(t, int64(1), stats.PublishFailures)
+	assert.Contains(t, stats.LastError, errTopicUnavailable.Error())
+	assert.False(t, p.Healthy())
+	require.NoError(t, producer.Close())
+}
+
+func TestDLQPublisher_BlockPolicyWaitsForSpace(t *testing.T) {
+	producer := failingProducer(t, 2)
+
+	p, err := NewDLQPublisher(dlqConfig(DLQPolicyBlock, 1, ""), producer)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Publish(context.Background(), dlqMessage(1)))
+
+	// Buffer is full and the topic is still down: the caller stays blocked
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Publish(ctx, dlqMessage(2)), context.DeadlineExceeded)
+	assert.Error(t, p.Flush())
+	assert.Equal(t, int64(0), p.Stats().Dropped)
+	require.NoError(t, producer.Close())
+
+	// Once the topic recovers, a flush frees space and unblocks the caller
+	recovered := mocks.NewSyncProducer(t, nil)
+	recovered.ExpectSendMessageAndSucceed()
+	recovered.ExpectSendMessageAndSucceed()
+	p.SetProducer(recovered)
+
+	done := make(chan error, 1)
+	go func() { done <- p.Publish(context.Background(), dlqMessage(2)) }()
+
+	require.Eventually(t, func() bool { return p.Stats().Blocked == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, p.Flush())
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("publish stayed blocked after flush")
+	}
+
+	require.NoError(t, p.Flush())
+	stats := p.Stats()
+	assert.Equal(t, int64(2), stats.Published)
+	assert.Equal(t, 0, stats.Buffered)
+	require.NoError(t, recovered.Close())
+}
+
+func TestDLQPublisher_SpillsToDiskAndRecovers(t *testing.T) {
+	spillPath := filepath.Join(t.TempDir(), "dlq.spill")
+	cfg := dlqConfig(DLQPolicyDrop, 1, spillPath)
+
+	p, err := NewDLQPublisher(cfg, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for offset := int64(1); offset <= 3; offset++ {
+		require.NoError(t, p.Publish(ctx, dlqMessage(offset)))
+	}
+
+	stats := p.Stats()
+	assert.Equal(t, 1, stats.Buffered)
+	assert.Equal(t, 2, stats.Spilled)
+	assert.Positive(t, stats.SpillBytes)
+	assert.ErrorIs(t, p.Flush(), ErrDLQUnavailable)
+
+	// A restarted consumer picks up the spilled messages and publishes them in order
+	recovered := mocks.NewSyncProducer(t, nil)
+	recovered.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(offsetChecker(2))
+	recovered.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(offsetChecker(3))
+
+	restarted, err := NewDLQPublisher(cfg, recovered)
+	require.NoError(t, err)
+	assert.Equal(t, 2, restarted.Stats().Spilled)
+
+	require.NoError(t, restarted.Flush())
+	stats = restarted.Stats()
+	assert.Equal(t, 0, stats.Spilled)
+	assert.Equal(t, int64(0), stats.SpillBytes)
+	assert.Equal(t, int64(2), stats.Published)
+	assert.True(t, restarted.Healthy())
+	require.NoError(t, recovered.Close())
+}
+
+func TestNewDLQPublisher_RejectsUnknownPolicy(t *testing.T) {
+	_, err := NewDLQPublisher(dlqConfig("retry-forever", 1, ""), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"fmt"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+)
+
+// Header keys set on every notification message so consumers can route or
+// filter without unmarshalling the payload
+const (
+	HeaderNotificationType = "notification_type"
+	HeaderChannel          = "channel"
+	HeaderPriority         = "priority"
+	HeaderNotificationID   = "notification_id"
+	HeaderRequestID        = "request_id"
+)
+
+// payloadHeaders maps outbox payload fields to the header they are copied into
+var payloadHeaders = []struct {
+	header string
+	field  string
+}{
+	{HeaderNotificationType, "type"},
+	{HeaderChannel, "channel"},
+	{HeaderPriority, "priority"},
+	{HeaderNotificationID, "id"},
+	{HeaderRequestID, "request_id"},
+}
+
+// NotificationHeaders builds the message headers for an outbox payload, reading
+// the fields from the event envelope or, for rows queued before it, the legacy
+// flat payload. Missing fields, such as a request ID on scheduled
+// notifications, are left out rather than sent empty.
+func NotificationHeaders(payload models.JSONMap) []sarama.RecordHeader {
+	fields := map[string]interface{}(payload)
+	if notification, ok := payload["notification"].(map[string]interface{}); ok {
+		fields = make(map[string]interface{}, len(notification)+1)
+		for k, v := range notification {
+			fields[k] = v
+		}
+		fields["request_id"] = payload["request_id"]
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(payloadHeaders))
+	for _, h := range payloadHeaders {
+		value, ok := fields[h.field]
+		if !ok || value == nil {
+			continue
+		}
+		s := fmt.Sprint(value)
+		if s == "" {
+			continue
+		}
+		headers = append(headers, sarama.RecordHeader{Key: []byte(h.header), Value: []byte(s)})
+	}
+	return headers
+}
+
+// HeaderValue returns the value of the named header on a consumed message
+func HeaderValue(headers []*sarama.RecordHeader, key string) (string, bool) {
+	for _, h := range headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"testing"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func consumed(headers []sarama.RecordHeader) []*sarama.RecordHeader {
+	out := make([]*sarama.RecordHeader, len(headers))
+	for i := range headers {
+		out[i] = &headers[i]
+	}
+	return out
+}
+
+func TestNotificationHeaders_RoundTrip(t *testing.T) {
+	payload := models.JSONMap{
+		"id":         "6f1c2a52-8a3e-4a4d-9a57-0b6f5b1f2e11",
+		"type":       models.NotificationType("order_shipped"),
+		"channel":    models.ChannelInApp,
+		"priority":   models.PriorityLow,
+		"request_id": "req-42",
+		"message":    "not copied",
+	}
+
+	headers := consumed(NotificationHeaders(payload))
+
+	assert.Len(t, headers, 5)
+	for key, want := range map[string]string{
+		HeaderNotificationID:   "6f1c2a52-8a3e-4a4d-9a57-0b6f5b1f2e11",
+		HeaderNotificationType: "order_shipped",
+		HeaderChannel:          "in_app",
+		HeaderPriority:         "low",
+		HeaderRequestID:        "req-42",
+	} {
+		got, ok := HeaderValue(headers, key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got, key)
+	}
+}
+
+func TestNotificationHeaders_SkipsMissingFields(t *testing.T) {
+	headers := consumed(NotificationHeaders(models.JSONMap{"channel": "email", "request_id": nil, "priority": ""}))
+
+	assert.Len(t, headers, 1)
+	_, ok := HeaderValue(headers, HeaderRequestID)
+	assert.False(t, ok)
+}
+
+func TestNotificationHeaders_ReadsEventEnvelope(t *testing.T) {
+	notification := &models.Notification{ID: models.NewNotificationID(), Type: models.StreakReminder, Channel: models.ChannelEmail, Priority: models.PriorityHigh}
+	entry, err := models.BuildOutboxEntry(notification, "notifications")
+	require.NoError(t, err)
+	entry.Payload["request_id"] = "req-7"
+
+	headers := consumed(NotificationHeaders(entry.Payload))
+
+	for key, want := range map[string]string{
+		HeaderNotificationID:   notification.ID.String(),
+		HeaderNotificationType: "streak_reminder",
+		HeaderChannel:          "email",
+		HeaderPriority:         "high",
+		HeaderRequestID:        "req-7",
+	} {
+		got, ok := HeaderValue(headers, key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got, key)
+	}
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// newClient creates sarama clients; tests replace it to inspect the config
+var newClient = sarama.NewClient
+
+// healthMetadataTimeout bounds one health check's metadata refresh
+const healthMetadataTimeout = 5 * time.Second
+
+// HealthCheck checks Kafka connectivity by refreshing cluster metadata over a
+// persistent client, so a readiness probe costs one metadata request instead
+// of bootstrapping and closing a producer. It returns once ctx ends even if
+// the brokers have not answered yet.
+func (cm *ClientManager) HealthCheck(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		client, err := cm.getHealthClient()
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- client.RefreshMetadata()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("Kafka health check failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Kafka health check failed: %w", ctx.Err())
+	}
+}
+
+// getHealthClient returns the health check client, creating it on first use.
+// Only broker metadata is fetched, never the full topic list.
+func (cm *ClientManager) getHealthClient() (sarama.Client, error) {
+	cm.healthMu.Lock()
+	defer cm.healthMu.Unlock()
+
+	if cm.healthClient != nil && !cm.healthClient.Closed() {
+		return cm.healthClient, nil
+	}
+
+	config, err := cm.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Metadata.Full = false
+	config.Metadata.Retry.Max = 0
+	config.Metadata.Timeout = healthMetadataTimeout
+
+	client, err := newClient(cm.config.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	cm.healthClient = client
+	return client, nil
+}
+
+// Close closes the persistent health check client
+func (cm *ClientManager) Close() error {
+	cm.healthMu.Lock()
+	defer cm.healthMu.Unlock()
+
+	if cm.healthClient == nil {
+		return nil
+	}
+	err := cm.healthClient.Close()
+	cm.healthClient = nil
+	return err
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHealthyBroker(t *testing.T) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+	})
+	return broker
+}
+
+func TestHealthCheck_Healthy(t *testing.T) {
+	broker := newHealthyBroker(t)
+	created := 0
+	original := newClient
+	newClient = func(addrs []string, cfg *sarama.Config) (sarama.Client, error) {
+		created++
+		return original(addrs, cfg)
+	}
+	t.Cleanup(func() { newClient = original })
+
+	clients := NewClientManager(&config.KafkaConfig{Brokers: []string{broker.Addr()}})
+	defer clients.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, clients.HealthCheck(context.Background()))
+	}
+	assert.Equal(t, 1, created, "the client is kept between checks")
+}
+
+func TestHealthCheck_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	clients := NewClientManager(&config.KafkaConfig{Brokers: []string{addr}})
+	defer clients.Close()
+
+	err = clients.HealthCheck(context.Background())
+
+	assert.ErrorContains(t, err, "Kafka health check failed")
+}
+
+func TestHealthCheck_HonorsContextDeadline(t *testing.T) {
+	broker := newHealthyBroker(t)
+	broker.SetLatency(2 * time.Second)
+	clients := NewClientManager(&config.KafkaConfig{Brokers: []string{broker.Addr()}})
+	defer clients.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := clients.HealthCheck(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,366 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/metrics"
+	"kafka-notify/pkg/apperr"
+
+	"github.com/IBM/sarama"
+)
+
+// ErrProducerNotReady is returned by sends on a ManagedProducer that has not
+// connected yet
+var ErrProducerNotReady = apperr.New(apperr.ErrUnavailable, "Kafka producer not ready")
+
+// ProducerFactory builds a fresh sync producer
+type ProducerFactory func() (sarama.SyncProducer, error)
+
+// Reasons a managed producer is rebuilt
+const (
+	RebuildReasonMaxAge           = "max_age"
+	RebuildReasonConnectionErrors = "connection_errors"
+)
+
+// ManagedProducer is a sarama.SyncProducer that transparently replaces its
+// underlying producer once it exceeds a maximum age or hits a streak of
+// connection errors, so stale broker connections left behind by a rolling
+// restart don't keep failing publishes. Sends hold a read lock on the current
+// producer; a swap waits for in-flight sends before closing the old one.
+type ManagedProducer struct {
+	factory        ProducerFactory
+	maxAge         time.Duration
+	errorThreshold int
+	now            func() time.Time
+
+	mu         sync.RWMutex
+	producer   sarama.SyncProducer
+	createdAt  time.Time
+	generation uint64
+	errStreak  int
+	closed     bool
+}
+
+// NewManagedProducer creates the initial producer and wraps it. A zero maxAge or
+// errorThreshold disables the corresponding rebuild trigger.
+func NewManagedProducer(factory ProducerFactory, maxAge time.Duration, errorThreshold int) (*ManagedProducer, error) {
+	producer, err := factory()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManagedProducer{
+		factory:        factory,
+		maxAge:         maxAge,
+		errorThreshold: errorThreshold,
+		now:            time.Now,
+		producer:       producer,
+		createdAt:      time.Now(),
+	}, nil
+}
+
+// NewPendingManagedProducer wraps factory without building a producer yet;
+// sends fail with ErrProducerNotReady until Connect succeeds
+func NewPendingManagedProducer(factory ProducerFactory, maxAge time.Duration, errorThreshold int) *ManagedProducer {
+	return &ManagedProducer{
+		factory:        factory,
+		maxAge:         maxAge,
+		errorThreshold: errorThreshold,
+		now:            time.Now,
+	}
+}
+
+// Connect builds the initial producer, retrying failures with policy's
+// backoff. It returns nil at once when a producer is already in place.
+func (p *ManagedProducer) Connect(ctx context.Context, policy config.ConnectRetryConfig) error {
+	return ConnectWithRetry(ctx, policy, "Kafka producer", func() error {
+		if p.Ready() {
+			return nil
+		}
+		producer, err := p.factory()
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.closed || p.producer != nil {
+			producer.Close()
+			return nil
+		}
+		p.producer = producer
+		p.createdAt = p.now()
+		return nil
+	})
+}
+
+// Ready reports whether a producer has been built
+func (p *ManagedProducer) Ready() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.producer != nil
+}
+
+// SendMessage produces a message, retrying once on a rebuilt producer when a
+// connection error triggered a rebuild
+func (p *ManagedProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.rebuildIfExpired()
+
+	producer, generation, release := p.acquire()
+	if producer == nil {
+		release()
+		return 0, 0, ErrProducerNotReady
+	}
+	partition, offset, err := producer.SendMessage(msg)
+	release()
+
+	if !p.recordResult(generation, isConnectionError(err)) {
+		return partition, offset, err
+	}
+
+	producer, _, release = p.acquire()
+	defer release()
+	return producer.SendMessage(msg)
+}
+
+// SendMessages produces a batch. When a connection error triggered a rebuild,
+// only the failed messages are retried on the new producer.
+func (p *ManagedProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.rebuildIfExpired()
+
+	producer, generation, release := p.acquire()
+	if producer == nil {
+		release()
+		return ErrProducerNotReady
+	}
+	err := producer.SendMessages(msgs)
+	release()
+
+	if !p.recordResult(generation, isConnectionError(err)) {
+		return err
+	}
+
+	retry := msgs
+	var producerErrs sarama.ProducerErrors
+	if errors.As(err, &producerErrs) {
+		retry = make([]*sarama.ProducerMessage, 0, len(producerErrs))
+		for _, pe := range producerErrs {
+			retry = append(retry, pe.Msg)
+		}
+	}
+
+	producer, _, release = p.acquire()
+	defer release()
+	return producer.SendMessages(retry)
+}
+
+// Generation returns how many times the underlying producer has been replaced
+func (p *ManagedProducer) Generation() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.generation
+}
+
+// acquire returns the current producer under a read lock; release must be called after use
+func (p *ManagedProducer) acquire() (sarama.SyncProducer, uint64, func()) {
+	p.mu.RLock()
+	return p.producer, p.generation, p.mu.RUnlock
+}
+
+// recordResult tracks the connection error streak for the producer generation
+// that handled a send and reports whether the send should be retried on a newer one
+func (p *ManagedProducer) recordResult(generation uint64, connErr bool) bool {
+	p.mu.Lock()
+	if p.generation != generation {
+		// Another send already replaced the producer that failed us
+		p.mu.Unlock()
+		return connErr
+	}
+	if !connErr {
+		p.errStreak = 0
+		p.mu.Unlock()
+		return false
+	}
+	p.errStreak++
+	streak := p.errStreak
+	p.mu.Unlock()
+
+	if p.errorThreshold <= 0 || streak < p.errorThreshold {
+		return false
+	}
+	return p.rebuild(generation, RebuildReasonConnectionErrors)
+}
+
+// rebuildIfExpired replaces the producer once it is older than maxAge
+func (p *ManagedProducer) rebuildIfExpired() {
+	if p.maxAge <= 0 {
+		return
+	}
+
+	p.mu.RLock()
+	expired := p.producer != nil && p.now().Sub(p.createdAt) >= p.maxAge
+	generation := p.generation
+	p.mu.RUnlock()
+
+	if expired {
+		p.rebuild(generation, RebuildReasonMaxAge)
+	}
+}
+
+// rebuild swaps in a new producer if the current one is still the given
+// generation, and reports whether the producer was replaced
+func (p *ManagedProducer) rebuild(generation uint64, reason string) bool {
+	// Build outside the lock so sends keep flowing on the old producer meanwhile
+	fresh, err := p.factory()
+	if err != nil {
+		metrics.KafkaProducerRebuildFailures.WithLabelValues(reason).Inc()
+		log.Printf("Failed to rebuild Kafka producer (%s): %v", reason, err)
+		return false
+	}
+
+	p.mu.Lock()
+	if p.closed || p.generation != generation {
+		p.mu.Unlock()
+		fresh.Close()
+		return !p.closed
+	}
+	old := p.producer
+	p.producer = fresh
+	p.createdAt = p.now()
+	p.generation++
+	p.errStreak = 0
+	p.mu.Unlock()
+
+	metrics.KafkaProducerRebuilds.WithLabelValues(reason).Inc()
+	log.Printf("Rebuilt Kafka producer (%s), generation %d", reason, generation+1)
+
+	// The write lock waited for in-flight sends, so nothing uses the old producer now
+	if err := old.Close(); err != nil {
+		log.Printf("Error closing replaced Kafka producer: %v", err)
+	}
+	return true
+}
+
+// isConnectionError reports whether a send failed because of a broken or
+// missing broker connection rather than a problem with the message itself
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var producerErrs sarama.ProducerErrors
+	if errors.As(err, &producerErrs) {
+		for _, pe := range producerErrs {
+			if isConnectionError(pe.Err) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netErr *net.OpError
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, sarama.ErrOutOfBrokers) ||
+		errors.Is(err, sarama.ErrNotConnected) ||
+		errors.Is(err, sarama.ErrClosedClient) ||
+		errors.Is(err, sarama.ErrBrokerNotAvailable) ||
+		errors.Is(err, sarama.ErrNotLeaderForPartition)
+}
+
+// Close closes the current producer
+func (p *ManagedProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.producer == nil {
+		return nil
+	}
+	if err := p.producer.Close(); err != nil {
+		return fmt.Errorf("failed to close Kafka producer: %w", err)
+	}
+	return nil
+}
+
+// TxnStatus delegates to the current producer
+func (p *ManagedProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return sarama.ProducerTxnFlagUninitialized
+	}
+	return producer.TxnStatus()
+}
+
+// IsTransactional delegates to the current producer
+func (p *ManagedProducer) IsTransactional() bool {
+	producer, _, release := p.acquire()
+	defer release()
+	return producer != nil && producer.IsTransactional()
+}
+
+// BeginTxn delegates to the current producer
+func (p *ManagedProducer) BeginTxn() error {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return ErrProducerNotReady
+	}
+	return producer.BeginTxn()
+}
+
+// CommitTxn delegates to the current producer
+func (p *ManagedProducer) CommitTxn() error {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return ErrProducerNotReady
+	}
+	return producer.CommitTxn()
+}
+
+// AbortTxn delegates to the current producer
+func (p *ManagedProducer) AbortTxn() error {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return ErrProducerNotReady
+	}
+	return producer.AbortTxn()
+}
+
+// AddOffsetsToTxn delegates to the current producer
+func (p *ManagedProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return ErrProducerNotReady
+	}
+	return producer.AddOffsetsToTxn(offsets, groupId)
+}
+
+// AddMessageToTxn delegates to the current producer
+func (p *ManagedProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	producer, _, release := p.acquire()
+	defer release()
+	if producer == nil {
+		return ErrProducerNotReady
+	}
+	return producer.AddMessageToTxn(msg, groupId, metadata)
+}
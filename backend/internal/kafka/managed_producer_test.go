@@ -0,0 +1,278 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// producerQueue hands out prepared mock producers in order, one per factory call
+type producerQueue struct {
+	mu        sync.Mutex
+	producers []*mocks.SyncProducer
+	built     int
+}
+
+func (q *producerQueue) factory() (sarama.SyncProducer, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.built == len(q.producers) {
+		return nil, errors.New("no more producers")
+	}
+	p := q.producers[q.built]
+	q.built++
+	return p, nil
+}
+
+func testMessage() *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{Topic: "notifications", Value: sarama.StringEncoder("hello")}
+}
+
+// failingFactory fails its first failures calls, then builds producers from queue
+type failingFactory struct {
+	queue    *producerQueue
+	failures int
+	calls    int
+}
+
+func (f *failingFactory) factory() (sarama.SyncProducer, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, sarama.ErrOutOfBrokers
+	}
+	return f.queue.factory()
+}
+
+func TestManagedProducer_PendingUntilConnected(t *testing.T) {
+	connected := mocks.NewSyncProducer(t, nil)
+	connected.ExpectSendMessageAndSucceed()
+	factory := &failingFactory{queue: &producerQueue{producers: []*mocks.SyncProducer{connected}}, failures: 3}
+
+	producer := NewPendingManagedProducer(factory.factory, 0, 3)
+	defer producer.Close()
+
+	assert.False(t, producer.Ready())
+	_, _, err := producer.SendMessage(testMessage())
+	assert.ErrorIs(t, err, ErrProducerNotReady)
+	assert.ErrorIs(t, producer.SendMessages([]*sarama.ProducerMessage{testMessage()}), ErrProducerNotReady)
+
+	err = producer.Connect(context.Background(), testRetryPolicy())
+	require.NoError(t, err)
+	assert.Equal(t, 4, factory.calls)
+	assert.True(t, producer.Ready())
+
+	_, _, err = producer.SendMessage(testMessage())
+	assert.NoError(t, err)
+}
+
+func TestManagedProducer_ConnectGivesUpAfterDeadline(t *testing.T) {
+	factory := &failingFactory{queue: &producerQueue{}, failures: 1000}
+	producer := NewPendingManagedProducer(factory.factory, 0, 3)
+	policy := testRetryPolicy()
+	policy.Deadline = 10 * time.Millisecond
+
+	err := producer.Connect(context.Background(), policy)
+
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	assert.False(t, producer.Ready())
+	assert.NoError(t, producer.Close())
+}
+
+func TestManagedProducer_RebuildsAfterConnectionErrorStreak(t *testing.T) {
+	stale := mocks.NewSyncProducer(t, nil)
+	stale.ExpectSendMessageAndSucceed()
+	for i := 0; i < 3; i++ {
+		stale.ExpectSendMessageAndFail(syscall.EPIPE)
+	}
+	fresh := mocks.NewSyncProducer(t, nil)
+	fresh.ExpectSendMessageAndSucceed()
+	fresh.ExpectSendMessageAndSucceed()
+
+	queue := &producerQueue{producers: []*mocks.SyncProducer{stale, fresh}}
+	producer, err := NewManagedProducer(queue.factory, 0, 3)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	// One success, then two broken pipes: still below the threshold
+	_, _, err = producer.SendMessage(testMessage())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _, err = producer.SendMessage(testMessage())
+		assert.ErrorIs(t, err, syscall.EPIPE)
+	}
+	assert.Equal(t, uint64(0), producer.Generation())
+
+	// The third consecutive error rebuilds and the send is retried on the new producer
+	_, _, err = producer.SendMessage(testMessage())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), producer.Generation())
+	assert.Equal(t, 2, queue.built)
+
+	_, _, err = producer.SendMessage(testMessage())
+	assert.NoError(t, err)
+}
+
+func TestManagedProducer_MessageErrorsDoNotCountTowardsRebuild(t *testing.T) {
+	stale := mocks.NewSyncProducer(t, nil)
+	stale.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+	stale.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+
+	queue := &producerQueue{producers: []*mocks.SyncProducer{stale}}
+	producer, err := NewManagedProducer(queue.factory, 0, 1)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	for i := 0; i < 2; i++ {
+		_, _, err = producer.SendMessage(testMessage())
+		assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	}
+	assert.Equal(t, uint64(0), producer.Generation())
+}
+
+func TestManagedProducer_RebuildsAfterMaxAge(t *testing.T) {
+	first := mocks.NewSyncProducer(t, nil)
+	first.ExpectSendMessageAndSucceed()
+	second := mocks.NewSyncProducer(t, nil)
+	second.ExpectSendMessageAndSucceed()
+
+	queue := &producerQueue{producers: []*mocks.SyncProducer{first, second}}
+	producer, err := NewManagedProducer(queue.factory, time.Hour, 0)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	now := time.Now()
+	producer.now = func() time.Time { return now }
+	producer.createdAt = now
+
+	_, _, err = producer.SendMessage(testMessage())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), producer.Generation())
+
+	now = now.Add(time.Hour)
+	_, _, err = producer.SendMessage(testMessage())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), producer.Generation())
+}
+
+// fakeProducer runs send for every message and records use after Close
+type fakeProducer struct {
+	sarama.SyncProducer
+	send      func() error
+	sendBatch func(msgs []*sarama.ProducerMessage) error
+
+	mu             sync.Mutex
+	sends          int
+	closed         bool
+	usedAfterClose bool
+}
+
+func (f *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	f.mu.Lock()
+	f.sends++
+	if f.closed {
+		f.usedAfterClose = true
+	}
+	f.mu.Unlock()
+	return 0, 0, f.send()
+}
+
+func (f *fakeProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	f.mu.Lock()
+	f.sends += len(msgs)
+	f.mu.Unlock()
+	return f.sendBatch(msgs)
+}
+
+func (f *fakeProducer) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestManagedProducer_ConcurrentSendsDuringRebuild(t *testing.T) {
+	const senders = 20
+
+	stale := &fakeProducer{send: func() error {
+		time.Sleep(time.Millisecond)
+		return syscall.EPIPE
+	}}
+	fresh := &fakeProducer{send: func() error { return nil }}
+
+	var builds int
+	var buildMu sync.Mutex
+	factory := func() (sarama.SyncProducer, error) {
+		buildMu.Lock()
+		defer buildMu.Unlock()
+		builds++
+		if builds == 1 {
+			return stale, nil
+		}
+		return fresh, nil
+	}
+
+	producer, err := NewManagedProducer(factory, 0, 1)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	start := make(chan struct{})
+	errs := make([]error, senders)
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, _, errs[i] = producer.SendMessage(testMessage())
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// Every send lands on the fresh producer, either directly or as a retry
+	for i, err := range errs {
+		assert.NoError(t, err, "sender %d", i)
+	}
+	assert.Equal(t, senders, fresh.sends)
+	assert.Equal(t, uint64(1), producer.Generation(), "concurrent failures trigger a single rebuild")
+	assert.True(t, stale.closed)
+	assert.False(t, stale.usedAfterClose, "in-flight sends finish before the old producer is closed")
+}
+
+func TestManagedProducer_BatchRetriesOnlyFailedMessages(t *testing.T) {
+	stale := &fakeProducer{sendBatch: func(msgs []*sarama.ProducerMessage) error {
+		return sarama.ProducerErrors{{Msg: msgs[1], Err: syscall.ECONNRESET}}
+	}}
+	var retried []*sarama.ProducerMessage
+	fresh := &fakeProducer{sendBatch: func(msgs []*sarama.ProducerMessage) error {
+		retried = msgs
+		return nil
+	}}
+
+	producers := []sarama.SyncProducer{stale, fresh}
+	factory := func() (sarama.SyncProducer, error) {
+		p := producers[0]
+		producers = producers[1:]
+		return p, nil
+	}
+
+	producer, err := NewManagedProducer(factory, 0, 1)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	batch := []*sarama.ProducerMessage{testMessage(), testMessage(), testMessage()}
+	err = producer.SendMessages(batch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), producer.Generation())
+	assert.Equal(t, []*sarama.ProducerMessage{batch[1]}, retried)
+}
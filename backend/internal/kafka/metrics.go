@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Metrics snapshots the sarama metrics of every client the manager created,
+// such as record-send-rate, request-latency-in-ms and batch-size, into a flat
+// map. Meters and histograms are split into one entry per statistic, for
+// example "record-send-rate.count" and "request-latency-in-ms.p99".
+func (cm *ClientManager) Metrics() map[string]float64 {
+	return snapshotMetrics(cm.registry)
+}
+
+// snapshotMetrics flattens every metric in the registry
+func snapshotMetrics(registry gometrics.Registry) map[string]float64 {
+	snapshot := make(map[string]float64)
+	registry.Each(func(name string, metric interface{}) {
+		switch m := metric.(type) {
+		case gometrics.Counter:
+			snapshot[name] = float64(m.Count())
+		case gometrics.Gauge:
+			snapshot[name] = float64(m.Value())
+		case gometrics.GaugeFloat64:
+			snapshot[name] = m.Value()
+		case gometrics.Meter:
+			s := m.Snapshot()
+			snapshot[name+".count"] = float64(s.Count())
+			snapshot[name+".rate1"] = s.Rate1()
+			snapshot[name+".rate_mean"] = s.RateMean()
+		case gometrics.Histogram:
+			s := m.Snapshot()
+			snapshot[name+".count"] = float64(s.Count())
+			snapshot[name+".min"] = float64(s.Min())
+			snapshot[name+".max"] = float64(s.Max())
+			snapshot[name+".mean"] = s.Mean()
+			snapshot[name+".p50"] = s.Percentile(0.5)
+			snapshot[name+".p99"] = s.Percentile(0.99)
+		}
+	})
+	return snapshot
+}
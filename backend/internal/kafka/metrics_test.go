@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_SnapshotsProducerMetrics(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("notifications", 0, broker.BrokerID()),
+		"InitProducerIDRequest": sarama.NewMockInitProducerIDResponse(t),
+		"ProduceRequest":        sarama.NewMockProduceResponse(t),
+	})
+
+	clients := NewClientManager(&config.KafkaConfig{
+		Brokers: []string{broker.Addr()},
+		ProducerConfig: config.ProducerConfig{
+			RequiredAcks: -1,
+			RetryMax:     3,
+			Timeout:      time.Second,
+		},
+	})
+	producer, err := clients.NewProducer()
+	require.NoError(t, err)
+	defer producer.Close()
+
+	_, _, err = producer.SendMessage(&sarama.ProducerMessage{Topic: "notifications", Value: sarama.StringEncoder("hello")})
+	require.NoError(t, err)
+
+	snapshot := clients.Metrics()
+	assert.Equal(t, float64(1), snapshot["record-send-rate.count"])
+	assert.Equal(t, float64(1), snapshot["record-send-rate-for-topic-notifications.count"])
+	assert.Contains(t, snapshot, "request-latency-in-ms.p99")
+	assert.Contains(t, snapshot, "batch-size.mean")
+}
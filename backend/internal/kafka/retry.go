@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"kafka-notify/internal/config"
+)
+
+// ConnectWithRetry calls connect until it succeeds, waiting RetryBackoff
+// between attempts. It gives up with the last error once policy.Deadline has
+// passed or ctx ends; a zero Deadline keeps trying until ctx ends.
+func ConnectWithRetry(ctx context.Context, policy config.ConnectRetryConfig, what string, connect func() error) error {
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Connected %s after %d attempts", what, attempt)
+			}
+			return nil
+		}
+
+		wait := RetryBackoff(policy, attempt)
+		log.Printf("Failed to connect %s (attempt %d), retrying in %s: %v", what, attempt, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up connecting %s after %d attempts: %w", what, attempt, err)
+		}
+	}
+}
+
+// RetryBackoff returns the wait after the given failed attempt: InitialBackoff
+// doubled per earlier failure, capped at MaxBackoff. Without a MaxBackoff the
+// wait stays at InitialBackoff.
+func RetryBackoff(policy config.ConnectRetryConfig, attempt int) time.Duration {
+	wait := policy.InitialBackoff
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for i := 1; i < attempt && wait < policy.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		wait = policy.MaxBackoff
+	}
+	return wait
+}
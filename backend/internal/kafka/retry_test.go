@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRetryPolicy() config.ConnectRetryConfig {
+	return config.ConnectRetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+}
+
+func TestConnectWithRetry_SucceedsAfterFailures(t *testing.T) {
+	errBroker := errors.New("broker not available")
+	calls := 0
+
+	err := ConnectWithRetry(context.Background(), testRetryPolicy(), "test client", func() error {
+		calls++
+		if calls <= 3 {
+			return errBroker
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestConnectWithRetry_GivesUpAfterDeadline(t *testing.T) {
+	errBroker := errors.New("broker not available")
+	policy := testRetryPolicy()
+	policy.Deadline = 20 * time.Millisecond
+	calls := 0
+
+	err := ConnectWithRetry(context.Background(), policy, "test client", func() error {
+		calls++
+		return errBroker
+	})
+
+	assert.ErrorIs(t, err, errBroker)
+	assert.ErrorContains(t, err, "gave up connecting test client")
+	assert.Greater(t, calls, 1)
+}
+
+func TestConnectWithRetry_StopsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := ConnectWithRetry(ctx, testRetryPolicy(), "test client", func() error {
+		calls++
+		cancel()
+		return errors.New("broker not available")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := config.ConnectRetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, RetryBackoff(policy, 1))
+	assert.Equal(t, 2*time.Second, RetryBackoff(policy, 2))
+	assert.Equal(t, 4*time.Second, RetryBackoff(policy, 3))
+	assert.Equal(t, 5*time.Second, RetryBackoff(policy, 4))
+	assert.Equal(t, 5*time.Second, RetryBackoff(policy, 50))
+
+	// Without a cap the backoff stays constant
+	assert.Equal(t, 2*time.Second, RetryBackoff(config.ConnectRetryConfig{InitialBackoff: 2 * time.Second}, 10))
+	assert.Equal(t, time.Second, RetryBackoff(config.ConnectRetryConfig{}, 1))
+}
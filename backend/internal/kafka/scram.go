@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// scramClient is a SCRAM (RFC 5802) client for sarama's SCRAM-SHA-256 and
+// SCRAM-SHA-512 mechanisms. Usernames are escaped as the RFC requires;
+// passwords are used as they are, without SASLprep.
+type scramClient struct {
+	hash  func() hash.Hash
+	nonce func() (string, error)
+
+	username, password, authzID string
+
+	step            int
+	gs2Header       string
+	clientFirstBare string
+	clientNonce     string
+	serverSignature []byte
+	done            bool
+}
+
+// newSCRAMClientGenerator returns a sarama SCRAM client generator for the hash
+func newSCRAMClientGenerator(h func() hash.Hash) func() sarama.SCRAMClient {
+	return func() sarama.SCRAMClient {
+		return &scramClient{hash: h, nonce: randomNonce}
+	}
+}
+
+// scramSHA256 and scramSHA512 generate clients for the SCRAM mechanisms
+var (
+	scramSHA256 = newSCRAMClientGenerator(sha256.New)
+	scramSHA512 = newSCRAMClientGenerator(sha512.New)
+)
+
+// Begin starts a new exchange for the user
+func (c *scramClient) Begin(username, password, authzID string) error {
+	*c = scramClient{hash: c.hash, nonce: c.nonce, username: username, password: password, authzID: authzID}
+	return nil
+}
+
+// Step answers the server's challenge: the first call sends client-first,
+// the second the proof for server-first and the third verifies server-final
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		return c.clientFirst()
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		return "", c.verifyServerFinal(challenge)
+	default:
+		return "", errors.New("scram: exchange already finished")
+	}
+}
+
+// Done reports whether the server has been verified
+func (c *scramClient) Done() bool {
+	return c.done
+}
+
+func (c *scramClient) clientFirst() (string, error) {
+	nonce, err := c.nonce()
+	if err != nil {
+		return "", fmt.Errorf("scram: failed to create nonce: %w", err)
+	}
+	c.clientNonce = nonce
+	c.gs2Header = "n,,"
+	if c.authzID != "" {
+		c.gs2Header = "n,a=" + escapeSCRAMName(c.authzID) + ","
+	}
+	c.clientFirstBare = "n=" + escapeSCRAMName(c.username) + ",r=" + nonce
+	return c.gs2Header + c.clientFirstBare, nil
+}
+
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := parseSCRAMAttributes(serverFirst)
+	if msg, ok := attrs["e"]; ok {
+		return "", fmt.Errorf("scram: server rejected authentication: %s", msg)
+	}
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return "", errors.New("scram: server nonce does not extend the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return "", errors.New("scram: server sent an invalid salt")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return "", errors.New("scram: server sent an invalid iteration count")
+	}
+
+	saltedPassword := c.hi([]byte(c.password), salt, iterations)
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	storedKey := c.sum(clientKey)
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header)) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	proof := c.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, []byte("Server Key")), authMessage)
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := parseSCRAMAttributes(serverFinal)
+	if msg, ok := attrs["e"]; ok {
+		return fmt.Errorf("scram: server rejected authentication: %s", msg)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("scram: server signature does not match")
+	}
+	c.done = true
+	return nil
+}
+
+// hi is SCRAM's Hi(): PBKDF2 with the HMAC of the client's hash, one block long
+func (c *scramClient) hi(password, salt []byte, iterations int) []byte {
+	u := c.hmac(password, append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		u = c.hmac(password, u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func (c *scramClient) hmac(key, data []byte) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (c *scramClient) sum(data []byte) []byte {
+	h := c.hash()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// parseSCRAMAttributes splits a SCRAM message into its key=value attributes
+func parseSCRAMAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+// escapeSCRAMName escapes ',' and '=' in a username as RFC 5802 requires
+func escapeSCRAMName(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
+
+// randomNonce returns a printable random client nonce
+func randomNonce() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(b), nil
+}
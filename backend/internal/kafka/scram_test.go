@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSCRAMClient_RFC7677 runs the SCRAM-SHA-256 example exchange from RFC 7677
+func TestSCRAMClient_RFC7677(t *testing.T) {
+	client := &scramClient{hash: sha256.New, nonce: func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }}
+	require.NoError(t, client.Begin("user", "pencil", ""))
+
+	first, err := client.Step("")
+	require.NoError(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", first)
+
+	final, err := client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	require.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", final)
+	assert.False(t, client.Done())
+
+	_, err = client.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	require.NoError(t, err)
+	assert.True(t, client.Done())
+}
+
+func TestSCRAMClient_RejectsBadServer(t *testing.T) {
+	newClient := func() *scramClient {
+		client := &scramClient{hash: sha256.New, nonce: func() (string, error) { return "clientnonce", nil }}
+		require.NoError(t, client.Begin("user", "pencil", ""))
+		_, err := client.Step("")
+		require.NoError(t, err)
+		return client
+	}
+
+	_, err := newClient().Step("r=othernonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.ErrorContains(t, err, "server nonce")
+
+	_, err = newClient().Step("e=invalid-proof")
+	assert.ErrorContains(t, err, "server rejected authentication: invalid-proof")
+
+	client := newClient()
+	_, err = client.Step("r=clientnonceserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=16")
+	require.NoError(t, err)
+	_, err = client.Step("v=bm90IHRoZSBzaWduYXR1cmU=")
+	assert.ErrorContains(t, err, "server signature does not match")
+	assert.False(t, client.Done())
+}
+
+func TestEscapeSCRAMName(t *testing.T) {
+	assert.Equal(t, "a=3Db=2Cc", escapeSCRAMName("a=b,c"))
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+)
+
+// applySecurity sets the SASL and TLS settings for cfg's security protocol
+func applySecurity(sc *sarama.Config, cfg *config.KafkaConfig) error {
+	if err := cfg.ValidateSecurity(); err != nil {
+		return err
+	}
+
+	if cfg.UsesTLS() {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = tlsConfig
+	}
+
+	if cfg.UsesSASL() {
+		sc.Net.SASL.Enable = true
+		sc.Net.SASL.Handshake = true
+		sc.Net.SASL.User = cfg.SASLUsername
+		sc.Net.SASL.Password = cfg.SASLPassword
+		switch cfg.SASLMechanism {
+		case config.KafkaSASLPlain:
+			sc.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case config.KafkaSASLSCRAMSHA256:
+			sc.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			sc.Net.SASL.SCRAMClientGeneratorFunc = scramSHA256
+		case config.KafkaSASLSCRAMSHA512:
+			sc.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			sc.Net.SASL.SCRAMClientGeneratorFunc = scramSHA512
+		}
+	}
+	return nil
+}
+
+// newTLSConfig builds the client TLS config, loading the CA bundle and client
+// certificate when they are set
+func newTLSConfig(cfg config.KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Kafka CA file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kafka client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate and its key as PEM files
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func newSecureConfig(t *testing.T, cfg config.KafkaConfig) *sarama.Config {
+	t.Helper()
+	cfg.Brokers = []string{"kafka-1:9092"}
+	sc, err := NewClientManager(&cfg).NewConfig()
+	require.NoError(t, err)
+	require.NoError(t, sc.Validate())
+	return sc
+}
+
+func TestNewConfig_Plaintext(t *testing.T) {
+	sc := newSecureConfig(t, config.KafkaConfig{SecurityProtocol: config.KafkaProtocolPlaintext})
+
+	assert.False(t, sc.Net.TLS.Enable)
+	assert.False(t, sc.Net.SASL.Enable)
+}
+
+func TestNewConfig_SSLWithClientCertificate(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	sc := newSecureConfig(t, config.KafkaConfig{
+		SecurityProtocol: config.KafkaProtocolSSL,
+		TLS:              config.KafkaTLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile},
+	})
+
+	assert.True(t, sc.Net.TLS.Enable)
+	assert.False(t, sc.Net.SASL.Enable)
+	require.NotNil(t, sc.Net.TLS.Config)
+	assert.NotNil(t, sc.Net.TLS.Config.RootCAs)
+	assert.Len(t, sc.Net.TLS.Config.Certificates, 1)
+	assert.Equal(t, uint16(tls.VersionTLS12), sc.Net.TLS.Config.MinVersion)
+	assert.False(t, sc.Net.TLS.Config.InsecureSkipVerify)
+}
+
+func TestNewConfig_SASLMechanisms(t *testing.T) {
+	tests := []struct {
+		protocol  string
+		mechanism string
+		want      sarama.SASLMechanism
+		scram     bool
+	}{
+		{config.KafkaProtocolSASLSSL, config.KafkaSASLPlain, sarama.SASLTypePlaintext, false},
+		{config.KafkaProtocolSASLSSL, config.KafkaSASLSCRAMSHA256, sarama.SASLTypeSCRAMSHA256, true},
+		{config.KafkaProtocolSASLSSL, config.KafkaSASLSCRAMSHA512, sarama.SASLTypeSCRAMSHA512, true},
+		{config.KafkaProtocolSASLPlaintext, config.KafkaSASLSCRAMSHA512, sarama.SASLTypeSCRAMSHA512, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol+"/"+tt.mechanism, func(t *testing.T) {
+			sc := newSecureConfig(t, config.KafkaConfig{
+				SecurityProtocol: tt.protocol,
+				SASLMechanism:    tt.mechanism,
+				SASLUsername:     "api-key",
+				SASLPassword:     "api-secret",
+				TLS:              config.KafkaTLSConfig{InsecureSkipVerify: tt.protocol == config.KafkaProtocolSASLSSL},
+			})
+
+			assert.True(t, sc.Net.SASL.Enable)
+			assert.True(t, sc.Net.SASL.Handshake)
+			assert.Equal(t, tt.want, sc.Net.SASL.Mechanism)
+			assert.Equal(t, "api-key", sc.Net.SASL.User)
+			assert.Equal(t, "api-secret", sc.Net.SASL.Password)
+			assert.Equal(t, tt.scram, sc.Net.SASL.SCRAMClientGeneratorFunc != nil)
+			assert.Equal(t, tt.protocol == config.KafkaProtocolSASLSSL, sc.Net.TLS.Enable)
+			if sc.Net.TLS.Enable {
+				assert.True(t, sc.Net.TLS.Config.InsecureSkipVerify)
+			}
+		})
+	}
+}
+
+func TestNewConfig_Misconfigured(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := map[string]struct {
+		cfg  config.KafkaConfig
+		want string
+	}{
+		"SASL without credentials": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSASLSSL, SASLMechanism: config.KafkaSASLPlain},
+			want: "SASL_SSL requires KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD",
+		},
+		"credentials without SASL": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSSL, SASLUsername: "api-key", SASLPassword: "api-secret"},
+			want: "KAFKA_SECURITY_PROTOCOL is SSL",
+		},
+		"unknown mechanism": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSASLSSL, SASLMechanism: "GSSAPI", SASLUsername: "u", SASLPassword: "p"},
+			want: `unknown KAFKA_SASL_MECHANISM "GSSAPI"`,
+		},
+		"TLS files without TLS": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSASLPlaintext, SASLMechanism: config.KafkaSASLPlain, SASLUsername: "u", SASLPassword: "p", TLS: config.KafkaTLSConfig{CAFile: missing}},
+			want: "KAFKA_SECURITY_PROTOCOL is SASL_PLAINTEXT",
+		},
+		"missing CA file": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSSL, TLS: config.KafkaTLSConfig{CAFile: missing}},
+			want: "failed to read Kafka CA file",
+		},
+		"CA file without certificates": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSSL, TLS: config.KafkaTLSConfig{CAFile: notPEM}},
+			want: "contains no PEM certificates",
+		},
+		"certificate without key": {
+			cfg:  config.KafkaConfig{SecurityProtocol: config.KafkaProtocolSSL, TLS: config.KafkaTLSConfig{CertFile: notPEM}},
+			want: "must be set together",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			manager := NewClientManager(&tt.cfg)
+
+			_, err := manager.NewConfig()
+			require.ErrorContains(t, err, tt.want)
+			assert.ErrorContains(t, err, "invalid Kafka security configuration")
+
+			_, err = manager.NewProducer()
+			assert.ErrorContains(t, err, tt.want)
+			_, err = manager.NewConsumerGroup("inbox-group")
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"kafka-notify/internal/config"
+
+	"github.com/IBM/sarama"
+)
+
+// clusterAdmin is the part of sarama.ClusterAdmin EnsureTopics uses
+type clusterAdmin interface {
+	CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error
+	Close() error
+}
+
+// newClusterAdmin creates cluster admins; tests replace it with a fake
+var newClusterAdmin = func(addrs []string, cfg *sarama.Config) (clusterAdmin, error) {
+	return sarama.NewClusterAdmin(addrs, cfg)
+}
+
+// EnsureTopics creates every configured topic (main, priority and DLQ) that
+// doesn't exist yet, using KafkaConfig.TopicSpecs. Existing topics are left
+// as they are.
+func (cm *ClientManager) EnsureTopics(ctx context.Context) error {
+	config, err := cm.NewConfig()
+	if err != nil {
+		return err
+	}
+	admin, err := newClusterAdmin(cm.config.Brokers, config)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka cluster admin: %w", err)
+	}
+	defer admin.Close()
+
+	specs := cm.config.TopicSpecs()
+	topics := make([]string, 0, len(specs))
+	for topic := range specs {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := admin.CreateTopic(topic, topicDetail(specs[topic]), false)
+		switch {
+		case err == nil:
+			log.Printf("Created Kafka topic %s (partitions: %d, replication factor: %d)",
+				topic, specs[topic].Partitions, specs[topic].ReplicationFactor)
+		case errors.Is(err, sarama.ErrTopicAlreadyExists):
+		default:
+			return fmt.Errorf("failed to create Kafka topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// topicDetail converts a topic spec to the request sarama sends
+func topicDetail(spec config.TopicSpec) *sarama.TopicDetail {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+	}
+	if spec.Retention > 0 {
+		retention := strconv.FormatInt(spec.Retention.Milliseconds(), 10)
+		detail.ConfigEntries = map[string]*string{"retention.ms": &retention}
+	}
+	return detail
+}
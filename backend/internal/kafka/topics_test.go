@@ -0,0 +1,125 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClusterAdmin records the topics it is asked to create
+type fakeClusterAdmin struct {
+	existing map[string]bool
+	err      error
+	created  map[string]*sarama.TopicDetail
+	closed   bool
+}
+
+func (a *fakeClusterAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.existing[topic] {
+		return &sarama.TopicError{Err: sarama.ErrTopicAlreadyExists}
+	}
+	a.created[topic] = detail
+	return nil
+}
+
+func (a *fakeClusterAdmin) Close() error {
+	a.closed = true
+	return nil
+}
+
+func useFakeClusterAdmin(t *testing.T, admin *fakeClusterAdmin) {
+	t.Helper()
+	admin.created = make(map[string]*sarama.TopicDetail)
+	original := newClusterAdmin
+	newClusterAdmin = func([]string, *sarama.Config) (clusterAdmin, error) { return admin, nil }
+	t.Cleanup(func() { newClusterAdmin = original })
+}
+
+func topicsConfig() *config.KafkaConfig {
+	return &config.KafkaConfig{
+		Brokers: []string{"kafka-1:9092"},
+		Topic:   "notifications",
+		Topics:  map[models.PriorityLevel]string{models.PriorityUrgent: "notifications-urgent"},
+		DLQ: config.DLQConfig{
+			Topic:     "notifications-dlq",
+			TopicSpec: config.TopicSpec{Partitions: 1, ReplicationFactor: 3},
+		},
+		TopicSpec: config.TopicSpec{Partitions: 12, ReplicationFactor: 3, Retention: 7 * 24 * time.Hour},
+	}
+}
+
+func TestEnsureTopics_CreatesEveryTopic(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	useFakeClusterAdmin(t, admin)
+
+	err := NewClientManager(topicsConfig()).EnsureTopics(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, admin.closed)
+	require.Len(t, admin.created, 3)
+	for _, topic := range []string{"notifications", "notifications-urgent"} {
+		detail := admin.created[topic]
+		require.NotNil(t, detail, topic)
+		assert.Equal(t, int32(12), detail.NumPartitions)
+		assert.Equal(t, int16(3), detail.ReplicationFactor)
+		assert.Equal(t, "604800000", *detail.ConfigEntries["retention.ms"])
+	}
+	dlq := admin.created["notifications-dlq"]
+	assert.Equal(t, int32(1), dlq.NumPartitions)
+	assert.Nil(t, dlq.ConfigEntries, "no retention leaves the broker default")
+}
+
+func TestEnsureTopics_ExistingTopicsSucceed(t *testing.T) {
+	admin := &fakeClusterAdmin{existing: map[string]bool{"notifications": true, "notifications-dlq": true}}
+	useFakeClusterAdmin(t, admin)
+
+	err := NewClientManager(topicsConfig()).EnsureTopics(context.Background())
+
+	require.NoError(t, err)
+	assert.Len(t, admin.created, 1)
+	assert.Contains(t, admin.created, "notifications-urgent")
+}
+
+func TestEnsureTopics_CreateFailure(t *testing.T) {
+	admin := &fakeClusterAdmin{err: sarama.ErrInvalidReplicationFactor}
+	useFakeClusterAdmin(t, admin)
+
+	err := NewClientManager(topicsConfig()).EnsureTopics(context.Background())
+
+	assert.ErrorIs(t, err, sarama.ErrInvalidReplicationFactor)
+	assert.ErrorContains(t, err, "failed to create Kafka topic notifications")
+	assert.True(t, admin.closed)
+}
+
+func TestEnsureTopics_AdminUnavailable(t *testing.T) {
+	original := newClusterAdmin
+	newClusterAdmin = func([]string, *sarama.Config) (clusterAdmin, error) { return nil, sarama.ErrOutOfBrokers }
+	t.Cleanup(func() { newClusterAdmin = original })
+
+	err := NewClientManager(topicsConfig()).EnsureTopics(context.Background())
+
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+}
+
+func TestEnsureTopics_StopsWhenCancelled(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	useFakeClusterAdmin(t, admin)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewClientManager(topicsConfig()).EnsureTopics(ctx)
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, admin.created)
+}
@@ -0,0 +1,50 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// JobLock keeps a scheduled job from running on more than one replica at a
+// time using Postgres transaction-level advisory locks. The lock is held by a
+// transaction kept open for the length of the run and is released when that
+// transaction ends, so a replica that dies mid-run frees it with its session.
+type JobLock struct {
+	db *sql.DB
+}
+
+// NewJobLock creates a job lock over the shared database
+func NewJobLock(db *sql.DB) *JobLock {
+	return &JobLock{db: db}
+}
+
+// JobKey derives the advisory lock key for a job name
+func JobKey(job string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("job:" + job))
+	return int64(h.Sum64())
+}
+
+// RunExclusive runs fn while holding the job's lock and reports whether it ran.
+// When another replica holds the lock, fn is skipped and RunExclusive returns
+// false with no error.
+func (l *JobLock) RunExclusive(ctx context.Context, job string, fn func(ctx context.Context) error) (bool, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin %s lock transaction: %w", job, err)
+	}
+	// The transaction only holds the lock; rolling it back releases it
+	defer tx.Rollback()
+
+	var acquired bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", JobKey(job)).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("failed to try %s lock: %w", job, err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	return true, fn(ctx)
+}
@@ -0,0 +1,166 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tryJobLockQuery = regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")
+
+func TestJobLock_RunsWhileHoldingLock(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(tryJobLockQuery).WithArgs(JobKey("daily")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+	mock.ExpectRollback()
+
+	runs := 0
+
+	// Act
+	ran, err := NewJobLock(db).RunExclusive(context.Background(), "daily", func(ctx context.Context) error {
+		runs++
+		return nil
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, 1, runs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobLock_SkipsWhileLockIsHeld(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(tryJobLockQuery).WithArgs(JobKey("daily")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+	mock.ExpectRollback()
+
+	// Act
+	ran, err := NewJobLock(db).RunExclusive(context.Background(), "daily", func(ctx context.Context) error {
+		t.Error("a job must not run without its lock")
+		return nil
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, ran)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobLock_ReturnsJobError(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(tryJobLockQuery).WithArgs(JobKey("daily")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+	mock.ExpectRollback()
+	jobErr := errors.New("query failed")
+
+	// Act
+	ran, err := NewJobLock(db).RunExclusive(context.Background(), "daily", func(ctx context.Context) error {
+		return jobErr
+	})
+
+	// Assert
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, jobErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobLock_LockQueryFailureSkipsJob(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(tryJobLockQuery).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	// Act
+	ran, err := NewJobLock(db).RunExclusive(context.Background(), "daily", func(ctx context.Context) error {
+		t.Error("a job must not run when the lock could not be checked")
+		return nil
+	})
+
+	// Assert
+	assert.False(t, ran)
+	assert.ErrorContains(t, err, "failed to try daily lock")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobKey_DiffersPerJob(t *testing.T) {
+	assert.Equal(t, JobKey("daily"), JobKey("daily"))
+	assert.NotEqual(t, JobKey("daily"), JobKey("weekly"))
+	assert.NotEqual(t, OutboxProcessorKey, JobKey("daily"))
+}
+
+// TestJobLock_SingleRunAcrossConnections runs against a real Postgres, using
+// the DB_* settings, when LEADER_INTEGRATION_DB is set
+func TestJobLock_SingleRunAcrossConnections(t *testing.T) {
+	if os.Getenv("LEADER_INTEGRATION_DB") == "" {
+		t.Skip("set LEADER_INTEGRATION_DB to run against Postgres")
+	}
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	first, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	defer second.Close()
+
+	ctx := context.Background()
+	holding := make(chan struct{})
+	finish := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	job := func(ctx context.Context) error {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		close(holding)
+		<-finish
+		return nil
+	}
+
+	// Replica a holds the lock while replica b tries the same job
+	done := make(chan bool, 1)
+	go func() {
+		ran, _ := NewJobLock(first.GetDB()).RunExclusive(ctx, "integration", job)
+		done <- ran
+	}()
+	<-holding
+	ran, err := NewJobLock(second.GetDB()).RunExclusive(ctx, "integration", job)
+	close(finish)
+
+	require.NoError(t, err)
+	assert.False(t, ran)
+	assert.True(t, <-done)
+	assert.Equal(t, 1, runs)
+}
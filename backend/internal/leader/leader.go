@@ -0,0 +1,111 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"sync"
+	"time"
+)
+
+// OutboxProcessorKey is the advisory lock key held by the producer replica that
+// runs the outbox processor
+const OutboxProcessorKey int64 = 0x6f7574626f78 // "outbox"
+
+// releaseTimeout bounds unlocking when leadership is given up
+const releaseTimeout = 5 * time.Second
+
+// Elector elects a single leader among replicas sharing a database using a
+// Postgres session-level advisory lock. The lock lives as long as the session
+// that took it, so the elector keeps one connection out of the pool while it
+// leads; if that connection dies, Postgres releases the lock for another replica.
+type Elector struct {
+	db   *sql.DB
+	key  int64
+	name string
+
+	mu      sync.Mutex
+	conn    *sql.Conn
+	standby bool
+}
+
+// NewElector creates an elector for the advisory lock key; name labels log lines
+func NewElector(db *sql.DB, key int64, name string) *Elector {
+	return &Elector{db: db, key: key, name: name}
+}
+
+// IsLeader reports whether this replica holds the lock, trying to take it when
+// it does not. A leader re-checks that its lock session is still alive.
+func (e *Elector) IsLeader(ctx context.Context) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		_, err := e.conn.ExecContext(ctx, "SELECT 1")
+		if err == nil {
+			return true
+		}
+		log.Printf("Lost %s leadership: %v", e.name, err)
+		e.releaseLocked()
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Failed to get connection for %s leader election: %v", e.name, err)
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil {
+		log.Printf("Failed to try %s leader lock: %v", e.name, err)
+		conn.Close()
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		if !e.standby {
+			log.Printf("Another replica leads %s; standing by", e.name)
+			e.standby = true
+		}
+		return false
+	}
+
+	e.conn = conn
+	e.standby = false
+	log.Printf("Acquired %s leadership", e.name)
+	return true
+}
+
+// Leading reports the last known leadership state without touching the database
+func (e *Elector) Leading() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.conn != nil
+}
+
+// Release gives up leadership so another replica can take over straight away
+func (e *Elector) Release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		e.releaseLocked()
+		log.Printf("Released %s leadership", e.name)
+	}
+}
+
+// releaseLocked unlocks and returns the lock connection; the caller holds mu
+func (e *Elector) releaseLocked() {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if _, err := e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", e.key); err != nil {
+		log.Printf("Failed to unlock %s leader lock: %v", e.name, err)
+		// Discard the connection rather than pooling a session that may still
+		// hold the lock; closing the session releases it
+		_ = e.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	e.conn.Close()
+	e.conn = nil
+}
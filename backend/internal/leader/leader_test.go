@@ -0,0 +1,163 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey int64 = 42
+
+var (
+	tryLockQuery = regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")
+	unlockQuery  = regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")
+	pingQuery    = regexp.QuoteMeta("SELECT 1")
+)
+
+func TestElector_AcquiresAndKeepsLeadership(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(pingQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(unlockQuery).WithArgs(testKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	elector := NewElector(db, testKey, "test")
+	ctx := context.Background()
+
+	// Act & Assert: the first check takes the lock, the next only confirms the session
+	assert.True(t, elector.IsLeader(ctx))
+	assert.True(t, elector.Leading())
+	assert.True(t, elector.IsLeader(ctx))
+
+	elector.Release()
+	assert.False(t, elector.Leading())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestElector_StandsByWhileLockIsHeld(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+
+	elector := NewElector(db, testKey, "test")
+	ctx := context.Background()
+
+	// Act & Assert: a standby keeps retrying and takes over once the lock is free
+	assert.False(t, elector.IsLeader(ctx))
+	assert.False(t, elector.Leading())
+	assert.True(t, elector.IsLeader(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestElector_LostSessionGivesUpLeadership(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(pingQuery).WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec(unlockQuery).WithArgs(testKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	elector := NewElector(db, testKey, "test")
+	ctx := context.Background()
+	require.True(t, elector.IsLeader(ctx))
+
+	// Act
+	leading := elector.IsLeader(ctx)
+
+	// Assert
+	assert.False(t, leading)
+	assert.False(t, elector.Leading())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestElector_FailedUnlockDiscardsSession(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(tryLockQuery).WithArgs(testKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(unlockQuery).WithArgs(testKey).WillReturnError(errors.New("connection reset"))
+
+	elector := NewElector(db, testKey, "test")
+	require.True(t, elector.IsLeader(context.Background()))
+
+	// Act
+	elector.Release()
+
+	// Assert: the session that may still hold the lock is closed, not pooled
+	assert.False(t, elector.Leading())
+	assert.Zero(t, db.Stats().OpenConnections)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestElector_ReleaseWithoutLeadershipIsNoop(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Act
+	NewElector(db, testKey, "test").Release()
+
+	// Assert
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestElector_SingleLeaderAcrossConnections runs against a real Postgres, using
+// the DB_* settings, when LEADER_INTEGRATION_DB is set
+func TestElector_SingleLeaderAcrossConnections(t *testing.T) {
+	if os.Getenv("LEADER_INTEGRATION_DB") == "" {
+		t.Skip("set LEADER_INTEGRATION_DB to run against Postgres")
+	}
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	first, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	defer second.Close()
+
+	ctx := context.Background()
+	a := NewElector(first.GetDB(), testKey, "replica a")
+	b := NewElector(second.GetDB(), testKey, "replica b")
+
+	// Only one replica leads at a time
+	require.True(t, a.IsLeader(ctx))
+	assert.False(t, b.IsLeader(ctx))
+	assert.True(t, a.IsLeader(ctx))
+
+	// Releasing hands leadership to the standby on its next check
+	a.Release()
+	assert.True(t, b.IsLeader(ctx))
+	assert.False(t, a.IsLeader(ctx))
+	b.Release()
+}
@@ -0,0 +1,97 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+// DefaultCacheTTL is how long the flag is served from memory before re-reading
+// it, which bounds how quickly other replicas notice a toggle
+const DefaultCacheTTL = 5 * time.Second
+
+// Store persists the maintenance flag
+type Store interface {
+	GetMaintenanceMode(ctx context.Context) (*models.MaintenanceMode, error)
+	SetMaintenanceMode(ctx context.Context, mode *models.MaintenanceMode) error
+}
+
+// Flag is a cached view of the system-wide maintenance mode. Background loops
+// call Active before each unit of work and skip it while maintenance is on.
+type Flag struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	mode      models.MaintenanceMode
+	fetchedAt time.Time
+	loaded    bool
+}
+
+// NewFlag creates a maintenance flag backed by store; a non-positive ttl uses DefaultCacheTTL
+func NewFlag(store Store, ttl time.Duration) *Flag {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Flag{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Active reports whether maintenance mode is on. If the store can't be read
+// the last known state is kept.
+func (f *Flag) Active(ctx context.Context) bool {
+	return f.State(ctx).Enabled
+}
+
+// State returns the current maintenance mode, refreshing it once the cache expires
+func (f *Flag) State(ctx context.Context) models.MaintenanceMode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.loaded && f.now().Sub(f.fetchedAt) < f.ttl {
+		return f.mode
+	}
+
+	mode, err := f.store.GetMaintenanceMode(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh maintenance mode, keeping enabled=%t: %v", f.mode.Enabled, err)
+		return f.mode
+	}
+
+	f.mode = *mode
+	f.fetchedAt = f.now()
+	f.loaded = true
+	return f.mode
+}
+
+// Set turns maintenance mode on or off and updates the local cache immediately
+func (f *Flag) Set(ctx context.Context, enabled bool, reason, setBy string) (models.MaintenanceMode, error) {
+	now := f.now()
+	mode := models.MaintenanceMode{
+		Enabled: enabled,
+		Reason:  reason,
+		SetBy:   setBy,
+		SetAt:   &now,
+	}
+
+	if err := f.store.SetMaintenanceMode(ctx, &mode); err != nil {
+		return models.MaintenanceMode{}, fmt.Errorf("failed to update maintenance mode: %w", err)
+	}
+
+	f.mu.Lock()
+	f.mode = mode
+	f.fetchedAt = now
+	f.loaded = true
+	f.mu.Unlock()
+
+	log.Printf("Maintenance mode enabled=%t by %q: %s", enabled, setBy, reason)
+	return mode, nil
+}
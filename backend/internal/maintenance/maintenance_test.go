@@ -0,0 +1,100 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store that counts reads
+type memoryStore struct {
+	mode  models.MaintenanceMode
+	reads int
+	err   error
+}
+
+func (s *memoryStore) GetMaintenanceMode(ctx context.Context) (*models.MaintenanceMode, error) {
+	s.reads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	mode := s.mode
+	return &mode, nil
+}
+
+func (s *memoryStore) SetMaintenanceMode(ctx context.Context, mode *models.MaintenanceMode) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mode = *mode
+	return nil
+}
+
+func newTestFlag(store Store) (*Flag, *time.Time) {
+	flag := NewFlag(store, 5*time.Second)
+	now := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	flag.now = func() time.Time { return now }
+	return flag, &now
+}
+
+func TestFlag_CachesStateForTTL(t *testing.T) {
+	store := &memoryStore{}
+	flag, now := newTestFlag(store)
+	ctx := context.Background()
+
+	assert.False(t, flag.Active(ctx))
+
+	// Another replica turns maintenance on; this one notices after the TTL
+	store.mode = models.MaintenanceMode{Enabled: true, Reason: "incident"}
+	*now = now.Add(2 * time.Second)
+	assert.False(t, flag.Active(ctx))
+	assert.Equal(t, 1, store.reads)
+
+	*now = now.Add(5 * time.Second)
+	assert.True(t, flag.Active(ctx))
+	assert.Equal(t, 2, store.reads)
+}
+
+func TestFlag_SetUpdatesCacheImmediately(t *testing.T) {
+	store := &memoryStore{}
+	flag, _ := newTestFlag(store)
+	ctx := context.Background()
+	require.False(t, flag.Active(ctx))
+
+	mode, err := flag.Set(ctx, true, "kafka incident", "oncall")
+
+	require.NoError(t, err)
+	assert.True(t, flag.Active(ctx))
+	assert.Equal(t, "kafka incident", mode.Reason)
+	assert.Equal(t, "oncall", store.mode.SetBy)
+	assert.NotNil(t, store.mode.SetAt)
+	assert.Equal(t, 1, store.reads, "Set does not need a re-read")
+}
+
+func TestFlag_KeepsLastStateWhenStoreFails(t *testing.T) {
+	store := &memoryStore{mode: models.MaintenanceMode{Enabled: true}}
+	flag, now := newTestFlag(store)
+	ctx := context.Background()
+	require.True(t, flag.Active(ctx))
+
+	store.err = errors.New("connection refused")
+	*now = now.Add(time.Minute)
+
+	assert.True(t, flag.Active(ctx))
+}
+
+func TestFlag_SetFailure(t *testing.T) {
+	store := &memoryStore{err: errors.New("connection refused")}
+	flag, _ := newTestFlag(store)
+
+	_, err := flag.Set(context.Background(), true, "incident", "oncall")
+
+	assert.Error(t, err)
+	assert.False(t, flag.mode.Enabled)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the default Prometheus registry
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Delivery latency SLO metrics
+var (
+	SLOCompliance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_slo_compliance_ratio",
+		Help: "Share of notifications delivered or read within the SLO target over the window.",
+	}, []string{"priority", "window"})
+
+	SLOBurnRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_slo_burn_rate",
+		Help: "Rate at which the SLO error budget is consumed over the window (1 = exactly on budget).",
+	}, []string{"priority", "window"})
+
+	SLOBreached = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_slo_breached",
+		Help: "1 when the burn rate over the window exceeds its alert threshold.",
+	}, []string{"priority", "window"})
+)
+
+// Kafka producer lifecycle metrics
+var (
+	KafkaProducerRebuilds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_producer_rebuilds_total",
+		Help: "Kafka producers replaced, by reason (max_age, connection_errors).",
+	}, []string{"reason"})
+
+	KafkaProducerRebuildFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_producer_rebuild_failures_total",
+		Help: "Failed attempts to build a replacement Kafka producer, by reason.",
+	}, []string{"reason"})
+)
+
+// Consumer poll metrics
+var (
+	PollItemsReturned = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "notification_poll_items_returned",
+		Help:    "Notifications serialized per consumer poll; not-modified responses count as zero.",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100, 250, 500},
+	})
+
+	PollNotModified = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notification_poll_not_modified_total",
+		Help: "Consumer polls answered with 304 Not Modified because the user's list was unchanged.",
+	})
+)
+
+// Delivery provider failover metrics
+var (
+	DeliveryProviderFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_provider_failovers_total",
+		Help: "Providers taken out of rotation after exceeding the error rate threshold, by channel and provider.",
+	}, []string{"channel", "provider"})
+
+	DeliveryProviderFailbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_provider_failbacks_total",
+		Help: "Failed-over providers restored after a successful probe, by channel and provider.",
+	}, []string{"channel", "provider"})
+)
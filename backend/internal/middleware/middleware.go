@@ -1,9 +1,19 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"kafka-notify/internal/auth"
+	"kafka-notify/internal/config"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -30,21 +40,95 @@ func Recovery() gin.HandlerFunc {
 	return gin.Recovery()
 }
 
-// CORS returns a CORS middleware
-func CORS() gin.HandlerFunc {
+// corsAllowHeaders are the request headers browsers may send cross-origin
+const corsAllowHeaders = "Origin, Content-Type, Content-Length, Accept, Accept-Encoding, Authorization, " +
+	"X-CSRF-Token, X-Request-ID, If-None-Match, Last-Event-ID"
+
+// CORS returns a middleware that lets the browser origins allowed by cfg call
+// the API. An allowed origin is echoed back in Access-Control-Allow-Origin, so
+// credentials work with it; requests from any other origin get no CORS
+// headers, and their preflights are rejected.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	allowed := originMatcher(cfg.AllowedOrigins)
+	maxAge := ""
+	if seconds := int(cfg.MaxAge / time.Second); seconds > 0 {
+		maxAge = strconv.Itoa(seconds)
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
 			return
 		}
 
-		c.Next()
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", "Content-Length, ETag, X-Request-ID")
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+		if maxAge != "" {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// originMatcher returns a func reporting whether an origin matches one of the
+// patterns: an exact origin, scheme://*.domain for any subdomain of domain on
+// that scheme (and port), or * for any origin. Matching ignores case.
+func originMatcher(patterns []string) func(origin string) bool {
+	exact := make(map[string]bool)
+	type wildcard struct{ prefix, suffix string }
+	var wildcards []wildcard
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" {
+			return func(string) bool { return true }
+		}
+		if scheme, rest, ok := strings.Cut(pattern, "://*."); ok {
+			wildcards = append(wildcards, wildcard{prefix: scheme + "://", suffix: "." + rest})
+			continue
+		}
+		exact[pattern] = true
+	}
+
+	return func(origin string) bool {
+		origin = strings.ToLower(origin)
+		if exact[origin] {
+			return true
+		}
+		for _, w := range wildcards {
+			if len(origin) <= len(w.prefix)+len(w.suffix) ||
+				!strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+				continue
+			}
+			// The subdomain part must be a host name, so *.example.com can't be
+			// satisfied by https://evil.com/.example.com or a different port
+			sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+			if strings.Trim(sub, "abcdefghijklmnopqrstuvwxyz0123456789-.") == "" && !strings.HasPrefix(sub, ".") {
+				return true
+			}
+		}
+		return false
 	}
 }
 
@@ -62,15 +146,143 @@ func RequestID() gin.HandlerFunc {
 	}
 }
 
-// Auth middleware for authentication (placeholder)
-func Auth() gin.HandlerFunc {
+// Context keys set by Auth
+const (
+	principalKey    = "principal"
+	authDisabledKey = "auth_disabled"
+)
+
+// Auth requires a valid JWT bearer token and stores the user it names in the
+// context for AuthorizeUser. Missing or invalid tokens get a 401. A nil
+// verifier turns authentication off, which config only allows in development.
+func Auth(verifier *auth.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: Implement authentication logic
-		// For now, just pass through
+		if verifier == nil {
+			c.Set(authDisabledKey, true)
+			c.Next()
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid bearer token"})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the user Auth authenticated, if any
+func CurrentPrincipal(c *gin.Context) (*auth.Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*auth.Principal)
+	return principal, ok
+}
+
+// AuthorizeUser reports whether the request may act for userID: the token's
+// subject must be userID or the token must carry the admin role. Otherwise it
+// aborts with a 403, or a 401 when the route isn't behind Auth at all, and
+// the handler should return.
+func AuthorizeUser(c *gin.Context, userID uuid.UUID) bool {
+	if c.GetBool(authDisabledKey) {
+		return true
+	}
+
+	principal, ok := CurrentPrincipal(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return false
+	}
+	if !principal.CanActFor(userID) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed to access this user's data"})
+		return false
+	}
+	return true
+}
+
+// AdminToken restricts a route group to callers presenting the admin API token
+// as a bearer token. An empty token disables the routes entirely.
+func AdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// APIKeyHeader carries the key internal callers present to APIKeyAuth
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth restricts routes meant for other services to callers presenting
+// one of keys in the X-API-Key header. Several keys may be active at once so
+// a new key can be rolled out before the old one is removed. Rejections get a
+// 401 and are logged with the key's label, never the key. With no keys the
+// routes are open, which config only allows in development.
+func APIKeyAuth(keys []string) gin.HandlerFunc {
+	// Comparing digests keeps the comparison constant-time whatever the lengths
+	digests := make([][sha256.Size]byte, len(keys))
+	for i, key := range keys {
+		digests[i] = sha256.Sum256([]byte(key))
+	}
+
+	return func(c *gin.Context) {
+		if len(digests) == 0 {
+			c.Next()
+			return
+		}
+
+		provided := c.GetHeader(APIKeyHeader)
+		if provided == "" {
+			log.Printf("Rejected %s %s from %s: no %s header", c.Request.Method, c.FullPath(), c.ClientIP(), APIKeyHeader)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+			return
+		}
+
+		digest := sha256.Sum256([]byte(provided))
+		match := 0
+		for i := range digests {
+			match |= subtle.ConstantTimeCompare(digest[:], digests[i][:])
+		}
+		if match != 1 {
+			log.Printf("Rejected %s %s from %s: unknown API key %s", c.Request.Method, c.FullPath(), c.ClientIP(), APIKeyLabel(provided))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// APIKeyLabel identifies a key in logs without revealing it: the first 8 hex
+// digits of its SHA-256, as printed by printf %s "$KEY" | sha256sum
+func APIKeyLabel(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
 // RateLimit middleware for rate limiting (placeholder)
 func RateLimit(limit int) gin.HandlerFunc {
 	return func(c *gin.Context) {
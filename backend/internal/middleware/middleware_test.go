@@ -0,0 +1,285 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/auth"
+	"kafka-notify/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name       string
+		token      string
+		authHeader string
+		status     int
+	}{
+		{"disabled when no token configured", "", "Bearer anything", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin", AdminToken(tc.token), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.CORSConfig{
+		AllowedOrigins:   []string{"http://localhost:3000", "https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}
+
+	cases := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{"no origin header", http.MethodGet, "", false, http.StatusOK, ""},
+		{"allowed origin", http.MethodGet, "http://localhost:3000", false, http.StatusOK, "http://localhost:3000"},
+		{"origin is case-insensitive", http.MethodGet, "http://LOCALHOST:3000", false, http.StatusOK, "http://LOCALHOST:3000"},
+		{"disallowed origin", http.MethodGet, "https://evil.com", false, http.StatusOK, ""},
+		{"wildcard subdomain", http.MethodGet, "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"wildcard nested subdomain", http.MethodGet, "https://a.b.example.com", false, http.StatusOK, "https://a.b.example.com"},
+		{"wildcard excludes bare domain", http.MethodGet, "https://example.com", false, http.StatusOK, ""},
+		{"wildcard checks scheme", http.MethodGet, "http://app.example.com", false, http.StatusOK, ""},
+		{"wildcard rejects lookalike", http.MethodGet, "https://evilexample.com", false, http.StatusOK, ""},
+		{"allowed preflight", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+		{"disallowed preflight", http.MethodOptions, "https://evil.com", true, http.StatusForbidden, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(CORS(cfg))
+			router.Any("/api", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tc.method, "/api", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.allowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			if tc.allowOrigin != "" {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+			if tc.origin != "" {
+				assert.Equal(t, "Origin", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORS_PreflightHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(config.CORSConfig{AllowedOrigins: []string{"*"}, MaxAge: 12 * time.Hour}))
+	router.POST("/api", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "https://anywhere.test")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://anywhere.test", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Equal(t, "43200", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func signedToken(t *testing.T, secret string, userID uuid.UUID, role string) string {
+	t.Helper()
+	claims := jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(time.Hour).Unix()}
+	if role != "" {
+		claims["role"] = role
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verifier, err := auth.NewVerifier(config.AuthConfig{JWTSecret: "secret", AdminRole: "admin"})
+	require.NoError(t, err)
+	owner := uuid.New()
+
+	cases := []struct {
+		name       string
+		authHeader string
+		path       uuid.UUID
+		status     int
+	}{
+		{"missing header", "", owner, http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", owner, http.StatusUnauthorized},
+		{"invalid token", "Bearer not-a-token", owner, http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + signedToken(t, "other", owner, ""), owner, http.StatusUnauthorized},
+		{"own user", "Bearer " + signedToken(t, "secret", owner, ""), owner, http.StatusOK},
+		{"another user", "Bearer " + signedToken(t, "secret", owner, ""), uuid.New(), http.StatusForbidden},
+		{"admin for another user", "Bearer " + signedToken(t, "secret", owner, "admin"), uuid.New(), http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/users/:userID", Auth(verifier), func(c *gin.Context) {
+				if !AuthorizeUser(c, uuid.MustParse(c.Param("userID"))) {
+					return
+				}
+				principal, _ := CurrentPrincipal(c)
+				c.String(http.StatusOK, principal.UserID.String())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+tc.path.String(), nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, owner.String(), w.Body.String())
+			}
+			if tc.status == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestAuthorizeUser_WithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name       string
+		middleware []gin.HandlerFunc
+		status     int
+	}{
+		{"route not behind Auth", nil, http.StatusUnauthorized},
+		{"auth disabled", []gin.HandlerFunc{Auth(nil)}, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			handlers := append(tc.middleware, func(c *gin.Context) {
+				if AuthorizeUser(c, uuid.New()) {
+					c.Status(http.StatusOK)
+				}
+			})
+			router.GET("/users", handlers...)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Two active keys, as while rotating from the old one to the new one
+	keys := []string{"new-key-00000001", "old-key-00000001"}
+
+	cases := []struct {
+		name   string
+		keys   []string
+		key    string
+		status int
+		logged string
+	}{
+		{"new key", keys, "new-key-00000001", http.StatusOK, ""},
+		{"old key", keys, "old-key-00000001", http.StatusOK, ""},
+		{"invalid key", keys, "revoked-key-0001", http.StatusUnauthorized, "unknown API key " + APIKeyLabel("revoked-key-0001")},
+		{"key prefix", keys, "new-key", http.StatusUnauthorized, "unknown API key"},
+		{"missing key", keys, "", http.StatusUnauthorized, "no X-API-Key header"},
+		{"no keys configured", nil, "", http.StatusOK, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			router := gin.New()
+			router.POST("/outbox/process", APIKeyAuth(tc.keys), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/outbox/process", nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusUnauthorized {
+				assert.Contains(t, w.Body.String(), `"error"`)
+				assert.Contains(t, logs.String(), "POST /outbox/process")
+				assert.Contains(t, logs.String(), tc.logged)
+				if tc.key != "" {
+					assert.NotContains(t, logs.String(), tc.key, "keys are never logged")
+				}
+			} else {
+				assert.Empty(t, logs.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyLabel(t *testing.T) {
+	// printf %s new-key-00000001 | sha256sum | cut -c1-8
+	assert.Equal(t, "sha256:8bcd0550", APIKeyLabel("new-key-00000001"))
+	assert.NotEqual(t, APIKeyLabel("new-key-00000001"), APIKeyLabel("old-key-00000001"))
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"kafka-notify/pkg/models"
+)
+
+// emailTemplate lays out a notification as an HTML email body with its
+// attachments embedded inline. Icons sit above the title, images below the message.
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body>
+{{- range .Icons}}
+<img src="{{.URL}}" alt="{{.AltText}}"{{if .Width}} width="{{.Width}}"{{end}}{{if .Height}} height="{{.Height}}"{{end}}>
+{{- end}}
+{{- if .Title}}
+<h1>{{.Title}}</h1>
+{{- end}}
+<p>{{.Message}}</p>
+{{- range .Images}}
+<img src="{{.URL}}" alt="{{.AltText}}"{{if .Width}} width="{{.Width}}"{{end}}{{if .Height}} height="{{.Height}}"{{end}}>
+{{- end}}
+</body>
+</html>
+`))
+
+type emailData struct {
+	Title   string
+	Message string
+	Icons   []models.Attachment
+	Images  []models.Attachment
+}
+
+// EmailHTML renders a notification as an HTML email body
+func EmailHTML(n *models.Notification) (string, error) {
+	data := emailData{Message: n.Message}
+	if n.Title != nil {
+		data.Title = *n.Title
+	}
+	for _, a := range n.Attachments {
+		switch a.Kind {
+		case models.AttachmentIcon:
+			data.Icons = append(data.Icons, a)
+		case models.AttachmentImage:
+			data.Images = append(data.Images, a)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := emailTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render email: %w", err)
+	}
+	return buf.String(), nil
+}
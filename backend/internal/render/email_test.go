@@ -0,0 +1,41 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailHTML_EmbedsAttachments(t *testing.T) {
+	title := "Badge unlocked"
+	n := &models.Notification{
+		Title:   &title,
+		Message: "You earned the <Week Warrior> badge",
+		Attachments: models.Attachments{
+			{Kind: models.AttachmentImage, URL: "https://cdn.example.com/badges/week.png", AltText: "Week Warrior badge", Width: 128, Height: 128},
+			{Kind: models.AttachmentIcon, URL: "https://cdn.example.com/icons/trophy.svg"},
+		},
+	}
+
+	body, err := EmailHTML(n)
+	require.NoError(t, err)
+
+	assert.Contains(t, body, `<img src="https://cdn.example.com/badges/week.png" alt="Week Warrior badge" width="128" height="128">`)
+	assert.Contains(t, body, `<img src="https://cdn.example.com/icons/trophy.svg" alt="">`)
+	assert.Contains(t, body, "You earned the &lt;Week Warrior&gt; badge")
+	assert.Less(t, strings.Index(body, "trophy.svg"), strings.Index(body, "<h1>"), "icons render above the title")
+	assert.Greater(t, strings.Index(body, "week.png"), strings.Index(body, "<p>"), "images render below the message")
+}
+
+func TestEmailHTML_NoAttachments(t *testing.T) {
+	body, err := EmailHTML(&models.Notification{Message: "Time to practice"})
+	require.NoError(t, err)
+
+	assert.NotContains(t, body, "<img")
+	assert.NotContains(t, body, "<h1>")
+	assert.Contains(t, body, "<p>Time to practice</p>")
+}
@@ -0,0 +1,222 @@
+package schema
+
+// Expected returns the schema the application code relies on. It mirrors the
+// migrations in backend/migrations and must be updated alongside them.
+func Expected() *Schema {
+	timestamps := map[string]string{
+		"created_at": "timestamptz",
+		"updated_at": "timestamptz",
+	}
+
+	withTimestamps := func(columns map[string]string) map[string]string {
+		for name, udt := range timestamps {
+			columns[name] = udt
+		}
+		return columns
+	}
+
+	return &Schema{
+		Tables: map[string]map[string]string{
+			"users": withTimestamps(map[string]string{
+				"user_id":  "uuid",
+				"name":     "varchar",
+				"email":    "varchar",
+				"total_xp": "int4",
+			}),
+			"user_profiles": withTimestamps(map[string]string{
+				"id":         "uuid",
+				"user_id":    "uuid",
+				"full_name":  "varchar",
+				"avatar_url": "text",
+				"bio":        "text",
+				"username":   "varchar",
+				"location":   "varchar",
+				"website":    "text",
+				"skills":     "_text",
+				"role":       "varchar",
+			}),
+			"notification_templates": {
+				"id":                  "int8",
+				"type":                "notification_type",
+				"channel":             "notification_channel",
+				"title":               "varchar",
+				"body":                "text",
+				"locale":              "varchar",
+				"priority":            "priority_level",
+				"is_active":           "bool",
+				"version":             "int4",
+				"created_at":          "timestamptz",
+				"default_attachments": "jsonb",
+			},
+			"notifications": {
+				"id":                  "uuid",
+				"user_id":             "uuid",
+				"type":                "notification_type",
+				"channel":             "notification_channel",
+				"priority":            "priority_level",
+				"template_id":         "int8",
+				"title":               "varchar",
+				"message":             "text",
+				"metadata":            "jsonb",
+				"attachments":         "jsonb",
+				"dedupe_key":          "varchar",
+				"dedupe_active":       "bool",
+				"created_at":          "timestamptz",
+				"scheduled_for":       "timestamptz",
+				"dispatched_at":       "timestamptz",
+				"sent_at":             "timestamptz",
+				"delivered_at":        "timestamptz",
+				"read_at":             "timestamptz",
+				"status":              "delivery_status",
+				"last_attempt_no":     "int4",
+				"last_attempt_status": "delivery_status",
+				"last_attempt_at":     "timestamptz",
+			},
+			"user_notification_preferences": withTimestamps(map[string]string{
+				"id":                "int8",
+				"user_id":           "uuid",
+				"type":              "notification_type",
+				"channel":           "notification_channel",
+				"enabled":           "bool",
+				"quiet_hours_start": "varchar",
+				"quiet_hours_end":   "varchar",
+				"max_per_day":       "int4",
+				"last_sent_at":      "timestamptz",
+				"metadata":          "jsonb",
+			}),
+			"notification_delivery_attempts": {
+				"id":                  "int8",
+				"notification_id":     "uuid",
+				"attempt_no":          "int4",
+				"status":              "delivery_status",
+				"error_code":          "varchar",
+				"error_message":       "text",
+				"provider_message_id": "varchar",
+				"provider":            "varchar",
+				"latency_ms":          "int4",
+				"created_at":          "timestamptz",
+			},
+			"outbox_notifications": {
+				"id":              "int8",
+				"notification_id": "uuid",
+				"topic":           "varchar",
+				"payload":         "jsonb",
+				"published":       "bool",
+				"created_at":      "timestamptz",
+				"published_at":    "timestamptz",
+				"claimed_by":      "varchar",
+				"claimed_at":      "timestamptz",
+				"attempts":        "int4",
+				"last_error":      "text",
+				"dead":            "bool",
+				"next_attempt_at": "timestamptz",
+			},
+			"idempotency_keys": {
+				"user_id":         "uuid",
+				"idempotency_key": "varchar",
+				"notification_id": "uuid",
+				"created_at":      "timestamptz",
+			},
+			"system_settings": {
+				"key":        "varchar",
+				"value":      "jsonb",
+				"updated_at": "timestamptz",
+			},
+			"user_devices": withTimestamps(map[string]string{
+				"id":       "int8",
+				"user_id":  "uuid",
+				"token":    "text",
+				"platform": "varchar",
+				"active":   "bool",
+			}),
+			"user_webhooks": withTimestamps(map[string]string{
+				"user_id": "uuid",
+				"url":     "text",
+				"secret":  "text",
+			}),
+			"user_goals": withTimestamps(map[string]string{
+				"id":         "int8",
+				"user_id":    "uuid",
+				"goal_type":  "varchar",
+				"target":     "int4",
+				"week_start": "date",
+			}),
+			"announcements": withTimestamps(map[string]string{
+				"id":                    "int8",
+				"title":                 "varchar",
+				"message":               "text",
+				"metadata":              "jsonb",
+				"segment":               "varchar",
+				"segment_value":         "int4",
+				"status":                "varchar",
+				"last_user_id":          "uuid",
+				"notifications_created": "int4",
+				"started_at":            "timestamptz",
+				"completed_at":          "timestamptz",
+			}),
+			"scheduler_runs": {
+				"id":                    "int8",
+				"job_name":              "varchar",
+				"started_at":            "timestamptz",
+				"finished_at":           "timestamptz",
+				"users_processed":       "int4",
+				"notifications_created": "int4",
+				"error":                 "text",
+			},
+			"user_engagement_streaks": withTimestamps(map[string]string{
+				"id":                      "int8",
+				"user_id":                 "uuid",
+				"streak_type":             "varchar",
+				"current_streak":          "int4",
+				"longest_streak":          "int4",
+				"last_activity_date":      "date",
+				"streak_start_date":       "date",
+				"total_activities":        "int4",
+				"timezone":                "varchar",
+				"typical_hour":            "int2",
+				"typical_hour_confidence": "float4",
+			}),
+		},
+		Indexes: map[string]string{
+			"idx_notifications_user_id":                "notifications",
+			"idx_notifications_type":                   "notifications",
+			"idx_notifications_status":                 "notifications",
+			"idx_notifications_scheduled_for":          "notifications",
+			"idx_notifications_created_at":             "notifications",
+			"idx_notifications_practice_events":        "notifications",
+			"idx_notifications_user_dedupe_key":        "notifications",
+			"idx_notifications_user_dedupe_key_active": "notifications",
+			"idx_notifications_user_unread":            "notifications",
+			"idx_user_preferences_user_id":             "user_notification_preferences",
+			"idx_user_preferences_type_channel":        "user_notification_preferences",
+			"idx_outbox_notifications_published":       "outbox_notifications",
+			"idx_outbox_notifications_topic":           "outbox_notifications",
+			"idx_outbox_notifications_unpublished":     "outbox_notifications",
+			"idx_outbox_notifications_published_at":    "outbox_notifications",
+			"idx_outbox_notifications_dead":            "outbox_notifications",
+			"idx_outbox_notifications_notification_id": "outbox_notifications",
+			"idx_idempotency_keys_created_at":          "idempotency_keys",
+			"idx_user_devices_user_active":             "user_devices",
+			"idx_scheduler_runs_job_started":           "scheduler_runs",
+			"idx_user_goals_type_week":                 "user_goals",
+			"idx_announcements_unfinished":             "announcements",
+			"idx_engagement_streaks_user_id":           "user_engagement_streaks",
+			"idx_engagement_streaks_streak_type":       "user_engagement_streaks",
+
+			// Backs UNIQUE(user_id, type, channel), the UpdateUserPreferences conflict target
+			"user_notification_preferences_user_id_type_channel_key": "user_notification_preferences",
+			// Backs UNIQUE(user_id, goal_type, week_start), the UpsertGoal conflict target
+			"user_goals_user_id_goal_type_week_start_key": "user_goals",
+		},
+		Enums: map[string][]string{
+			"notification_type": {
+				"daily_reminder", "streak_reminder", "last_chance_alert", "achievement_unlock",
+				"xp_goal_reminder", "league_update", "we_miss_you", "event_notification",
+				"new_course", "practice_needed", "weekly_recap", "preferences_updated",
+			},
+			"notification_channel": {"in_app", "push", "email", "sms", "webhook"},
+			"delivery_status":      {"queued", "sent", "delivered", "failed", "suppressed", "read", "cancelled"},
+			"priority_level":       {"low", "medium", "high", "urgent"},
+		},
+	}
+}
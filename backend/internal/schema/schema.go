@@ -0,0 +1,202 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// FindingKind identifies the type of schema drift
+type FindingKind string
+
+const (
+	MissingTable     FindingKind = "missing_table"
+	MissingColumn    FindingKind = "missing_column"
+	WrongType        FindingKind = "wrong_type"
+	MissingIndex     FindingKind = "missing_index"
+	MissingEnum      FindingKind = "missing_enum"
+	MissingEnumValue FindingKind = "missing_enum_value"
+)
+
+// Schema is a minimal description of tables, indexes and enum types.
+// Column types are Postgres udt names (e.g. "int8", "timestamptz", or the enum name).
+type Schema struct {
+	Tables  map[string]map[string]string `json:"tables"`
+	Indexes map[string]string            `json:"indexes"`
+	Enums   map[string][]string          `json:"enums"`
+}
+
+// Finding describes a single difference between the expected and actual schema
+type Finding struct {
+	Kind     FindingKind `json:"kind"`
+	Table    string      `json:"table,omitempty"`
+	Column   string      `json:"column,omitempty"`
+	Index    string      `json:"index,omitempty"`
+	Enum     string      `json:"enum,omitempty"`
+	Expected string      `json:"expected,omitempty"`
+	Actual   string      `json:"actual,omitempty"`
+}
+
+// Report is the machine-readable result of a schema verification
+type Report struct {
+	OK       bool      `json:"ok"`
+	Findings []Finding `json:"findings"`
+}
+
+// Diff compares an actual schema against the expected one and returns the drift.
+// Objects that exist only in the actual schema are not reported.
+func Diff(expected, actual *Schema) []Finding {
+	findings := []Finding{}
+
+	for _, table := range sortedKeys(expected.Tables) {
+		actualColumns, ok := actual.Tables[table]
+		if !ok {
+			findings = append(findings, Finding{Kind: MissingTable, Table: table})
+			continue
+		}
+
+		expectedColumns := expected.Tables[table]
+		for _, column := range sortedKeys(expectedColumns) {
+			actualType, ok := actualColumns[column]
+			if !ok {
+				findings = append(findings, Finding{
+					Kind: MissingColumn, Table: table, Column: column, Expected: expectedColumns[column],
+				})
+				continue
+			}
+			if actualType != expectedColumns[column] {
+				findings = append(findings, Finding{
+					Kind: WrongType, Table: table, Column: column,
+					Expected: expectedColumns[column], Actual: actualType,
+				})
+			}
+		}
+	}
+
+	for _, index := range sortedKeys(expected.Indexes) {
+		if _, ok := actual.Indexes[index]; !ok {
+			findings = append(findings, Finding{Kind: MissingIndex, Index: index, Table: expected.Indexes[index]})
+		}
+	}
+
+	for _, enum := range sortedKeys(expected.Enums) {
+		actualValues, ok := actual.Enums[enum]
+		if !ok {
+			findings = append(findings, Finding{Kind: MissingEnum, Enum: enum})
+			continue
+		}
+
+		present := make(map[string]bool, len(actualValues))
+		for _, v := range actualValues {
+			present[v] = true
+		}
+		for _, v := range expected.Enums[enum] {
+			if !present[v] {
+				findings = append(findings, Finding{Kind: MissingEnumValue, Enum: enum, Expected: v})
+			}
+		}
+	}
+
+	return findings
+}
+
+// Introspect reads the public schema of a Postgres database
+func Introspect(ctx context.Context, db *sql.DB) (*Schema, error) {
+	s := &Schema{
+		Tables:  make(map[string]map[string]string),
+		Indexes: make(map[string]string),
+		Enums:   make(map[string][]string),
+	}
+
+	columnRows, err := db.QueryContext(ctx, `
+		SELECT table_name, column_name, udt_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer columnRows.Close()
+
+	for columnRows.Next() {
+		var table, column, udt string
+		if err := columnRows.Scan(&table, &column, &udt); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		if s.Tables[table] == nil {
+			s.Tables[table] = make(map[string]string)
+		}
+		s.Tables[table][column] = udt
+	}
+	if err := columnRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating columns: %w", err)
+	}
+
+	indexRows, err := db.QueryContext(ctx, `
+		SELECT indexname, tablename
+		FROM pg_indexes
+		WHERE schemaname = 'public'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var index, table string
+		if err := indexRows.Scan(&index, &table); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		s.Indexes[index] = table
+	}
+	if err := indexRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating indexes: %w", err)
+	}
+
+	enumRows, err := db.QueryContext(ctx, `
+		SELECT t.typname, e.enumlabel
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = 'public'
+		ORDER BY t.typname, e.enumsortorder
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enums: %w", err)
+	}
+	defer enumRows.Close()
+
+	for enumRows.Next() {
+		var enum, label string
+		if err := enumRows.Scan(&enum, &label); err != nil {
+			return nil, fmt.Errorf("failed to scan enum value: %w", err)
+		}
+		s.Enums[enum] = append(s.Enums[enum], label)
+	}
+	if err := enumRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating enum values: %w", err)
+	}
+
+	return s, nil
+}
+
+// Verify introspects the database and reports drift from the expected schema
+func Verify(ctx context.Context, db *sql.DB) (*Report, error) {
+	actual, err := Introspect(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := Diff(Expected(), actual)
+	return &Report{OK: len(findings) == 0, Findings: findings}, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
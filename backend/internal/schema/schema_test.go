@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// driftedSchema returns the expected schema with one instance of every drift kind applied
+func driftedSchema() *Schema {
+	s := Expected()
+
+	delete(s.Tables, "user_profiles")
+	delete(s.Tables["notifications"], "dedupe_key")
+	s.Tables["outbox_notifications"]["payload"] = "json"
+	delete(s.Indexes, "idx_outbox_notifications_published")
+	delete(s.Enums, "priority_level")
+	s.Enums["delivery_status"] = []string{"queued", "sent", "delivered", "failed", "read", "cancelled"}
+
+	return s
+}
+
+func TestDiff_NoDrift(t *testing.T) {
+	assert.Empty(t, Diff(Expected(), Expected()))
+}
+
+func TestDiff_DetectsEachFindingKind(t *testing.T) {
+	findings := Diff(Expected(), driftedSchema())
+
+	assert.ElementsMatch(t, []Finding{
+		{Kind: MissingTable, Table: "user_profiles"},
+		{Kind: MissingColumn, Table: "notifications", Column: "dedupe_key", Expected: "varchar"},
+		{Kind: WrongType, Table: "outbox_notifications", Column: "payload", Expected: "jsonb", Actual: "json"},
+		{Kind: MissingIndex, Index: "idx_outbox_notifications_published", Table: "outbox_notifications"},
+		{Kind: MissingEnum, Enum: "priority_level"},
+		{Kind: MissingEnumValue, Enum: "delivery_status", Expected: "suppressed"},
+	}, findings)
+}
+
+func TestDiff_IgnoresExtraObjects(t *testing.T) {
+	actual := Expected()
+	actual.Tables["legacy_notifications"] = map[string]string{"id": "int8"}
+	actual.Tables["notifications"]["legacy_flag"] = "bool"
+	actual.Indexes["idx_legacy"] = "legacy_notifications"
+
+	assert.Empty(t, Diff(Expected(), actual))
+}
+
+func TestVerify_ReportsDriftFromDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	drifted := driftedSchema()
+
+	columns := sqlmock.NewRows([]string{"table_name", "column_name", "udt_name"})
+	for table, cols := range drifted.Tables {
+		for column, udt := range cols {
+			columns.AddRow(table, column, udt)
+		}
+	}
+	indexes := sqlmock.NewRows([]string{"indexname", "tablename"})
+	for index, table := range drifted.Indexes {
+		indexes.AddRow(index, table)
+	}
+	enums := sqlmock.NewRows([]string{"typname", "enumlabel"})
+	for enum, values := range drifted.Enums {
+		for _, v := range values {
+			enums.AddRow(enum, v)
+		}
+	}
+
+	mock.ExpectQuery("FROM information_schema.columns").WillReturnRows(columns)
+	mock.ExpectQuery("FROM pg_indexes").WillReturnRows(indexes)
+	mock.ExpectQuery("FROM pg_type").WillReturnRows(enums)
+
+	report, err := Verify(context.Background(), db)
+
+	require.NoError(t, err)
+	assert.False(t, report.OK)
+	assert.Len(t, report.Findings, 6)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
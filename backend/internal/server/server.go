@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -21,10 +22,16 @@ type Server struct {
 	router     *gin.Engine
 	httpServer *http.Server
 	stopChan   chan os.Signal
+
+	// healthDetails adds named fields to the /health response
+	healthDetails map[string]func(ctx context.Context) interface{}
+	// shutdownHooks run in order once the HTTP server has stopped
+	shutdownHooks []func()
 }
 
-// NewServer creates a new HTTP server
-func NewServer(cfg *config.ServerConfig) *Server {
+// NewServer creates a new HTTP server answering CORS requests from the
+// origins cors allows
+func NewServer(cfg *config.ServerConfig, cors config.CORSConfig) *Server {
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -35,13 +42,14 @@ func NewServer(cfg *config.ServerConfig) *Server {
 	// Add middleware
 	router.Use(middleware.Logger())
 	router.Use(middleware.Recovery())
-	router.Use(middleware.CORS())
+	router.Use(middleware.CORS(cors))
 	router.Use(middleware.RequestID())
 
 	server := &Server{
-		config:   cfg,
-		router:   router,
-		stopChan: make(chan os.Signal, 1),
+		config:        cfg,
+		router:        router,
+		stopChan:      make(chan os.Signal, 1),
+		healthDetails: make(map[string]func(ctx context.Context) interface{}),
 	}
 
 	// Setup health check route
@@ -58,24 +66,68 @@ func (s *Server) GetRouter() *gin.Engine {
 // setupHealthCheck sets up the health check endpoint
 func (s *Server) setupHealthCheck() {
 	s.router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
+		body := gin.H{
 			"status":    "healthy",
 			"timestamp": time.Now().UTC(),
 			"service":   "notification-service",
-		})
+		}
+		for name, detail := range s.healthDetails {
+			body[name] = detail(c.Request.Context())
+		}
+		c.JSON(http.StatusOK, body)
 	})
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	// Create HTTP server
-	s.httpServer = &http.Server{
+// AddHealthDetail reports the value returned by detail under name in /health
+func (s *Server) AddHealthDetail(name string, detail func(ctx context.Context) interface{}) {
+	s.healthDetails[name] = detail
+}
+
+// OnShutdown registers fn to run during Shutdown, after in-flight requests have
+// drained, so background workers can be stopped before the process exits
+func (s *Server) OnShutdown(fn func()) {
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// newHTTPServer creates the HTTP server from the configuration
+func (s *Server) newHTTPServer() *http.Server {
+	return &http.Server{
 		Addr:         s.config.Port,
 		Handler:      s.router,
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 		IdleTimeout:  s.config.IdleTimeout,
 	}
+}
+
+// Run serves HTTP until ctx ends and then shuts the server down, for
+// processes that handle signals themselves. It returns early if the server
+// cannot listen.
+func (s *Server) Run(ctx context.Context) error {
+	s.httpServer = s.newHTTPServer()
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.Printf("Starting HTTP server on port %s", s.config.Port)
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- err
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server...")
+	return s.Shutdown()
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
+	// Create HTTP server
+	s.httpServer = s.newHTTPServer()
 
 	// Start server in goroutine
 	go func() {
@@ -98,8 +150,15 @@ func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := s.httpServer.Shutdown(ctx); err != nil {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	for _, hook := range s.shutdownHooks {
+		hook()
+	}
+	if err != nil {
 		return err
 	}
 
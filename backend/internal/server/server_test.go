@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_RunsHooksInOrderAfterServerStops(t *testing.T) {
+	s := NewServer(&config.ServerConfig{Port: "127.0.0.1:0"}, config.CORSConfig{})
+	s.httpServer = &http.Server{Addr: s.config.Port, Handler: s.router, ReadHeaderTimeout: time.Second}
+
+	var order []string
+	s.OnShutdown(func() { order = append(order, "outbox") })
+	s.OnShutdown(func() { order = append(order, "purger") })
+
+	require.NoError(t, s.Shutdown())
+
+	assert.Equal(t, []string{"outbox", "purger"}, order)
+}
+
+func TestRun_ServesUntilContextEnds(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s := NewServer(&config.ServerConfig{Port: addr}, config.CORSConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/health")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after ctx ended")
+	}
+}
+
+func TestRun_ReturnsListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := NewServer(&config.ServerConfig{Port: listener.Addr().String()}, config.CORSConfig{})
+
+	err = s.Run(context.Background())
+
+	assert.ErrorContains(t, err, "failed to start server")
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotificationNotCancellable is returned when cancelling a notification that
+// is no longer queued
+var ErrNotificationNotCancellable = apperr.New(apperr.ErrConflict, "notification is no longer queued")
+
+// CancelNotification withdraws a queued notification before it is sent. It is
+// marked cancelled and its unpublished outbox entry removed together, so the
+// scheduled dispatcher no longer releases it and the outbox processor never
+// picks it up. One already claimed for publishing can no longer be cancelled.
+func (s *notificationService) CancelNotification(ctx context.Context, notificationID uuid.UUID) (*models.Notification, error) {
+	notification, err := s.repository.GetNotificationByID(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Status != models.StatusQueued {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotificationNotCancellable, notificationID, notification.Status)
+	}
+
+	cancelled, err := s.repository.CancelQueuedNotification(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		// Published, being published or otherwise moved on since it was read
+		return nil, fmt.Errorf("%w: %s", ErrNotificationNotCancellable, notificationID)
+	}
+
+	notification.Status = models.StatusCancelled
+	return notification, nil
+}
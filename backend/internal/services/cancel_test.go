@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelNotification_Queued(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), UserID: uuid.New(), Status: models.StatusQueued}
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+	mockRepo.On("CancelQueuedNotification", ctx, notification.ID).Return(true, nil)
+
+	// Act
+	cancelled, err := service.CancelNotification(ctx, notification.ID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, cancelled.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCancelNotification_NotQueued(t *testing.T) {
+	statuses := []models.DeliveryStatus{
+		models.StatusSent,
+		models.StatusDelivered,
+		models.StatusRead,
+		models.StatusFailed,
+		models.StatusSuppressed,
+		models.StatusCancelled,
+	}
+	for _, status := range statuses {
+		t.Run(string(status), func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+			ctx := context.Background()
+
+			notification := &models.Notification{ID: uuid.New(), Status: status}
+			mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+
+			// Act
+			_, err := service.CancelNotification(ctx, notification.ID)
+
+			// Assert
+			assert.ErrorIs(t, err, ErrNotificationNotCancellable)
+			mockRepo.AssertNotCalled(t, "CancelQueuedNotification", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCancelNotification_PublishedMeanwhile(t *testing.T) {
+	// Arrange: queued when read, but sent before the conditional update
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), Status: models.StatusQueued}
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+	mockRepo.On("CancelQueuedNotification", ctx, notification.ID).Return(false, nil)
+
+	// Act
+	_, err := service.CancelNotification(ctx, notification.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotificationNotCancellable)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCancelNotification_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	missing := uuid.New()
+	mockRepo.On("GetNotificationByID", ctx, missing).
+		Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+
+	// Act
+	_, err := service.CancelNotification(ctx, missing)
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrNotificationNotFound)
+	mockRepo.AssertExpectations(t)
+}
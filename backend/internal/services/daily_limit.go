@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// startOfLocalDay returns midnight of now's date in loc
+func startOfLocalDay(now time.Time, loc *time.Location) time.Time {
+	year, month, day := now.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// DailyCounter counts a user's notifications of a type created since a time,
+// leaving out suppressed ones. The repository counts stored notifications; a
+// decision replay counts the ones it has let through so far.
+type DailyCounter interface {
+	CountNotificationsForUserTypeSince(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, since time.Time) (int, error)
+}
+
+// ApplyDailyLimit enforces the user's max_per_day preference on a notification
+// about to be created at now. When the user already got MaxPerDay notifications
+// of its type since their local midnight, the notification is marked suppressed
+// with the rule and reason in its metadata and true is returned. A nil
+// preference or MaxPerDay means unlimited; a failed count lets it through.
+func ApplyDailyLimit(ctx context.Context, counter DailyCounter, n *models.Notification, pref *models.UserNotificationPreferences, loc *time.Location, now time.Time) bool {
+	if pref == nil || pref.MaxPerDay == nil {
+		return false
+	}
+
+	sentToday, err := counter.CountNotificationsForUserTypeSince(ctx, n.UserID, n.Type, startOfLocalDay(now, loc))
+	if err != nil {
+		log.Printf("Failed to count today's %s notifications for user %s: %v", n.Type, n.UserID, err)
+		return false
+	}
+	if sentToday < *pref.MaxPerDay {
+		return false
+	}
+
+	if n.Metadata == nil {
+		n.Metadata = models.JSONMap{}
+	}
+	n.Metadata["decision_rule"] = RuleMaxPerDay
+	n.Metadata["suppressed_reason"] = fmt.Sprintf("%d of %d allowed today already sent", sentToday, *pref.MaxPerDay)
+	n.Status = models.StatusSuppressed
+	return true
+}
+
+// RecordPreferenceSent bumps the preference's last_sent_at after a notification
+// is queued; failures are only logged since the notification is already stored
+func RecordPreferenceSent(ctx context.Context, repo repository.NotificationRepository, pref *models.UserNotificationPreferences, now time.Time) {
+	if pref == nil {
+		return
+	}
+	if err := repo.MarkPreferenceSent(ctx, pref.UserID, pref.Type, pref.Channel, now); err != nil {
+		log.Printf("Failed to record last sent time for user %s: %v", pref.UserID, err)
+		return
+	}
+	pref.LastSentAt = &now
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitedNotification() *models.Notification {
+	return &models.Notification{
+		ID:      uuid.New(),
+		UserID:  uuid.New(),
+		Type:    models.StreakReminder,
+		Channel: models.ChannelPush,
+		Status:  models.StatusQueued,
+	}
+}
+
+func TestApplyDailyLimit(t *testing.T) {
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	midnight := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		maxPerDay  *int
+		sentToday  int
+		countErr   error
+		suppressed bool
+	}{
+		{name: "under the limit", maxPerDay: intPtr(3), sentToday: 2},
+		{name: "limit reached", maxPerDay: intPtr(3), sentToday: 3, suppressed: true},
+		{name: "zero allows none", maxPerDay: intPtr(0), sentToday: 0, suppressed: true},
+		{name: "count failure lets it through", maxPerDay: intPtr(1), countErr: errors.New("db down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			n := newLimitedNotification()
+			pref := &models.UserNotificationPreferences{UserID: n.UserID, Type: n.Type, Channel: n.Channel, Enabled: true, MaxPerDay: tt.maxPerDay}
+			mockRepo := new(MockNotificationRepository)
+			mockRepo.On("CountNotificationsForUserTypeSince", mock.Anything, n.UserID, n.Type, midnight).Return(tt.sentToday, tt.countErr)
+
+			// Act
+			suppressed := ApplyDailyLimit(context.Background(), mockRepo, n, pref, time.UTC, now)
+
+			// Assert
+			assert.Equal(t, tt.suppressed, suppressed)
+			if tt.suppressed {
+				assert.Equal(t, models.StatusSuppressed, n.Status)
+				assert.Equal(t, RuleMaxPerDay, n.Metadata["decision_rule"])
+				assert.Contains(t, n.Metadata["suppressed_reason"], "allowed today already sent")
+			} else {
+				assert.Equal(t, models.StatusQueued, n.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApplyDailyLimit_UnlimitedSkipsCount(t *testing.T) {
+	n := newLimitedNotification()
+	mockRepo := new(MockNotificationRepository)
+	now := time.Now()
+
+	assert.False(t, ApplyDailyLimit(context.Background(), mockRepo, n, nil, time.UTC, now))
+	assert.False(t, ApplyDailyLimit(context.Background(), mockRepo, n, &models.UserNotificationPreferences{Enabled: true}, time.UTC, now))
+	mockRepo.AssertNotCalled(t, "CountNotificationsForUserTypeSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyDailyLimit_CountsFromLocalMidnight(t *testing.T) {
+	// Arrange: 03:30 UTC on the 12th is still the 11th in New York (UTC-4)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	now := time.Date(2024, 3, 12, 3, 30, 0, 0, time.UTC)
+
+	n := newLimitedNotification()
+	pref := &models.UserNotificationPreferences{UserID: n.UserID, Type: n.Type, Channel: n.Channel, Enabled: true, MaxPerDay: intPtr(1)}
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("CountNotificationsForUserTypeSince", mock.Anything, n.UserID, n.Type, mock.MatchedBy(func(since time.Time) bool {
+		return since.Equal(time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC))
+	})).Return(0, nil)
+
+	// Act
+	suppressed := ApplyDailyLimit(context.Background(), mockRepo, n, pref, newYork, now)
+
+	// Assert
+	assert.False(t, suppressed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_SuppressedAtDailyLimit(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	req := &models.CreateNotificationRequest{
+		UserID:   uuid.New(),
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelPush,
+		Priority: models.PriorityMedium,
+		Message:  "Keep your streak going",
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: req.Type, Channel: req.Channel, Enabled: true, MaxPerDay: intPtr(2)},
+	}, nil)
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("CountNotificationsForUserTypeSince", mock.Anything, req.UserID, req.Type, time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)).Return(2, nil)
+	mockRepo.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.Status == models.StatusSuppressed
+	})).Return(nil)
+
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: stored for the record, never published, and last_sent_at untouched
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuppressed, notification.Status)
+	assert.Equal(t, "2 of 2 allowed today already sent", notification.Metadata["suppressed_reason"])
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkPreferenceSent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_SuppressedWhenOptedOut(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	req := &models.CreateNotificationRequest{
+		UserID:   uuid.New(),
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelPush,
+		Priority: models.PriorityMedium,
+		Message:  "Keep your streak going",
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: req.Type, Channel: req.Channel, Enabled: false},
+	}, nil)
+	mockRepo.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.Status == models.StatusSuppressed
+	})).Return(nil)
+
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: stored for the record, never published, and last_sent_at untouched
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuppressed, notification.Status)
+	assert.Equal(t, RulePreferenceDisabled, notification.Metadata["decision_rule"])
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkPreferenceSent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_UnderDailyLimitRecordsLastSent(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	req := &models.CreateNotificationRequest{
+		UserID:   uuid.New(),
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelPush,
+		Priority: models.PriorityMedium,
+		Message:  "Keep your streak going",
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: req.Type, Channel: req.Channel, Enabled: true, MaxPerDay: intPtr(2)},
+	}, nil)
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("CountNotificationsForUserTypeSince", mock.Anything, req.UserID, req.Type, mock.AnythingOfType("time.Time")).Return(1, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+	mockRepo.On("MarkPreferenceSent", mock.Anything, req.UserID, req.Type, req.Channel, now).Return(nil)
+
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, notification.Status)
+	mockRepo.AssertExpectations(t)
+}
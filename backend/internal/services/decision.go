@@ -0,0 +1,103 @@
+package services
+
+import (
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+// DecisionOutcome is what the delivery policy does with a notification
+type DecisionOutcome string
+
+const (
+	DecisionSend     DecisionOutcome = "send"
+	DecisionDefer    DecisionOutcome = "defer"
+	DecisionSuppress DecisionOutcome = "suppress"
+)
+
+// Rules a decision can be attributed to
+const (
+	RuleDefault            = "default"
+	RulePreferenceDisabled = "preference_disabled"
+	RuleQuietHours         = "quiet_hours"
+	RuleMaxPerDay          = "max_per_day"
+	// RuleUnknown is used for historical decisions that recorded no rule
+	RuleUnknown = "unknown"
+)
+
+// optOutExempt lists notification types users cannot disable, such as account
+// security confirmations; quiet hours and daily limits still apply to them
+var optOutExempt = map[models.NotificationType]bool{
+	models.PreferencesUpdated: true,
+}
+
+// Decision is the outcome of evaluating a notification and the rule that decided it
+type Decision struct {
+	Outcome DecisionOutcome `json:"outcome"`
+	Rule    string          `json:"rule"`
+	Reason  string          `json:"reason,omitempty"`
+}
+
+// FindPreference returns the preference for a type and channel, or nil
+func FindPreference(prefs []models.UserNotificationPreferences, notificationType models.NotificationType, channel models.NotificationChannel) *models.UserNotificationPreferences {
+	for i := range prefs {
+		if prefs[i].Type == notificationType && prefs[i].Channel == channel {
+			return &prefs[i]
+		}
+	}
+	return nil
+}
+
+// quietHoursEnd returns when the "HH:MM" quiet window [start, end) containing
+// the local time ends, in the same location, or false when the time is outside
+// the window. Windows wrap around midnight when end is before start;
+// unparseable bounds disable the window.
+func quietHoursEnd(local time.Time, start, end string) (time.Time, bool) {
+	startMin, ok := parseClock(start)
+	if !ok {
+		return time.Time{}, false
+	}
+	endMin, ok := parseClock(end)
+	if !ok || startMin == endMin {
+		return time.Time{}, false
+	}
+
+	now := local.Hour()*60 + local.Minute()
+	year, month, day := local.Date()
+	switch {
+	case startMin < endMin && now >= startMin && now < endMin,
+		startMin > endMin && now < endMin:
+		// Ends later today
+	case startMin > endMin && now >= startMin:
+		// Started tonight and ends tomorrow morning
+		day++
+	default:
+		return time.Time{}, false
+	}
+	return time.Date(year, month, day, endMin/60, endMin%60, 0, 0, local.Location()), true
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// originalDecision reconstructs the decision recorded for a stored notification.
+// Suppressed and deferred rows carry the deciding rule in metadata["decision_rule"];
+// older suppressed rows may not.
+func originalDecision(n *models.Notification) Decision {
+	rule, _ := n.Metadata["decision_rule"].(string)
+	switch {
+	case n.Status == models.StatusSuppressed && rule == "":
+		return Decision{Outcome: DecisionSuppress, Rule: RuleUnknown}
+	case n.Status == models.StatusSuppressed:
+		return Decision{Outcome: DecisionSuppress, Rule: rule}
+	case rule == RuleQuietHours:
+		return Decision{Outcome: DecisionDefer, Rule: RuleQuietHours}
+	}
+	return Decision{Outcome: DecisionSend, Rule: RuleDefault}
+}
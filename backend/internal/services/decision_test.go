@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestDeliveryPolicyPlan_Decision(t *testing.T) {
+	at := time.Date(2024, 3, 12, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		pref      *models.UserNotificationPreferences
+		loc       *time.Location
+		sentToday int
+		outcome   DecisionOutcome
+		rule      string
+	}{
+		{name: "no preference", outcome: DecisionSend, rule: RuleDefault},
+		{name: "disabled", pref: &models.UserNotificationPreferences{Enabled: false}, outcome: DecisionSuppress, rule: RulePreferenceDisabled},
+		{
+			name:    "quiet hours wrap midnight",
+			pref:    &models.UserNotificationPreferences{Enabled: true, QuietHoursStart: strPtr("22:00"), QuietHoursEnd: strPtr("07:00")},
+			outcome: DecisionDefer, rule: RuleQuietHours,
+		},
+		{
+			name:    "quiet hours in user's timezone",
+			pref:    &models.UserNotificationPreferences{Enabled: true, QuietHoursStart: strPtr("22:00"), QuietHoursEnd: strPtr("07:00")},
+			loc:     time.FixedZone("UTC-5", -5*3600),
+			outcome: DecisionSend, rule: RuleDefault,
+		},
+		{
+			name:    "outside quiet hours",
+			pref:    &models.UserNotificationPreferences{Enabled: true, QuietHoursStart: strPtr("08:00"), QuietHoursEnd: strPtr("12:00")},
+			outcome: DecisionSend, rule: RuleDefault,
+		},
+		{
+			name:    "malformed quiet hours are ignored",
+			pref:    &models.UserNotificationPreferences{Enabled: true, QuietHoursStart: strPtr("late"), QuietHoursEnd: strPtr("07:00")},
+			outcome: DecisionSend, rule: RuleDefault,
+		},
+		{
+			name:      "daily limit reached",
+			pref:      &models.UserNotificationPreferences{Enabled: true, MaxPerDay: intPtr(2)},
+			sentToday: 2,
+			outcome:   DecisionSuppress, rule: RuleMaxPerDay,
+		},
+		{
+			name:      "under daily limit",
+			pref:      &models.UserNotificationPreferences{Enabled: true, MaxPerDay: intPtr(2)},
+			sentToday: 1,
+			outcome:   DecisionSend, rule: RuleDefault,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			n := &models.Notification{UserID: uuid.New(), Type: models.StreakReminder, Channel: models.ChannelPush, Status: models.StatusQueued}
+			pc := PlanContext{At: at, Location: tt.loc, Preferences: []models.UserNotificationPreferences{}}
+			if pc.Location == nil {
+				pc.Location = time.UTC
+			}
+			if tt.pref != nil {
+				pref := *tt.pref
+				pref.Type, pref.Channel = n.Type, n.Channel
+				pc.Preferences = []models.UserNotificationPreferences{pref}
+			}
+			mockRepo := new(MockNotificationRepository)
+			mockRepo.On("CountNotificationsForUserTypeSince", mock.Anything, n.UserID, n.Type, mock.AnythingOfType("time.Time")).Return(tt.sentToday, nil)
+
+			// Act
+			plan := NewDeliveryPolicy(mockRepo).Plan(context.Background(), n, pc)
+
+			// Assert: the snapshot is used as given, never reloaded
+			assert.Equal(t, tt.outcome, plan.Decision.Outcome)
+			assert.Equal(t, tt.rule, plan.Decision.Rule)
+			assert.Equal(t, tt.outcome == DecisionSend, plan.Publish)
+			if tt.rule != RuleDefault {
+				assert.NotEmpty(t, plan.Decision.Reason)
+			}
+			mockRepo.AssertNotCalled(t, "GetUserPreferences", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// replayFixture sets up a user with two push streak reminders sent on the same day
+func replayFixture() (*MockNotificationRepository, *models.DecisionReplayRequest, []models.Notification) {
+	userID := uuid.New()
+	day := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	req := &models.DecisionReplayRequest{UserID: userID, From: day, To: day.Add(24 * time.Hour)}
+
+	history := []models.Notification{
+		{ID: uuid.New(), UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Status: models.StatusDelivered, CreatedAt: day.Add(9 * time.Hour)},
+		{ID: uuid.New(), UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Status: models.StatusDelivered, CreatedAt: day.Add(18 * time.Hour)},
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, userID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("GetUserNotificationsBetween", mock.Anything, userID, day, req.To).Return(history, nil)
+	return mockRepo, req, history
+}
+
+func TestReplayDecisions_RuleChangeFlipsDecision(t *testing.T) {
+	// Arrange: the user has since capped streak reminders at one per day
+	mockRepo, req, history := replayFixture()
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true, MaxPerDay: intPtr(1)},
+	}, nil)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	replay, err := service.ReplayDecisions(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, replay.Entries, 2)
+	assert.Equal(t, 1, replay.ChangedCount)
+
+	first, second := replay.Entries[0], replay.Entries[1]
+	assert.Equal(t, history[0].ID, first.NotificationID)
+	assert.False(t, first.Changed)
+	assert.Equal(t, DecisionSend, first.Replayed.Outcome)
+
+	assert.True(t, second.Changed)
+	assert.Equal(t, DecisionSend, second.Original.Outcome)
+	assert.Equal(t, DecisionSuppress, second.Replayed.Outcome)
+	assert.Equal(t, RuleMaxPerDay, second.Replayed.Rule)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReplayDecisions_UnchangedRules(t *testing.T) {
+	// Arrange: quiet hours exist but neither notification falls inside them
+	mockRepo, req, _ := replayFixture()
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true,
+			QuietHoursStart: strPtr("22:00"), QuietHoursEnd: strPtr("07:00")},
+	}, nil)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	replay, err := service.ReplayDecisions(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, replay.Entries, 2)
+	assert.Equal(t, 0, replay.ChangedCount)
+	for _, entry := range replay.Entries {
+		assert.False(t, entry.Changed)
+		assert.Equal(t, entry.Original, entry.Replayed)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReplayDecisions_RejectsInvalidRange(t *testing.T) {
+	service := NewNotificationService(new(MockNotificationRepository), new(MockKafkaProducer), "test-topic")
+	now := time.Now()
+
+	_, err := service.ReplayDecisions(context.Background(), &models.DecisionReplayRequest{UserID: uuid.New(), From: now, To: now})
+	assert.ErrorIs(t, err, ErrInvalidReplay)
+
+	_, err = service.ReplayDecisions(context.Background(), &models.DecisionReplayRequest{
+		UserID: uuid.New(), From: now.Add(-MaxReplayWindow - time.Hour), To: now,
+	})
+	assert.ErrorIs(t, err, ErrInvalidReplay)
+}
+
+func TestReplayDecisions_QuietHoursDeferralIsUnchanged(t *testing.T) {
+	// Arrange: the evening reminder was deferred by the quiet hours still in place
+	mockRepo, req, history := replayFixture()
+	deferredTo := history[1].CreatedAt.Add(13 * time.Hour)
+	history[1].ScheduledFor = &deferredTo
+	history[1].Metadata = models.JSONMap{"decision_rule": RuleQuietHours}
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true,
+			QuietHoursStart: strPtr("17:00"), QuietHoursEnd: strPtr("07:00")},
+	}, nil)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	replay, err := service.ReplayDecisions(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, replay.Entries, 2)
+	assert.Equal(t, 0, replay.ChangedCount)
+	deferred := replay.Entries[1]
+	assert.Equal(t, DecisionDefer, deferred.Original.Outcome)
+	assert.Equal(t, DecisionDefer, deferred.Replayed.Outcome)
+	assert.Equal(t, RuleQuietHours, deferred.Replayed.Rule)
+	assert.Equal(t, &deferredTo, history[1].ScheduledFor, "the stored notification is left alone")
+}
+
+func TestReplayDecisions_DailyLimitCountsEveryChannel(t *testing.T) {
+	// Arrange: as in the live policy, max_per_day counts the type across channels
+	mockRepo, req, history := replayFixture()
+	history[0].Channel = models.ChannelInApp
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true, MaxPerDay: intPtr(1)},
+	}, nil)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	replay, err := service.ReplayDecisions(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, replay.Entries, 2)
+	assert.Equal(t, DecisionSend, replay.Entries[0].Replayed.Outcome)
+	assert.Equal(t, DecisionSuppress, replay.Entries[1].Replayed.Outcome)
+	assert.Equal(t, RuleMaxPerDay, replay.Entries[1].Replayed.Rule)
+}
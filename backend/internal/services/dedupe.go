@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+)
+
+// DefaultDedupeWindow is how long a dedupe key resolves repeat requests to the
+// notification first created with it
+const DefaultDedupeWindow = 24 * time.Hour
+
+// ErrDuplicateNotification is matched by DuplicateNotificationError
+var ErrDuplicateNotification = apperr.New(apperr.ErrConflict, "duplicate notification")
+
+// DuplicateNotificationError is returned by CreateNotification when the request's
+// dedupe key matches a notification created within the dedupe window, or its
+// idempotency key one created within IdempotencyKeyTTL. Existing is that
+// notification; nothing new was stored or queued.
+type DuplicateNotificationError struct {
+	Existing         *models.Notification
+	ByIdempotencyKey bool
+}
+
+func (e *DuplicateNotificationError) Error() string {
+	matched := "dedupe key"
+	if e.ByIdempotencyKey {
+		matched = "idempotency key"
+	}
+	return fmt.Sprintf("%v: %s matches notification %s", ErrDuplicateNotification, matched, e.Existing.ID)
+}
+
+func (e *DuplicateNotificationError) Unwrap() error {
+	return ErrDuplicateNotification
+}
+
+// WithDedupeWindow sets how long a dedupe key maps repeat requests to the first notification
+func WithDedupeWindow(window time.Duration) Option {
+	return func(s *notificationService) {
+		if window > 0 {
+			s.dedupeWindow = window
+		}
+	}
+}
+
+// checkDedupeKey looks for a live notification holding n's dedupe key. One created
+// within the dedupe window is returned as a DuplicateNotificationError; an older
+// one has its key retired so n can take it over. Empty keys are dropped.
+func (s *notificationService) checkDedupeKey(ctx context.Context, n *models.Notification, now time.Time) error {
+	if n.DedupeKey == nil {
+		return nil
+	}
+	if *n.DedupeKey == "" {
+		n.DedupeKey = nil
+		return nil
+	}
+
+	existing, err := s.repository.GetActiveNotificationByDedupeKey(ctx, n.UserID, *n.DedupeKey)
+	if errors.Is(err, repository.ErrNotificationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check dedupe key: %w", err)
+	}
+
+	if now.Sub(existing.CreatedAt) < s.dedupeWindow {
+		return &DuplicateNotificationError{Existing: existing}
+	}
+	if err := s.repository.RetireDedupeKey(ctx, existing.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkIdempotencyKey returns a DuplicateNotificationError holding the
+// notification the user created with idempotencyKey within IdempotencyKeyTTL
+func (s *notificationService) checkIdempotencyKey(ctx context.Context, n *models.Notification, idempotencyKey string, now time.Time) error {
+	if idempotencyKey == "" {
+		return nil
+	}
+
+	existing, err := s.repository.GetNotificationByIdempotencyKey(ctx, n.UserID, idempotencyKey, now.Add(-IdempotencyKeyTTL))
+	if errors.Is(err, repository.ErrNotificationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	return &DuplicateNotificationError{Existing: existing, ByIdempotencyKey: true}
+}
+
+// saveNotification stores a new notification, with an outbox entry when it is to
+// be published straight away, recording its idempotency key if one is set.
+// Losing a race for the dedupe or idempotency key to a concurrent request
+// resolves to that request's notification as a DuplicateNotificationError.
+func (s *notificationService) saveNotification(ctx context.Context, n *models.Notification, publish bool, idempotencyKey string) error {
+	var outboxItem *models.OutboxNotification
+	if publish {
+		var err error
+		outboxItem, err = models.BuildOutboxEntry(n, s.topicFor(n.Priority))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The notification, its outbox entry for Kafka and its idempotency key are saved atomically
+	var err error
+	switch {
+	case idempotencyKey != "":
+		err = s.repository.CreateNotificationWithIdempotencyKey(ctx, n, outboxItem, idempotencyKey, n.CreatedAt.Add(-IdempotencyKeyTTL))
+	case publish:
+		err = s.repository.CreateNotificationWithOutbox(ctx, n, outboxItem)
+	default:
+		err = s.repository.CreateNotification(ctx, n)
+	}
+
+	if errors.Is(err, repository.ErrDuplicateDedupeKey) {
+		existing, lookupErr := s.repository.GetActiveNotificationByDedupeKey(ctx, n.UserID, *n.DedupeKey)
+		if lookupErr == nil {
+			return &DuplicateNotificationError{Existing: existing}
+		}
+	}
+	if errors.Is(err, repository.ErrDuplicateIdempotencyKey) {
+		if dupErr := s.checkIdempotencyKey(ctx, n, idempotencyKey, n.CreatedAt); dupErr != nil {
+			return dupErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// dedupeFixture returns a request carrying key and a service fixed at now
+func dedupeFixture(now time.Time, key string) (*notificationService, *MockNotificationRepository, *models.CreateNotificationRequest) {
+	req := &models.CreateNotificationRequest{
+		UserID:    uuid.New(),
+		Type:      models.AchievementUnlock,
+		Channel:   models.ChannelInApp,
+		Priority:  models.PriorityMedium,
+		Message:   "You finished lesson 42",
+		DedupeKey: &key,
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic", WithDedupeWindow(time.Hour)).(*notificationService)
+	service.now = func() time.Time { return now }
+	return service, mockRepo, req
+}
+
+func TestCreateNotification_FirstDedupeKeyIsCreated(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := dedupeFixture(time.Now(), "lesson-42-complete")
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, "lesson-42-complete").
+		Return(nil, fmt.Errorf("%w: dedupe key", repository.ErrNotificationNotFound))
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, notification.DedupeKey)
+	assert.Equal(t, "lesson-42-complete", *notification.DedupeKey)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_DuplicateWithinWindowReturnsExisting(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	service, mockRepo, req := dedupeFixture(now, "lesson-42-complete")
+	existing := &models.Notification{ID: uuid.New(), UserID: req.UserID, DedupeKey: req.DedupeKey, CreatedAt: now.Add(-59 * time.Minute)}
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, "lesson-42-complete").Return(existing, nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: nothing new is stored or queued
+	assert.Nil(t, notification)
+	var duplicate *DuplicateNotificationError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, existing, duplicate.Existing)
+	assert.ErrorIs(t, err, ErrDuplicateNotification)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_ExpiredDedupeKeyIsReused(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	service, mockRepo, req := dedupeFixture(now, "lesson-42-complete")
+	existing := &models.Notification{ID: uuid.New(), UserID: req.UserID, DedupeKey: req.DedupeKey, CreatedAt: now.Add(-time.Hour)}
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, "lesson-42-complete").Return(existing, nil)
+	mockRepo.On("RetireDedupeKey", mock.Anything, existing.ID).Return(nil)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEqual(t, existing.ID, notification.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_ConcurrentDuplicateResolvesToWinner(t *testing.T) {
+	// Arrange: the key is free at lookup, but a concurrent request inserts first
+	service, mockRepo, req := dedupeFixture(time.Now(), "lesson-42-complete")
+	winner := &models.Notification{ID: uuid.New(), UserID: req.UserID, DedupeKey: req.DedupeKey, CreatedAt: time.Now()}
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, "lesson-42-complete").
+		Return(nil, repository.ErrNotificationNotFound).Once()
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Return(fmt.Errorf("%w: %q", repository.ErrDuplicateDedupeKey, "lesson-42-complete"))
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, "lesson-42-complete").Return(winner, nil).Once()
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	assert.Nil(t, notification)
+	var duplicate *DuplicateNotificationError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, winner.ID, duplicate.Existing.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_EmptyDedupeKeyIsIgnored(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := dedupeFixture(time.Now(), "")
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, notification.DedupeKey)
+	mockRepo.AssertNotCalled(t, "GetActiveNotificationByDedupeKey", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+)
+
+// DeliveryPolicy applies a user's preference for a notification's type and
+// channel to a notification about to be created. The API and the scheduler
+// both create notifications through it, so they suppress and defer alike, and
+// decision replays run past notifications through it as well.
+type DeliveryPolicy struct {
+	repository repository.NotificationRepository
+	counter    DailyCounter
+}
+
+// NewDeliveryPolicy creates a delivery policy reading preferences, timezones
+// and the notifications already sent today from the repository
+func NewDeliveryPolicy(repo repository.NotificationRepository) *DeliveryPolicy {
+	return &DeliveryPolicy{repository: repo, counter: repo}
+}
+
+// PlanContext is the state a notification is planned against. The live paths
+// pin only the time; a replay pins the timezone and preferences as well, so
+// the same context always yields the same plan.
+type PlanContext struct {
+	// At is the evaluation time; quiet hours and the local day are taken from it
+	At time.Time
+	// Location is the user's timezone; when nil it is looked up from their
+	// practice streak, and only when their preference needs it
+	Location *time.Location
+	// Preferences is the user's preference snapshot; when nil their current
+	// preferences are loaded
+	Preferences []models.UserNotificationPreferences
+}
+
+// DeliveryPlan is what the user's preference makes of a notification
+type DeliveryPlan struct {
+	// Preference is the user's preference for the notification's type and
+	// channel, nil when they have none or it can't be loaded
+	Preference *models.UserNotificationPreferences
+	// OptedOut is set when the user turned the type off on the channel
+	OptedOut bool
+	// Publish is set when the notification can go to the outbox now; a
+	// suppressed or deferred one is stored without an outbox entry
+	Publish bool
+	// Decision is the rule that decided the plan
+	Decision Decision
+}
+
+// Plan applies the user's preference to a notification created at pc.At. One
+// the user turned off is never published; the caller drops or suppresses it.
+// Once max_per_day is reached on the user's local day it is marked suppressed;
+// during quiet hours its ScheduledFor is set to the end of the window and the
+// rule recorded in its metadata. One whose ScheduledFor is still ahead is
+// never published straight away.
+func (p *DeliveryPolicy) Plan(ctx context.Context, n *models.Notification, pc PlanContext) DeliveryPlan {
+	plan := DeliveryPlan{
+		Publish:  n.ScheduledFor == nil || !n.ScheduledFor.After(pc.At),
+		Decision: Decision{Outcome: DecisionSend, Rule: RuleDefault},
+	}
+	plan.Preference = p.preference(ctx, n, pc.Preferences)
+	if plan.Preference == nil {
+		return plan
+	}
+
+	plan.OptedOut = !plan.Preference.Enabled && !optOutExempt[n.Type]
+	if plan.OptedOut {
+		plan.Publish = false
+		plan.Decision = Decision{
+			Outcome: DecisionSuppress,
+			Rule:    RulePreferenceDisabled,
+			Reason:  fmt.Sprintf("%s notifications are disabled on %s", n.Type, n.Channel),
+		}
+		return plan
+	}
+
+	loc := pc.Location
+	if loc == nil {
+		loc = p.location(ctx, n)
+	}
+	if ApplyDailyLimit(ctx, p.counter, n, plan.Preference, loc, pc.At) {
+		plan.Publish = false
+		reason, _ := n.Metadata["suppressed_reason"].(string)
+		plan.Decision = Decision{Outcome: DecisionSuppress, Rule: RuleMaxPerDay, Reason: reason}
+	} else if deferUntil := quietHoursDeferral(plan.Preference, loc, pc.At); plan.Publish && deferUntil != nil {
+		if n.Metadata == nil {
+			n.Metadata = models.JSONMap{}
+		}
+		n.Metadata["decision_rule"] = RuleQuietHours
+		n.ScheduledFor = deferUntil
+		plan.Publish = false
+		plan.Decision = Decision{
+			Outcome: DecisionDefer,
+			Rule:    RuleQuietHours,
+			Reason:  fmt.Sprintf("%s falls within quiet hours %s-%s", pc.At.In(loc).Format("15:04"), *plan.Preference.QuietHoursStart, *plan.Preference.QuietHoursEnd),
+		}
+	}
+	return plan
+}
+
+// preference returns the user's preference for a notification's type and
+// channel from the snapshot, or their current preferences without one. It is
+// nil when they have none or it can't be loaded.
+func (p *DeliveryPolicy) preference(ctx context.Context, n *models.Notification, snapshot []models.UserNotificationPreferences) *models.UserNotificationPreferences {
+	if snapshot != nil {
+		return FindPreference(snapshot, n.Type, n.Channel)
+	}
+
+	prefs, err := p.repository.GetUserPreferences(ctx, n.UserID)
+	if err != nil {
+		log.Printf("Failed to load preferences for user %s: %v", n.UserID, err)
+		return nil
+	}
+	return FindPreference(prefs, n.Type, n.Channel)
+}
+
+// location returns the user's timezone from their practice streak, or UTC
+// when they have none
+func (p *DeliveryPolicy) location(ctx context.Context, n *models.Notification) *time.Location {
+	streak, err := p.repository.GetUserEngagementStreak(ctx, n.UserID, "practice")
+	if err != nil || streak == nil {
+		return time.UTC
+	}
+	return LoadLocation(streak.Timezone)
+}
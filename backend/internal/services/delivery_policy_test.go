@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeliveryPolicyPlan_OptOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      models.NotificationType
+		optedOut bool
+	}{
+		{name: "disabled type is opted out", typ: models.StreakReminder, optedOut: true},
+		{name: "exempt type is never opted out", typ: models.PreferencesUpdated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			n := &models.Notification{UserID: uuid.New(), Type: tt.typ, Channel: models.ChannelInApp}
+			mockRepo := new(MockNotificationRepository)
+			mockRepo.On("GetUserPreferences", mock.Anything, n.UserID).Return([]models.UserNotificationPreferences{
+				{UserID: n.UserID, Type: tt.typ, Channel: n.Channel, Enabled: false},
+			}, nil)
+
+			// Act
+			plan := NewDeliveryPolicy(mockRepo).Plan(context.Background(), n, PlanContext{At: time.Now(), Location: time.UTC})
+
+			// Assert
+			assert.Equal(t, tt.optedOut, plan.OptedOut)
+			assert.NotNil(t, plan.Preference)
+			assert.Equal(t, !tt.optedOut, plan.Publish, "an opted-out notification is never published")
+		})
+	}
+}
+
+func TestDeliveryPolicyPlan_NoPreferencePublishes(t *testing.T) {
+	// Arrange
+	n := &models.Notification{UserID: uuid.New(), Type: models.StreakReminder, Channel: models.ChannelInApp}
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetUserPreferences", mock.Anything, n.UserID).Return([]models.UserNotificationPreferences{}, nil)
+
+	// Act
+	plan := NewDeliveryPolicy(mockRepo).Plan(context.Background(), n, PlanContext{At: time.Now()})
+
+	// Assert: without a preference the streak timezone is never looked up
+	assert.Equal(t, DeliveryPlan{Publish: true, Decision: Decision{Outcome: DecisionSend, Rule: RuleDefault}}, plan)
+	mockRepo.AssertNotCalled(t, "GetUserEngagementStreak", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+// Engagement nudge tiers, escalating the longer a user stays inactive. A user
+// gets each tier at most once per inactive spell and nothing after the last.
+const (
+	NudgeTierNone = iota
+	// NudgeTierWeek is the first "we miss you", after 7 inactive days
+	NudgeTierWeek
+	// NudgeTierFortnight reminds the user of their longest streak after 14 days
+	NudgeTierFortnight
+	// NudgeTierMonth offers a streak restore after 30 days and is the last nudge
+	NudgeTierMonth
+)
+
+// nudgeTierDays is the number of inactive days each tier starts at, by tier
+var nudgeTierDays = [...]int{NudgeTierWeek: 7, NudgeTierFortnight: 14, NudgeTierMonth: 30}
+
+// InactiveDays returns the whole local days since the streak's last activity,
+// counted in the streak's timezone, or 0 when it has none
+func InactiveDays(now time.Time, streak *models.UserEngagementStreak) int {
+	if streak == nil || streak.LastActivityDate == nil {
+		return 0
+	}
+	today := calendarDate(now.In(LoadLocation(streak.Timezone)))
+	return int(today.Sub(calendarDate(*streak.LastActivityDate)).Hours() / 24)
+}
+
+// NudgeTierFor returns the highest tier due after the given inactive days, or
+// NudgeTierNone before the first one
+func NudgeTierFor(inactiveDays int) int {
+	for tier := NudgeTierMonth; tier > NudgeTierNone; tier-- {
+		if inactiveDays >= nudgeTierDays[tier] {
+			return tier
+		}
+	}
+	return NudgeTierNone
+}
+
+// NextNudgeTier returns the tier to nudge a user with after lastTier was sent
+// in the current inactive spell, or NudgeTierNone when the due tier was already
+// sent or none is due yet. Tiers never regress, so nothing follows the last one.
+func NextNudgeTier(inactiveDays, lastTier int) int {
+	tier := NudgeTierFor(inactiveDays)
+	if tier <= lastTier {
+		return NudgeTierNone
+	}
+	return tier
+}
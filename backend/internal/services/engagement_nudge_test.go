@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextNudgeTier_Escalates(t *testing.T) {
+	tests := []struct {
+		name         string
+		inactiveDays int
+		lastTier     int
+		expected     int
+	}{
+		{"active this week", 6, NudgeTierNone, NudgeTierNone},
+		{"first week", 7, NudgeTierNone, NudgeTierWeek},
+		{"week tier already sent", 10, NudgeTierWeek, NudgeTierNone},
+		{"second week", 14, NudgeTierWeek, NudgeTierFortnight},
+		{"skips straight to the due tier", 20, NudgeTierNone, NudgeTierFortnight},
+		{"fortnight tier already sent", 29, NudgeTierFortnight, NudgeTierNone},
+		{"a month", 30, NudgeTierFortnight, NudgeTierMonth},
+		{"stops after the last tier", 90, NudgeTierMonth, NudgeTierNone},
+		{"never regresses", 8, NudgeTierFortnight, NudgeTierNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NextNudgeTier(tt.inactiveDays, tt.lastTier))
+		})
+	}
+}
+
+func TestInactiveDays_UsesStreakTimezone(t *testing.T) {
+	last := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	// 02:00 UTC on the 12th is still the 11th in New York
+	now := time.Date(2024, time.March, 12, 2, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 7, InactiveDays(now, &models.UserEngagementStreak{Timezone: "UTC", LastActivityDate: &last}))
+	assert.Equal(t, 6, InactiveDays(now, &models.UserEngagementStreak{Timezone: "America/New_York", LastActivityDate: &last}))
+	assert.Equal(t, 0, InactiveDays(now, &models.UserEngagementStreak{Timezone: "UTC"}))
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// CreateFanout creates one notification per channel the user has enabled for
+// the request's type, each through CreateNotification with its own outbox
+// entry. The rows share a metadata["fanout_group"] UUID; dedupe and
+// idempotency keys are scoped per channel. Users with no enabled channel get an empty list.
+func (s *notificationService) CreateFanout(ctx context.Context, req *models.CreateNotificationRequest) ([]*models.Notification, error) {
+	if !models.IsValidNotificationType(req.Type) {
+		return nil, fmt.Errorf("%w type: %s", ErrInvalidNotification, req.Type)
+	}
+	if err := validatePriority(req); err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.repository.GetUserPreferences(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+
+	group := uuid.NewString()
+	var created []*models.Notification
+	for _, pref := range prefs {
+		if pref.Type != req.Type || !pref.Enabled || !models.IsValidChannel(pref.Channel) {
+			continue
+		}
+
+		channelReq := *req
+		channelReq.Channel = pref.Channel
+		channelReq.Metadata = models.JSONMap{"fanout_group": group}
+		for k, v := range req.Metadata {
+			if k != "fanout_group" {
+				channelReq.Metadata[k] = v
+			}
+		}
+		if req.DedupeKey != nil && *req.DedupeKey != "" {
+			key := fmt.Sprintf("%s:%s", *req.DedupeKey, pref.Channel)
+			channelReq.DedupeKey = &key
+		}
+		if req.IdempotencyKey != "" {
+			channelReq.IdempotencyKey = fmt.Sprintf("%s:%s", req.IdempotencyKey, pref.Channel)
+		}
+
+		notification, err := s.CreateNotification(ctx, &channelReq)
+		var duplicate *DuplicateNotificationError
+		if errors.As(err, &duplicate) {
+			notification, err = duplicate.Existing, nil
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to create %s notification: %w", pref.Channel, err)
+		}
+		created = append(created, notification)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fanoutFixture returns an auto-channel request and a repository whose user has
+// the given preferences for the request's type
+func fanoutFixture(prefs func(userID uuid.UUID) []models.UserNotificationPreferences) (NotificationService, *MockNotificationRepository, *models.CreateNotificationRequest) {
+	req := &models.CreateNotificationRequest{
+		UserID:   uuid.New(),
+		Type:     models.AchievementUnlock,
+		Channel:  models.ChannelAuto,
+		Priority: models.PriorityMedium,
+		Message:  "You unlocked Marathon",
+		Metadata: models.JSONMap{"achievement": "marathon"},
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return(prefs(req.UserID), nil)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, mock.Anything).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("MarkPreferenceSent", mock.Anything, req.UserID, req.Type, mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	return NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic"), mockRepo, req
+}
+
+func TestCreateFanout_NoEnabledChannels(t *testing.T) {
+	// Arrange: the only preference for the type is disabled
+	service, mockRepo, req := fanoutFixture(func(userID uuid.UUID) []models.UserNotificationPreferences {
+		return []models.UserNotificationPreferences{
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelPush, Enabled: false},
+			{UserID: userID, Type: models.DailyReminder, Channel: models.ChannelPush, Enabled: true},
+		}
+	})
+
+	// Act
+	notifications, err := service.CreateFanout(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateFanout_InvalidPriority(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := fanoutFixture(func(userID uuid.UUID) []models.UserNotificationPreferences {
+		return nil
+	})
+	req.Priority = "banana"
+
+	// Act
+	_, err := service.CreateFanout(context.Background(), req)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidPriority)
+	mockRepo.AssertNotCalled(t, "GetUserPreferences", mock.Anything, mock.Anything)
+}
+
+func TestCreateFanout_OneEnabledChannel(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := fanoutFixture(func(userID uuid.UUID) []models.UserNotificationPreferences {
+		return []models.UserNotificationPreferences{
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelEmail, Enabled: true},
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelSMS, Enabled: false},
+		}
+	})
+
+	// Act
+	notifications, err := service.CreateFanout(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, models.ChannelEmail, notifications[0].Channel)
+	assert.NotEmpty(t, notifications[0].Metadata["fanout_group"])
+	assert.Equal(t, "marathon", notifications[0].Metadata["achievement"])
+	mockRepo.AssertNumberOfCalls(t, "CreateNotificationWithOutbox", 1)
+}
+
+func TestCreateFanout_ThreeEnabledChannels(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := fanoutFixture(func(userID uuid.UUID) []models.UserNotificationPreferences {
+		return []models.UserNotificationPreferences{
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelInApp, Enabled: true},
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelPush, Enabled: true},
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelSMS, Enabled: false},
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelEmail, Enabled: true},
+		}
+	})
+	key := "marathon-unlocked"
+	req.DedupeKey = &key
+	mockRepo.On("GetActiveNotificationByDedupeKey", mock.Anything, req.UserID, mock.Anything).
+		Return(nil, repository.ErrNotificationNotFound)
+
+	// Act
+	notifications, err := service.CreateFanout(context.Background(), req)
+
+	// Assert: one row and outbox entry per enabled channel, correlated by group
+	require.NoError(t, err)
+	require.Len(t, notifications, 3)
+	channels := make([]models.NotificationChannel, len(notifications))
+	group := notifications[0].Metadata["fanout_group"]
+	for i, n := range notifications {
+		channels[i] = n.Channel
+		assert.Equal(t, group, n.Metadata["fanout_group"])
+		assert.Equal(t, "marathon-unlocked:"+string(n.Channel), *n.DedupeKey)
+	}
+	assert.Equal(t, []models.NotificationChannel{models.ChannelInApp, models.ChannelPush, models.ChannelEmail}, channels)
+	assert.NotEqual(t, notifications[0].ID, notifications[1].ID)
+	mockRepo.AssertNumberOfCalls(t, "CreateNotificationWithOutbox", 3)
+	_, isShared := req.Metadata["fanout_group"]
+	assert.False(t, isShared, "the caller's metadata is left untouched")
+}
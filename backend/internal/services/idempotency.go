@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKeyTTL is how long an Idempotency-Key resolves retried requests to
+// the notification first created with it
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// PurgeIdempotencyKeys deletes idempotency keys older than IdempotencyKeyTTL and
+// returns how many were removed
+func (s *notificationService) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	deleted, err := s.repository.DeleteIdempotencyKeysBefore(ctx, s.now().Add(-IdempotencyKeyTTL))
+	if err != nil {
+		return deleted, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return deleted, nil
+}
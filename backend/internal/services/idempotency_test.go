@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// idempotencyFixture returns a request carrying key and a service fixed at now
+func idempotencyFixture(now time.Time, key string) (*notificationService, *MockNotificationRepository, *models.CreateNotificationRequest) {
+	req := &models.CreateNotificationRequest{
+		UserID:         uuid.New(),
+		Type:           models.AchievementUnlock,
+		Channel:        models.ChannelInApp,
+		Priority:       models.PriorityMedium,
+		Message:        "You finished lesson 42",
+		IdempotencyKey: key,
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+	return service, mockRepo, req
+}
+
+func TestCreateNotification_IdempotencyKeyIsRecorded(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	service, mockRepo, req := idempotencyFixture(now, "req-7f3a")
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, "req-7f3a", now.Add(-IdempotencyKeyTTL)).
+		Return(nil, fmt.Errorf("%w: idempotency key", repository.ErrNotificationNotFound))
+	mockRepo.On("CreateNotificationWithIdempotencyKey", mock.Anything, mock.AnythingOfType("*models.Notification"),
+		mock.AnythingOfType("*models.OutboxNotification"), "req-7f3a", now.Add(-IdempotencyKeyTTL)).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: stored with its outbox entry and key in one call
+	require.NoError(t, err)
+	assert.Equal(t, now, notification.CreatedAt)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_IdempotencyKeyReplayReturnsOriginal(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	service, mockRepo, req := idempotencyFixture(now, "req-7f3a")
+	original := &models.Notification{ID: uuid.New(), UserID: req.UserID, CreatedAt: now.Add(-23 * time.Hour)}
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, "req-7f3a", now.Add(-IdempotencyKeyTTL)).Return(original, nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: nothing new is stored or queued
+	assert.Nil(t, notification)
+	var duplicate *DuplicateNotificationError
+	require.True(t, errors.As(err, &duplicate))
+	assert.True(t, duplicate.ByIdempotencyKey)
+	assert.Equal(t, original, duplicate.Existing)
+	assert.ErrorContains(t, err, "idempotency key matches notification")
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateNotification_ExpiredIdempotencyKeyCreatesNew(t *testing.T) {
+	// Arrange: the lookup only sees keys from the last IdempotencyKeyTTL, and the
+	// insert may take over an older row the purge has not removed yet
+	now := time.Now()
+	service, mockRepo, req := idempotencyFixture(now, "req-7f3a")
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, "req-7f3a", now.Add(-24*time.Hour)).
+		Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("CreateNotificationWithIdempotencyKey", mock.Anything, mock.AnythingOfType("*models.Notification"),
+		mock.AnythingOfType("*models.OutboxNotification"), "req-7f3a", now.Add(-24*time.Hour)).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotNil(t, notification)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_ConcurrentIdempotencyKeyResolvesToWinner(t *testing.T) {
+	// Arrange: the key is free at lookup, but a concurrent request commits first
+	now := time.Now()
+	service, mockRepo, req := idempotencyFixture(now, "req-7f3a")
+	winner := &models.Notification{ID: uuid.New(), UserID: req.UserID, CreatedAt: now}
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, "req-7f3a", mock.Anything).
+		Return(nil, repository.ErrNotificationNotFound).Once()
+	mockRepo.On("CreateNotificationWithIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, "req-7f3a", mock.Anything).
+		Return(fmt.Errorf("%w: %q", repository.ErrDuplicateIdempotencyKey, "req-7f3a"))
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, "req-7f3a", mock.Anything).Return(winner, nil).Once()
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	assert.Nil(t, notification)
+	var duplicate *DuplicateNotificationError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, winner.ID, duplicate.Existing.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateFanout_IdempotencyKeyScopedPerChannel(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := fanoutFixture(func(userID uuid.UUID) []models.UserNotificationPreferences {
+		return []models.UserNotificationPreferences{
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelInApp, Enabled: true},
+			{UserID: userID, Type: models.AchievementUnlock, Channel: models.ChannelEmail, Enabled: true},
+		}
+	})
+	req.IdempotencyKey = "req-7f3a"
+	mockRepo.On("GetNotificationByIdempotencyKey", mock.Anything, req.UserID, mock.Anything, mock.Anything).
+		Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("CreateNotificationWithIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, "req-7f3a:in_app", mock.Anything).Return(nil)
+	mockRepo.On("CreateNotificationWithIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, "req-7f3a:email", mock.Anything).Return(nil)
+
+	// Act
+	notifications, err := service.CreateFanout(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, notifications, 2)
+	mockRepo.AssertNumberOfCalls(t, "CreateNotificationWithIdempotencyKey", 2)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+	mockRepo.On("DeleteIdempotencyKeysBefore", mock.Anything, now.Add(-IdempotencyKeyTTL)).Return(int64(12), nil)
+
+	// Act
+	deleted, err := service.PurgeIdempotencyKeys(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), deleted)
+	mockRepo.AssertExpectations(t)
+}
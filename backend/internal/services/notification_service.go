@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"kafka-notify/internal/kafka"
+	"kafka-notify/pkg/apperr"
 	"kafka-notify/pkg/models"
 	"kafka-notify/pkg/repository"
 
@@ -18,46 +22,353 @@ import (
 // NotificationService defines the interface for notification operations
 type NotificationService interface {
 	CreateNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error)
-	GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Notification, error)
-	MarkAsRead(ctx context.Context, notificationID uuid.UUID) error
-	UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) error
+	CreateFanout(ctx context.Context, req *models.CreateNotificationRequest) ([]*models.Notification, error)
+	BackfillNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error)
+	GetUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error)
+	CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error)
+	GetNotification(ctx context.Context, notificationID uuid.UUID) (*models.NotificationDetail, error)
+	GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error)
+	GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error)
+	MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error
+	MarkAsDelivered(ctx context.Context, notificationID, userID uuid.UUID) error
+	MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error)
+	UpdateUserPreferences(ctx context.Context, userID uuid.UUID, req *models.NotificationPreferencesRequest) (*models.UserNotificationPreferences, error)
+	PatchUserPreferences(ctx context.Context, userID uuid.UUID, patch *models.NotificationPreferencesPatch) (*models.UserNotificationPreferences, error)
+	UpdateUserPreferencesBulk(ctx context.Context, userID uuid.UUID, reqs []models.NotificationPreferencesRequest) ([]models.UserNotificationPreferences, error)
+	DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error
+	DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error)
 	GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error)
-	CreateDailyReminder(ctx context.Context, user models.User) error
-	CreateStreakReminder(ctx context.Context, user models.User) error
-	ProcessOutbox(ctx context.Context) error
+	GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.ResolvedPreference, error)
+	CreateFromTemplate(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, data map[string]interface{}) (*models.Notification, error)
+	CreateDailyReminder(ctx context.Context, userID uuid.UUID) error
+	CreateStreakReminder(ctx context.Context, userID uuid.UUID) error
+	ProcessOutbox(ctx context.Context) (*OutboxResult, error)
+	ProcessOutboxAsync(ctx context.Context) (*OutboxResult, error)
+	DispatchScheduled(ctx context.Context) error
+	PurgeOutbox(ctx context.Context, before time.Time) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+	GetOutboxStats(ctx context.Context) (*models.OutboxStats, error)
+	ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error)
+	RetryDeadOutbox(ctx context.Context, outboxID int64) error
+	RepublishNotification(ctx context.Context, notificationID uuid.UUID, force, publishNow bool) (*RepublishResult, error)
+	RetryFailed(ctx context.Context) (*RetryResult, error)
+	RetryNotification(ctx context.Context, notificationID uuid.UUID, force bool) (*models.Notification, error)
+	CancelNotification(ctx context.Context, notificationID uuid.UUID) (*models.Notification, error)
+	ReplayDecisions(ctx context.Context, req *models.DecisionReplayRequest) (*DecisionReplay, error)
 }
 
+// BackfillOutboxCutoff is the age beyond which backfilled notifications are
+// stored without an outbox entry, so historical rows are not re-published
+const BackfillOutboxCutoff = 24 * time.Hour
+
+// ErrInvalidBackfill is returned when a backfill request fails validation
+var ErrInvalidBackfill = apperr.New(apperr.ErrInvalidInput, "invalid backfill request")
+
+// ErrInvalidNotification is returned for an unknown notification type, channel or priority
+var ErrInvalidNotification = apperr.New(apperr.ErrInvalidInput, "invalid notification")
+
+// ErrInvalidPriority is the ErrInvalidNotification for a priority other than
+// low, medium, high or urgent; handlers answer it with 400 rather than 422
+var ErrInvalidPriority = apperr.New(ErrInvalidNotification, "invalid notification priority")
+
+// ErrNoActiveStreak is returned when creating a streak reminder for a user
+// whose streak is broken
+var ErrNoActiveStreak = apperr.New(apperr.ErrConflict, "user has no active streak")
+
+// ErrAlreadySentToday is returned when creating a reminder the user already got
+// on their local day
+var ErrAlreadySentToday = apperr.New(apperr.ErrConflict, "reminder already sent today")
+
+// ErrReminderDisabled is returned when creating a reminder the user turned off
+var ErrReminderDisabled = apperr.New(apperr.ErrConflict, "reminder disabled by user preference")
+
 // notificationService implements NotificationService
 type notificationService struct {
 	repository repository.NotificationRepository
 	producer   sarama.SyncProducer
 	topic      string
+	workerID   string
+
+	// topics routes priorities to dedicated topics; unlisted ones use topic
+	topics map[models.PriorityLevel]string
+
+	attachmentHosts []string
+	outboxBatchSize int
+	outboxRetention time.Duration
+
+	outboxMaxAttempts int
+	outboxBackoffBase time.Duration
+	outboxBackoffMax  time.Duration
+
+	retryMaxAttempts int
+	retryBackoffBase time.Duration
+	retryBackoffMax  time.Duration
+
+	dedupeWindow time.Duration
+
+	// maxMessageBytes is the producer's message size limit; 0 leaves it to Kafka
+	maxMessageBytes int
+
+	// asyncProducer, when set, publishes the outbox through an async producer;
+	// asyncMu keeps one batch at a time on its shared result channels
+	asyncProducer func() sarama.AsyncProducer
+	asyncMu       sync.Mutex
+
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// DefaultOutboxBatchSize is the number of outbox items published per ProcessOutbox call
+const DefaultOutboxBatchSize = 100
+
+// Option configures optional notification service settings
+type Option func(*notificationService)
+
+// WithAttachmentHosts sets the CDN hosts attachment URLs may point at
+func WithAttachmentHosts(hosts []string) Option {
+	return func(s *notificationService) {
+		s.attachmentHosts = hosts
+	}
+}
+
+// WithOutboxBatchSize sets how many outbox items are claimed and published per batch
+func WithOutboxBatchSize(size int) Option {
+	return func(s *notificationService) {
+		if size > 0 {
+			s.outboxBatchSize = size
+		}
+	}
+}
+
+// WithOutboxRetention sets how long published outbox items are kept before purging
+func WithOutboxRetention(retention time.Duration) Option {
+	return func(s *notificationService) {
+		if retention > 0 {
+			s.outboxRetention = retention
+		}
+	}
+}
+
+// WithOutboxMaxAttempts sets how many failed publishes mark an outbox item dead
+func WithOutboxMaxAttempts(attempts int) Option {
+	return func(s *notificationService) {
+		if attempts > 0 {
+			s.outboxMaxAttempts = attempts
+		}
+	}
+}
+
+// WithOutboxBackoff sets the delay after an outbox item's first failed publish,
+// doubled on each further failure up to max
+func WithOutboxBackoff(base, max time.Duration) Option {
+	return func(s *notificationService) {
+		if base > 0 {
+			s.outboxBackoffBase = base
+		}
+		if max > 0 {
+			s.outboxBackoffMax = max
+		}
+	}
+}
+
+// WithMaxMessageBytes dead-letters outbox items whose message is larger than
+// the producer's KAFKA_PRODUCER_MAX_MESSAGE_BYTES instead of sending them
+func WithMaxMessageBytes(limit int) Option {
+	return func(s *notificationService) {
+		s.maxMessageBytes = limit
+	}
+}
+
+// WithPriorityTopics publishes notifications of the listed priorities to their own
+// topic so urgent alerts aren't queued behind bulk low-priority traffic
+func WithPriorityTopics(topics map[models.PriorityLevel]string) Option {
+	return func(s *notificationService) {
+		s.topics = topics
+	}
 }
 
 // NewNotificationService creates a new notification service
-func NewNotificationService(repo repository.NotificationRepository, producer sarama.SyncProducer, topic string) NotificationService {
-	return &notificationService{
+func NewNotificationService(repo repository.NotificationRepository, producer sarama.SyncProducer, topic string, opts ...Option) NotificationService {
+	s := &notificationService{
 		repository: repo,
 		producer:   producer,
 		topic:      topic,
+		workerID:   newWorkerID(),
+
+		outboxBatchSize: DefaultOutboxBatchSize,
+		outboxRetention: DefaultOutboxRetention,
+
+		outboxMaxAttempts: repository.MaxOutboxAttempts,
+		outboxBackoffBase: DefaultOutboxBackoffBase,
+		outboxBackoffMax:  DefaultOutboxBackoffMax,
+
+		retryMaxAttempts: DefaultRetryMaxAttempts,
+		retryBackoffBase: DefaultRetryBackoffBase,
+		retryBackoffMax:  DefaultRetryBackoffMax,
+
+		dedupeWindow: DefaultDedupeWindow,
+
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// topicFor returns the topic a notification of the given priority is published to
+func (s *notificationService) topicFor(priority models.PriorityLevel) string {
+	if topic := s.topics[priority]; topic != "" {
+		return topic
 	}
+	return s.topic
 }
 
-// CreateNotification creates a new notification
+// newWorkerID identifies this service instance when claiming outbox items
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
+
+// CreateNotification creates a new notification, applying the user's preference
+// for its type and channel. One the user turned off on the channel, or once
+// max_per_day is reached, is stored suppressed.
+// One created during quiet hours is stored queued with ScheduledFor set to the
+// end of the window and no outbox entry, as is one whose ScheduledFor is still
+// ahead; DispatchScheduled releases them once due. A repeated dedupe key or
+// idempotency key returns a DuplicateNotificationError with the original.
 func (s *notificationService) CreateNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error) {
-	// Validate notification type
-	if !models.IsValidNotificationType(req.Type) {
-		return nil, fmt.Errorf("invalid notification type: %s", req.Type)
+	if err := validateNotificationRequest(req); err != nil {
+		return nil, err
+	}
+	if err := req.Attachments.Validate(s.attachmentHosts); err != nil {
+		return nil, err
 	}
 
-	// Validate channel
+	// ID and CreatedAt are reserved for backfills and ignored here
+	now := s.now()
+	notification := newNotificationFromRequest(req, models.NewNotificationID(), now)
+	if err := s.checkIdempotencyKey(ctx, notification, req.IdempotencyKey, now); err != nil {
+		return nil, err
+	}
+	if err := s.checkDedupeKey(ctx, notification, now); err != nil {
+		return nil, err
+	}
+	if len(notification.Attachments) == 0 {
+		notification.Attachments = s.defaultAttachments(ctx, req.Type, req.Channel)
+	}
+
+	// Notifications scheduled for later wait for DispatchScheduled
+	plan := NewDeliveryPolicy(s.repository).Plan(ctx, notification, PlanContext{At: now})
+	if plan.OptedOut {
+		// Kept for the record like a daily-limit suppression, with no outbox entry
+		if notification.Metadata == nil {
+			notification.Metadata = models.JSONMap{}
+		}
+		notification.Metadata["decision_rule"] = plan.Decision.Rule
+		notification.Metadata["suppressed_reason"] = plan.Decision.Reason
+		notification.Status = models.StatusSuppressed
+	}
+
+	if err := s.saveNotification(ctx, notification, plan.Publish, req.IdempotencyKey); err != nil {
+		return nil, err
+	}
+	if notification.Status == models.StatusQueued {
+		RecordPreferenceSent(ctx, s.repository, plan.Preference, now)
+	}
+
+	// Immediate publish only if explicitly enabled (OUTBOX_IMMEDIATE_PUBLISH=true)
+	if plan.Publish && strings.EqualFold(os.Getenv("OUTBOX_IMMEDIATE_PUBLISH"), "true") {
+		_, _ = s.ProcessOutbox(ctx)
+	}
+
+	return notification, nil
+}
+
+// BackfillNotification inserts a historical notification preserving its original
+// ID and creation time. Rows older than BackfillOutboxCutoff get no outbox entry.
+func (s *notificationService) BackfillNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error) {
+	if err := validateNotificationRequest(req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackfill, err)
+	}
+	if req.ID == nil || *req.ID == uuid.Nil {
+		return nil, fmt.Errorf("%w: id is required", ErrInvalidBackfill)
+	}
+	if err := req.Attachments.Validate(s.attachmentHosts); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackfill, err)
+	}
+
+	now := time.Now()
+	createdAt := now
+	if req.CreatedAt != nil {
+		if req.CreatedAt.After(now) {
+			return nil, fmt.Errorf("%w: created_at %s is in the future", ErrInvalidBackfill, req.CreatedAt.Format(time.RFC3339))
+		}
+		createdAt = *req.CreatedAt
+	}
+
+	notification := newNotificationFromRequest(req, *req.ID, createdAt)
+
+	var err error
+	if now.Sub(createdAt) > BackfillOutboxCutoff {
+		err = s.repository.CreateNotification(ctx, notification)
+	} else {
+		var outboxItem *models.OutboxNotification
+		outboxItem, err = models.BuildOutboxEntry(notification, s.topicFor(notification.Priority))
+		if err == nil {
+			err = s.repository.CreateNotificationWithOutbox(ctx, notification, outboxItem)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to backfill notification: %w", err)
+	}
+
+	return notification, nil
+}
+
+// validateNotificationRequest checks the notification type, channel and priority
+func validateNotificationRequest(req *models.CreateNotificationRequest) error {
+	if !models.IsValidNotificationType(req.Type) {
+		return fmt.Errorf("%w type: %s", ErrInvalidNotification, req.Type)
+	}
 	if !models.IsValidChannel(req.Channel) {
-		return nil, fmt.Errorf("invalid notification channel: %s", req.Channel)
+		return fmt.Errorf("%w channel: %s", ErrInvalidNotification, req.Channel)
 	}
+	return validatePriority(req)
+}
 
-	// Create notification
-	notification := &models.Notification{
-		ID:           uuid.New(),
+// validatePriority defaults an empty priority to medium and rejects unknown ones
+func validatePriority(req *models.CreateNotificationRequest) error {
+	if req.Priority == "" {
+		req.Priority = models.PriorityMedium
+	}
+	if !models.IsValidPriority(req.Priority) {
+		return fmt.Errorf("%w: %s", ErrInvalidPriority, req.Priority)
+	}
+	return nil
+}
+
+// defaultAttachments returns the attachments declared by the newest active
+// template for the type and channel. Lookup failures only cost the defaults.
+func (s *notificationService) defaultAttachments(ctx context.Context, notificationType models.NotificationType, channel models.NotificationChannel) models.Attachments {
+	templates, err := s.repository.GetNotificationTemplates(ctx, notificationType, channel)
+	if err != nil {
+		log.Printf("Failed to load default attachments for %s/%s: %v", notificationType, channel, err)
+		return nil
+	}
+	if len(templates) == 0 {
+		return nil
+	}
+	return templates[0].DefaultAttachments
+}
+
+// newNotificationFromRequest builds a queued notification from a create request
+func newNotificationFromRequest(req *models.CreateNotificationRequest, id uuid.UUID, createdAt time.Time) *models.Notification {
+	return &models.Notification{
+		ID:           id,
 		UserID:       req.UserID,
 		Type:         req.Type,
 		Channel:      req.Channel,
@@ -65,68 +376,197 @@ func (s *notificationService) CreateNotification(ctx context.Context, req *model
 		Title:        req.Title,
 		Message:      req.Message,
 		Metadata:     req.Metadata,
+		Attachments:  req.Attachments,
+		DedupeKey:    req.DedupeKey,
 		Status:       models.StatusQueued,
-		CreatedAt:    time.Now(),
+		CreatedAt:    createdAt,
 		ScheduledFor: req.ScheduledFor,
 	}
+}
 
-	// Save to database
-	if err := s.repository.CreateNotification(ctx, notification); err != nil {
-		return nil, fmt.Errorf("failed to create notification: %w", err)
+// GetUserNotifications retrieves a page of a user's notifications matching the filter
+func (s *notificationService) GetUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Create outbox entry for Kafka
-	outboxItem := &models.OutboxNotification{
-		NotificationID: notification.ID,
-		Topic:          s.topic,
-		Payload: models.JSONMap{
-			"id":         notification.ID.String(),
-			"user_id":    notification.UserID.String(),
-			"type":       notification.Type,
-			"channel":    notification.Channel,
-			"priority":   notification.Priority,
-			"title":      notification.Title,
-			"message":    notification.Message,
-			"created_at": notification.CreatedAt,
-		},
-		Published: false,
-		CreatedAt: time.Now(),
+	return s.repository.QueryUserNotifications(ctx, userID, filter.WithDefaults())
+}
+
+// CountUserNotifications counts all of a user's notifications matching the
+// filter across every page
+func (s *notificationService) CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	return s.repository.CountUserNotifications(ctx, userID, filter)
+}
+
+// GetNotification retrieves a notification with its delivery attempts
+func (s *notificationService) GetNotification(ctx context.Context, notificationID uuid.UUID) (*models.NotificationDetail, error) {
+	notification, err := s.repository.GetNotificationByID(ctx, notificationID)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.repository.CreateOutboxEntry(ctx, outboxItem); err != nil {
-		return nil, fmt.Errorf("failed to create outbox entry: %w", err)
+	attempts, err := s.repository.GetDeliveryAttempts(ctx, notificationID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Immediate publish only if explicitly enabled (OUTBOX_IMMEDIATE_PUBLISH=true)
-	if strings.EqualFold(os.Getenv("OUTBOX_IMMEDIATE_PUBLISH"), "true") {
-		_ = s.ProcessOutbox(ctx)
+	return &models.NotificationDetail{Notification: *notification, DeliveryAttempts: attempts}, nil
+}
+
+// GetNotificationByDedupeKey retrieves a user's notification by its client-generated dedupe key
+func (s *notificationService) GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	if dedupeKey == "" {
+		return nil, fmt.Errorf("dedupe key is required")
 	}
 
-	return notification, nil
+	return s.repository.GetNotificationByDedupeKey(ctx, userID, dedupeKey)
 }
 
-// GetUserNotifications retrieves notifications for a specific user
-func (s *notificationService) GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Notification, error) {
-	if limit <= 0 {
-		limit = 50 // Default limit
+// GetUnreadCount counts a user's unread notifications, on one channel if set
+func (s *notificationService) GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error) {
+	if channel != "" && !models.IsValidChannel(channel) {
+		return 0, fmt.Errorf("%w channel: %s", ErrInvalidNotification, channel)
 	}
-	if offset < 0 {
-		offset = 0
+	return s.repository.GetUnreadCount(ctx, userID, channel)
+}
+
+// MarkAsRead marks a user's notification as read
+func (s *notificationService) MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error {
+	return s.repository.MarkAsRead(ctx, notificationID, userID)
+}
+
+// MarkAsDelivered records that the user's client received the notification
+func (s *notificationService) MarkAsDelivered(ctx context.Context, notificationID, userID uuid.UUID) error {
+	return s.repository.AcknowledgeDelivery(ctx, notificationID, userID)
+}
+
+// MarkAllAsRead marks a user's unread notifications created at or before the
+// cutoff (now when zero) as read, optionally only one type, and returns how many
+func (s *notificationService) MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error) {
+	if notificationType != "" && !models.IsValidNotificationType(notificationType) {
+		return 0, fmt.Errorf("%w type: %s", ErrInvalidNotification, notificationType)
 	}
+	if before.IsZero() {
+		before = s.now()
+	}
+	return s.repository.MarkAllAsRead(ctx, userID, before, notificationType)
+}
 
-	return s.repository.GetUserNotifications(ctx, userID, limit, offset)
+// UpdateUserPreferences updates notification preferences for a user, returns the
+// stored preference and sends a summary of what changed. The summary is best
+// effort and never fails the update.
+func (s *notificationService) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, req *models.NotificationPreferencesRequest) (*models.UserNotificationPreferences, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	current, loadErr := s.repository.GetUserPreferences(ctx, userID)
+	if loadErr != nil {
+		log.Printf("Failed to load current preferences for user %s: %v", userID, loadErr)
+	}
+
+	// Without the previous settings there is nothing reliable to summarize
+	return s.storePreference(ctx, userID, req.Preferences(userID), current, loadErr == nil)
 }
 
-// MarkAsRead marks a notification as read
-func (s *notificationService) MarkAsRead(ctx context.Context, notificationID uuid.UUID) error {
-	return s.repository.MarkAsRead(ctx, notificationID)
+// PatchUserPreferences updates the fields set in patch and keeps the stored
+// values of the rest
+func (s *notificationService) PatchUserPreferences(ctx context.Context, userID uuid.UUID, patch *models.NotificationPreferencesPatch) (*models.UserNotificationPreferences, error) {
+	current, err := s.repository.GetUserPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current preferences: %w", err)
+	}
+
+	req := patch.Apply(FindPreference(current, patch.Type, patch.Channel))
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.storePreference(ctx, userID, req.Preferences(userID), current, true)
 }
 
-// UpdateUserPreferences updates notification preferences for a user
-func (s *notificationService) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) error {
-	prefs.UserID = userID
+// storePreference upserts prefs and, when summarize is set, sends a summary of
+// how it differs from the user's current preferences
+func (s *notificationService) storePreference(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences, current []models.UserNotificationPreferences, summarize bool) (*models.UserNotificationPreferences, error) {
+	var before *models.UserNotificationPreferences
+	if existing := FindPreference(current, prefs.Type, prefs.Channel); existing != nil {
+		previous := *existing
+		before = &previous
+	}
+
 	prefs.UpdatedAt = time.Now()
-	return s.repository.UpdateUserPreferences(ctx, userID, prefs)
+	stored, err := s.repository.UpdateUserPreferences(ctx, userID, prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !summarize {
+		return stored, nil
+	}
+
+	updated := append([]models.UserNotificationPreferences{*stored}, current...)
+	if err := s.sendPreferencesDigest(ctx, userID, updated, diffPreference(before, stored)); err != nil {
+		log.Printf("Failed to send preferences summary to user %s: %v", userID, err)
+	}
+	return stored, nil
+}
+
+// UpdateUserPreferencesBulk applies several preference updates in one
+// transaction and returns the user's full preference set. One summary covers
+// every change.
+func (s *notificationService) UpdateUserPreferencesBulk(ctx context.Context, userID uuid.UUID, reqs []models.NotificationPreferencesRequest) ([]models.UserNotificationPreferences, error) {
+	if err := models.ValidatePreferencesBatch(reqs); err != nil {
+		return nil, err
+	}
+
+	current, loadErr := s.repository.GetUserPreferences(ctx, userID)
+	if loadErr != nil {
+		log.Printf("Failed to load current preferences for user %s: %v", userID, loadErr)
+	}
+
+	prefs := make([]models.UserNotificationPreferences, len(reqs))
+	for i := range reqs {
+		prefs[i] = *reqs[i].Preferences(userID)
+	}
+
+	stored, err := s.repository.UpdateUserPreferencesBatch(ctx, userID, prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	if loadErr != nil {
+		return stored, nil
+	}
+
+	var changes []PreferenceChange
+	for i := range prefs {
+		changes = append(changes, diffPreference(FindPreference(current, prefs[i].Type, prefs[i].Channel), &prefs[i])...)
+	}
+	if err := s.sendPreferencesDigest(ctx, userID, stored, changes); err != nil {
+		log.Printf("Failed to send preferences summary to user %s: %v", userID, err)
+	}
+	return stored, nil
+}
+
+// DeleteUserPreference removes a user's preference for a type and channel so
+// the defaults apply to it again
+func (s *notificationService) DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error {
+	if err := models.ValidatePreferenceKey(notificationType, channel); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteUserPreference(ctx, userID, notificationType, channel)
+}
+
+// DeleteUserPreferences removes every preference of a user, returning how many
+// were deleted
+func (s *notificationService) DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.repository.DeleteUserPreferences(ctx, userID)
 }
 
 // GetUserPreferences retrieves notification preferences for a user
@@ -134,64 +574,67 @@ func (s *notificationService) GetUserPreferences(ctx context.Context, userID uui
 	return s.repository.GetUserPreferences(ctx, userID)
 }
 
-// CreateDailyReminder creates a daily reminder for a user
-func (s *notificationService) CreateDailyReminder(ctx context.Context, user models.User) error {
-	// Get user engagement streak
-	streak, err := s.repository.GetUserEngagementStreak(ctx, user.ID, "practice")
-	if err != nil {
-		// Continue with default streak value
+// GetUserPreference returns the preference that applies to a user's type and
+// channel: the stored row, or the enabled default delivery assumes without one
+func (s *notificationService) GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.ResolvedPreference, error) {
+	if err := models.ValidatePreferenceKey(notificationType, channel); err != nil {
+		return nil, err
 	}
 
-	currentStreak := 0
-	if streak != nil {
-		currentStreak = streak.CurrentStreak
+	pref, err := s.repository.GetUserPreference(ctx, userID, notificationType, channel)
+	if errors.Is(err, repository.ErrPreferenceNotFound) {
+		return &models.ResolvedPreference{
+			UserNotificationPreferences: models.UserNotificationPreferences{
+				UserID: userID, Type: notificationType, Channel: channel, Enabled: true,
+			},
+			Default: true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Create daily reminder notification
-	notification := &models.Notification{
-		ID:        uuid.New(),
-		UserID:    user.ID,
-		Type:      models.DailyReminder,
-		Channel:   models.ChannelInApp,
-		Priority:  models.PriorityMedium,
-		Title:     stringPtr("Time to Practice!"),
-		Message:   fmt.Sprintf("Hey %s! It's time for your daily practice session. Keep your %d-day streak alive! 🔥", user.Name, currentStreak),
-		Status:    models.StatusQueued,
-		CreatedAt: time.Now(),
+	return &models.ResolvedPreference{UserNotificationPreferences: *pref}, nil
+}
+
+// CreateDailyReminder creates a daily reminder for a user from the daily_reminder
+// in_app template, with the user's stored .Name and current .Streak as data.
+// Like the scheduler it creates nothing when the user disabled daily reminders
+// or already got one on their local day.
+func (s *notificationService) CreateDailyReminder(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
 	}
 
-	// Save to database
-	if err := s.repository.CreateNotification(ctx, notification); err != nil {
-		return fmt.Errorf("failed to create daily reminder: %w", err)
+	// Get user engagement streak; without one the streak is 0 and the day is UTC's
+	currentStreak, loc := 0, time.UTC
+	if streak, err := s.repository.GetUserEngagementStreak(ctx, user.ID, "practice"); err == nil && streak != nil {
+		currentStreak, loc = streak.CurrentStreak, LoadLocation(streak.Timezone)
 	}
 
-	// Create outbox entry
-	outboxItem := &models.OutboxNotification{
-		NotificationID: notification.ID,
-		Topic:          s.topic,
-		Payload: map[string]interface{}{
-			"id":         notification.ID.String(),
-			"user_id":    notification.UserID.String(),
-			"type":       notification.Type,
-			"channel":    notification.Channel,
-			"priority":   notification.Priority,
-			"title":      notification.Title,
-			"message":    notification.Message,
-			"created_at": notification.CreatedAt,
-		},
-		Published: false,
-		CreatedAt: time.Now(),
+	if err := s.checkReminderAllowed(ctx, user.ID, models.DailyReminder, loc); err != nil {
+		return err
 	}
 
-	if err := s.repository.CreateOutboxEntry(ctx, outboxItem); err != nil {
-		return fmt.Errorf("failed to create outbox entry for daily reminder: %w", err)
+	data := map[string]interface{}{"Name": user.Name, "Streak": currentStreak}
+	if _, err := s.CreateFromTemplate(ctx, user.ID, models.DailyReminder, models.ChannelInApp, data); err != nil {
+		return fmt.Errorf("failed to create daily reminder: %w", err)
 	}
 
 	return nil
 }
 
-// CreateStreakReminder creates a streak reminder for a user
-func (s *notificationService) CreateStreakReminder(ctx context.Context, user models.User) error {
+// CreateStreakReminder creates a streak reminder for a user from the
+// streak_reminder in_app template, with the user's stored .Name and .Streak as
+// data. Like the scheduler it creates nothing when the user disabled streak
+// reminders or already got one on their local day.
+func (s *notificationService) CreateStreakReminder(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+
 	// Get user engagement streak
 	streak, err := s.repository.GetUserEngagementStreak(ctx, user.ID, "practice")
 	if err != nil {
@@ -199,95 +642,243 @@ func (s *notificationService) CreateStreakReminder(ctx context.Context, user mod
 	}
 
 	if streak.CurrentStreak == 0 {
-		return fmt.Errorf("user has no active streak")
+		return ErrNoActiveStreak
 	}
 
-	// Create streak reminder notification
-	notification := &models.Notification{
-		ID:        uuid.New(),
-		UserID:    user.ID,
-		Type:      models.StreakReminder,
-		Channel:   models.ChannelInApp,
-		Priority:  models.PriorityHigh,
-		Title:     stringPtr("Don't Break Your Streak!"),
-		Message:   fmt.Sprintf("%s, you haven't practiced today! Your %d-day streak is at risk. Practice now to keep it going!", user.Name, streak.CurrentStreak),
-		Status:    models.StatusQueued,
-		CreatedAt: time.Now(),
+	if err := s.checkReminderAllowed(ctx, user.ID, models.StreakReminder, LoadLocation(streak.Timezone)); err != nil {
+		return err
 	}
 
-	// Save to database
-	if err := s.repository.CreateNotification(ctx, notification); err != nil {
+	data := map[string]interface{}{"Name": user.Name, "Streak": streak.CurrentStreak}
+	if _, err := s.CreateFromTemplate(ctx, user.ID, models.StreakReminder, models.ChannelInApp, data); err != nil {
 		return fmt.Errorf("failed to create streak reminder: %w", err)
 	}
 
-	// Create outbox entry
-	outboxItem := &models.OutboxNotification{
-		NotificationID: notification.ID,
-		Topic:          s.topic,
-		Payload: models.JSONMap{
-			"id":         notification.ID.String(),
-			"user_id":    notification.UserID.String(),
-			"type":       notification.Type,
-			"channel":    notification.Channel,
-			"priority":   notification.Priority,
-			"title":      notification.Title,
-			"message":    notification.Message,
-			"created_at": notification.CreatedAt,
-		},
-		Published: false,
-		CreatedAt: time.Now(),
+	return nil
+}
+
+// checkReminderAllowed returns ErrReminderDisabled when the user turned off
+// in_app reminders of the type and ErrAlreadySentToday when one was already
+// sent since midnight in loc. Without a stored preference reminders are on.
+func (s *notificationService) checkReminderAllowed(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, loc *time.Location) error {
+	prefs, err := s.repository.GetUserPreferences(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load preferences: %w", err)
+	}
+	if pref := FindPreference(prefs, notificationType, models.ChannelInApp); pref != nil && !pref.Enabled {
+		return fmt.Errorf("%w: %s", ErrReminderDisabled, notificationType)
 	}
 
-	if err := s.repository.CreateOutboxEntry(ctx, outboxItem); err != nil {
-		return fmt.Errorf("failed to create outbox entry for streak reminder: %w", err)
+	sent, err := s.repository.HasNotificationOfTypeToday(ctx, userID, notificationType, loc.String())
+	if err != nil {
+		return err
+	}
+	if sent {
+		return fmt.Errorf("%w: %s", ErrAlreadySentToday, notificationType)
 	}
 
 	return nil
 }
 
-// ProcessOutbox processes unpublished outbox items
-func (s *notificationService) ProcessOutbox(ctx context.Context) error {
-	// Get unpublished outbox items
-	outboxItems, err := s.repository.GetUnpublishedOutbox(ctx, 100)
+// OutboxItemError describes an outbox item that failed to publish
+type OutboxItemError struct {
+	OutboxID       int64     `json:"outbox_id"`
+	NotificationID uuid.UUID `json:"notification_id"`
+	Error          string    `json:"error"`
+}
+
+// OutboxResult summarizes one ProcessOutbox run
+type OutboxResult struct {
+	Published int               `json:"published"`
+	Failed    int               `json:"failed"`
+	Remaining int               `json:"remaining"`
+	Errors    []OutboxItemError `json:"errors,omitempty"`
+}
+
+// readyProducer is implemented by producers that connect after startup, such
+// as kafka.ManagedProducer
+type readyProducer interface {
+	Ready() bool
+}
+
+// outboxSender publishes encoded outbox messages, recording failed ones in
+// failed by the outbox ID in their Metadata
+type outboxSender func(ctx context.Context, messages []*sarama.ProducerMessage, failed map[int64]error)
+
+// ProcessOutbox processes unpublished outbox items. A failing item is recorded
+// against its outbox row and skipped so the rest of the batch still goes out.
+// The result counts what happened; the returned error joins every failure.
+// While the producer is still connecting nothing is claimed and
+// kafka.ErrProducerNotReady is returned. With an async producer configured
+// the batch goes through ProcessOutboxAsync.
+func (s *notificationService) ProcessOutbox(ctx context.Context) (*OutboxResult, error) {
+	if s.asyncProducer != nil {
+		return s.ProcessOutboxAsync(ctx)
+	}
+	if p, ok := s.producer.(readyProducer); ok && !p.Ready() {
+		return nil, kafka.ErrProducerNotReady
+	}
+	return s.processOutbox(ctx, s.sendOutboxSync)
+}
+
+// processOutbox claims a batch, publishes it with send and records the outcome
+func (s *notificationService) processOutbox(ctx context.Context, send outboxSender) (*OutboxResult, error) {
+	// Claim unpublished outbox items so other producer instances skip them
+	outboxItems, err := s.repository.ClaimUnpublishedOutbox(ctx, s.workerID, s.outboxBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim unpublished outbox: %w", err)
+	}
+
+	result := &OutboxResult{}
+	var errs []error
+
+	if len(outboxItems) > 0 {
+		errs = s.publishOutboxItems(ctx, outboxItems, send, result)
+	}
+
+	remaining, err := s.repository.CountPendingOutbox(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get unpublished outbox: %w", err)
+		errs = append(errs, err)
 	}
+	result.Remaining = remaining
+
+	return result, errors.Join(errs...)
+}
 
+// publishOutboxItems sends a claimed batch to Kafka and records the outcome per item
+func (s *notificationService) publishOutboxItems(ctx context.Context, outboxItems []models.OutboxNotification, send outboxSender, result *OutboxResult) []error {
+	// Publish the whole batch in one round trip, keyed by user ID so a user's
+	// notifications share a partition and keep their order. Metadata carries the outbox ID
+	// so per-message failures can be traced back to their rows. Headers repeat
+	// the routing fields so consumers can filter without decoding the payload.
+	// Payloads that don't encode as a notification message, or encode larger
+	// than the producer's message limit, will never publish, so they are
+	// dead-lettered straight away.
+	failed := make(map[int64]error)
+	unpublishable := make(map[int64]bool)
+	messages := make([]*sarama.ProducerMessage, 0, len(outboxItems))
 	for _, item := range outboxItems {
-		// Publish to Kafka
-		message := &sarama.ProducerMessage{
-			Topic: item.Topic,
-			Key:   sarama.StringEncoder(item.NotificationID.String()),
-			Value: sarama.ByteEncoder(mustMarshalJSON(item.Payload)),
+		key, value, err := models.EncodeOutboxPayload(item.Payload)
+		if err != nil {
+			failed[item.ID] = fmt.Errorf("payload is not a valid notification: %w", err)
+			unpublishable[item.ID] = true
+			continue
+		}
+		msg := &sarama.ProducerMessage{
+			Topic:    item.Topic,
+			Key:      sarama.StringEncoder(key),
+			Value:    sarama.ByteEncoder(value),
+			Headers:  kafka.NotificationHeaders(item.Payload),
+			Metadata: item.ID,
+		}
+		if err := s.checkMessageSize(msg); err != nil {
+			failed[item.ID] = err
+			unpublishable[item.ID] = true
+			continue
 		}
+		messages = append(messages, msg)
+	}
 
-		partition, offset, err := s.producer.SendMessage(message)
-		if err != nil {
-			return fmt.Errorf("failed to send message to Kafka: %w", err)
+	if len(messages) > 0 {
+		send(ctx, messages, failed)
+	}
+
+	var errs []error
+	var published []models.OutboxNotification
+	for _, item := range outboxItems {
+		sendErr, ok := failed[item.ID]
+		if !ok {
+			published = append(published, item)
+			continue
 		}
 
-		// Mark as published
-		if err := s.repository.MarkOutboxPublished(ctx, item.ID); err != nil {
-			return fmt.Errorf("failed to mark outbox as published: %w", err)
+		attempts := item.Attempts + 1
+		maxAttempts := s.outboxMaxAttempts
+		if unpublishable[item.ID] {
+			maxAttempts = attempts
+		}
+		nextAttemptAt := time.Now().Add(outboxBackoff(attempts, s.outboxBackoffBase, s.outboxBackoffMax))
+		if unpublishable[item.ID] {
+			log.Printf("Outbox item %d is dead, it can never be published: %v", item.ID, sendErr)
+		} else if attempts >= maxAttempts {
+			log.Printf("Outbox item %d is dead after %d failed publishes: %v", item.ID, attempts, sendErr)
+		} else {
+			log.Printf("Failed to publish outbox item %d (attempt %d), retrying at %s: %v",
+				item.ID, attempts, nextAttemptAt.Format(time.RFC3339), sendErr)
 		}
+		if err := s.repository.IncrementOutboxAttempts(ctx, item.ID, sendErr.Error(), nextAttemptAt, maxAttempts); err != nil {
+			errs = append(errs, err)
+		}
+		result.recordFailure(item, sendErr)
+		errs = append(errs, fmt.Errorf("failed to send outbox item %d to Kafka: %w", item.ID, sendErr))
+	}
 
-		// Log success
-		fmt.Printf("Published notification %s to Kafka: partition=%d, offset=%d\n",
-			item.NotificationID, partition, offset)
+	if len(published) == 0 {
+		return errs
 	}
 
+	// Mark the successful messages published and their notifications sent in a single update
+	ids := make([]int64, len(published))
+	for i, item := range published {
+		ids[i] = item.ID
+	}
+	if err := s.repository.MarkOutboxBatchSent(ctx, ids); err != nil {
+		// Sent but still pending: these rows will be published again
+		markErr := fmt.Errorf("failed to mark as published: %w", err)
+		for _, item := range published {
+			result.recordFailure(item, markErr)
+		}
+		return append(errs, fmt.Errorf("failed to mark %d outbox items as published: %w", len(ids), err))
+	}
+
+	result.Published = len(published)
+	return errs
+}
+
+// checkMessageSize rejects a message the producer would refuse as larger than
+// its MaxMessageBytes, measured the way sarama measures record batches
+func (s *notificationService) checkMessageSize(msg *sarama.ProducerMessage) error {
+	if s.maxMessageBytes <= 0 {
+		return nil
+	}
+	if size := msg.ByteSize(2); size > s.maxMessageBytes {
+		return fmt.Errorf("message is %d bytes, over the %d byte limit: %w", size, s.maxMessageBytes, sarama.ErrMessageSizeTooLarge)
+	}
 	return nil
 }
 
+// sendOutboxSync publishes the batch in one SendMessages call
+func (s *notificationService) sendOutboxSync(ctx context.Context, messages []*sarama.ProducerMessage, failed map[int64]error) {
+	err := s.producer.SendMessages(messages)
+	if err == nil {
+		return
+	}
+
+	var producerErrs sarama.ProducerErrors
+	if errors.As(err, &producerErrs) {
+		for _, pe := range producerErrs {
+			if id, ok := pe.Msg.Metadata.(int64); ok {
+				failed[id] = pe.Err
+			}
+		}
+		return
+	}
+	for _, msg := range messages {
+		failed[msg.Metadata.(int64)] = err
+	}
+}
+
+// recordFailure counts a failed outbox item and keeps its error
+func (r *OutboxResult) recordFailure(item models.OutboxNotification, err error) {
+	r.Failed++
+	r.Errors = append(r.Errors, OutboxItemError{
+		OutboxID:       item.ID,
+		NotificationID: item.NotificationID,
+		Error:          err.Error(),
+	})
+}
+
 // Helper functions
 func stringPtr(s string) *string {
 	return &s
 }
-
-func mustMarshalJSON(v interface{}) []byte {
-	data, err := json.Marshal(v)
-	if err != nil {
-		panic(fmt.Sprintf("failed to marshal JSON: %v", err))
-	}
-	return data
-}
@@ -2,15 +2,24 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"kafka-notify/internal/kafka"
+	"kafka-notify/pkg/apperr"
 	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
 
 	"github.com/IBM/sarama"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockNotificationRepository is a mock implementation of NotificationRepository
@@ -23,8 +32,51 @@ func (m *MockNotificationRepository) CreateNotification(ctx context.Context, not
 	return args.Error(0)
 }
 
-func (m *MockNotificationRepository) GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Notification, error) {
-	args := m.Called(ctx, userID, limit, offset)
+func (m *MockNotificationRepository) CreateNotificationWithOutbox(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification) error {
+	args := m.Called(ctx, notification, outboxItem)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) CreateNotificationsWithOutbox(ctx context.Context, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error {
+	args := m.Called(ctx, notifications, outboxItems)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) CreateNotificationWithIdempotencyKey(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification, key string, expiredBefore time.Time) error {
+	args := m.Called(ctx, notification, outboxItem, key, expiredBefore)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) GetNotificationByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, since time.Time) (*models.Notification, error) {
+	args := m.Called(ctx, userID, key, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) DeleteIdempotencyKeysBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) QueryUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error) {
+	args := m.Called(ctx, userID, channel)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUserNotificationsBetween(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]models.Notification, error) {
+	args := m.Called(ctx, userID, from, to)
 	return args.Get(0).([]models.Notification), args.Error(1)
 }
 
@@ -36,31 +88,125 @@ func (m *MockNotificationRepository) GetNotificationByID(ctx context.Context, no
 	return args.Get(0).(*models.Notification), args.Error(1)
 }
 
-func (m *MockNotificationRepository) MarkAsRead(ctx context.Context, notificationID uuid.UUID) error {
+func (m *MockNotificationRepository) GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	args := m.Called(ctx, userID, dedupeKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetActiveNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	args := m.Called(ctx, userID, dedupeKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) RetireDedupeKey(ctx context.Context, notificationID uuid.UUID) error {
 	args := m.Called(ctx, notificationID)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error {
+	args := m.Called(ctx, notificationID, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error) {
+	args := m.Called(ctx, userID, before, notificationType)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) AcknowledgeDelivery(ctx context.Context, notificationID, userID uuid.UUID) error {
+	args := m.Called(ctx, notificationID, userID)
+	return args.Error(0)
+}
+
 func (m *MockNotificationRepository) MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error {
 	args := m.Called(ctx, notificationID)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) MarkAsFailed(ctx context.Context, notificationID uuid.UUID) error {
+	args := m.Called(ctx, notificationID)
+	return args.Error(0)
+}
+
 func (m *MockNotificationRepository) MarkAsSent(ctx context.Context, notificationID uuid.UUID) error {
 	args := m.Called(ctx, notificationID)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) CancelQueuedNotification(ctx context.Context, notificationID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, notificationID)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockNotificationRepository) GetUnpublishedOutbox(ctx context.Context, limit int) ([]models.OutboxNotification, error) {
 	args := m.Called(ctx, limit)
 	return args.Get(0).([]models.OutboxNotification), args.Error(1)
 }
 
+func (m *MockNotificationRepository) ClaimUnpublishedOutbox(ctx context.Context, workerID string, limit int) ([]models.OutboxNotification, error) {
+	args := m.Called(ctx, workerID, limit)
+	return args.Get(0).([]models.OutboxNotification), args.Error(1)
+}
+
 func (m *MockNotificationRepository) MarkOutboxPublished(ctx context.Context, outboxID int64) error {
 	args := m.Called(ctx, outboxID)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) MarkOutboxBatchSent(ctx context.Context, outboxIDs []int64) error {
+	args := m.Called(ctx, outboxIDs)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) CountPendingOutbox(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetOutboxStats(ctx context.Context) (*models.OutboxStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OutboxStats), args.Error(1)
+}
+
+func (m *MockNotificationRepository) DeletePublishedOutboxBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) IncrementOutboxAttempts(ctx context.Context, outboxID int64, errMsg string, nextAttemptAt time.Time, maxAttempts int) error {
+	args := m.Called(ctx, outboxID, errMsg, nextAttemptAt, maxAttempts)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]models.OutboxNotification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) RequeueOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error {
+	args := m.Called(ctx, outboxItem)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) RequeueFailedNotification(ctx context.Context, outboxItem *models.OutboxNotification) (bool, error) {
+	args := m.Called(ctx, outboxItem)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) RequeueDeadOutbox(ctx context.Context, outboxID int64) error {
+	args := m.Called(ctx, outboxID)
+	return args.Error(0)
+}
+
 func (m *MockNotificationRepository) CreateOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error {
 	args := m.Called(ctx, outboxItem)
 	return args.Error(0)
@@ -71,11 +217,108 @@ func (m *MockNotificationRepository) GetUserPreferences(ctx context.Context, use
 	return args.Get(0).([]models.UserNotificationPreferences), args.Error(1)
 }
 
-func (m *MockNotificationRepository) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) error {
+func (m *MockNotificationRepository) MarkPreferenceSent(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, sentAt time.Time) error {
+	args := m.Called(ctx, userID, notificationType, channel, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) CountNotificationsForUserTypeSince(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, since time.Time) (int, error) {
+	args := m.Called(ctx, userID, notificationType, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) (*models.UserNotificationPreferences, error) {
 	args := m.Called(ctx, userID, prefs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpdateUserPreferencesBatch(ctx context.Context, userID uuid.UUID, prefs []models.UserNotificationPreferences) ([]models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID, prefs)
+	return args.Get(0).([]models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationRepository) DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error {
+	args := m.Called(ctx, userID, notificationType, channel)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID, notificationType, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationRepository) HasNotificationOfTypeToday(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, tz string) (bool, error) {
+	args := m.Called(ctx, userID, notificationType, tz)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUsersNeedingDailyReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUsersNeedingStreakReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUsersNeedingLastChanceAlerts(ctx context.Context, startHour, endHour, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, startHour, endHour, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetUsersNeedingXPGoalReminders(ctx context.Context, reminderHour int, threshold float64, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, reminderHour, threshold, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetActiveUsersForWeeklyRecap(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetInactiveUsersForEngagement(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	args := m.Called(ctx, limit, after)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetLastEngagementNudgeTier(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	args := m.Called(ctx, userID, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetPracticeStreaks(ctx context.Context) ([]models.UserEngagementStreak, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.UserEngagementStreak), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetWeeklyActivitySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (*models.WeeklyActivitySummary, error) {
+	args := m.Called(ctx, userID, weekStart)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WeeklyActivitySummary), args.Error(1)
+}
+
 func (m *MockNotificationRepository) GetUserEngagementStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error) {
 	args := m.Called(ctx, userID, streakType)
 	if args.Get(0) == nil {
@@ -89,6 +332,28 @@ func (m *MockNotificationRepository) UpdateUserEngagementStreak(ctx context.Cont
 	return args.Error(0)
 }
 
+// RecordStreakActivity applies the activity to the streak the expectation
+// returns, as the repository does to the locked row
+func (m *MockNotificationRepository) RecordStreakActivity(ctx context.Context, userID uuid.UUID, streakType string, apply func(*models.UserEngagementStreak)) (*models.UserEngagementStreak, error) {
+	args := m.Called(ctx, userID, streakType, apply)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	streak := args.Get(0).(*models.UserEngagementStreak)
+	apply(streak)
+	return streak, args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpdateStreakTypicalHour(ctx context.Context, userID uuid.UUID, streakType string, typicalHour *int, confidence float64) error {
+	args := m.Called(ctx, userID, streakType, typicalHour, confidence)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) GetPracticeTimestamps(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error) {
+	args := m.Called(ctx, userID, limit)
+	return args.Get(0).([]time.Time), args.Error(1)
+}
+
 func (m *MockNotificationRepository) GetNotificationsByStatus(ctx context.Context, status models.DeliveryStatus, limit int) ([]models.Notification, error) {
 	args := m.Called(ctx, status, limit)
 	return args.Get(0).([]models.Notification), args.Error(1)
@@ -99,11 +364,34 @@ func (m *MockNotificationRepository) GetScheduledNotifications(ctx context.Conte
 	return args.Get(0).([]models.Notification), args.Error(1)
 }
 
+func (m *MockNotificationRepository) ReleaseScheduledNotifications(ctx context.Context, before time.Time, limit int, build repository.OutboxBuilder) ([]models.Notification, error) {
+	args := m.Called(ctx, before, limit, build)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetDeliveryAttempts(ctx context.Context, notificationID uuid.UUID) ([]models.NotificationDeliveryAttempt, error) {
+	args := m.Called(ctx, notificationID)
+	return args.Get(0).([]models.NotificationDeliveryAttempt), args.Error(1)
+}
+
 func (m *MockNotificationRepository) CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error {
 	args := m.Called(ctx, attempt)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepository) GetAttemptSummaryMismatches(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetDeliveryLatencySamples(ctx context.Context, priorities []models.PriorityLevel, since time.Time) ([]models.DeliveryLatencySample, error) {
+	args := m.Called(ctx, priorities, since)
+	return args.Get(0).([]models.DeliveryLatencySample), args.Error(1)
+}
+
 func (m *MockNotificationRepository) GetNotificationTemplates(ctx context.Context, notificationType models.NotificationType, channel models.NotificationChannel) ([]models.NotificationTemplate, error) {
 	args := m.Called(ctx, notificationType, channel)
 	return args.Get(0).([]models.NotificationTemplate), args.Error(1)
@@ -116,11 +404,14 @@ type MockKafkaProducer struct {
 
 func (m *MockKafkaProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
 	args := m.Called(msg)
-	return int32(args.Int(0)), args.Int64(1), args.Error(2)
+	return int32(args.Int(0)), args.Get(1).(int64), args.Error(2)
 }
 
 func (m *MockKafkaProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
 	args := m.Called(msgs)
+	if fn, ok := args.Get(0).(func([]*sarama.ProducerMessage) error); ok {
+		return fn(msgs)
+	}
 	return args.Error(0)
 }
 
@@ -129,6 +420,34 @@ func (m *MockKafkaProducer) Close() error {
 	return args.Error(0)
 }
 
+func (m *MockKafkaProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sarama.ProducerTxnFlagReady
+}
+
+func (m *MockKafkaProducer) IsTransactional() bool {
+	return false
+}
+
+func (m *MockKafkaProducer) BeginTxn() error {
+	return nil
+}
+
+func (m *MockKafkaProducer) CommitTxn() error {
+	return nil
+}
+
+func (m *MockKafkaProducer) AbortTxn() error {
+	return nil
+}
+
+func (m *MockKafkaProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	return nil
+}
+
+func (m *MockKafkaProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	return nil
+}
+
 func TestCreateNotification_ValidRequest(t *testing.T) {
 	// Arrange
 	mockRepo := new(MockNotificationRepository)
@@ -147,8 +466,9 @@ func TestCreateNotification_ValidRequest(t *testing.T) {
 	ctx := context.Background()
 
 	// Mock expectations
-	mockRepo.On("CreateNotification", ctx, mock.AnythingOfType("*models.Notification")).Return(nil)
-	mockRepo.On("CreateOutboxEntry", ctx, mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+	mockRepo.On("GetNotificationTemplates", ctx, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", ctx, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
 
 	// Act
 	notification, err := service.CreateNotification(ctx, req)
@@ -189,6 +509,7 @@ func TestCreateNotification_InvalidType(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, notification)
 	assert.Contains(t, err.Error(), "invalid notification type")
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
 }
 
 func TestCreateNotification_InvalidChannel(t *testing.T) {
@@ -214,6 +535,54 @@ func TestCreateNotification_InvalidChannel(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, notification)
 	assert.Contains(t, err.Error(), "invalid notification channel")
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+}
+
+func TestCreateNotification_Priority(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority models.PriorityLevel
+		want     models.PriorityLevel
+		wantErr  bool
+	}{
+		{"empty defaults to medium", "", models.PriorityMedium, false},
+		{"low", models.PriorityLow, models.PriorityLow, false},
+		{"urgent", models.PriorityUrgent, models.PriorityUrgent, false},
+		{"unknown", "banana", "", true},
+		{"wrong case", "HIGH", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+			req := &models.CreateNotificationRequest{
+				UserID:   uuid.New(),
+				Type:     models.DailyReminder,
+				Channel:  models.ChannelInApp,
+				Priority: tt.priority,
+				Message:  "Test notification",
+			}
+			mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+			mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+			mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+			// Act
+			notification, err := service.CreateNotification(context.Background(), req)
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPriority)
+				assert.ErrorIs(t, err, ErrInvalidNotification)
+				assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+				assert.Contains(t, err.Error(), "invalid notification priority")
+				mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, notification.Priority)
+		})
+	}
 }
 
 func TestGetUserNotifications_ValidRequest(t *testing.T) {
@@ -248,10 +617,11 @@ func TestGetUserNotifications_ValidRequest(t *testing.T) {
 	}
 
 	// Mock expectations
-	mockRepo.On("GetUserNotifications", ctx, userID, limit, offset).Return(expectedNotifications, nil)
+	mockRepo.On("QueryUserNotifications", ctx, userID, models.NotificationFilter{Sort: models.SortNewest, Limit: limit, Offset: offset}).
+		Return(expectedNotifications, nil)
 
 	// Act
-	notifications, err := service.GetUserNotifications(ctx, userID, limit, offset)
+	notifications, err := service.GetUserNotifications(ctx, userID, models.NotificationFilter{Limit: limit, Offset: offset})
 
 	// Assert
 	assert.NoError(t, err)
@@ -261,6 +631,28 @@ func TestGetUserNotifications_ValidRequest(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestMarkAsDelivered_AcknowledgesForUser(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+
+	notificationID := uuid.New()
+	userID := uuid.New()
+	ctx := context.Background()
+
+	mockRepo.On("AcknowledgeDelivery", ctx, notificationID, userID).Return(nil)
+
+	// Act
+	err := service.MarkAsDelivered(ctx, notificationID, userID)
+
+	// Assert
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestMarkAsRead_ValidRequest(t *testing.T) {
 	// Arrange
 	mockRepo := new(MockNotificationRepository)
@@ -269,16 +661,1287 @@ func TestMarkAsRead_ValidRequest(t *testing.T) {
 	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
 
 	notificationID := uuid.New()
+	userID := uuid.New()
+	ctx := context.Background()
+
+	// Mock expectations
+	mockRepo.On("MarkAsRead", ctx, notificationID, userID).Return(nil)
+
+	// Act
+	err := service.MarkAsRead(ctx, notificationID, userID)
+
+	// Assert
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetNotificationByDedupeKey_Found(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+
+	userID := uuid.New()
+	dedupeKey := "daily-reminder:2024-03-12"
 	ctx := context.Background()
 
+	expected := &models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      models.DailyReminder,
+		Channel:   models.ChannelInApp,
+		Message:   "Test notification",
+		DedupeKey: &dedupeKey,
+	}
+
 	// Mock expectations
-	mockRepo.On("MarkAsRead", ctx, notificationID).Return(nil)
+	mockRepo.On("GetNotificationByDedupeKey", ctx, userID, dedupeKey).Return(expected, nil)
 
 	// Act
-	err := service.MarkAsRead(ctx, notificationID)
+	notification, err := service.GetNotificationByDedupeKey(ctx, userID, dedupeKey)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Equal(t, expected, notification)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetNotificationByDedupeKey_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+
+	userID := uuid.New()
+	ctx := context.Background()
+
+	// Mock expectations
+	mockRepo.On("GetNotificationByDedupeKey", ctx, userID, "missing").
+		Return(nil, fmt.Errorf("%w: dedupe key %q", repository.ErrNotificationNotFound, "missing"))
+
+	// Act
+	notification, err := service.GetNotificationByDedupeKey(ctx, userID, "missing")
+
+	// Assert
+	assert.Nil(t, notification)
+	assert.ErrorIs(t, err, repository.ErrNotificationNotFound)
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetNotificationByDedupeKey_EmptyKey(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+
+	// Act
+	notification, err := service.GetNotificationByDedupeKey(context.Background(), uuid.New(), "")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, notification)
+	mockRepo.AssertNotCalled(t, "GetNotificationByDedupeKey")
+}
+
+func newBackfillRequest(createdAt time.Time) *models.CreateNotificationRequest {
+	id := uuid.New()
+	return &models.CreateNotificationRequest{
+		ID:        &id,
+		CreatedAt: &createdAt,
+		UserID:    uuid.New(),
+		Type:      models.DailyReminder,
+		Channel:   models.ChannelInApp,
+		Message:   "Legacy notification",
+	}
+}
+
+func TestCreateNotification_IgnoresBackfillFields(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	req := newBackfillRequest(time.Now().Add(-365 * 24 * time.Hour))
+	ctx := context.Background()
+
+	mockRepo.On("GetNotificationTemplates", ctx, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", ctx, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(ctx, req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, *req.ID, notification.ID)
+	assert.Equal(t, uuid.Version(7), notification.ID.Version())
+	assert.WithinDuration(t, time.Now(), notification.CreatedAt, time.Minute)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillNotification_RecentRowGetsOutboxEntry(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	req := newBackfillRequest(time.Now().Add(-time.Hour))
+	ctx := context.Background()
+
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.ID == *req.ID && n.CreatedAt.Equal(*req.CreatedAt)
+	}), mock.MatchedBy(func(o *models.OutboxNotification) bool {
+		return o.NotificationID == *req.ID
+	})).Return(nil)
+
+	// Act
+	notification, err := service.BackfillNotification(ctx, req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, *req.ID, notification.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillNotification_HistoricalRowSkipsOutbox(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	req := newBackfillRequest(time.Now().Add(-BackfillOutboxCutoff - time.Hour))
+	ctx := context.Background()
+
+	mockRepo.On("CreateNotification", ctx, mock.AnythingOfType("*models.Notification")).Return(nil)
+
+	// Act
+	notification, err := service.BackfillNotification(ctx, req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, *req.ID, notification.ID)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBackfillNotification_Conflict(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	req := newBackfillRequest(time.Now().Add(-time.Hour))
+	ctx := context.Background()
+
+	// The outbox entry shares the transaction, so it is rolled back with the conflicting insert
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Return(fmt.Errorf("%w: %s", repository.ErrNotificationExists, *req.ID))
+
+	// Act
+	notification, err := service.BackfillNotification(ctx, req)
+
+	// Assert
+	assert.Nil(t, notification)
+	assert.ErrorIs(t, err, repository.ErrNotificationExists)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillNotification_Validation(t *testing.T) {
+	service := NewNotificationService(new(MockNotificationRepository), new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	future := newBackfillRequest(time.Now().Add(time.Hour))
+	_, err := service.BackfillNotification(ctx, future)
+	assert.ErrorIs(t, err, ErrInvalidBackfill)
+
+	missingID := newBackfillRequest(time.Now().Add(-time.Hour))
+	missingID.ID = nil
+	_, err = service.BackfillNotification(ctx, missingID)
+	assert.ErrorIs(t, err, ErrInvalidBackfill)
+
+	badType := newBackfillRequest(time.Now().Add(-time.Hour))
+	badType.Type = "invalid_type"
+	_, err = service.BackfillNotification(ctx, badType)
+	assert.ErrorIs(t, err, ErrInvalidBackfill)
+}
+
+func TestCreateStreakReminder_SavesNotificationWithOutbox(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{UserID: user.ID, CurrentStreak: 5}, nil)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", ctx, user.ID, models.StreakReminder, "UTC").Return(false, nil)
+	mockRepo.On("GetNotificationTemplates", ctx, models.StreakReminder, models.ChannelInApp).
+		Return([]models.NotificationTemplate{streakReminderTemplate()}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.MatchedBy(func(o *models.OutboxNotification) bool {
+		notification, ok := o.Payload["notification"].(map[string]interface{})
+		return ok && o.Topic == "test-topic" && notification["type"] == string(models.StreakReminder)
+	})).Return(errors.New("outbox insert failed"))
+
+	// Act
+	err := service.CreateStreakReminder(ctx, user.ID)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create streak reminder")
+	mockRepo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateStreakReminder_NoActiveStreak(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{UserID: user.ID, CurrentStreak: 0}, nil)
+
+	// Act
+	err := service.CreateStreakReminder(ctx, user.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoActiveStreak)
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUserNotifications_InvalidFilter(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	filter := models.NotificationFilter{Types: []models.NotificationType{models.DailyReminder}, Statuses: []models.DeliveryStatus{"pending"}}
+
+	// Act
+	_, err := service.GetUserNotifications(context.Background(), uuid.New(), filter)
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrInvalidFilter)
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "QueryUserNotifications", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMarkAllAsRead_DefaultsCutoffToNow(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+
+	userID := uuid.New()
+	ctx := context.Background()
+	mockRepo.On("MarkAllAsRead", ctx, userID, now, models.NotificationType("")).Return(int64(4), nil)
+
+	// Act
+	updated, err := service.MarkAllAsRead(ctx, userID, time.Time{}, "")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), updated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMarkAllAsRead_InvalidType(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	_, err := service.MarkAllAsRead(context.Background(), uuid.New(), time.Now(), "nope")
+
+	// Assert
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "MarkAllAsRead", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUserPreferences_InvalidChannel(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	req := &models.NotificationPreferencesRequest{Type: models.StreakReminder, Channel: models.ChannelAuto, Enabled: true}
+
+	// Act
+	_, err := service.UpdateUserPreferences(context.Background(), uuid.New(), req)
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrInvalidPreferences)
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "UpdateUserPreferences", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchUserPreferences_PreservesOmittedFields(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+	start, end, limit := "22:00", "07:00", 3
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{
+		{ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true,
+			QuietHoursStart: &start, QuietHoursEnd: &end, MaxPerDay: &limit},
+	}, nil)
+	var saved *models.UserNotificationPreferences
+	mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).
+		Run(func(args mock.Arguments) { saved = args.Get(2).(*models.UserNotificationPreferences) }).
+		Return(&models.UserNotificationPreferences{ID: 7}, nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").
+		Return(&models.Notification{CreatedAt: time.Now()}, nil)
+
+	disabled := false
+	patch := &models.NotificationPreferencesPatch{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: &disabled}
+
+	// Act
+	stored, err := service.PatchUserPreferences(ctx, userID, patch)
+
+	// Assert: only enabled changes, the quiet hours and daily limit are kept
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), stored.ID)
+	require.NotNil(t, saved)
+	assert.False(t, saved.Enabled)
+	assert.Equal(t, "22:00", *saved.QuietHoursStart)
+	assert.Equal(t, "07:00", *saved.QuietHoursEnd)
+	assert.Equal(t, 3, *saved.MaxPerDay)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchUserPreferences_NewPreferenceStartsEnabled(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{}, nil)
+	var saved *models.UserNotificationPreferences
+	mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).
+		Run(func(args mock.Arguments) { saved = args.Get(2).(*models.UserNotificationPreferences) }).
+		Return(&models.UserNotificationPreferences{}, nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").
+		Return(&models.Notification{CreatedAt: time.Now()}, nil)
+
+	limit := 2
+	patch := &models.NotificationPreferencesPatch{Type: models.DailyReminder, Channel: models.ChannelEmail, MaxPerDay: &limit}
+
+	// Act
+	_, err := service.PatchUserPreferences(ctx, userID, patch)
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.True(t, saved.Enabled)
+	assert.Nil(t, saved.QuietHoursStart)
+	assert.Equal(t, 2, *saved.MaxPerDay)
+}
+
+func TestPatchUserPreferences_InvalidField(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	userID := uuid.New()
+	mockRepo.On("GetUserPreferences", mock.Anything, userID).Return([]models.UserNotificationPreferences{}, nil)
+
+	start := "9pm"
+	patch := &models.NotificationPreferencesPatch{Type: models.DailyReminder, Channel: models.ChannelEmail, QuietHoursStart: &start}
+
+	// Act
+	_, err := service.PatchUserPreferences(context.Background(), userID, patch)
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrInvalidPreferences)
+	mockRepo.AssertNotCalled(t, "UpdateUserPreferences", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUserPreferencesBulk_MixesInsertsAndUpdates(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+	start := "22:00"
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{
+		{ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true},
+	}, nil)
+	result := []models.UserNotificationPreferences{
+		{ID: 8, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelEmail, Enabled: true, QuietHoursStart: &start},
+		{ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+	}
+	var batch []models.UserNotificationPreferences
+	mockRepo.On("UpdateUserPreferencesBatch", ctx, userID, mock.AnythingOfType("[]models.UserNotificationPreferences")).
+		Run(func(args mock.Arguments) { batch = args.Get(2).([]models.UserNotificationPreferences) }).
+		Return(result, nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("GetNotificationTemplates", ctx, models.PreferencesUpdated, models.ChannelInApp).Return([]models.NotificationTemplate{}, nil)
+	var summary *models.Notification
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Run(func(args mock.Arguments) { summary = args.Get(1).(*models.Notification) }).
+		Return(nil).Once()
+
+	reqs := []models.NotificationPreferencesRequest{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+		{Type: models.StreakReminder, Channel: models.ChannelEmail, Enabled: true, QuietHoursStart: &start},
+	}
+
+	// Act
+	preferences, err := service.UpdateUserPreferencesBulk(ctx, userID, reqs)
+
+	// Assert: both entries go in one batch and one summary lists every change
+	require.NoError(t, err)
+	assert.Equal(t, result, preferences)
+	require.Len(t, batch, 2)
+	assert.Equal(t, userID, batch[1].UserID)
+	require.NotNil(t, summary)
+	changes := summary.Metadata["changes"].([]interface{})
+	require.Len(t, changes, 2)
+	assert.Equal(t, "enabled", changes[0].(map[string]interface{})["field"])
+	assert.Equal(t, "quiet_hours_start", changes[1].(map[string]interface{})["field"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUserPreferencesBulk_RejectsDuplicateEntries(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	reqs := []models.NotificationPreferencesRequest{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true},
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+	}
+
+	// Act
+	_, err := service.UpdateUserPreferencesBulk(context.Background(), uuid.New(), reqs)
+
+	// Assert
+	var fieldErrs *models.FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, "repeats the type and channel of entry 0", fieldErrs.Fields["1.channel"])
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "UpdateUserPreferencesBatch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteUserPreference_InvalidKey(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	err := service.DeleteUserPreference(context.Background(), uuid.New(), "banana", models.ChannelAuto)
+
+	// Assert
+	var fieldErrs *models.FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Contains(t, fieldErrs.Fields, "type")
+	assert.Contains(t, fieldErrs.Fields, "channel")
+	mockRepo.AssertNotCalled(t, "DeleteUserPreference", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteUserPreference_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	userID := uuid.New()
+	mockRepo.On("DeleteUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelInApp).
+		Return(repository.ErrPreferenceNotFound)
+
+	// Act
+	err := service.DeleteUserPreference(context.Background(), userID, models.DailyReminder, models.ChannelInApp)
+
+	// Assert
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetUserPreference(t *testing.T) {
+	userID := uuid.New()
+	start := "22:00"
+	stored := &models.UserNotificationPreferences{
+		ID: 7, UserID: userID, Type: models.DailyReminder, Channel: models.ChannelInApp, Enabled: false, QuietHoursStart: &start,
+	}
+
+	tests := []struct {
+		name        string
+		repoPref    *models.UserNotificationPreferences
+		repoErr     error
+		wantEnabled bool
+		wantDefault bool
+		wantErr     error
+	}{
+		{name: "stored row", repoPref: stored, wantEnabled: false, wantDefault: false},
+		{name: "no row falls back to the default", repoErr: repository.ErrPreferenceNotFound, wantEnabled: true, wantDefault: true},
+		{name: "lookup failure", repoErr: errors.New("connection refused"), wantErr: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+			mockRepo.On("GetUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelInApp).
+				Return(tt.repoPref, tt.repoErr)
+
+			// Act
+			pref, err := service.GetUserPreference(context.Background(), userID, models.DailyReminder, models.ChannelInApp)
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEnabled, pref.Enabled)
+			assert.Equal(t, tt.wantDefault, pref.Default)
+			assert.Equal(t, models.DailyReminder, pref.Type)
+			assert.Equal(t, models.ChannelInApp, pref.Channel)
+			if tt.wantDefault {
+				assert.Nil(t, pref.QuietHoursStart)
+				assert.Nil(t, pref.MaxPerDay)
+			}
+		})
+	}
+}
+
+func TestGetUserPreference_InvalidKey(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	_, err := service.GetUserPreference(context.Background(), uuid.New(), models.DailyReminder, "fax")
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrInvalidPreferences)
+	mockRepo.AssertNotCalled(t, "GetUserPreference", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// outboxStore is an in-memory outbox whose claim step is atomic, mirroring
+// SELECT ... FOR UPDATE SKIP LOCKED in Postgres
+type outboxStore struct {
+	MockNotificationRepository
+	mu            sync.Mutex
+	items         []models.OutboxNotification
+	claimedBy     map[int64]string
+	notifications map[uuid.UUID]*models.Notification
+}
+
+func (s *outboxStore) ClaimUnpublishedOutbox(ctx context.Context, workerID string, limit int) ([]models.OutboxNotification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []models.OutboxNotification
+	for _, item := range s.items {
+		if len(claimed) == limit {
+			break
+		}
+		if item.Published || item.Dead || s.claimedBy[item.ID] != "" {
+			continue
+		}
+		s.claimedBy[item.ID] = workerID
+		claimed = append(claimed, item)
+	}
+	return claimed, nil
+}
+
+func (s *outboxStore) MarkOutboxPublished(ctx context.Context, outboxID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.items {
+		if s.items[i].ID == outboxID {
+			s.items[i].Published = true
+		}
+	}
+	return nil
+}
+
+func (s *outboxStore) MarkOutboxBatchSent(ctx context.Context, outboxIDs []int64) error {
+	for _, id := range outboxIDs {
+		if err := s.MarkOutboxPublished(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, item := range s.items {
+		n := s.notifications[item.NotificationID]
+		if item.Published && n != nil && n.Status == models.StatusQueued {
+			n.Status = models.StatusSent
+			n.SentAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *outboxStore) CountPendingOutbox(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := 0
+	for _, item := range s.items {
+		if !item.Published {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// testOutboxPayload returns the stored payload of a freshly queued notification
+func testOutboxPayload() models.JSONMap {
+	return testOutboxItem(0, models.NewNotificationID()).Payload
+}
+
+// testOutboxItem returns an unpublished outbox row for the notification
+func testOutboxItem(id int64, notificationID uuid.UUID) models.OutboxNotification {
+	entry, err := models.BuildOutboxEntry(&models.Notification{
+		ID:      notificationID,
+		UserID:  uuid.New(),
+		Type:    models.DailyReminder,
+		Channel: models.ChannelInApp,
+		Message: "Time to practice",
+		Status:  models.StatusQueued,
+	}, "test-topic")
+	if err != nil {
+		panic(err)
+	}
+	entry.ID = id
+	return *entry
+}
+
+func TestProcessOutbox_ConcurrentProcessorsPublishEachRowOnce(t *testing.T) {
+	// Arrange
+	store := &outboxStore{claimedBy: make(map[int64]string)}
+	for i := int64(1); i <= 250; i++ {
+		store.items = append(store.items, models.OutboxNotification{
+			ID:             i,
+			NotificationID: uuid.New(),
+			Topic:          "test-topic",
+			Payload:        testOutboxPayload(),
+		})
+	}
+
+	var mu sync.Mutex
+	published := make(map[int64]int)
+	mockProducer := new(MockKafkaProducer)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Run(func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, msg := range args.Get(0).([]*sarama.ProducerMessage) {
+				published[msg.Metadata.(int64)]++
+			}
+		}).
+		Return(nil)
+
+	processors := []NotificationService{
+		NewNotificationService(store, mockProducer, "test-topic"),
+		NewNotificationService(store, mockProducer, "test-topic"),
+	}
+
+	// Act: both replicas tick until the outbox is drained
+	var wg sync.WaitGroup
+	for _, p := range processors {
+		wg.Add(1)
+		go func(p NotificationService) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				_, err := p.ProcessOutbox(context.Background())
+				assert.NoError(t, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Len(t, published, len(store.items))
+	for _, item := range store.items {
+		assert.True(t, item.Published)
+		assert.Equal(t, 1, published[item.ID], "outbox item %d", item.ID)
+	}
+}
+
+func TestProcessOutbox_KeysMessagesByUser(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	userID := uuid.New()
+	var items []models.OutboxNotification
+	for i, message := range []string{"Your streak is at risk", "Streak saved!"} {
+		entry, err := models.BuildOutboxEntry(&models.Notification{
+			ID: models.NewNotificationID(), UserID: userID, Type: models.StreakReminder, Channel: models.ChannelInApp, Message: message,
+		}, "test-topic")
+		require.NoError(t, err)
+		entry.ID = int64(i + 1)
+		items = append(items, *entry)
+	}
+
+	var sent []*sarama.ProducerMessage
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return(items, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			sent = msgs
+			return nil
+		})
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 2}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	_, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+	partitioner := sarama.NewHashPartitioner("test-topic")
+	var partitions []int32
+	for _, msg := range sent {
+		key, err := msg.Key.Encode()
+		require.NoError(t, err)
+		assert.Equal(t, userID.String(), string(key))
+
+		partition, err := partitioner.Partition(msg, 12)
+		require.NoError(t, err)
+		partitions = append(partitions, partition)
+	}
+	assert.Equal(t, partitions[0], partitions[1], "a user's notifications share a partition")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_ContinuesAfterFailedSend(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	var items []models.OutboxNotification
+	for i := int64(1); i <= 5; i++ {
+		items = append(items, testOutboxItem(i, uuid.New()))
+	}
+	failing := items[1]
+
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return(items, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			return sarama.ProducerErrors{{Msg: msgs[1], Err: errors.New("message too large")}}
+		})
+	mockRepo.On("IncrementOutboxAttempts", ctx, failing.ID, "message too large", mock.AnythingOfType("time.Time"), repository.MaxOutboxAttempts).Return(nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 3, 4, 5}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(1, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outbox item 2")
+	require.NotNil(t, result)
+	assert.Equal(t, 4, result.Published)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 1, result.Remaining)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, OutboxItemError{OutboxID: failing.ID, NotificationID: failing.NotificationID, Error: "message too large"}, result.Errors[0])
+	mockRepo.AssertExpectations(t)
+	mockProducer.AssertNumberOfCalls(t, "SendMessages", 1)
+	mockProducer.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestProcessOutbox_DeadLettersMalformedPayload(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	valid := testOutboxItem(1, uuid.New())
+	malformed := models.OutboxNotification{ID: 2, NotificationID: uuid.New(), Topic: "test-topic",
+		Payload: models.JSONMap{"schema_version": 1, "notification": models.JSONMap{"id": "42", "user_id": uuid.NewString()}}}
+
+	var sent []*sarama.ProducerMessage
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).
+		Return([]models.OutboxNotification{valid, malformed}, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			sent = msgs
+			return nil
+		})
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(2), mock.MatchedBy(func(msg string) bool {
+		return strings.Contains(msg, `invalid notification id "42"`)
+	}), mock.AnythingOfType("time.Time"), 1).Return(nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to send outbox item 2")
+	assert.Equal(t, 1, result.Published)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, sent, 1)
+	assert.Equal(t, int64(1), sent[0].Metadata)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_DeadLettersOversizedMessage(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic", WithMaxMessageBytes(2000))
+	ctx := context.Background()
+
+	valid := testOutboxItem(1, uuid.New())
+	oversized, err := models.BuildOutboxEntry(&models.Notification{
+		ID:       uuid.New(),
+		UserID:   uuid.New(),
+		Type:     models.DailyReminder,
+		Channel:  models.ChannelInApp,
+		Message:  "Time to practice",
+		Metadata: models.JSONMap{"blob": strings.Repeat("x", 4000)},
+		Status:   models.StatusQueued,
+	}, "test-topic")
+	require.NoError(t, err)
+	oversized.ID = 2
+
+	var sent []*sarama.ProducerMessage
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).
+		Return([]models.OutboxNotification{valid, *oversized}, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			sent = msgs
+			return nil
+		})
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(2), mock.MatchedBy(func(msg string) bool {
+		return strings.Contains(msg, "over the 2000 byte limit")
+	}), mock.AnythingOfType("time.Time"), 1).Return(nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	assert.Equal(t, 1, result.Published)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, sent, 1)
+	assert.Equal(t, int64(1), sent[0].Metadata)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_ProducerNotReady(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	producer := kafka.NewPendingManagedProducer(func() (sarama.SyncProducer, error) {
+		return nil, sarama.ErrOutOfBrokers
+	}, 0, 3)
+	service := NewNotificationService(mockRepo, producer, "test-topic")
+
+	// Act
+	result, err := service.ProcessOutbox(context.Background())
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, kafka.ErrProducerNotReady)
+	assert.ErrorIs(t, err, apperr.ErrUnavailable)
+	mockRepo.AssertNotCalled(t, "ClaimUnpublishedOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessOutbox_SetsRoutingHeaders(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{
+		ID:       uuid.New(),
+		UserID:   uuid.New(),
+		Type:     models.NotificationType("order_shipped"),
+		Channel:  models.ChannelEmail,
+		Priority: models.PriorityHigh,
+		Message:  "Your order is on its way",
+	}
+	scheduledItem, err := models.BuildOutboxEntry(notification, "test-topic")
+	require.NoError(t, err)
+	scheduled := *scheduledItem
+	scheduled.ID = 1
+	requestedItem, err := models.BuildOutboxEntry(notification, "test-topic")
+	require.NoError(t, err)
+	requested := *requestedItem
+	requested.ID = 2
+	requested.Payload["request_id"] = "req-123"
+
+	var sent []*sarama.ProducerMessage
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).
+		Return([]models.OutboxNotification{scheduled, requested}, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			sent = msgs
+			return nil
+		})
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 2}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	_, err = service.ProcessOutbox(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+	for _, msg := range sent {
+		headers := recordHeaders(msg)
+		for key, want := range map[string]string{
+			kafka.HeaderNotificationType: "order_shipped",
+			kafka.HeaderChannel:          "email",
+			kafka.HeaderPriority:         "high",
+			kafka.HeaderNotificationID:   notification.ID.String(),
+		} {
+			got, ok := kafka.HeaderValue(headers, key)
+			assert.True(t, ok, key)
+			assert.Equal(t, want, got, key)
+		}
+	}
+
+	_, ok := kafka.HeaderValue(recordHeaders(sent[0]), kafka.HeaderRequestID)
+	assert.False(t, ok, "no request ID header when the payload has none")
+	requestID, ok := kafka.HeaderValue(recordHeaders(sent[1]), kafka.HeaderRequestID)
+	assert.True(t, ok)
+	assert.Equal(t, "req-123", requestID)
+
+	// The body is the encoded payload, the same for consumers that ignore headers
+	value, err := sent[0].Value.Encode()
+	require.NoError(t, err)
+	_, want, err := models.EncodeOutboxPayload(scheduled.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, want, value)
+	mockRepo.AssertExpectations(t)
+}
+
+// recordHeaders returns a produced message's headers as a consumer receives them
+func recordHeaders(msg *sarama.ProducerMessage) []*sarama.RecordHeader {
+	headers := make([]*sarama.RecordHeader, len(msg.Headers))
+	for i := range msg.Headers {
+		headers[i] = &msg.Headers[i]
+	}
+	return headers
+}
+
+func TestProcessOutbox_BatchFailureRetriesEveryItem(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic", WithOutboxBatchSize(2))
+	ctx := context.Background()
+
+	items := []models.OutboxNotification{testOutboxItem(1, uuid.New()), testOutboxItem(2, uuid.New())}
+
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), 2).Return(items, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(sarama.ErrOutOfBrokers)
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(1), sarama.ErrOutOfBrokers.Error(), mock.AnythingOfType("time.Time"), repository.MaxOutboxAttempts).Return(nil)
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(2), sarama.ErrOutOfBrokers.Error(), mock.AnythingOfType("time.Time"), repository.MaxOutboxAttempts).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(2, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	assert.Equal(t, &OutboxResult{Failed: 2, Remaining: 2, Errors: []OutboxItemError{
+		{OutboxID: 1, NotificationID: items[0].NotificationID, Error: sarama.ErrOutOfBrokers.Error()},
+		{OutboxID: 2, NotificationID: items[1].NotificationID, Error: sarama.ErrOutOfBrokers.Error()},
+	}}, result)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "MarkOutboxBatchSent", mock.Anything, mock.Anything)
+}
+
+func TestProcessOutbox_MarksPublishedNotificationsSent(t *testing.T) {
+	// Arrange
+	store := &outboxStore{claimedBy: make(map[int64]string), notifications: make(map[uuid.UUID]*models.Notification)}
+	for i := int64(1); i <= 3; i++ {
+		n := &models.Notification{ID: uuid.New(), Status: models.StatusQueued}
+		store.notifications[n.ID] = n
+		store.items = append(store.items, testOutboxItem(i, n.ID))
+	}
+	failing := store.items[1]
+	store.On("IncrementOutboxAttempts", mock.Anything, failing.ID, "broker unavailable", mock.AnythingOfType("time.Time"), repository.MaxOutboxAttempts).Return(nil)
+
+	mockProducer := new(MockKafkaProducer)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			return sarama.ProducerErrors{{Msg: msgs[1], Err: errors.New("broker unavailable")}}
+		})
+	service := NewNotificationService(store, mockProducer, "test-topic")
+
+	// Act
+	result, err := service.ProcessOutbox(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, 2, result.Published)
+	for _, item := range store.items {
+		n := store.notifications[item.NotificationID]
+		if item.ID == failing.ID {
+			assert.Equal(t, models.StatusQueued, n.Status, "a failed send leaves the notification queued")
+			assert.Nil(t, n.SentAt)
+			continue
+		}
+		assert.Equal(t, models.StatusSent, n.Status)
+		assert.NotNil(t, n.SentAt)
+	}
+}
+
+var testAttachmentHosts = []string{"cdn.example.com"}
+
+func newAttachmentRequest(attachments models.Attachments) *models.CreateNotificationRequest {
+	return &models.CreateNotificationRequest{
+		UserID:      uuid.New(),
+		Type:        models.AchievementUnlock,
+		Channel:     models.ChannelInApp,
+		Priority:    models.PriorityMedium,
+		Message:     "Badge unlocked",
+		Attachments: attachments,
+	}
+}
+
+func TestCreateNotification_AttachmentsReachConsumerPayload(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic", WithAttachmentHosts(testAttachmentHosts))
+
+	attachments := models.Attachments{{
+		Kind: models.AttachmentImage, URL: "https://cdn.example.com/badges/streak.png", AltText: "Streak badge", Width: 64, Height: 64,
+	}}
+	req := newAttachmentRequest(attachments)
+	ctx := context.Background()
+
+	var outboxItem *models.OutboxNotification
+	mockRepo.On("GetUserPreferences", ctx, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Run(func(args mock.Arguments) { outboxItem = args.Get(2).(*models.OutboxNotification) }).
+		Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(ctx, req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, attachments, notification.Attachments)
+
+	// The consumer decodes the published payload into a NotificationEvent
+	data, err := json.Marshal(outboxItem.Payload)
+	require.NoError(t, err)
+	var consumed models.NotificationEvent
+	require.NoError(t, json.Unmarshal(data, &consumed))
+	assert.Equal(t, attachments, consumed.Notification.Attachments)
+
+	mockRepo.AssertNotCalled(t, "GetNotificationTemplates", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_RejectsAttachmentFromUnlistedHost(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic", WithAttachmentHosts(testAttachmentHosts))
+
+	req := newAttachmentRequest(models.Attachments{{Kind: models.AttachmentIcon, URL: "https://images.attacker.net/x.png"}})
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrInvalidAttachment)
+	assert.Nil(t, notification)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateNotification_UsesTemplateDefaultAttachments(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic", WithAttachmentHosts(testAttachmentHosts))
+
+	defaults := models.Attachments{{Kind: models.AttachmentIcon, URL: "https://cdn.example.com/icons/trophy.svg"}}
+	req := newAttachmentRequest(nil)
+	ctx := context.Background()
+
+	mockRepo.On("GetNotificationTemplates", ctx, req.Type, req.Channel).
+		Return([]models.NotificationTemplate{{Type: req.Type, Channel: req.Channel, DefaultAttachments: defaults}}, nil)
+	mockRepo.On("GetUserPreferences", ctx, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(ctx, req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, defaults, notification.Attachments)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_MarkFailureCountsSentItemsAsFailed(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	items := []models.OutboxNotification{
+		testOutboxItem(1, uuid.New()),
+		testOutboxItem(2, uuid.New()),
+		testOutboxItem(3, uuid.New()),
+	}
+
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return(items, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).
+		Return(func(msgs []*sarama.ProducerMessage) error {
+			return sarama.ProducerErrors{{Msg: msgs[2], Err: sarama.ErrMessageSizeTooLarge}}
+		})
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(3), sarama.ErrMessageSizeTooLarge.Error(), mock.AnythingOfType("time.Time"), repository.MaxOutboxAttempts).Return(nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 2}).Return(errors.New("connection reset"))
+	mockRepo.On("CountPendingOutbox", ctx).Return(3, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, 0, result.Published)
+	assert.Equal(t, 3, result.Failed)
+	assert.Equal(t, 3, result.Remaining)
+	assert.Len(t, result.Errors, 3)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_EmptyOutboxReportsRemaining(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return([]models.OutboxNotification{}, nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &OutboxResult{}, result)
+	mockProducer.AssertNotCalled(t, "SendMessages", mock.Anything)
+}
+
+func TestTopicFor_RoutesEachPriority(t *testing.T) {
+	service := NewNotificationService(new(MockNotificationRepository), new(MockKafkaProducer), "notifications",
+		WithPriorityTopics(map[models.PriorityLevel]string{
+			models.PriorityUrgent: "notifications-urgent",
+			models.PriorityHigh:   "notifications-urgent",
+			models.PriorityLow:    "notifications-bulk",
+		}),
+	).(*notificationService)
+
+	tests := []struct {
+		priority models.PriorityLevel
+		want     string
+	}{
+		{models.PriorityUrgent, "notifications-urgent"},
+		{models.PriorityHigh, "notifications-urgent"},
+		{models.PriorityMedium, "notifications"},
+		{models.PriorityLow, "notifications-bulk"},
+		{"", "notifications"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, service.topicFor(tt.priority), "priority %q", tt.priority)
+	}
+}
+
+func TestTopicFor_SingleTopicByDefault(t *testing.T) {
+	service := NewNotificationService(new(MockNotificationRepository), new(MockKafkaProducer), "notifications").(*notificationService)
+
+	for _, priority := range []models.PriorityLevel{models.PriorityUrgent, models.PriorityHigh, models.PriorityMedium, models.PriorityLow} {
+		assert.Equal(t, "notifications", service.topicFor(priority))
+	}
+}
+
+func TestCreateStreakReminder_PublishesToUrgentTopic(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "notifications",
+		WithPriorityTopics(map[models.PriorityLevel]string{models.PriorityHigh: "notifications-urgent"}))
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{UserID: user.ID, CurrentStreak: 5}, nil)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", ctx, user.ID, models.StreakReminder, "UTC").Return(false, nil)
+	mockRepo.On("GetNotificationTemplates", ctx, models.StreakReminder, models.ChannelInApp).
+		Return([]models.NotificationTemplate{streakReminderTemplate()}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.MatchedBy(func(o *models.OutboxNotification) bool {
+		return o.Topic == "notifications-urgent"
+	})).Return(nil)
+
+	// Act
+	err := service.CreateStreakReminder(ctx, user.ID)
+
+	// Assert
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetNotification_WithDeliveryAttempts(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), UserID: uuid.New(), Status: models.StatusDelivered}
+	attempts := []models.NotificationDeliveryAttempt{
+		{NotificationID: notification.ID, AttemptNo: 1, Status: models.StatusFailed},
+		{NotificationID: notification.ID, AttemptNo: 2, Status: models.StatusDelivered},
+	}
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+	mockRepo.On("GetDeliveryAttempts", ctx, notification.ID).Return(attempts, nil)
+
+	// Act
+	detail, err := service.GetNotification(ctx, notification.ID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, notification.ID, detail.ID)
+	assert.Equal(t, attempts, detail.DeliveryAttempts)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetNotification_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	id := uuid.New()
+	mockRepo.On("GetNotificationByID", ctx, id).Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, id))
+
+	// Act
+	_, err := service.GetNotification(ctx, id)
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrNotificationNotFound)
+	mockRepo.AssertNotCalled(t, "GetDeliveryAttempts", mock.Anything, mock.Anything)
+}
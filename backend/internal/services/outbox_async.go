@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+
+	"kafka-notify/internal/kafka"
+
+	"github.com/IBM/sarama"
+)
+
+// WithAsyncProducer publishes the outbox through an async producer instead of
+// the sync one (KAFKA_PRODUCER_MODE=async). producer returns nil while it is
+// still connecting. The producer must return successes as well as errors.
+func WithAsyncProducer(producer func() sarama.AsyncProducer) Option {
+	return func(s *notificationService) {
+		s.asyncProducer = producer
+	}
+}
+
+// ProcessOutboxAsync processes unpublished outbox items like ProcessOutbox, but
+// feeds them to the async producer and waits for each message's success or
+// error instead of blocking on a SendMessages call. Rows are only marked
+// published once Kafka has acknowledged their message; failed ones stay
+// unpublished and are retried with backoff.
+func (s *notificationService) ProcessOutboxAsync(ctx context.Context) (*OutboxResult, error) {
+	var producer sarama.AsyncProducer
+	if s.asyncProducer != nil {
+		producer = s.asyncProducer()
+	}
+	if producer == nil {
+		return nil, kafka.ErrProducerNotReady
+	}
+
+	return s.processOutbox(ctx, func(ctx context.Context, messages []*sarama.ProducerMessage, failed map[int64]error) {
+		s.sendOutboxAsync(ctx, producer, messages, failed)
+	})
+}
+
+// sendOutboxAsync feeds messages to the producer's input while draining its
+// Successes and Errors channels, matching each result to its row through the
+// outbox ID in Metadata. Messages not yet fed when ctx ends fail with ctx's
+// error; ones already fed are still waited for, since sarama reports a result
+// for every message it accepted.
+func (s *notificationService) sendOutboxAsync(ctx context.Context, producer sarama.AsyncProducer, messages []*sarama.ProducerMessage, failed map[int64]error) {
+	// The result channels are shared, so batches must not interleave
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+
+	pending := make(map[int64]bool, len(messages))
+	done := ctx.Done()
+	next := 0
+	for next < len(messages) || len(pending) > 0 {
+		var input chan<- *sarama.ProducerMessage
+		var msg *sarama.ProducerMessage
+		if next < len(messages) {
+			input = producer.Input()
+			msg = messages[next]
+		}
+
+		select {
+		case input <- msg:
+			pending[msg.Metadata.(int64)] = true
+			next++
+		case success, ok := <-producer.Successes():
+			if !ok {
+				failPending(pending, messages[next:], failed, sarama.ErrClosedClient)
+				return
+			}
+			if id, ok := success.Metadata.(int64); ok {
+				delete(pending, id)
+			}
+		case pe, ok := <-producer.Errors():
+			if !ok {
+				failPending(pending, messages[next:], failed, sarama.ErrClosedClient)
+				return
+			}
+			if id, ok := pe.Msg.Metadata.(int64); ok && pending[id] {
+				delete(pending, id)
+				failed[id] = pe.Err
+			}
+		case <-done:
+			for _, msg := range messages[next:] {
+				failed[msg.Metadata.(int64)] = ctx.Err()
+			}
+			next = len(messages)
+			done = nil
+		}
+	}
+}
+
+// failPending fails every message still waiting for a result and every one not yet fed
+func failPending(pending map[int64]bool, unsent []*sarama.ProducerMessage, failed map[int64]error, err error) {
+	for id := range pending {
+		failed[id] = err
+	}
+	for _, msg := range unsent {
+		failed[msg.Metadata.(int64)] = err
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"kafka-notify/internal/kafka"
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockAsyncProducer(t *testing.T) *mocks.AsyncProducer {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	t.Cleanup(func() { producer.Close() })
+	return producer
+}
+
+func TestProcessOutboxAsync_MarksAcknowledgedRowsPublished(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	producer := newMockAsyncProducer(t)
+	producer.ExpectInputAndSucceed().ExpectInputAndSucceed().ExpectInputAndSucceed()
+	service := NewNotificationService(mockRepo, nil, "test-topic",
+		WithAsyncProducer(func() sarama.AsyncProducer { return producer }))
+	ctx := context.Background()
+
+	items := []models.OutboxNotification{testOutboxItem(1, uuid.New()), testOutboxItem(2, uuid.New()), testOutboxItem(3, uuid.New())}
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return(items, nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 2, 3}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act: ProcessOutbox goes through the async producer once one is configured
+	result, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Published)
+	assert.Equal(t, 0, result.Failed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutboxAsync_FailedSendsStayUnpublished(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	producer := newMockAsyncProducer(t)
+	producer.ExpectInputAndSucceed().ExpectInputAndFail(sarama.ErrNotLeaderForPartition).ExpectInputAndSucceed()
+	service := NewNotificationService(mockRepo, nil, "test-topic",
+		WithAsyncProducer(func() sarama.AsyncProducer { return producer }))
+	ctx := context.Background()
+
+	failed := testOutboxItem(2, uuid.New())
+	items := []models.OutboxNotification{testOutboxItem(1, uuid.New()), failed, testOutboxItem(3, uuid.New())}
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).Return(items, nil)
+	mockRepo.On("IncrementOutboxAttempts", ctx, int64(2), sarama.ErrNotLeaderForPartition.Error(),
+		mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(nil)
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{1, 3}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(1, nil)
+
+	// Act
+	result, err := service.ProcessOutboxAsync(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, sarama.ErrNotLeaderForPartition)
+	assert.Equal(t, 2, result.Published)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 1, result.Remaining)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, failed.NotificationID, result.Errors[0].NotificationID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutboxAsync_ProducerNotReady(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, nil, "test-topic",
+		WithAsyncProducer(func() sarama.AsyncProducer { return nil }))
+
+	// Act
+	result, err := service.ProcessOutbox(context.Background())
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, kafka.ErrProducerNotReady)
+	mockRepo.AssertNotCalled(t, "ClaimUnpublishedOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
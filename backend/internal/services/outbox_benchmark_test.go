@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// brokerRoundTrip approximates one acks=all produce request
+const brokerRoundTrip = 50 * time.Microsecond
+
+// roundTripProducer is a mock producer that charges one broker round trip per call
+type roundTripProducer struct {
+	MockKafkaProducer
+}
+
+func (p *roundTripProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	time.Sleep(brokerRoundTrip)
+	return 0, 0, nil
+}
+
+func (p *roundTripProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	time.Sleep(brokerRoundTrip)
+	return nil
+}
+
+// roundTripAsyncProducer is a fake async producer that acknowledges whatever
+// has queued up on its input once per broker round trip, the way sarama
+// batches messages that arrive while a produce request is in flight
+type roundTripAsyncProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+}
+
+func newRoundTripAsyncProducer() *roundTripAsyncProducer {
+	p := &roundTripAsyncProducer{
+		input:     make(chan *sarama.ProducerMessage, 256),
+		successes: make(chan *sarama.ProducerMessage, 256),
+	}
+	go func() {
+		for msg := range p.input {
+			batch := []*sarama.ProducerMessage{msg}
+		drain:
+			for {
+				select {
+				case msg, ok := <-p.input:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, msg)
+				default:
+					break drain
+				}
+			}
+			time.Sleep(brokerRoundTrip)
+			for _, msg := range batch {
+				p.successes <- msg
+			}
+		}
+		close(p.successes)
+	}()
+	return p
+}
+
+func (p *roundTripAsyncProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *roundTripAsyncProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *roundTripAsyncProducer) Errors() <-chan *sarama.ProducerError      { return nil }
+func (p *roundTripAsyncProducer) Close() error {
+	close(p.input)
+	return nil
+}
+
+// benchOutboxRepo hands out the same batch on every claim
+type benchOutboxRepo struct {
+	MockNotificationRepository
+	items []models.OutboxNotification
+}
+
+func (r *benchOutboxRepo) ClaimUnpublishedOutbox(ctx context.Context, workerID string, limit int) ([]models.OutboxNotification, error) {
+	return r.items, nil
+}
+
+func (r *benchOutboxRepo) MarkOutboxPublished(ctx context.Context, outboxID int64) error {
+	return nil
+}
+
+func (r *benchOutboxRepo) MarkOutboxBatchSent(ctx context.Context, outboxIDs []int64) error {
+	return nil
+}
+
+func (r *benchOutboxRepo) CountPendingOutbox(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func newBenchOutboxRepo(n int) *benchOutboxRepo {
+	repo := &benchOutboxRepo{}
+	for i := 1; i <= n; i++ {
+		repo.items = append(repo.items, models.OutboxNotification{
+			ID:             int64(i),
+			NotificationID: uuid.New(),
+			Topic:          "bench-topic",
+			Payload:        testOutboxPayload(),
+		})
+	}
+	return repo
+}
+
+// publishOneAtATime is the previous ProcessOutbox loop: one SendMessage and one
+// UPDATE per outbox item
+func publishOneAtATime(ctx context.Context, repo *benchOutboxRepo, producer sarama.SyncProducer) {
+	items, _ := repo.ClaimUnpublishedOutbox(ctx, "bench", DefaultOutboxBatchSize)
+	for _, item := range items {
+		key, value, err := models.EncodeOutboxPayload(item.Payload)
+		if err != nil {
+			continue
+		}
+		message := &sarama.ProducerMessage{
+			Topic: item.Topic,
+			Key:   sarama.StringEncoder(key),
+			Value: sarama.ByteEncoder(value),
+		}
+		if _, _, err := producer.SendMessage(message); err != nil {
+			continue
+		}
+		_ = repo.MarkOutboxPublished(ctx, item.ID)
+	}
+}
+
+func BenchmarkProcessOutbox(b *testing.B) {
+	ctx := context.Background()
+	repo := newBenchOutboxRepo(DefaultOutboxBatchSize)
+	producer := &roundTripProducer{}
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	b.Run("SendMessage", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			publishOneAtATime(ctx, repo, producer)
+		}
+	})
+
+	b.Run("SendMessages", func(b *testing.B) {
+		service := NewNotificationService(repo, producer, "bench-topic")
+		for i := 0; i < b.N; i++ {
+			if _, err := service.ProcessOutbox(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AsyncProducer", func(b *testing.B) {
+		asyncProducer := newRoundTripAsyncProducer()
+		defer asyncProducer.Close()
+		service := NewNotificationService(repo, nil, "bench-topic",
+			WithAsyncProducer(func() sarama.AsyncProducer { return asyncProducer }))
+		for i := 0; i < b.N; i++ {
+			if _, err := service.ProcessOutbox(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+const (
+	// DefaultOutboxBackoffBase is the delay after an outbox row's first failed publish
+	DefaultOutboxBackoffBase = 30 * time.Second
+	// DefaultOutboxBackoffMax caps the delay between publish attempts for one row
+	DefaultOutboxBackoffMax = time.Hour
+)
+
+// outboxBackoff returns how long to hold an outbox row back after its nth
+// failed publish: the base delay doubled per earlier failure, capped at max
+func outboxBackoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// GetOutboxStats returns the outbox backlog and the age of its oldest pending row
+func (s *notificationService) GetOutboxStats(ctx context.Context) (*models.OutboxStats, error) {
+	stats, err := s.repository.GetOutboxStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox stats: %w", err)
+	}
+	return stats, nil
+}
+
+// ListDeadOutbox returns outbox items that exhausted their publish attempts
+func (s *notificationService) ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error) {
+	items, err := s.repository.ListDeadOutbox(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead outbox items: %w", err)
+	}
+	return items, nil
+}
+
+// RetryDeadOutbox requeues a dead outbox item so the processor publishes it again
+func (s *notificationService) RetryDeadOutbox(ctx context.Context, outboxID int64) error {
+	if err := s.repository.RequeueDeadOutbox(ctx, outboxID); err != nil {
+		return fmt.Errorf("failed to retry dead outbox item: %w", err)
+	}
+	return nil
+}
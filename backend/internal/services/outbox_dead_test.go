@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxBackoff_DoublesUpToCap(t *testing.T) {
+	base, max := 30*time.Second, time.Hour
+
+	expected := []time.Duration{
+		30 * time.Second,
+		time.Minute,
+		2 * time.Minute,
+		4 * time.Minute,
+		8 * time.Minute,
+		16 * time.Minute,
+		32 * time.Minute,
+		time.Hour,
+		time.Hour,
+	}
+	for i, want := range expected {
+		assert.Equal(t, want, outboxBackoff(i+1, base, max), "attempt %d", i+1)
+	}
+	assert.Equal(t, time.Hour, outboxBackoff(500, base, max), "large attempt counts don't overflow")
+}
+
+func TestProcessOutbox_BacksOffFailedItem(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic",
+		WithOutboxBackoff(time.Minute, 10*time.Minute), WithOutboxMaxAttempts(8))
+	ctx := context.Background()
+
+	item := testOutboxItem(4, uuid.New())
+	item.Attempts = 2
+
+	var nextAttemptAt time.Time
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).
+		Return([]models.OutboxNotification{item}, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(sarama.ErrOutOfBrokers)
+	mockRepo.On("IncrementOutboxAttempts", ctx, item.ID, sarama.ErrOutOfBrokers.Error(), mock.AnythingOfType("time.Time"), 8).
+		Run(func(args mock.Arguments) { nextAttemptAt = args.Get(3).(time.Time) }).
+		Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(1, nil)
+
+	// Act
+	start := time.Now()
+	_, err := service.ProcessOutbox(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	// Third failure: base doubled twice
+	assert.WithinDuration(t, start.Add(4*time.Minute), nextAttemptAt, 5*time.Second)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessOutbox_DeadLettersAfterMaxAttempts(t *testing.T) {
+	// Arrange
+	store := &outboxStore{claimedBy: make(map[int64]string)}
+	store.items = []models.OutboxNotification{testOutboxItem(1, uuid.New())}
+	store.On("IncrementOutboxAttempts", mock.Anything, int64(1), "broker unavailable", mock.AnythingOfType("time.Time"), 3).
+		Run(func(args mock.Arguments) {
+			// Mirror the repository: release the claim and count the attempt
+			item := &store.items[0]
+			item.Attempts++
+			item.Dead = item.Attempts >= args.Int(4)
+			store.mu.Lock()
+			delete(store.claimedBy, item.ID)
+			store.mu.Unlock()
+		}).
+		Return(nil)
+
+	mockProducer := new(MockKafkaProducer)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(errors.New("broker unavailable"))
+	service := NewNotificationService(store, mockProducer, "test-topic", WithOutboxMaxAttempts(3))
+
+	// Act
+	for i := 0; i < 5; i++ {
+		_, _ = service.ProcessOutbox(context.Background())
+	}
+
+	// Assert
+	require.True(t, store.items[0].Dead)
+	assert.Equal(t, 3, store.items[0].Attempts, "a dead row is no longer claimed or retried")
+	mockProducer.AssertNumberOfCalls(t, "SendMessages", 3)
+}
+
+func TestRetryDeadOutbox(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	mockRepo.On("RequeueDeadOutbox", ctx, int64(9)).Return(nil)
+
+	// Act
+	err := service.RetryDeadOutbox(ctx, 9)
+
+	// Assert
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
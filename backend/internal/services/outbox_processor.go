@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Outbox processor defaults
+const (
+	DefaultOutboxInterval = 30 * time.Second
+	DefaultOutboxJitter   = 3 * time.Second
+
+	// outboxTickTimeout bounds a single ProcessOutbox call
+	outboxTickTimeout = 10 * time.Second
+)
+
+// MaintenanceChecker reports whether background work is paused for maintenance
+type MaintenanceChecker interface {
+	Active(ctx context.Context) bool
+}
+
+// LeaderChecker reports whether this replica is the one that should run
+// singleton background work, re-checking or acquiring leadership as needed
+type LeaderChecker interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// OutboxProcessor publishes outbox batches on an interval until its context is
+// cancelled. Each wait adds a random jitter so replicas started together don't
+// all claim at the same moment. Ticks are skipped while maintenance mode is on;
+// the outbox rows stay queued and are published once it is turned off. With a
+// leader checker only the elected replica publishes; the others stand by.
+type OutboxProcessor struct {
+	service     NotificationService
+	interval    time.Duration
+	jitter      time.Duration
+	maintenance MaintenanceChecker
+	leader      LeaderChecker
+
+	// after and randDuration are swapped out in tests
+	after        func(time.Duration) <-chan time.Time
+	randDuration func(time.Duration) time.Duration
+}
+
+// NewOutboxProcessor creates an outbox processor; a non-positive interval uses
+// DefaultOutboxInterval, a nil maintenance checker never pauses and a nil
+// leader checker runs on every replica
+func NewOutboxProcessor(service NotificationService, interval, jitter time.Duration, maintenance MaintenanceChecker, leader LeaderChecker) *OutboxProcessor {
+	if interval <= 0 {
+		interval = DefaultOutboxInterval
+	}
+	if jitter < 0 {
+		jitter = 0
+	}
+
+	return &OutboxProcessor{
+		service:     service,
+		interval:    interval,
+		jitter:      jitter,
+		maintenance: maintenance,
+		leader:      leader,
+		after:       time.After,
+		randDuration: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max)))
+		},
+	}
+}
+
+// Run processes the outbox every interval plus jitter until ctx is cancelled.
+// A batch already in flight when ctx is cancelled is finished, so rows sent to
+// Kafka are still marked published, and Run returns without starting another.
+func (p *OutboxProcessor) Run(ctx context.Context) {
+	log.Printf("Starting outbox processor (every %s, jitter %s)...", p.interval, p.jitter)
+
+	for {
+		select {
+		case <-p.after(p.nextDelay()):
+		case <-ctx.Done():
+		}
+		// Both cases can be ready at once; never start a batch after cancellation
+		if ctx.Err() != nil {
+			log.Println("Outbox processor stopped")
+			return
+		}
+
+		p.tick(ctx)
+	}
+}
+
+// nextDelay returns the interval plus a random jitter in [0, jitter)
+func (p *OutboxProcessor) nextDelay() time.Duration {
+	if p.jitter <= 0 {
+		return p.interval
+	}
+	return p.interval + p.randDuration(p.jitter)
+}
+
+// tick processes one outbox batch and logs the outcome
+func (p *OutboxProcessor) tick(ctx context.Context) {
+	if p.maintenance != nil && p.maintenance.Active(ctx) {
+		log.Println("Outbox processor paused: maintenance mode is on")
+		return
+	}
+	if p.leader != nil && !p.leader.IsLeader(ctx) {
+		return
+	}
+
+	// Shutdown must not interrupt a batch between publishing and marking it
+	// published, so the batch only inherits the tick timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), outboxTickTimeout)
+	defer cancel()
+
+	result, err := p.service.ProcessOutbox(ctx)
+	if err != nil {
+		log.Printf("Outbox processing error: %v", err)
+	}
+	if result != nil && (result.Published > 0 || result.Failed > 0 || result.Remaining > 0) {
+		log.Printf("Outbox processed: published=%d failed=%d remaining=%d",
+			result.Published, result.Failed, result.Remaining)
+	}
+}
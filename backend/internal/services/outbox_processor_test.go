@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingOutboxService counts ProcessOutbox calls
+type countingOutboxService struct {
+	NotificationService
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *countingOutboxService) ProcessOutbox(ctx context.Context) (*OutboxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return &OutboxResult{}, nil
+}
+
+func (s *countingOutboxService) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestOutboxProcessor_WaitsIntervalPlusJitter(t *testing.T) {
+	// Arrange
+	service := &countingOutboxService{}
+	processor := NewOutboxProcessor(service, 20*time.Second, 4*time.Second, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jitters := []time.Duration{0, 3 * time.Second, 1500 * time.Millisecond}
+	var delays []time.Duration
+	processor.randDuration = func(max time.Duration) time.Duration {
+		assert.Equal(t, 4*time.Second, max)
+		return jitters[len(delays)%len(jitters)]
+	}
+	processor.after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		if len(delays) > len(jitters) {
+			cancel()
+			return nil
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	// Act
+	processor.Run(ctx)
+
+	// Assert
+	assert.Equal(t, []time.Duration{20 * time.Second, 23 * time.Second, 21500 * time.Millisecond, 20 * time.Second}, delays)
+	assert.Equal(t, len(jitters), service.Calls())
+}
+
+func TestOutboxProcessor_DefaultsAndNoJitter(t *testing.T) {
+	processor := NewOutboxProcessor(&countingOutboxService{}, 0, 0, nil, nil)
+
+	assert.Equal(t, DefaultOutboxInterval, processor.nextDelay())
+}
+
+func TestOutboxProcessor_StopsOnCancel(t *testing.T) {
+	// Arrange
+	service := &countingOutboxService{}
+	processor := NewOutboxProcessor(service, time.Millisecond, 0, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		processor.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return service.Calls() > 0 }, time.Second, time.Millisecond)
+
+	// Act
+	cancel()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("outbox processor did not stop after cancellation")
+	}
+	calls := service.Calls()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, calls, service.Calls(), "no ticks after Run returned")
+}
+
+// maintenanceSwitch is a MaintenanceChecker toggled by the test
+type maintenanceSwitch struct {
+	mu     sync.Mutex
+	active bool
+}
+
+func (m *maintenanceSwitch) Active(ctx context.Context) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active
+}
+
+func (m *maintenanceSwitch) set(active bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = active
+}
+
+func TestOutboxProcessor_PausesDuringMaintenance(t *testing.T) {
+	// Arrange
+	store := &outboxStore{claimedBy: make(map[int64]string)}
+	for i := int64(1); i <= 3; i++ {
+		store.items = append(store.items, testOutboxItem(i, uuid.New()))
+	}
+	mockProducer := new(MockKafkaProducer)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(nil)
+	service := NewNotificationService(store, mockProducer, "test-topic")
+
+	maintenance := &maintenanceSwitch{active: true}
+	processor := NewOutboxProcessor(service, time.Minute, 0, maintenance, nil)
+	ctx := context.Background()
+
+	// Act: ticks while maintenance is on publish nothing
+	processor.tick(ctx)
+	processor.tick(ctx)
+
+	// Assert
+	mockProducer.AssertNotCalled(t, "SendMessages", mock.Anything)
+	pending, _ := store.CountPendingOutbox(ctx)
+	assert.Equal(t, 3, pending, "queued items are kept while paused")
+
+	// Act: the next tick after turning it off drains the queue
+	maintenance.set(false)
+	processor.tick(ctx)
+
+	// Assert
+	mockProducer.AssertNumberOfCalls(t, "SendMessages", 1)
+	pending, _ = store.CountPendingOutbox(ctx)
+	assert.Equal(t, 0, pending)
+}
+
+// leaderSwitch is a LeaderChecker whose answer can be flipped between ticks
+type leaderSwitch struct {
+	leading bool
+	checks  int
+}
+
+func (l *leaderSwitch) IsLeader(context.Context) bool {
+	l.checks++
+	return l.leading
+}
+
+func TestOutboxProcessor_OnlyLeaderPublishes(t *testing.T) {
+	// Arrange
+	store := &outboxStore{claimedBy: make(map[int64]string)}
+	for i := int64(1); i <= 2; i++ {
+		store.items = append(store.items, testOutboxItem(i, uuid.New()))
+	}
+	mockProducer := new(MockKafkaProducer)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(nil)
+	service := NewNotificationService(store, mockProducer, "test-topic")
+
+	leader := &leaderSwitch{}
+	processor := NewOutboxProcessor(service, time.Minute, 0, nil, leader)
+	ctx := context.Background()
+
+	// Act: a standby replica re-checks every tick but publishes nothing
+	processor.tick(ctx)
+	processor.tick(ctx)
+
+	// Assert
+	assert.Equal(t, 2, leader.checks)
+	mockProducer.AssertNotCalled(t, "SendMessages", mock.Anything)
+	pending, _ := store.CountPendingOutbox(ctx)
+	assert.Equal(t, 2, pending)
+
+	// Act: once it takes over leadership it drains the queue
+	leader.leading = true
+	processor.tick(ctx)
+
+	// Assert
+	mockProducer.AssertNumberOfCalls(t, "SendMessages", 1)
+	pending, _ = store.CountPendingOutbox(ctx)
+	assert.Equal(t, 0, pending)
+}
+
+// blockingOutboxService holds ProcessOutbox open until released, recording
+// whether the batch's context was cancelled underneath it
+type blockingOutboxService struct {
+	NotificationService
+	started  chan struct{}
+	release  chan struct{}
+	mu       sync.Mutex
+	calls    int
+	batchErr error
+}
+
+func (s *blockingOutboxService) ProcessOutbox(ctx context.Context) (*OutboxResult, error) {
+	s.mu.Lock()
+	s.calls++
+	s.mu.Unlock()
+
+	s.started <- struct{}{}
+	<-s.release
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batchErr = ctx.Err()
+	return &OutboxResult{Published: 1}, nil
+}
+
+func TestOutboxProcessor_FinishesInFlightBatchOnCancel(t *testing.T) {
+	// Arrange
+	service := &blockingOutboxService{started: make(chan struct{}), release: make(chan struct{})}
+	processor := NewOutboxProcessor(service, time.Millisecond, 0, nil, nil)
+	processor.after = func(time.Duration) <-chan time.Time {
+		// Every wait is already over, so only cancellation can stop the loop
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		processor.Run(ctx)
+		close(done)
+	}()
+
+	// Act: shut down while the first batch is between publish and mark
+	<-service.started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run returned before the in-flight batch finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(service.release)
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the in-flight batch finished")
+	}
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	assert.NoError(t, service.batchErr, "the in-flight batch keeps a live context")
+	assert.Equal(t, 1, service.calls, "no batch starts after cancellation")
+}
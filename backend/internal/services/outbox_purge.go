@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+)
+
+// DefaultOutboxRetention is how long published outbox items are kept
+const DefaultOutboxRetention = 7 * 24 * time.Hour
+
+// ErrInvalidPurge is returned when a purge cutoff is rejected
+var ErrInvalidPurge = apperr.New(apperr.ErrInvalidInput, "invalid outbox purge request")
+
+// PurgeOutbox deletes outbox items published before the cutoff and returns how
+// many were removed. A zero cutoff uses the configured retention window.
+func (s *notificationService) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	now := time.Now()
+	if before.IsZero() {
+		before = now.Add(-s.outboxRetention)
+	}
+	if before.After(now) {
+		return 0, fmt.Errorf("%w: cutoff %s is in the future", ErrInvalidPurge, before.Format(time.RFC3339))
+	}
+
+	deleted, err := s.repository.DeletePublishedOutboxBefore(ctx, before)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurgeOutbox_DefaultsToRetentionWindow(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic", WithOutboxRetention(48*time.Hour))
+	ctx := context.Background()
+
+	expectedCutoff := time.Now().Add(-48 * time.Hour)
+	mockRepo.On("DeletePublishedOutboxBefore", ctx, mock.MatchedBy(func(cutoff time.Time) bool {
+		return cutoff.Sub(expectedCutoff).Abs() < time.Minute
+	})).Return(int64(42), nil)
+
+	// Act
+	deleted, err := service.PurgeOutbox(ctx, time.Time{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), deleted)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeOutbox_RejectsFutureCutoff(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+
+	// Act
+	_, err := service.PurgeOutbox(context.Background(), time.Now().Add(time.Hour))
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidPurge)
+	mockRepo.AssertNotCalled(t, "DeletePublishedOutboxBefore", mock.Anything, mock.Anything)
+}
+
+func TestPurgeOutbox_ReportsRowsDeletedBeforeFailure(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	cutoff := time.Now().Add(-time.Hour)
+
+	mockRepo.On("DeletePublishedOutboxBefore", ctx, cutoff).Return(int64(5000), assert.AnError)
+
+	// Act
+	deleted, err := service.PurgeOutbox(ctx, cutoff)
+
+	// Assert
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, int64(5000), deleted)
+}
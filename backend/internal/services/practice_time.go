@@ -0,0 +1,118 @@
+package services
+
+import (
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+const (
+	// TypicalHourSampleSize is how many recent practice sessions feed the typical hour
+	TypicalHourSampleSize = 14
+
+	// typicalHourMinSamples is the sample count below which confidence is scaled down
+	typicalHourMinSamples = 5
+
+	// latestReminderHour keeps buffered reminders on the same local day
+	latestReminderHour = 23
+)
+
+// ComputeTypicalHour returns the local hour the user most often practices at and the
+// share of sessions that fall within an hour of it. Hours wrap around midnight, so
+// sessions at 23:00 and 00:00 count towards the same window.
+func ComputeTypicalHour(timestamps []time.Time, loc *time.Location) (int, float64) {
+	if len(timestamps) == 0 {
+		return 0, 0
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var counts [24]int
+	for _, ts := range timestamps {
+		counts[ts.In(loc).Hour()]++
+	}
+
+	bestHour, bestScore := 0, -1
+	for h := 0; h < 24; h++ {
+		score := counts[(h+23)%24] + counts[h] + counts[(h+1)%24]
+		if score > bestScore || (score == bestScore && counts[h] > counts[bestHour]) {
+			bestHour, bestScore = h, score
+		}
+	}
+
+	confidence := float64(bestScore) / float64(len(timestamps))
+	if len(timestamps) < typicalHourMinSamples {
+		confidence *= float64(len(timestamps)) / float64(typicalHourMinSamples)
+	}
+
+	return bestHour, confidence
+}
+
+// StreakReminderPolicy decides when a streak reminder may be sent during the user's day
+type StreakReminderPolicy struct {
+	Buffer        time.Duration
+	DefaultHour   int
+	MinConfidence float64
+}
+
+// ReminderHour returns the local hour the reminder is anchored to, falling back to
+// the default hour when the typical hour is unknown or not trustworthy
+func (p StreakReminderPolicy) ReminderHour(streak *models.UserEngagementStreak) int {
+	if streak == nil || streak.TypicalHour == nil || streak.TypicalHourConfidence < p.MinConfidence {
+		return p.DefaultHour
+	}
+	return *streak.TypicalHour
+}
+
+// IsDue reports whether the user's local time has passed their reminder hour plus the buffer
+func (p StreakReminderPolicy) IsDue(now time.Time, streak *models.UserEngagementStreak) bool {
+	loc := time.UTC
+	if streak != nil {
+		loc = LoadLocation(streak.Timezone)
+	}
+
+	local := now.In(loc)
+	year, month, day := local.Date()
+
+	threshold := time.Date(year, month, day, p.ReminderHour(streak), 0, 0, 0, loc).Add(p.Buffer)
+	if latest := time.Date(year, month, day, latestReminderHour, 0, 0, 0, loc); threshold.After(latest) {
+		threshold = latest
+	}
+
+	return !local.Before(threshold)
+}
+
+// LastChanceWindow is the local evening window in which a streak that has not
+// been extended today gets a last-chance alert
+type LastChanceWindow struct {
+	// StartHour and EndHour bound the window in local hours, [StartHour, EndHour)
+	StartHour int
+	EndHour   int
+}
+
+// IsOpen reports whether now falls in the window in the streak's timezone
+// while the streak is active but not yet extended on that local day
+func (w LastChanceWindow) IsOpen(now time.Time, streak *models.UserEngagementStreak) bool {
+	if streak == nil || streak.CurrentStreak == 0 {
+		return false
+	}
+
+	local := now.In(LoadLocation(streak.Timezone))
+	if local.Hour() < w.StartHour || local.Hour() >= w.EndHour {
+		return false
+	}
+	return streak.LastActivityDate == nil || calendarDate(*streak.LastActivityDate).Before(calendarDate(local))
+}
+
+// LoadLocation resolves an IANA timezone name, defaulting to UTC when it is unknown
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
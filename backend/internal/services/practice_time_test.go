@@ -0,0 +1,174 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func practiceAt(loc *time.Location, hours ...int) []time.Time {
+	var timestamps []time.Time
+	for i, h := range hours {
+		timestamps = append(timestamps, time.Date(2024, time.March, 1+i, h, 15, 0, 0, loc))
+	}
+	return timestamps
+}
+
+func TestComputeTypicalHour_ConsistentEvenings(t *testing.T) {
+	timestamps := practiceAt(time.UTC, 20, 20, 20, 21, 19, 20, 20)
+
+	hour, confidence := ComputeTypicalHour(timestamps, time.UTC)
+
+	assert.Equal(t, 20, hour)
+	assert.Equal(t, 1.0, confidence)
+}
+
+func TestComputeTypicalHour_UsesUserTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	// 11:00 UTC is 20:00 in Tokyo
+	timestamps := practiceAt(time.UTC, 11, 11, 11, 11, 11)
+
+	hour, _ := ComputeTypicalHour(timestamps, tokyo)
+
+	assert.Equal(t, 20, hour)
+}
+
+func TestComputeTypicalHour_WrapsAroundMidnight(t *testing.T) {
+	timestamps := practiceAt(time.UTC, 23, 0, 0, 23, 0, 1, 12)
+
+	hour, confidence := ComputeTypicalHour(timestamps, time.UTC)
+
+	assert.Equal(t, 0, hour)
+	assert.InDelta(t, 6.0/7.0, confidence, 0.001)
+}
+
+func TestComputeTypicalHour_ScattersLowConfidence(t *testing.T) {
+	timestamps := practiceAt(time.UTC, 6, 10, 14, 18, 22, 2)
+
+	_, confidence := ComputeTypicalHour(timestamps, time.UTC)
+
+	assert.Less(t, confidence, 0.5)
+}
+
+func TestComputeTypicalHour_FewSamplesReduceConfidence(t *testing.T) {
+	hour, confidence := ComputeTypicalHour(practiceAt(time.UTC, 20, 20), time.UTC)
+
+	assert.Equal(t, 20, hour)
+	assert.InDelta(t, 0.4, confidence, 0.001)
+
+	_, confidence = ComputeTypicalHour(nil, time.UTC)
+	assert.Equal(t, 0.0, confidence)
+}
+
+func TestStreakReminderPolicy_BufferAcrossTimezones(t *testing.T) {
+	policy := StreakReminderPolicy{Buffer: time.Hour, DefaultHour: 18, MinConfidence: 0.5}
+
+	tests := []struct {
+		name     string
+		timezone string
+		now      time.Time
+		expected bool
+	}{
+		{"new york before typical hour", "America/New_York", time.Date(2024, time.March, 12, 23, 30, 0, 0, time.UTC), false},
+		{"new york inside buffer", "America/New_York", time.Date(2024, time.March, 13, 0, 30, 0, 0, time.UTC), false},
+		{"new york after buffer", "America/New_York", time.Date(2024, time.March, 13, 1, 0, 0, 0, time.UTC), true},
+		{"tokyo after buffer", "Asia/Tokyo", time.Date(2024, time.March, 12, 12, 0, 0, 0, time.UTC), true},
+		{"tokyo before typical hour", "Asia/Tokyo", time.Date(2024, time.March, 12, 10, 0, 0, 0, time.UTC), false},
+		{"unknown timezone uses utc", "Mars/Olympus", time.Date(2024, time.March, 12, 21, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak := &models.UserEngagementStreak{
+				Timezone:              tt.timezone,
+				TypicalHour:           intPtr(20),
+				TypicalHourConfidence: 0.9,
+			}
+			assert.Equal(t, tt.expected, policy.IsDue(tt.now, streak))
+		})
+	}
+}
+
+func TestStreakReminderPolicy_LowConfidenceFallsBackToDefaultHour(t *testing.T) {
+	policy := StreakReminderPolicy{Buffer: 30 * time.Minute, DefaultHour: 18, MinConfidence: 0.5}
+
+	streak := &models.UserEngagementStreak{
+		Timezone:              "UTC",
+		TypicalHour:           intPtr(21),
+		TypicalHourConfidence: 0.2,
+	}
+
+	assert.Equal(t, 18, policy.ReminderHour(streak))
+	assert.False(t, policy.IsDue(time.Date(2024, time.March, 12, 18, 15, 0, 0, time.UTC), streak))
+	assert.True(t, policy.IsDue(time.Date(2024, time.March, 12, 18, 30, 0, 0, time.UTC), streak))
+
+	streak.TypicalHour = nil
+	streak.TypicalHourConfidence = 1
+	assert.Equal(t, 18, policy.ReminderHour(streak))
+}
+
+func TestStreakReminderPolicy_BufferPastMidnightStaysOnSameDay(t *testing.T) {
+	policy := StreakReminderPolicy{Buffer: 3 * time.Hour, DefaultHour: 18, MinConfidence: 0.5}
+
+	streak := &models.UserEngagementStreak{
+		Timezone:              "UTC",
+		TypicalHour:           intPtr(22),
+		TypicalHourConfidence: 1,
+	}
+
+	assert.False(t, policy.IsDue(time.Date(2024, time.March, 12, 22, 59, 0, 0, time.UTC), streak))
+	assert.True(t, policy.IsDue(time.Date(2024, time.March, 12, 23, 0, 0, 0, time.UTC), streak))
+}
+
+func TestLastChanceWindow_UsesStreakTimezone(t *testing.T) {
+	window := LastChanceWindow{StartHour: 20, EndHour: 23}
+	march := func(day int) *time.Time {
+		d := time.Date(2024, time.March, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	tests := []struct {
+		name         string
+		timezone     string
+		now          time.Time
+		lastActivity *time.Time
+		expected     bool
+	}{
+		{"utc inside window", "UTC", time.Date(2024, time.March, 12, 21, 0, 0, 0, time.UTC), march(11), true},
+		{"utc before window", "UTC", time.Date(2024, time.March, 12, 19, 59, 0, 0, time.UTC), march(11), false},
+		{"window end is exclusive", "UTC", time.Date(2024, time.March, 12, 23, 0, 0, 0, time.UTC), march(11), false},
+		{"practiced today", "UTC", time.Date(2024, time.March, 12, 21, 0, 0, 0, time.UTC), march(12), false},
+		// 01:30 UTC on the 13th is 21:30 on the 12th in New York, where the user has not practiced yet
+		{"new york evening is the next utc day", "America/New_York", time.Date(2024, time.March, 13, 1, 30, 0, 0, time.UTC), march(11), true},
+		{"new york practiced earlier that local day", "America/New_York", time.Date(2024, time.March, 13, 1, 30, 0, 0, time.UTC), march(12), false},
+		// 21:00 UTC is already 06:00 the next day in Tokyo
+		{"tokyo morning is outside the window", "Asia/Tokyo", time.Date(2024, time.March, 12, 21, 0, 0, 0, time.UTC), march(11), false},
+		{"tokyo evening", "Asia/Tokyo", time.Date(2024, time.March, 12, 12, 0, 0, 0, time.UTC), march(11), true},
+		// New York springs forward on March 10th; 00:30 UTC on the 11th is 20:30 EDT
+		{"new york after daylight saving change", "America/New_York", time.Date(2024, time.March, 11, 0, 30, 0, 0, time.UTC), march(9), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak := &models.UserEngagementStreak{Timezone: tt.timezone, CurrentStreak: 4, LastActivityDate: tt.lastActivity}
+			assert.Equal(t, tt.expected, window.IsOpen(tt.now, streak))
+		})
+	}
+}
+
+func TestLastChanceWindow_NeedsActiveStreak(t *testing.T) {
+	window := LastChanceWindow{StartHour: 20, EndHour: 23}
+	now := time.Date(2024, time.March, 12, 21, 0, 0, 0, time.UTC)
+
+	assert.False(t, window.IsOpen(now, nil))
+	assert.False(t, window.IsOpen(now, &models.UserEngagementStreak{Timezone: "UTC"}))
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"text/template"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PreferencesDigestWindow collapses rapid successive preference edits into one summary
+	PreferencesDigestWindow = 10 * time.Minute
+
+	// preferencesDigestDedupeKey prefixes the dedupe key of preference summary
+	// notifications, which is suffixed with the channel as fan-out does, since
+	// a user's live dedupe keys must be unique
+	preferencesDigestDedupeKey = "preferences_updated"
+
+	// defaultPreferencesDigestBody is used when no active template exists
+	defaultPreferencesDigestBody = `Your notification settings were updated: {{range $i, $c := .Changes}}{{if $i}}, {{end}}{{$c.Type}} via {{$c.Channel}} {{$c.Field}} {{$c.From}} -> {{$c.To}}{{end}}.`
+)
+
+// PreferenceChange is one setting that changed in a preference update
+type PreferenceChange struct {
+	Type    models.NotificationType    `json:"type"`
+	Channel models.NotificationChannel `json:"channel"`
+	Field   string                     `json:"field"`
+	From    string                     `json:"from"`
+	To      string                     `json:"to"`
+}
+
+// diffPreference lists the settings that differ between the stored preference
+// (nil when none existed) and the updated one
+func diffPreference(before, after *models.UserNotificationPreferences) []PreferenceChange {
+	if before == nil {
+		before = &models.UserNotificationPreferences{Enabled: true}
+	}
+
+	change := func(field, from, to string) PreferenceChange {
+		return PreferenceChange{Type: after.Type, Channel: after.Channel, Field: field, From: from, To: to}
+	}
+
+	var changes []PreferenceChange
+	if before.Enabled != after.Enabled {
+		changes = append(changes, change("enabled", strconv.FormatBool(before.Enabled), strconv.FormatBool(after.Enabled)))
+	}
+	if from, to := formatOptional(before.QuietHoursStart), formatOptional(after.QuietHoursStart); from != to {
+		changes = append(changes, change("quiet_hours_start", from, to))
+	}
+	if from, to := formatOptional(before.QuietHoursEnd), formatOptional(after.QuietHoursEnd); from != to {
+		changes = append(changes, change("quiet_hours_end", from, to))
+	}
+	if from, to := formatOptionalInt(before.MaxPerDay), formatOptionalInt(after.MaxPerDay); from != to {
+		changes = append(changes, change("max_per_day", from, to))
+	}
+	return changes
+}
+
+func formatOptional(s *string) string {
+	if s == nil || *s == "" {
+		return "none"
+	}
+	return *s
+}
+
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return "none"
+	}
+	return strconv.Itoa(*i)
+}
+
+// sendPreferencesDigest creates a low-priority summary of preference changes on
+// in_app, plus email when the user has it enabled for this type. A summary
+// created on a channel within PreferencesDigestWindow absorbs further edits;
+// an older one has its dedupe key retired so the new summary can take it.
+func (s *notificationService) sendPreferencesDigest(ctx context.Context, userID uuid.UUID, prefs []models.UserNotificationPreferences, changes []PreferenceChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	channels := []models.NotificationChannel{models.ChannelInApp}
+	if pref := FindPreference(prefs, models.PreferencesUpdated, models.ChannelEmail); pref != nil && pref.Enabled {
+		channels = append(channels, models.ChannelEmail)
+	}
+
+	changeList := make([]interface{}, len(changes))
+	for i, c := range changes {
+		changeList[i] = map[string]interface{}{
+			"type": c.Type, "channel": c.Channel, "field": c.Field, "from": c.From, "to": c.To,
+		}
+	}
+
+	now := time.Now()
+	for _, channel := range channels {
+		dedupeKey := fmt.Sprintf("%s:%s", preferencesDigestDedupeKey, channel)
+		recent, err := s.repository.GetActiveNotificationByDedupeKey(ctx, userID, dedupeKey)
+		switch {
+		case err == nil && now.Sub(recent.CreatedAt) < PreferencesDigestWindow:
+			continue
+		case err == nil:
+			if err := s.repository.RetireDedupeKey(ctx, recent.ID); err != nil {
+				return err
+			}
+		case !errors.Is(err, repository.ErrNotificationNotFound):
+			return fmt.Errorf("failed to look up recent preferences summary: %w", err)
+		}
+
+		title, body := s.renderPreferencesDigest(ctx, channel, changes)
+		notification := &models.Notification{
+			ID:        models.NewNotificationID(),
+			UserID:    userID,
+			Type:      models.PreferencesUpdated,
+			Channel:   channel,
+			Priority:  models.PriorityLow,
+			Title:     title,
+			Message:   body,
+			Metadata:  models.JSONMap{"changes": changeList},
+			DedupeKey: &dedupeKey,
+			Status:    models.StatusQueued,
+			CreatedAt: now,
+		}
+
+		outboxItem, err := models.BuildOutboxEntry(notification, s.topicFor(notification.Priority))
+		if err != nil {
+			return err
+		}
+		err = s.repository.CreateNotificationWithOutbox(ctx, notification, outboxItem)
+		if errors.Is(err, repository.ErrDuplicateDedupeKey) {
+			// A concurrent edit created this channel's summary first
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create preferences summary: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// renderPreferencesDigest renders the newest active template for the channel,
+// falling back to a built-in body
+func (s *notificationService) renderPreferencesDigest(ctx context.Context, channel models.NotificationChannel, changes []PreferenceChange) (*string, string) {
+	title := stringPtr("Notification settings updated")
+	body := defaultPreferencesDigestBody
+
+	templates, err := s.repository.GetNotificationTemplates(ctx, models.PreferencesUpdated, channel)
+	if err != nil {
+		log.Printf("Failed to load preferences summary template for %s: %v", channel, err)
+	} else if len(templates) > 0 {
+		title = templates[0].Title
+		body = templates[0].Body
+	}
+
+	message, err := renderChanges(body, changes)
+	if err != nil {
+		log.Printf("Failed to render preferences summary template for %s, using default: %v", channel, err)
+		message, _ = renderChanges(defaultPreferencesDigestBody, changes)
+	}
+	return title, message
+}
+
+// renderChanges executes a text/template body with the changes as .Changes
+func renderChanges(body string, changes []PreferenceChange) (string, error) {
+	tmpl, err := template.New("preferences_updated").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Changes []PreferenceChange }{changes}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func disablePushPreference() *models.NotificationPreferencesRequest {
+	return &models.NotificationPreferencesRequest{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false}
+}
+
+func TestUpdateUserPreferences_SendsSummaryOfChanges(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+	prefs := disablePushPreference()
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true},
+	}, nil)
+	mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).Return(prefs.Preferences(userID), nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("GetNotificationTemplates", ctx, models.PreferencesUpdated, models.ChannelInApp).Return([]models.NotificationTemplate{}, nil)
+
+	var saved *models.Notification
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Run(func(args mock.Arguments) { saved = args.Get(1).(*models.Notification) }).
+		Return(nil).Once()
+
+	// Act
+	_, err := service.UpdateUserPreferences(ctx, userID, prefs)
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, models.PreferencesUpdated, saved.Type)
+	assert.Equal(t, models.ChannelInApp, saved.Channel)
+	assert.Equal(t, models.PriorityLow, saved.Priority)
+	assert.Contains(t, saved.Message, "streak_reminder via push enabled true -> false")
+	changes := saved.Metadata["changes"].([]interface{})
+	require.Len(t, changes, 1)
+	assert.Equal(t, "enabled", changes[0].(map[string]interface{})["field"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUserPreferences_CollapsesEditsWithinWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastSummary time.Duration
+		wantSummary bool
+	}{
+		{name: "recent summary absorbs the edit", lastSummary: 2 * time.Minute, wantSummary: false},
+		{name: "summary outside the window", lastSummary: PreferencesDigestWindow + time.Minute, wantSummary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+			ctx := context.Background()
+			userID := uuid.New()
+			prefs := disablePushPreference()
+
+			mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{}, nil)
+			mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).Return(prefs.Preferences(userID), nil)
+			previous := uuid.New()
+			mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").
+				Return(&models.Notification{ID: previous, CreatedAt: time.Now().Add(-tt.lastSummary)}, nil)
+			if tt.wantSummary {
+				// The expired summary gives up its key for the new one
+				mockRepo.On("RetireDedupeKey", ctx, previous).Return(nil)
+				mockRepo.On("GetNotificationTemplates", ctx, models.PreferencesUpdated, models.ChannelInApp).Return([]models.NotificationTemplate{}, nil)
+				mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil).Once()
+			}
+
+			// Act
+			_, err := service.UpdateUserPreferences(ctx, userID, prefs)
+
+			// Assert
+			require.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+			if !tt.wantSummary {
+				mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUpdateUserPreferences_SummaryIgnoresOptOut(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+	prefs := &models.NotificationPreferencesRequest{Type: models.PreferencesUpdated, Channel: models.ChannelInApp, Enabled: false}
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{
+		{Type: models.PreferencesUpdated, Channel: models.ChannelEmail, Enabled: true},
+	}, nil)
+	mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).Return(prefs.Preferences(userID), nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:email").Return(nil, repository.ErrNotificationNotFound)
+	mockRepo.On("GetNotificationTemplates", ctx, models.PreferencesUpdated, mock.Anything).Return([]models.NotificationTemplate{}, nil)
+
+	var channels []models.NotificationChannel
+	var keys []string
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).
+		Run(func(args mock.Arguments) {
+			n := args.Get(1).(*models.Notification)
+			channels = append(channels, n.Channel)
+			keys = append(keys, *n.DedupeKey)
+		}).
+		Return(nil)
+
+	// Act
+	_, err := service.UpdateUserPreferences(ctx, userID, prefs)
+
+	// Assert: each channel's row holds its own live dedupe key
+	require.NoError(t, err)
+	assert.Equal(t, []models.NotificationChannel{models.ChannelInApp, models.ChannelEmail}, channels)
+	assert.Equal(t, []string{"preferences_updated:in_app", "preferences_updated:email"}, keys)
+}
+
+func TestUpdateUserPreferences_SummaryFailureDoesNotFailUpdate(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	userID := uuid.New()
+	prefs := disablePushPreference()
+
+	mockRepo.On("GetUserPreferences", ctx, userID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("UpdateUserPreferences", ctx, userID, mock.AnythingOfType("*models.UserNotificationPreferences")).Return(prefs.Preferences(userID), nil)
+	mockRepo.On("GetActiveNotificationByDedupeKey", ctx, userID, "preferences_updated:in_app").Return(nil, assert.AnError)
+
+	// Act
+	_, err := service.UpdateUserPreferences(ctx, userID, prefs)
+
+	// Assert
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeliveryPolicyPlan_PreferencesUpdatedIsOptOutExempt(t *testing.T) {
+	n := &models.Notification{Type: models.PreferencesUpdated, Channel: models.ChannelInApp}
+	pc := PlanContext{
+		At:       time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC),
+		Location: time.UTC,
+		Preferences: []models.UserNotificationPreferences{
+			{Type: models.PreferencesUpdated, Channel: models.ChannelInApp, Enabled: false},
+		},
+	}
+
+	plan := NewDeliveryPolicy(new(MockNotificationRepository)).Plan(context.Background(), n, pc)
+
+	assert.Equal(t, DecisionSend, plan.Decision.Outcome)
+	assert.Equal(t, RuleDefault, plan.Decision.Rule)
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"time"
+
+	"kafka-notify/pkg/models"
+)
+
+// quietHoursDeferral returns when a notification created at now may be delivered
+// if now falls in the preference's quiet hours in loc, or nil when it can go out
+// straight away
+func quietHoursDeferral(pref *models.UserNotificationPreferences, loc *time.Location, now time.Time) *time.Time {
+	if pref.QuietHoursStart == nil || pref.QuietHoursEnd == nil {
+		return nil
+	}
+
+	end, ok := quietHoursEnd(now.In(loc), *pref.QuietHoursStart, *pref.QuietHoursEnd)
+	if !ok {
+		return nil
+	}
+	end = end.UTC()
+	return &end
+}
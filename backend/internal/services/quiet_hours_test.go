@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2024, 3, d, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		local      time.Time
+		start, end string
+		want       time.Time
+		inside     bool
+	}{
+		{name: "inside same-day window", local: day(12, 13, 30), start: "13:00", end: "15:00", want: day(12, 15, 0), inside: true},
+		{name: "window end is exclusive", local: day(12, 15, 0), start: "13:00", end: "15:00"},
+		{name: "before same-day window", local: day(12, 12, 59), start: "13:00", end: "15:00"},
+		{name: "wrapping window before midnight", local: day(12, 23, 15), start: "22:00", end: "07:00", want: day(13, 7, 0), inside: true},
+		{name: "wrapping window after midnight", local: day(13, 2, 0), start: "22:00", end: "07:00", want: day(13, 7, 0), inside: true},
+		{name: "outside wrapping window", local: day(12, 12, 0), start: "22:00", end: "07:00"},
+		{name: "month rollover", local: day(31, 22, 30), start: "22:00", end: "07:00", want: time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC), inside: true},
+		{name: "malformed start", local: day(12, 23, 15), start: "10pm", end: "07:00"},
+		{name: "malformed end", local: day(12, 23, 15), start: "22:00", end: "25:00"},
+		{name: "empty window", local: day(12, 22, 0), start: "22:00", end: "22:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, inside := quietHoursEnd(tt.local, tt.start, tt.end)
+			assert.Equal(t, tt.inside, inside)
+			if tt.inside {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+// quietHoursFixture returns a request, a service fixed at now and its repository
+// with a quiet window from start to end on the request's type and channel
+func quietHoursFixture(now time.Time, start, end string) (*notificationService, *MockNotificationRepository, *models.CreateNotificationRequest) {
+	req := &models.CreateNotificationRequest{
+		UserID:   uuid.New(),
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelPush,
+		Priority: models.PriorityMedium,
+		Message:  "Keep your streak going",
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{
+		{UserID: req.UserID, Type: req.Type, Channel: req.Channel, Enabled: true, QuietHoursStart: strPtr(start), QuietHoursEnd: strPtr(end)},
+	}, nil)
+	mockRepo.On("MarkPreferenceSent", mock.Anything, req.UserID, req.Type, req.Channel, now).Return(nil)
+
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+	return service, mockRepo, req
+}
+
+func TestCreateNotification_DefersDuringQuietHours(t *testing.T) {
+	// Arrange: 03:30 UTC is 23:30 the previous evening in New York (UTC-4 in March)
+	service, mockRepo, req := quietHoursFixture(time.Date(2024, 3, 12, 3, 30, 0, 0, time.UTC), "22:00", "07:00")
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").
+		Return(&models.UserEngagementStreak{UserID: req.UserID, Timezone: "America/New_York"}, nil)
+	mockRepo.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.Notification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: held until 07:00 New York time, with no outbox entry yet
+	require.NoError(t, err)
+	require.NotNil(t, notification.ScheduledFor)
+	assert.Equal(t, time.Date(2024, 3, 12, 11, 0, 0, 0, time.UTC), *notification.ScheduledFor)
+	assert.Equal(t, models.StatusQueued, notification.Status)
+	assert.Equal(t, RuleQuietHours, notification.Metadata["decision_rule"], "recorded for decision replays")
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_DefersUntilSameDayWindowEnds(t *testing.T) {
+	// Arrange: a user without a streak is treated as UTC
+	service, mockRepo, req := quietHoursFixture(time.Date(2024, 3, 12, 14, 10, 0, 0, time.UTC), "13:00", "15:00")
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.Notification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, notification.ScheduledFor)
+	assert.Equal(t, time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC), *notification.ScheduledFor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_SendsOutsideLocalQuietHours(t *testing.T) {
+	// Arrange: 23:00 UTC is inside the window in UTC but 16:00 in Los Angeles
+	service, mockRepo, req := quietHoursFixture(time.Date(2024, 3, 12, 23, 0, 0, 0, time.UTC), "22:00", "07:00")
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").
+		Return(&models.UserEngagementStreak{UserID: req.UserID, Timezone: "America/Los_Angeles"}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, notification.ScheduledFor)
+	mockRepo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_MalformedQuietHoursSendNow(t *testing.T) {
+	// Arrange
+	service, mockRepo, req := quietHoursFixture(time.Date(2024, 3, 12, 23, 0, 0, 0, time.UTC), "late", "07:00")
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, req.UserID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("CreateNotificationWithOutbox", mock.Anything, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, notification.ScheduledFor)
+	mockRepo.AssertExpectations(t)
+}
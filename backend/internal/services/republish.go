@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrRepublishRead is returned when republishing a notification the user has
+// already read without forcing it
+var ErrRepublishRead = apperr.New(apperr.ErrConflict, "notification has already been read")
+
+// RepublishResult describes a notification queued for publishing again
+type RepublishResult struct {
+	Notification *models.Notification `json:"notification"`
+	// Outbox is set when the outbox was processed straight away
+	Outbox *OutboxResult `json:"outbox,omitempty"`
+}
+
+// RepublishNotification re-emits a stored notification to Kafka by queueing a
+// fresh outbox entry for it. Read notifications are refused unless force is set.
+// With publishNow the outbox is processed immediately instead of on the next tick.
+func (s *notificationService) RepublishNotification(ctx context.Context, notificationID uuid.UUID, force, publishNow bool) (*RepublishResult, error) {
+	notification, err := s.repository.GetNotificationByID(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Status == models.StatusRead && !force {
+		return nil, fmt.Errorf("%w: %s", ErrRepublishRead, notificationID)
+	}
+
+	outboxItem, err := models.BuildOutboxEntry(notification, s.topicFor(notification.Priority))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.RequeueOutboxEntry(ctx, outboxItem); err != nil {
+		return nil, fmt.Errorf("failed to republish notification: %w", err)
+	}
+
+	result := &RepublishResult{Notification: notification}
+	if publishNow {
+		// Per-item failures stay in the outbox for the processor to retry
+		result.Outbox, err = s.ProcessOutbox(ctx)
+		if result.Outbox == nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepublishNotification_RequeuesOutboxEntry(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), UserID: uuid.New(), Type: models.StreakReminder, Channel: models.ChannelInApp, Status: models.StatusDelivered}
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+	mockRepo.On("RequeueOutboxEntry", ctx, mock.MatchedBy(func(o *models.OutboxNotification) bool {
+		event, ok := o.Payload["notification"].(map[string]interface{})
+		return ok && o.NotificationID == notification.ID && o.Topic == "test-topic" && event["id"] == notification.ID.String()
+	})).Return(nil)
+
+	// Act
+	result, err := service.RepublishNotification(ctx, notification.ID, false, false)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, notification, result.Notification)
+	assert.Nil(t, result.Outbox, "the processor publishes it on its next tick")
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ClaimUnpublishedOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRepublishNotification_RefusesReadUnlessForced(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), Status: models.StatusRead}
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+
+	// Act
+	_, err := service.RepublishNotification(ctx, notification.ID, false, false)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrRepublishRead)
+	mockRepo.AssertNotCalled(t, "RequeueOutboxEntry", mock.Anything, mock.Anything)
+
+	// Forcing it queues the read notification anyway
+	mockRepo.On("RequeueOutboxEntry", ctx, mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+	_, err = service.RepublishNotification(ctx, notification.ID, true, false)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRepublishNotification_PublishesImmediately(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockProducer := new(MockKafkaProducer)
+	service := NewNotificationService(mockRepo, mockProducer, "test-topic")
+	ctx := context.Background()
+
+	notification := &models.Notification{ID: uuid.New(), Status: models.StatusSent}
+	requeued := testOutboxItem(12, notification.ID)
+	mockRepo.On("GetNotificationByID", ctx, notification.ID).Return(notification, nil)
+	mockRepo.On("RequeueOutboxEntry", ctx, mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+	mockRepo.On("ClaimUnpublishedOutbox", ctx, mock.AnythingOfType("string"), DefaultOutboxBatchSize).
+		Return([]models.OutboxNotification{requeued}, nil)
+	mockProducer.On("SendMessages", mock.AnythingOfType("[]*sarama.ProducerMessage")).Return(func([]*sarama.ProducerMessage) error { return nil })
+	mockRepo.On("MarkOutboxBatchSent", ctx, []int64{12}).Return(nil)
+	mockRepo.On("CountPendingOutbox", ctx).Return(0, nil)
+
+	// Act
+	result, err := service.RepublishNotification(ctx, notification.ID, false, true)
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, result.Outbox)
+	assert.Equal(t, 1, result.Outbox.Published)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRepublishNotification_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+	id := uuid.New()
+
+	mockRepo.On("GetNotificationByID", ctx, id).Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, id))
+
+	// Act
+	_, err := service.RepublishNotification(ctx, id, true, true)
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrNotificationNotFound)
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRetryMaxAttempts is the number of delivery attempts after which a
+	// failed notification is no longer requeued automatically
+	DefaultRetryMaxAttempts = 5
+	// DefaultRetryBackoffBase is the wait after a notification's first failed attempt
+	DefaultRetryBackoffBase = time.Minute
+	// DefaultRetryBackoffMax caps the wait between requeues of one notification
+	DefaultRetryBackoffMax = time.Hour
+
+	// RetryBatchSize caps how many failed notifications one retry pass looks at
+	RetryBatchSize = 200
+)
+
+// ErrNotRetryable is returned when requeueing a notification that is not
+// failed, or failed permanently without forcing it
+var ErrNotRetryable = apperr.New(apperr.ErrConflict, "notification cannot be retried")
+
+// WithDeliveryRetry sets how many delivery attempts a failed notification is
+// requeued for and the wait after its latest one: base, doubled per earlier
+// attempt, capped at max
+func WithDeliveryRetry(maxAttempts int, base, max time.Duration) Option {
+	return func(s *notificationService) {
+		if maxAttempts > 0 {
+			s.retryMaxAttempts = maxAttempts
+		}
+		if base > 0 {
+			s.retryBackoffBase = base
+		}
+		if max > 0 {
+			s.retryBackoffMax = max
+		}
+	}
+}
+
+// RetryResult counts what one retry pass did with the failed notifications it read
+type RetryResult struct {
+	Requeued int `json:"requeued"`
+	// Waiting are still inside their backoff
+	Waiting int `json:"waiting"`
+	// Exhausted used up their delivery attempts
+	Exhausted int `json:"exhausted"`
+	// Permanent were rejected in a way another attempt cannot fix
+	Permanent int `json:"permanent"`
+}
+
+// retryState is what a retry pass does with one failed notification
+type retryState int
+
+const (
+	retryDue retryState = iota
+	retryWaiting
+	retryExhausted
+	retryPermanent
+)
+
+// retryStateOf classifies a failed notification by its delivery attempts,
+// oldest first
+func (s *notificationService) retryStateOf(attempts []models.NotificationDeliveryAttempt, now time.Time) retryState {
+	if len(attempts) == 0 {
+		// Failed before any send, such as on a channel without a sender
+		return retryPermanent
+	}
+	last := attempts[len(attempts)-1]
+	if isPermanentAttempt(last) {
+		return retryPermanent
+	}
+	if len(attempts) >= s.retryMaxAttempts {
+		return retryExhausted
+	}
+	if now.Before(last.CreatedAt.Add(outboxBackoff(len(attempts), s.retryBackoffBase, s.retryBackoffMax))) {
+		return retryWaiting
+	}
+	return retryDue
+}
+
+// isPermanentAttempt reports whether the provider rejected the attempt (4xx),
+// such as an invalid address or unregistered device token. Attempts without a
+// code timed out or never reached the provider.
+func isPermanentAttempt(attempt models.NotificationDeliveryAttempt) bool {
+	if attempt.ErrorCode == nil {
+		return false
+	}
+	code, err := strconv.Atoi(*attempt.ErrorCode)
+	return err == nil && code >= 400 && code < 500
+}
+
+// RetryFailed requeues failed notifications whose latest delivery attempt
+// failed transiently and whose backoff has passed, until they have used up
+// their delivery attempts. Requeued notifications go back to queued with a
+// fresh outbox entry; a notification another producer requeued first is skipped.
+func (s *notificationService) RetryFailed(ctx context.Context) (*RetryResult, error) {
+	failed, err := s.repository.GetNotificationsByStatus(ctx, models.StatusFailed, RetryBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed notifications: %w", err)
+	}
+
+	result := &RetryResult{}
+	now := s.now()
+	for i := range failed {
+		n := &failed[i]
+		attempts, err := s.repository.GetDeliveryAttempts(ctx, n.ID)
+		if err != nil {
+			return result, fmt.Errorf("failed to get delivery attempts for %s: %w", n.ID, err)
+		}
+
+		switch s.retryStateOf(attempts, now) {
+		case retryWaiting:
+			result.Waiting++
+			continue
+		case retryExhausted:
+			result.Exhausted++
+			continue
+		case retryPermanent:
+			result.Permanent++
+			continue
+		}
+
+		requeued, err := s.requeueFailed(ctx, n)
+		if err != nil {
+			return result, err
+		}
+		if requeued {
+			result.Requeued++
+		}
+	}
+
+	if result.Requeued > 0 {
+		log.Printf("Requeued %d failed notifications for delivery", result.Requeued)
+	}
+	return result, nil
+}
+
+// RetryNotification requeues one failed notification for delivery regardless
+// of its attempt count. One the provider rejected permanently is refused
+// unless force is set, e.g. after the user fixed their address.
+func (s *notificationService) RetryNotification(ctx context.Context, notificationID uuid.UUID, force bool) (*models.Notification, error) {
+	notification, err := s.repository.GetNotificationByID(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Status != models.StatusFailed {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotRetryable, notificationID, notification.Status)
+	}
+
+	if !force {
+		attempts, err := s.repository.GetDeliveryAttempts(ctx, notificationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get delivery attempts: %w", err)
+		}
+		if len(attempts) > 0 && isPermanentAttempt(attempts[len(attempts)-1]) {
+			return nil, fmt.Errorf("%w: %s was rejected by the provider", ErrNotRetryable, notificationID)
+		}
+	}
+
+	requeued, err := s.requeueFailed(ctx, notification)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		return nil, fmt.Errorf("%w: %s is no longer failed", ErrNotRetryable, notificationID)
+	}
+	notification.Status = models.StatusQueued
+	return notification, nil
+}
+
+// requeueFailed moves a failed notification back to queued with a fresh
+// outbox entry, reporting whether it was still failed
+func (s *notificationService) requeueFailed(ctx context.Context, n *models.Notification) (bool, error) {
+	outboxItem, err := models.BuildOutboxEntry(n, s.topicFor(n.Priority))
+	if err != nil {
+		return false, err
+	}
+	requeued, err := s.repository.RequeueFailedNotification(ctx, outboxItem)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue notification %s: %w", n.ID, err)
+	}
+	return requeued, nil
+}
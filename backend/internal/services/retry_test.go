@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func retryFixture(now time.Time) (*notificationService, *MockNotificationRepository) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic",
+		WithDeliveryRetry(5, time.Minute, 5*time.Minute)).(*notificationService)
+	service.now = func() time.Time { return now }
+	return service, mockRepo
+}
+
+// failedAttempts returns n failed attempts, the last made at last, with the
+// last one's error code set to code ("" for a timeout without a code)
+func failedAttempts(n int, last time.Time, code string) []models.NotificationDeliveryAttempt {
+	attempts := make([]models.NotificationDeliveryAttempt, n)
+	for i := range attempts {
+		attempts[i] = models.NotificationDeliveryAttempt{AttemptNo: i + 1, Status: models.StatusFailed, CreatedAt: last}
+	}
+	if code != "" {
+		attempts[n-1].ErrorCode = &code
+	}
+	return attempts
+}
+
+func TestRetryStateOf_Backoff(t *testing.T) {
+	now := time.Now()
+	service, _ := retryFixture(now)
+
+	tests := []struct {
+		name     string
+		attempts int
+		since    time.Duration
+		want     retryState
+	}{
+		{"first attempt inside base", 1, 59 * time.Second, retryWaiting},
+		{"first attempt after base", 1, time.Minute, retryDue},
+		{"second attempt doubles", 2, 90 * time.Second, retryWaiting},
+		{"second attempt after double", 2, 2 * time.Minute, retryDue},
+		{"fourth attempt is capped", 4, 5 * time.Minute, retryDue},
+		{"fourth attempt inside cap", 4, 4*time.Minute + 59*time.Second, retryWaiting},
+		{"max attempts reached", 5, time.Hour, retryExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, service.retryStateOf(failedAttempts(tt.attempts, now.Add(-tt.since), "503"), now))
+		})
+	}
+}
+
+func TestRetryStateOf_Permanent(t *testing.T) {
+	now := time.Now()
+	service, _ := retryFixture(now)
+
+	assert.Equal(t, retryPermanent, service.retryStateOf(failedAttempts(1, now.Add(-time.Hour), "400"), now), "invalid address")
+	assert.Equal(t, retryPermanent, service.retryStateOf(failedAttempts(2, now.Add(-time.Hour), "404"), now), "unregistered token")
+	assert.Equal(t, retryPermanent, service.retryStateOf(nil, now), "failed before any send")
+	assert.Equal(t, retryDue, service.retryStateOf(failedAttempts(1, now.Add(-time.Hour), ""), now), "timeouts carry no code")
+}
+
+func TestRetryFailed_RequeuesDueNotifications(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	service, mockRepo := retryFixture(now)
+	ctx := context.Background()
+
+	due := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelEmail, Status: models.StatusFailed}
+	waiting := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelEmail, Status: models.StatusFailed}
+	exhausted := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelEmail, Status: models.StatusFailed}
+	rejected := models.Notification{ID: uuid.New(), UserID: uuid.New(), Channel: models.ChannelPush, Status: models.StatusFailed}
+	mockRepo.On("GetNotificationsByStatus", ctx, models.StatusFailed, RetryBatchSize).
+		Return([]models.Notification{due, waiting, exhausted, rejected}, nil)
+	mockRepo.On("GetDeliveryAttempts", ctx, due.ID).Return(failedAttempts(3, now.Add(-5*time.Minute), "503"), nil)
+	mockRepo.On("GetDeliveryAttempts", ctx, waiting.ID).Return(failedAttempts(3, now.Add(-3*time.Minute), "503"), nil)
+	mockRepo.On("GetDeliveryAttempts", ctx, exhausted.ID).Return(failedAttempts(5, now.Add(-time.Hour), "503"), nil)
+	mockRepo.On("GetDeliveryAttempts", ctx, rejected.ID).Return(failedAttempts(1, now.Add(-time.Hour), "404"), nil)
+	mockRepo.On("RequeueFailedNotification", ctx, mock.MatchedBy(func(o *models.OutboxNotification) bool {
+		return o.NotificationID == due.ID && o.Topic == "test-topic"
+	})).Return(true, nil)
+
+	// Act
+	result, err := service.RetryFailed(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, &RetryResult{Requeued: 1, Waiting: 1, Exhausted: 1, Permanent: 1}, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetryNotification(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("requeues failed", func(t *testing.T) {
+		// Arrange
+		service, mockRepo := retryFixture(now)
+		n := &models.Notification{ID: uuid.New(), Status: models.StatusFailed}
+		mockRepo.On("GetNotificationByID", ctx, n.ID).Return(n, nil)
+		mockRepo.On("GetDeliveryAttempts", ctx, n.ID).Return(failedAttempts(5, now, "503"), nil)
+		mockRepo.On("RequeueFailedNotification", ctx, mock.Anything).Return(true, nil)
+
+		// Act
+		requeued, err := service.RetryNotification(ctx, n.ID, false)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusQueued, requeued.Status, "manual retries ignore the attempt cap")
+	})
+
+	t.Run("refuses permanent failure unless forced", func(t *testing.T) {
+		// Arrange
+		service, mockRepo := retryFixture(now)
+		n := &models.Notification{ID: uuid.New(), Status: models.StatusFailed}
+		mockRepo.On("GetNotificationByID", ctx, n.ID).Return(n, nil)
+		mockRepo.On("GetDeliveryAttempts", ctx, n.ID).Return(failedAttempts(1, now, "400"), nil)
+		mockRepo.On("RequeueFailedNotification", ctx, mock.Anything).Return(true, nil)
+
+		// Act
+		_, err := service.RetryNotification(ctx, n.ID, false)
+		_, forcedErr := service.RetryNotification(ctx, n.ID, true)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrNotRetryable)
+		assert.NoError(t, forcedErr)
+		mockRepo.AssertNumberOfCalls(t, "RequeueFailedNotification", 1)
+	})
+
+	t.Run("refuses notification that is not failed", func(t *testing.T) {
+		// Arrange
+		service, mockRepo := retryFixture(now)
+		n := &models.Notification{ID: uuid.New(), Status: models.StatusDelivered}
+		mockRepo.On("GetNotificationByID", ctx, n.ID).Return(n, nil)
+
+		// Act
+		_, err := service.RetryNotification(ctx, n.ID, true)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrNotRetryable)
+		mockRepo.AssertNotCalled(t, "RequeueFailedNotification", mock.Anything, mock.Anything)
+	})
+}
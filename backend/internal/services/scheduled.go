@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"kafka-notify/pkg/models"
+)
+
+// ScheduledDispatchBatchSize caps how many due notifications one dispatch pass releases
+const ScheduledDispatchBatchSize = 500
+
+// DispatchScheduled releases queued notifications whose scheduled_for has
+// arrived, such as ones deferred past quiet hours, by giving each an outbox
+// entry. The outbox processor then publishes them and marks them sent. Rows are
+// claimed in the database, so concurrent producers never release one twice.
+func (s *notificationService) DispatchScheduled(ctx context.Context) error {
+	released, err := s.repository.ReleaseScheduledNotifications(ctx, s.now(), ScheduledDispatchBatchSize,
+		func(n *models.Notification) (*models.OutboxNotification, error) {
+			return models.BuildOutboxEntry(n, s.topicFor(n.Priority))
+		})
+	if err != nil {
+		return fmt.Errorf("failed to dispatch scheduled notifications: %w", err)
+	}
+
+	if len(released) > 0 {
+		log.Printf("Released %d scheduled notifications to the outbox", len(released))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduledStore releases scheduled notifications the way the Postgres claim
+// does: due, queued and not yet dispatched rows, each claimed exactly once
+type scheduledStore struct {
+	repository.NotificationRepository
+	mu         sync.Mutex
+	rows       []models.Notification
+	dispatched map[uuid.UUID]bool
+	outbox     []*models.OutboxNotification
+}
+
+func newScheduledStore(rows ...models.Notification) *scheduledStore {
+	return &scheduledStore{rows: rows, dispatched: make(map[uuid.UUID]bool)}
+}
+
+func (s *scheduledStore) ReleaseScheduledNotifications(ctx context.Context, before time.Time, limit int, build repository.OutboxBuilder) ([]models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var released []models.Notification
+	for _, n := range s.rows {
+		if len(released) == limit {
+			break
+		}
+		if n.Status != models.StatusQueued || n.ScheduledFor == nil || n.ScheduledFor.After(before) || s.dispatched[n.ID] {
+			continue
+		}
+		outboxItem, err := build(&n)
+		if err != nil {
+			return nil, err
+		}
+		s.dispatched[n.ID] = true
+		s.outbox = append(s.outbox, outboxItem)
+		released = append(released, n)
+	}
+	return released, nil
+}
+
+func scheduledAt(at time.Time, priority models.PriorityLevel) models.Notification {
+	return models.Notification{
+		ID:           uuid.New(),
+		UserID:       uuid.New(),
+		Type:         models.DailyReminder,
+		Channel:      models.ChannelInApp,
+		Priority:     priority,
+		Message:      "Time to practice",
+		Status:       models.StatusQueued,
+		ScheduledFor: &at,
+	}
+}
+
+func TestDispatchScheduled_ReleasesDueNotifications(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	past := scheduledAt(now.Add(-time.Hour), models.PriorityUrgent)
+	exactlyNow := scheduledAt(now, models.PriorityLow)
+	future := scheduledAt(now.Add(time.Second), models.PriorityLow)
+	store := newScheduledStore(past, exactlyNow, future)
+
+	service := NewNotificationService(store, new(MockKafkaProducer), "notifications",
+		WithPriorityTopics(map[models.PriorityLevel]string{models.PriorityUrgent: "notifications.urgent"})).(*notificationService)
+	service.now = func() time.Time { return now }
+
+	// Act
+	err := service.DispatchScheduled(context.Background())
+
+	// Assert: past and exactly-now rows are released, the future one waits
+	require.NoError(t, err)
+	require.Len(t, store.outbox, 2)
+	assert.Equal(t, past.ID, store.outbox[0].NotificationID)
+	assert.Equal(t, "notifications.urgent", store.outbox[0].Topic)
+	assert.Equal(t, exactlyNow.ID, store.outbox[1].NotificationID)
+	assert.Equal(t, "notifications", store.outbox[1].Topic)
+	assert.False(t, store.dispatched[future.ID])
+
+	// Act: once its time comes the future row goes out, and nothing is released twice
+	service.now = func() time.Time { return now.Add(time.Minute) }
+	require.NoError(t, service.DispatchScheduled(context.Background()))
+
+	// Assert
+	require.Len(t, store.outbox, 3)
+	assert.Equal(t, future.ID, store.outbox[2].NotificationID)
+}
+
+func TestDispatchScheduled_ConcurrentInstancesReleaseOnce(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	var rows []models.Notification
+	for i := 0; i < 50; i++ {
+		rows = append(rows, scheduledAt(now.Add(-time.Duration(i)*time.Second), models.PriorityMedium))
+	}
+	store := newScheduledStore(rows...)
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service := NewNotificationService(store, new(MockKafkaProducer), "notifications")
+			assert.NoError(t, service.DispatchScheduled(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	require.Len(t, store.outbox, len(rows))
+	seen := make(map[uuid.UUID]bool)
+	for _, item := range store.outbox {
+		assert.False(t, seen[item.NotificationID], "notification %s released twice", item.NotificationID)
+		seen[item.NotificationID] = true
+	}
+}
+
+func TestDispatchScheduled_ReleaseFailure(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("ReleaseScheduledNotifications", mock.Anything, mock.AnythingOfType("time.Time"), ScheduledDispatchBatchSize, mock.Anything).
+		Return(nil, errors.New("connection refused"))
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "notifications")
+
+	// Act
+	err := service.DispatchScheduled(context.Background())
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to dispatch scheduled notifications")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateNotification_ScheduledAheadWaitsForDispatch(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	scheduledFor := now.Add(2 * time.Hour)
+	req := &models.CreateNotificationRequest{
+		UserID:       uuid.New(),
+		Type:         models.DailyReminder,
+		Channel:      models.ChannelInApp,
+		Priority:     models.PriorityMedium,
+		Message:      "Time to practice",
+		ScheduledFor: &scheduledFor,
+	}
+
+	mockRepo := new(MockNotificationRepository)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, req.Type, req.Channel).Return([]models.NotificationTemplate{}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, req.UserID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.Notification")).Return(nil)
+
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic").(*notificationService)
+	service.now = func() time.Time { return now }
+
+	// Act
+	notification, err := service.CreateNotification(context.Background(), req)
+
+	// Assert: stored without an outbox entry until DispatchScheduled releases it
+	require.NoError(t, err)
+	assert.Equal(t, scheduledFor, *notification.ScheduledFor)
+	assert.Equal(t, models.StatusQueued, notification.Status)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
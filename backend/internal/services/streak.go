@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// PracticeStreakType is the streak that completed practice sessions count towards
+const PracticeStreakType = "practice"
+
+// streakTypes lists the streak types users can have
+var streakTypes = map[string]bool{
+	PracticeStreakType: true,
+}
+
+// ErrInvalidStreakType is returned for a streak type outside the allow-list
+var ErrInvalidStreakType = apperr.New(apperr.ErrInvalidInput, "invalid streak type")
+
+// StreakService keeps users' engagement streaks up to date with their activity
+type StreakService interface {
+	GetStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error)
+	RecordActivity(ctx context.Context, userID uuid.UUID, streakType string, at time.Time) (*models.UserEngagementStreak, error)
+}
+
+// streakService implements StreakService
+type streakService struct {
+	repository repository.NotificationRepository
+}
+
+// NewStreakService creates a new streak service
+func NewStreakService(repo repository.NotificationRepository) StreakService {
+	return &streakService{repository: repo}
+}
+
+// GetStreak returns the user's streak of a type, or a zeroed streak when the
+// user has no activity of that type yet
+func (s *streakService) GetStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error) {
+	if !streakTypes[streakType] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStreakType, streakType)
+	}
+
+	streak, err := s.repository.GetUserEngagementStreak(ctx, userID, streakType)
+	if errors.Is(err, repository.ErrStreakNotFound) {
+		return newStreak(userID, streakType), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s streak: %w", streakType, err)
+	}
+
+	return streak, nil
+}
+
+// RecordActivity counts an activity at the given time towards the user's
+// streak of a type, creating the streak on the first activity, and returns the
+// stored streak. The streak row is locked while the activity is applied, so
+// simultaneous activities each count.
+func (s *streakService) RecordActivity(ctx context.Context, userID uuid.UUID, streakType string, at time.Time) (*models.UserEngagementStreak, error) {
+	if !streakTypes[streakType] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStreakType, streakType)
+	}
+
+	streak, err := s.repository.RecordStreakActivity(ctx, userID, streakType, func(streak *models.UserEngagementStreak) {
+		ApplyActivity(streak, at)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s activity: %w", streakType, err)
+	}
+
+	return streak, nil
+}
+
+// newStreak returns an empty streak in the column defaults
+func newStreak(userID uuid.UUID, streakType string) *models.UserEngagementStreak {
+	return &models.UserEngagementStreak{UserID: userID, StreakType: streakType, Timezone: "UTC"}
+}
+
+// ApplyActivity advances the streak for an activity at the given time, judging
+// days in the streak's timezone. An activity the day after the last one extends
+// the streak, another one the same day leaves the count alone and anything else
+// starts a new streak of 1.
+func ApplyActivity(streak *models.UserEngagementStreak, at time.Time) {
+	today := calendarDate(at.In(LoadLocation(streak.Timezone)))
+	streak.TotalActivities++
+
+	if streak.LastActivityDate != nil {
+		last := calendarDate(*streak.LastActivityDate)
+		switch {
+		case !today.After(last):
+			// Already counted today, or the timezone moved the day back
+			return
+		case last.AddDate(0, 0, 1).Equal(today) && streak.CurrentStreak > 0:
+			streak.CurrentStreak++
+			if streak.StreakStartDate == nil {
+				start := today.AddDate(0, 0, 1-streak.CurrentStreak)
+				streak.StreakStartDate = &start
+			}
+			streak.LastActivityDate = &today
+			if streak.CurrentStreak > streak.LongestStreak {
+				streak.LongestStreak = streak.CurrentStreak
+			}
+			return
+		}
+	}
+
+	streak.CurrentStreak = 1
+	streak.StreakStartDate = &today
+	streak.LastActivityDate = &today
+	if streak.LongestStreak < 1 {
+		streak.LongestStreak = 1
+	}
+}
+
+// calendarDate returns the date of t as midnight UTC, the form DATE columns
+// are read and written in
+func calendarDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
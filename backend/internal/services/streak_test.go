@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestApplyActivity(t *testing.T) {
+	// 15:00 UTC on March 12, which is still March 12 in New York and already
+	// March 13 in Auckland
+	at := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		streak      models.UserEngagementStreak
+		wantCurrent int
+		wantLongest int
+		wantStart   *time.Time
+		wantLast    *time.Time
+		wantTotal   int
+	}{
+		{
+			name:        "first activity starts a streak",
+			streak:      models.UserEngagementStreak{Timezone: "UTC"},
+			wantCurrent: 1, wantLongest: 1, wantStart: date(2024, 3, 12), wantLast: date(2024, 3, 12), wantTotal: 1,
+		},
+		{
+			name: "activity the day after extends the streak",
+			streak: models.UserEngagementStreak{Timezone: "UTC", CurrentStreak: 4, LongestStreak: 9, TotalActivities: 6,
+				StreakStartDate: date(2024, 3, 8), LastActivityDate: date(2024, 3, 11)},
+			wantCurrent: 5, wantLongest: 9, wantStart: date(2024, 3, 8), wantLast: date(2024, 3, 12), wantTotal: 7,
+		},
+		{
+			name: "extending past the longest streak raises it",
+			streak: models.UserEngagementStreak{Timezone: "UTC", CurrentStreak: 9, LongestStreak: 9, TotalActivities: 12,
+				StreakStartDate: date(2024, 3, 3), LastActivityDate: date(2024, 3, 11)},
+			wantCurrent: 10, wantLongest: 10, wantStart: date(2024, 3, 3), wantLast: date(2024, 3, 12), wantTotal: 13,
+		},
+		{
+			name: "second activity the same day leaves the count alone",
+			streak: models.UserEngagementStreak{Timezone: "UTC", CurrentStreak: 4, LongestStreak: 9, TotalActivities: 6,
+				StreakStartDate: date(2024, 3, 9), LastActivityDate: date(2024, 3, 12)},
+			wantCurrent: 4, wantLongest: 9, wantStart: date(2024, 3, 9), wantLast: date(2024, 3, 12), wantTotal: 7,
+		},
+		{
+			name: "a missed day resets to 1",
+			streak: models.UserEngagementStreak{Timezone: "UTC", CurrentStreak: 4, LongestStreak: 9, TotalActivities: 6,
+				StreakStartDate: date(2024, 3, 7), LastActivityDate: date(2024, 3, 10)},
+			wantCurrent: 1, wantLongest: 9, wantStart: date(2024, 3, 12), wantLast: date(2024, 3, 12), wantTotal: 7,
+		},
+		{
+			name: "days follow the streak's timezone",
+			streak: models.UserEngagementStreak{Timezone: "Pacific/Auckland", CurrentStreak: 2, LongestStreak: 2, TotalActivities: 2,
+				StreakStartDate: date(2024, 3, 11), LastActivityDate: date(2024, 3, 12)},
+			wantCurrent: 3, wantLongest: 3, wantStart: date(2024, 3, 11), wantLast: date(2024, 3, 13), wantTotal: 3,
+		},
+		{
+			name: "same local day in a timezone behind UTC",
+			streak: models.UserEngagementStreak{Timezone: "America/New_York", CurrentStreak: 2, LongestStreak: 2, TotalActivities: 2,
+				StreakStartDate: date(2024, 3, 11), LastActivityDate: date(2024, 3, 12)},
+			wantCurrent: 2, wantLongest: 2, wantStart: date(2024, 3, 11), wantLast: date(2024, 3, 12), wantTotal: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak := tt.streak
+
+			ApplyActivity(&streak, at)
+
+			assert.Equal(t, tt.wantCurrent, streak.CurrentStreak)
+			assert.Equal(t, tt.wantLongest, streak.LongestStreak)
+			assert.Equal(t, tt.wantStart, streak.StreakStartDate)
+			assert.Equal(t, tt.wantLast, streak.LastActivityDate)
+			assert.Equal(t, tt.wantTotal, streak.TotalActivities)
+		})
+	}
+}
+
+func TestApplyActivity_SameDayIsIdempotent(t *testing.T) {
+	streak := models.UserEngagementStreak{Timezone: "UTC", CurrentStreak: 3, LongestStreak: 3,
+		StreakStartDate: date(2024, 3, 9), LastActivityDate: date(2024, 3, 11)}
+	morning := time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC)
+
+	ApplyActivity(&streak, morning)
+	ApplyActivity(&streak, morning.Add(4*time.Hour))
+	ApplyActivity(&streak, morning.Add(15*time.Hour))
+
+	assert.Equal(t, 4, streak.CurrentStreak)
+	assert.Equal(t, 4, streak.LongestStreak)
+	assert.Equal(t, date(2024, 3, 12), streak.LastActivityDate)
+	assert.Equal(t, 3, streak.TotalActivities)
+}
+
+func TestRecordActivity_ExtendsStoredStreak(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewStreakService(mockRepo)
+	ctx := context.Background()
+	userID := uuid.New()
+	at := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+
+	mockRepo.On("RecordStreakActivity", ctx, userID, PracticeStreakType, mock.Anything).Return(&models.UserEngagementStreak{
+		UserID: userID, StreakType: PracticeStreakType, Timezone: "UTC", CurrentStreak: 6, LongestStreak: 6,
+		StreakStartDate: date(2024, 3, 6), LastActivityDate: date(2024, 3, 11), TotalActivities: 8,
+	}, nil)
+
+	// Act
+	streak, err := service.RecordActivity(ctx, userID, PracticeStreakType, at)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 7, streak.CurrentStreak)
+	assert.Equal(t, 7, streak.LongestStreak)
+	assert.Equal(t, 9, streak.TotalActivities)
+	assert.Equal(t, date(2024, 3, 12), streak.LastActivityDate)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRecordActivity_CreatesStreakOnFirstSession(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewStreakService(mockRepo)
+	userID := uuid.New()
+
+	// A new row comes back in the column defaults
+	mockRepo.On("RecordStreakActivity", mock.Anything, userID, PracticeStreakType, mock.Anything).
+		Return(&models.UserEngagementStreak{UserID: userID, StreakType: PracticeStreakType, Timezone: "UTC"}, nil)
+
+	// Act
+	streak, err := service.RecordActivity(context.Background(), userID, PracticeStreakType, time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, userID, streak.UserID)
+	assert.Equal(t, 1, streak.CurrentStreak)
+	assert.Equal(t, 1, streak.LongestStreak)
+	assert.Equal(t, date(2024, 3, 12), streak.StreakStartDate)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRecordActivity_StoreFailure(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewStreakService(mockRepo)
+	userID := uuid.New()
+
+	mockRepo.On("RecordStreakActivity", mock.Anything, userID, PracticeStreakType, mock.Anything).
+		Return(nil, errors.New("connection refused"))
+
+	// Act
+	_, err := service.RecordActivity(context.Background(), userID, PracticeStreakType, time.Now())
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to record practice activity")
+}
+
+func TestRecordActivity_InvalidStreakType(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewStreakService(mockRepo)
+
+	// Act
+	_, err := service.RecordActivity(context.Background(), uuid.New(), "juggling", time.Now())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidStreakType)
+	assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "RecordStreakActivity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetStreak(t *testing.T) {
+	userID := uuid.New()
+	stored := &models.UserEngagementStreak{ID: 3, UserID: userID, StreakType: PracticeStreakType, CurrentStreak: 4, LongestStreak: 12}
+
+	tests := []struct {
+		name        string
+		repoStreak  *models.UserEngagementStreak
+		repoErr     error
+		wantCurrent int
+		wantLongest int
+		wantErr     string
+	}{
+		{name: "stored streak", repoStreak: stored, wantCurrent: 4, wantLongest: 12},
+		{name: "no activity yet", repoErr: repository.ErrStreakNotFound, wantCurrent: 0, wantLongest: 0},
+		{name: "lookup failure", repoErr: errors.New("connection refused"), wantErr: "failed to load practice streak"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewStreakService(mockRepo)
+			mockRepo.On("GetUserEngagementStreak", mock.Anything, userID, PracticeStreakType).Return(tt.repoStreak, tt.repoErr)
+
+			// Act
+			streak, err := service.GetStreak(context.Background(), userID, PracticeStreakType)
+
+			// Assert
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, userID, streak.UserID)
+			assert.Equal(t, PracticeStreakType, streak.StreakType)
+			assert.Equal(t, tt.wantCurrent, streak.CurrentStreak)
+			assert.Equal(t, tt.wantLongest, streak.LongestStreak)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTemplateNotFound is returned when no active template exists for a type and channel
+	ErrTemplateNotFound = apperr.New(apperr.ErrInvalidInput, "notification template not found")
+	// ErrTemplateRender is returned when a template does not render against the supplied data
+	ErrTemplateRender = apperr.New(apperr.ErrInvalidInput, "failed to render notification template")
+)
+
+// CreateFromTemplate creates a notification and its outbox entry from the newest
+// active template for the type and channel, rendering its title and body with
+// text/template against data. Every variable the template uses must be present
+// in data; nothing is stored when rendering fails.
+func (s *notificationService) CreateFromTemplate(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, data map[string]interface{}) (*models.Notification, error) {
+	tmpl, err := s.activeTemplate(ctx, notificationType, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	title, body, err := renderNotificationTemplate(tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := tmpl.Priority
+	if priority == "" {
+		priority = models.PriorityMedium
+	}
+	templateID := tmpl.ID
+	notification := &models.Notification{
+		ID:          models.NewNotificationID(),
+		UserID:      userID,
+		Type:        notificationType,
+		Channel:     channel,
+		Priority:    priority,
+		TemplateID:  &templateID,
+		Title:       title,
+		Message:     body,
+		Attachments: tmpl.DefaultAttachments,
+		Status:      models.StatusQueued,
+		CreatedAt:   time.Now(),
+	}
+
+	outboxItem, err := models.BuildOutboxEntry(notification, s.topicFor(notification.Priority))
+	if err != nil {
+		return nil, err
+	}
+
+	// Save notification and outbox entry atomically
+	if err := s.repository.CreateNotificationWithOutbox(ctx, notification, outboxItem); err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return notification, nil
+}
+
+// activeTemplate returns the newest active template for the type and channel
+func (s *notificationService) activeTemplate(ctx context.Context, notificationType models.NotificationType, channel models.NotificationChannel) (*models.NotificationTemplate, error) {
+	templates, err := s.repository.GetNotificationTemplates(ctx, notificationType, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification template: %w", err)
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("%w for %s on %s", ErrTemplateNotFound, notificationType, channel)
+	}
+	return &templates[0], nil
+}
+
+// renderNotificationTemplate renders a template's title and body against data,
+// failing on any variable data does not provide
+func renderNotificationTemplate(tmpl *models.NotificationTemplate, data map[string]interface{}) (*string, string, error) {
+	body, err := renderTemplateText("body", tmpl.Body, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w %d: %v", ErrTemplateRender, tmpl.ID, err)
+	}
+	if tmpl.Title == nil {
+		return nil, body, nil
+	}
+
+	title, err := renderTemplateText("title", *tmpl.Title, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w %d: %v", ErrTemplateRender, tmpl.ID, err)
+	}
+	return &title, body, nil
+}
+
+// renderTemplateText executes a text/template string with missing keys as errors
+func renderTemplateText(name, text string, data map[string]interface{}) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
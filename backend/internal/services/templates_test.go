@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// streakReminderTemplate mirrors the streak_reminder in_app template seeded by migration 016
+func streakReminderTemplate() models.NotificationTemplate {
+	return models.NotificationTemplate{
+		ID:       7,
+		Type:     models.StreakReminder,
+		Channel:  models.ChannelInApp,
+		Title:    strPtr("Don't Break Your Streak!"),
+		Body:     "{{.Name}}, you haven't practiced today! Your {{.Streak}}-day streak is at risk. Practice now to keep it going!",
+		Priority: models.PriorityHigh,
+		IsActive: true,
+		Version:  2,
+	}
+}
+
+func TestCreateFromTemplate_RendersNewestTemplate(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	userID := uuid.New()
+	ctx := context.Background()
+
+	older := streakReminderTemplate()
+	older.ID, older.Version, older.Body = 3, 1, "Old copy"
+	mockRepo.On("GetNotificationTemplates", ctx, models.StreakReminder, models.ChannelInApp).
+		Return([]models.NotificationTemplate{streakReminderTemplate(), older}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateFromTemplate(ctx, userID, models.StreakReminder, models.ChannelInApp,
+		map[string]interface{}{"Name": "Alex", "Streak": 5})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "Alex, you haven't practiced today! Your 5-day streak is at risk. Practice now to keep it going!", notification.Message)
+	assert.Equal(t, "Don't Break Your Streak!", *notification.Title)
+	require.NotNil(t, notification.TemplateID)
+	assert.Equal(t, int64(7), *notification.TemplateID)
+	assert.Equal(t, models.PriorityHigh, notification.Priority)
+	assert.Equal(t, userID, notification.UserID)
+	assert.Equal(t, models.StatusQueued, notification.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateFromTemplate_RendersTitleVariables(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	ctx := context.Background()
+
+	tmpl := models.NotificationTemplate{ID: 9, Title: strPtr("{{.Achievement}} unlocked"), Body: "Well done, {{.Name}}!"}
+	mockRepo.On("GetNotificationTemplates", ctx, models.AchievementUnlock, models.ChannelEmail).Return([]models.NotificationTemplate{tmpl}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.AnythingOfType("*models.Notification"), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	notification, err := service.CreateFromTemplate(ctx, uuid.New(), models.AchievementUnlock, models.ChannelEmail,
+		map[string]interface{}{"Name": "Alex", "Achievement": "Marathon"})
+
+	// Assert: a template without a priority is sent at medium
+	require.NoError(t, err)
+	assert.Equal(t, "Marathon unlocked", *notification.Title)
+	assert.Equal(t, "Well done, Alex!", notification.Message)
+	assert.Equal(t, models.PriorityMedium, notification.Priority)
+}
+
+func TestCreateFromTemplate_MissingVariable(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl models.NotificationTemplate
+		data map[string]interface{}
+	}{
+		{name: "missing in body", tmpl: streakReminderTemplate(), data: map[string]interface{}{"Name": "Alex"}},
+		{name: "missing in title", tmpl: models.NotificationTemplate{Title: strPtr("Hi {{.Name}}"), Body: "Practice now"}, data: map[string]interface{}{}},
+		{name: "no data", tmpl: streakReminderTemplate(), data: nil},
+		{name: "unparseable", tmpl: models.NotificationTemplate{Body: "Hi {{.Name"}, data: map[string]interface{}{"Name": "Alex"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockNotificationRepository)
+			service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+			mockRepo.On("GetNotificationTemplates", mock.Anything, models.StreakReminder, models.ChannelInApp).
+				Return([]models.NotificationTemplate{tt.tmpl}, nil)
+
+			// Act
+			notification, err := service.CreateFromTemplate(context.Background(), uuid.New(), models.StreakReminder, models.ChannelInApp, tt.data)
+
+			// Assert: nothing is stored
+			assert.Nil(t, notification)
+			assert.ErrorIs(t, err, ErrTemplateRender)
+			mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateFromTemplate_MissingTemplate(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	mockRepo.On("GetNotificationTemplates", mock.Anything, models.WeeklyRecap, models.ChannelSMS).Return([]models.NotificationTemplate{}, nil)
+
+	// Act
+	notification, err := service.CreateFromTemplate(context.Background(), uuid.New(), models.WeeklyRecap, models.ChannelSMS, nil)
+
+	// Assert
+	assert.Nil(t, notification)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateFromTemplate_TemplateLookupFailure(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	mockRepo.On("GetNotificationTemplates", mock.Anything, models.DailyReminder, models.ChannelInApp).
+		Return([]models.NotificationTemplate(nil), errors.New("connection refused"))
+
+	_, err := service.CreateFromTemplate(context.Background(), uuid.New(), models.DailyReminder, models.ChannelInApp, nil)
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestCreateDailyReminder_RendersTemplateWithoutStreak(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").Return(nil, errors.New("streak not found"))
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", ctx, user.ID, models.DailyReminder, "UTC").Return(false, nil)
+	mockRepo.On("GetNotificationTemplates", ctx, models.DailyReminder, models.ChannelInApp).Return([]models.NotificationTemplate{{
+		ID: 11, Title: strPtr("Time to Practice!"), Body: "Hey {{.Name}}! Keep your {{.Streak}}-day streak alive!", Priority: models.PriorityMedium,
+	}}, nil)
+	mockRepo.On("CreateNotificationWithOutbox", ctx, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.Message == "Hey Alex! Keep your 0-day streak alive!" && n.Type == models.DailyReminder
+	}), mock.AnythingOfType("*models.OutboxNotification")).Return(nil)
+
+	// Act
+	err := service.CreateDailyReminder(ctx, user.ID)
+
+	// Assert
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateDailyReminder_UnknownUser(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	userID := uuid.New()
+	mockRepo.On("GetUserByID", mock.Anything, userID).Return(nil, repository.ErrUserNotFound)
+
+	// Act
+	err := service.CreateDailyReminder(context.Background(), userID)
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	mockRepo.AssertNotCalled(t, "GetNotificationTemplates", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateDailyReminder_AlreadySentToday(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{CurrentStreak: 3, Timezone: "America/New_York"}, nil)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", ctx, user.ID, models.DailyReminder, "America/New_York").Return(true, nil)
+
+	// Act
+	err := service.CreateDailyReminder(ctx, user.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrAlreadySentToday)
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateDailyReminder_PreferenceDisabled(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").Return(nil, repository.ErrStreakNotFound)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{
+		{UserID: user.ID, Type: models.DailyReminder, Channel: models.ChannelPush, Enabled: true},
+		{UserID: user.ID, Type: models.DailyReminder, Channel: models.ChannelInApp, Enabled: false},
+	}, nil)
+
+	// Act
+	err := service.CreateDailyReminder(ctx, user.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReminderDisabled)
+	mockRepo.AssertNotCalled(t, "HasNotificationOfTypeToday", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateStreakReminder_AlreadySentToday(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{CurrentStreak: 4, Timezone: "Pacific/Auckland"}, nil)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", ctx, user.ID, models.StreakReminder, "Pacific/Auckland").Return(true, nil)
+
+	// Act
+	err := service.CreateStreakReminder(ctx, user.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrAlreadySentToday)
+	mockRepo.AssertNotCalled(t, "GetNotificationTemplates", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateStreakReminder_PreferenceDisabled(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+	ctx := context.Background()
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", ctx, user.ID, "practice").
+		Return(&models.UserEngagementStreak{CurrentStreak: 4}, nil)
+	mockRepo.On("GetUserPreferences", ctx, user.ID).Return([]models.UserNotificationPreferences{
+		{UserID: user.ID, Type: models.StreakReminder, Channel: models.ChannelInApp, Enabled: false},
+	}, nil)
+
+	// Act
+	err := service.CreateStreakReminder(ctx, user.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReminderDisabled)
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+	mockRepo.AssertNotCalled(t, "CreateNotificationWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateDailyReminder_MissingTemplate(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, new(MockKafkaProducer), "test-topic")
+	user := models.User{ID: uuid.New(), Name: "Alex"}
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(&user, nil)
+	mockRepo.On("GetUserEngagementStreak", mock.Anything, user.ID, "practice").Return(&models.UserEngagementStreak{CurrentStreak: 3}, nil)
+	mockRepo.On("GetUserPreferences", mock.Anything, user.ID).Return([]models.UserNotificationPreferences{}, nil)
+	mockRepo.On("HasNotificationOfTypeToday", mock.Anything, user.ID, models.DailyReminder, "UTC").Return(false, nil)
+	mockRepo.On("GetNotificationTemplates", mock.Anything, models.DailyReminder, models.ChannelInApp).Return([]models.NotificationTemplate{}, nil)
+
+	err := service.CreateDailyReminder(context.Background(), user.ID)
+
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
@@ -0,0 +1,55 @@
+package services
+
+import "time"
+
+// XPGoalPolicy decides when a user is reminded about their weekly XP goal
+type XPGoalPolicy struct {
+	// ReminderHour is the local hour from which Thursday and Friday reminders go out
+	ReminderHour int
+	// Threshold is the share of the target below which a user is reminded
+	Threshold float64
+}
+
+// IsReminderTime reports whether now is a Thursday or Friday evening in loc
+func (p XPGoalPolicy) IsReminderTime(now time.Time, loc *time.Location) bool {
+	local := now.In(loc)
+	weekday := local.Weekday()
+	return (weekday == time.Thursday || weekday == time.Friday) && local.Hour() >= p.ReminderHour
+}
+
+// IsBehind reports whether the user has earned less than Threshold of their target
+func (p XPGoalPolicy) IsBehind(progress XPGoalProgress) bool {
+	return progress.Target > 0 && progress.Fraction() < p.Threshold
+}
+
+// XPGoalProgress is a user's progress towards a weekly XP target
+type XPGoalProgress struct {
+	Target int
+	Earned int
+}
+
+// Remaining returns the XP still needed to reach the target
+func (p XPGoalProgress) Remaining() int {
+	if p.Earned >= p.Target {
+		return 0
+	}
+	return p.Target - p.Earned
+}
+
+// Fraction returns the share of the target earned so far, 0 without a target
+func (p XPGoalProgress) Fraction() float64 {
+	if p.Target <= 0 {
+		return 0
+	}
+	return float64(p.Earned) / float64(p.Target)
+}
+
+// WeekStart returns the local midnight of the Monday starting the week now
+// falls in, in loc
+func WeekStart(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	sinceMonday := (int(local.Weekday()) + 6) % 7
+	year, month, day := local.Date()
+	// time.Date normalizes the day and keeps midnight across daylight saving changes
+	return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, loc)
+}
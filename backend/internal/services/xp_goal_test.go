@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXPGoalProgress(t *testing.T) {
+	policy := XPGoalPolicy{ReminderHour: 18, Threshold: 0.7}
+
+	tests := []struct {
+		name      string
+		progress  XPGoalProgress
+		remaining int
+		fraction  float64
+		behind    bool
+	}{
+		{"nothing earned", XPGoalProgress{Target: 500, Earned: 0}, 500, 0, true},
+		{"just under the threshold", XPGoalProgress{Target: 500, Earned: 349}, 151, 0.698, true},
+		{"at the threshold", XPGoalProgress{Target: 500, Earned: 350}, 150, 0.7, false},
+		{"goal reached", XPGoalProgress{Target: 500, Earned: 620}, 0, 1.24, false},
+		{"no target", XPGoalProgress{Target: 0, Earned: 10}, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.remaining, tt.progress.Remaining())
+			assert.InDelta(t, tt.fraction, tt.progress.Fraction(), 0.0001)
+			assert.Equal(t, tt.behind, policy.IsBehind(tt.progress))
+		})
+	}
+}
+
+func TestXPGoalPolicy_IsReminderTime(t *testing.T) {
+	policy := XPGoalPolicy{ReminderHour: 18, Threshold: 0.7}
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		loc      *time.Location
+		expected bool
+	}{
+		{"thursday evening", time.Date(2024, 3, 14, 19, 0, 0, 0, time.UTC), time.UTC, true},
+		{"thursday afternoon", time.Date(2024, 3, 14, 17, 59, 0, 0, time.UTC), time.UTC, false},
+		{"friday evening", time.Date(2024, 3, 15, 22, 0, 0, 0, time.UTC), time.UTC, true},
+		{"wednesday evening", time.Date(2024, 3, 13, 20, 0, 0, 0, time.UTC), time.UTC, false},
+		// 01:00 UTC on Friday is still Thursday 21:00 in New York
+		{"thursday evening in new york", time.Date(2024, 3, 15, 1, 0, 0, 0, time.UTC), newYork, true},
+		// 01:00 UTC on Saturday is Friday 21:00 in New York
+		{"friday evening in new york", time.Date(2024, 3, 16, 1, 0, 0, 0, time.UTC), newYork, true},
+		// 01:00 UTC on Thursday is Wednesday 21:00 in New York
+		{"wednesday evening in new york", time.Date(2024, 3, 14, 1, 0, 0, 0, time.UTC), newYork, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.IsReminderTime(tt.now, tt.loc))
+		})
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// Thursday the 14th; Monday the 11th follows the daylight saving change on the 10th
+	start := WeekStart(time.Date(2024, 3, 14, 19, 0, 0, 0, time.UTC), newYork)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, newYork), start)
+
+	// 02:00 UTC on Monday is still Sunday in New York, the previous week
+	start = WeekStart(time.Date(2024, 3, 18, 2, 0, 0, 0, time.UTC), newYork)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, newYork), start)
+}
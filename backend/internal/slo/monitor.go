@@ -0,0 +1,137 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kafka-notify/internal/metrics"
+	"kafka-notify/pkg/models"
+)
+
+// SampleSource loads the notification timestamps the SLO is computed from
+type SampleSource interface {
+	GetDeliveryLatencySamples(ctx context.Context, priorities []models.PriorityLevel, since time.Time) ([]models.DeliveryLatencySample, error)
+}
+
+// Alert is raised when a window starts or stops breaching its burn-rate threshold
+type Alert struct {
+	Result   Result    `json:"result"`
+	Resolved bool      `json:"resolved"`
+	FiredAt  time.Time `json:"fired_at"`
+}
+
+// AlertHook receives SLO breach alerts
+type AlertHook interface {
+	Fire(ctx context.Context, alert Alert)
+}
+
+// LogAlertHook writes alerts to the service log
+type LogAlertHook struct{}
+
+// Fire logs the alert
+func (LogAlertHook) Fire(ctx context.Context, alert Alert) {
+	r := alert.Result
+	if alert.Resolved {
+		log.Printf("SLO RESOLVED: %s notifications over %s back within budget (compliance %.3f, burn rate %.2f)",
+			r.Priority, r.Window, r.Compliance, r.BurnRate)
+		return
+	}
+	log.Printf("ALERT: SLO burn for %s notifications over %s: compliance %.3f (%d/%d), burn rate %.2f",
+		r.Priority, r.Window, r.Compliance, r.Good, r.Total, r.BurnRate)
+}
+
+// Monitor periodically recomputes the SLO, exports it as metrics and raises
+// alerts when a window's breach state changes
+type Monitor struct {
+	calculator Calculator
+	source     SampleSource
+	hooks      []AlertHook
+	interval   time.Duration
+
+	mu       sync.RWMutex
+	latest   *Report
+	breached map[string]bool
+}
+
+// NewMonitor creates an SLO monitor
+func NewMonitor(calculator Calculator, source SampleSource, interval time.Duration, hooks ...AlertHook) *Monitor {
+	return &Monitor{
+		calculator: calculator,
+		source:     source,
+		hooks:      hooks,
+		interval:   interval,
+		breached:   make(map[string]bool),
+	}
+}
+
+// Run refreshes the SLO every interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Refresh(ctx); err != nil {
+			log.Printf("SLO refresh error: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Refresh recomputes the SLO report from the sample source
+func (m *Monitor) Refresh(ctx context.Context) (*Report, error) {
+	now := time.Now()
+	samples, err := m.source.GetDeliveryLatencySamples(ctx, m.calculator.Priorities(), now.Add(-m.calculator.LongestWindow()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SLO samples: %w", err)
+	}
+
+	report := m.calculator.Compute(now, samples)
+	m.record(ctx, &report)
+	return &report, nil
+}
+
+// Latest returns the most recently computed report, or nil before the first refresh
+func (m *Monitor) Latest() *Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latest
+}
+
+// record stores the report, updates metrics and fires alerts on breach transitions
+func (m *Monitor) record(ctx context.Context, report *Report) {
+	var alerts []Alert
+
+	m.mu.Lock()
+	m.latest = report
+	for _, r := range report.Results {
+		priority, window := string(r.Priority), r.Window
+		metrics.SLOCompliance.WithLabelValues(priority, window).Set(r.Compliance)
+		metrics.SLOBurnRate.WithLabelValues(priority, window).Set(r.BurnRate)
+		breached := 0.0
+		if r.Breached {
+			breached = 1
+		}
+		metrics.SLOBreached.WithLabelValues(priority, window).Set(breached)
+
+		key := priority + "/" + window
+		if r.Breached != m.breached[key] {
+			m.breached[key] = r.Breached
+			alerts = append(alerts, Alert{Result: r, Resolved: !r.Breached, FiredAt: report.GeneratedAt})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, alert := range alerts {
+		for _, hook := range m.hooks {
+			hook.Fire(ctx, alert)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+)
+
+// Target is the latency objective for one priority: Objective of notifications
+// must be delivered or read within Threshold of creation
+type Target struct {
+	Priority  models.PriorityLevel `json:"priority"`
+	Threshold time.Duration        `json:"threshold"`
+	Objective float64              `json:"objective"`
+}
+
+// Window is a rolling evaluation window and the burn rate that counts as a breach
+type Window struct {
+	Name        string        `json:"name"`
+	Duration    time.Duration `json:"duration"`
+	MaxBurnRate float64       `json:"max_burn_rate"`
+}
+
+// Result is the SLI for one priority over one window
+type Result struct {
+	Priority   models.PriorityLevel `json:"priority"`
+	Window     string               `json:"window"`
+	Total      int                  `json:"total"`
+	Good       int                  `json:"good"`
+	Compliance float64              `json:"compliance"`
+	BurnRate   float64              `json:"burn_rate"`
+	Breached   bool                 `json:"breached"`
+}
+
+// Report is the full set of results computed at a point in time
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Targets     []Target  `json:"targets"`
+	Results     []Result  `json:"results"`
+}
+
+// Calculator computes SLO compliance from notification latency samples
+type Calculator struct {
+	Targets []Target
+	Windows []Window
+}
+
+// NewCalculator builds a calculator for high and urgent notifications from config
+func NewCalculator(cfg config.SLOConfig) (Calculator, error) {
+	windows, err := ParseWindows(cfg.Windows)
+	if err != nil {
+		return Calculator{}, err
+	}
+
+	targets := []Target{
+		{Priority: models.PriorityHigh, Threshold: cfg.HighTarget, Objective: cfg.HighObjective},
+		{Priority: models.PriorityUrgent, Threshold: cfg.UrgentTarget, Objective: cfg.UrgentObjective},
+	}
+	for _, t := range targets {
+		if t.Objective <= 0 || t.Objective >= 1 {
+			return Calculator{}, fmt.Errorf("SLO objective for %s must be between 0 and 1, got %v", t.Priority, t.Objective)
+		}
+		if t.Threshold <= 0 {
+			return Calculator{}, fmt.Errorf("SLO target for %s must be positive, got %s", t.Priority, t.Threshold)
+		}
+	}
+
+	return Calculator{Targets: targets, Windows: windows}, nil
+}
+
+// ParseWindows parses "1h=14.4,24h=6" into windows with burn-rate thresholds
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, rate, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid SLO window %q: expected duration=burn_rate", part)
+		}
+		duration, err := time.ParseDuration(name)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid SLO window duration %q", name)
+		}
+		maxBurnRate, err := strconv.ParseFloat(rate, 64)
+		if err != nil || maxBurnRate <= 0 {
+			return nil, fmt.Errorf("invalid SLO burn rate %q for window %s", rate, name)
+		}
+
+		windows = append(windows, Window{Name: name, Duration: duration, MaxBurnRate: maxBurnRate})
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no SLO windows configured")
+	}
+	return windows, nil
+}
+
+// Priorities returns the priorities that have a target
+func (c Calculator) Priorities() []models.PriorityLevel {
+	priorities := make([]models.PriorityLevel, len(c.Targets))
+	for i, t := range c.Targets {
+		priorities[i] = t.Priority
+	}
+	return priorities
+}
+
+// LongestWindow returns the duration of the widest window
+func (c Calculator) LongestWindow() time.Duration {
+	var longest time.Duration
+	for _, w := range c.Windows {
+		if w.Duration > longest {
+			longest = w.Duration
+		}
+	}
+	return longest
+}
+
+// Compute evaluates every target over every window. A notification counts as
+// good once delivered or read within the threshold, and as bad once the
+// threshold has passed without either; notifications still inside the
+// threshold are not counted yet.
+func (c Calculator) Compute(now time.Time, samples []models.DeliveryLatencySample) Report {
+	report := Report{GeneratedAt: now, Targets: c.Targets}
+
+	for _, target := range c.Targets {
+		for _, window := range c.Windows {
+			result := Result{Priority: target.Priority, Window: window.Name, Compliance: 1}
+			since := now.Add(-window.Duration)
+
+			for _, sample := range samples {
+				if sample.Priority != target.Priority || sample.CreatedAt.Before(since) {
+					continue
+				}
+
+				reachedAt := sample.ReachedAt()
+				switch {
+				case reachedAt != nil:
+					result.Total++
+					if reachedAt.Sub(sample.CreatedAt) <= target.Threshold {
+						result.Good++
+					}
+				case now.Sub(sample.CreatedAt) > target.Threshold:
+					result.Total++
+				}
+			}
+
+			if result.Total > 0 {
+				result.Compliance = float64(result.Good) / float64(result.Total)
+				result.BurnRate = (1 - result.Compliance) / (1 - target.Objective)
+				result.Breached = result.BurnRate > window.MaxBurnRate
+			}
+
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	return report
+}
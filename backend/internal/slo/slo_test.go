@@ -0,0 +1,177 @@
+package slo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC)
+
+func testCalculator(t *testing.T) Calculator {
+	calc, err := NewCalculator(config.SLOConfig{
+		HighTarget:      10 * time.Minute,
+		HighObjective:   0.9,
+		UrgentTarget:    5 * time.Minute,
+		UrgentObjective: 0.99,
+		Windows:         "1h=14.4,24h=6,168h=1",
+	})
+	require.NoError(t, err)
+	return calc
+}
+
+// sample builds a notification created `age` ago that was delivered after `latency`;
+// a negative latency means it has not been delivered
+func sample(priority models.PriorityLevel, age, latency time.Duration) models.DeliveryLatencySample {
+	s := models.DeliveryLatencySample{Priority: priority, CreatedAt: now.Add(-age)}
+	if latency >= 0 {
+		delivered := s.CreatedAt.Add(latency)
+		s.DeliveredAt = &delivered
+	}
+	return s
+}
+
+func findResult(t *testing.T, report Report, priority models.PriorityLevel, window string) Result {
+	for _, r := range report.Results {
+		if r.Priority == priority && r.Window == window {
+			return r
+		}
+	}
+	t.Fatalf("no result for %s/%s", priority, window)
+	return Result{}
+}
+
+func TestCompute_WindowMath(t *testing.T) {
+	calc := testCalculator(t)
+
+	samples := []models.DeliveryLatencySample{
+		// Last hour: 3 good, 1 slow
+		sample(models.PriorityHigh, 30*time.Minute, 2*time.Minute),
+		sample(models.PriorityHigh, 40*time.Minute, 9*time.Minute),
+		sample(models.PriorityHigh, 50*time.Minute, 10*time.Minute),
+		sample(models.PriorityHigh, 45*time.Minute, 11*time.Minute),
+		// Last day only: 4 good
+		sample(models.PriorityHigh, 3*time.Hour, time.Minute),
+		sample(models.PriorityHigh, 5*time.Hour, time.Minute),
+		sample(models.PriorityHigh, 8*time.Hour, time.Minute),
+		sample(models.PriorityHigh, 20*time.Hour, time.Minute),
+		// Last week only: 2 undelivered past the threshold
+		sample(models.PriorityHigh, 3*24*time.Hour, -1),
+		sample(models.PriorityHigh, 6*24*time.Hour, -1),
+		// Outside every window
+		sample(models.PriorityHigh, 8*24*time.Hour, -1),
+		// Still within the threshold and undelivered: not counted yet
+		sample(models.PriorityHigh, 5*time.Minute, -1),
+		// Other priorities are ignored for the high target
+		sample(models.PriorityMedium, 30*time.Minute, time.Hour),
+	}
+
+	report := calc.Compute(now, samples)
+	assert.Len(t, report.Results, 6)
+
+	hour := findResult(t, report, models.PriorityHigh, "1h")
+	assert.Equal(t, 4, hour.Total)
+	assert.Equal(t, 3, hour.Good)
+	assert.InDelta(t, 0.75, hour.Compliance, 1e-9)
+	assert.InDelta(t, 2.5, hour.BurnRate, 1e-9)
+	assert.False(t, hour.Breached)
+
+	day := findResult(t, report, models.PriorityHigh, "24h")
+	assert.Equal(t, 8, day.Total)
+	assert.Equal(t, 7, day.Good)
+
+	week := findResult(t, report, models.PriorityHigh, "168h")
+	assert.Equal(t, 10, week.Total)
+	assert.Equal(t, 7, week.Good)
+	assert.InDelta(t, 3.0, week.BurnRate, 1e-9)
+	assert.True(t, week.Breached)
+
+	urgent := findResult(t, report, models.PriorityUrgent, "1h")
+	assert.Equal(t, 0, urgent.Total)
+	assert.Equal(t, 1.0, urgent.Compliance)
+	assert.False(t, urgent.Breached)
+}
+
+func TestCompute_ReadCountsWhenDeliveryMissing(t *testing.T) {
+	calc := testCalculator(t)
+
+	read := now.Add(-55 * time.Minute)
+	report := calc.Compute(now, []models.DeliveryLatencySample{
+		{Priority: models.PriorityUrgent, CreatedAt: now.Add(-time.Hour + time.Second), ReadAt: &read},
+	})
+
+	r := findResult(t, report, models.PriorityUrgent, "1h")
+	assert.Equal(t, 1, r.Total)
+	assert.Equal(t, 1, r.Good)
+}
+
+func TestParseWindows_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "1h", "1h=abc", "soon=1", "1h=-2"} {
+		_, err := ParseWindows(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+type staticSource struct {
+	samples []models.DeliveryLatencySample
+}
+
+func (s *staticSource) GetDeliveryLatencySamples(ctx context.Context, priorities []models.PriorityLevel, since time.Time) ([]models.DeliveryLatencySample, error) {
+	return s.samples, nil
+}
+
+type recordingHook struct {
+	alerts []Alert
+}
+
+func (h *recordingHook) Fire(ctx context.Context, alert Alert) {
+	h.alerts = append(h.alerts, alert)
+}
+
+func TestMonitor_FiresOnBreachTransitions(t *testing.T) {
+	calc := testCalculator(t)
+	calc.Windows = []Window{{Name: "1h", Duration: time.Hour, MaxBurnRate: 2}}
+
+	source := &staticSource{}
+	hook := &recordingHook{}
+	monitor := NewMonitor(calc, source, time.Minute, hook)
+	ctx := context.Background()
+
+	// Half of recent high-priority notifications are slow: burn rate 5
+	for i := 0; i < 4; i++ {
+		latency := time.Minute
+		if i%2 == 0 {
+			latency = time.Hour
+		}
+		source.samples = append(source.samples, models.DeliveryLatencySample{
+			Priority: models.PriorityHigh, CreatedAt: time.Now().Add(-30 * time.Minute),
+		})
+		delivered := source.samples[i].CreatedAt.Add(latency)
+		source.samples[i].DeliveredAt = &delivered
+	}
+
+	_, err := monitor.Refresh(ctx)
+	require.NoError(t, err)
+	require.Len(t, hook.alerts, 1)
+	assert.False(t, hook.alerts[0].Resolved)
+	assert.Equal(t, models.PriorityHigh, hook.alerts[0].Result.Priority)
+
+	// Still breaching: no duplicate alert
+	_, err = monitor.Refresh(ctx)
+	require.NoError(t, err)
+	assert.Len(t, hook.alerts, 1)
+
+	// Recovered: a resolve alert is raised
+	source.samples = nil
+	report, err := monitor.Refresh(ctx)
+	require.NoError(t, err)
+	require.Len(t, hook.alerts, 2)
+	assert.True(t, hook.alerts[1].Resolved)
+	assert.Equal(t, report, monitor.Latest())
+}
@@ -0,0 +1,13 @@
+-- Typical practice hour on engagement streaks
+-- Migration: 002_streak_typical_hour.sql
+
+-- Local hour (0-23) the user usually practices at, recomputed weekly by the scheduler
+ALTER TABLE user_engagement_streaks ADD COLUMN IF NOT EXISTS typical_hour SMALLINT;
+
+-- Share of recent practice sessions that fall around typical_hour (0.0 - 1.0)
+ALTER TABLE user_engagement_streaks ADD COLUMN IF NOT EXISTS typical_hour_confidence REAL DEFAULT 0;
+
+-- Practice sessions are recorded as achievement notifications tagged with the event name
+CREATE INDEX IF NOT EXISTS idx_notifications_practice_events
+    ON notifications(user_id, created_at DESC)
+    WHERE metadata->>'event' = 'practice_completed';
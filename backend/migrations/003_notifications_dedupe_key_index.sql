@@ -0,0 +1,6 @@
+-- Index for client reconciliation lookups by dedupe key
+-- Migration: 003_notifications_dedupe_key_index.sql
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_dedupe_key
+    ON notifications(user_id, dedupe_key)
+    WHERE dedupe_key IS NOT NULL;
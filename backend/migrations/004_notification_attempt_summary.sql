@@ -0,0 +1,18 @@
+-- Last delivery attempt summary on notifications
+-- Migration: 004_notification_attempt_summary.sql
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS last_attempt_no INTEGER;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS last_attempt_status delivery_status;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS last_attempt_at TIMESTAMP WITH TIME ZONE;
+
+-- Backfill from existing attempts
+UPDATE notifications n
+SET last_attempt_no = latest.attempt_no,
+    last_attempt_status = latest.status,
+    last_attempt_at = latest.created_at
+FROM (
+    SELECT DISTINCT ON (notification_id) notification_id, attempt_no, status, created_at
+    FROM notification_delivery_attempts
+    ORDER BY notification_id, attempt_no DESC, id DESC
+) latest
+WHERE latest.notification_id = n.id;
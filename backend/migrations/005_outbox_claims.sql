@@ -0,0 +1,9 @@
+-- Claim columns so multiple producer instances don't publish the same outbox row
+-- Migration: 005_outbox_claims.sql
+
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS claimed_by VARCHAR(255);
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_notifications_unpublished
+    ON outbox_notifications(created_at)
+    WHERE published = false;
@@ -0,0 +1,6 @@
+-- Track failed publish attempts so one bad outbox row can't block the rest
+-- Migration: 006_outbox_attempts.sql
+
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS dead BOOLEAN NOT NULL DEFAULT false;
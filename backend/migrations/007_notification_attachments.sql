@@ -0,0 +1,5 @@
+-- First-class image/icon attachments on notifications and template defaults
+-- Migration: 007_notification_attachments.sql
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS attachments JSONB;
+ALTER TABLE notification_templates ADD COLUMN IF NOT EXISTS default_attachments JSONB;
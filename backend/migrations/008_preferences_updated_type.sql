@@ -0,0 +1,20 @@
+-- Confirmation notification sent when a user changes their notification settings
+-- Migration: 008_preferences_updated_type.sql
+
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'preferences_updated';
+
+INSERT INTO notification_templates (type, channel, title, body, priority)
+SELECT 'preferences_updated', 'in_app', 'Notification settings updated',
+       'Your notification settings were updated: {{range $i, $c := .Changes}}{{if $i}}, {{end}}{{$c.Type}} via {{$c.Channel}} {{$c.Field}} {{$c.From}} -> {{$c.To}}{{end}}. If you did not make this change, please contact support.',
+       'low'
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_templates WHERE type = 'preferences_updated' AND channel = 'in_app'
+);
+
+INSERT INTO notification_templates (type, channel, title, body, priority)
+SELECT 'preferences_updated', 'email', 'Your notification settings were changed',
+       'Hi, this confirms that your notification settings were updated: {{range $i, $c := .Changes}}{{if $i}}, {{end}}{{$c.Type}} via {{$c.Channel}} {{$c.Field}} {{$c.From}} -> {{$c.To}}{{end}}. If you did not make this change, please contact support.',
+       'low'
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_templates WHERE type = 'preferences_updated' AND channel = 'email'
+);
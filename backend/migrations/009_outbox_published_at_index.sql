@@ -0,0 +1,4 @@
+-- Index published outbox rows by publish time so the retention purge can find old rows cheaply
+-- Migration: 009_outbox_published_at_index.sql
+
+CREATE INDEX IF NOT EXISTS idx_outbox_notifications_published_at ON outbox_notifications(published_at) WHERE published = true;
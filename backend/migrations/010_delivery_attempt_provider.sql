@@ -0,0 +1,4 @@
+-- Record which provider handled each delivery attempt (for per-channel provider failover)
+-- Migration: 010_delivery_attempt_provider.sql
+
+ALTER TABLE notification_delivery_attempts ADD COLUMN IF NOT EXISTS provider VARCHAR(100);
@@ -0,0 +1,8 @@
+-- System-wide settings such as the maintenance mode flag
+-- Migration: 011_system_settings.sql
+
+CREATE TABLE IF NOT EXISTS system_settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value JSONB NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- Back off failing outbox rows instead of retrying them on every tick
+-- Migration: 012_outbox_next_attempt_at.sql
+
+ALTER TABLE outbox_notifications ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_notifications_dead ON outbox_notifications(created_at) WHERE dead = true;
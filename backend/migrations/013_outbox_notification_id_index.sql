@@ -0,0 +1,5 @@
+-- Index outbox rows by notification so the scheduled dispatcher can skip
+-- notifications that were already released to the outbox
+-- Migration: 013_outbox_notification_id_index.sql
+
+CREATE INDEX IF NOT EXISTS idx_outbox_notifications_notification_id ON outbox_notifications(notification_id);
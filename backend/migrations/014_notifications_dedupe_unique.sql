@@ -0,0 +1,20 @@
+-- Enforce one live notification per user and dedupe key. Once a key's dedupe
+-- window expires the row is retired (dedupe_active = false) so the key can be reused.
+-- Migration: 014_notifications_dedupe_unique.sql
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS dedupe_active BOOLEAN NOT NULL DEFAULT true;
+
+-- Retire all but the newest row of keys duplicated before the index existed
+UPDATE notifications n
+SET dedupe_active = false
+WHERE n.dedupe_key IS NOT NULL
+  AND EXISTS (
+    SELECT 1 FROM notifications newer
+    WHERE newer.user_id = n.user_id
+      AND newer.dedupe_key = n.dedupe_key
+      AND (newer.created_at, newer.id) > (n.created_at, n.id)
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_user_dedupe_key_active
+    ON notifications(user_id, dedupe_key)
+    WHERE dedupe_key IS NOT NULL AND dedupe_active;
@@ -0,0 +1,14 @@
+-- Record when a scheduled notification was released to the outbox. The dispatcher
+-- claims due rows by setting it under FOR UPDATE SKIP LOCKED, so concurrent
+-- producers never release the same notification twice.
+-- Migration: 015_notifications_dispatched_at.sql
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS dispatched_at TIMESTAMPTZ;
+
+-- Scheduled notifications already released have an outbox entry
+UPDATE notifications n
+SET dispatched_at = o.created_at
+FROM outbox_notifications o
+WHERE o.notification_id = n.id
+  AND n.scheduled_for IS NOT NULL
+  AND n.dispatched_at IS NULL;
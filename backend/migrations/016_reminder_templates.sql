@@ -0,0 +1,23 @@
+-- Reminder templates rendered by CreateFromTemplate with .Name and .Streak.
+-- Each is added as a new version so it supersedes the static seed copy.
+-- Migration: 016_reminder_templates.sql
+
+INSERT INTO notification_templates (type, channel, title, body, priority, version)
+SELECT 'daily_reminder', 'in_app', 'Time to Practice!',
+       'Hey {{.Name}}! It''s time for your daily practice session. Keep your {{.Streak}}-day streak alive! 🔥',
+       'medium',
+       COALESCE((SELECT MAX(version) FROM notification_templates WHERE type = 'daily_reminder' AND channel = 'in_app'), 0) + 1
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_templates
+    WHERE type = 'daily_reminder' AND channel = 'in_app' AND body LIKE '%{{.Name}}%'
+);
+
+INSERT INTO notification_templates (type, channel, title, body, priority, version)
+SELECT 'streak_reminder', 'in_app', 'Don''t Break Your Streak!',
+       '{{.Name}}, you haven''t practiced today! Your {{.Streak}}-day streak is at risk. Practice now to keep it going!',
+       'high',
+       COALESCE((SELECT MAX(version) FROM notification_templates WHERE type = 'streak_reminder' AND channel = 'in_app'), 0) + 1
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_templates
+    WHERE type = 'streak_reminder' AND channel = 'in_app' AND body LIKE '%{{.Name}}%'
+);
@@ -0,0 +1,4 @@
+-- Notifications withdrawn before they were sent
+-- Migration: 017_cancelled_status.sql
+
+ALTER TYPE delivery_status ADD VALUE IF NOT EXISTS 'cancelled';
@@ -0,0 +1,5 @@
+-- Count a user's unread notifications for the bell badge without scanning
+-- their read history
+-- Migration: 018_notifications_unread_index.sql
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id, channel) WHERE read_at IS NULL;
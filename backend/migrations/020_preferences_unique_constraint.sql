@@ -0,0 +1,11 @@
+-- UpdateUserPreferences upserts ON CONFLICT (user_id, type, channel), which needs
+-- a unique index over exactly those columns or every preference save fails.
+-- 001 declares UNIQUE(user_id, type, channel) inline, and Postgres backs it with
+-- an index of this name, so on databases created from 001 this is a no-op. It's
+-- here for tables 001 didn't create: 001 uses a plain CREATE TABLE, which fails
+-- and leaves an existing user_notification_preferences table (restored from a
+-- dump or created by hand) as it was, without the index.
+-- setup_db.go splits migrations on semicolons, so this stays a single statement.
+-- Migration: 020_preferences_unique_constraint.sql
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_notification_preferences_user_id_type_channel_key ON user_notification_preferences(user_id, type, channel);
@@ -0,0 +1,16 @@
+-- Push device tokens per user. A token belongs to one user at a time and is
+-- deactivated when FCM reports it unregistered.
+-- Migration: 021_user_devices.sql
+
+CREATE TABLE IF NOT EXISTS user_devices (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    platform VARCHAR(20) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Push delivery reads a user's active tokens
+CREATE INDEX IF NOT EXISTS idx_user_devices_user_active ON user_devices(user_id) WHERE active;
@@ -0,0 +1,13 @@
+-- Webhook channel: notifications POSTed to a URL each user registers, signed
+-- with the user's shared secret.
+-- Migration: 022_user_webhooks.sql
+
+ALTER TYPE notification_channel ADD VALUE IF NOT EXISTS 'webhook';
+
+CREATE TABLE IF NOT EXISTS user_webhooks (
+    user_id UUID PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,16 @@
+-- Scheduler run history: one row per scheduler pass, written when it starts
+-- and completed when it finishes or fails
+-- Migration: 023_scheduler_runs.sql
+
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_name VARCHAR(50) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    users_processed INTEGER NOT NULL DEFAULT 0,
+    notifications_created INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+-- Run history is listed newest first, per job
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_job_started ON scheduler_runs(job_name, started_at DESC);
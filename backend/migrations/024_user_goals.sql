@@ -0,0 +1,18 @@
+-- Weekly goals users set for themselves, starting with a weekly XP target
+-- that the scheduler reminds them about late in the week
+-- Migration: 024_user_goals.sql
+
+CREATE TABLE IF NOT EXISTS user_goals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    goal_type VARCHAR(50) NOT NULL DEFAULT 'weekly_xp',
+    target INTEGER NOT NULL CHECK (target > 0),
+    -- Monday the goal's week starts on, in the user's timezone
+    week_start DATE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, goal_type, week_start)
+);
+
+-- The scheduler looks goals up by type and week
+CREATE INDEX IF NOT EXISTS idx_user_goals_type_week ON user_goals(goal_type, week_start);
@@ -0,0 +1,25 @@
+-- Announcements broadcast as new_course notifications to every user or a
+-- segment of them. The scheduler fans each one out a page of users at a time,
+-- keeping the last user it reached so a crashed fanout resumes where it stopped.
+-- Migration: 025_announcements.sql
+
+CREATE TABLE IF NOT EXISTS announcements (
+    id BIGSERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    metadata JSONB,
+    segment VARCHAR(20) NOT NULL DEFAULT 'all' CHECK (segment IN ('all', 'min_streak', 'inactive_days')),
+    -- Minimum streak or inactive days for the min_streak and inactive_days segments
+    segment_value INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'completed')),
+    -- Last user the fanout reached, in user ID order
+    last_user_id UUID,
+    notifications_created INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The fanout picks up the oldest announcement that is not completed
+CREATE INDEX IF NOT EXISTS idx_announcements_unfinished ON announcements(id) WHERE status <> 'completed';
@@ -0,0 +1,32 @@
+package apperr
+
+import "errors"
+
+var (
+	// ErrNotFound means the requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidInput means the request is well formed but cannot be processed
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrConflict means the request conflicts with the record's current state
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden means the caller may not act on the record
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnavailable means a dependency is not ready yet; the request can be retried
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// classified is an error with its own message that matches its class under errors.Is
+type classified struct {
+	class error
+	msg   string
+}
+
+func (e *classified) Error() string { return e.msg }
+
+func (e *classified) Unwrap() error { return e.class }
+
+// New returns a sentinel error with message msg that belongs to class, e.g.
+// apperr.New(apperr.ErrNotFound, "notification not found")
+func New(class error, msg string) error {
+	return &classified{class: class, msg: msg}
+}
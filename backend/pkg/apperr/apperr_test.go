@@ -0,0 +1,20 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_MatchesClassAndKeepsMessage(t *testing.T) {
+	errMissing := New(ErrNotFound, "notification not found")
+	wrapped := fmt.Errorf("failed to load: %w", errMissing)
+
+	assert.Equal(t, "notification not found", errMissing.Error())
+	assert.ErrorIs(t, wrapped, errMissing)
+	assert.ErrorIs(t, wrapped, ErrNotFound)
+	assert.NotErrorIs(t, wrapped, ErrConflict)
+	assert.False(t, errors.Is(New(ErrNotFound, "notification not found"), errMissing), "sentinels are distinct")
+}
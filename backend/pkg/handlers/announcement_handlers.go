@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnnouncementStore queues announcements and reports their fanout; the
+// announcement repository implements it
+type AnnouncementStore interface {
+	CreateAnnouncement(ctx context.Context, announcement *models.Announcement) error
+	GetAnnouncement(ctx context.Context, id int64) (*models.Announcement, error)
+}
+
+// AnnouncementHandlers handles HTTP requests for announcements
+type AnnouncementHandlers struct {
+	announcements AnnouncementStore
+}
+
+// NewAnnouncementHandlers creates new announcement handlers
+func NewAnnouncementHandlers(announcements AnnouncementStore) *AnnouncementHandlers {
+	return &AnnouncementHandlers{
+		announcements: announcements,
+	}
+}
+
+// CreateAnnouncement handles POST /announcements, queueing the announcement
+// for the scheduler to fan out
+func (h *AnnouncementHandlers) CreateAnnouncement(c *gin.Context) {
+	var req models.CreateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		respondError(c, "Invalid announcement", err)
+		return
+	}
+
+	announcement := req.Announcement()
+	if err := h.announcements.CreateAnnouncement(c.Request.Context(), announcement); err != nil {
+		respondError(c, "Failed to create announcement", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Announcement queued",
+		"data":    announcement,
+	})
+}
+
+// GetAnnouncement handles GET /announcements/:id, reporting the fanout's status
+func (h *AnnouncementHandlers) GetAnnouncement(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid announcement ID",
+		})
+		return
+	}
+
+	announcement, err := h.announcements.GetAnnouncement(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, "Failed to get announcement", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": announcement,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAnnouncementStore is a mock implementation of AnnouncementStore
+type MockAnnouncementStore struct {
+	mock.Mock
+}
+
+func (m *MockAnnouncementStore) CreateAnnouncement(ctx context.Context, announcement *models.Announcement) error {
+	args := m.Called(ctx, announcement)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementStore) GetAnnouncement(ctx context.Context, id int64) (*models.Announcement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Announcement), args.Error(1)
+}
+
+func setupAnnouncementRouter(h *AnnouncementHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/announcements", h.CreateAnnouncement)
+	router.GET("/api/v1/announcements/:id", h.GetAnnouncement)
+	return router
+}
+
+func TestCreateAnnouncement(t *testing.T) {
+	announcements := new(MockAnnouncementStore)
+	router := setupAnnouncementRouter(NewAnnouncementHandlers(announcements))
+
+	announcements.On("CreateAnnouncement", mock.Anything, mock.MatchedBy(func(a *models.Announcement) bool {
+		return a.Title == "New Course: Spanish" && a.Segment == models.SegmentMinStreak && *a.SegmentValue == 7 &&
+			a.Metadata["course_id"] == "es-101" && a.Status == models.AnnouncementPending
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Announcement).ID = 12
+	}).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/announcements", strings.NewReader(
+		`{"title": "New Course: Spanish", "message": "Spanish is here!", "metadata": {"course_id": "es-101"}, "segment": {"type": "min_streak", "value": 7}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var body struct {
+		Data models.Announcement `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, int64(12), body.Data.ID)
+	announcements.AssertExpectations(t)
+}
+
+func TestCreateAnnouncement_RejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "missing message", body: `{"title": "New Course"}`, wantStatus: http.StatusBadRequest},
+		{name: "segment without a value", body: `{"title": "New Course", "message": "Hi", "segment": {"type": "inactive_days"}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown segment", body: `{"title": "New Course", "message": "Hi", "segment": {"type": "premium"}}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcements := new(MockAnnouncementStore)
+			router := setupAnnouncementRouter(NewAnnouncementHandlers(announcements))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/announcements", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			announcements.AssertNotCalled(t, "CreateAnnouncement", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetAnnouncement(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           string
+		announcement *models.Announcement
+		err          error
+		wantStatus   int
+	}{
+		{name: "found", id: "12", announcement: &models.Announcement{ID: 12, Status: models.AnnouncementSending, NotificationsCreated: 2000}, wantStatus: http.StatusOK},
+		{name: "unknown", id: "12", err: apperr.New(apperr.ErrNotFound, "announcement not found"), wantStatus: http.StatusNotFound},
+		{name: "invalid ID", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcements := new(MockAnnouncementStore)
+			router := setupAnnouncementRouter(NewAnnouncementHandlers(announcements))
+			var found interface{}
+			if tt.announcement != nil {
+				found = tt.announcement
+			}
+			announcements.On("GetAnnouncement", mock.Anything, int64(12)).Return(found, tt.err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/announcements/"+tt.id, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"status":"sending"`)
+			}
+		})
+	}
+}
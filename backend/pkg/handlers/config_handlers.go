@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigDumper returns the effective configuration with secrets redacted;
+// config.Config implements it
+type ConfigDumper interface {
+	Dump() (map[string]interface{}, error)
+}
+
+// ConfigHandlers handles HTTP requests for the running configuration
+type ConfigHandlers struct {
+	config ConfigDumper
+}
+
+// NewConfigHandlers creates new config handlers
+func NewConfigHandlers(config ConfigDumper) *ConfigHandlers {
+	return &ConfigHandlers{
+		config: config,
+	}
+}
+
+// GetConfig handles GET /admin/config
+func (h *ConfigHandlers) GetConfig(c *gin.Context) {
+	dump, err := h.config.Dump()
+	if err != nil {
+		respondError(c, "Failed to dump configuration", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dump})
+}
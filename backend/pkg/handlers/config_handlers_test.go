@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConfig dumps a fixed configuration
+type fakeConfig struct {
+	dump map[string]interface{}
+	err  error
+}
+
+func (f fakeConfig) Dump() (map[string]interface{}, error) {
+	return f.dump, f.err
+}
+
+func getConfig(t *testing.T, config ConfigDumper) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/config", NewConfigHandlers(config).GetConfig)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil))
+	return w
+}
+
+func TestGetConfig_ReturnsDump(t *testing.T) {
+	w := getConfig(t, fakeConfig{dump: map[string]interface{}{
+		"database": map[string]interface{}{"host": "db.internal", "password": "[REDACTED]"},
+	}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data map[string]map[string]string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]string{"host": "db.internal", "password": "[REDACTED]"}, body.Data["database"])
+}
+
+func TestGetConfig_DumpFails(t *testing.T) {
+	w := getConfig(t, fakeConfig{err: errors.New("boom")})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DeviceRegistry registers and removes users' push device tokens
+type DeviceRegistry interface {
+	RegisterDevice(ctx context.Context, device *models.UserDevice) (*models.UserDevice, error)
+	UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error
+}
+
+// DeviceHandlers handles HTTP requests for push device tokens
+type DeviceHandlers struct {
+	devices DeviceRegistry
+}
+
+// NewDeviceHandlers creates new device handlers
+func NewDeviceHandlers(devices DeviceRegistry) *DeviceHandlers {
+	return &DeviceHandlers{
+		devices: devices,
+	}
+}
+
+// RegisterDevice handles POST /devices/:userID
+func (h *DeviceHandlers) RegisterDevice(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var req models.RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	device, err := h.devices.RegisterDevice(c.Request.Context(), &models.UserDevice{
+		UserID:   userID,
+		Token:    req.Token,
+		Platform: req.Platform,
+	})
+	if err != nil {
+		respondError(c, "Failed to register device", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Device registered",
+		"data":    device,
+	})
+}
+
+// UnregisterDevice handles DELETE /devices/:userID?token=...
+func (h *DeviceHandlers) UnregisterDevice(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "token query parameter is required",
+		})
+		return
+	}
+
+	if err := h.devices.UnregisterDevice(c.Request.Context(), userID, token); err != nil {
+		respondError(c, "Failed to unregister device", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Device unregistered",
+	})
+}
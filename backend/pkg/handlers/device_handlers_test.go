@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDeviceRegistry is a mock implementation of DeviceRegistry
+type MockDeviceRegistry struct {
+	mock.Mock
+}
+
+func (m *MockDeviceRegistry) RegisterDevice(ctx context.Context, device *models.UserDevice) (*models.UserDevice, error) {
+	args := m.Called(ctx, device)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserDevice), args.Error(1)
+}
+
+func (m *MockDeviceRegistry) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	args := m.Called(ctx, userID, token)
+	return args.Error(0)
+}
+
+func setupDeviceRouter(h *DeviceHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Auth is off here as in development; TestDeviceRoutes_RequireOwnToken covers it
+	api := router.Group("/api/v1", middleware.Auth(nil))
+	api.POST("/devices/:userID", h.RegisterDevice)
+	api.DELETE("/devices/:userID", h.UnregisterDevice)
+	return router
+}
+
+func TestRegisterDevice(t *testing.T) {
+	devices := new(MockDeviceRegistry)
+	router := setupDeviceRouter(NewDeviceHandlers(devices))
+	userID := uuid.New()
+
+	devices.On("RegisterDevice", mock.Anything, mock.MatchedBy(func(d *models.UserDevice) bool {
+		return d.UserID == userID && d.Token == "fcm-token" && d.Platform == models.PlatformIOS
+	})).Return(&models.UserDevice{ID: 1, UserID: userID, Token: "fcm-token", Platform: models.PlatformIOS, Active: true}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/devices/"+userID.String(),
+		strings.NewReader(`{"token": "fcm-token", "platform": "ios"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var body struct {
+		Data models.UserDevice `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.True(t, body.Data.Active)
+	devices.AssertExpectations(t)
+}
+
+func TestRegisterDevice_InvalidPlatform(t *testing.T) {
+	devices := new(MockDeviceRegistry)
+	router := setupDeviceRouter(NewDeviceHandlers(devices))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/devices/"+uuid.NewString(),
+		strings.NewReader(`{"token": "fcm-token", "platform": "blackberry"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	devices.AssertNotCalled(t, "RegisterDevice", mock.Anything, mock.Anything)
+}
+
+func TestUnregisterDevice(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		query      string
+		err        error
+		wantStatus int
+	}{
+		{name: "removed", query: "?token=fcm-token", wantStatus: http.StatusOK},
+		{name: "unknown token", query: "?token=fcm-token", err: apperr.New(apperr.ErrNotFound, "device not found"), wantStatus: http.StatusNotFound},
+		{name: "missing token", query: "", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := new(MockDeviceRegistry)
+			router := setupDeviceRouter(NewDeviceHandlers(devices))
+			devices.On("UnregisterDevice", mock.Anything, userID, "fcm-token").Return(tt.err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/devices/"+userID.String()+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestDeviceRoutes_RequireOwnToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	devices := new(MockDeviceRegistry)
+	h := NewDeviceHandlers(devices)
+	router := gin.New()
+	user := router.Group("", testAuth(t))
+	user.POST("/devices/:userID", h.RegisterDevice)
+	user.DELETE("/devices/:userID", h.UnregisterDevice)
+
+	owner := uuid.New()
+	devices.On("RegisterDevice", mock.Anything, mock.MatchedBy(func(d *models.UserDevice) bool { return d.UserID == owner })).
+		Return(&models.UserDevice{ID: 1, UserID: owner, Token: "fcm-token", Platform: models.PlatformIOS, Active: true}, nil)
+	devices.On("UnregisterDevice", mock.Anything, owner, "fcm-token").Return(nil)
+
+	assertRequiresOwnToken(t, router, owner, []ownTokenRoute{
+		{"register", http.MethodPost, "/devices/" + owner.String(), `{"token": "fcm-token", "platform": "ios"}`, http.StatusCreated},
+		{"unregister", http.MethodDelete, "/devices/" + owner.String() + "?token=fcm-token", "", http.StatusOK},
+	})
+}
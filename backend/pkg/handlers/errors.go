@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorStatus maps an apperr class to its HTTP status; unclassified errors are 500s.
+// An invalid priority is a malformed request field rather than an
+// unprocessable one, so it is a 400 although it is invalid input.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPriority):
+		return http.StatusBadRequest
+	case errors.Is(err, apperr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperr.ErrInvalidInput):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, apperr.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperr.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, apperr.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError writes the error envelope for a failed service call with the
+// status matching the error's class. Field validation failures also list the
+// problem with each field under "fields".
+func respondError(c *gin.Context, message string, err error) {
+	body := gin.H{
+		"error":   message,
+		"details": err.Error(),
+	}
+	var fieldErrs *models.FieldErrors
+	if errors.As(err, &fieldErrs) {
+		body["fields"] = fieldErrs.Fields
+	}
+	c.JSON(errorStatus(err), body)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"kafka-notify/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EventHandlers handles HTTP requests for product events
+type EventHandlers struct {
+	registry *events.Registry
+}
+
+// NewEventHandlers creates new event handlers
+func NewEventHandlers(registry *events.Registry) *EventHandlers {
+	return &EventHandlers{
+		registry: registry,
+	}
+}
+
+// IngestEvent handles POST /events
+func (h *EventHandlers) IngestEvent(c *gin.Context) {
+	var event events.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	notifications, err := h.registry.Dispatch(c.Request.Context(), event)
+	if err != nil {
+		respondError(c, "Failed to process event", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Event processed",
+		"data":    notifications,
+	})
+}
+
+// PracticeCompleted handles POST /events/practice-completed by forwarding it
+// to the practice-completed event mapping
+func (h *EventHandlers) PracticeCompleted(c *gin.Context) {
+	var req struct {
+		UserID uuid.UUID `json:"user_id" binding:"required"`
+		Points *int      `json:"points"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	payload, err := json.Marshal(gin.H{"points": req.Points})
+	if err != nil {
+		respondError(c, "Failed to create event notification", err)
+		return
+	}
+
+	notifications, err := h.registry.Dispatch(c.Request.Context(), events.Event{
+		Type:    events.PracticeCompleted,
+		UserID:  req.UserID,
+		Payload: payload,
+	})
+	if err != nil {
+		respondError(c, "Failed to create event notification", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Event notification created",
+		"data":    notifications[0],
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/events"
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStreakService is a mock implementation of StreakService
+type MockStreakService struct {
+	mock.Mock
+}
+
+func (m *MockStreakService) GetStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error) {
+	args := m.Called(ctx, userID, streakType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserEngagementStreak), args.Error(1)
+}
+
+func (m *MockStreakService) RecordActivity(ctx context.Context, userID uuid.UUID, streakType string, at time.Time) (*models.UserEngagementStreak, error) {
+	args := m.Called(ctx, userID, streakType, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserEngagementStreak), args.Error(1)
+}
+
+func setupEventRouter(mockService *MockNotificationService, mockStreaks *MockStreakService) *gin.Engine {
+	registry := events.NewRegistry(events.Services{Notifications: mockService, Streaks: mockStreaks})
+	registry.Register(events.PracticeCompleted, events.HandlePracticeCompleted)
+	registry.Register(events.LevelUp, events.HandleLevelUp)
+	h := NewEventHandlers(registry)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/events", h.IngestEvent)
+	router.POST("/api/v1/events/practice-completed", h.PracticeCompleted)
+	return router
+}
+
+func TestIngestEvent(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupEventRouter(mockService, new(MockStreakService))
+
+	userID := uuid.New()
+	mockService.On("CreateNotification", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+		return req.UserID == userID && req.Message == "You reached level 4. Keep going!"
+	})).Return(&models.Notification{ID: uuid.New(), UserID: userID}, nil)
+
+	body := `{"event_type":"level-up","user_id":"` + userID.String() + `","payload":{"level":4}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []models.Notification `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data, 1)
+	mockService.AssertExpectations(t)
+}
+
+func TestIngestEvent_Rejected(t *testing.T) {
+	userID := uuid.New().String()
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"unknown event type", `{"event_type":"course-completed","user_id":"` + userID + `"}`, http.StatusUnprocessableEntity},
+		{"invalid payload", `{"event_type":"level-up","user_id":"` + userID + `","payload":{"level":-1}}`, http.StatusUnprocessableEntity},
+		{"missing event type", `{"user_id":"` + userID + `"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupEventRouter(mockService, new(MockStreakService))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			mockService.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestIngestEvent_InvalidPriority(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupEventRouter(mockService, new(MockStreakService))
+	userID := uuid.New()
+	mockService.On("CreateNotification", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: banana", services.ErrInvalidPriority))
+
+	body := `{"event_type":"level-up","user_id":"` + userID.String() + `","payload":{"level":4}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid notification priority")
+}
+
+func TestPracticeCompleted_ForwardsToRegistry(t *testing.T) {
+	mockService := new(MockNotificationService)
+	mockStreaks := new(MockStreakService)
+	router := setupEventRouter(mockService, mockStreaks)
+
+	userID := uuid.New()
+	mockStreaks.On("RecordActivity", mock.Anything, userID, services.PracticeStreakType, mock.AnythingOfType("time.Time")).
+		Return(&models.UserEngagementStreak{UserID: userID, CurrentStreak: 5}, nil)
+	var created *models.CreateNotificationRequest
+	mockService.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*models.CreateNotificationRequest) }).
+		Return(&models.Notification{ID: uuid.New(), UserID: userID}, nil)
+
+	body := `{"user_id":"` + userID.String() + `","points":20}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events/practice-completed", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	require.NotNil(t, created)
+	assert.Equal(t, "Great job on completing your practice session. You're on a 5 day streak, keep it up! You earned 20 XP.", created.Message)
+	mockStreaks.AssertExpectations(t)
+	mockService.AssertExpectations(t)
+}
+
+func TestPracticeCompleted_StreakFailure(t *testing.T) {
+	mockService := new(MockNotificationService)
+	mockStreaks := new(MockStreakService)
+	router := setupEventRouter(mockService, mockStreaks)
+
+	userID := uuid.New()
+	mockStreaks.On("RecordActivity", mock.Anything, userID, services.PracticeStreakType, mock.AnythingOfType("time.Time")).
+		Return(nil, errors.New("connection refused"))
+
+	body := `{"user_id":"` + userID.String() + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events/practice-completed", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockService.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GoalStore stores the weekly goals users set; the goal repository implements it
+type GoalStore interface {
+	UpsertGoal(ctx context.Context, goal *models.UserGoal) (*models.UserGoal, error)
+	GetGoals(ctx context.Context, userID uuid.UUID) ([]models.UserGoal, error)
+	UpdateGoalTarget(ctx context.Context, userID uuid.UUID, goalID int64, target int) (*models.UserGoal, error)
+	DeleteGoal(ctx context.Context, userID uuid.UUID, goalID int64) error
+}
+
+// GoalHandlers handles HTTP requests for user goals
+type GoalHandlers struct {
+	goals GoalStore
+}
+
+// NewGoalHandlers creates new goal handlers
+func NewGoalHandlers(goals GoalStore) *GoalHandlers {
+	return &GoalHandlers{
+		goals: goals,
+	}
+}
+
+// SetGoal handles POST /goals/:userID, setting the goal for a week or
+// replacing the target of the one already set
+func (h *GoalHandlers) SetGoal(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var req models.SetGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	weekStart, err := time.Parse("2006-01-02", req.WeekStart)
+	if err != nil || weekStart.Weekday() != time.Monday {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "week_start must be a Monday in YYYY-MM-DD format",
+		})
+		return
+	}
+
+	goalType := req.GoalType
+	if goalType == "" {
+		goalType = models.GoalWeeklyXP
+	}
+
+	goal, err := h.goals.UpsertGoal(c.Request.Context(), &models.UserGoal{
+		UserID:    userID,
+		GoalType:  goalType,
+		Target:    req.Target,
+		WeekStart: weekStart,
+	})
+	if err != nil {
+		respondError(c, "Failed to set goal", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Goal set",
+		"data":    goal,
+	})
+}
+
+// GetGoals handles GET /goals/:userID
+func (h *GoalHandlers) GetGoals(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	goals, err := h.goals.GetGoals(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, "Failed to get goals", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": goals,
+	})
+}
+
+// UpdateGoal handles PUT /goals/:userID/:goalID
+func (h *GoalHandlers) UpdateGoal(c *gin.Context) {
+	userID, goalID, ok := goalParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	goal, err := h.goals.UpdateGoalTarget(c.Request.Context(), userID, goalID, req.Target)
+	if err != nil {
+		respondError(c, "Failed to update goal", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Goal updated",
+		"data":    goal,
+	})
+}
+
+// DeleteGoal handles DELETE /goals/:userID/:goalID
+func (h *GoalHandlers) DeleteGoal(c *gin.Context) {
+	userID, goalID, ok := goalParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.goals.DeleteGoal(c.Request.Context(), userID, goalID); err != nil {
+		respondError(c, "Failed to delete goal", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Goal deleted",
+	})
+}
+
+// goalParams parses the user and goal IDs from the path, responding with a
+// 400 when either is malformed and a 401 or 403 when the caller can't act for
+// the user
+func goalParams(c *gin.Context) (uuid.UUID, int64, bool) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return uuid.Nil, 0, false
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return uuid.Nil, 0, false
+	}
+
+	goalID, err := strconv.ParseInt(c.Param("goalID"), 10, 64)
+	if err != nil || goalID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid goal ID",
+		})
+		return uuid.Nil, 0, false
+	}
+	return userID, goalID, true
+}
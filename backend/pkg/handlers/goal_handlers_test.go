@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockGoalStore is a mock implementation of GoalStore
+type MockGoalStore struct {
+	mock.Mock
+}
+
+func (m *MockGoalStore) UpsertGoal(ctx context.Context, goal *models.UserGoal) (*models.UserGoal, error) {
+	args := m.Called(ctx, goal)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserGoal), args.Error(1)
+}
+
+func (m *MockGoalStore) GetGoals(ctx context.Context, userID uuid.UUID) ([]models.UserGoal, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.UserGoal), args.Error(1)
+}
+
+func (m *MockGoalStore) UpdateGoalTarget(ctx context.Context, userID uuid.UUID, goalID int64, target int) (*models.UserGoal, error) {
+	args := m.Called(ctx, userID, goalID, target)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserGoal), args.Error(1)
+}
+
+func (m *MockGoalStore) DeleteGoal(ctx context.Context, userID uuid.UUID, goalID int64) error {
+	args := m.Called(ctx, userID, goalID)
+	return args.Error(0)
+}
+
+func setupGoalRouter(h *GoalHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Auth is off here as in development; TestGoalRoutes_RequireOwnToken covers it
+	api := router.Group("/api/v1", middleware.Auth(nil))
+	api.POST("/goals/:userID", h.SetGoal)
+	api.GET("/goals/:userID", h.GetGoals)
+	api.PUT("/goals/:userID/:goalID", h.UpdateGoal)
+	api.DELETE("/goals/:userID/:goalID", h.DeleteGoal)
+	return router
+}
+
+func TestSetGoal(t *testing.T) {
+	goals := new(MockGoalStore)
+	router := setupGoalRouter(NewGoalHandlers(goals))
+	userID := uuid.New()
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	goals.On("UpsertGoal", mock.Anything, mock.MatchedBy(func(g *models.UserGoal) bool {
+		return g.UserID == userID && g.GoalType == models.GoalWeeklyXP && g.Target == 500 && g.WeekStart.Equal(monday)
+	})).Return(&models.UserGoal{ID: 1, UserID: userID, GoalType: models.GoalWeeklyXP, Target: 500, WeekStart: monday}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/goals/"+userID.String(),
+		strings.NewReader(`{"target": 500, "week_start": "2024-03-04"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var body struct {
+		Data models.UserGoal `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 500, body.Data.Target)
+	goals.AssertExpectations(t)
+}
+
+func TestSetGoal_RejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "week does not start on a Monday", body: `{"target": 500, "week_start": "2024-03-05"}`},
+		{name: "malformed week start", body: `{"target": 500, "week_start": "March 4"}`},
+		{name: "missing target", body: `{"week_start": "2024-03-04"}`},
+		{name: "unknown goal type", body: `{"goal_type": "daily_minutes", "target": 500, "week_start": "2024-03-04"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goals := new(MockGoalStore)
+			router := setupGoalRouter(NewGoalHandlers(goals))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/goals/"+uuid.NewString(), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			goals.AssertNotCalled(t, "UpsertGoal", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetGoals(t *testing.T) {
+	goals := new(MockGoalStore)
+	router := setupGoalRouter(NewGoalHandlers(goals))
+	userID := uuid.New()
+	goals.On("GetGoals", mock.Anything, userID).Return([]models.UserGoal{{ID: 1, UserID: userID, Target: 500}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/goals/"+userID.String(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []models.UserGoal `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 1)
+}
+
+func TestUpdateGoal(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		goalID     string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{name: "updated", goalID: "7", body: `{"target": 800}`, wantStatus: http.StatusOK},
+		{name: "unknown goal", goalID: "7", body: `{"target": 800}`, err: apperr.New(apperr.ErrNotFound, "goal not found"), wantStatus: http.StatusNotFound},
+		{name: "invalid goal ID", goalID: "abc", body: `{"target": 800}`, wantStatus: http.StatusBadRequest},
+		{name: "zero target", goalID: "7", body: `{"target": 0}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goals := new(MockGoalStore)
+			router := setupGoalRouter(NewGoalHandlers(goals))
+			var updated interface{}
+			if tt.err == nil {
+				updated = &models.UserGoal{ID: 7, UserID: userID, Target: 800}
+			}
+			goals.On("UpdateGoalTarget", mock.Anything, userID, int64(7), 800).Return(updated, tt.err)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/goals/"+userID.String()+"/"+tt.goalID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestDeleteGoal(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "deleted", wantStatus: http.StatusOK},
+		{name: "unknown goal", err: apperr.New(apperr.ErrNotFound, "goal not found"), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goals := new(MockGoalStore)
+			router := setupGoalRouter(NewGoalHandlers(goals))
+			goals.On("DeleteGoal", mock.Anything, userID, int64(3)).Return(tt.err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/goals/"+userID.String()+"/3", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestGoalRoutes_RequireOwnToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	goals := new(MockGoalStore)
+	h := NewGoalHandlers(goals)
+	router := gin.New()
+	user := router.Group("", testAuth(t))
+	user.POST("/goals/:userID", h.SetGoal)
+	user.GET("/goals/:userID", h.GetGoals)
+	user.PUT("/goals/:userID/:goalID", h.UpdateGoal)
+	user.DELETE("/goals/:userID/:goalID", h.DeleteGoal)
+
+	owner := uuid.New()
+	goal := &models.UserGoal{ID: 1, UserID: owner, GoalType: models.GoalWeeklyXP, Target: 500}
+	goals.On("UpsertGoal", mock.Anything, mock.MatchedBy(func(g *models.UserGoal) bool { return g.UserID == owner })).Return(goal, nil)
+	goals.On("GetGoals", mock.Anything, owner).Return([]models.UserGoal{*goal}, nil)
+	goals.On("UpdateGoalTarget", mock.Anything, owner, int64(1), 800).Return(goal, nil)
+	goals.On("DeleteGoal", mock.Anything, owner, int64(1)).Return(nil)
+
+	assertRequiresOwnToken(t, router, owner, []ownTokenRoute{
+		{"set goal", http.MethodPost, "/goals/" + owner.String(), `{"target": 500, "week_start": "2024-03-04"}`, http.StatusCreated},
+		{"get goals", http.MethodGet, "/goals/" + owner.String(), "", http.StatusOK},
+		{"update goal", http.MethodPut, "/goals/" + owner.String() + "/1", `{"target": 800}`, http.StatusOK},
+		{"delete goal", http.MethodDelete, "/goals/" + owner.String() + "/1", "", http.StatusOK},
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceFlag reads and toggles the system-wide maintenance mode
+type MaintenanceFlag interface {
+	State(ctx context.Context) models.MaintenanceMode
+	Set(ctx context.Context, enabled bool, reason, setBy string) (models.MaintenanceMode, error)
+}
+
+// MaintenanceHandlers handles HTTP requests for maintenance mode
+type MaintenanceHandlers struct {
+	flag MaintenanceFlag
+}
+
+// NewMaintenanceHandlers creates new maintenance handlers
+func NewMaintenanceHandlers(flag MaintenanceFlag) *MaintenanceHandlers {
+	return &MaintenanceHandlers{
+		flag: flag,
+	}
+}
+
+// GetMaintenance handles GET /admin/maintenance
+func (h *MaintenanceHandlers) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.flag.State(c.Request.Context())})
+}
+
+// SetMaintenance handles POST /admin/maintenance
+func (h *MaintenanceHandlers) SetMaintenance(c *gin.Context) {
+	var req models.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// The admin token is shared, so the operator identifies themselves by header
+	setBy := c.GetHeader("X-Admin-User")
+	if setBy == "" {
+		setBy = c.ClientIP()
+	}
+
+	mode, err := h.flag.Set(c.Request.Context(), *req.Enabled, req.Reason, setBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update maintenance mode",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	message := "Maintenance mode disabled"
+	if mode.Enabled {
+		message = "Maintenance mode enabled"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    mode,
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMaintenanceFlag is a mock implementation of MaintenanceFlag
+type MockMaintenanceFlag struct {
+	mock.Mock
+}
+
+func (m *MockMaintenanceFlag) State(ctx context.Context) models.MaintenanceMode {
+	args := m.Called(ctx)
+	return args.Get(0).(models.MaintenanceMode)
+}
+
+func (m *MockMaintenanceFlag) Set(ctx context.Context, enabled bool, reason, setBy string) (models.MaintenanceMode, error) {
+	args := m.Called(ctx, enabled, reason, setBy)
+	return args.Get(0).(models.MaintenanceMode), args.Error(1)
+}
+
+func setupMaintenanceRouter(h *MaintenanceHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/maintenance", h.GetMaintenance)
+	router.POST("/api/v1/admin/maintenance", h.SetMaintenance)
+	return router
+}
+
+func TestSetMaintenance_EnablesWithOperator(t *testing.T) {
+	flag := new(MockMaintenanceFlag)
+	router := setupMaintenanceRouter(NewMaintenanceHandlers(flag))
+
+	flag.On("Set", mock.Anything, true, "kafka incident", "oncall").
+		Return(models.MaintenanceMode{Enabled: true, Reason: "kafka incident", SetBy: "oncall"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": true, "reason": "kafka incident"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-User", "oncall")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Message string                 `json:"message"`
+		Data    models.MaintenanceMode `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Maintenance mode enabled", body.Message)
+	assert.Equal(t, "oncall", body.Data.SetBy)
+	flag.AssertExpectations(t)
+}
+
+func TestSetMaintenance_RequiresEnabled(t *testing.T) {
+	flag := new(MockMaintenanceFlag)
+	router := setupMaintenanceRouter(NewMaintenanceHandlers(flag))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance", strings.NewReader(`{"reason": "forgot the flag"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	flag.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetMaintenance_StoreFailure(t *testing.T) {
+	flag := new(MockMaintenanceFlag)
+	router := setupMaintenanceRouter(NewMaintenanceHandlers(flag))
+
+	flag.On("Set", mock.Anything, false, "", mock.Anything).Return(models.MaintenanceMode{}, errors.New("connection refused"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": false}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetMaintenance(t *testing.T) {
+	flag := new(MockMaintenanceFlag)
+	router := setupMaintenanceRouter(NewMaintenanceHandlers(flag))
+
+	flag.On("State", mock.Anything).Return(models.MaintenanceMode{Enabled: true, Reason: "migration"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/maintenance", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":{"enabled":true,"reason":"migration"}}`, w.Body.String())
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"kafka-notify/internal/middleware"
 	"kafka-notify/internal/services"
 	"kafka-notify/pkg/models"
 
@@ -23,6 +27,10 @@ func NewNotificationHandlers(notificationService services.NotificationService) *
 	}
 }
 
+// MaxIdempotencyKeyLength bounds the Idempotency-Key header, leaving room in the
+// stored key for the channel suffix fan-out adds
+const MaxIdempotencyKeyLength = 200
+
 // CreateNotification handles POST /notifications
 func (h *NotificationHandlers) CreateNotification(c *gin.Context) {
 	var req models.CreateNotificationRequest
@@ -34,14 +42,46 @@ func (h *NotificationHandlers) CreateNotification(c *gin.Context) {
 		return
 	}
 
+	if req.IsBackfill() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "id and created_at can only be set through the admin backfill endpoint",
+		})
+		return
+	}
+
+	req.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	if len(req.IdempotencyKey) > MaxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Idempotency-Key header",
+			"details": fmt.Sprintf("key is longer than %d characters", MaxIdempotencyKeyLength),
+		})
+		return
+	}
+
+	if req.Channel == models.ChannelAuto {
+		h.createFanout(c, &req)
+		return
+	}
+
 	notification, err := h.notificationService.CreateNotification(c.Request.Context(), &req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create notification",
-			"details": err.Error(),
+	var duplicate *services.DuplicateNotificationError
+	if errors.As(err, &duplicate) {
+		// A retried request gets the notification it already created
+		message := "Notification already exists for this dedupe key"
+		if duplicate.ByIdempotencyKey {
+			message = "Notification already exists for this idempotency key"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+			"data":    duplicate.Existing,
 		})
 		return
 	}
+	if err != nil {
+		respondError(c, "Failed to create notification", err)
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Notification created successfully",
@@ -49,13 +89,32 @@ func (h *NotificationHandlers) CreateNotification(c *gin.Context) {
 	})
 }
 
-// PracticeCompleted handles POST /events/practice-completed
-// Simplified event-to-notification mapping for POC
-func (h *NotificationHandlers) PracticeCompleted(c *gin.Context) {
-	var req struct {
-		UserID uuid.UUID `json:"user_id" binding:"required"`
-		Points *int      `json:"points"`
+// createFanout creates a notification on every channel the user enabled for
+// the type and returns them as a list
+func (h *NotificationHandlers) createFanout(c *gin.Context, req *models.CreateNotificationRequest) {
+	notifications, err := h.notificationService.CreateFanout(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create notifications", err)
+		return
+	}
+
+	if len(notifications) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No channels enabled for this notification type",
+			"data":    []*models.Notification{},
+		})
+		return
 	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Notifications created successfully",
+		"data":    notifications,
+	})
+}
+
+// CreateFromTemplate handles POST /notifications/from-template
+func (h *NotificationHandlers) CreateFromTemplate(c *gin.Context) {
+	var req models.CreateFromTemplateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request body",
@@ -64,39 +123,49 @@ func (h *NotificationHandlers) PracticeCompleted(c *gin.Context) {
 		return
 	}
 
-	title := ptr("Practice Completed!")
-	message := "Great job on completing your practice session. Keep it up!"
-	if req.Points != nil {
-		message = message + " You earned " + strconv.Itoa(*req.Points) + " XP."
+	if !models.IsValidNotificationType(req.Type) || !models.IsValidChannel(req.Channel) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "invalid notification type or channel",
+		})
+		return
 	}
 
-	newReq := &models.CreateNotificationRequest{
-		UserID:   req.UserID,
-		Type:     models.AchievementUnlock,
-		Channel:  models.ChannelInApp,
-		Priority: models.PriorityMedium,
-		Title:    title,
-		Message:  message,
-		Metadata: models.JSONMap{"event": "practice_completed"},
+	notification, err := h.notificationService.CreateFromTemplate(c.Request.Context(), req.UserID, req.Type, req.Channel, req.Data)
+	if err != nil {
+		respondError(c, "Failed to create notification from template", err)
+		return
 	}
 
-	n, err := h.notificationService.CreateNotification(c.Request.Context(), newReq)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create event notification",
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Notification created successfully",
+		"data":    notification,
+	})
+}
+
+// BackfillNotification handles POST /admin/notifications/backfill
+func (h *NotificationHandlers) BackfillNotification(c *gin.Context) {
+	var req models.CreateNotificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	notification, err := h.notificationService.BackfillNotification(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, "Failed to backfill notification", err)
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Event notification created",
-		"data":    n,
+		"message": "Notification backfilled successfully",
+		"data":    notification,
 	})
 }
 
-func ptr(s string) *string { return &s }
-
 // GetUserNotifications handles GET /notifications/:userID
 func (h *NotificationHandlers) GetUserNotifications(c *gin.Context) {
 	userIDStr := c.Param("userID")
@@ -107,47 +176,195 @@ func (h *NotificationHandlers) GetUserNotifications(c *gin.Context) {
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
 
-	// Get query parameters
-	limitStr := c.DefaultQuery("limit", "50")
-	offsetStr := c.DefaultQuery("offset", "0")
+	filter, err := parseNotificationFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameter",
+			"details": err.Error(),
+		})
+		return
+	}
 
-	limit, err := strconv.Atoi(limitStr)
+	includeTotal, err := strconv.ParseBool(c.DefaultQuery("include_total", "true"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid limit parameter",
+			"error":   "Invalid query parameter",
+			"details": fmt.Sprintf("invalid include_total: %v", err),
 		})
 		return
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
+	notifications, err := h.notificationService.GetUserNotifications(c.Request.Context(), userID, filter)
+	if err != nil {
+		respondError(c, "Failed to retrieve notifications", err)
+		return
+	}
+
+	meta := gin.H{
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+		"count":  len(notifications),
+		"filter": filter,
+	}
+
+	// The total costs a second query, so callers paging with infinite scroll
+	// can skip it; has_more then assumes a full page means there is another
+	if includeTotal {
+		total, err := h.notificationService.CountUserNotifications(c.Request.Context(), userID, filter)
+		if err != nil {
+			respondError(c, "Failed to count notifications", err)
+			return
+		}
+		meta["total"] = total
+		meta["has_more"] = hasMore(filter.Offset, len(notifications), total)
+	} else {
+		meta["has_more"] = len(notifications) == filter.Limit
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notifications,
+		"meta": meta,
+	})
+}
+
+// hasMore reports whether rows remain after a page of count rows starting at offset
+func hasMore(offset, count int, total int64) bool {
+	return int64(offset+count) < total
+}
+
+// parseNotificationFilter reads limit, offset, type, status, channel (each
+// repeatable), unread, since, until (RFC 3339) and sort from the query. Enum
+// values are checked by the service.
+func parseNotificationFilter(c *gin.Context) (models.NotificationFilter, error) {
+	var filter models.NotificationFilter
+	var err error
+
+	if filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "50")); err != nil {
+		return filter, fmt.Errorf("invalid limit: %w", err)
+	}
+	if filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil {
+		return filter, fmt.Errorf("invalid offset: %w", err)
+	}
+	for _, t := range c.QueryArray("type") {
+		filter.Types = append(filter.Types, models.NotificationType(t))
+	}
+	for _, s := range c.QueryArray("status") {
+		filter.Statuses = append(filter.Statuses, models.DeliveryStatus(s))
+	}
+	for _, ch := range c.QueryArray("channel") {
+		filter.Channels = append(filter.Channels, models.NotificationChannel(ch))
+	}
+	if raw := c.Query("unread"); raw != "" {
+		if filter.UnreadOnly, err = strconv.ParseBool(raw); err != nil {
+			return filter, fmt.Errorf("invalid unread: %w", err)
+		}
+	}
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+		filter.Since = &since
+	}
+	if raw := c.Query("until"); raw != "" {
+		until, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+		filter.Until = &until
+	}
+	filter.Sort = models.NotificationSort(c.Query("sort"))
+
+	return filter.WithDefaults(), nil
+}
+
+// GetUnreadCount handles GET /notifications/:userID/unread-count?channel=
+func (h *NotificationHandlers) GetUnreadCount(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid offset parameter",
+			"error": "Invalid user ID format",
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
 
-	notifications, err := h.notificationService.GetUserNotifications(c.Request.Context(), userID, limit, offset)
+	channel := models.NotificationChannel(c.Query("channel"))
+	count, err := h.notificationService.GetUnreadCount(c.Request.Context(), userID, channel)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve notifications",
-			"details": err.Error(),
+		respondError(c, "Failed to count unread notifications", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// GetNotificationByDedupeKey handles GET /notifications/by-dedupe-key?key=&userID=.
+// The userID must be the caller's own unless they are an admin.
+func (h *NotificationHandlers) GetNotificationByDedupeKey(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	dedupeKey := c.Query("key")
+	if dedupeKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Missing key parameter",
+		})
+		return
+	}
+
+	notification, err := h.notificationService.GetNotificationByDedupeKey(c.Request.Context(), userID, dedupeKey)
+	if err != nil {
+		respondError(c, "Failed to retrieve notification", err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": notifications,
-		"meta": gin.H{
-			"limit":  limit,
-			"offset": offset,
-			"count":  len(notifications),
-		},
+		"data": notification,
+	})
+}
+
+// GetNotification handles GET /notifications/id/:id. Only the notification's
+// user or an admin may read it.
+func (h *NotificationHandlers) GetNotification(c *gin.Context) {
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID format",
+		})
+		return
+	}
+
+	notification, err := h.notificationService.GetNotification(c.Request.Context(), notificationID)
+	if err != nil {
+		respondError(c, "Failed to retrieve notification", err)
+		return
+	}
+	if !middleware.AuthorizeUser(c, notification.UserID) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notification,
 	})
 }
 
-// MarkAsRead handles PUT /notifications/:id/read
+// MarkAsRead handles PUT /notifications/:id/read?userID=. The userID must be
+// the caller's own unless they are an admin.
 func (h *NotificationHandlers) MarkAsRead(c *gin.Context) {
 	notificationIDStr := c.Param("id")
 	notificationID, err := uuid.Parse(notificationIDStr)
@@ -158,19 +375,182 @@ func (h *NotificationHandlers) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.notificationService.MarkAsRead(c.Request.Context(), notificationID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to mark notification as read",
-			"details": err.Error(),
+	// The query names the user for admins; users may leave it out and act for
+	// themselves
+	userIDStr := c.Query("userID")
+	if principal, ok := middleware.CurrentPrincipal(c); ok && userIDStr == "" {
+		userIDStr = principal.UserID.String()
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	if err := h.notificationService.MarkAsRead(c.Request.Context(), notificationID, userID); err != nil {
+		respondError(c, "Failed to mark notification as read", err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Notification marked as read successfully",
 	})
 }
 
+// MarkAsDelivered handles PUT /notifications/:id/delivered?userID=, which the
+// consumer calls once a client has acknowledged the notification
+func (h *NotificationHandlers) MarkAsDelivered(c *gin.Context) {
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID format",
+		})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Query("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+
+	if err := h.notificationService.MarkAsDelivered(c.Request.Context(), notificationID, userID); err != nil {
+		respondError(c, "Failed to mark notification as delivered", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification marked as delivered successfully",
+	})
+}
+
+// MarkAllAsRead handles PUT /notifications/:userID/read-all?before=&type=
+// The route names the segment :id to share the wildcard with /:id/read.
+func (h *NotificationHandlers) MarkAllAsRead(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var before time.Time
+	if raw := c.Query("before"); raw != "" {
+		before, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid before parameter",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	notificationType := models.NotificationType(c.Query("type"))
+	updated, err := h.notificationService.MarkAllAsRead(c.Request.Context(), userID, before, notificationType)
+	if err != nil {
+		respondError(c, "Failed to mark notifications as read", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications marked as read",
+		"data":    gin.H{"updated": updated},
+	})
+}
+
+// RepublishNotification handles POST /notifications/:id/republish
+func (h *NotificationHandlers) RepublishNotification(c *gin.Context) {
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID format",
+		})
+		return
+	}
+
+	force := c.Query("force") == "true"
+	publishNow := c.Query("publish") == "true"
+
+	result, err := h.notificationService.RepublishNotification(c.Request.Context(), notificationID, force, publishNow)
+	if err != nil {
+		respondError(c, "Failed to republish notification", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Notification queued for republishing",
+		"data":    result,
+	})
+}
+
+// RetryNotification handles POST /notifications/:id/retry
+func (h *NotificationHandlers) RetryNotification(c *gin.Context) {
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID format",
+		})
+		return
+	}
+
+	force := c.Query("force") == "true"
+
+	notification, err := h.notificationService.RetryNotification(c.Request.Context(), notificationID, force)
+	if err != nil {
+		respondError(c, "Failed to retry notification", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Notification queued for delivery",
+		"data":    notification,
+	})
+}
+
+// CancelNotification handles DELETE /notifications/:id. Only the
+// notification's user or an admin may cancel it.
+func (h *NotificationHandlers) CancelNotification(c *gin.Context) {
+	notificationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID format",
+		})
+		return
+	}
+
+	existing, err := h.notificationService.GetNotification(c.Request.Context(), notificationID)
+	if err != nil {
+		respondError(c, "Failed to cancel notification", err)
+		return
+	}
+	if !middleware.AuthorizeUser(c, existing.UserID) {
+		return
+	}
+
+	notification, err := h.notificationService.CancelNotification(c.Request.Context(), notificationID)
+	if err != nil {
+		respondError(c, "Failed to cancel notification", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification cancelled",
+		"data":    notification,
+	})
+}
+
 // UpdateUserPreferences handles PUT /preferences/:userID
 func (h *NotificationHandlers) UpdateUserPreferences(c *gin.Context) {
 	userIDStr := c.Param("userID")
@@ -181,9 +561,12 @@ func (h *NotificationHandlers) UpdateUserPreferences(c *gin.Context) {
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
 
-	var prefs models.UserNotificationPreferences
-	if err := c.ShouldBindJSON(&prefs); err != nil {
+	var req models.NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request body",
 			"details": err.Error(),
@@ -191,16 +574,133 @@ func (h *NotificationHandlers) UpdateUserPreferences(c *gin.Context) {
 		return
 	}
 
-	if err := h.notificationService.UpdateUserPreferences(c.Request.Context(), userID, &prefs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update user preferences",
+	stored, err := h.notificationService.UpdateUserPreferences(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondError(c, "Failed to update user preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User preferences updated successfully",
+		"data":    stored,
+	})
+}
+
+// PatchUserPreferences handles PATCH /preferences/:userID
+func (h *NotificationHandlers) PatchUserPreferences(c *gin.Context) {
+	userIDStr := c.Param("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var patch models.NotificationPreferencesPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	stored, err := h.notificationService.PatchUserPreferences(c.Request.Context(), userID, &patch)
+	if err != nil {
+		respondError(c, "Failed to update user preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User preferences updated successfully",
+		"data":    stored,
+	})
+}
+
+// UpdateUserPreferencesBulk handles PUT /preferences/:userID/bulk
+func (h *NotificationHandlers) UpdateUserPreferencesBulk(c *gin.Context) {
+	userIDStr := c.Param("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var reqs []models.NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	preferences, err := h.notificationService.UpdateUserPreferencesBulk(c.Request.Context(), userID, reqs)
+	if err != nil {
+		respondError(c, "Failed to update user preferences", err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User preferences updated successfully",
+		"data":    preferences,
+	})
+}
+
+// DeleteUserPreferences handles DELETE /preferences/:userID. With type and
+// channel it deletes that one preference, without them every preference of the
+// user.
+func (h *NotificationHandlers) DeleteUserPreferences(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	notificationType := models.NotificationType(c.Query("type"))
+	channel := models.NotificationChannel(c.Query("channel"))
+	if (notificationType == "") != (channel == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "type and channel must be given together",
+		})
+		return
+	}
+
+	if notificationType == "" {
+		deleted, err := h.notificationService.DeleteUserPreferences(c.Request.Context(), userID)
+		if err != nil {
+			respondError(c, "Failed to delete user preferences", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User preferences deleted",
+			"data":    gin.H{"deleted": deleted},
+		})
+		return
+	}
+
+	if err := h.notificationService.DeleteUserPreference(c.Request.Context(), userID, notificationType, channel); err != nil {
+		respondError(c, "Failed to delete user preference", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User preference deleted",
 	})
 }
 
@@ -214,13 +714,13 @@ func (h *NotificationHandlers) GetUserPreferences(c *gin.Context) {
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
 
 	preferences, err := h.notificationService.GetUserPreferences(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve user preferences",
-			"details": err.Error(),
-		})
+		respondError(c, "Failed to retrieve user preferences", err)
 		return
 	}
 
@@ -229,65 +729,236 @@ func (h *NotificationHandlers) GetUserPreferences(c *gin.Context) {
 	})
 }
 
-// CreateDailyReminder handles POST /reminders/daily
-func (h *NotificationHandlers) CreateDailyReminder(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+// GetUserPreference handles GET /preferences/:userID/:type/:channel
+func (h *NotificationHandlers) GetUserPreference(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
+			"error": "Invalid user ID format",
 		})
 		return
 	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	notificationType := models.NotificationType(c.Param("type"))
+	channel := models.NotificationChannel(c.Param("channel"))
+	preference, err := h.notificationService.GetUserPreference(c.Request.Context(), userID, notificationType, channel)
+	if err != nil {
+		respondError(c, "Failed to retrieve user preference", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": preference,
+	})
+}
+
+// reminderRequest is the body of the reminder endpoints. The whole User body
+// they used to take is still accepted through its id, but the other fields are
+// ignored: reminders use the stored user.
+type reminderRequest struct {
+	UserID   uuid.UUID `json:"user_id"`
+	LegacyID uuid.UUID `json:"id"`
+}
+
+// reminderBodyDeprecation is returned alongside reminders created from a User body
+const reminderBodyDeprecation = "Sending a user object is deprecated and its fields are ignored; send {\"user_id\": \"...\"} instead"
 
-	if err := h.notificationService.CreateDailyReminder(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create daily reminder",
+// bindReminderUserID reads the user ID from a reminder request, responding with
+// 400 when there is none. deprecated reports whether the legacy User body was used.
+func bindReminderUserID(c *gin.Context) (userID uuid.UUID, deprecated bool, ok bool) {
+	var req reminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
 			"details": err.Error(),
 		})
+		return uuid.Nil, false, false
+	}
+
+	switch {
+	case req.UserID != uuid.Nil:
+		return req.UserID, false, true
+	case req.LegacyID != uuid.Nil:
+		return req.LegacyID, true, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "user_id is required",
+		})
+		return uuid.Nil, false, false
+	}
+}
+
+// reminderCreated responds to a created reminder, warning callers still on the User body
+func reminderCreated(c *gin.Context, message string, deprecated bool) {
+	response := gin.H{"message": message}
+	if deprecated {
+		response["warning"] = reminderBodyDeprecation
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// CreateDailyReminder handles POST /reminders/daily
+func (h *NotificationHandlers) CreateDailyReminder(c *gin.Context) {
+	userID, deprecated, ok := bindReminderUserID(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Daily reminder created successfully",
-	})
+	if err := h.notificationService.CreateDailyReminder(c.Request.Context(), userID); err != nil {
+		respondError(c, "Failed to create daily reminder", err)
+		return
+	}
+
+	reminderCreated(c, "Daily reminder created successfully", deprecated)
 }
 
 // CreateStreakReminder handles POST /reminders/streak
 func (h *NotificationHandlers) CreateStreakReminder(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	userID, deprecated, ok := bindReminderUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.notificationService.CreateStreakReminder(c.Request.Context(), userID); err != nil {
+		respondError(c, "Failed to create streak reminder", err)
+		return
+	}
+
+	reminderCreated(c, "Streak reminder created successfully", deprecated)
+}
+
+// ProcessOutbox handles POST /outbox/process
+func (h *NotificationHandlers) ProcessOutbox(c *gin.Context) {
+	result, err := h.notificationService.ProcessOutbox(c.Request.Context())
+	if result == nil {
+		respondError(c, "Failed to process outbox", err)
+		return
+	}
+
+	// Per-item failures are reported in the result rather than failing the request
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Outbox processed",
+		"data":    result,
+	})
+}
+
+// PurgeOutbox handles POST /outbox/purge
+func (h *NotificationHandlers) PurgeOutbox(c *gin.Context) {
+	var req models.OutboxPurgeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	var before time.Time
+	if req.Before != nil {
+		before = *req.Before
+	}
+
+	deleted, err := h.notificationService.PurgeOutbox(c.Request.Context(), before)
+	if err != nil {
+		respondError(c, "Failed to purge outbox", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Outbox purged",
+		"data":    gin.H{"deleted": deleted},
+	})
+}
+
+// GetOutboxStats handles GET /outbox/stats
+func (h *NotificationHandlers) GetOutboxStats(c *gin.Context) {
+	stats, err := h.notificationService.GetOutboxStats(c.Request.Context())
+	if err != nil {
+		respondError(c, "Failed to get outbox stats", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// ListDeadOutbox handles GET /outbox/dead
+func (h *NotificationHandlers) ListDeadOutbox(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
+			"error": "Invalid limit parameter",
 		})
 		return
 	}
 
-	if err := h.notificationService.CreateStreakReminder(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create streak reminder",
-			"details": err.Error(),
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid offset parameter",
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Streak reminder created successfully",
+	items, err := h.notificationService.ListDeadOutbox(c.Request.Context(), limit, offset)
+	if err != nil {
+		respondError(c, "Failed to list dead outbox items", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"meta": gin.H{
+			"limit":  limit,
+			"offset": offset,
+			"count":  len(items),
+		},
 	})
 }
 
-// ProcessOutbox handles POST /outbox/process
-func (h *NotificationHandlers) ProcessOutbox(c *gin.Context) {
-	if err := h.notificationService.ProcessOutbox(c.Request.Context()); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to process outbox",
+// RetryDeadOutbox handles POST /outbox/dead/:id/retry
+func (h *NotificationHandlers) RetryDeadOutbox(c *gin.Context) {
+	outboxID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid outbox ID",
+		})
+		return
+	}
+
+	if err := h.notificationService.RetryDeadOutbox(c.Request.Context(), outboxID); err != nil {
+		respondError(c, "Failed to retry outbox item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Outbox item requeued",
+	})
+}
+
+// ReplayDecisions handles POST /admin/debug/replay-decisions
+func (h *NotificationHandlers) ReplayDecisions(c *gin.Context) {
+	var req models.DecisionReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	replay, err := h.notificationService.ReplayDecisions(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, "Failed to replay decisions", err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Outbox processed successfully",
+		"data": replay,
 	})
 }
@@ -0,0 +1,1885 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/auth"
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/events"
+	"kafka-notify/internal/middleware"
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/models"
+	"kafka-notify/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockNotificationService is a mock implementation of services.NotificationService
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) CreateNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) CreateFanout(ctx context.Context, req *models.CreateNotificationRequest) ([]*models.Notification, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) BackfillNotification(ctx context.Context, req *models.CreateNotificationRequest) (*models.Notification, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) GetUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) GetNotification(ctx context.Context, notificationID uuid.UUID) (*models.NotificationDetail, error) {
+	args := m.Called(ctx, notificationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationDetail), args.Error(1)
+}
+
+func (m *MockNotificationService) GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	args := m.Called(ctx, userID, dedupeKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error) {
+	args := m.Called(ctx, userID, channel)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error {
+	args := m.Called(ctx, notificationID, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) MarkAsDelivered(ctx context.Context, notificationID, userID uuid.UUID) error {
+	args := m.Called(ctx, notificationID, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error) {
+	args := m.Called(ctx, userID, before, notificationType)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, req *models.NotificationPreferencesRequest) (*models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationService) PatchUserPreferences(ctx context.Context, userID uuid.UUID, patch *models.NotificationPreferencesPatch) (*models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID, patch)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationService) UpdateUserPreferencesBulk(ctx context.Context, userID uuid.UUID, reqs []models.NotificationPreferencesRequest) ([]models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID, reqs)
+	return args.Get(0).([]models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationService) DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error {
+	args := m.Called(ctx, userID, notificationType, channel)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.ResolvedPreference, error) {
+	args := m.Called(ctx, userID, notificationType, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ResolvedPreference), args.Error(1)
+}
+
+func (m *MockNotificationService) GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.UserNotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationService) CreateDailyReminder(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) CreateStreakReminder(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) ProcessOutbox(ctx context.Context) (*services.OutboxResult, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.OutboxResult), args.Error(1)
+}
+
+func (m *MockNotificationService) ProcessOutboxAsync(ctx context.Context) (*services.OutboxResult, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.OutboxResult), args.Error(1)
+}
+
+func (m *MockNotificationService) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) CreateFromTemplate(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, data map[string]interface{}) (*models.Notification, error) {
+	args := m.Called(ctx, userID, notificationType, channel, data)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) DispatchScheduled(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) GetOutboxStats(ctx context.Context) (*models.OutboxStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OutboxStats), args.Error(1)
+}
+
+func (m *MockNotificationService) ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error) {
+	args := m.Called(ctx, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OutboxNotification), args.Error(1)
+}
+
+func (m *MockNotificationService) RetryDeadOutbox(ctx context.Context, outboxID int64) error {
+	args := m.Called(ctx, outboxID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) RepublishNotification(ctx context.Context, notificationID uuid.UUID, force, publishNow bool) (*services.RepublishResult, error) {
+	args := m.Called(ctx, notificationID, force, publishNow)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.RepublishResult), args.Error(1)
+}
+
+func (m *MockNotificationService) RetryFailed(ctx context.Context) (*services.RetryResult, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.RetryResult), args.Error(1)
+}
+
+func (m *MockNotificationService) RetryNotification(ctx context.Context, notificationID uuid.UUID, force bool) (*models.Notification, error) {
+	args := m.Called(ctx, notificationID, force)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) CancelNotification(ctx context.Context, notificationID uuid.UUID) (*models.Notification, error) {
+	args := m.Called(ctx, notificationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) ReplayDecisions(ctx context.Context, req *models.DecisionReplayRequest) (*services.DecisionReplay, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.DecisionReplay), args.Error(1)
+}
+
+func setupRouter(h *NotificationHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Auth is off here as in development; TestUserRoutes_RequireOwnToken covers it
+	api := router.Group("/api/v1", middleware.Auth(nil))
+	api.POST("/notifications", h.CreateNotification)
+	api.POST("/notifications/from-template", h.CreateFromTemplate)
+	api.POST("/admin/notifications/backfill", h.BackfillNotification)
+	api.GET("/notifications/by-dedupe-key", h.GetNotificationByDedupeKey)
+	api.GET("/notifications/id/:id", h.GetNotification)
+	api.GET("/notifications/:userID", h.GetUserNotifications)
+	api.GET("/notifications/:userID/unread-count", h.GetUnreadCount)
+	api.POST("/admin/debug/replay-decisions", h.ReplayDecisions)
+	api.POST("/outbox/process", h.ProcessOutbox)
+	api.POST("/outbox/purge", h.PurgeOutbox)
+	api.POST("/notifications/:id/republish", h.RepublishNotification)
+	api.POST("/notifications/:id/retry", h.RetryNotification)
+	api.DELETE("/notifications/:id", h.CancelNotification)
+	api.PUT("/notifications/:id/read", h.MarkAsRead)
+	api.PUT("/notifications/:id/delivered", h.MarkAsDelivered)
+	api.PUT("/notifications/:id/read-all", h.MarkAllAsRead)
+	api.PUT("/preferences/:userID", h.UpdateUserPreferences)
+	api.PATCH("/preferences/:userID", h.PatchUserPreferences)
+	api.PUT("/preferences/:userID/bulk", h.UpdateUserPreferencesBulk)
+	api.GET("/preferences/:userID", h.GetUserPreferences)
+	api.GET("/preferences/:userID/:type/:channel", h.GetUserPreference)
+	api.DELETE("/preferences/:userID", h.DeleteUserPreferences)
+	api.POST("/reminders/daily", h.CreateDailyReminder)
+	api.POST("/reminders/streak", h.CreateStreakReminder)
+	api.GET("/outbox/stats", h.GetOutboxStats)
+	api.GET("/outbox/dead", h.ListDeadOutbox)
+	api.POST("/outbox/dead/:id/retry", h.RetryDeadOutbox)
+
+	return router
+}
+
+func TestGetNotificationByDedupeKey_Found(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	dedupeKey := "daily-reminder:2024-03-12"
+	expected := &models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      models.DailyReminder,
+		Channel:   models.ChannelInApp,
+		Message:   "Time to practice",
+		DedupeKey: &dedupeKey,
+	}
+
+	mockService.On("GetNotificationByDedupeKey", mock.Anything, userID, dedupeKey).Return(expected, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications/by-dedupe-key?key="+dedupeKey+"&userID="+userID.String(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data models.Notification `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, expected.ID, body.Data.ID)
+	assert.Equal(t, dedupeKey, *body.Data.DedupeKey)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetNotificationByDedupeKey_NotFound(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	mockService.On("GetNotificationByDedupeKey", mock.Anything, userID, "missing").
+		Return(nil, fmt.Errorf("%w: dedupe key %q", repository.ErrNotificationNotFound, "missing"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications/by-dedupe-key?key=missing&userID="+userID.String(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetNotificationByDedupeKey_ForeignUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockNotificationService)
+	router := gin.New()
+	router.GET("/notifications/by-dedupe-key", testAuth(t), NewNotificationHandlers(mockService).GetNotificationByDedupeKey)
+
+	// The caller asks for another user's key
+	caller := uuid.New()
+	otherUserID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/notifications/by-dedupe-key?key=daily-reminder:2024-03-12&userID="+otherUserID.String(), nil)
+	req.Header.Set("Authorization", userToken(t, caller, ""))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertNotCalled(t, "GetNotificationByDedupeKey", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetNotificationByDedupeKey_InvalidParams(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	for _, url := range []string{
+		"/api/v1/notifications/by-dedupe-key?key=abc&userID=not-a-uuid",
+		"/api/v1/notifications/by-dedupe-key?userID=" + uuid.New().String(),
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+
+	mockService.AssertNotCalled(t, "GetNotificationByDedupeKey")
+}
+
+func TestCreateNotification_RejectsBackfillFields(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	body := fmt.Sprintf(`{"id":%q,"created_at":"2023-01-01T00:00:00Z","user_id":%q,"type":"daily_reminder","channel":"in_app","message":"hi"}`,
+		uuid.New(), uuid.New())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateNotification")
+}
+
+func TestCreateNotification_InvalidAttachments(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("CreateNotification", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+		return len(req.Attachments) == 1 && req.Attachments[0].URL == "http://cdn.example.com/b.png"
+	})).Return(nil, fmt.Errorf("%w: attachment 0: url must use https", models.ErrInvalidAttachment))
+
+	body := fmt.Sprintf(`{"user_id":%q,"type":"achievement_unlock","channel":"in_app","message":"hi","attachments":[{"kind":"icon","url":"http://cdn.example.com/b.png"}]}`,
+		uuid.New())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "url must use https")
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateNotification_DedupeKeyStatusCodes(t *testing.T) {
+	userID := uuid.New()
+	dedupeKey := "lesson-42-complete"
+	existing := &models.Notification{ID: uuid.New(), UserID: userID, DedupeKey: &dedupeKey}
+
+	cases := []struct {
+		name         string
+		notification *models.Notification
+		err          error
+		status       int
+	}{
+		{"first write", &models.Notification{ID: uuid.New(), UserID: userID, DedupeKey: &dedupeKey}, nil, http.StatusCreated},
+		{"duplicate", nil, &services.DuplicateNotificationError{Existing: existing}, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			mockService.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).Return(tc.notification, tc.err)
+
+			body := fmt.Sprintf(`{"user_id":%q,"type":"achievement_unlock","channel":"in_app","message":"hi","dedupe_key":%q}`, userID, dedupeKey)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			var resp struct {
+				Data models.Notification `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if tc.err != nil {
+				assert.Equal(t, existing.ID, resp.Data.ID)
+			} else {
+				assert.Equal(t, tc.notification.ID, resp.Data.ID)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateNotification_IdempotencyKeyHeader(t *testing.T) {
+	userID := uuid.New()
+	original := &models.Notification{ID: uuid.New(), UserID: userID}
+	body := fmt.Sprintf(`{"user_id":%q,"type":"achievement_unlock","channel":"in_app","message":"hi"}`, userID)
+
+	cases := []struct {
+		name    string
+		key     string
+		err     error
+		status  int
+		message string
+	}{
+		{"first request", "req-7f3a", nil, http.StatusCreated, "Notification created successfully"},
+		{"replay", "req-7f3a", &services.DuplicateNotificationError{Existing: original, ByIdempotencyKey: true}, http.StatusOK, "Notification already exists for this idempotency key"},
+		{"key too long", strings.Repeat("k", MaxIdempotencyKeyLength+1), nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			mockService.On("CreateNotification", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+				return req.IdempotencyKey == tc.key
+			})).Return(original, tc.err).Maybe()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Idempotency-Key", tc.key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusBadRequest {
+				mockService.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+				return
+			}
+			var resp struct {
+				Message string              `json:"message"`
+				Data    models.Notification `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tc.message, resp.Message)
+			assert.Equal(t, original.ID, resp.Data.ID)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateNotification_AutoChannelFansOut(t *testing.T) {
+	userID := uuid.New()
+	cases := []struct {
+		name          string
+		notifications []*models.Notification
+		status        int
+	}{
+		{"no enabled channels", []*models.Notification{}, http.StatusOK},
+		{"two channels", []*models.Notification{
+			{ID: uuid.New(), UserID: userID, Channel: models.ChannelPush},
+			{ID: uuid.New(), UserID: userID, Channel: models.ChannelEmail},
+		}, http.StatusCreated},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			mockService.On("CreateFanout", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+				return req.Channel == models.ChannelAuto
+			})).Return(tc.notifications, nil)
+
+			body := fmt.Sprintf(`{"user_id":%q,"type":"achievement_unlock","channel":"auto","message":"hi"}`, userID)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			var resp struct {
+				Data []models.Notification `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Len(t, resp.Data, len(tc.notifications))
+			mockService.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateFromTemplate_StatusCodes(t *testing.T) {
+	userID := uuid.New()
+	cases := []struct {
+		name         string
+		notification *models.Notification
+		err          error
+		status       int
+	}{
+		{"created", &models.Notification{ID: uuid.New(), UserID: userID}, nil, http.StatusCreated},
+		{"missing template", nil, fmt.Errorf("%w for weekly_recap on sms", services.ErrTemplateNotFound), http.StatusUnprocessableEntity},
+		{"missing variable", nil, fmt.Errorf("%w 7: map has no entry for key \"Streak\"", services.ErrTemplateRender), http.StatusUnprocessableEntity},
+		{"internal", nil, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			mockService.On("CreateFromTemplate", mock.Anything, userID, models.StreakReminder, models.ChannelInApp,
+				map[string]interface{}{"Name": "Alex"}).Return(tc.notification, tc.err)
+
+			body := fmt.Sprintf(`{"user_id":%q,"type":"streak_reminder","channel":"in_app","data":{"Name":"Alex"}}`, userID)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/from-template", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateFromTemplate_InvalidType(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	body := fmt.Sprintf(`{"user_id":%q,"type":"nope","channel":"in_app"}`, uuid.New())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/from-template", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateFromTemplate")
+}
+
+func TestBackfillNotification_StatusCodes(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"conflict", fmt.Errorf("failed to backfill notification: %w", repository.ErrNotificationExists), http.StatusConflict},
+		{"invalid", fmt.Errorf("%w: created_at is in the future", services.ErrInvalidBackfill), http.StatusUnprocessableEntity},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+
+			id := uuid.New()
+			var result interface{}
+			if tc.err == nil {
+				result = &models.Notification{ID: id}
+			}
+			mockService.On("BackfillNotification", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+				return req.ID != nil && *req.ID == id
+			})).Return(result, tc.err)
+
+			body := fmt.Sprintf(`{"id":%q,"created_at":"2023-01-01T00:00:00Z","user_id":%q,"type":"daily_reminder","channel":"in_app","message":"hi"}`,
+				id, uuid.New())
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/notifications/backfill", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplayDecisions_InvalidRange(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("ReplayDecisions", mock.Anything, mock.AnythingOfType("*models.DecisionReplayRequest")).
+		Return(nil, fmt.Errorf("%w: to must be after from", services.ErrInvalidReplay))
+
+	body := fmt.Sprintf(`{"user_id":%q,"from":"2024-03-02T00:00:00Z","to":"2024-03-01T00:00:00Z"}`, uuid.New())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/debug/replay-decisions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestProcessOutbox_ReturnsResult(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	notificationID := uuid.New()
+	result := &services.OutboxResult{
+		Published: 4,
+		Failed:    1,
+		Remaining: 12,
+		Errors:    []services.OutboxItemError{{OutboxID: 7, NotificationID: notificationID, Error: "message too large"}},
+	}
+	mockService.On("ProcessOutbox", mock.Anything).Return(result, errors.New("failed to send outbox item 7 to Kafka: message too large"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/outbox/process", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data services.OutboxResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, *result, body.Data)
+}
+
+func TestProcessOutbox_ClaimFailure(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("ProcessOutbox", mock.Anything).Return(nil, errors.New("failed to claim unpublished outbox: connection refused"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/outbox/process", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestPurgeOutbox_DefaultsToRetentionWindow(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("PurgeOutbox", mock.Anything, time.Time{}).Return(int64(12000), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/outbox/purge", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Outbox purged","data":{"deleted":12000}}`, w.Body.String())
+}
+
+func TestPurgeOutbox_UsesBeforeTimestamp(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	before := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("PurgeOutbox", mock.Anything, mock.MatchedBy(func(t time.Time) bool { return t.Equal(before) })).
+		Return(int64(3), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/outbox/purge", strings.NewReader(`{"before":"2024-03-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPurgeOutbox_FutureCutoffIsUnprocessable(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("PurgeOutbox", mock.Anything, mock.Anything).
+		Return(int64(0), fmt.Errorf("%w: cutoff is in the future", services.ErrInvalidPurge))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/outbox/purge", strings.NewReader(`{"before":"2999-01-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestGetOutboxStats(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	oldest := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	mockService.On("GetOutboxStats", mock.Anything).Return(&models.OutboxStats{
+		Pending:                 12,
+		Dead:                    2,
+		PublishedLastHour:       340,
+		OldestPendingAt:         &oldest,
+		OldestPendingAgeSeconds: 95.5,
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/outbox/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{
+		"pending":                    float64(12),
+		"dead":                       float64(2),
+		"published_last_hour":        float64(340),
+		"oldest_pending_at":          "2024-03-12T09:00:00Z",
+		"oldest_pending_age_seconds": 95.5,
+	}, body.Data)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetOutboxStats_Error(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("GetOutboxStats", mock.Anything).Return(nil, errors.New("db down"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/outbox/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestListDeadOutbox(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	lastError := "broker down"
+	dead := []models.OutboxNotification{{ID: 9, NotificationID: uuid.New(), Topic: "notifications", Attempts: 5, LastError: &lastError, Dead: true}}
+	mockService.On("ListDeadOutbox", mock.Anything, 20, 40).Return(dead, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/outbox/dead?limit=20&offset=40", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []models.OutboxNotification `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, int64(9), body.Data[0].ID)
+	assert.Equal(t, "broker down", *body.Data[0].LastError)
+	mockService.AssertExpectations(t)
+}
+
+func TestRetryDeadOutbox(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	mockService.On("RetryDeadOutbox", mock.Anything, int64(9)).Return(nil)
+	mockService.On("RetryDeadOutbox", mock.Anything, int64(10)).
+		Return(fmt.Errorf("failed to retry dead outbox item: %w", repository.ErrOutboxNotFound))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/v1/outbox/dead/9/retry", http.StatusOK},
+		{"/api/v1/outbox/dead/10/retry", http.StatusNotFound},
+		{"/api/v1/outbox/dead/abc/retry", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.want, w.Code, tt.path)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRepublishNotification(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	queued := uuid.New()
+	read := uuid.New()
+	missing := uuid.New()
+	forced := uuid.New()
+	mockService.On("RepublishNotification", mock.Anything, queued, false, true).
+		Return(&services.RepublishResult{Notification: &models.Notification{ID: queued}, Outbox: &services.OutboxResult{Published: 1}}, nil)
+	mockService.On("RepublishNotification", mock.Anything, read, false, false).
+		Return(nil, fmt.Errorf("%w: %s", services.ErrRepublishRead, read))
+	mockService.On("RepublishNotification", mock.Anything, missing, false, false).
+		Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+	mockService.On("RepublishNotification", mock.Anything, forced, true, false).
+		Return(&services.RepublishResult{Notification: &models.Notification{ID: forced, Status: models.StatusRead}}, nil)
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"publishes immediately", "/api/v1/notifications/" + queued.String() + "/republish?publish=true", http.StatusAccepted},
+		{"read without force", "/api/v1/notifications/" + read.String() + "/republish", http.StatusConflict},
+		{"unknown notification", "/api/v1/notifications/" + missing.String() + "/republish", http.StatusNotFound},
+		{"read with force", "/api/v1/notifications/" + forced.String() + "/republish?force=true", http.StatusAccepted},
+		{"invalid id", "/api/v1/notifications/not-a-uuid/republish", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRetryNotification(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	failed := uuid.New()
+	delivered := uuid.New()
+	rejected := uuid.New()
+	missing := uuid.New()
+	mockService.On("RetryNotification", mock.Anything, failed, false).
+		Return(&models.Notification{ID: failed, Status: models.StatusQueued}, nil)
+	mockService.On("RetryNotification", mock.Anything, delivered, false).
+		Return(nil, fmt.Errorf("%w: %s is delivered", services.ErrNotRetryable, delivered))
+	mockService.On("RetryNotification", mock.Anything, rejected, true).
+		Return(&models.Notification{ID: rejected, Status: models.StatusQueued}, nil)
+	mockService.On("RetryNotification", mock.Anything, missing, false).
+		Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"requeues failed", "/api/v1/notifications/" + failed.String() + "/retry", http.StatusAccepted},
+		{"not failed", "/api/v1/notifications/" + delivered.String() + "/retry", http.StatusConflict},
+		{"rejected with force", "/api/v1/notifications/" + rejected.String() + "/retry?force=true", http.StatusAccepted},
+		{"unknown notification", "/api/v1/notifications/" + missing.String() + "/retry", http.StatusNotFound},
+		{"invalid id", "/api/v1/notifications/not-a-uuid/retry", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestCancelNotification(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	queued := uuid.New()
+	sent := uuid.New()
+	missing := uuid.New()
+	for _, id := range []uuid.UUID{queued, sent} {
+		mockService.On("GetNotification", mock.Anything, id).
+			Return(&models.NotificationDetail{Notification: models.Notification{ID: id}}, nil)
+	}
+	mockService.On("GetNotification", mock.Anything, missing).
+		Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+	mockService.On("CancelNotification", mock.Anything, queued).
+		Return(&models.Notification{ID: queued, Status: models.StatusCancelled}, nil)
+	mockService.On("CancelNotification", mock.Anything, sent).
+		Return(nil, fmt.Errorf("%w: %s is sent", services.ErrNotificationNotCancellable, sent))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"queued", "/api/v1/notifications/" + queued.String(), http.StatusOK},
+		{"already sent", "/api/v1/notifications/" + sent.String(), http.StatusConflict},
+		{"unknown notification", "/api/v1/notifications/" + missing.String(), http.StatusNotFound},
+		{"invalid id", "/api/v1/notifications/not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestGetNotification(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	found := uuid.New()
+	missing := uuid.New()
+	provider := "twilio"
+	mockService.On("GetNotification", mock.Anything, found).Return(&models.NotificationDetail{
+		Notification: models.Notification{ID: found, Status: models.StatusDelivered},
+		DeliveryAttempts: []models.NotificationDeliveryAttempt{
+			{NotificationID: found, AttemptNo: 1, Status: models.StatusDelivered, Provider: &provider},
+		},
+	}, nil)
+	mockService.On("GetNotification", mock.Anything, missing).
+		Return(nil, fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"found", "/api/v1/notifications/id/" + found.String(), http.StatusOK},
+		{"unknown notification", "/api/v1/notifications/id/" + missing.String(), http.StatusNotFound},
+		{"invalid id", "/api/v1/notifications/id/not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+
+	// The attempts are embedded next to the notification's own fields
+	req := httptest.NewRequest(http.MethodGet, tests[0].path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data struct {
+			ID               uuid.UUID                            `json:"id"`
+			Status           models.DeliveryStatus                `json:"status"`
+			DeliveryAttempts []models.NotificationDeliveryAttempt `json:"delivery_attempts"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, found, body.Data.ID)
+	assert.Equal(t, models.StatusDelivered, body.Data.Status)
+	require.Len(t, body.Data.DeliveryAttempts, 1)
+	assert.Equal(t, "twilio", *body.Data.DeliveryAttempts[0].Provider)
+}
+
+func TestCreateReminder_Body(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name        string
+		path        string
+		call        string
+		body        string
+		wantStatus  int
+		wantWarning bool
+	}{
+		{"daily, user_id", "/api/v1/reminders/daily", "CreateDailyReminder", fmt.Sprintf(`{"user_id":%q}`, userID), http.StatusCreated, false},
+		{"streak, user_id", "/api/v1/reminders/streak", "CreateStreakReminder", fmt.Sprintf(`{"user_id":%q}`, userID), http.StatusCreated, false},
+		{"daily, legacy user body", "/api/v1/reminders/daily", "CreateDailyReminder",
+			fmt.Sprintf(`{"id":%q,"name":"Spoofed","email":"x@example.com","total_xp":9999}`, userID), http.StatusCreated, true},
+		{"streak, legacy user body", "/api/v1/reminders/streak", "CreateStreakReminder",
+			fmt.Sprintf(`{"id":%q,"name":"Spoofed"}`, userID), http.StatusCreated, true},
+		{"daily, no user", "/api/v1/reminders/daily", "", `{"name":"Ada"}`, http.StatusBadRequest, false},
+		{"streak, malformed user_id", "/api/v1/reminders/streak", "", `{"user_id":"nope"}`, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			if tt.call != "" {
+				mockService.On(tt.call, mock.Anything, userID).Return(nil)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			_, hasWarning := body["warning"]
+			assert.Equal(t, tt.wantWarning, hasWarning)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestErrorClassStatusCodes(t *testing.T) {
+	id := uuid.New().String()
+	user := fmt.Sprintf(`{"user_id":%q}`, id)
+	preference := `{"type":"streak_reminder","channel":"push","enabled":true}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		call   string
+		err    error
+		want   int
+	}{
+		{"mark read, unknown notification", http.MethodPut, "/api/v1/notifications/" + id + "/read?userID=" + id, "", "MarkAsRead",
+			fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, id), http.StatusNotFound},
+		{"mark read, internal", http.MethodPut, "/api/v1/notifications/" + id + "/read?userID=" + id, "", "MarkAsRead",
+			errors.New("connection refused"), http.StatusInternalServerError},
+		{"create, invalid type", http.MethodPost, "/api/v1/notifications",
+			fmt.Sprintf(`{"user_id":%q,"type":"nope","channel":"in_app","message":"hi"}`, id), "CreateNotification",
+			fmt.Errorf("%w type: nope", services.ErrInvalidNotification), http.StatusUnprocessableEntity},
+		{"create, invalid priority", http.MethodPost, "/api/v1/notifications",
+			fmt.Sprintf(`{"user_id":%q,"type":"daily_reminder","channel":"in_app","priority":"banana","message":"hi"}`, id), "CreateNotification",
+			fmt.Errorf("%w: banana", services.ErrInvalidPriority), http.StatusBadRequest},
+		{"fan out, invalid priority", http.MethodPost, "/api/v1/notifications",
+			fmt.Sprintf(`{"user_id":%q,"type":"daily_reminder","channel":"auto","priority":"banana","message":"hi"}`, id), "CreateFanout",
+			fmt.Errorf("%w: banana", services.ErrInvalidPriority), http.StatusBadRequest},
+		{"update preferences, invalid channel", http.MethodPut, "/api/v1/preferences/" + id, preference, "UpdateUserPreferences",
+			fmt.Errorf("%w channel: fax", services.ErrInvalidNotification), http.StatusUnprocessableEntity},
+		{"update preferences, internal", http.MethodPut, "/api/v1/preferences/" + id, preference, "UpdateUserPreferences",
+			errors.New("connection refused"), http.StatusInternalServerError},
+		{"daily reminder, unknown user", http.MethodPost, "/api/v1/reminders/daily", user, "CreateDailyReminder",
+			fmt.Errorf("failed to load user: %w", repository.ErrUserNotFound), http.StatusNotFound},
+		{"daily reminder, already sent today", http.MethodPost, "/api/v1/reminders/daily", user, "CreateDailyReminder",
+			fmt.Errorf("%w: daily_reminder", services.ErrAlreadySentToday), http.StatusConflict},
+		{"streak reminder, no streak row", http.MethodPost, "/api/v1/reminders/streak", user, "CreateStreakReminder",
+			fmt.Errorf("failed to get user streak: %w", repository.ErrStreakNotFound), http.StatusNotFound},
+		{"streak reminder, broken streak", http.MethodPost, "/api/v1/reminders/streak", user, "CreateStreakReminder",
+			services.ErrNoActiveStreak, http.StatusConflict},
+		{"streak reminder, missing template", http.MethodPost, "/api/v1/reminders/streak", user, "CreateStreakReminder",
+			fmt.Errorf("failed to create streak reminder: %w", services.ErrTemplateNotFound), http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			switch tt.call {
+			case "CreateNotification", "CreateFanout":
+				mockService.On(tt.call, mock.Anything, mock.Anything).Return(nil, tt.err)
+			case "UpdateUserPreferences":
+				mockService.On(tt.call, mock.Anything, mock.Anything, mock.Anything).Return(nil, tt.err)
+			case "MarkAsRead":
+				mockService.On(tt.call, mock.Anything, mock.Anything, mock.Anything).Return(tt.err)
+			default:
+				mockService.On(tt.call, mock.Anything, mock.Anything).Return(tt.err)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.NotEmpty(t, body["error"])
+			assert.Equal(t, tt.err.Error(), body["details"])
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMarkAsRead_StatusCodes(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	owner := uuid.New()
+	stranger := uuid.New()
+	notificationID := uuid.New()
+	missing := uuid.New()
+	mockService.On("MarkAsRead", mock.Anything, notificationID, owner).Return(nil)
+	mockService.On("MarkAsRead", mock.Anything, notificationID, stranger).
+		Return(fmt.Errorf("%w: %s", repository.ErrNotificationNotOwned, notificationID))
+	mockService.On("MarkAsRead", mock.Anything, missing, owner).
+		Return(fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"owner", "/api/v1/notifications/" + notificationID.String() + "/read?userID=" + owner.String(), http.StatusOK},
+		{"another user", "/api/v1/notifications/" + notificationID.String() + "/read?userID=" + stranger.String(), http.StatusForbidden},
+		{"unknown notification", "/api/v1/notifications/" + missing.String() + "/read?userID=" + owner.String(), http.StatusNotFound},
+		{"missing user", "/api/v1/notifications/" + notificationID.String() + "/read", http.StatusBadRequest},
+		{"invalid id", "/api/v1/notifications/not-a-uuid/read?userID=" + owner.String(), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestMarkAsDelivered_StatusCodes(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	owner := uuid.New()
+	stranger := uuid.New()
+	notificationID := uuid.New()
+	missing := uuid.New()
+	mockService.On("MarkAsDelivered", mock.Anything, notificationID, owner).Return(nil)
+	mockService.On("MarkAsDelivered", mock.Anything, notificationID, stranger).
+		Return(fmt.Errorf("%w: %s", repository.ErrNotificationNotOwned, notificationID))
+	mockService.On("MarkAsDelivered", mock.Anything, missing, owner).
+		Return(fmt.Errorf("%w: %s", repository.ErrNotificationNotFound, missing))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"owner", "/api/v1/notifications/" + notificationID.String() + "/delivered?userID=" + owner.String(), http.StatusOK},
+		{"another user", "/api/v1/notifications/" + notificationID.String() + "/delivered?userID=" + stranger.String(), http.StatusForbidden},
+		{"unknown notification", "/api/v1/notifications/" + missing.String() + "/delivered?userID=" + owner.String(), http.StatusNotFound},
+		{"missing user", "/api/v1/notifications/" + notificationID.String() + "/delivered", http.StatusBadRequest},
+		{"invalid id", "/api/v1/notifications/not-a-uuid/delivered?userID=" + owner.String(), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUnreadCount(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	failing := uuid.New()
+	mockService.On("GetUnreadCount", mock.Anything, userID, models.NotificationChannel("")).Return(int64(7), nil)
+	mockService.On("GetUnreadCount", mock.Anything, userID, models.ChannelInApp).Return(int64(3), nil)
+	mockService.On("GetUnreadCount", mock.Anything, userID, models.NotificationChannel("fax")).
+		Return(int64(0), fmt.Errorf("%w channel: fax", services.ErrInvalidNotification))
+	mockService.On("GetUnreadCount", mock.Anything, failing, models.NotificationChannel("")).
+		Return(int64(0), errors.New("connection refused"))
+
+	tests := []struct {
+		name      string
+		path      string
+		want      int
+		wantCount float64
+	}{
+		{"all channels", "/api/v1/notifications/" + userID.String() + "/unread-count", http.StatusOK, 7},
+		{"in-app only", "/api/v1/notifications/" + userID.String() + "/unread-count?channel=in_app", http.StatusOK, 3},
+		{"unknown channel", "/api/v1/notifications/" + userID.String() + "/unread-count?channel=fax", http.StatusUnprocessableEntity, 0},
+		{"invalid user", "/api/v1/notifications/not-a-uuid/unread-count", http.StatusBadRequest, 0},
+		{"internal", "/api/v1/notifications/" + failing.String() + "/unread-count", http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusOK {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantCount, body["count"])
+			}
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestMarkAllAsRead(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	cutoff := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	mockService.On("MarkAllAsRead", mock.Anything, userID, time.Time{}, models.NotificationType("")).Return(int64(0), nil)
+	mockService.On("MarkAllAsRead", mock.Anything, userID, cutoff, models.StreakReminder).Return(int64(5), nil)
+	mockService.On("MarkAllAsRead", mock.Anything, userID, time.Time{}, models.NotificationType("nope")).
+		Return(int64(0), fmt.Errorf("%w type: nope", services.ErrInvalidNotification))
+
+	base := "/api/v1/notifications/" + userID.String() + "/read-all"
+	tests := []struct {
+		name        string
+		path        string
+		want        int
+		wantUpdated float64
+	}{
+		{"nothing unread", base, http.StatusOK, 0},
+		{"one type up to a cutoff", base + "?type=streak_reminder&before=2024-03-12T15:00:00Z", http.StatusOK, 5},
+		{"unknown type", base + "?type=nope", http.StatusUnprocessableEntity, 0},
+		{"invalid cutoff", base + "?before=yesterday", http.StatusBadRequest, 0},
+		{"invalid user", "/api/v1/notifications/not-a-uuid/read-all", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusOK {
+				var body struct {
+					Data struct {
+						Updated float64 `json:"updated"`
+					} `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantUpdated, body.Data.Updated)
+			}
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUserNotifications_Filters(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	combined := models.NotificationFilter{
+		Types:      []models.NotificationType{models.StreakReminder, models.DailyReminder},
+		Statuses:   []models.DeliveryStatus{models.StatusDelivered},
+		Channels:   []models.NotificationChannel{models.ChannelInApp},
+		UnreadOnly: true,
+		Since:      &since,
+		Sort:       models.SortPriority,
+		Limit:      20,
+	}
+	mockService.On("GetUserNotifications", mock.Anything, userID, combined).
+		Return([]models.Notification{{ID: uuid.New(), UserID: userID}}, nil)
+	mockService.On("CountUserNotifications", mock.Anything, userID, combined).Return(int64(1), nil)
+	mockService.On("GetUserNotifications", mock.Anything, userID, models.NotificationFilter{Statuses: []models.DeliveryStatus{"pending"}, Sort: models.SortNewest, Limit: 50}).
+		Return([]models.Notification(nil), fmt.Errorf("%w: unknown status %q", models.ErrInvalidFilter, "pending"))
+
+	base := "/api/v1/notifications/" + userID.String()
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"combined", base + "?type=streak_reminder&type=daily_reminder&status=delivered&channel=in_app&unread=true&since=2024-03-01T00:00:00Z&sort=priority&limit=20", http.StatusOK},
+		{"unknown status", base + "?status=pending", http.StatusUnprocessableEntity},
+		{"malformed since", base + "?since=last-week", http.StatusBadRequest},
+		{"malformed unread", base + "?unread=maybe", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+
+	// The applied filter is echoed back
+	req := httptest.NewRequest(http.MethodGet, tests[0].path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Meta struct {
+			Count  int                       `json:"count"`
+			Limit  int                       `json:"limit"`
+			Filter models.NotificationFilter `json:"filter"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Meta.Count)
+	assert.Equal(t, 20, body.Meta.Limit)
+	assert.Equal(t, combined.Types, body.Meta.Filter.Types)
+	assert.Equal(t, models.SortPriority, body.Meta.Filter.Sort)
+	assert.True(t, body.Meta.Filter.UnreadOnly)
+	assert.True(t, since.Equal(*body.Meta.Filter.Since))
+}
+
+func TestGetUserNotifications_TotalAndHasMore(t *testing.T) {
+	userID := uuid.New()
+	page := func(n int) []models.Notification {
+		return make([]models.Notification, n)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		offset    int
+		rows      int
+		total     int64
+		wantTotal bool
+		wantMore  bool
+	}{
+		{"first page", "?limit=20", 0, 20, 45, true, true},
+		{"middle page", "?limit=20&offset=20", 20, 20, 45, true, true},
+		{"last partial page", "?limit=20&offset=40", 40, 5, 45, true, false},
+		{"exactly full last page", "?limit=20&offset=20", 20, 20, 40, true, false},
+		{"past the end", "?limit=20&offset=60", 60, 0, 45, true, false},
+		{"total skipped, full page", "?limit=20&include_total=false", 0, 20, 0, false, true},
+		{"total skipped, partial page", "?limit=20&offset=40&include_total=false", 40, 5, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+			filter := models.NotificationFilter{Sort: models.SortNewest, Limit: 20, Offset: tt.offset}
+			mockService.On("GetUserNotifications", mock.Anything, userID, filter).Return(page(tt.rows), nil)
+			if tt.wantTotal {
+				mockService.On("CountUserNotifications", mock.Anything, userID, filter).Return(tt.total, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications/"+userID.String()+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var body struct {
+				Meta map[string]interface{} `json:"meta"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantMore, body.Meta["has_more"])
+			if tt.wantTotal {
+				assert.Equal(t, float64(tt.total), body.Meta["total"])
+			} else {
+				assert.NotContains(t, body.Meta, "total")
+				mockService.AssertNotCalled(t, "CountUserNotifications", mock.Anything, mock.Anything, mock.Anything)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetUserNotifications_InvalidIncludeTotal(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications/"+uuid.New().String()+"?include_total=sometimes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetUserNotifications", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUserPreferences_InvalidFields(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	mockService.On("UpdateUserPreferences", mock.Anything, userID, mock.AnythingOfType("*models.NotificationPreferencesRequest")).
+		Return(nil, &models.FieldErrors{Err: models.ErrInvalidPreferences, Fields: map[string]string{
+			"quiet_hours_start": `must be HH:MM, got "25:99"`,
+			"max_per_day":       "must not be negative, got -1",
+		}})
+
+	body := `{"type":"streak_reminder","channel":"push","quiet_hours_start":"25:99","max_per_day":-1}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/preferences/"+userID.String(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var resp struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Failed to update user preferences", resp.Error)
+	assert.Equal(t, `must be HH:MM, got "25:99"`, resp.Fields["quiet_hours_start"])
+	assert.Equal(t, "must not be negative, got -1", resp.Fields["max_per_day"])
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUserPreference(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		path       string
+		setup      func(m *MockNotificationService)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "stored preference",
+			path: "/daily_reminder/in_app",
+			setup: func(m *MockNotificationService) {
+				m.On("GetUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelInApp).Return(&models.ResolvedPreference{
+					UserNotificationPreferences: models.UserNotificationPreferences{ID: 7, UserID: userID, Type: models.DailyReminder, Channel: models.ChannelInApp},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"enabled":false`,
+		},
+		{
+			name: "default when none is stored",
+			path: "/daily_reminder/email",
+			setup: func(m *MockNotificationService) {
+				m.On("GetUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelEmail).Return(&models.ResolvedPreference{
+					UserNotificationPreferences: models.UserNotificationPreferences{UserID: userID, Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: true},
+					Default:                     true,
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"default":true`,
+		},
+		{
+			name: "invalid channel",
+			path: "/daily_reminder/fax",
+			setup: func(m *MockNotificationService) {
+				m.On("GetUserPreference", mock.Anything, userID, models.DailyReminder, models.NotificationChannel("fax")).
+					Return(nil, models.ValidatePreferenceKey(models.DailyReminder, "fax"))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"fields":{"channel":`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			tt.setup(mockService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/preferences/"+userID.String()+tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteUserPreferences(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		query      string
+		setup      func(m *MockNotificationService)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:  "one preference",
+			query: "?type=daily_reminder&channel=in_app",
+			setup: func(m *MockNotificationService) {
+				m.On("DeleteUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelInApp).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"message":"User preference deleted"`,
+		},
+		{
+			name:  "no such preference",
+			query: "?type=daily_reminder&channel=email",
+			setup: func(m *MockNotificationService) {
+				m.On("DeleteUserPreference", mock.Anything, userID, models.DailyReminder, models.ChannelEmail).
+					Return(repository.ErrPreferenceNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"error":"Failed to delete user preference"`,
+		},
+		{
+			name:  "every preference",
+			query: "",
+			setup: func(m *MockNotificationService) {
+				m.On("DeleteUserPreferences", mock.Anything, userID).Return(int64(3), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"data":{"deleted":3}`,
+		},
+		{
+			name:       "type without channel",
+			query:      "?type=daily_reminder",
+			setup:      func(m *MockNotificationService) {},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"error":"type and channel must be given together"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockNotificationService)
+			tt.setup(mockService)
+			router := setupRouter(NewNotificationHandlers(mockService))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/preferences/"+userID.String()+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateUserPreferences_ReturnsStoredRow(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	stored := &models.UserNotificationPreferences{
+		ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true,
+		CreatedAt: time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 3, 12, 10, 0, 0, 0, time.UTC),
+	}
+	mockService.On("UpdateUserPreferences", mock.Anything, userID, mock.AnythingOfType("*models.NotificationPreferencesRequest")).Return(stored, nil)
+
+	body := `{"type":"streak_reminder","channel":"push","enabled":true}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/preferences/"+userID.String(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data models.UserNotificationPreferences `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(7), resp.Data.ID)
+	assert.Equal(t, stored.CreatedAt, resp.Data.CreatedAt)
+	assert.Equal(t, stored.UpdatedAt, resp.Data.UpdatedAt)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchUserPreferences_OmittedFieldsStayUnset(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	start := "22:00"
+	stored := &models.UserNotificationPreferences{
+		ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false, QuietHoursStart: &start,
+	}
+	var patch *models.NotificationPreferencesPatch
+	mockService.On("PatchUserPreferences", mock.Anything, userID, mock.AnythingOfType("*models.NotificationPreferencesPatch")).
+		Run(func(args mock.Arguments) { patch = args.Get(2).(*models.NotificationPreferencesPatch) }).
+		Return(stored, nil)
+
+	body := `{"type":"streak_reminder","channel":"push","enabled":false}`
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/preferences/"+userID.String(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, patch)
+	assert.False(t, *patch.Enabled)
+	assert.Nil(t, patch.QuietHoursStart, "omitted fields are left for the service to fill from the stored row")
+	assert.Nil(t, patch.MaxPerDay)
+	var resp struct {
+		Data models.UserNotificationPreferences `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "22:00", *resp.Data.QuietHoursStart)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateUserPreferencesBulk_ReturnsFullSet(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	userID := uuid.New()
+	result := []models.UserNotificationPreferences{
+		{ID: 8, UserID: userID, Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: true},
+		{ID: 7, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+		{ID: 3, UserID: userID, Type: models.StreakReminder, Channel: models.ChannelSMS, Enabled: true},
+	}
+	mockService.On("UpdateUserPreferencesBulk", mock.Anything, userID, []models.NotificationPreferencesRequest{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+		{Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: true},
+	}).Return(result, nil)
+
+	body := `[{"type":"streak_reminder","channel":"push","enabled":false},{"type":"daily_reminder","channel":"email","enabled":true}]`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/preferences/"+userID.String()+"/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []models.UserNotificationPreferences `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data, 3, "the full preference set, not only the submitted entries")
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateUserPreferencesBulk_InvalidBody(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := setupRouter(NewNotificationHandlers(mockService))
+
+	body := `{"type":"streak_reminder","channel":"push"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/preferences/"+uuid.New().String()+"/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "UpdateUserPreferencesBulk", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// userToken signs a test token for userID with the role, if any
+func userToken(t *testing.T, userID uuid.UUID, role string) string {
+	t.Helper()
+	claims := jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(time.Hour).Unix()}
+	if role != "" {
+		claims["role"] = role
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+// testAuth is Auth with a verifier for tokens signed by userToken
+func testAuth(t *testing.T) gin.HandlerFunc {
+	t.Helper()
+	verifier, err := auth.NewVerifier(config.AuthConfig{JWTSecret: "test-secret", AdminRole: "admin"})
+	require.NoError(t, err)
+	return middleware.Auth(verifier)
+}
+
+// ownTokenRoute is a request to a user route; ok is its status for callers
+// allowed to act for the user
+type ownTokenRoute struct {
+	name   string
+	method string
+	path   string
+	body   string
+	ok     int
+}
+
+// assertRequiresOwnToken sends each route without a token, with an invalid
+// one and with the tokens of owner, another user and an admin: only owner and
+// the admin may get through
+func assertRequiresOwnToken(t *testing.T, router http.Handler, owner uuid.UUID, routes []ownTokenRoute) {
+	t.Helper()
+	callers := []struct {
+		name    string
+		token   string
+		allowed bool
+		want    int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "invalid token", token: "Bearer nonsense", want: http.StatusUnauthorized},
+		{name: "owner", token: userToken(t, owner, ""), allowed: true},
+		{name: "another user", token: userToken(t, uuid.New(), ""), want: http.StatusForbidden},
+		{name: "admin", token: userToken(t, uuid.New(), "admin"), allowed: true},
+	}
+
+	for _, route := range routes {
+		for _, caller := range callers {
+			t.Run(route.name+"/"+caller.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				req.Header.Set("Content-Type", "application/json")
+				if caller.token != "" {
+					req.Header.Set("Authorization", caller.token)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				want := caller.want
+				if caller.allowed {
+					want = route.ok
+				}
+				assert.Equal(t, want, w.Code, w.Body.String())
+			})
+		}
+	}
+}
+
+func TestUserRoutes_RequireOwnToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockNotificationService)
+	h := NewNotificationHandlers(mockService)
+	router := gin.New()
+	user := router.Group("", testAuth(t))
+	user.GET("/notifications/by-dedupe-key", h.GetNotificationByDedupeKey)
+	user.GET("/notifications/id/:id", h.GetNotification)
+	user.GET("/notifications/:userID", h.GetUserNotifications)
+	user.GET("/notifications/:userID/unread-count", h.GetUnreadCount)
+	user.PUT("/notifications/:id/read", h.MarkAsRead)
+	user.PUT("/notifications/:id/read-all", h.MarkAllAsRead)
+	user.DELETE("/notifications/:id", h.CancelNotification)
+	user.PUT("/preferences/:userID", h.UpdateUserPreferences)
+	user.PATCH("/preferences/:userID", h.PatchUserPreferences)
+	user.PUT("/preferences/:userID/bulk", h.UpdateUserPreferencesBulk)
+	user.GET("/preferences/:userID", h.GetUserPreferences)
+	user.GET("/preferences/:userID/:type/:channel", h.GetUserPreference)
+	user.DELETE("/preferences/:userID", h.DeleteUserPreferences)
+
+	owner := uuid.New()
+	notificationID := uuid.New()
+	mockService.On("GetNotificationByDedupeKey", mock.Anything, owner, "daily-reminder:2024-03-12").
+		Return(&models.Notification{ID: notificationID, UserID: owner}, nil)
+	mockService.On("GetUserNotifications", mock.Anything, owner, mock.Anything).Return([]models.Notification{}, nil)
+	mockService.On("CountUserNotifications", mock.Anything, owner, mock.Anything).Return(int64(0), nil)
+	mockService.On("GetUnreadCount", mock.Anything, owner, mock.Anything).Return(int64(0), nil)
+	mockService.On("MarkAsRead", mock.Anything, notificationID, owner).Return(nil)
+	mockService.On("MarkAllAsRead", mock.Anything, owner, mock.Anything, mock.Anything).Return(int64(0), nil)
+	mockService.On("GetNotification", mock.Anything, notificationID).
+		Return(&models.NotificationDetail{Notification: models.Notification{ID: notificationID, UserID: owner}}, nil)
+	mockService.On("CancelNotification", mock.Anything, notificationID).
+		Return(&models.Notification{ID: notificationID, UserID: owner, Status: models.StatusCancelled}, nil)
+	mockService.On("UpdateUserPreferences", mock.Anything, owner, mock.Anything).
+		Return(&models.UserNotificationPreferences{UserID: owner}, nil)
+	mockService.On("PatchUserPreferences", mock.Anything, owner, mock.Anything).
+		Return(&models.UserNotificationPreferences{UserID: owner}, nil)
+	mockService.On("UpdateUserPreferencesBulk", mock.Anything, owner, mock.Anything).Return([]models.UserNotificationPreferences{}, nil)
+	mockService.On("GetUserPreferences", mock.Anything, owner).Return([]models.UserNotificationPreferences{}, nil)
+	mockService.On("GetUserPreference", mock.Anything, owner, models.DailyReminder, models.ChannelInApp).
+		Return(&models.ResolvedPreference{}, nil)
+	mockService.On("DeleteUserPreferences", mock.Anything, owner).Return(int64(0), nil)
+
+	preference := `{"type":"daily_reminder","channel":"in_app","enabled":true}`
+	assertRequiresOwnToken(t, router, owner, []ownTokenRoute{
+		{"by dedupe key", http.MethodGet, "/notifications/by-dedupe-key?key=daily-reminder:2024-03-12&userID=" + owner.String(), "", http.StatusOK},
+		// As with cancel, the notification's user is the owner
+		{"get notification", http.MethodGet, "/notifications/id/" + notificationID.String(), "", http.StatusOK},
+		{"list notifications", http.MethodGet, "/notifications/" + owner.String(), "", http.StatusOK},
+		{"unread count", http.MethodGet, "/notifications/" + owner.String() + "/unread-count", "", http.StatusOK},
+		{"mark as read", http.MethodPut, "/notifications/" + notificationID.String() + "/read?userID=" + owner.String(), "", http.StatusOK},
+		{"mark all as read", http.MethodPut, "/notifications/" + owner.String() + "/read-all", "", http.StatusOK},
+		// The notification's user is the owner here, not a path segment
+		{"cancel", http.MethodDelete, "/notifications/" + notificationID.String(), "", http.StatusOK},
+		{"update preferences", http.MethodPut, "/preferences/" + owner.String(), preference, http.StatusOK},
+		{"patch preferences", http.MethodPatch, "/preferences/" + owner.String(), preference, http.StatusOK},
+		{"bulk preferences", http.MethodPut, "/preferences/" + owner.String() + "/bulk", "[" + preference + "]", http.StatusOK},
+		{"get preferences", http.MethodGet, "/preferences/" + owner.String(), "", http.StatusOK},
+		{"get preference", http.MethodGet, "/preferences/" + owner.String() + "/daily_reminder/in_app", "", http.StatusOK},
+		{"delete preferences", http.MethodDelete, "/preferences/" + owner.String(), "", http.StatusOK},
+	})
+	// Only the owner's and the admin's requests got as far as cancelling
+	mockService.AssertNumberOfCalls(t, "CancelNotification", 2)
+}
+
+func TestServiceRoutes_RequireAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockNotificationService)
+	mockStreaks := new(MockStreakService)
+	registry := events.NewRegistry(events.Services{Notifications: mockService, Streaks: mockStreaks})
+	registry.Register(events.PracticeCompleted, events.HandlePracticeCompleted)
+	registry.Register(events.LevelUp, events.HandleLevelUp)
+	h := NewNotificationHandlers(mockService)
+	eventHandlers := NewEventHandlers(registry)
+
+	const key = "internal-key-0123456789abcdef"
+	router := gin.New()
+	internal := router.Group("", middleware.APIKeyAuth([]string{key}))
+	internal.POST("/notifications", h.CreateNotification)
+	internal.POST("/notifications/from-template", h.CreateFromTemplate)
+	internal.POST("/events", eventHandlers.IngestEvent)
+	internal.POST("/events/practice-completed", eventHandlers.PracticeCompleted)
+
+	userID := uuid.New()
+	mockService.On("CreateNotification", mock.Anything, mock.Anything).Return(&models.Notification{ID: uuid.New(), UserID: userID}, nil)
+	mockService.On("CreateFromTemplate", mock.Anything, userID, models.DailyReminder, models.ChannelInApp, mock.Anything).
+		Return(&models.Notification{ID: uuid.New(), UserID: userID}, nil)
+	mockStreaks.On("RecordActivity", mock.Anything, userID, services.PracticeStreakType, mock.AnythingOfType("time.Time")).
+		Return(&models.UserEngagementStreak{UserID: userID, CurrentStreak: 1}, nil)
+
+	routes := []struct {
+		name, path, body string
+		ok               int
+	}{
+		{"create", "/notifications", `{"user_id":"` + userID.String() + `","type":"daily_reminder","channel":"in_app","priority":"low","message":"hi"}`, http.StatusCreated},
+		{"from template", "/notifications/from-template", `{"user_id":"` + userID.String() + `","type":"daily_reminder","channel":"in_app"}`, http.StatusCreated},
+		{"ingest event", "/events", `{"event_type":"level-up","user_id":"` + userID.String() + `","payload":{"level":4}}`, http.StatusOK},
+		{"practice completed", "/events/practice-completed", `{"user_id":"` + userID.String() + `"}`, http.StatusCreated},
+	}
+	callers := []struct {
+		name, key string
+		want      int
+	}{
+		{name: "no key", want: http.StatusUnauthorized},
+		{name: "unknown key", key: "not-the-internal-key-at-all", want: http.StatusUnauthorized},
+		{name: "internal key", key: key},
+	}
+
+	for _, route := range routes {
+		for _, caller := range callers {
+			t.Run(route.name+"/"+caller.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, route.path, strings.NewReader(route.body))
+				req.Header.Set("Content-Type", "application/json")
+				if caller.key != "" {
+					req.Header.Set(middleware.APIKeyHeader, caller.key)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				want := caller.want
+				if want == 0 {
+					want = route.ok
+				}
+				assert.Equal(t, want, w.Code, w.Body.String())
+			})
+		}
+	}
+	// Only requests with the key reached the service
+	mockService.AssertNumberOfCalls(t, "CreateFromTemplate", 1)
+	mockStreaks.AssertNumberOfCalls(t, "RecordActivity", 1)
+}
+
+func TestMarkAsRead_DefaultsToTokenSubject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockNotificationService)
+	router := gin.New()
+	router.PUT("/notifications/:id/read", testAuth(t), NewNotificationHandlers(mockService).MarkAsRead)
+
+	owner := uuid.New()
+	notificationID := uuid.New()
+	mockService.On("MarkAsRead", mock.Anything, notificationID, owner).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/notifications/"+notificationID.String()+"/read", nil)
+	req.Header.Set("Authorization", userToken(t, owner, ""))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
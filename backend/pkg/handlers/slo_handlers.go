@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"kafka-notify/internal/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLOHandlers handles HTTP requests for delivery latency SLO reporting
+type SLOHandlers struct {
+	monitor *slo.Monitor
+}
+
+// NewSLOHandlers creates new SLO handlers
+func NewSLOHandlers(monitor *slo.Monitor) *SLOHandlers {
+	return &SLOHandlers{
+		monitor: monitor,
+	}
+}
+
+// GetSLO handles GET /admin/slo
+func (h *SLOHandlers) GetSLO(c *gin.Context) {
+	report := h.monitor.Latest()
+	if report == nil || c.Query("refresh") == "true" {
+		var err error
+		report, err = h.monitor.Refresh(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute SLO",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StreakHandlers handles HTTP requests for engagement streaks
+type StreakHandlers struct {
+	streakService services.StreakService
+}
+
+// NewStreakHandlers creates new streak handlers
+func NewStreakHandlers(streakService services.StreakService) *StreakHandlers {
+	return &StreakHandlers{
+		streakService: streakService,
+	}
+}
+
+// GetStreak handles GET /streaks/:userID
+func (h *StreakHandlers) GetStreak(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	streakType := c.DefaultQuery("type", services.PracticeStreakType)
+	streak, err := h.streakService.GetStreak(c.Request.Context(), userID, streakType)
+	if err != nil {
+		respondError(c, "Failed to retrieve streak", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": streak,
+	})
+}
+
+// RecordActivity handles POST /streaks/:userID/activity
+func (h *StreakHandlers) RecordActivity(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	streakType := c.DefaultQuery("type", services.PracticeStreakType)
+	streak, err := h.streakService.RecordActivity(c.Request.Context(), userID, streakType, time.Now())
+	if err != nil {
+		respondError(c, "Failed to record activity", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Activity recorded",
+		"data":    streak,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/internal/services"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupStreakRouter(h *StreakHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Auth is off here as in development; TestStreakRoutes_RequireOwnToken covers it
+	api := router.Group("/api/v1", middleware.Auth(nil))
+	api.GET("/streaks/:userID", h.GetStreak)
+	api.POST("/streaks/:userID/activity", h.RecordActivity)
+	return router
+}
+
+func TestGetStreak(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name        string
+		query       string
+		setup       func(m *MockStreakService)
+		wantStatus  int
+		wantCurrent int
+	}{
+		{
+			name:  "defaults to the practice streak",
+			query: "",
+			setup: func(m *MockStreakService) {
+				m.On("GetStreak", mock.Anything, userID, services.PracticeStreakType).
+					Return(&models.UserEngagementStreak{UserID: userID, StreakType: "practice", CurrentStreak: 6, LongestStreak: 10}, nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantCurrent: 6,
+		},
+		{
+			name:  "no activity yet",
+			query: "?type=practice",
+			setup: func(m *MockStreakService) {
+				m.On("GetStreak", mock.Anything, userID, services.PracticeStreakType).
+					Return(&models.UserEngagementStreak{UserID: userID, StreakType: "practice"}, nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantCurrent: 0,
+		},
+		{
+			name:  "unknown streak type",
+			query: "?type=juggling",
+			setup: func(m *MockStreakService) {
+				m.On("GetStreak", mock.Anything, userID, "juggling").
+					Return(nil, fmt.Errorf("%w: %q", services.ErrInvalidStreakType, "juggling"))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStreaks := new(MockStreakService)
+			tt.setup(mockStreaks)
+			router := setupStreakRouter(NewStreakHandlers(mockStreaks))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/streaks/"+userID.String()+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var resp struct {
+					Data map[string]interface{} `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, float64(tt.wantCurrent), resp.Data["current_streak"])
+			}
+			mockStreaks.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetStreak_InvalidUserID(t *testing.T) {
+	mockStreaks := new(MockStreakService)
+	router := setupStreakRouter(NewStreakHandlers(mockStreaks))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/streaks/not-a-uuid", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStreaks.AssertNotCalled(t, "GetStreak", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRecordActivity(t *testing.T) {
+	mockStreaks := new(MockStreakService)
+	router := setupStreakRouter(NewStreakHandlers(mockStreaks))
+
+	userID := uuid.New()
+	mockStreaks.On("RecordActivity", mock.Anything, userID, services.PracticeStreakType, mock.AnythingOfType("time.Time")).
+		Return(&models.UserEngagementStreak{UserID: userID, StreakType: "practice", CurrentStreak: 3, LongestStreak: 3}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streaks/"+userID.String()+"/activity", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data models.UserEngagementStreak `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Data.CurrentStreak)
+	mockStreaks.AssertExpectations(t)
+}
+
+func TestRecordActivity_UnknownStreakType(t *testing.T) {
+	mockStreaks := new(MockStreakService)
+	router := setupStreakRouter(NewStreakHandlers(mockStreaks))
+
+	userID := uuid.New()
+	mockStreaks.On("RecordActivity", mock.Anything, userID, "juggling", mock.AnythingOfType("time.Time")).
+		Return(nil, services.ErrInvalidStreakType)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streaks/"+userID.String()+"/activity?type=juggling", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockStreaks.AssertExpectations(t)
+}
+
+func TestStreakRoutes_RequireOwnToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStreaks := new(MockStreakService)
+	h := NewStreakHandlers(mockStreaks)
+	router := gin.New()
+	user := router.Group("", testAuth(t))
+	user.GET("/streaks/:userID", h.GetStreak)
+	user.POST("/streaks/:userID/activity", h.RecordActivity)
+
+	owner := uuid.New()
+	streak := &models.UserEngagementStreak{UserID: owner, StreakType: services.PracticeStreakType}
+	mockStreaks.On("GetStreak", mock.Anything, owner, services.PracticeStreakType).Return(streak, nil)
+	mockStreaks.On("RecordActivity", mock.Anything, owner, services.PracticeStreakType, mock.AnythingOfType("time.Time")).Return(streak, nil)
+
+	assertRequiresOwnToken(t, router, owner, []ownTokenRoute{
+		{"get streak", http.MethodGet, "/streaks/" + owner.String(), "", http.StatusOK},
+		{"record activity", http.MethodPost, "/streaks/" + owner.String() + "/activity", "", http.StatusOK},
+	})
+}
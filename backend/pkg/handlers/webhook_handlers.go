@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookRegistry stores users' webhook endpoints
+type WebhookRegistry interface {
+	UpsertWebhook(ctx context.Context, webhook *models.UserWebhook) (*models.UserWebhook, error)
+}
+
+// WebhookHandlers handles HTTP requests for webhook endpoints
+type WebhookHandlers struct {
+	webhooks WebhookRegistry
+}
+
+// NewWebhookHandlers creates new webhook handlers
+func NewWebhookHandlers(webhooks WebhookRegistry) *WebhookHandlers {
+	return &WebhookHandlers{
+		webhooks: webhooks,
+	}
+}
+
+// RegisterWebhook handles POST /webhooks/:userID
+func (h *WebhookHandlers) RegisterWebhook(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID format",
+		})
+		return
+	}
+	if !middleware.AuthorizeUser(c, userID) {
+		return
+	}
+
+	var req models.RegisterWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	webhook, err := h.webhooks.UpsertWebhook(c.Request.Context(), &models.UserWebhook{
+		UserID: userID,
+		URL:    req.URL,
+		Secret: req.Secret,
+	})
+	if err != nil {
+		respondError(c, "Failed to register webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook registered",
+		"data":    webhook,
+	})
+}
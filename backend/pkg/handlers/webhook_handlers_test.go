@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kafka-notify/internal/middleware"
+	"kafka-notify/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookRegistry is a mock implementation of WebhookRegistry
+type MockWebhookRegistry struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRegistry) UpsertWebhook(ctx context.Context, webhook *models.UserWebhook) (*models.UserWebhook, error) {
+	args := m.Called(ctx, webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserWebhook), args.Error(1)
+}
+
+func setupWebhookRouter(h *WebhookHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Auth is off here as in development; TestWebhookRoutes_RequireOwnToken covers it
+	api := router.Group("/api/v1", middleware.Auth(nil))
+	api.POST("/webhooks/:userID", h.RegisterWebhook)
+	return router
+}
+
+func TestRegisterWebhook_HidesSecret(t *testing.T) {
+	webhooks := new(MockWebhookRegistry)
+	router := setupWebhookRouter(NewWebhookHandlers(webhooks))
+	userID := uuid.New()
+
+	webhooks.On("UpsertWebhook", mock.Anything, &models.UserWebhook{UserID: userID, URL: "https://crm.example.com/hook", Secret: "0123456789abcdef"}).
+		Return(&models.UserWebhook{UserID: userID, URL: "https://crm.example.com/hook", Secret: "0123456789abcdef"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/"+userID.String(),
+		strings.NewReader(`{"url": "https://crm.example.com/hook", "secret": "0123456789abcdef"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "https://crm.example.com/hook")
+	assert.NotContains(t, w.Body.String(), "0123456789abcdef")
+	webhooks.AssertExpectations(t)
+}
+
+func TestRegisterWebhook_InvalidBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "not a url", body: `{"url": "crm hook", "secret": "0123456789abcdef"}`},
+		{name: "short secret", body: `{"url": "https://crm.example.com/hook", "secret": "short"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhooks := new(MockWebhookRegistry)
+			router := setupWebhookRouter(NewWebhookHandlers(webhooks))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/"+uuid.NewString(), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			webhooks.AssertNotCalled(t, "UpsertWebhook", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestWebhookRoutes_RequireOwnToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	webhooks := new(MockWebhookRegistry)
+	h := NewWebhookHandlers(webhooks)
+	router := gin.New()
+	router.POST("/webhooks/:userID", testAuth(t), h.RegisterWebhook)
+
+	owner := uuid.New()
+	webhooks.On("UpsertWebhook", mock.Anything, mock.MatchedBy(func(w *models.UserWebhook) bool { return w.UserID == owner })).
+		Return(&models.UserWebhook{UserID: owner, URL: "https://crm.example.com/hook"}, nil)
+
+	assertRequiresOwnToken(t, router, owner, []ownTokenRoute{
+		{"register", http.MethodPost, "/webhooks/" + owner.String(), `{"url": "https://crm.example.com/hook", "secret": "0123456789abcdef"}`, http.StatusCreated},
+	})
+}
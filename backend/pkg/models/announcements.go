@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAnnouncement is returned when an announcement fails validation
+var ErrInvalidAnnouncement = apperr.New(apperr.ErrInvalidInput, "invalid announcement")
+
+// AnnouncementSegment selects which users an announcement goes to
+type AnnouncementSegment string
+
+const (
+	// SegmentAll targets every user
+	SegmentAll AnnouncementSegment = "all"
+	// SegmentMinStreak targets users whose current practice streak is at least the segment value
+	SegmentMinStreak AnnouncementSegment = "min_streak"
+	// SegmentInactiveDays targets users who have not practiced for at least the segment value in days
+	SegmentInactiveDays AnnouncementSegment = "inactive_days"
+)
+
+// AnnouncementStatus tracks an announcement's fanout
+type AnnouncementStatus string
+
+const (
+	AnnouncementPending   AnnouncementStatus = "pending"
+	AnnouncementSending   AnnouncementStatus = "sending"
+	AnnouncementCompleted AnnouncementStatus = "completed"
+)
+
+// Announcement is a new_course message broadcast to a segment of users
+type Announcement struct {
+	ID           int64               `json:"id" db:"id"`
+	Title        string              `json:"title" db:"title"`
+	Message      string              `json:"message" db:"message"`
+	Metadata     JSONMap             `json:"metadata,omitempty" db:"metadata"`
+	Segment      AnnouncementSegment `json:"segment" db:"segment"`
+	SegmentValue *int                `json:"segment_value,omitempty" db:"segment_value"`
+	Status       AnnouncementStatus  `json:"status" db:"status"`
+	// LastUserID is the last user the fanout reached; it resumes after them
+	LastUserID           *uuid.UUID `json:"last_user_id,omitempty" db:"last_user_id"`
+	NotificationsCreated int        `json:"notifications_created" db:"notifications_created"`
+	StartedAt            *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// AnnouncementSegmentFilter narrows an announcement to a segment of users
+type AnnouncementSegmentFilter struct {
+	Type  AnnouncementSegment `json:"type"`
+	Value *int                `json:"value,omitempty"`
+}
+
+// CreateAnnouncementRequest queues an announcement; without a segment it goes
+// to every user
+type CreateAnnouncementRequest struct {
+	Title    string                     `json:"title" binding:"required,max=255"`
+	Message  string                     `json:"message" binding:"required"`
+	Metadata JSONMap                    `json:"metadata,omitempty"`
+	Segment  *AnnouncementSegmentFilter `json:"segment,omitempty"`
+}
+
+// Validate checks the segment type and that the min_streak and
+// inactive_days segments have a positive value
+func (r *CreateAnnouncementRequest) Validate() error {
+	if r.Segment == nil {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	switch r.Segment.Type {
+	case SegmentAll:
+	case SegmentMinStreak, SegmentInactiveDays:
+		if r.Segment.Value == nil || *r.Segment.Value < 1 {
+			fields["segment.value"] = fmt.Sprintf("must be at least 1 for the %s segment", r.Segment.Type)
+		}
+	default:
+		fields["segment.type"] = fmt.Sprintf("must be all, min_streak or inactive_days, got %q", r.Segment.Type)
+	}
+
+	if len(fields) > 0 {
+		return &FieldErrors{Err: ErrInvalidAnnouncement, Fields: fields}
+	}
+	return nil
+}
+
+// Announcement returns the pending announcement the request describes
+func (r *CreateAnnouncementRequest) Announcement() *Announcement {
+	announcement := &Announcement{
+		Title:    r.Title,
+		Message:  r.Message,
+		Metadata: r.Metadata,
+		Segment:  SegmentAll,
+		Status:   AnnouncementPending,
+	}
+	if r.Segment != nil && r.Segment.Type != SegmentAll {
+		announcement.Segment = r.Segment.Type
+		announcement.SegmentValue = r.Segment.Value
+	}
+	return announcement
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAnnouncementRequest_Validate(t *testing.T) {
+	value := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		segment   *AnnouncementSegmentFilter
+		wantField string
+	}{
+		{"no segment", nil, ""},
+		{"all", &AnnouncementSegmentFilter{Type: SegmentAll}, ""},
+		{"min streak", &AnnouncementSegmentFilter{Type: SegmentMinStreak, Value: value(7)}, ""},
+		{"inactive days", &AnnouncementSegmentFilter{Type: SegmentInactiveDays, Value: value(30)}, ""},
+		{"min streak without a value", &AnnouncementSegmentFilter{Type: SegmentMinStreak}, "segment.value"},
+		{"inactive days of zero", &AnnouncementSegmentFilter{Type: SegmentInactiveDays, Value: value(0)}, "segment.value"},
+		{"unknown segment", &AnnouncementSegmentFilter{Type: "premium"}, "segment.type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := CreateAnnouncementRequest{Title: "New course", Message: "Spanish is here", Segment: tt.segment}
+
+			err := req.Validate()
+			if tt.wantField == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrs *FieldErrors
+			require.True(t, errors.As(err, &fieldErrs))
+			assert.Contains(t, fieldErrs.Fields, tt.wantField)
+			assert.ErrorIs(t, err, ErrInvalidAnnouncement)
+		})
+	}
+}
+
+func TestCreateAnnouncementRequest_Announcement(t *testing.T) {
+	streak := 7
+	req := CreateAnnouncementRequest{
+		Title:    "New course",
+		Message:  "Spanish is here",
+		Metadata: JSONMap{"course_id": "es-101"},
+		Segment:  &AnnouncementSegmentFilter{Type: SegmentMinStreak, Value: &streak},
+	}
+
+	announcement := req.Announcement()
+
+	assert.Equal(t, SegmentMinStreak, announcement.Segment)
+	assert.Equal(t, 7, *announcement.SegmentValue)
+	assert.Equal(t, AnnouncementPending, announcement.Status)
+	assert.Equal(t, "es-101", announcement.Metadata["course_id"])
+
+	req.Segment = nil
+	assert.Equal(t, SegmentAll, req.Announcement().Segment)
+	assert.Nil(t, req.Announcement().SegmentValue)
+}
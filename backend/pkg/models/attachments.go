@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"kafka-notify/pkg/apperr"
+)
+
+// AttachmentKind identifies how a client should render an attachment
+type AttachmentKind string
+
+const (
+	AttachmentImage AttachmentKind = "image"
+	AttachmentIcon  AttachmentKind = "icon"
+)
+
+// MaxAttachments is the most attachments a single notification may carry
+const MaxAttachments = 3
+
+// ErrInvalidAttachment is returned when an attachment fails validation
+var ErrInvalidAttachment = apperr.New(apperr.ErrInvalidInput, "invalid attachment")
+
+// Attachment is an image or icon referenced by URL, e.g. an achievement badge
+type Attachment struct {
+	Kind    AttachmentKind `json:"kind"`
+	URL     string         `json:"url"`
+	AltText string         `json:"alt_text,omitempty"`
+	Width   int            `json:"width,omitempty"`
+	Height  int            `json:"height,omitempty"`
+}
+
+// Attachments is a list of attachments stored as a JSONB array
+type Attachments []Attachment
+
+// Scan implements the sql.Scanner interface for JSONB
+func (a *Attachments) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("cannot scan %T into Attachments", value)
+	}
+}
+
+// Value implements the driver.Valuer interface for JSONB
+func (a Attachments) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Validate checks the attachment count and each attachment against the
+// allowed CDN hosts. Hosts are matched exactly and case-insensitively.
+func (a Attachments) Validate(allowedHosts []string) error {
+	if len(a) > MaxAttachments {
+		return fmt.Errorf("%w: at most %d attachments allowed, got %d", ErrInvalidAttachment, MaxAttachments, len(a))
+	}
+	for i, attachment := range a {
+		if err := attachment.validate(allowedHosts); err != nil {
+			return fmt.Errorf("%w: attachment %d: %v", ErrInvalidAttachment, i, err)
+		}
+	}
+	return nil
+}
+
+// validate checks a single attachment
+func (a Attachment) validate(allowedHosts []string) error {
+	switch a.Kind {
+	case AttachmentImage:
+		if strings.TrimSpace(a.AltText) == "" {
+			return fmt.Errorf("alt_text is required for images")
+		}
+	case AttachmentIcon:
+	default:
+		return fmt.Errorf("unknown kind %q", a.Kind)
+	}
+
+	if a.Width < 0 || a.Height < 0 {
+		return fmt.Errorf("width and height must not be negative")
+	}
+
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("url must use https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("url must not contain credentials")
+	}
+
+	host := u.Hostname()
+	for _, allowed := range allowedHosts {
+		if host != "" && strings.EqualFold(host, strings.TrimSpace(allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not an allowed attachment host", host)
+}
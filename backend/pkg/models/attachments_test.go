@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allowedHosts = []string{"cdn.example.com"}
+
+func badge() Attachment {
+	return Attachment{
+		Kind:    AttachmentImage,
+		URL:     "https://cdn.example.com/badges/streak-30.png",
+		AltText: "30 day streak badge",
+		Width:   128,
+		Height:  128,
+	}
+}
+
+func TestAttachments_DatabaseRoundTrip(t *testing.T) {
+	original := Attachments{badge(), {Kind: AttachmentIcon, URL: "https://cdn.example.com/icons/flame.svg"}}
+
+	value, err := original.Value()
+	require.NoError(t, err)
+
+	var scanned Attachments
+	require.NoError(t, scanned.Scan(value))
+	assert.Equal(t, original, scanned)
+
+	var empty Attachments
+	value, err = empty.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	require.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+}
+
+func TestNotification_AttachmentsJSONRoundTrip(t *testing.T) {
+	original := Notification{Message: "Badge unlocked", Attachments: Attachments{badge()}}
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"alt_text":"30 day streak badge"`)
+
+	var decoded Notification
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, original.Attachments, decoded.Attachments)
+}
+
+func TestAttachments_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		attachments Attachments
+		wantErr     string
+	}{
+		{name: "valid image and icon", attachments: Attachments{badge(), {Kind: AttachmentIcon, URL: "https://CDN.example.com/i.svg"}}},
+		{name: "empty", attachments: nil},
+		{name: "too many", attachments: Attachments{badge(), badge(), badge(), badge()}, wantErr: "at most 3"},
+		{name: "host not allow-listed", attachments: Attachments{{Kind: AttachmentIcon, URL: "https://evil.example.net/i.svg"}}, wantErr: "not an allowed attachment host"},
+		{name: "allowed host as subdomain suffix", attachments: Attachments{{Kind: AttachmentIcon, URL: "https://cdn.example.com.evil.net/i.svg"}}, wantErr: "not an allowed attachment host"},
+		{name: "plain http", attachments: Attachments{{Kind: AttachmentIcon, URL: "http://cdn.example.com/i.svg"}}, wantErr: "https"},
+		{name: "credentials in url", attachments: Attachments{{Kind: AttachmentIcon, URL: "https://user:pw@cdn.example.com/i.svg"}}, wantErr: "credentials"},
+		{name: "image without alt text", attachments: Attachments{{Kind: AttachmentImage, URL: "https://cdn.example.com/b.png"}}, wantErr: "alt_text"},
+		{name: "unknown kind", attachments: Attachments{{Kind: "video", URL: "https://cdn.example.com/v.mp4"}}, wantErr: "unknown kind"},
+		{name: "negative size", attachments: Attachments{{Kind: AttachmentIcon, URL: "https://cdn.example.com/i.svg", Width: -1}}, wantErr: "negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.attachments.Validate(allowedHosts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidAttachment)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestAttachments_ValidateRejectsAllWithoutAllowedHosts(t *testing.T) {
+	err := Attachments{badge()}.Validate(nil)
+	assert.ErrorIs(t, err, ErrInvalidAttachment)
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DevicePlatform is the platform a push device token was issued on
+type DevicePlatform string
+
+const (
+	PlatformIOS     DevicePlatform = "ios"
+	PlatformAndroid DevicePlatform = "android"
+	PlatformWeb     DevicePlatform = "web"
+)
+
+// UserDevice is a push device token registered for a user
+type UserDevice struct {
+	ID        int64          `json:"id" db:"id"`
+	UserID    uuid.UUID      `json:"user_id" db:"user_id"`
+	Token     string         `json:"token" db:"token"`
+	Platform  DevicePlatform `json:"platform" db:"platform"`
+	Active    bool           `json:"active" db:"active"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// RegisterDeviceRequest registers a push device token for a user
+type RegisterDeviceRequest struct {
+	Token    string         `json:"token" binding:"required,max=4096"`
+	Platform DevicePlatform `json:"platform" binding:"required,oneof=ios android web"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NotificationEventSchemaVersion is the envelope version written by BuildOutboxEntry.
+// Payloads without a schema_version are the legacy flat notification map.
+const NotificationEventSchemaVersion = 1
+
+// EventNotificationCreated is the event type for a newly queued notification
+const EventNotificationCreated = "notification.created"
+
+// NotificationEvent is the envelope published to Kafka for a notification
+type NotificationEvent struct {
+	SchemaVersion int                 `json:"schema_version"`
+	EventType     string              `json:"event_type"`
+	OccurredAt    time.Time           `json:"occurred_at"`
+	RequestID     string              `json:"request_id,omitempty"`
+	Notification  NotificationMessage `json:"notification"`
+}
+
+// NewNotificationEvent wraps a notification in the current envelope version
+func NewNotificationEvent(notification *Notification) NotificationEvent {
+	return NotificationEvent{
+		SchemaVersion: NotificationEventSchemaVersion,
+		EventType:     EventNotificationCreated,
+		OccurredAt:    time.Now().UTC(),
+		Notification:  NewNotificationMessage(notification),
+	}
+}
+
+// BuildOutboxEntry builds the outbox entry that publishes a notification to topic
+func BuildOutboxEntry(notification *Notification, topic string) (*OutboxNotification, error) {
+	payload, err := toJSONMap(NewNotificationEvent(notification))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build outbox payload for notification %s: %w", notification.ID, err)
+	}
+
+	return &OutboxNotification{
+		NotificationID: notification.ID,
+		Topic:          topic,
+		Payload:        payload,
+		Published:      false,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// toJSONMap converts a struct to the map form stored in JSONB columns
+func toJSONMap(v interface{}) (JSONMap, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m JSONMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOutboxEntry_WrapsNotificationInEnvelope(t *testing.T) {
+	title := "Don't Break Your Streak!"
+	notification := &Notification{
+		ID:        NewNotificationID(),
+		UserID:    uuid.New(),
+		Type:      StreakReminder,
+		Channel:   ChannelInApp,
+		Priority:  PriorityHigh,
+		Title:     &title,
+		Message:   "Your 5-day streak is at risk",
+		Status:    StatusQueued,
+		CreatedAt: time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC),
+	}
+
+	entry, err := BuildOutboxEntry(notification, "notifications")
+
+	require.NoError(t, err)
+	assert.Equal(t, notification.ID, entry.NotificationID)
+	assert.Equal(t, "notifications", entry.Topic)
+	assert.False(t, entry.Published)
+	assert.EqualValues(t, NotificationEventSchemaVersion, entry.Payload["schema_version"])
+	assert.Equal(t, EventNotificationCreated, entry.Payload["event_type"])
+
+	// The stored payload decodes back into the typed envelope
+	data, err := json.Marshal(entry.Payload)
+	require.NoError(t, err)
+	var event NotificationEvent
+	require.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, NotificationEventSchemaVersion, event.SchemaVersion)
+	assert.False(t, event.OccurredAt.IsZero())
+	assert.Equal(t, notification.ID.String(), event.Notification.ID)
+	assert.Equal(t, notification.UserID.String(), event.Notification.UserID)
+	assert.Equal(t, title, *event.Notification.Title)
+	assert.Equal(t, "2024-03-12T09:00:00Z", event.Notification.CreatedAt)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+)
+
+// ErrInvalidFilter is returned when a notification list filter fails validation
+var ErrInvalidFilter = apperr.New(apperr.ErrInvalidInput, "invalid notification filter")
+
+// NotificationSort orders a user's notification list
+type NotificationSort string
+
+const (
+	// SortNewest lists the most recent notifications first (the default)
+	SortNewest NotificationSort = "newest"
+	// SortOldest lists the oldest notifications first
+	SortOldest NotificationSort = "oldest"
+	// SortPriority lists urgent notifications first, newest first within a priority
+	SortPriority NotificationSort = "priority"
+)
+
+// NotificationFilter narrows and orders a user's notification list. Empty
+// fields do not filter; several values in a list match any of them.
+type NotificationFilter struct {
+	Types      []NotificationType    `json:"types,omitempty"`
+	Statuses   []DeliveryStatus      `json:"statuses,omitempty"`
+	Channels   []NotificationChannel `json:"channels,omitempty"`
+	UnreadOnly bool                  `json:"unread_only"`
+	Since      *time.Time            `json:"since,omitempty"`
+	Until      *time.Time            `json:"until,omitempty"`
+	Sort       NotificationSort      `json:"sort"`
+	Limit      int                   `json:"-"`
+	Offset     int                   `json:"-"`
+}
+
+// DefaultNotificationLimit is the page size when a filter sets none
+const DefaultNotificationLimit = 50
+
+// WithDefaults returns the filter with the default sort and page size filled in
+// and a negative offset cleared
+func (f NotificationFilter) WithDefaults() NotificationFilter {
+	if f.Sort == "" {
+		f.Sort = SortNewest
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultNotificationLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
+
+// Validate rejects unknown types, statuses, channels and sorts, and a since
+// that is after until
+func (f NotificationFilter) Validate() error {
+	for _, t := range f.Types {
+		if !IsValidNotificationType(t) {
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, t)
+		}
+	}
+	for _, s := range f.Statuses {
+		if !IsValidStatus(s) {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, s)
+		}
+	}
+	for _, c := range f.Channels {
+		if !IsValidChannel(c) {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidFilter, c)
+		}
+	}
+	switch f.Sort {
+	case "", SortNewest, SortOldest, SortPriority:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, f.Sort)
+	}
+	if f.Since != nil && f.Until != nil && f.Since.After(*f.Until) {
+		return fmt.Errorf("%w: since is after until", ErrInvalidFilter)
+	}
+	return nil
+}
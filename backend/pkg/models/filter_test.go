@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationFilter_Validate(t *testing.T) {
+	since := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		filter  NotificationFilter
+		wantErr string
+	}{
+		{"empty", NotificationFilter{}, ""},
+		{"combined", NotificationFilter{
+			Types:      []NotificationType{StreakReminder, DailyReminder},
+			Statuses:   []DeliveryStatus{StatusDelivered},
+			Channels:   []NotificationChannel{ChannelInApp},
+			UnreadOnly: true,
+			Since:      &since,
+			Until:      &until,
+			Sort:       SortPriority,
+		}, ""},
+		{"unknown type", NotificationFilter{Types: []NotificationType{DailyReminder, "nope"}}, `unknown type "nope"`},
+		{"unknown status", NotificationFilter{Statuses: []DeliveryStatus{"pending"}}, `unknown status "pending"`},
+		{"auto is not a stored channel", NotificationFilter{Channels: []NotificationChannel{ChannelAuto}}, `unknown channel "auto"`},
+		{"unknown sort", NotificationFilter{Sort: "alphabetical"}, `unknown sort "alphabetical"`},
+		{"inverted range", NotificationFilter{Since: &until, Until: &since}, "since is after until"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidFilter)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNotificationFilter_WithDefaults(t *testing.T) {
+	assert.Equal(t, NotificationFilter{Sort: SortNewest, Limit: DefaultNotificationLimit},
+		NotificationFilter{Limit: -1, Offset: -5}.WithDefaults())
+	assert.Equal(t, NotificationFilter{Sort: SortOldest, Limit: 10, Offset: 20},
+		NotificationFilter{Sort: SortOldest, Limit: 10, Offset: 20}.WithDefaults())
+}
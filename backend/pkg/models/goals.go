@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GoalType identifies what a user goal measures
+type GoalType string
+
+const (
+	// GoalWeeklyXP is a target for the XP earned in one Monday-to-Sunday week
+	GoalWeeklyXP GoalType = "weekly_xp"
+)
+
+// UserGoal is a target a user set for one week
+type UserGoal struct {
+	ID       int64     `json:"id" db:"id"`
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	GoalType GoalType  `json:"goal_type" db:"goal_type"`
+	Target   int       `json:"target" db:"target"`
+	// WeekStart is the Monday the goal's week starts on, as a UTC midnight
+	WeekStart time.Time `json:"week_start" db:"week_start"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SetGoalRequest sets a user's goal for the week starting on WeekStart, a
+// Monday in YYYY-MM-DD form
+type SetGoalRequest struct {
+	GoalType  GoalType `json:"goal_type" binding:"omitempty,oneof=weekly_xp"`
+	Target    int      `json:"target" binding:"required,min=1,max=1000000"`
+	WeekStart string   `json:"week_start" binding:"required"`
+}
+
+// UpdateGoalRequest changes the target of an existing goal
+type UpdateGoalRequest struct {
+	Target int `json:"target" binding:"required,min=1,max=1000000"`
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationMessage is a notification as it travels over Kafka. IDs and
+// timestamps are plain strings (UUIDs and RFC 3339) so the wire format does not
+// depend on how uuid.UUID or time.Time happen to marshal; ToNotification
+// checks them when the message is read back.
+type NotificationMessage struct {
+	ID           string              `json:"id"`
+	UserID       string              `json:"user_id"`
+	Type         NotificationType    `json:"type"`
+	Channel      NotificationChannel `json:"channel"`
+	Priority     PriorityLevel       `json:"priority"`
+	TemplateID   *int64              `json:"template_id,omitempty"`
+	Title        *string             `json:"title,omitempty"`
+	Message      string              `json:"message"`
+	Metadata     JSONMap             `json:"metadata,omitempty"`
+	Attachments  Attachments         `json:"attachments,omitempty"`
+	DedupeKey    *string             `json:"dedupe_key,omitempty"`
+	Status       DeliveryStatus      `json:"status,omitempty"`
+	CreatedAt    string              `json:"created_at"`
+	ScheduledFor *string             `json:"scheduled_for,omitempty"`
+}
+
+// NewNotificationMessage converts a notification to its wire form
+func NewNotificationMessage(n *Notification) NotificationMessage {
+	msg := NotificationMessage{
+		ID:          n.ID.String(),
+		UserID:      n.UserID.String(),
+		Type:        n.Type,
+		Channel:     n.Channel,
+		Priority:    n.Priority,
+		TemplateID:  n.TemplateID,
+		Title:       n.Title,
+		Message:     n.Message,
+		Metadata:    n.Metadata,
+		Attachments: n.Attachments,
+		DedupeKey:   n.DedupeKey,
+		Status:      n.Status,
+		CreatedAt:   n.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if n.ScheduledFor != nil {
+		scheduledFor := n.ScheduledFor.UTC().Format(time.RFC3339Nano)
+		msg.ScheduledFor = &scheduledFor
+	}
+	return msg
+}
+
+// ToNotification converts the message back to a notification, failing when an
+// ID is not a UUID or a timestamp is not RFC 3339
+func (m NotificationMessage) ToNotification() (Notification, error) {
+	id, err := uuid.Parse(m.ID)
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid notification id %q: %w", m.ID, err)
+	}
+	userID, err := uuid.Parse(m.UserID)
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid user_id %q: %w", m.UserID, err)
+	}
+	createdAt, err := parseWireTime(m.CreatedAt)
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid created_at: %w", err)
+	}
+
+	n := Notification{
+		ID:          id,
+		UserID:      userID,
+		Type:        m.Type,
+		Channel:     m.Channel,
+		Priority:    m.Priority,
+		TemplateID:  m.TemplateID,
+		Title:       m.Title,
+		Message:     m.Message,
+		Metadata:    m.Metadata,
+		Attachments: m.Attachments,
+		DedupeKey:   m.DedupeKey,
+		Status:      m.Status,
+		CreatedAt:   createdAt,
+	}
+	if m.ScheduledFor != nil {
+		scheduledFor, err := parseWireTime(*m.ScheduledFor)
+		if err != nil {
+			return Notification{}, fmt.Errorf("invalid scheduled_for: %w", err)
+		}
+		n.ScheduledFor = &scheduledFor
+	}
+	return n, nil
+}
+
+// parseWireTime parses an RFC 3339 timestamp; an empty one is the zero time
+func parseWireTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// EncodeOutboxPayload renders a stored outbox payload as the key and body of
+// the message published to Kafka. The key is the notification's user ID, so
+// every notification for a user lands on the same partition and is consumed
+// in order. Legacy flat payloads are upgraded to the current envelope, and
+// payloads that don't hold a valid notification are rejected rather than
+// published for the consumer to drop.
+func EncodeOutboxPayload(payload JSONMap) (string, []byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	event, err := parseNotificationEvent(data)
+	if err != nil {
+		return "", nil, err
+	}
+	n, err := event.Notification.ToNotification()
+	if err != nil {
+		return "", nil, err
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = n.CreatedAt
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal notification event: %w", err)
+	}
+	return n.UserID.String(), value, nil
+}
+
+// DecodeNotificationEvent reads the notification from a Kafka message body
+// written by EncodeOutboxPayload, or by producers that predate the envelope
+func DecodeNotificationEvent(value []byte) (Notification, error) {
+	event, err := parseNotificationEvent(value)
+	if err != nil {
+		return Notification{}, err
+	}
+	return event.Notification.ToNotification()
+}
+
+// parseNotificationEvent unmarshals a notification event, wrapping legacy
+// payloads without a schema_version in the current envelope
+func parseNotificationEvent(data []byte) (NotificationEvent, error) {
+	var event NotificationEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return NotificationEvent{}, fmt.Errorf("failed to decode notification event: %w", err)
+	}
+
+	switch event.SchemaVersion {
+	case 0:
+		var msg NotificationMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return NotificationEvent{}, fmt.Errorf("failed to decode legacy notification: %w", err)
+		}
+		return NotificationEvent{
+			SchemaVersion: NotificationEventSchemaVersion,
+			EventType:     EventNotificationCreated,
+			Notification:  msg,
+		}, nil
+	case NotificationEventSchemaVersion:
+		return event, nil
+	default:
+		return NotificationEvent{}, fmt.Errorf("unsupported notification schema version %d", event.SchemaVersion)
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storedPayload round-trips an outbox entry's payload through its JSONB column
+func storedPayload(t *testing.T, entry *OutboxNotification) JSONMap {
+	t.Helper()
+	value, err := entry.Payload.Value()
+	require.NoError(t, err)
+	var payload JSONMap
+	require.NoError(t, payload.Scan(value))
+	return payload
+}
+
+// TestNotificationMessage_ProducerConsumerContract runs a notification through
+// what the producer stores and publishes and what the consumer decodes
+func TestNotificationMessage_ProducerConsumerContract(t *testing.T) {
+	title := "Level up!"
+	templateID := int64(7)
+	dedupeKey := "level-5"
+	scheduledFor := time.Date(2024, 3, 12, 18, 30, 0, 0, time.FixedZone("IST", 19800))
+	notification := &Notification{
+		ID:           NewNotificationID(),
+		UserID:       uuid.New(),
+		Type:         AchievementUnlock,
+		Channel:      ChannelInApp,
+		Priority:     PriorityHigh,
+		TemplateID:   &templateID,
+		Title:        &title,
+		Message:      "You reached level 5",
+		Metadata:     JSONMap{"xp": 1250, "level": 5, "badges": []interface{}{"streak"}},
+		Attachments:  Attachments{{Kind: AttachmentIcon, URL: "https://cdn.example.com/level.png"}},
+		DedupeKey:    &dedupeKey,
+		Status:       StatusQueued,
+		CreatedAt:    time.Date(2024, 3, 12, 9, 0, 0, 123456789, time.UTC),
+		ScheduledFor: &scheduledFor,
+	}
+	entry, err := BuildOutboxEntry(notification, "notifications")
+	require.NoError(t, err)
+
+	key, value, err := EncodeOutboxPayload(storedPayload(t, entry))
+	require.NoError(t, err)
+	decoded, err := DecodeNotificationEvent(value)
+
+	require.NoError(t, err)
+	assert.Equal(t, notification.UserID.String(), key)
+	assert.Equal(t, notification.ID, decoded.ID)
+	assert.Equal(t, notification.UserID, decoded.UserID)
+	assert.Equal(t, notification.Type, decoded.Type)
+	assert.Equal(t, notification.Channel, decoded.Channel)
+	assert.Equal(t, notification.Priority, decoded.Priority)
+	assert.Equal(t, templateID, *decoded.TemplateID)
+	assert.Equal(t, title, *decoded.Title)
+	assert.Equal(t, notification.Message, decoded.Message)
+	assert.EqualValues(t, 1250, decoded.Metadata["xp"], "numbers in metadata decode as float64")
+	assert.Equal(t, []interface{}{"streak"}, decoded.Metadata["badges"])
+	assert.Equal(t, notification.Attachments, decoded.Attachments)
+	assert.Equal(t, dedupeKey, *decoded.DedupeKey)
+	assert.Equal(t, StatusQueued, decoded.Status)
+	assert.True(t, notification.CreatedAt.Equal(decoded.CreatedAt))
+	assert.True(t, scheduledFor.Equal(*decoded.ScheduledFor))
+}
+
+func TestEncodeOutboxPayload_UpgradesLegacyFlatPayload(t *testing.T) {
+	id := uuid.New()
+	legacy := JSONMap{
+		"id": id.String(), "user_id": uuid.NewString(), "type": "daily_reminder", "channel": "in_app",
+		"priority": "medium", "title": "Time to Practice!", "message": "Keep your streak alive",
+		"created_at": "2024-03-12T09:00:00Z", "sent_at": nil,
+	}
+
+	_, value, err := EncodeOutboxPayload(legacy)
+
+	require.NoError(t, err)
+	var event NotificationEvent
+	require.NoError(t, json.Unmarshal(value, &event))
+	assert.Equal(t, NotificationEventSchemaVersion, event.SchemaVersion)
+	assert.Equal(t, EventNotificationCreated, event.EventType)
+	assert.Equal(t, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), event.OccurredAt.UTC())
+	assert.Equal(t, id.String(), event.Notification.ID)
+}
+
+func TestEncodeOutboxPayload_RejectsInvalidNotification(t *testing.T) {
+	for name, payload := range map[string]JSONMap{
+		"bad id":         {"schema_version": 1, "notification": JSONMap{"id": "42", "user_id": uuid.NewString()}},
+		"numeric id":     {"schema_version": 1, "notification": JSONMap{"id": 42}},
+		"bad timestamp":  {"schema_version": 1, "notification": JSONMap{"id": uuid.NewString(), "user_id": uuid.NewString(), "created_at": "yesterday"}},
+		"future version": {"schema_version": 99, "notification": JSONMap{}},
+		"empty":          nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := EncodeOutboxPayload(payload)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeNotificationEvent_LegacyFlatPayload(t *testing.T) {
+	id := uuid.New()
+	value := []byte(`{"id":"` + id.String() + `","user_id":"` + uuid.NewString() + `","type":"daily_reminder","channel":"in_app","priority":"medium","title":"Time to Practice!","message":"Keep your streak alive","created_at":"2024-03-12T09:00:00Z"}`)
+
+	decoded, err := DecodeNotificationEvent(value)
+
+	require.NoError(t, err)
+	assert.Equal(t, id, decoded.ID)
+	assert.Equal(t, DailyReminder, decoded.Type)
+	assert.Equal(t, "Time to Practice!", *decoded.Title)
+}
+
+func TestDecodeNotificationEvent_UnknownSchemaVersion(t *testing.T) {
+	_, err := DecodeNotificationEvent([]byte(`{"schema_version":99,"notification":{}}`))
+
+	assert.ErrorContains(t, err, "unsupported notification schema version 99")
+}
@@ -57,12 +57,19 @@ const (
 	NewCourse         NotificationType = "new_course"
 	PracticeNeeded    NotificationType = "practice_needed"
 	WeeklyRecap       NotificationType = "weekly_recap"
+	// PreferencesUpdated confirms a change to the user's notification settings
+	PreferencesUpdated NotificationType = "preferences_updated"
 
 	// Notification Channels
 	ChannelInApp NotificationChannel = "in_app"
 	ChannelPush  NotificationChannel = "push"
 	ChannelEmail NotificationChannel = "email"
 	ChannelSMS   NotificationChannel = "sms"
+	// ChannelWebhook POSTs the notification to the URL the user registered
+	ChannelWebhook NotificationChannel = "webhook"
+	// ChannelAuto asks for one notification per channel the user enabled for the
+	// type; it is only valid on create requests, never stored
+	ChannelAuto NotificationChannel = "auto"
 
 	// Delivery Status
 	StatusQueued     DeliveryStatus = "queued"
@@ -71,6 +78,7 @@ const (
 	StatusFailed     DeliveryStatus = "failed"
 	StatusSuppressed DeliveryStatus = "suppressed"
 	StatusRead       DeliveryStatus = "read"
+	StatusCancelled  DeliveryStatus = "cancelled"
 
 	// Priority Levels
 	PriorityLow    PriorityLevel = "low"
@@ -118,6 +126,7 @@ type Notification struct {
 	Title        *string             `json:"title" db:"title"`
 	Message      string              `json:"message" db:"message"`
 	Metadata     JSONMap             `json:"metadata" db:"metadata"`
+	Attachments  Attachments         `json:"attachments,omitempty" db:"attachments"`
 	DedupeKey    *string             `json:"dedupe_key" db:"dedupe_key"`
 	CreatedAt    time.Time           `json:"created_at" db:"created_at"`
 	ScheduledFor *time.Time          `json:"scheduled_for" db:"scheduled_for"`
@@ -125,6 +134,11 @@ type Notification struct {
 	DeliveredAt  *time.Time          `json:"delivered_at" db:"delivered_at"`
 	ReadAt       *time.Time          `json:"read_at" db:"read_at"`
 	Status       DeliveryStatus      `json:"status" db:"status"`
+
+	// Denormalized from the latest notification_delivery_attempts row
+	LastAttemptNo     *int            `json:"last_attempt_no" db:"last_attempt_no"`
+	LastAttemptStatus *DeliveryStatus `json:"last_attempt_status" db:"last_attempt_status"`
+	LastAttemptAt     *time.Time      `json:"last_attempt_at" db:"last_attempt_at"`
 }
 
 // NotificationTemplate represents a notification template
@@ -139,6 +153,9 @@ type NotificationTemplate struct {
 	IsActive  bool                `json:"is_active" db:"is_active"`
 	Version   int                 `json:"version" db:"version"`
 	CreatedAt time.Time           `json:"created_at" db:"created_at"`
+
+	// DefaultAttachments are used when a request for this type carries none
+	DefaultAttachments Attachments `json:"default_attachments,omitempty" db:"default_attachments"`
 }
 
 // UserNotificationPreferences represents user notification preferences
@@ -157,6 +174,14 @@ type UserNotificationPreferences struct {
 	UpdatedAt       time.Time           `json:"updated_at" db:"updated_at"`
 }
 
+// ResolvedPreference is the preference that applies to a user's type and
+// channel. Default is set when the user has no stored row and the built-in
+// default (enabled, no quiet hours or limit) applies.
+type ResolvedPreference struct {
+	UserNotificationPreferences
+	Default bool `json:"default"`
+}
+
 // NotificationDeliveryAttempt represents a delivery attempt
 type NotificationDeliveryAttempt struct {
 	ID                int64          `json:"id" db:"id"`
@@ -166,10 +191,17 @@ type NotificationDeliveryAttempt struct {
 	ErrorCode         *string        `json:"error_code" db:"error_code"`
 	ErrorMessage      *string        `json:"error_message" db:"error_message"`
 	ProviderMessageID *string        `json:"provider_message_id" db:"provider_message_id"`
+	Provider          *string        `json:"provider" db:"provider"`
 	LatencyMs         *int           `json:"latency_ms" db:"latency_ms"`
 	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
 }
 
+// NotificationDetail is a notification together with its delivery attempts
+type NotificationDetail struct {
+	Notification
+	DeliveryAttempts []NotificationDeliveryAttempt `json:"delivery_attempts"`
+}
+
 // OutboxNotification represents a notification in the outbox for Kafka
 type OutboxNotification struct {
 	ID             int64      `json:"id" db:"id"`
@@ -179,21 +211,61 @@ type OutboxNotification struct {
 	Published      bool       `json:"published" db:"published"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	PublishedAt    *time.Time `json:"published_at" db:"published_at"`
+	ClaimedBy      *string    `json:"claimed_by" db:"claimed_by"`
+	ClaimedAt      *time.Time `json:"claimed_at" db:"claimed_at"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	LastError      *string    `json:"last_error" db:"last_error"`
+	Dead           bool       `json:"dead" db:"dead"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+}
+
+// DeliveryLatencySample holds the timestamps used to measure delivery latency SLOs
+type DeliveryLatencySample struct {
+	Priority    PriorityLevel `json:"priority" db:"priority"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	DeliveredAt *time.Time    `json:"delivered_at" db:"delivered_at"`
+	ReadAt      *time.Time    `json:"read_at" db:"read_at"`
+}
+
+// ReachedAt returns when the notification first reached the user (delivered or read)
+func (s DeliveryLatencySample) ReachedAt() *time.Time {
+	if s.DeliveredAt == nil {
+		return s.ReadAt
+	}
+	if s.ReadAt != nil && s.ReadAt.Before(*s.DeliveredAt) {
+		return s.ReadAt
+	}
+	return s.DeliveredAt
 }
 
 // UserEngagementStreak represents user engagement streaks
 type UserEngagementStreak struct {
-	ID               int64      `json:"id" db:"id"`
-	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
-	StreakType       string     `json:"streak_type" db:"streak_type"`
-	CurrentStreak    int        `json:"current_streak" db:"current_streak"`
-	LongestStreak    int        `json:"longest_streak" db:"longest_streak"`
-	LastActivityDate *time.Time `json:"last_activity_date" db:"last_activity_date"`
-	StreakStartDate  *time.Time `json:"streak_start_date" db:"streak_start_date"`
-	TotalActivities  int        `json:"total_activities" db:"total_activities"`
-	Timezone         string     `json:"timezone" db:"timezone"`
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+	ID                    int64      `json:"id" db:"id"`
+	UserID                uuid.UUID  `json:"user_id" db:"user_id"`
+	StreakType            string     `json:"streak_type" db:"streak_type"`
+	CurrentStreak         int        `json:"current_streak" db:"current_streak"`
+	LongestStreak         int        `json:"longest_streak" db:"longest_streak"`
+	LastActivityDate      *time.Time `json:"last_activity_date" db:"last_activity_date"`
+	StreakStartDate       *time.Time `json:"streak_start_date" db:"streak_start_date"`
+	TotalActivities       int        `json:"total_activities" db:"total_activities"`
+	Timezone              string     `json:"timezone" db:"timezone"`
+	TypicalHour           *int       `json:"typical_hour" db:"typical_hour"`
+	TypicalHourConfidence float64    `json:"typical_hour_confidence" db:"typical_hour_confidence"`
+	CreatedAt             time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// WeeklyActivitySummary aggregates a user's practice sessions over one
+// Monday-to-Sunday week in their timezone
+type WeeklyActivitySummary struct {
+	WeekStart         time.Time `json:"week_start"`
+	SessionsCompleted int       `json:"sessions_completed"`
+	XPEarned          int       `json:"xp_earned"`
+	ActiveDays        int       `json:"active_days"`
+	// LongestStreak is the most consecutive active days within the week
+	LongestStreak int `json:"longest_streak"`
+	// DailySessions counts sessions per day, Monday first
+	DailySessions [7]int `json:"daily_sessions"`
 }
 
 // ============== REQUEST/RESPONSE MODELS ==============
@@ -207,7 +279,72 @@ type CreateNotificationRequest struct {
 	Title        *string             `json:"title"`
 	Message      string              `json:"message" binding:"required"`
 	Metadata     JSONMap             `json:"metadata"`
+	Attachments  Attachments         `json:"attachments"`
+	DedupeKey    *string             `json:"dedupe_key"`
 	ScheduledFor *time.Time          `json:"scheduled_for"`
+
+	// IdempotencyKey comes from the Idempotency-Key header rather than the body
+	IdempotencyKey string `json:"-"`
+
+	// Backfill only: preserve the legacy ID and creation time
+	ID        *uuid.UUID `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// IsBackfill reports whether the request sets fields reserved for backfills
+func (r *CreateNotificationRequest) IsBackfill() bool {
+	return r.ID != nil || r.CreatedAt != nil
+}
+
+// CreateFromTemplateRequest represents a request to create a notification from
+// the newest active template for its type and channel
+type CreateFromTemplateRequest struct {
+	UserID  uuid.UUID              `json:"user_id" binding:"required"`
+	Type    NotificationType       `json:"type" binding:"required"`
+	Channel NotificationChannel    `json:"channel" binding:"required"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// DecisionReplayRequest asks for a user's notification decisions in [From, To)
+// to be re-evaluated against the current rules
+type DecisionReplayRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+	From   time.Time `json:"from" binding:"required"`
+	To     time.Time `json:"to" binding:"required"`
+
+	// Preferences replaces the user's stored preferences for a what-if replay
+	Preferences []UserNotificationPreferences `json:"preferences,omitempty"`
+}
+
+// OutboxPurgeRequest asks for published outbox items older than Before to be
+// deleted; without Before the configured retention window applies
+type OutboxPurgeRequest struct {
+	Before *time.Time `json:"before,omitempty"`
+}
+
+// OutboxStats summarises how well the outbox is draining
+type OutboxStats struct {
+	Pending           int `json:"pending"`
+	Dead              int `json:"dead"`
+	PublishedLastHour int `json:"published_last_hour"`
+	// OldestPendingAt is nil when nothing is waiting to be published
+	OldestPendingAt         *time.Time `json:"oldest_pending_at"`
+	OldestPendingAgeSeconds float64    `json:"oldest_pending_age_seconds"`
+}
+
+// MaintenanceMode is the system-wide switch that pauses automated notification
+// generation and outbox publishing during incident response
+type MaintenanceMode struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	SetBy   string     `json:"set_by,omitempty"`
+	SetAt   *time.Time `json:"set_at,omitempty"`
+}
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Reason  string `json:"reason"`
 }
 
 // UpdateNotificationRequest represents a request to update a notification
@@ -227,6 +364,19 @@ type NotificationPreferencesRequest struct {
 	QuietHoursStart *string             `json:"quiet_hours_start"`
 	QuietHoursEnd   *string             `json:"quiet_hours_end"`
 	MaxPerDay       *int                `json:"max_per_day"`
+	Metadata        JSONMap             `json:"metadata"`
+}
+
+// NotificationPreferencesPatch represents a partial preference update; omitted
+// fields keep their stored values
+type NotificationPreferencesPatch struct {
+	Type            NotificationType    `json:"type" binding:"required"`
+	Channel         NotificationChannel `json:"channel" binding:"required"`
+	Enabled         *bool               `json:"enabled"`
+	QuietHoursStart *string             `json:"quiet_hours_start"`
+	QuietHoursEnd   *string             `json:"quiet_hours_end"`
+	MaxPerDay       *int                `json:"max_per_day"`
+	Metadata        JSONMap             `json:"metadata"`
 }
 
 // ============== HELPER METHODS ==============
@@ -262,12 +412,22 @@ func (p PriorityLevel) GetPriority() int {
 	}
 }
 
+// NewNotificationID returns a time-ordered UUIDv7 so new rows stay close together
+// in the primary key index. Existing v4 IDs still parse as regular UUIDs.
+func NewNotificationID() uuid.UUID {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New()
+	}
+	return id
+}
+
 // IsValidNotificationType checks if the notification type is valid
 func IsValidNotificationType(nt NotificationType) bool {
 	validTypes := []NotificationType{
 		DailyReminder, StreakReminder, LastChanceAlert, AchievementUnlock,
 		XPGoalReminder, LeagueUpdate, WeMissYou, EventNotification,
-		NewCourse, PracticeNeeded, WeeklyRecap,
+		NewCourse, PracticeNeeded, WeeklyRecap, PreferencesUpdated,
 	}
 
 	for _, validType := range validTypes {
@@ -278,10 +438,24 @@ func IsValidNotificationType(nt NotificationType) bool {
 	return false
 }
 
+// IsValidPriority checks if the priority level is valid
+func IsValidPriority(p PriorityLevel) bool {
+	validPriorities := []PriorityLevel{
+		PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent,
+	}
+
+	for _, validPriority := range validPriorities {
+		if p == validPriority {
+			return true
+		}
+	}
+	return false
+}
+
 // IsValidChannel checks if the notification channel is valid
 func IsValidChannel(nc NotificationChannel) bool {
 	validChannels := []NotificationChannel{
-		ChannelInApp, ChannelPush, ChannelEmail, ChannelSMS,
+		ChannelInApp, ChannelPush, ChannelEmail, ChannelSMS, ChannelWebhook,
 	}
 
 	for _, validChannel := range validChannels {
@@ -291,3 +465,18 @@ func IsValidChannel(nc NotificationChannel) bool {
 	}
 	return false
 }
+
+// IsValidStatus checks if the delivery status is valid
+func IsValidStatus(ds DeliveryStatus) bool {
+	validStatuses := []DeliveryStatus{
+		StatusQueued, StatusSent, StatusDelivered, StatusFailed,
+		StatusSuppressed, StatusRead, StatusCancelled,
+	}
+
+	for _, validStatus := range validStatuses {
+		if ds == validStatus {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidPreferences is returned when a preferences update fails validation
+var ErrInvalidPreferences = apperr.New(apperr.ErrInvalidInput, "invalid preferences")
+
+// FieldErrors is a validation failure listing what is wrong with each invalid
+// field of a request, keyed by the field's JSON name
+type FieldErrors struct {
+	Err    error
+	Fields map[string]string
+}
+
+func (e *FieldErrors) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, len(names))
+	for i, name := range names {
+		problems[i] = name + " " + e.Fields[name]
+	}
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(problems, "; "))
+}
+
+func (e *FieldErrors) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the type and channel, the HH:MM quiet hours and that
+// max_per_day is not negative, reporting every invalid field at once
+func (r *NotificationPreferencesRequest) Validate() error {
+	fields := make(map[string]string)
+	checkPreferenceKey(fields, r.Type, r.Channel)
+	if r.QuietHoursStart != nil && !isClockTime(*r.QuietHoursStart) {
+		fields["quiet_hours_start"] = fmt.Sprintf("must be HH:MM, got %q", *r.QuietHoursStart)
+	}
+	if r.QuietHoursEnd != nil && !isClockTime(*r.QuietHoursEnd) {
+		fields["quiet_hours_end"] = fmt.Sprintf("must be HH:MM, got %q", *r.QuietHoursEnd)
+	}
+	if r.MaxPerDay != nil && *r.MaxPerDay < 0 {
+		fields["max_per_day"] = fmt.Sprintf("must not be negative, got %d", *r.MaxPerDay)
+	}
+
+	if len(fields) > 0 {
+		return &FieldErrors{Err: ErrInvalidPreferences, Fields: fields}
+	}
+	return nil
+}
+
+// ValidatePreferenceKey checks the type and channel that identify a preference
+func ValidatePreferenceKey(notificationType NotificationType, channel NotificationChannel) error {
+	fields := make(map[string]string)
+	checkPreferenceKey(fields, notificationType, channel)
+	if len(fields) > 0 {
+		return &FieldErrors{Err: ErrInvalidPreferences, Fields: fields}
+	}
+	return nil
+}
+
+// checkPreferenceKey records an invalid type or channel in fields
+func checkPreferenceKey(fields map[string]string, notificationType NotificationType, channel NotificationChannel) {
+	if !IsValidNotificationType(notificationType) {
+		fields["type"] = fmt.Sprintf("must be a known notification type, got %q", notificationType)
+	}
+	if !IsValidChannel(channel) {
+		fields["channel"] = fmt.Sprintf("must be one of in_app, push, email or sms, got %q", channel)
+	}
+}
+
+// ValidatePreferencesBatch validates every request of a bulk update and checks
+// that no type and channel appears twice. Fields are keyed by the entry's
+// index, such as "2.channel".
+func ValidatePreferencesBatch(reqs []NotificationPreferencesRequest) error {
+	fields := make(map[string]string)
+	if len(reqs) == 0 {
+		fields["preferences"] = "must contain at least one entry"
+	}
+
+	type key struct {
+		notificationType NotificationType
+		channel          NotificationChannel
+	}
+	seen := make(map[key]int, len(reqs))
+	for i := range reqs {
+		var fieldErrs *FieldErrors
+		if err := reqs[i].Validate(); errors.As(err, &fieldErrs) {
+			for name, problem := range fieldErrs.Fields {
+				fields[fmt.Sprintf("%d.%s", i, name)] = problem
+			}
+			continue
+		}
+
+		k := key{reqs[i].Type, reqs[i].Channel}
+		if first, ok := seen[k]; ok {
+			fields[fmt.Sprintf("%d.channel", i)] = fmt.Sprintf("repeats the type and channel of entry %d", first)
+			continue
+		}
+		seen[k] = i
+	}
+
+	if len(fields) > 0 {
+		return &FieldErrors{Err: ErrInvalidPreferences, Fields: fields}
+	}
+	return nil
+}
+
+// Apply merges the patch over the stored preference, or over an enabled
+// preference with no limits when current is nil
+func (p *NotificationPreferencesPatch) Apply(current *UserNotificationPreferences) *NotificationPreferencesRequest {
+	req := &NotificationPreferencesRequest{Type: p.Type, Channel: p.Channel, Enabled: true}
+	if current != nil {
+		req.Enabled = current.Enabled
+		req.QuietHoursStart = current.QuietHoursStart
+		req.QuietHoursEnd = current.QuietHoursEnd
+		req.MaxPerDay = current.MaxPerDay
+		req.Metadata = current.Metadata
+	}
+
+	if p.Enabled != nil {
+		req.Enabled = *p.Enabled
+	}
+	if p.QuietHoursStart != nil {
+		req.QuietHoursStart = p.QuietHoursStart
+	}
+	if p.QuietHoursEnd != nil {
+		req.QuietHoursEnd = p.QuietHoursEnd
+	}
+	if p.MaxPerDay != nil {
+		req.MaxPerDay = p.MaxPerDay
+	}
+	if p.Metadata != nil {
+		req.Metadata = p.Metadata
+	}
+	return req
+}
+
+// Preferences converts the request into the user's preference model
+func (r *NotificationPreferencesRequest) Preferences(userID uuid.UUID) *UserNotificationPreferences {
+	return &UserNotificationPreferences{
+		UserID:          userID,
+		Type:            r.Type,
+		Channel:         r.Channel,
+		Enabled:         r.Enabled,
+		QuietHoursStart: r.QuietHoursStart,
+		QuietHoursEnd:   r.QuietHoursEnd,
+		MaxPerDay:       r.MaxPerDay,
+		Metadata:        r.Metadata,
+	}
+}
+
+// isClockTime reports whether s is a 24-hour HH:MM time
+func isClockTime(s string) bool {
+	_, err := time.Parse("15:04", s)
+	return err == nil
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"kafka-notify/pkg/apperr"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPreferencesRequest_Validate(t *testing.T) {
+	clock := func(s string) *string { return &s }
+	count := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		req       NotificationPreferencesRequest
+		wantField string
+	}{
+		{"valid", NotificationPreferencesRequest{
+			Type: StreakReminder, Channel: ChannelPush, Enabled: true,
+			QuietHoursStart: clock("22:00"), QuietHoursEnd: clock("07:30"), MaxPerDay: count(0),
+		}, ""},
+		{"unknown type", NotificationPreferencesRequest{Type: "banana", Channel: ChannelPush}, "type"},
+		{"auto channel", NotificationPreferencesRequest{Type: StreakReminder, Channel: ChannelAuto}, "channel"},
+		{"unknown channel", NotificationPreferencesRequest{Type: StreakReminder, Channel: "fax"}, "channel"},
+		{"start out of range", NotificationPreferencesRequest{Type: StreakReminder, Channel: ChannelPush, QuietHoursStart: clock("25:99")}, "quiet_hours_start"},
+		{"start with seconds", NotificationPreferencesRequest{Type: StreakReminder, Channel: ChannelPush, QuietHoursStart: clock("22:00:00")}, "quiet_hours_start"},
+		{"end not a time", NotificationPreferencesRequest{Type: StreakReminder, Channel: ChannelPush, QuietHoursEnd: clock("morning")}, "quiet_hours_end"},
+		{"negative max per day", NotificationPreferencesRequest{Type: StreakReminder, Channel: ChannelPush, MaxPerDay: count(-1)}, "max_per_day"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantField == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrs *FieldErrors
+			require.True(t, errors.As(err, &fieldErrs))
+			assert.Len(t, fieldErrs.Fields, 1)
+			assert.Contains(t, fieldErrs.Fields, tt.wantField)
+			assert.ErrorIs(t, err, ErrInvalidPreferences)
+			assert.ErrorIs(t, err, apperr.ErrInvalidInput)
+		})
+	}
+}
+
+func TestNotificationPreferencesRequest_ValidateReportsEveryField(t *testing.T) {
+	start, maxPerDay := "7pm", -3
+	req := NotificationPreferencesRequest{Type: "banana", Channel: ChannelPush, QuietHoursStart: &start, MaxPerDay: &maxPerDay}
+
+	err := req.Validate()
+
+	var fieldErrs *FieldErrors
+	require.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, []string{"max_per_day", "quiet_hours_start", "type"}, sortedKeys(fieldErrs.Fields))
+	assert.Equal(t, `invalid preferences: max_per_day must not be negative, got -3; `+
+		`quiet_hours_start must be HH:MM, got "7pm"; type must be a known notification type, got "banana"`, err.Error())
+}
+
+func TestNotificationPreferencesRequest_Preferences(t *testing.T) {
+	userID := uuid.New()
+	start := "22:00"
+	req := NotificationPreferencesRequest{
+		Type: StreakReminder, Channel: ChannelEmail, Enabled: true,
+		QuietHoursStart: &start, Metadata: JSONMap{"source": "settings"},
+	}
+
+	prefs := req.Preferences(userID)
+
+	assert.Equal(t, userID, prefs.UserID)
+	assert.Equal(t, StreakReminder, prefs.Type)
+	assert.Equal(t, ChannelEmail, prefs.Channel)
+	assert.True(t, prefs.Enabled)
+	assert.Equal(t, &start, prefs.QuietHoursStart)
+	assert.Equal(t, "settings", prefs.Metadata["source"])
+}
+
+func TestValidatePreferencesBatch(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		name       string
+		reqs       []NotificationPreferencesRequest
+		wantFields map[string]string
+	}{
+		{"valid", []NotificationPreferencesRequest{
+			{Type: StreakReminder, Channel: ChannelPush},
+			{Type: StreakReminder, Channel: ChannelEmail},
+		}, nil},
+		{"empty", nil, map[string]string{"preferences": "must contain at least one entry"}},
+		{"invalid entry is keyed by index", []NotificationPreferencesRequest{
+			{Type: StreakReminder, Channel: ChannelPush},
+			{Type: StreakReminder, Channel: ChannelEmail, MaxPerDay: &negative},
+		}, map[string]string{"1.max_per_day": "must not be negative, got -1"}},
+		{"duplicate type and channel", []NotificationPreferencesRequest{
+			{Type: StreakReminder, Channel: ChannelPush},
+			{Type: DailyReminder, Channel: ChannelPush},
+			{Type: StreakReminder, Channel: ChannelPush, Enabled: true},
+		}, map[string]string{"2.channel": "repeats the type and channel of entry 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePreferencesBatch(tt.reqs)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrs *FieldErrors
+			require.True(t, errors.As(err, &fieldErrs))
+			assert.Equal(t, tt.wantFields, fieldErrs.Fields)
+			assert.ErrorIs(t, err, ErrInvalidPreferences)
+		})
+	}
+}
+
+func TestNotificationPreferencesPatch_Apply(t *testing.T) {
+	start, end, limit, newLimit := "22:00", "07:00", 3, 5
+	enabled := true
+	current := &UserNotificationPreferences{
+		Type: StreakReminder, Channel: ChannelPush, Enabled: false,
+		QuietHoursStart: &start, QuietHoursEnd: &end, MaxPerDay: &limit, Metadata: JSONMap{"source": "settings"},
+	}
+
+	t.Run("omitted fields keep stored values", func(t *testing.T) {
+		req := (&NotificationPreferencesPatch{Type: StreakReminder, Channel: ChannelPush, MaxPerDay: &newLimit}).Apply(current)
+
+		assert.False(t, req.Enabled)
+		assert.Equal(t, &start, req.QuietHoursStart)
+		assert.Equal(t, &end, req.QuietHoursEnd)
+		assert.Equal(t, 5, *req.MaxPerDay)
+		assert.Equal(t, "settings", req.Metadata["source"])
+	})
+
+	t.Run("set fields replace stored values", func(t *testing.T) {
+		later := "23:30"
+		req := (&NotificationPreferencesPatch{Type: StreakReminder, Channel: ChannelPush, Enabled: &enabled, QuietHoursStart: &later}).Apply(current)
+
+		assert.True(t, req.Enabled)
+		assert.Equal(t, "23:30", *req.QuietHoursStart)
+		assert.Equal(t, 3, *req.MaxPerDay)
+	})
+
+	t.Run("no stored preference starts enabled", func(t *testing.T) {
+		req := (&NotificationPreferencesPatch{Type: DailyReminder, Channel: ChannelEmail}).Apply(nil)
+
+		assert.True(t, req.Enabled)
+		assert.Nil(t, req.QuietHoursStart)
+		assert.Nil(t, req.MaxPerDay)
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
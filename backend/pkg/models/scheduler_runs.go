@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SchedulerRun records one pass of a scheduler job
+type SchedulerRun struct {
+	ID        int64     `json:"id" db:"id"`
+	JobName   string    `json:"job_name" db:"job_name"`
+	StartedAt time.Time `json:"started_at" db:"started_at"`
+	// FinishedAt is nil while the run is in progress or if the process died during it
+	FinishedAt           *time.Time `json:"finished_at" db:"finished_at"`
+	UsersProcessed       int        `json:"users_processed" db:"users_processed"`
+	NotificationsCreated int        `json:"notifications_created" db:"notifications_created"`
+	// Error is the error the run failed with, nil when it succeeded
+	Error *string `json:"error" db:"error"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserWebhook is the endpoint a user's webhook notifications are POSTed to
+type UserWebhook struct {
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	URL    string    `json:"url" db:"url"`
+	// Secret signs every request; it is never returned by the API
+	Secret    string    `json:"-" db:"secret"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RegisterWebhookRequest sets the webhook endpoint and signing secret for a user
+type RegisterWebhookRequest struct {
+	URL    string `json:"url" binding:"required,url,max=2048"`
+	Secret string `json:"secret" binding:"required,min=16,max=256"`
+}
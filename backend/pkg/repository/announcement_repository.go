@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrAnnouncementNotFound is returned when there is no announcement with an ID,
+// or none left to fan out
+var ErrAnnouncementNotFound = apperr.New(apperr.ErrNotFound, "announcement not found")
+
+// announcementColumns is the column list read by scanAnnouncement
+const announcementColumns = `id, title, message, metadata, segment, segment_value, status, last_user_id,
+			   notifications_created, started_at, completed_at, created_at, updated_at`
+
+// AnnouncementRepository stores announcements and the progress of their fanout
+type AnnouncementRepository interface {
+	CreateAnnouncement(ctx context.Context, announcement *models.Announcement) error
+	GetAnnouncement(ctx context.Context, id int64) (*models.Announcement, error)
+	GetNextUnfinishedAnnouncement(ctx context.Context) (*models.Announcement, error)
+	StartAnnouncement(ctx context.Context, id int64, startedAt time.Time) error
+	GetAnnouncementRecipients(ctx context.Context, announcement *models.Announcement, limit int, after uuid.UUID) ([]models.User, error)
+	SaveAnnouncementPage(ctx context.Context, id int64, notifications []*models.Notification, outboxItems []*models.OutboxNotification, lastUserID uuid.UUID) error
+	CompleteAnnouncement(ctx context.Context, id int64, completedAt time.Time) error
+}
+
+// PostgresAnnouncementRepository implements AnnouncementRepository for PostgreSQL
+type PostgresAnnouncementRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresAnnouncementRepository creates a new PostgreSQL announcement repository
+func NewPostgresAnnouncementRepository(db *sql.DB) *PostgresAnnouncementRepository {
+	return &PostgresAnnouncementRepository{
+		db: db,
+	}
+}
+
+// scanAnnouncement scans a row selected with announcementColumns
+func scanAnnouncement(row rowScanner, a *models.Announcement) error {
+	return row.Scan(&a.ID, &a.Title, &a.Message, &a.Metadata, &a.Segment, &a.SegmentValue, &a.Status, &a.LastUserID,
+		&a.NotificationsCreated, &a.StartedAt, &a.CompletedAt, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// CreateAnnouncement inserts a pending announcement, setting its ID and timestamps
+func (r *PostgresAnnouncementRepository) CreateAnnouncement(ctx context.Context, announcement *models.Announcement) error {
+	query := `
+		INSERT INTO announcements (title, message, metadata, segment, segment_value, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, announcement.Title, announcement.Message, announcement.Metadata,
+		announcement.Segment, announcement.SegmentValue, announcement.Status,
+	).Scan(&announcement.ID, &announcement.CreatedAt, &announcement.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create announcement: %w", err)
+	}
+	return nil
+}
+
+// GetAnnouncement gets an announcement with its fanout progress
+func (r *PostgresAnnouncementRepository) GetAnnouncement(ctx context.Context, id int64) (*models.Announcement, error) {
+	query := `SELECT ` + announcementColumns + ` FROM announcements WHERE id = $1`
+
+	var announcement models.Announcement
+	if err := scanAnnouncement(r.db.QueryRowContext(ctx, query, id), &announcement); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrAnnouncementNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get announcement: %w", err)
+	}
+	return &announcement, nil
+}
+
+// GetNextUnfinishedAnnouncement gets the oldest announcement whose fanout has
+// not completed, including one a crashed fanout left sending
+func (r *PostgresAnnouncementRepository) GetNextUnfinishedAnnouncement(ctx context.Context) (*models.Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `
+		FROM announcements
+		WHERE status <> 'completed'
+		ORDER BY id
+		LIMIT 1
+	`
+
+	var announcement models.Announcement
+	if err := scanAnnouncement(r.db.QueryRowContext(ctx, query), &announcement); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAnnouncementNotFound
+		}
+		return nil, fmt.Errorf("failed to get next announcement: %w", err)
+	}
+	return &announcement, nil
+}
+
+// StartAnnouncement marks the announcement sending, keeping the time its
+// first fanout started
+func (r *PostgresAnnouncementRepository) StartAnnouncement(ctx context.Context, id int64, startedAt time.Time) error {
+	query := `
+		UPDATE announcements
+		SET status = 'sending', started_at = COALESCE(started_at, $2), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id, startedAt); err != nil {
+		return fmt.Errorf("failed to start announcement: %w", err)
+	}
+	return nil
+}
+
+// GetAnnouncementRecipients gets a page of up to limit users in the
+// announcement's segment after the given user ID, in user ID order. It leaves
+// out users who turned in-app new_course notifications off. Users who never
+// practiced have a streak of 0 and count as inactive for any number of days.
+func (r *PostgresAnnouncementRepository) GetAnnouncementRecipients(ctx context.Context, announcement *models.Announcement, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT u.user_id, u.name, u.email
+		FROM users u
+		LEFT JOIN user_engagement_streaks ues ON ues.user_id = u.user_id AND ues.streak_type = 'practice'
+		WHERE u.user_id > $1
+		  AND CASE $3
+			WHEN 'min_streak' THEN COALESCE(ues.current_streak, 0) >= $4
+			WHEN 'inactive_days' THEN ues.last_activity_date IS NULL OR ues.last_activity_date <= CURRENT_DATE - $4::int
+			ELSE true
+		  END
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_notification_preferences unp
+			WHERE unp.user_id = u.user_id
+			  AND unp.type = 'new_course'
+			  AND unp.channel = 'in_app'
+			  AND unp.enabled = false
+		  )
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	segmentValue := 0
+	if announcement.SegmentValue != nil {
+		segmentValue = *announcement.SegmentValue
+	}
+	return queryUserPage(ctx, r.db, query, "announcement recipients", limit, after, string(announcement.Segment), segmentValue)
+}
+
+// SaveAnnouncementPage stores a page of the announcement's notifications with
+// their outbox entries and moves its fanout past lastUserID in one
+// transaction, so a fanout that crashes resumes after the last stored page
+// without notifying anyone twice
+func (r *PostgresAnnouncementRepository) SaveAnnouncementPage(ctx context.Context, id int64, notifications []*models.Notification, outboxItems []*models.OutboxNotification, lastUserID uuid.UUID) error {
+	if len(notifications) != len(outboxItems) {
+		return fmt.Errorf("got %d notifications but %d outbox entries", len(notifications), len(outboxItems))
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin announcement transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(notifications) > 0 {
+		if err := insertNotificationsWithOutbox(ctx, tx, notifications, outboxItems); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE announcements
+		SET last_user_id = $2,
+			notifications_created = notifications_created + $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, id, lastUserID, len(notifications)); err != nil {
+		return fmt.Errorf("failed to record announcement progress: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit announcement transaction: %w", err)
+	}
+	return nil
+}
+
+// CompleteAnnouncement marks the announcement's fanout completed
+func (r *PostgresAnnouncementRepository) CompleteAnnouncement(ctx context.Context, id int64, completedAt time.Time) error {
+	query := `
+		UPDATE announcements
+		SET status = 'completed', completed_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id, completedAt); err != nil {
+		return fmt.Errorf("failed to complete announcement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var announcementRowColumns = []string{"id", "title", "message", "metadata", "segment", "segment_value", "status", "last_user_id",
+	"notifications_created", "started_at", "completed_at", "created_at", "updated_at"}
+
+func TestGetAnnouncementRecipients_SelectsSegment(t *testing.T) {
+	value := func(n int) *int { return &n }
+	tests := []struct {
+		name      string
+		segment   models.AnnouncementSegment
+		value     *int
+		wantValue int
+	}{
+		{name: "all", segment: models.SegmentAll, wantValue: 0},
+		{name: "min streak", segment: models.SegmentMinStreak, value: value(7), wantValue: 7},
+		{name: "inactive days", segment: models.SegmentInactiveDays, value: value(30), wantValue: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			after, userID := uuid.New(), uuid.New()
+			mock.ExpectQuery(`WHEN 'min_streak' THEN COALESCE\(ues.current_streak, 0\) >= \$4\s+`+
+				`WHEN 'inactive_days' THEN ues.last_activity_date IS NULL OR ues.last_activity_date <= CURRENT_DATE - \$4::int`).
+				WithArgs(after, 1000, string(tt.segment), tt.wantValue).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email"}).AddRow(userID, "Ada", "ada@example.com"))
+
+			users, err := NewPostgresAnnouncementRepository(db).GetAnnouncementRecipients(context.Background(),
+				&models.Announcement{ID: 1, Segment: tt.segment, SegmentValue: tt.value}, 1000, after)
+
+			require.NoError(t, err)
+			require.Len(t, users, 1)
+			assert.Equal(t, userID, users[0].ID)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSaveAnnouncementPage_StoresPageAndCursorTogether(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	lastUserID := uuid.New()
+	n := &models.Notification{ID: uuid.New(), UserID: lastUserID, Type: models.NewCourse, Channel: models.ChannelInApp, Message: "Spanish is here"}
+	outbox := &models.OutboxNotification{NotificationID: n.ID, Topic: "notifications"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("SET last_user_id = $2")).
+		WithArgs(int64(4), lastUserID, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = NewPostgresAnnouncementRepository(db).SaveAnnouncementPage(context.Background(), 4,
+		[]*models.Notification{n}, []*models.OutboxNotification{outbox}, lastUserID)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAnnouncementPage_FailedCursorRollsBackPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	lastUserID := uuid.New()
+	n := &models.Notification{ID: uuid.New(), UserID: lastUserID, Type: models.NewCourse, Channel: models.ChannelInApp, Message: "Spanish is here"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("SET last_user_id = $2")).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	err = NewPostgresAnnouncementRepository(db).SaveAnnouncementPage(context.Background(), 4,
+		[]*models.Notification{n}, []*models.OutboxNotification{{NotificationID: n.ID}}, lastUserID)
+
+	assert.ErrorContains(t, err, "failed to record announcement progress")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetNextUnfinishedAnnouncement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	lastUserID, now := uuid.New(), time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE status <> 'completed'")).
+		WillReturnRows(sqlmock.NewRows(announcementRowColumns).AddRow(
+			2, "New course", "Spanish is here", []byte(`{"course_id":"es-101"}`), "min_streak", 7, "sending", lastUserID,
+			1000, now, nil, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE status <> 'completed'")).WillReturnError(sql.ErrNoRows)
+
+	repo := NewPostgresAnnouncementRepository(db)
+	announcement, err := repo.GetNextUnfinishedAnnouncement(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, models.AnnouncementSending, announcement.Status)
+	assert.Equal(t, lastUserID, *announcement.LastUserID)
+	assert.Equal(t, 7, *announcement.SegmentValue)
+	assert.Equal(t, "es-101", announcement.Metadata["course_id"])
+
+	_, err = repo.GetNextUnfinishedAnnouncement(context.Background())
+	assert.ErrorIs(t, err, ErrAnnouncementNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrDeviceNotFound is returned when a user has no device with a token
+var ErrDeviceNotFound = apperr.New(apperr.ErrNotFound, "device not found")
+
+// deviceColumns is the column list read by scanDevice
+const deviceColumns = `id, user_id, token, platform, active, created_at, updated_at`
+
+// DeviceRepository stores users' push device tokens
+type DeviceRepository interface {
+	RegisterDevice(ctx context.Context, device *models.UserDevice) (*models.UserDevice, error)
+	UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error
+	GetActiveDevices(ctx context.Context, userID uuid.UUID) ([]models.UserDevice, error)
+	DeactivateDevice(ctx context.Context, token string) error
+}
+
+// PostgresDeviceRepository implements DeviceRepository for PostgreSQL
+type PostgresDeviceRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresDeviceRepository creates a new PostgreSQL device repository
+func NewPostgresDeviceRepository(db *sql.DB) *PostgresDeviceRepository {
+	return &PostgresDeviceRepository{
+		db: db,
+	}
+}
+
+// scanDevice scans a row selected with deviceColumns
+func scanDevice(row rowScanner, device *models.UserDevice) error {
+	return row.Scan(&device.ID, &device.UserID, &device.Token, &device.Platform,
+		&device.Active, &device.CreatedAt, &device.UpdatedAt)
+}
+
+// RegisterDevice stores a device token for the user and returns the stored row.
+// Registering a known token reactivates it, moving it to this user if another
+// user had it, since a device only has one signed-in user at a time.
+func (r *PostgresDeviceRepository) RegisterDevice(ctx context.Context, device *models.UserDevice) (*models.UserDevice, error) {
+	query := `
+		INSERT INTO user_devices (user_id, token, platform, active)
+		VALUES ($1, $2, $3, true)
+		ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			platform = EXCLUDED.platform,
+			active = true,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + deviceColumns
+
+	var stored models.UserDevice
+	if err := scanDevice(r.db.QueryRowContext(ctx, query, device.UserID, device.Token, device.Platform), &stored); err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+	return &stored, nil
+}
+
+// UnregisterDevice removes one of the user's device tokens
+func (r *PostgresDeviceRepository) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	query := `DELETE FROM user_devices WHERE user_id = $1 AND token = $2`
+
+	result, err := r.db.ExecContext(ctx, query, userID, token)
+	if err != nil {
+		return fmt.Errorf("failed to unregister device: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w for user %s", ErrDeviceNotFound, userID)
+	}
+	return nil
+}
+
+// GetActiveDevices returns the user's active device tokens, oldest first
+func (r *PostgresDeviceRepository) GetActiveDevices(ctx context.Context, userID uuid.UUID) ([]models.UserDevice, error) {
+	query := `SELECT ` + deviceColumns + ` FROM user_devices WHERE user_id = $1 AND active ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := []models.UserDevice{}
+	for rows.Next() {
+		var device models.UserDevice
+		if err := scanDevice(rows, &device); err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate devices: %w", err)
+	}
+	return devices, nil
+}
+
+// DeactivateDevice stops push delivery to a token the provider no longer accepts
+func (r *PostgresDeviceRepository) DeactivateDevice(ctx context.Context, token string) error {
+	query := `UPDATE user_devices SET active = false, updated_at = CURRENT_TIMESTAMP WHERE token = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, token); err != nil {
+		return fmt.Errorf("failed to deactivate device: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deviceRowColumns = []string{"id", "user_id", "token", "platform", "active", "created_at", "updated_at"}
+
+func TestRegisterDevice_UpsertsByToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID, now := uuid.New(), time.Now()
+	mock.ExpectQuery(`INSERT INTO user_devices .* ON CONFLICT \(token\) DO UPDATE SET\s+user_id = EXCLUDED.user_id`).
+		WithArgs(userID, "token-1", models.PlatformAndroid).
+		WillReturnRows(sqlmock.NewRows(deviceRowColumns).AddRow(7, userID, "token-1", "android", true, now, now))
+
+	device, err := NewPostgresDeviceRepository(db).RegisterDevice(context.Background(),
+		&models.UserDevice{UserID: userID, Token: "token-1", Platform: models.PlatformAndroid})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), device.ID)
+	assert.True(t, device.Active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnregisterDevice_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectExec(`DELETE FROM user_devices WHERE user_id = \$1 AND token = \$2`).
+		WithArgs(userID, "token-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewPostgresDeviceRepository(db).UnregisterDevice(context.Background(), userID, "token-1")
+
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetActiveDevices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID, now := uuid.New(), time.Now()
+	mock.ExpectQuery(`FROM user_devices WHERE user_id = \$1 AND active ORDER BY id`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(deviceRowColumns).
+			AddRow(1, userID, "phone", "ios", true, now, now).
+			AddRow(2, userID, "browser", "web", true, now, now))
+
+	devices, err := NewPostgresDeviceRepository(db).GetActiveDevices(context.Background(), userID)
+
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "phone", devices[0].Token)
+	assert.Equal(t, models.PlatformWeb, devices[1].Platform)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeactivateDevice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE user_devices SET active = false, .* WHERE token = \$1`).
+		WithArgs("stale").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewPostgresDeviceRepository(db).DeactivateDevice(context.Background(), "stale")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrGoalNotFound is returned when a user has no goal with an ID or for a week
+var ErrGoalNotFound = apperr.New(apperr.ErrNotFound, "goal not found")
+
+// goalColumns is the column list read by scanGoal
+const goalColumns = `id, user_id, goal_type, target, week_start, created_at, updated_at`
+
+// GoalRepository stores the weekly goals users set
+type GoalRepository interface {
+	UpsertGoal(ctx context.Context, goal *models.UserGoal) (*models.UserGoal, error)
+	GetGoals(ctx context.Context, userID uuid.UUID) ([]models.UserGoal, error)
+	GetGoalForWeek(ctx context.Context, userID uuid.UUID, goalType models.GoalType, weekStart time.Time) (*models.UserGoal, error)
+	UpdateGoalTarget(ctx context.Context, userID uuid.UUID, goalID int64, target int) (*models.UserGoal, error)
+	DeleteGoal(ctx context.Context, userID uuid.UUID, goalID int64) error
+}
+
+// PostgresGoalRepository implements GoalRepository for PostgreSQL
+type PostgresGoalRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresGoalRepository creates a new PostgreSQL goal repository
+func NewPostgresGoalRepository(db *sql.DB) *PostgresGoalRepository {
+	return &PostgresGoalRepository{
+		db: db,
+	}
+}
+
+// scanGoal scans a row selected with goalColumns
+func scanGoal(row rowScanner, goal *models.UserGoal) error {
+	return row.Scan(&goal.ID, &goal.UserID, &goal.GoalType, &goal.Target,
+		&goal.WeekStart, &goal.CreatedAt, &goal.UpdatedAt)
+}
+
+// UpsertGoal sets the user's goal of its type for its week, replacing the
+// target of one already set, and returns the stored row
+func (r *PostgresGoalRepository) UpsertGoal(ctx context.Context, goal *models.UserGoal) (*models.UserGoal, error) {
+	query := `
+		INSERT INTO user_goals (user_id, goal_type, target, week_start)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, goal_type, week_start) DO UPDATE SET
+			target = EXCLUDED.target,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + goalColumns
+
+	var stored models.UserGoal
+	err := scanGoal(r.db.QueryRowContext(ctx, query, goal.UserID, goal.GoalType, goal.Target, goal.WeekStart.Format("2006-01-02")), &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set goal: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetGoals returns the user's goals, newest week first
+func (r *PostgresGoalRepository) GetGoals(ctx context.Context, userID uuid.UUID) ([]models.UserGoal, error) {
+	query := `SELECT ` + goalColumns + ` FROM user_goals WHERE user_id = $1 ORDER BY week_start DESC, goal_type`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []models.UserGoal{}
+	for rows.Next() {
+		var goal models.UserGoal
+		if err := scanGoal(rows, &goal); err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %w", err)
+		}
+		goals = append(goals, goal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate goals: %w", err)
+	}
+	return goals, nil
+}
+
+// GetGoalForWeek returns the user's goal of a type for the week starting on
+// weekStart's date
+func (r *PostgresGoalRepository) GetGoalForWeek(ctx context.Context, userID uuid.UUID, goalType models.GoalType, weekStart time.Time) (*models.UserGoal, error) {
+	query := `SELECT ` + goalColumns + ` FROM user_goals WHERE user_id = $1 AND goal_type = $2 AND week_start = $3`
+
+	var goal models.UserGoal
+	err := scanGoal(r.db.QueryRowContext(ctx, query, userID, goalType, weekStart.Format("2006-01-02")), &goal)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w for user %s in week %s", ErrGoalNotFound, userID, weekStart.Format("2006-01-02"))
+		}
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+	return &goal, nil
+}
+
+// UpdateGoalTarget changes the target of one of the user's goals
+func (r *PostgresGoalRepository) UpdateGoalTarget(ctx context.Context, userID uuid.UUID, goalID int64, target int) (*models.UserGoal, error) {
+	query := `
+		UPDATE user_goals SET target = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2
+		RETURNING ` + goalColumns
+
+	var goal models.UserGoal
+	if err := scanGoal(r.db.QueryRowContext(ctx, query, goalID, userID, target), &goal); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrGoalNotFound, goalID)
+		}
+		return nil, fmt.Errorf("failed to update goal: %w", err)
+	}
+	return &goal, nil
+}
+
+// DeleteGoal removes one of the user's goals
+func (r *PostgresGoalRepository) DeleteGoal(ctx context.Context, userID uuid.UUID, goalID int64) error {
+	query := `DELETE FROM user_goals WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, goalID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %d", ErrGoalNotFound, goalID)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var goalRowColumns = []string{"id", "user_id", "goal_type", "target", "week_start", "created_at", "updated_at"}
+
+func TestUpsertGoal_ReplacesTargetForTheWeek(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID, now := uuid.New(), time.Now()
+	weekStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO user_goals .* ON CONFLICT \(user_id, goal_type, week_start\) DO UPDATE SET\s+target = EXCLUDED.target`).
+		WithArgs(userID, models.GoalWeeklyXP, 500, "2024-03-11").
+		WillReturnRows(sqlmock.NewRows(goalRowColumns).AddRow(3, userID, "weekly_xp", 500, weekStart, now, now))
+
+	goal, err := NewPostgresGoalRepository(db).UpsertGoal(context.Background(),
+		&models.UserGoal{UserID: userID, GoalType: models.GoalWeeklyXP, Target: 500, WeekStart: weekStart})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), goal.ID)
+	assert.Equal(t, 500, goal.Target)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetGoalForWeek_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE user_id = $1 AND goal_type = $2 AND week_start = $3")).
+		WithArgs(userID, models.GoalWeeklyXP, "2024-03-11").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = NewPostgresGoalRepository(db).GetGoalForWeek(context.Background(), userID, models.GoalWeeklyXP,
+		time.Date(2024, 3, 11, 0, 0, 0, 0, time.FixedZone("EST", -5*3600)))
+
+	assert.ErrorIs(t, err, ErrGoalNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteGoal_OtherUsersGoal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM user_goals WHERE id = $1 AND user_id = $2")).
+		WithArgs(int64(3), userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewPostgresGoalRepository(db).DeleteGoal(context.Background(), userID, 3)
+
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,36 +3,141 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"kafka-notify/pkg/apperr"
 	"kafka-notify/pkg/models"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+var (
+	// ErrNotificationNotFound is returned when a notification lookup matches no rows
+	ErrNotificationNotFound = apperr.New(apperr.ErrNotFound, "notification not found")
+	// ErrNotificationExists is returned when inserting a notification whose ID is taken
+	ErrNotificationExists = apperr.New(apperr.ErrConflict, "notification already exists")
+	// ErrOutboxNotFound is returned when an outbox lookup matches no rows
+	ErrOutboxNotFound = apperr.New(apperr.ErrNotFound, "outbox item not found")
+	// ErrDuplicateDedupeKey is returned when inserting a notification whose
+	// dedupe key is already held by another of the user's live notifications
+	ErrDuplicateDedupeKey = apperr.New(apperr.ErrConflict, "dedupe key already in use")
+	// ErrNotificationNotOwned is returned when a user acts on another user's notification
+	ErrNotificationNotOwned = apperr.New(apperr.ErrForbidden, "notification belongs to another user")
+	// ErrPreferenceNotFound is returned when a user has no preference for a type and channel
+	ErrPreferenceNotFound = apperr.New(apperr.ErrNotFound, "preference not found")
+	// ErrUserNotFound is returned when a user lookup matches no rows
+	ErrUserNotFound = apperr.New(apperr.ErrNotFound, "user not found")
+	// ErrStreakNotFound is returned when a user has no engagement streak of a type
+	ErrStreakNotFound = apperr.New(apperr.ErrNotFound, "streak not found")
+	// ErrDuplicateIdempotencyKey is returned when creating a notification under an
+	// idempotency key the user used for another notification that has not expired
+	ErrDuplicateIdempotencyKey = apperr.New(apperr.ErrConflict, "idempotency key already in use")
+)
+
+// dedupeKeyIndex is the partial unique index over live dedupe keys
+const dedupeKeyIndex = "idx_notifications_user_dedupe_key_active"
+
+// isUniqueViolation reports whether err is a unique violation on the given constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
 // NotificationRepository defines the interface for notification operations
 type NotificationRepository interface {
 	CreateNotification(ctx context.Context, notification *models.Notification) error
-	GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Notification, error)
+	CreateNotificationWithOutbox(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification) error
+	CreateNotificationsWithOutbox(ctx context.Context, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error
+	CreateNotificationWithIdempotencyKey(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification, key string, expiredBefore time.Time) error
+	GetNotificationByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, since time.Time) (*models.Notification, error)
+	DeleteIdempotencyKeysBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	QueryUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error)
+	CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error)
+	GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error)
+	GetUserNotificationsBetween(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]models.Notification, error)
 	GetNotificationByID(ctx context.Context, notificationID uuid.UUID) (*models.Notification, error)
-	MarkAsRead(ctx context.Context, notificationID uuid.UUID) error
+	GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error)
+	GetActiveNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error)
+	RetireDedupeKey(ctx context.Context, notificationID uuid.UUID) error
+	MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error
+	MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error)
 	MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error
+	AcknowledgeDelivery(ctx context.Context, notificationID, userID uuid.UUID) error
 	MarkAsSent(ctx context.Context, notificationID uuid.UUID) error
+	MarkAsFailed(ctx context.Context, notificationID uuid.UUID) error
+	CancelQueuedNotification(ctx context.Context, notificationID uuid.UUID) (bool, error)
 	GetUnpublishedOutbox(ctx context.Context, limit int) ([]models.OutboxNotification, error)
+	ClaimUnpublishedOutbox(ctx context.Context, workerID string, limit int) ([]models.OutboxNotification, error)
 	MarkOutboxPublished(ctx context.Context, outboxID int64) error
+	MarkOutboxBatchSent(ctx context.Context, outboxIDs []int64) error
+	CountPendingOutbox(ctx context.Context) (int, error)
+	GetOutboxStats(ctx context.Context) (*models.OutboxStats, error)
+	DeletePublishedOutboxBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	IncrementOutboxAttempts(ctx context.Context, outboxID int64, errMsg string, nextAttemptAt time.Time, maxAttempts int) error
+	ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error)
+	RequeueDeadOutbox(ctx context.Context, outboxID int64) error
 	CreateOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error
+	RequeueOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error
+	RequeueFailedNotification(ctx context.Context, outboxItem *models.OutboxNotification) (bool, error)
 	GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error)
-	UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) error
+	GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.UserNotificationPreferences, error)
+	UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) (*models.UserNotificationPreferences, error)
+	UpdateUserPreferencesBatch(ctx context.Context, userID uuid.UUID, prefs []models.UserNotificationPreferences) ([]models.UserNotificationPreferences, error)
+	DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error
+	DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkPreferenceSent(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, sentAt time.Time) error
+	CountNotificationsForUserTypeSince(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, since time.Time) (int, error)
+	HasNotificationOfTypeToday(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, tz string) (bool, error)
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	GetUsersNeedingDailyReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error)
+	GetUsersNeedingStreakReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error)
+	GetUsersNeedingLastChanceAlerts(ctx context.Context, startHour, endHour, limit int, after uuid.UUID) ([]models.User, error)
+	GetUsersNeedingXPGoalReminders(ctx context.Context, reminderHour int, threshold float64, limit int, after uuid.UUID) ([]models.User, error)
+	GetActiveUsersForWeeklyRecap(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error)
+	GetInactiveUsersForEngagement(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error)
+	GetLastEngagementNudgeTier(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	GetPracticeStreaks(ctx context.Context) ([]models.UserEngagementStreak, error)
+	GetWeeklyActivitySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (*models.WeeklyActivitySummary, error)
 	GetUserEngagementStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error)
 	UpdateUserEngagementStreak(ctx context.Context, streak *models.UserEngagementStreak) error
+	RecordStreakActivity(ctx context.Context, userID uuid.UUID, streakType string, apply func(*models.UserEngagementStreak)) (*models.UserEngagementStreak, error)
+	UpdateStreakTypicalHour(ctx context.Context, userID uuid.UUID, streakType string, typicalHour *int, confidence float64) error
+	GetPracticeTimestamps(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error)
 	GetNotificationsByStatus(ctx context.Context, status models.DeliveryStatus, limit int) ([]models.Notification, error)
 	GetScheduledNotifications(ctx context.Context, before time.Time, limit int) ([]models.Notification, error)
+	ReleaseScheduledNotifications(ctx context.Context, before time.Time, limit int, build OutboxBuilder) ([]models.Notification, error)
 	CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error
+	GetDeliveryAttempts(ctx context.Context, notificationID uuid.UUID) ([]models.NotificationDeliveryAttempt, error)
+	GetAttemptSummaryMismatches(ctx context.Context, limit int) ([]uuid.UUID, error)
+	GetDeliveryLatencySamples(ctx context.Context, priorities []models.PriorityLevel, since time.Time) ([]models.DeliveryLatencySample, error)
 	GetNotificationTemplates(ctx context.Context, notificationType models.NotificationType, channel models.NotificationChannel) ([]models.NotificationTemplate, error)
 }
 
+// notificationColumns is the column list read by scanNotification
+const notificationColumns = `id, user_id, type, channel, priority, template_id, title, message,
+			   metadata, attachments, dedupe_key, created_at, scheduled_for, sent_at, delivered_at, read_at, status,
+			   last_attempt_no, last_attempt_status, last_attempt_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNotification scans a row selected with notificationColumns
+func scanNotification(row rowScanner, n *models.Notification) error {
+	return row.Scan(
+		&n.ID, &n.UserID, &n.Type, &n.Channel, &n.Priority, &n.TemplateID,
+		&n.Title, &n.Message, &n.Metadata, &n.Attachments, &n.DedupeKey, &n.CreatedAt,
+		&n.ScheduledFor, &n.SentAt, &n.DeliveredAt, &n.ReadAt, &n.Status,
+		&n.LastAttemptNo, &n.LastAttemptStatus, &n.LastAttemptAt,
+	)
+}
+
 // PostgresNotificationRepository implements NotificationRepository using PostgreSQL
 type PostgresNotificationRepository struct {
 	db *sql.DB
@@ -45,14 +150,170 @@ func NewPostgresNotificationRepository(db *sql.DB) *PostgresNotificationReposito
 
 // CreateNotification creates a new notification in the database
 func (r *PostgresNotificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	return insertNotification(ctx, r.db, notification)
+}
+
+// CreateNotificationWithOutbox creates a notification and its outbox entry in a
+// single transaction, so a notification is never stored without being published
+func (r *PostgresNotificationRepository) CreateNotificationWithOutbox(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin notification transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertNotification(ctx, tx, notification); err != nil {
+		return err
+	}
+	if err := insertOutboxEntry(ctx, tx, outboxItem); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CreateNotificationsWithOutbox creates several notifications with their
+// outbox entries, paired by index, in one transaction using one multi-row
+// insert per table
+func (r *PostgresNotificationRepository) CreateNotificationsWithOutbox(ctx context.Context, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error {
+	if len(notifications) != len(outboxItems) {
+		return fmt.Errorf("got %d notifications but %d outbox entries", len(notifications), len(outboxItems))
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin notification transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertNotificationsWithOutbox(ctx, tx, notifications, outboxItems); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertNotificationsWithOutbox inserts the notifications and their outbox
+// entries with one multi-row insert each
+func insertNotificationsWithOutbox(ctx context.Context, db execer, notifications []*models.Notification, outboxItems []*models.OutboxNotification) error {
+	values := make([]string, len(notifications))
+	args := make([]interface{}, 0, len(notifications)*14)
+	for i, n := range notifications {
+		values[i] = placeholderRow(len(args), 14)
+		args = append(args, n.ID, n.UserID, n.Type, n.Channel, n.Priority, n.TemplateID, n.Title, n.Message,
+			n.Metadata, n.Attachments, n.DedupeKey, n.ScheduledFor, n.Status, n.CreatedAt)
+	}
+	query := `
+		INSERT INTO notifications (
+			id, user_id, type, channel, priority, template_id, title, message,
+			metadata, attachments, dedupe_key, scheduled_for, status, created_at
+		) VALUES ` + strings.Join(values, ", ")
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to create notifications: %w", err)
+	}
+
+	values = make([]string, len(outboxItems))
+	args = make([]interface{}, 0, len(outboxItems)*5)
+	for i, item := range outboxItems {
+		values[i] = placeholderRow(len(args), 5)
+		args = append(args, item.NotificationID, item.Topic, item.Payload, item.Published, item.CreatedAt)
+	}
+	query = `
+		INSERT INTO outbox_notifications (
+			notification_id, topic, payload, published, created_at
+		) VALUES ` + strings.Join(values, ", ")
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to create outbox entries: %w", err)
+	}
+
+	return nil
+}
+
+// placeholderRow returns a VALUES row of n placeholders numbered after offset,
+// e.g. "($4, $5, $6)" for offset 3 and n 3
+func placeholderRow(offset, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", offset+i+1)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+// CreateNotificationWithIdempotencyKey creates a notification, with its outbox
+// entry unless outboxItem is nil, and records key for the user in the same
+// transaction. A key the user already holds that was created at or after
+// expiredBefore fails with ErrDuplicateIdempotencyKey; an older one is taken
+// over. Concurrent requests with one key serialize on its primary key, so only
+// one of them commits a notification.
+func (r *PostgresNotificationRepository) CreateNotificationWithIdempotencyKey(ctx context.Context, notification *models.Notification, outboxItem *models.OutboxNotification, key string, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin notification transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertNotification(ctx, tx, notification); err != nil {
+		return err
+	}
+	if outboxItem != nil {
+		if err := insertOutboxEntry(ctx, tx, outboxItem); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, notification_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		SET notification_id = EXCLUDED.notification_id, created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $5
+	`
+
+	result, err := tx.ExecContext(ctx, query, notification.UserID, key, notification.ID, notification.CreatedAt, expiredBefore)
+	if err != nil {
+		return fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if claimed == 0 {
+		return fmt.Errorf("%w: %q", ErrDuplicateIdempotencyKey, key)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification transaction: %w", err)
+	}
+
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertNotification inserts a notification row
+func insertNotification(ctx context.Context, db execer, notification *models.Notification) error {
 	query := `
 		INSERT INTO notifications (
 			id, user_id, type, channel, priority, template_id, title, message, 
-			metadata, dedupe_key, scheduled_for, status, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			metadata, attachments, dedupe_key, scheduled_for, status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		notification.ID,
 		notification.UserID,
 		notification.Type,
@@ -62,12 +323,19 @@ func (r *PostgresNotificationRepository) CreateNotification(ctx context.Context,
 		notification.Title,
 		notification.Message,
 		notification.Metadata, // JSONMap handles JSON serialization automatically
+		notification.Attachments,
 		notification.DedupeKey,
 		notification.ScheduledFor,
 		notification.Status,
 		notification.CreatedAt,
 	)
 
+	if isUniqueViolation(err, "notifications_pkey") {
+		return fmt.Errorf("%w: %s", ErrNotificationExists, notification.ID)
+	}
+	if isUniqueViolation(err, dedupeKeyIndex) {
+		return fmt.Errorf("%w: %q", ErrDuplicateDedupeKey, *notification.DedupeKey)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create notification: %w", err)
 	}
@@ -75,18 +343,123 @@ func (r *PostgresNotificationRepository) CreateNotification(ctx context.Context,
 	return nil
 }
 
-// GetUserNotifications retrieves notifications for a specific user
-func (r *PostgresNotificationRepository) GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Notification, error) {
+// notificationOrder maps each sort to its ORDER BY clause. priority_level is
+// declared low to urgent, so descending puts urgent first.
+var notificationOrder = map[models.NotificationSort]string{
+	models.SortNewest:   "created_at DESC, id DESC",
+	models.SortOldest:   "created_at ASC, id ASC",
+	models.SortPriority: "priority DESC, created_at DESC, id DESC",
+}
+
+// QueryUserNotifications retrieves a page of a user's notifications matching
+// the filter, newest first unless the filter sorts otherwise
+func (r *PostgresNotificationRepository) QueryUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) ([]models.Notification, error) {
+	conditions, args := notificationFilterConditions(userID, filter)
+
+	order, ok := notificationOrder[filter.Sort]
+	if !ok {
+		order = notificationOrder[models.SortNewest]
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM notifications
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, notificationColumns, strings.Join(conditions, " AND "), order, len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		err := scanNotification(rows, &n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// notificationFilterConditions builds the WHERE conditions and positional
+// arguments selecting a user's notifications that match the filter
+func notificationFilterConditions(userID uuid.UUID, filter models.NotificationFilter) ([]string, []interface{}) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		where("type = ANY($%d)", pq.Array(types))
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, s := range filter.Statuses {
+			statuses[i] = string(s)
+		}
+		where("status = ANY($%d)", pq.Array(statuses))
+	}
+	if len(filter.Channels) > 0 {
+		channels := make([]string, len(filter.Channels))
+		for i, c := range filter.Channels {
+			channels[i] = string(c)
+		}
+		where("channel = ANY($%d)", pq.Array(channels))
+	}
+	if filter.UnreadOnly {
+		conditions = append(conditions, "read_at IS NULL")
+	}
+	if filter.Since != nil {
+		where("created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("created_at < $%d", *filter.Until)
+	}
+
+	return conditions, args
+}
+
+// CountUserNotifications counts all of a user's notifications matching the
+// filter, ignoring its limit and offset
+func (r *PostgresNotificationRepository) CountUserNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationFilter) (int64, error) {
+	conditions, args := notificationFilterConditions(userID, filter)
+	query := `SELECT COUNT(*) FROM notifications WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count user notifications: %w", err)
+	}
+	return total, nil
+}
+
+// GetUserNotificationsBetween retrieves a user's notifications created in [from, to), oldest first
+func (r *PostgresNotificationRepository) GetUserNotificationsBetween(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]models.Notification, error) {
 	query := `
-		SELECT id, user_id, type, channel, priority, template_id, title, message,
-			   metadata, dedupe_key, created_at, scheduled_for, sent_at, delivered_at, read_at, status
+		SELECT ` + notificationColumns + `
 		FROM notifications 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at ASC, id ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user notifications: %w", err)
 	}
@@ -95,12 +468,7 @@ func (r *PostgresNotificationRepository) GetUserNotifications(ctx context.Contex
 	var notifications []models.Notification
 	for rows.Next() {
 		var n models.Notification
-		err := rows.Scan(
-			&n.ID, &n.UserID, &n.Type, &n.Channel, &n.Priority, &n.TemplateID,
-			&n.Title, &n.Message, &n.Metadata, &n.DedupeKey, &n.CreatedAt,
-			&n.ScheduledFor, &n.SentAt, &n.DeliveredAt, &n.ReadAt, &n.Status,
-		)
-		if err != nil {
+		if err := scanNotification(rows, &n); err != nil {
 			return nil, fmt.Errorf("failed to scan notification: %w", err)
 		}
 		notifications = append(notifications, n)
@@ -116,22 +484,17 @@ func (r *PostgresNotificationRepository) GetUserNotifications(ctx context.Contex
 // GetNotificationByID retrieves a notification by its ID
 func (r *PostgresNotificationRepository) GetNotificationByID(ctx context.Context, notificationID uuid.UUID) (*models.Notification, error) {
 	query := `
-		SELECT id, user_id, type, channel, priority, template_id, title, message,
-			   metadata, dedupe_key, created_at, scheduled_for, sent_at, delivered_at, read_at, status
+		SELECT ` + notificationColumns + `
 		FROM notifications 
 		WHERE id = $1
 	`
 
 	var n models.Notification
-	err := r.db.QueryRowContext(ctx, query, notificationID).Scan(
-		&n.ID, &n.UserID, &n.Type, &n.Channel, &n.Priority, &n.TemplateID,
-		&n.Title, &n.Message, &n.Metadata, &n.DedupeKey, &n.CreatedAt,
-		&n.ScheduledFor, &n.SentAt, &n.DeliveredAt, &n.ReadAt, &n.Status,
-	)
+	err := scanNotification(r.db.QueryRowContext(ctx, query, notificationID), &n)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("notification not found: %s", notificationID)
+			return nil, fmt.Errorf("%w: %s", ErrNotificationNotFound, notificationID)
 		}
 		return nil, fmt.Errorf("failed to get notification: %w", err)
 	}
@@ -139,33 +502,200 @@ func (r *PostgresNotificationRepository) GetNotificationByID(ctx context.Context
 	return &n, nil
 }
 
-// MarkAsRead marks a notification as read
-func (r *PostgresNotificationRepository) MarkAsRead(ctx context.Context, notificationID uuid.UUID) error {
+// GetNotificationByIdempotencyKey retrieves the notification a user created with
+// an idempotency key recorded at or after since
+func (r *PostgresNotificationRepository) GetNotificationByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, since time.Time) (*models.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE id = (
+			SELECT notification_id FROM idempotency_keys
+			WHERE user_id = $1 AND idempotency_key = $2 AND created_at >= $3
+		)
+	`
+
+	var n models.Notification
+	err := scanNotification(r.db.QueryRowContext(ctx, query, userID, key, since), &n)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: idempotency key %q", ErrNotificationNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get notification by idempotency key: %w", err)
+	}
+
+	return &n, nil
+}
+
+// DeleteIdempotencyKeysBefore deletes idempotency keys created before cutoff and
+// returns the number of rows deleted
+func (r *PostgresNotificationRepository) DeleteIdempotencyKeysBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete idempotency keys: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted, nil
+}
+
+// GetNotificationByDedupeKey retrieves the newest notification for a user with the given dedupe key
+func (r *PostgresNotificationRepository) GetNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	return r.getNotificationByDedupeKey(ctx, userID, dedupeKey, false)
+}
+
+// GetActiveNotificationByDedupeKey retrieves the notification still holding a
+// user's dedupe key. Rows whose key was retired, on expiry or cancellation, are
+// skipped, so they no longer block a new notification with the same key.
+func (r *PostgresNotificationRepository) GetActiveNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string) (*models.Notification, error) {
+	return r.getNotificationByDedupeKey(ctx, userID, dedupeKey, true)
+}
+
+func (r *PostgresNotificationRepository) getNotificationByDedupeKey(ctx context.Context, userID uuid.UUID, dedupeKey string, activeOnly bool) (*models.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications 
+		WHERE user_id = $1 AND dedupe_key = $2`
+	if activeOnly {
+		query += ` AND dedupe_active`
+	}
+	query += `
+		ORDER BY created_at DESC 
+		LIMIT 1
+	`
+
+	var n models.Notification
+	err := scanNotification(r.db.QueryRowContext(ctx, query, userID, dedupeKey), &n)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: dedupe key %q", ErrNotificationNotFound, dedupeKey)
+		}
+		return nil, fmt.Errorf("failed to get notification by dedupe key: %w", err)
+	}
+
+	return &n, nil
+}
+
+// RetireDedupeKey releases a notification's dedupe key for reuse once its
+// dedupe window has expired; the key stays on the row for lookups
+func (r *PostgresNotificationRepository) RetireDedupeKey(ctx context.Context, notificationID uuid.UUID) error {
+	query := `UPDATE notifications SET dedupe_active = false WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, notificationID); err != nil {
+		return fmt.Errorf("failed to retire dedupe key: %w", err)
+	}
+
+	return nil
+}
+
+// MarkAsRead marks a user's notification as read. Reading it again keeps the
+// original read_at. Returns ErrNotificationNotOwned if it belongs to another user.
+func (r *PostgresNotificationRepository) MarkAsRead(ctx context.Context, notificationID, userID uuid.UUID) error {
 	query := `
 		UPDATE notifications 
-		SET read_at = $1, status = $2, updated_at = $3
-		WHERE id = $4
+		SET read_at = COALESCE(read_at, $1), status = $2
+		WHERE id = $3 AND user_id = $4
 	`
 
-	now := time.Now()
-	_, err := r.db.ExecContext(ctx, query, now, models.StatusRead, now, notificationID)
+	result, err := r.db.ExecContext(ctx, query, time.Now(), models.StatusRead, notificationID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to mark notification as read: %w", err)
 	}
 
-	return nil
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notifications WHERE id = $1)`, notificationID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check notification: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrNotificationNotOwned, notificationID)
+	}
+	return fmt.Errorf("%w: %s", ErrNotificationNotFound, notificationID)
+}
+
+// AcknowledgeDelivery records that a user's client received a notification.
+// The first delivered_at is kept, and read, suppressed or cancelled
+// notifications keep their status. Returns ErrNotificationNotOwned if it
+// belongs to another user.
+func (r *PostgresNotificationRepository) AcknowledgeDelivery(ctx context.Context, notificationID, userID uuid.UUID) error {
+	query := `
+		UPDATE notifications
+		SET delivered_at = COALESCE(delivered_at, $1),
+			status = CASE WHEN status IN ('read', 'suppressed', 'cancelled') THEN status ELSE $2 END
+		WHERE id = $3 AND user_id = $4
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), models.StatusDelivered, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge notification delivery: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notifications WHERE id = $1)`, notificationID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check notification: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrNotificationNotOwned, notificationID)
+	}
+	return fmt.Errorf("%w: %s", ErrNotificationNotFound, notificationID)
+}
+
+// MarkAllAsRead marks every unread notification the user received at or before
+// the cutoff as read in a single update, optionally only one type, and returns
+// how many were marked
+func (r *PostgresNotificationRepository) MarkAllAsRead(ctx context.Context, userID uuid.UUID, before time.Time, notificationType models.NotificationType) (int64, error) {
+	query := `
+		UPDATE notifications
+		SET read_at = $1, status = $2
+		WHERE user_id = $3 AND read_at IS NULL AND created_at <= $4 AND status NOT IN ($5, $6)
+	`
+	args := []interface{}{time.Now(), models.StatusRead, userID, before, models.StatusSuppressed, models.StatusCancelled}
+	if notificationType != "" {
+		query += ` AND type = $7`
+		args = append(args, notificationType)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications as read: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
 }
 
 // MarkAsDelivered marks a notification as delivered
 func (r *PostgresNotificationRepository) MarkAsDelivered(ctx context.Context, notificationID uuid.UUID) error {
 	query := `
 		UPDATE notifications 
-		SET delivered_at = $1, status = $2, updated_at = $3
-		WHERE id = $4
+		SET delivered_at = $1, status = $2
+		WHERE id = $3
 	`
 
-	now := time.Now()
-	_, err := r.db.ExecContext(ctx, query, now, models.StatusDelivered, now, notificationID)
+	_, err := r.db.ExecContext(ctx, query, time.Now(), models.StatusDelivered, notificationID)
 	if err != nil {
 		return fmt.Errorf("failed to mark notification as delivered: %w", err)
 	}
@@ -173,16 +703,32 @@ func (r *PostgresNotificationRepository) MarkAsDelivered(ctx context.Context, no
 	return nil
 }
 
+// MarkAsFailed marks a notification as failed once delivery gave up on it
+func (r *PostgresNotificationRepository) MarkAsFailed(ctx context.Context, notificationID uuid.UUID) error {
+	query := `
+		UPDATE notifications 
+		SET status = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, models.StatusFailed, notificationID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification as failed: %w", err)
+	}
+
+	return nil
+}
+
 // MarkAsSent marks a notification as sent
 func (r *PostgresNotificationRepository) MarkAsSent(ctx context.Context, notificationID uuid.UUID) error {
 	query := `
 		UPDATE notifications 
-		SET sent_at = $1, status = $2, updated_at = $3
-		WHERE id = $4
+		SET sent_at = $1, status = $2
+		WHERE id = $3
 	`
 
 	now := time.Now()
-	_, err := r.db.ExecContext(ctx, query, now, models.StatusSent, now, notificationID)
+	_, err := r.db.ExecContext(ctx, query, now, models.StatusSent, notificationID)
 	if err != nil {
 		return fmt.Errorf("failed to mark notification as sent: %w", err)
 	}
@@ -190,17 +736,75 @@ func (r *PostgresNotificationRepository) MarkAsSent(ctx context.Context, notific
 	return nil
 }
 
+// CancelQueuedNotification marks a notification cancelled if it is still
+// queued and removes its unpublished outbox entries in one transaction,
+// reporting whether it was cancelled. An entry a publisher holds a live claim
+// on may already be on its way to Kafka, so it is left alone and the
+// notification stays queued.
+func (r *PostgresNotificationRepository) CancelQueuedNotification(ctx context.Context, notificationID uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin cancel transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE notifications
+		SET status = $1, dedupe_active = false
+		WHERE id = $2 AND status = $3
+	`
+
+	result, err := tx.ExecContext(ctx, query, models.StatusCancelled, notificationID, models.StatusQueued)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel notification: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	// Claims older than OutboxClaimTimeout belong to crashed workers and may be taken over
+	deleteQuery := `
+		DELETE FROM outbox_notifications
+		WHERE notification_id = $1 AND published = false
+		  AND (claimed_at IS NULL OR claimed_at < $2)
+	`
+	if _, err := tx.ExecContext(ctx, deleteQuery, notificationID, time.Now().Add(-OutboxClaimTimeout)); err != nil {
+		return false, fmt.Errorf("failed to delete unpublished outbox entries: %w", err)
+	}
+
+	var claimed bool
+	claimedQuery := `SELECT EXISTS (SELECT 1 FROM outbox_notifications WHERE notification_id = $1 AND published = false)`
+	if err := tx.QueryRowContext(ctx, claimedQuery, notificationID).Scan(&claimed); err != nil {
+		return false, fmt.Errorf("failed to check claimed outbox entries: %w", err)
+	}
+	if claimed {
+		return false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit cancel transaction: %w", err)
+	}
+
+	return true, nil
+}
+
 // GetUnpublishedOutbox retrieves unpublished notifications from the outbox
 func (r *PostgresNotificationRepository) GetUnpublishedOutbox(ctx context.Context, limit int) ([]models.OutboxNotification, error) {
 	query := `
 		SELECT id, notification_id, topic, payload, published, created_at, published_at
 		FROM outbox_notifications 
-		WHERE published = false 
+		WHERE published = false AND dead = false
+		  AND (next_attempt_at IS NULL OR next_attempt_at <= $1)
 		ORDER BY created_at ASC 
-		LIMIT $1
+		LIMIT $2
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	rows, err := r.db.QueryContext(ctx, query, time.Now(), limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query unpublished outbox: %w", err)
 	}
@@ -226,6 +830,65 @@ func (r *PostgresNotificationRepository) GetUnpublishedOutbox(ctx context.Contex
 	return outboxItems, nil
 }
 
+// OutboxClaimTimeout is how long a claim is honoured before another worker may
+// take over the row, so items claimed by a crashed worker are not stuck forever
+const OutboxClaimTimeout = 5 * time.Minute
+
+// ClaimUnpublishedOutbox atomically claims up to limit unpublished outbox items for
+// workerID. Rows locked or claimed by other workers, or still backing off after a
+// failed publish, are skipped.
+func (r *PostgresNotificationRepository) ClaimUnpublishedOutbox(ctx context.Context, workerID string, limit int) ([]models.OutboxNotification, error) {
+	query := `
+		UPDATE outbox_notifications
+		SET claimed_by = $1, claimed_at = $2
+		WHERE id IN (
+			SELECT id
+			FROM outbox_notifications
+			WHERE published = false AND dead = false
+			  AND (claimed_at IS NULL OR claimed_at < $3)
+			  AND (next_attempt_at IS NULL OR next_attempt_at <= $2)
+			ORDER BY created_at ASC
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, notification_id, topic, payload, published, created_at, published_at,
+				  claimed_by, claimed_at, attempts, last_error, dead, next_attempt_at
+	`
+
+	now := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, workerID, now, now.Add(-OutboxClaimTimeout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim unpublished outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var outboxItems []models.OutboxNotification
+	for rows.Next() {
+		var item models.OutboxNotification
+		err := rows.Scan(
+			&item.ID, &item.NotificationID, &item.Topic, &item.Payload,
+			&item.Published, &item.CreatedAt, &item.PublishedAt,
+			&item.ClaimedBy, &item.ClaimedAt, &item.Attempts, &item.LastError, &item.Dead,
+			&item.NextAttemptAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox item: %w", err)
+		}
+		outboxItems = append(outboxItems, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox items: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not preserve the subquery order
+	sort.Slice(outboxItems, func(i, j int) bool {
+		return outboxItems[i].CreatedAt.Before(outboxItems[j].CreatedAt)
+	})
+
+	return outboxItems, nil
+}
+
 // MarkOutboxPublished marks an outbox item as published
 func (r *PostgresNotificationRepository) MarkOutboxPublished(ctx context.Context, outboxID int64) error {
 	query := `
@@ -240,119 +903,945 @@ func (r *PostgresNotificationRepository) MarkOutboxPublished(ctx context.Context
 		return fmt.Errorf("failed to mark outbox as published: %w", err)
 	}
 
-	return nil
+	return nil
+}
+
+// MarkOutboxBatchSent marks several outbox items as published and moves their
+// still-queued notifications to sent, in one statement
+func (r *PostgresNotificationRepository) MarkOutboxBatchSent(ctx context.Context, outboxIDs []int64) error {
+	if len(outboxIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		WITH published AS (
+			UPDATE outbox_notifications 
+			SET published = true, published_at = $1
+			WHERE id = ANY($2)
+			RETURNING notification_id
+		)
+		UPDATE notifications 
+		SET sent_at = $1, status = $3
+		WHERE id IN (SELECT notification_id FROM published) AND status = $4
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, query, now, pq.Array(outboxIDs), models.StatusSent, models.StatusQueued)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox batch as sent: %w", err)
+	}
+
+	return nil
+}
+
+// CountPendingOutbox returns the number of outbox items still waiting to be published
+func (r *PostgresNotificationRepository) CountPendingOutbox(ctx context.Context) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM outbox_notifications
+		WHERE published = false AND dead = false
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox items: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetOutboxStats returns the outbox backlog counts and the age of the oldest
+// pending row. Each figure is its own subquery so it can use the partial
+// index matching its predicate instead of scanning the whole table.
+func (r *PostgresNotificationRepository) GetOutboxStats(ctx context.Context) (*models.OutboxStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM outbox_notifications WHERE published = false AND dead = false),
+			(SELECT COUNT(*) FROM outbox_notifications WHERE dead = true),
+			(SELECT COUNT(*) FROM outbox_notifications WHERE published = true AND published_at >= $1),
+			(SELECT MIN(created_at) FROM outbox_notifications WHERE published = false AND dead = false)
+	`
+
+	now := time.Now()
+	var stats models.OutboxStats
+	var oldest sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, now.Add(-time.Hour)).Scan(
+		&stats.Pending, &stats.Dead, &stats.PublishedLastHour, &oldest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox stats: %w", err)
+	}
+
+	if oldest.Valid {
+		stats.OldestPendingAt = &oldest.Time
+		stats.OldestPendingAgeSeconds = now.Sub(oldest.Time).Seconds()
+	}
+
+	return &stats, nil
+}
+
+// OutboxPurgeBatchSize caps how many outbox rows one purge statement deletes, so
+// the purge never holds locks on a large part of the table
+const OutboxPurgeBatchSize = 5000
+
+// DeletePublishedOutboxBefore deletes outbox items published before cutoff in
+// batches of OutboxPurgeBatchSize and returns the number of rows deleted
+func (r *PostgresNotificationRepository) DeletePublishedOutboxBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.deletePublishedOutboxBefore(ctx, cutoff, OutboxPurgeBatchSize)
+}
+
+func (r *PostgresNotificationRepository) deletePublishedOutboxBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM outbox_notifications
+		WHERE id IN (
+			SELECT id
+			FROM outbox_notifications
+			WHERE published = true AND published_at < $1
+			LIMIT $2
+		)
+	`
+
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		result, err := r.db.ExecContext(ctx, query, cutoff, batchSize)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to purge published outbox items: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get purged row count: %w", err)
+		}
+		deleted += rows
+
+		if rows < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+// MaxOutboxAttempts is the default number of failed publishes after which an outbox row is marked dead
+const MaxOutboxAttempts = 5
+
+// IncrementOutboxAttempts records a failed publish and releases the claim. The
+// row is held back until nextAttemptAt, and marked dead once maxAttempts is reached.
+func (r *PostgresNotificationRepository) IncrementOutboxAttempts(ctx context.Context, outboxID int64, errMsg string, nextAttemptAt time.Time, maxAttempts int) error {
+	query := `
+		UPDATE outbox_notifications 
+		SET attempts = attempts + 1, last_error = $1, dead = attempts + 1 >= $2,
+			claimed_by = NULL, claimed_at = NULL, next_attempt_at = $3
+		WHERE id = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, errMsg, maxAttempts, nextAttemptAt, outboxID)
+	if err != nil {
+		return fmt.Errorf("failed to increment outbox attempts: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeadOutbox returns outbox items that exhausted their publish attempts, oldest first
+func (r *PostgresNotificationRepository) ListDeadOutbox(ctx context.Context, limit, offset int) ([]models.OutboxNotification, error) {
+	query := `
+		SELECT id, notification_id, topic, payload, published, created_at, published_at,
+			   claimed_by, claimed_at, attempts, last_error, dead, next_attempt_at
+		FROM outbox_notifications
+		WHERE dead = true
+		ORDER BY created_at ASC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var outboxItems []models.OutboxNotification
+	for rows.Next() {
+		var item models.OutboxNotification
+		err := rows.Scan(
+			&item.ID, &item.NotificationID, &item.Topic, &item.Payload,
+			&item.Published, &item.CreatedAt, &item.PublishedAt,
+			&item.ClaimedBy, &item.ClaimedAt, &item.Attempts, &item.LastError, &item.Dead,
+			&item.NextAttemptAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox item: %w", err)
+		}
+		outboxItems = append(outboxItems, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox items: %w", err)
+	}
+
+	return outboxItems, nil
+}
+
+// RequeueDeadOutbox resets a dead outbox item so the next processor tick
+// publishes it again with a fresh attempt budget
+func (r *PostgresNotificationRepository) RequeueDeadOutbox(ctx context.Context, outboxID int64) error {
+	query := `
+		UPDATE outbox_notifications
+		SET dead = false, attempts = 0, next_attempt_at = NULL,
+			claimed_by = NULL, claimed_at = NULL
+		WHERE id = $1 AND dead = true
+	`
+
+	result, err := r.db.ExecContext(ctx, query, outboxID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue outbox item: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: no dead outbox item %d", ErrOutboxNotFound, outboxID)
+	}
+
+	return nil
+}
+
+// CreateOutboxEntry creates a new outbox entry
+func (r *PostgresNotificationRepository) CreateOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error {
+	return insertOutboxEntry(ctx, r.db, outboxItem)
+}
+
+// RequeueOutboxEntry queues a notification for publishing again. Its existing
+// outbox rows are reset to unpublished with the new payload and a fresh attempt
+// budget; a new row is inserted when the original has already been purged.
+func (r *PostgresNotificationRepository) RequeueOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin requeue transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requeueOutboxEntry(ctx, tx, outboxItem); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit requeue transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueFailedNotification moves a failed notification back to queued and
+// requeues its outbox entry in one transaction, reporting whether it was still
+// failed. A notification another producer already requeued is left alone.
+func (r *PostgresNotificationRepository) RequeueFailedNotification(ctx context.Context, outboxItem *models.OutboxNotification) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin requeue transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE notifications SET status = $1 WHERE id = $2 AND status = $3`
+
+	result, err := tx.ExecContext(ctx, query, models.StatusQueued, outboxItem.NotificationID, models.StatusFailed)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue notification: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := requeueOutboxEntry(ctx, tx, outboxItem); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit requeue transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// requeueOutboxEntry resets a notification's outbox rows to unpublished, or
+// inserts one when none is left
+func requeueOutboxEntry(ctx context.Context, db execer, outboxItem *models.OutboxNotification) error {
+	query := `
+		UPDATE outbox_notifications
+		SET topic = $2, payload = $3, published = false, published_at = NULL,
+			claimed_by = NULL, claimed_at = NULL, attempts = 0, last_error = NULL,
+			dead = false, next_attempt_at = NULL
+		WHERE notification_id = $1
+	`
+
+	result, err := db.ExecContext(ctx, query, outboxItem.NotificationID, outboxItem.Topic, outboxItem.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to requeue outbox entry: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return insertOutboxEntry(ctx, db, outboxItem)
+	}
+	return nil
+}
+
+// insertOutboxEntry inserts an outbox row
+func insertOutboxEntry(ctx context.Context, db execer, outboxItem *models.OutboxNotification) error {
+	query := `
+		INSERT INTO outbox_notifications (
+			notification_id, topic, payload, published, created_at
+		) VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := db.ExecContext(ctx, query,
+		outboxItem.NotificationID,
+		outboxItem.Topic,
+		outboxItem.Payload, // JSONMap handles JSON serialization automatically
+		outboxItem.Published,
+		outboxItem.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create outbox entry: %w", err)
+	}
+
+	return nil
+}
+
+// preferenceColumns is the column list read by scanPreference
+const preferenceColumns = `id, user_id, type, channel, enabled, quiet_hours_start, quiet_hours_end,
+			   max_per_day, last_sent_at, metadata, created_at, updated_at`
+
+// scanPreference scans a row selected with preferenceColumns
+func scanPreference(row rowScanner, pref *models.UserNotificationPreferences) error {
+	return row.Scan(
+		&pref.ID, &pref.UserID, &pref.Type, &pref.Channel, &pref.Enabled,
+		&pref.QuietHoursStart, &pref.QuietHoursEnd, &pref.MaxPerDay,
+		&pref.LastSentAt, &pref.Metadata, &pref.CreatedAt, &pref.UpdatedAt,
+	)
+}
+
+// GetUserPreferences retrieves notification preferences for a user
+func (r *PostgresNotificationRepository) GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error) {
+	query := `
+		SELECT ` + preferenceColumns + `
+		FROM user_notification_preferences 
+		WHERE user_id = $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user preferences: %w", err)
+	}
+	defer rows.Close()
+
+	var preferences []models.UserNotificationPreferences
+	for rows.Next() {
+		var pref models.UserNotificationPreferences
+		err := scanPreference(rows, &pref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preference: %w", err)
+		}
+		preferences = append(preferences, pref)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+// GetUserPreference retrieves a user's preference for a type and channel,
+// returning ErrPreferenceNotFound when none is stored
+func (r *PostgresNotificationRepository) GetUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) (*models.UserNotificationPreferences, error) {
+	query := `
+		SELECT ` + preferenceColumns + `
+		FROM user_notification_preferences
+		WHERE user_id = $1 AND type = $2 AND channel = $3
+	`
+
+	var pref models.UserNotificationPreferences
+	err := scanPreference(r.db.QueryRowContext(ctx, query, userID, notificationType, channel), &pref)
+	if err == sql.ErrNoRows {
+		return nil, ErrPreferenceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user preference: %w", err)
+	}
+
+	return &pref, nil
+}
+
+// UpdateUserPreferences inserts or updates a user's preference for a type and
+// channel and returns the stored row
+func (r *PostgresNotificationRepository) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) (*models.UserNotificationPreferences, error) {
+	query := `
+		INSERT INTO user_notification_preferences (
+			user_id, type, channel, enabled, quiet_hours_start, quiet_hours_end,
+			max_per_day, metadata, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, type, channel) 
+		DO UPDATE SET 
+			enabled = EXCLUDED.enabled,
+			quiet_hours_start = EXCLUDED.quiet_hours_start,
+			quiet_hours_end = EXCLUDED.quiet_hours_end,
+			max_per_day = EXCLUDED.max_per_day,
+			metadata = EXCLUDED.metadata,
+			updated_at = EXCLUDED.updated_at
+		RETURNING ` + preferenceColumns
+
+	now := time.Now()
+	var stored models.UserNotificationPreferences
+	err := scanPreference(r.db.QueryRowContext(ctx, query,
+		userID, prefs.Type, prefs.Channel, prefs.Enabled,
+		prefs.QuietHoursStart, prefs.QuietHoursEnd, prefs.MaxPerDay,
+		prefs.Metadata, now, // JSONMap handles JSON serialization automatically
+	), &stored)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	return &stored, nil
+}
+
+// UpdateUserPreferencesBatch inserts or updates several of a user's preferences
+// in one statement and returns the user's full preference set, ordered by type
+// and channel. Each type and channel may appear only once in prefs.
+func (r *PostgresNotificationRepository) UpdateUserPreferencesBatch(ctx context.Context, userID uuid.UUID, prefs []models.UserNotificationPreferences) ([]models.UserNotificationPreferences, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin preferences transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	values := make([]string, len(prefs))
+	args := []interface{}{userID, now}
+	for i, pref := range prefs {
+		n := len(args)
+		values[i] = fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $2)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+		args = append(args, pref.Type, pref.Channel, pref.Enabled,
+			pref.QuietHoursStart, pref.QuietHoursEnd, pref.MaxPerDay, pref.Metadata)
+	}
+
+	upsert := `
+		INSERT INTO user_notification_preferences (
+			user_id, type, channel, enabled, quiet_hours_start, quiet_hours_end,
+			max_per_day, metadata, updated_at
+		) VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (user_id, type, channel)
+		DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			quiet_hours_start = EXCLUDED.quiet_hours_start,
+			quiet_hours_end = EXCLUDED.quiet_hours_end,
+			max_per_day = EXCLUDED.max_per_day,
+			metadata = EXCLUDED.metadata,
+			updated_at = EXCLUDED.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, upsert, args...); err != nil {
+		return nil, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	query := `
+		SELECT ` + preferenceColumns + `
+		FROM user_notification_preferences
+		WHERE user_id = $1
+		ORDER BY type, channel
+	`
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user preferences: %w", err)
+	}
+	defer rows.Close()
+
+	var stored []models.UserNotificationPreferences
+	for rows.Next() {
+		var pref models.UserNotificationPreferences
+		if err := scanPreference(rows, &pref); err != nil {
+			return nil, fmt.Errorf("failed to scan preference: %w", err)
+		}
+		stored = append(stored, pref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preferences: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit preferences transaction: %w", err)
+	}
+
+	return stored, nil
+}
+
+// DeleteUserPreference removes a user's preference for a type and channel so
+// the defaults apply again, returning ErrPreferenceNotFound when none exists
+func (r *PostgresNotificationRepository) DeleteUserPreference(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel) error {
+	query := `
+		DELETE FROM user_notification_preferences
+		WHERE user_id = $1 AND type = $2 AND channel = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, notificationType, channel)
+	if err != nil {
+		return fmt.Errorf("failed to delete user preference: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrPreferenceNotFound
+	}
+
+	return nil
+}
+
+// DeleteUserPreferences removes every preference of a user and returns how
+// many were deleted
+func (r *PostgresNotificationRepository) DeleteUserPreferences(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `DELETE FROM user_notification_preferences WHERE user_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user preferences: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// MarkPreferenceSent records when a notification of the preference's type and
+// channel was last queued for the user
+func (r *PostgresNotificationRepository) MarkPreferenceSent(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, channel models.NotificationChannel, sentAt time.Time) error {
+	query := `
+		UPDATE user_notification_preferences
+		SET last_sent_at = $4
+		WHERE user_id = $1 AND type = $2 AND channel = $3
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, notificationType, channel, sentAt); err != nil {
+		return fmt.Errorf("failed to mark preference sent: %w", err)
+	}
+
+	return nil
+}
+
+// GetUnreadCount counts a user's unread notifications, skipping suppressed and
+// cancelled ones the user never received. An empty channel counts every channel.
+func (r *PostgresNotificationRepository) GetUnreadCount(ctx context.Context, userID uuid.UUID, channel models.NotificationChannel) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = $1 AND read_at IS NULL AND status NOT IN ($2, $3)
+	`
+	args := []interface{}{userID, models.StatusSuppressed, models.StatusCancelled}
+	if channel != "" {
+		query += ` AND channel = $4`
+		args = append(args, channel)
+	}
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountNotificationsForUserTypeSince counts the user's notifications of a type
+// created since a time, leaving out suppressed ones since they were never sent
+func (r *PostgresNotificationRepository) CountNotificationsForUserTypeSince(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = $1 AND type = $2 AND created_at >= $3 AND status <> $4
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID, notificationType, since, models.StatusSuppressed).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// HasNotificationOfTypeToday reports whether the user got a notification of a
+// type since midnight in the tz timezone, leaving out suppressed ones since
+// they were never sent
+func (r *PostgresNotificationRepository) HasNotificationOfTypeToday(ctx context.Context, userID uuid.UUID, notificationType models.NotificationType, tz string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE user_id = $1 AND type = $2 AND status <> $4
+			  AND created_at >= date_trunc('day', NOW() AT TIME ZONE $3) AT TIME ZONE $3
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, userID, notificationType, tz, models.StatusSuppressed).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check today's notifications: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetUserByID retrieves a user
+func (r *PostgresNotificationRepository) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	query := `
+		SELECT user_id, name, email, COALESCE(total_xp, 0), created_at, updated_at
+		FROM users
+		WHERE user_id = $1
+	`
+
+	var user models.User
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&user.ID, &user.Name, &user.Email, &user.TotalXP, &user.CreatedAt, &user.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUsersNeedingDailyReminders gets a page of up to limit users who need a daily reminder today
+// after the given user ID, in user ID order
+func (r *PostgresNotificationRepository) GetUsersNeedingDailyReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT DISTINCT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_notification_preferences unp ON u.user_id = unp.user_id
+		WHERE unp.type = 'daily_reminder'
+		  AND unp.channel = 'in_app'
+		  AND unp.enabled = true
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'daily_reminder'
+			  AND n.created_at::date = current_date
+		  )
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "users needing daily reminders", limit, after)
+}
+
+// GetUsersNeedingStreakReminders gets a page of up to limit users whose practice streak is at risk today
+// after the given user ID, in user ID order
+func (r *PostgresNotificationRepository) GetUsersNeedingStreakReminders(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT DISTINCT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_notification_preferences unp ON u.user_id = unp.user_id
+		JOIN user_engagement_streaks ues ON u.user_id = ues.user_id
+		WHERE unp.type = 'streak_reminder'
+		  AND unp.channel = 'in_app'
+		  AND unp.enabled = true
+		  AND ues.streak_type = 'practice'
+		  AND ues.current_streak > 0
+		  AND ues.last_activity_date < current_date
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'streak_reminder'
+			  AND n.created_at::date = current_date
+		  )
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "users needing streak reminders", limit, after)
+}
+
+// GetUsersNeedingLastChanceAlerts gets a page of up to limit users after the
+// given user ID, in user ID order, whose practice streak is still unextended
+// while their local time is in [startHour, endHour), who were already sent a
+// streak reminder and no last-chance alert on that local day, and who have not
+// turned last-chance alerts off. Days and hours are taken in the streak's timezone.
+func (r *PostgresNotificationRepository) GetUsersNeedingLastChanceAlerts(ctx context.Context, startHour, endHour, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_engagement_streaks ues ON u.user_id = ues.user_id
+		CROSS JOIN LATERAL (
+			SELECT NOW() AT TIME ZONE COALESCE(ues.timezone, 'UTC') AS local_now
+		) tz
+		WHERE ues.streak_type = 'practice'
+		  AND ues.current_streak > 0
+		  AND ues.last_activity_date < tz.local_now::date
+		  AND EXTRACT(HOUR FROM tz.local_now) >= $3
+		  AND EXTRACT(HOUR FROM tz.local_now) < $4
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_notification_preferences unp
+			WHERE unp.user_id = u.user_id
+			  AND unp.type = 'last_chance_alert'
+			  AND unp.channel = 'in_app'
+			  AND unp.enabled = false
+		  )
+		  AND EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'streak_reminder'
+			  AND n.status <> 'suppressed'
+			  AND n.created_at >= date_trunc('day', tz.local_now) AT TIME ZONE COALESCE(ues.timezone, 'UTC')
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'last_chance_alert'
+			  AND n.created_at >= date_trunc('day', tz.local_now) AT TIME ZONE COALESCE(ues.timezone, 'UTC')
+		  )
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "users needing last-chance alerts", limit, after, startHour, endHour)
+}
+
+// GetUsersNeedingXPGoalReminders gets a page of up to limit users after the
+// given user ID, in user ID order, who set a weekly XP goal for the current
+// week and have earned less than threshold of it by a Thursday or Friday
+// from reminderHour on. It leaves out users who turned the reminders off or
+// were already reminded this week. Weeks and hours are taken in the streak's
+// timezone, and XP is the points of the week's practice_completed events.
+func (r *PostgresNotificationRepository) GetUsersNeedingXPGoalReminders(ctx context.Context, reminderHour int, threshold float64, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_goals g ON g.user_id = u.user_id AND g.goal_type = 'weekly_xp'
+		LEFT JOIN user_engagement_streaks ues ON ues.user_id = u.user_id AND ues.streak_type = 'practice'
+		CROSS JOIN LATERAL (
+			SELECT NOW() AT TIME ZONE COALESCE(ues.timezone, 'UTC') AS now,
+			       date_trunc('week', NOW() AT TIME ZONE COALESCE(ues.timezone, 'UTC')) AT TIME ZONE COALESCE(ues.timezone, 'UTC') AS week_start
+		) local
+		WHERE g.week_start = local.now::date - (EXTRACT(ISODOW FROM local.now)::int - 1)
+		  AND EXTRACT(ISODOW FROM local.now) IN (4, 5)
+		  AND EXTRACT(HOUR FROM local.now) >= $3
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_notification_preferences unp
+			WHERE unp.user_id = u.user_id
+			  AND unp.type = 'xp_goal_reminder'
+			  AND unp.channel = 'in_app'
+			  AND unp.enabled = false
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'xp_goal_reminder'
+			  AND n.created_at >= local.week_start
+		  )
+		  AND (
+			-- POST /notifications takes any metadata, so points that are not an int are skipped, not cast
+			SELECT COALESCE(SUM(CASE WHEN n.metadata->>'points' ~ '^-?[0-9]{1,9}$' THEN (n.metadata->>'points')::int END), 0)
+			FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.metadata->>'event' = 'practice_completed'
+			  AND n.created_at >= local.week_start
+		  ) < $4 * g.target
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "users needing XP goal reminders", limit, after, reminderHour, threshold)
+}
+
+// GetActiveUsersForWeeklyRecap gets a page of up to limit users with an active streak and no recap this week
+// after the given user ID, in user ID order
+func (r *PostgresNotificationRepository) GetActiveUsersForWeeklyRecap(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT DISTINCT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_notification_preferences unp ON u.user_id = unp.user_id
+		WHERE unp.type = 'weekly_recap'
+		  AND unp.channel = 'in_app'
+		  AND unp.enabled = true
+		  AND EXISTS (
+			SELECT 1 FROM user_engagement_streaks ues
+			WHERE ues.user_id = u.user_id
+			  AND ues.streak_type = 'practice'
+			  AND ues.current_streak > 0
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'weekly_recap'
+			  AND n.created_at >= date_trunc('week', current_date)
+		  )
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "active users for weekly recap", limit, after)
+}
+
+// GetInactiveUsersForEngagement gets a page of up to limit users after the
+// given user ID, in user ID order, who have not practiced for at least 7 days
+// of their local calendar and are due a higher nudge tier (7, 14 or 30 days)
+// than any nudge sent since they were last active
+func (r *PostgresNotificationRepository) GetInactiveUsersForEngagement(ctx context.Context, limit int, after uuid.UUID) ([]models.User, error) {
+	query := `
+		SELECT u.user_id, u.name, u.email
+		FROM users u
+		JOIN user_notification_preferences unp ON u.user_id = unp.user_id
+		JOIN user_engagement_streaks ues ON u.user_id = ues.user_id
+		CROSS JOIN LATERAL (
+			SELECT (NOW() AT TIME ZONE COALESCE(ues.timezone, 'UTC'))::date - ues.last_activity_date AS days
+		) inactive
+		WHERE unp.type = 'we_miss_you'
+		  AND unp.channel = 'in_app'
+		  AND unp.enabled = true
+		  AND ues.streak_type = 'practice'
+		  AND inactive.days >= 7
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.user_id
+			  AND n.type = 'we_miss_you'
+			  AND n.created_at >= ues.last_activity_date
+			  AND COALESCE((n.metadata->>'nudge_tier')::int, 1) >=
+				CASE WHEN inactive.days >= 30 THEN 3 WHEN inactive.days >= 14 THEN 2 ELSE 1 END
+		  )
+		  AND u.user_id > $1
+		ORDER BY u.user_id
+		LIMIT $2
+	`
+
+	return queryUserPage(ctx, r.db, query, "inactive users for engagement nudge", limit, after)
 }
 
-// CreateOutboxEntry creates a new outbox entry
-func (r *PostgresNotificationRepository) CreateOutboxEntry(ctx context.Context, outboxItem *models.OutboxNotification) error {
+// GetLastEngagementNudgeTier returns the highest nudge tier the user was sent
+// since the given time, or 0 when none was. Nudges from before tiers were
+// recorded count as the first tier.
+func (r *PostgresNotificationRepository) GetLastEngagementNudgeTier(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
 	query := `
-		INSERT INTO outbox_notifications (
-			notification_id, topic, payload, published, created_at
-		) VALUES ($1, $2, $3, $4, $5)
+		SELECT COALESCE(MAX(COALESCE((metadata->>'nudge_tier')::int, 1)), 0)
+		FROM notifications
+		WHERE user_id = $1 AND type = 'we_miss_you' AND created_at >= $2
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
-		outboxItem.NotificationID,
-		outboxItem.Topic,
-		outboxItem.Payload, // JSONMap handles JSON serialization automatically
-		outboxItem.Published,
-		outboxItem.CreatedAt,
-	)
+	var tier int
+	if err := r.db.QueryRowContext(ctx, query, userID, since).Scan(&tier); err != nil {
+		return 0, fmt.Errorf("failed to get last engagement nudge tier: %w", err)
+	}
+
+	return tier, nil
+}
 
+// queryUserPage runs a user page query taking the last seen user ID as $1,
+// the page size as $2 and any further args from $3 on, selecting user_id,
+// name and email
+func queryUserPage(ctx context.Context, db *sql.DB, query, what string, limit int, after uuid.UUID, args ...interface{}) ([]models.User, error) {
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{after, limit}, args...)...)
 	if err != nil {
-		return fmt.Errorf("failed to create outbox entry: %w", err)
+		return nil, fmt.Errorf("failed to query %s: %w", what, err)
 	}
+	defer rows.Close()
 
-	return nil
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", what, err)
+	}
+
+	return users, nil
 }
 
-// GetUserPreferences retrieves notification preferences for a user
-func (r *PostgresNotificationRepository) GetUserPreferences(ctx context.Context, userID uuid.UUID) ([]models.UserNotificationPreferences, error) {
+// GetPracticeStreaks retrieves every practice streak with the user's timezone
+func (r *PostgresNotificationRepository) GetPracticeStreaks(ctx context.Context) ([]models.UserEngagementStreak, error) {
 	query := `
-		SELECT id, user_id, type, channel, enabled, quiet_hours_start, quiet_hours_end,
-			   max_per_day, last_sent_at, metadata, created_at, updated_at
-		FROM user_notification_preferences 
-		WHERE user_id = $1
+		SELECT ues.user_id, ues.streak_type, COALESCE(ues.timezone, 'UTC')
+		FROM user_engagement_streaks ues
+		WHERE ues.streak_type = 'practice'
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query user preferences: %w", err)
+		return nil, fmt.Errorf("failed to query practice streaks: %w", err)
 	}
 	defer rows.Close()
 
-	var preferences []models.UserNotificationPreferences
+	var streaks []models.UserEngagementStreak
 	for rows.Next() {
-		var pref models.UserNotificationPreferences
-		err := rows.Scan(
-			&pref.ID, &pref.UserID, &pref.Type, &pref.Channel, &pref.Enabled,
-			&pref.QuietHoursStart, &pref.QuietHoursEnd, &pref.MaxPerDay,
-			&pref.LastSentAt, &pref.Metadata, &pref.CreatedAt, &pref.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan preference: %w", err)
+		var streak models.UserEngagementStreak
+		if err := rows.Scan(&streak.UserID, &streak.StreakType, &streak.Timezone); err != nil {
+			return nil, fmt.Errorf("failed to scan practice streak: %w", err)
 		}
-		preferences = append(preferences, pref)
+		streaks = append(streaks, streak)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating preferences: %w", err)
+		return nil, fmt.Errorf("error iterating practice streaks: %w", err)
 	}
 
-	return preferences, nil
+	return streaks, nil
 }
 
-// UpdateUserPreferences updates notification preferences for a user
-func (r *PostgresNotificationRepository) UpdateUserPreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserNotificationPreferences) error {
-	query := `
-		INSERT INTO user_notification_preferences (
-			user_id, type, channel, enabled, quiet_hours_start, quiet_hours_end,
-			max_per_day, metadata, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT (userID, type, channel) 
-		DO UPDATE SET 
-			enabled = EXCLUDED.enabled,
-			quiet_hours_start = EXCLUDED.quiet_hours_start,
-			quiet_hours_end = EXCLUDED.quiet_hours_end,
-			max_per_day = EXCLUDED.max_per_day,
-			metadata = EXCLUDED.metadata,
-			updated_at = EXCLUDED.updated_at
-	`
+// streakColumns is the column list read by scanStreak
+const streakColumns = `id, user_id, streak_type, current_streak, longest_streak,
+			   last_activity_date, streak_start_date, total_activities, timezone,
+			   typical_hour, COALESCE(typical_hour_confidence, 0), created_at, updated_at`
 
-	now := time.Now()
-	_, err := r.db.ExecContext(ctx, query,
-		userID, prefs.Type, prefs.Channel, prefs.Enabled,
-		prefs.QuietHoursStart, prefs.QuietHoursEnd, prefs.MaxPerDay,
-		prefs.Metadata, now, // JSONMap handles JSON serialization automatically
+// scanStreak scans a row selected with streakColumns
+func scanStreak(row rowScanner, streak *models.UserEngagementStreak) error {
+	return row.Scan(
+		&streak.ID, &streak.UserID, &streak.StreakType, &streak.CurrentStreak,
+		&streak.LongestStreak, &streak.LastActivityDate, &streak.StreakStartDate,
+		&streak.TotalActivities, &streak.Timezone, &streak.TypicalHour,
+		&streak.TypicalHourConfidence, &streak.CreatedAt, &streak.UpdatedAt,
 	)
-
-	if err != nil {
-		return fmt.Errorf("failed to update user preferences: %w", err)
-	}
-
-	return nil
 }
 
 // GetUserEngagementStreak retrieves engagement streak for a user
 func (r *PostgresNotificationRepository) GetUserEngagementStreak(ctx context.Context, userID uuid.UUID, streakType string) (*models.UserEngagementStreak, error) {
 	query := `
-		SELECT id, user_id, streak_type, current_streak, longest_streak,
-			   last_activity_date, streak_start_date, total_activities, timezone,
-			   created_at, updated_at
+		SELECT ` + streakColumns + `
 		FROM user_engagement_streaks 
 		WHERE user_id = $1 AND streak_type = $2
 	`
 
 	var streak models.UserEngagementStreak
-	err := r.db.QueryRowContext(ctx, query, userID, streakType).Scan(
-		&streak.ID, &streak.UserID, &streak.StreakType, &streak.CurrentStreak,
-		&streak.LongestStreak, &streak.LastActivityDate, &streak.StreakStartDate,
-		&streak.TotalActivities, &streak.Timezone, &streak.CreatedAt, &streak.UpdatedAt,
-	)
+	err := scanStreak(r.db.QueryRowContext(ctx, query, userID, streakType), &streak)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("streak not found for user %s and type %s", userID, streakType)
+			return nil, fmt.Errorf("%w for user %s and type %s", ErrStreakNotFound, userID, streakType)
 		}
 		return nil, fmt.Errorf("failed to get user engagement streak: %w", err)
 	}
@@ -392,11 +1881,179 @@ func (r *PostgresNotificationRepository) UpdateUserEngagementStreak(ctx context.
 	return nil
 }
 
+// RecordStreakActivity applies an activity to the user's streak of a type and
+// stores the result in one transaction. The row is created in the column
+// defaults if missing and locked with FOR UPDATE before apply sees it, so
+// concurrent activities for the same streak apply one after the other rather
+// than overwriting each other's counts.
+func (r *PostgresNotificationRepository) RecordStreakActivity(ctx context.Context, userID uuid.UUID, streakType string, apply func(*models.UserEngagementStreak)) (*models.UserEngagementStreak, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin streak transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createQuery := `
+		INSERT INTO user_engagement_streaks (user_id, streak_type)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, streak_type) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, createQuery, userID, streakType); err != nil {
+		return nil, fmt.Errorf("failed to create user engagement streak: %w", err)
+	}
+
+	lockQuery := `
+		SELECT ` + streakColumns + `
+		FROM user_engagement_streaks
+		WHERE user_id = $1 AND streak_type = $2
+		FOR UPDATE
+	`
+	var streak models.UserEngagementStreak
+	if err := scanStreak(tx.QueryRowContext(ctx, lockQuery, userID, streakType), &streak); err != nil {
+		return nil, fmt.Errorf("failed to lock user engagement streak: %w", err)
+	}
+
+	apply(&streak)
+	streak.UpdatedAt = time.Now()
+
+	updateQuery := `
+		UPDATE user_engagement_streaks
+		SET current_streak = $1, longest_streak = $2, last_activity_date = $3,
+			streak_start_date = $4, total_activities = $5, updated_at = $6
+		WHERE id = $7
+	`
+	_, err = tx.ExecContext(ctx, updateQuery,
+		streak.CurrentStreak, streak.LongestStreak, streak.LastActivityDate,
+		streak.StreakStartDate, streak.TotalActivities, streak.UpdatedAt, streak.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user engagement streak: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit streak transaction: %w", err)
+	}
+
+	return &streak, nil
+}
+
+// UpdateStreakTypicalHour stores the user's typical practice hour on an existing streak
+func (r *PostgresNotificationRepository) UpdateStreakTypicalHour(ctx context.Context, userID uuid.UUID, streakType string, typicalHour *int, confidence float64) error {
+	query := `
+		UPDATE user_engagement_streaks 
+		SET typical_hour = $1, typical_hour_confidence = $2, updated_at = $3
+		WHERE user_id = $4 AND streak_type = $5
+	`
+
+	_, err := r.db.ExecContext(ctx, query, typicalHour, confidence, time.Now(), userID, streakType)
+	if err != nil {
+		return fmt.Errorf("failed to update streak typical hour: %w", err)
+	}
+
+	return nil
+}
+
+// GetPracticeTimestamps retrieves the most recent practice session timestamps for a user
+func (r *PostgresNotificationRepository) GetPracticeTimestamps(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error) {
+	query := `
+		SELECT created_at
+		FROM notifications 
+		WHERE user_id = $1 
+		  AND metadata->>'event' = 'practice_completed'
+		ORDER BY created_at DESC 
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query practice timestamps: %w", err)
+	}
+	defer rows.Close()
+
+	var timestamps []time.Time
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			return nil, fmt.Errorf("failed to scan practice timestamp: %w", err)
+		}
+		timestamps = append(timestamps, ts)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating practice timestamps: %w", err)
+	}
+
+	return timestamps, nil
+}
+
+// GetWeeklyActivitySummary aggregates the user's practice sessions in the week
+// starting at weekStart, a local midnight whose location is the user's
+// timezone. Sessions are the practice_completed notifications, so XP is the
+// sum of their points; points that are not an integer count as none.
+func (r *PostgresNotificationRepository) GetWeeklyActivitySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (*models.WeeklyActivitySummary, error) {
+	query := `
+		SELECT (created_at AT TIME ZONE $4)::date AS day,
+		       COUNT(*),
+		       COALESCE(SUM(CASE WHEN metadata->>'points' ~ '^-?[0-9]{1,9}$' THEN (metadata->>'points')::int END), 0)
+		FROM notifications
+		WHERE user_id = $1
+		  AND metadata->>'event' = 'practice_completed'
+		  AND created_at >= $2
+		  AND created_at < $3
+		GROUP BY day
+	`
+
+	// AddDate keeps local midnight across a daylight saving change
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	rows, err := r.db.QueryContext(ctx, query, userID, weekStart, weekEnd, weekStart.Location().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weekly activity: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &models.WeeklyActivitySummary{WeekStart: weekStart}
+	firstDay := time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, time.UTC)
+	for rows.Next() {
+		var day time.Time
+		var sessions, xp int
+		if err := rows.Scan(&day, &sessions, &xp); err != nil {
+			return nil, fmt.Errorf("failed to scan weekly activity: %w", err)
+		}
+
+		// Dates come back as UTC midnights, so whole days separate them
+		index := int(day.Sub(firstDay).Hours() / 24)
+		if index < 0 || index >= len(summary.DailySessions) {
+			continue
+		}
+		summary.DailySessions[index] += sessions
+		summary.SessionsCompleted += sessions
+		summary.XPEarned += xp
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating weekly activity: %w", err)
+	}
+
+	run := 0
+	for _, sessions := range summary.DailySessions {
+		if sessions == 0 {
+			run = 0
+			continue
+		}
+		summary.ActiveDays++
+		run++
+		if run > summary.LongestStreak {
+			summary.LongestStreak = run
+		}
+	}
+
+	return summary, nil
+}
+
 // GetNotificationsByStatus retrieves notifications by their delivery status
 func (r *PostgresNotificationRepository) GetNotificationsByStatus(ctx context.Context, status models.DeliveryStatus, limit int) ([]models.Notification, error) {
 	query := `
-		SELECT id, user_id, type, channel, priority, template_id, title, message,
-			   metadata, dedupe_key, created_at, scheduled_for, sent_at, delivered_at, read_at, status
+		SELECT ` + notificationColumns + `
 		FROM notifications 
 		WHERE status = $1 
 		ORDER BY created_at ASC 
@@ -412,11 +2069,7 @@ func (r *PostgresNotificationRepository) GetNotificationsByStatus(ctx context.Co
 	var notifications []models.Notification
 	for rows.Next() {
 		var n models.Notification
-		err := rows.Scan(
-			&n.ID, &n.UserID, &n.Type, &n.Channel, &n.Priority, &n.TemplateID,
-			&n.Title, &n.Message, &n.Metadata, &n.DedupeKey, &n.CreatedAt,
-			&n.ScheduledFor, &n.SentAt, &n.DeliveredAt, &n.ReadAt, &n.Status,
-		)
+		err := scanNotification(rows, &n)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan notification: %w", err)
 		}
@@ -430,16 +2083,19 @@ func (r *PostgresNotificationRepository) GetNotificationsByStatus(ctx context.Co
 	return notifications, nil
 }
 
-// GetScheduledNotifications retrieves notifications scheduled to be sent before a specific time
+// GetScheduledNotifications retrieves queued notifications scheduled to be sent
+// before a specific time that have no outbox entry yet
 func (r *PostgresNotificationRepository) GetScheduledNotifications(ctx context.Context, before time.Time, limit int) ([]models.Notification, error) {
 	query := `
-		SELECT id, user_id, type, channel, priority, template_id, title, message,
-			   metadata, dedupe_key, created_at, scheduled_for, sent_at, delivered_at, read_at, status
-		FROM notifications 
-		WHERE scheduled_for IS NOT NULL 
-		  AND scheduled_for <= $1 
-		  AND status = $2
-		ORDER BY scheduled_for ASC 
+		SELECT ` + notificationColumns + `
+		FROM notifications n
+		WHERE n.scheduled_for IS NOT NULL
+		  AND n.scheduled_for <= $1
+		  AND n.status = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM outbox_notifications o WHERE o.notification_id = n.id
+		  )
+		ORDER BY n.scheduled_for ASC
 		LIMIT $3
 	`
 
@@ -452,11 +2108,7 @@ func (r *PostgresNotificationRepository) GetScheduledNotifications(ctx context.C
 	var notifications []models.Notification
 	for rows.Next() {
 		var n models.Notification
-		err := rows.Scan(
-			&n.ID, &n.UserID, &n.Type, &n.Channel, &n.Priority, &n.TemplateID,
-			&n.Title, &n.Message, &n.Metadata, &n.DedupeKey, &n.CreatedAt,
-			&n.ScheduledFor, &n.SentAt, &n.DeliveredAt, &n.ReadAt, &n.Status,
-		)
+		err := scanNotification(rows, &n)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan notification: %w", err)
 		}
@@ -470,32 +2122,245 @@ func (r *PostgresNotificationRepository) GetScheduledNotifications(ctx context.C
 	return notifications, nil
 }
 
-// CreateDeliveryAttempt creates a new delivery attempt record
-func (r *PostgresNotificationRepository) CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error {
+// OutboxBuilder builds the outbox entry a released scheduled notification is published from
+type OutboxBuilder func(notification *models.Notification) (*models.OutboxNotification, error)
+
+// ReleaseScheduledNotifications claims up to limit queued notifications scheduled
+// at or before the given time and gives each an outbox entry, in one transaction.
+// Rows are claimed by setting dispatched_at under FOR UPDATE SKIP LOCKED, so
+// concurrent callers never release the same notification twice; rows that were
+// given an outbox entry when created are skipped.
+func (r *PostgresNotificationRepository) ReleaseScheduledNotifications(ctx context.Context, before time.Time, limit int, build OutboxBuilder) ([]models.Notification, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin scheduled release transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
+		UPDATE notifications
+		SET dispatched_at = $1
+		WHERE id IN (
+			SELECT id
+			FROM notifications
+			WHERE scheduled_for IS NOT NULL
+			  AND scheduled_for <= $2
+			  AND status = $3
+			  AND dispatched_at IS NULL
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox_notifications o WHERE o.notification_id = notifications.id
+			  )
+			ORDER BY scheduled_for ASC
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + notificationColumns
+
+	rows, err := tx.QueryContext(ctx, query, time.Now(), before, models.StatusQueued, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled notifications: %w", err)
+	}
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled notifications: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not preserve the subquery order
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ScheduledFor.Before(*notifications[j].ScheduledFor)
+	})
+
+	for i := range notifications {
+		outboxItem, err := build(&notifications[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build outbox entry for notification %s: %w", notifications[i].ID, err)
+		}
+		if err := insertOutboxEntry(ctx, tx, outboxItem); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit scheduled release: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// CreateDeliveryAttempt creates a new delivery attempt record and refreshes the
+// notification's last-attempt summary in the same transaction
+func (r *PostgresNotificationRepository) CreateDeliveryAttempt(ctx context.Context, attempt *models.NotificationDeliveryAttempt) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin delivery attempt transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
 		INSERT INTO notification_delivery_attempts (
 			notification_id, attempt_no, status, error_code, error_message,
-			provider_message_id, latency_ms, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			provider_message_id, provider, latency_ms, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = tx.ExecContext(ctx, insertQuery,
 		attempt.NotificationID, attempt.AttemptNo, attempt.Status,
 		attempt.ErrorCode, attempt.ErrorMessage, attempt.ProviderMessageID,
-		attempt.LatencyMs, attempt.CreatedAt,
+		attempt.Provider, attempt.LatencyMs, attempt.CreatedAt,
 	)
-
 	if err != nil {
 		return fmt.Errorf("failed to create delivery attempt: %w", err)
 	}
 
+	// Older attempts recorded out of order must not overwrite a newer summary
+	summaryQuery := `
+		UPDATE notifications 
+		SET last_attempt_no = $1, last_attempt_status = $2, last_attempt_at = $3
+		WHERE id = $4 AND (last_attempt_no IS NULL OR last_attempt_no <= $1)
+	`
+
+	_, err = tx.ExecContext(ctx, summaryQuery,
+		attempt.AttemptNo, attempt.Status, attempt.CreatedAt, attempt.NotificationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification attempt summary: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delivery attempt: %w", err)
+	}
+
 	return nil
 }
 
+// GetDeliveryAttempts retrieves every delivery attempt for a notification, oldest first
+func (r *PostgresNotificationRepository) GetDeliveryAttempts(ctx context.Context, notificationID uuid.UUID) ([]models.NotificationDeliveryAttempt, error) {
+	query := `
+		SELECT id, notification_id, attempt_no, status, error_code, error_message,
+			   provider_message_id, provider, latency_ms, created_at
+		FROM notification_delivery_attempts
+		WHERE notification_id = $1
+		ORDER BY attempt_no ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, notificationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.NotificationDeliveryAttempt{}
+	for rows.Next() {
+		var a models.NotificationDeliveryAttempt
+		err := rows.Scan(
+			&a.ID, &a.NotificationID, &a.AttemptNo, &a.Status, &a.ErrorCode, &a.ErrorMessage,
+			&a.ProviderMessageID, &a.Provider, &a.LatencyMs, &a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivery attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// GetAttemptSummaryMismatches returns notifications whose last-attempt summary
+// does not match their latest delivery attempt row
+func (r *PostgresNotificationRepository) GetAttemptSummaryMismatches(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT n.id
+		FROM notifications n
+		LEFT JOIN LATERAL (
+			SELECT a.attempt_no, a.status, a.created_at
+			FROM notification_delivery_attempts a
+			WHERE a.notification_id = n.id
+			ORDER BY a.attempt_no DESC, a.id DESC
+			LIMIT 1
+		) latest ON true
+		WHERE n.last_attempt_no IS DISTINCT FROM latest.attempt_no
+		   OR n.last_attempt_status IS DISTINCT FROM latest.status
+		   OR n.last_attempt_at IS DISTINCT FROM latest.created_at
+		LIMIT $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempt summary mismatches: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan notification id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attempt summary mismatches: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetDeliveryLatencySamples returns creation, delivery and read times for
+// notifications of the given priorities created since the given time
+func (r *PostgresNotificationRepository) GetDeliveryLatencySamples(ctx context.Context, priorities []models.PriorityLevel, since time.Time) ([]models.DeliveryLatencySample, error) {
+	query := `
+		SELECT priority, created_at, delivered_at, read_at
+		FROM notifications
+		WHERE priority::text = ANY($1) AND created_at >= $2
+	`
+
+	names := make([]string, len(priorities))
+	for i, p := range priorities {
+		names[i] = string(p)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(names), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery latency samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []models.DeliveryLatencySample
+	for rows.Next() {
+		var s models.DeliveryLatencySample
+		if err := rows.Scan(&s.Priority, &s.CreatedAt, &s.DeliveredAt, &s.ReadAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery latency sample: %w", err)
+		}
+		samples = append(samples, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivery latency samples: %w", err)
+	}
+
+	return samples, nil
+}
+
 // GetNotificationTemplates retrieves notification templates by type and channel
 func (r *PostgresNotificationRepository) GetNotificationTemplates(ctx context.Context, notificationType models.NotificationType, channel models.NotificationChannel) ([]models.NotificationTemplate, error) {
 	query := `
-		SELECT id, type, channel, title, body, locale, priority, is_active, version, created_at
+		SELECT id, type, channel, title, body, locale, priority, is_active, version, created_at,
+			   default_attachments
 		FROM notification_templates 
 		WHERE type = $1 AND channel = $2 AND is_active = true
 		ORDER BY version DESC
@@ -513,6 +2378,7 @@ func (r *PostgresNotificationRepository) GetNotificationTemplates(ctx context.Co
 		err := rows.Scan(
 			&t.ID, &t.Type, &t.Channel, &t.Title, &t.Body, &t.Locale,
 			&t.Priority, &t.IsActive, &t.Version, &t.CreatedAt,
+			&t.DefaultAttachments,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan template: %w", err)
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-notify/internal/config"
+	"kafka-notify/internal/database"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// integrationDB connects to a real, migrated Postgres using the DB_* settings,
+// skipping the test unless REPOSITORY_INTEGRATION_DB is set
+func integrationDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if os.Getenv("REPOSITORY_INTEGRATION_DB") == "" {
+		t.Skip("set REPOSITORY_INTEGRATION_DB to run against Postgres")
+	}
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	conn, err := database.NewConnectionManager(&cfg.Database)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.GetDB()
+}
+
+// seedUser inserts a user that is deleted, with its notifications, after the test
+func seedUser(t *testing.T, db *sql.DB) uuid.UUID {
+	t.Helper()
+	userID := uuid.New()
+	_, err := db.Exec(`INSERT INTO users (user_id, name, email) VALUES ($1, 'Seeded', $2)`,
+		userID, userID.String()+"@example.com")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE user_id = $1`, userID) })
+	return userID
+}
+
+type seededNotification struct {
+	notificationType models.NotificationType
+	channel          models.NotificationChannel
+	status           models.DeliveryStatus
+	createdAt        time.Time
+	readAt           *time.Time
+}
+
+func seedNotifications(t *testing.T, db *sql.DB, userID uuid.UUID, rows ...seededNotification) {
+	t.Helper()
+	for _, row := range rows {
+		_, err := db.Exec(`
+			INSERT INTO notifications (user_id, type, channel, message, status, created_at, read_at)
+			VALUES ($1, $2, $3, 'seeded', $4, $5, $6)
+		`, userID, row.notificationType, row.channel, row.status, row.createdAt, row.readAt)
+		require.NoError(t, err)
+	}
+}
+
+func TestGetUnreadCount_SeededRows(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+
+	now := time.Now()
+	seedNotifications(t, db, userID,
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusDelivered, now, nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusSent, now, nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusRead, now, &now},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusSuppressed, now, nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusCancelled, now, nil},
+		seededNotification{models.DailyReminder, models.ChannelPush, models.StatusDelivered, now, nil},
+		seededNotification{models.DailyReminder, models.ChannelEmail, models.StatusRead, now, &now},
+	)
+
+	repo := NewPostgresNotificationRepository(db)
+	all, err := repo.GetUnreadCount(ctx, userID, "")
+	require.NoError(t, err)
+	inApp, err := repo.GetUnreadCount(ctx, userID, models.ChannelInApp)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), all)
+	assert.Equal(t, int64(2), inApp)
+}
+
+func TestMarkAllAsRead_SeededRows(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+
+	cutoff := time.Now().Truncate(time.Second)
+	earlier := cutoff.Add(-time.Hour)
+	readAt := cutoff.Add(-30 * time.Minute)
+	seedNotifications(t, db, userID,
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusDelivered, earlier, nil},
+		seededNotification{models.StreakReminder, models.ChannelInApp, models.StatusDelivered, earlier, nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusDelivered, cutoff, nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusDelivered, cutoff.Add(time.Second), nil},
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusRead, earlier, &readAt},
+	)
+	repo := NewPostgresNotificationRepository(db)
+
+	// Only the matching type is touched
+	updated, err := repo.MarkAllAsRead(ctx, userID, cutoff, models.StreakReminder)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+
+	// The row created exactly at the cutoff is included, the later one is not,
+	// and the already read row keeps its read_at
+	updated, err = repo.MarkAllAsRead(ctx, userID, cutoff, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated)
+
+	unread, err := repo.GetUnreadCount(ctx, userID, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), unread)
+
+	var kept time.Time
+	require.NoError(t, db.QueryRow(`SELECT read_at FROM notifications WHERE user_id = $1 AND created_at = $2 AND status = 'read' ORDER BY read_at LIMIT 1`,
+		userID, earlier).Scan(&kept))
+	assert.WithinDuration(t, readAt, kept, time.Millisecond)
+
+	// Nothing left before the cutoff
+	updated, err = repo.MarkAllAsRead(ctx, userID, cutoff, "")
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+}
+
+func TestCreateNotificationWithIdempotencyKey_ConcurrentRequests(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	// Every request races to create a notification under the same key
+	const requests = 8
+	now := time.Now()
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := &models.Notification{
+				ID: models.NewNotificationID(), UserID: userID, Type: models.DailyReminder, Channel: models.ChannelInApp,
+				Priority: models.PriorityMedium, Message: "Time to practice", Status: models.StatusQueued, CreatedAt: now,
+			}
+			errs <- repo.CreateNotificationWithIdempotencyKey(ctx, n, nil, "req-7f3a", now.Add(-24*time.Hour))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrDuplicateIdempotencyKey)
+	}
+	assert.Equal(t, 1, created)
+
+	var stored int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1`, userID).Scan(&stored))
+	assert.Equal(t, 1, stored, "losing requests roll back their notification")
+
+	_, err := repo.GetNotificationByIdempotencyKey(ctx, userID, "req-7f3a", now.Add(-time.Minute))
+	assert.NoError(t, err)
+}
+
+func TestRecordStreakActivity_ConcurrentActivities(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	// Every request races to count an activity on a streak that doesn't exist yet
+	const requests = 8
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.RecordStreakActivity(ctx, userID, "practice", func(s *models.UserEngagementStreak) {
+				s.TotalActivities++
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	streak, err := repo.GetUserEngagementStreak(ctx, userID, "practice")
+	require.NoError(t, err)
+	assert.Equal(t, requests, streak.TotalActivities, "no activity is lost to a concurrent one")
+}
+
+func TestCreateNotificationWithIdempotencyKey_ExpiredKeyIsTakenOver(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	newNotification := func(createdAt time.Time) *models.Notification {
+		return &models.Notification{
+			ID: models.NewNotificationID(), UserID: userID, Type: models.DailyReminder, Channel: models.ChannelInApp,
+			Priority: models.PriorityMedium, Message: "Time to practice", Status: models.StatusQueued, CreatedAt: createdAt,
+		}
+	}
+	now := time.Now()
+	old := newNotification(now.Add(-25 * time.Hour))
+	require.NoError(t, repo.CreateNotificationWithIdempotencyKey(ctx, old, nil, "req-7f3a", old.CreatedAt.Add(-24*time.Hour)))
+
+	// The expired key no longer resolves, and the next request reclaims it
+	_, err := repo.GetNotificationByIdempotencyKey(ctx, userID, "req-7f3a", now.Add(-24*time.Hour))
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+
+	fresh := newNotification(now)
+	require.NoError(t, repo.CreateNotificationWithIdempotencyKey(ctx, fresh, nil, "req-7f3a", now.Add(-24*time.Hour)))
+	found, err := repo.GetNotificationByIdempotencyKey(ctx, userID, "req-7f3a", now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, fresh.ID, found.ID)
+}
+
+func TestUpdateUserPreferences_UpsertsOneRow(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	inserted, err := repo.UpdateUserPreferences(ctx, userID,
+		&models.UserNotificationPreferences{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true})
+	require.NoError(t, err)
+
+	limit := 2
+	updated, err := repo.UpdateUserPreferences(ctx, userID,
+		&models.UserNotificationPreferences{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false, MaxPerDay: &limit})
+	require.NoError(t, err)
+
+	assert.Equal(t, inserted.ID, updated.ID)
+	assert.Equal(t, inserted.CreatedAt, updated.CreatedAt)
+	assert.False(t, updated.Enabled)
+	assert.Equal(t, 2, *updated.MaxPerDay)
+
+	prefs, err := repo.GetUserPreferences(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, prefs, 1)
+}
+
+func TestUpdateUserPreferencesBatch_InsertsAndUpdatesInPlace(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	start, end := "22:00", "07:00"
+	existing, err := repo.UpdateUserPreferences(ctx, userID, &models.UserNotificationPreferences{
+		Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true, QuietHoursStart: &start, QuietHoursEnd: &end,
+	})
+	require.NoError(t, err)
+
+	prefs, err := repo.UpdateUserPreferencesBatch(ctx, userID, []models.UserNotificationPreferences{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false, QuietHoursStart: &start, QuietHoursEnd: &end},
+		{Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: true},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, prefs, 2)
+	assert.Equal(t, models.DailyReminder, prefs[0].Type)
+	assert.True(t, prefs[0].Enabled)
+	assert.Equal(t, existing.ID, prefs[1].ID, "the existing row is updated in place")
+	assert.False(t, prefs[1].Enabled)
+	assert.Equal(t, "22:00", *prefs[1].QuietHoursStart)
+}
+
+func TestDeleteUserPreference_FallsBackToDefaults(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+	repo := NewPostgresNotificationRepository(db)
+
+	_, err := repo.UpdateUserPreferencesBatch(ctx, userID, []models.UserNotificationPreferences{
+		{Type: models.DailyReminder, Channel: models.ChannelInApp, Enabled: false},
+		{Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: false},
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteUserPreference(ctx, userID, models.DailyReminder, models.ChannelInApp))
+	assert.ErrorIs(t, repo.DeleteUserPreference(ctx, userID, models.DailyReminder, models.ChannelInApp), ErrPreferenceNotFound)
+
+	prefs, err := repo.GetUserPreferences(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, prefs, 2)
+
+	deleted, err := repo.DeleteUserPreferences(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	prefs, err = repo.GetUserPreferences(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, prefs)
+}
+
+func TestHasNotificationOfTypeToday_SeededRows(t *testing.T) {
+	db := integrationDB(t)
+	ctx := context.Background()
+	userID := seedUser(t, db)
+
+	now := time.Now()
+	seedNotifications(t, db, userID,
+		seededNotification{models.DailyReminder, models.ChannelInApp, models.StatusDelivered, now, nil},
+		seededNotification{models.StreakReminder, models.ChannelInApp, models.StatusDelivered, now.Add(-48 * time.Hour), nil},
+		seededNotification{models.WeeklyRecap, models.ChannelInApp, models.StatusSuppressed, now, nil},
+	)
+	repo := NewPostgresNotificationRepository(db)
+
+	daily, err := repo.HasNotificationOfTypeToday(ctx, userID, models.DailyReminder, "UTC")
+	require.NoError(t, err)
+	streak, err := repo.HasNotificationOfTypeToday(ctx, userID, models.StreakReminder, "UTC")
+	require.NoError(t, err)
+	recap, err := repo.HasNotificationOfTypeToday(ctx, userID, models.WeeklyRecap, "UTC")
+	require.NoError(t, err)
+
+	assert.True(t, daily)
+	assert.False(t, streak, "two days ago is not today")
+	assert.False(t, recap, "suppressed notifications were never sent")
+}
@@ -0,0 +1,1638 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeliveryAttempt() *models.NotificationDeliveryAttempt {
+	return &models.NotificationDeliveryAttempt{
+		NotificationID: uuid.New(),
+		AttemptNo:      2,
+		Status:         models.StatusDelivered,
+		CreatedAt:      time.Now(),
+	}
+}
+
+func TestCreateDeliveryAttempt_UpdatesSummaryInSameTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	attempt := newDeliveryAttempt()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notification_delivery_attempts").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE notifications").
+		WithArgs(attempt.AttemptNo, attempt.Status, attempt.CreatedAt, attempt.NotificationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateDeliveryAttempt(context.Background(), attempt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeliveryAttempts_OldestFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID := uuid.New()
+	now := time.Now()
+	columns := []string{"id", "notification_id", "attempt_no", "status", "error_code", "error_message",
+		"provider_message_id", "provider", "latency_ms", "created_at"}
+	mock.ExpectQuery(`FROM notification_delivery_attempts\s+WHERE notification_id = \$1\s+ORDER BY attempt_no ASC, id ASC`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, notificationID, 1, "failed", "timeout", "provider timed out", nil, "smtp", 10000, now.Add(-time.Minute)).
+			AddRow(2, notificationID, 2, "delivered", nil, nil, "msg-42", "ses", 120, now))
+
+	attempts, err := NewPostgresNotificationRepository(db).GetDeliveryAttempts(context.Background(), notificationID)
+
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, models.StatusFailed, attempts[0].Status)
+	assert.Equal(t, "timeout", *attempts[0].ErrorCode)
+	assert.Equal(t, "ses", *attempts[1].Provider)
+	assert.Equal(t, "msg-42", *attempts[1].ProviderMessageID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeliveryAttempts_NoneIsEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM notification_delivery_attempts`).WillReturnRows(sqlmock.NewRows(nil))
+
+	attempts, err := NewPostgresNotificationRepository(db).GetDeliveryAttempts(context.Background(), uuid.New())
+
+	require.NoError(t, err)
+	assert.NotNil(t, attempts, "an empty list encodes as [] rather than null")
+	assert.Empty(t, attempts)
+}
+
+func TestCreateDeliveryAttempt_RecordsProvider(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	attempt := newDeliveryAttempt()
+	provider := "ses"
+	attempt.Provider = &provider
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notification_delivery_attempts").
+		WithArgs(attempt.NotificationID, attempt.AttemptNo, attempt.Status, nil, nil, nil, "ses", nil, attempt.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = NewPostgresNotificationRepository(db).CreateDeliveryAttempt(context.Background(), attempt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateDeliveryAttempt_RollsBackWhenSummaryUpdateFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notification_delivery_attempts").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE notifications").
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	err = repo.CreateDeliveryAttempt(context.Background(), newDeliveryAttempt())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to update notification attempt summary")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAttemptSummaryMismatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	stale := uuid.New()
+
+	mock.ExpectQuery("FROM notifications n").
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(stale))
+
+	ids, err := repo.GetAttemptSummaryMismatches(context.Background(), 100)
+
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{stale}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newNotificationWithOutbox() (*models.Notification, *models.OutboxNotification) {
+	notification := &models.Notification{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		Type:      models.DailyReminder,
+		Channel:   models.ChannelInApp,
+		Priority:  models.PriorityMedium,
+		Message:   "Time to practice",
+		Status:    models.StatusQueued,
+		CreatedAt: time.Now(),
+	}
+	outboxItem := &models.OutboxNotification{
+		NotificationID: notification.ID,
+		Topic:          "notifications",
+		Payload:        models.JSONMap{"id": notification.ID.String()},
+		CreatedAt:      time.Now(),
+	}
+	return notification, outboxItem
+}
+
+func TestCreateNotificationWithOutbox_Commits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, outboxItem := newNotificationWithOutbox()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateNotificationWithOutbox(context.Background(), notification, outboxItem)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationWithOutbox_RollsBackWhenOutboxInsertFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, outboxItem := newNotificationWithOutbox()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err = repo.CreateNotificationWithOutbox(context.Background(), notification, outboxItem)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create outbox entry")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationsWithOutbox_OneInsertPerTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	first, firstOutbox := newNotificationWithOutbox()
+	second, secondOutbox := newNotificationWithOutbox()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14), ($15,")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox_notifications")).
+		WithArgs(first.ID, "notifications", sqlmock.AnyArg(), false, sqlmock.AnyArg(),
+			second.ID, "notifications", sqlmock.AnyArg(), false, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+
+	err = repo.CreateNotificationsWithOutbox(context.Background(),
+		[]*models.Notification{first, second}, []*models.OutboxNotification{firstOutbox, secondOutbox})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationsWithOutbox_RollsBackWhenOutboxInsertFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, outboxItem := newNotificationWithOutbox()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err = repo.CreateNotificationsWithOutbox(context.Background(),
+		[]*models.Notification{notification}, []*models.OutboxNotification{outboxItem})
+
+	assert.ErrorContains(t, err, "failed to create outbox entries")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationsWithOutbox_EmptyIsNoop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = NewPostgresNotificationRepository(db).CreateNotificationsWithOutbox(context.Background(), nil, nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationWithIdempotencyKey_Commits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, outboxItem := newNotificationWithOutbox()
+	expiredBefore := notification.CreatedAt.Add(-24 * time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO idempotency_keys .*ON CONFLICT \(user_id, idempotency_key\) DO UPDATE.*WHERE idempotency_keys.created_at < \$5`).
+		WithArgs(notification.UserID, "req-7f3a", notification.ID, notification.CreatedAt, expiredBefore).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateNotificationWithIdempotencyKey(context.Background(), notification, outboxItem, "req-7f3a", expiredBefore)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationWithIdempotencyKey_LiveKeyRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, _ := newNotificationWithOutbox()
+
+	// Without an outbox item only the notification and key are written
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.CreateNotificationWithIdempotencyKey(context.Background(), notification, nil, "req-7f3a", time.Now().Add(-24*time.Hour))
+
+	assert.ErrorIs(t, err, ErrDuplicateIdempotencyKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteIdempotencyKeysBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE created_at < \$1`).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := NewPostgresNotificationRepository(db).DeleteIdempotencyKeysBefore(context.Background(), cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateNotificationWithOutbox_DuplicateDedupeKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notification, outboxItem := newNotificationWithOutbox()
+	key := "lesson-42-complete"
+	notification.DedupeKey = &key
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO notifications").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_notifications_user_dedupe_key_active"})
+	mock.ExpectRollback()
+
+	err = repo.CreateNotificationWithOutbox(context.Background(), notification, outboxItem)
+
+	assert.ErrorIs(t, err, ErrDuplicateDedupeKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetireDedupeKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notificationID := uuid.New()
+
+	mock.ExpectExec(`UPDATE notifications SET dedupe_active = false WHERE id = \$1`).
+		WithArgs(notificationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RetireDedupeKey(context.Background(), notificationID)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetActiveNotificationByDedupeKey_SkipsRetiredKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+
+	// Only a cancelled row, its key retired, holds the key
+	mock.ExpectQuery(`WHERE user_id = \$1 AND dedupe_key = \$2 AND dedupe_active\s+ORDER BY created_at DESC`).
+		WithArgs(userID, "lesson-42").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetActiveNotificationByDedupeKey(context.Background(), userID, "lesson-42")
+
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelQueuedNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	queued := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE notifications\s+SET status = \$1, dedupe_active = false\s+WHERE id = \$2 AND status = \$3`).
+		WithArgs(models.StatusCancelled, queued, models.StatusQueued).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM outbox_notifications\s+WHERE notification_id = \$1 AND published = false\s+AND \(claimed_at IS NULL OR claimed_at < \$2\)`).
+		WithArgs(queued, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(queued).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectCommit()
+
+	cancelled, err := repo.CancelQueuedNotification(context.Background(), queued)
+
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelQueuedNotification_NotQueued(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	sent := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE notifications`).
+		WithArgs(models.StatusCancelled, sent, models.StatusQueued).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	cancelled, err := repo.CancelQueuedNotification(context.Background(), sent)
+
+	require.NoError(t, err)
+	assert.False(t, cancelled, "only queued notifications are cancelled")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelQueuedNotification_ClaimedForPublishing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	notificationID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE notifications`).
+		WithArgs(models.StatusCancelled, notificationID, models.StatusQueued).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM outbox_notifications`).
+		WithArgs(notificationID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	cancelled, err := repo.CancelQueuedNotification(context.Background(), notificationID)
+
+	require.NoError(t, err)
+	assert.False(t, cancelled, "an entry a publisher has claimed is not withdrawn")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimUnpublishedOutbox_SkipsLockedRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	worker := "producer-1"
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "notification_id", "topic", "payload", "published", "created_at", "published_at",
+		"claimed_by", "claimed_at", "attempts", "last_error", "dead", "next_attempt_at",
+	}).
+		AddRow(2, uuid.New(), "notifications", []byte(`{}`), false, now, nil, worker, now, 0, nil, false, nil).
+		AddRow(1, uuid.New(), "notifications", []byte(`{}`), false, now.Add(-time.Minute), nil, worker, now, 1, "broker down", false, now.Add(-time.Second))
+
+	mock.ExpectQuery(`next_attempt_at IS NULL OR next_attempt_at <= \$2\)\s+ORDER BY created_at ASC\s+LIMIT \$4\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs(worker, sqlmock.AnyArg(), sqlmock.AnyArg(), 100).
+		WillReturnRows(rows)
+
+	items, err := repo.ClaimUnpublishedOutbox(context.Background(), worker, 100)
+
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, int64(1), items[0].ID, "items are returned oldest first")
+	assert.Equal(t, worker, *items[0].ClaimedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIncrementOutboxAttempts_ReleasesClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	nextAttempt := time.Now().Add(2 * time.Minute)
+
+	mock.ExpectExec(`SET attempts = attempts \+ 1, last_error = \$1, dead = attempts \+ 1 >= \$2,\s+claimed_by = NULL, claimed_at = NULL, next_attempt_at = \$3`).
+		WithArgs("broker down", MaxOutboxAttempts, nextAttempt, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.IncrementOutboxAttempts(context.Background(), 7, "broker down", nextAttempt, MaxOutboxAttempts)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetScheduledNotifications_SkipsReleasedNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	before := time.Now()
+
+	mock.ExpectQuery(`(?s)n.scheduled_for <= \$1\s+AND n.status = \$2\s+AND NOT EXISTS \(\s+SELECT 1 FROM outbox_notifications o WHERE o.notification_id = n.id`).
+		WithArgs(before, models.StatusQueued, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	notifications, err := repo.GetScheduledNotifications(context.Background(), before, 100)
+
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func scheduledNotificationRows(scheduledFor ...time.Time) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "type", "channel", "priority", "template_id", "title", "message",
+		"metadata", "attachments", "dedupe_key", "created_at", "scheduled_for", "sent_at", "delivered_at", "read_at", "status",
+		"last_attempt_no", "last_attempt_status", "last_attempt_at",
+	})
+	for _, at := range scheduledFor {
+		rows.AddRow(uuid.New(), uuid.New(), models.DailyReminder, models.ChannelInApp, models.PriorityMedium, nil, nil, "Time to practice",
+			nil, nil, nil, at.Add(-time.Hour), at, nil, nil, nil, models.StatusQueued,
+			0, nil, nil)
+	}
+	return rows
+}
+
+func TestReleaseScheduledNotifications_ClaimsAndQueuesInOneTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	before := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?s)SET dispatched_at = \$1.*scheduled_for <= \$2\s+AND status = \$3\s+AND dispatched_at IS NULL\s+AND NOT EXISTS.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(sqlmock.AnyArg(), before, models.StatusQueued, 100).
+		WillReturnRows(scheduledNotificationRows(before, before.Add(-time.Hour)))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	var built []time.Time
+	released, err := repo.ReleaseScheduledNotifications(context.Background(), before, 100, func(n *models.Notification) (*models.OutboxNotification, error) {
+		built = append(built, *n.ScheduledFor)
+		return models.BuildOutboxEntry(n, "notifications")
+	})
+
+	require.NoError(t, err)
+	require.Len(t, released, 2)
+	// Released oldest first regardless of RETURNING order
+	assert.Equal(t, []time.Time{before.Add(-time.Hour), before}, built)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseScheduledNotifications_RollsBackWhenOutboxInsertFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	before := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SET dispatched_at").WillReturnRows(scheduledNotificationRows(before))
+	mock.ExpectExec("INSERT INTO outbox_notifications").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	released, err := repo.ReleaseScheduledNotifications(context.Background(), before, 100, func(n *models.Notification) (*models.OutboxNotification, error) {
+		return models.BuildOutboxEntry(n, "notifications")
+	})
+
+	// The claim is undone with the transaction, so the next pass retries it
+	assert.Error(t, err)
+	assert.Nil(t, released)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountNotificationsForUserTypeSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	since := time.Date(2024, 3, 12, 4, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`WHERE user_id = \$1 AND type = \$2 AND created_at >= \$3 AND status <> \$4`).
+		WithArgs(userID, models.StreakReminder, since, models.StatusSuppressed).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountNotificationsForUserTypeSince(context.Background(), userID, models.StreakReminder, since)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUnreadCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectQuery(`WHERE user_id = \$1 AND read_at IS NULL AND status NOT IN \(\$2, \$3\)\s*$`).
+		WithArgs(userID, models.StatusSuppressed, models.StatusCancelled).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectQuery(`WHERE user_id = \$1 AND read_at IS NULL AND status NOT IN \(\$2, \$3\)\s+AND channel = \$4`).
+		WithArgs(userID, models.StatusSuppressed, models.StatusCancelled, models.ChannelInApp).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	all, err := repo.GetUnreadCount(context.Background(), userID, "")
+	require.NoError(t, err)
+	inApp, err := repo.GetUnreadCount(context.Background(), userID, models.ChannelInApp)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), all)
+	assert.Equal(t, int64(2), inApp)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const markAllAsReadQuery = `UPDATE notifications\s+SET read_at = \$1, status = \$2\s+WHERE user_id = \$3 AND read_at IS NULL AND created_at <= \$4 AND status NOT IN \(\$5, \$6\)`
+
+func TestMarkAllAsRead_SingleUpdateUpToCutoff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	cutoff := time.Date(2024, 3, 12, 15, 0, 0, 0, time.UTC)
+	mock.ExpectExec(markAllAsReadQuery+`\s+AND type = \$7`).
+		WithArgs(sqlmock.AnyArg(), models.StatusRead, userID, cutoff, models.StatusSuppressed, models.StatusCancelled, models.StreakReminder).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	updated, err := NewPostgresNotificationRepository(db).MarkAllAsRead(context.Background(), userID, cutoff, models.StreakReminder)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAllAsRead_NothingUnread(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	cutoff := time.Now()
+	mock.ExpectExec(markAllAsReadQuery+`\s*$`).
+		WithArgs(sqlmock.AnyArg(), models.StatusRead, userID, cutoff, models.StatusSuppressed, models.StatusCancelled).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	updated, err := NewPostgresNotificationRepository(db).MarkAllAsRead(context.Background(), userID, cutoff, "")
+
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryUserNotifications_DefaultPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`WHERE user_id = \$1\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2 OFFSET \$3`).
+		WithArgs(userID, 50, 0).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	_, err = NewPostgresNotificationRepository(db).QueryUserNotifications(context.Background(), userID,
+		models.NotificationFilter{Sort: models.SortNewest, Limit: 50})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryUserNotifications_CombinedFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	filter := models.NotificationFilter{
+		Types:      []models.NotificationType{models.StreakReminder, models.DailyReminder},
+		Statuses:   []models.DeliveryStatus{models.StatusDelivered},
+		Channels:   []models.NotificationChannel{models.ChannelInApp},
+		UnreadOnly: true,
+		Since:      &since,
+		Until:      &until,
+		Sort:       models.SortPriority,
+		Limit:      20,
+		Offset:     40,
+	}
+
+	mock.ExpectQuery(`WHERE user_id = \$1 AND type = ANY\(\$2\) AND status = ANY\(\$3\) AND channel = ANY\(\$4\) AND read_at IS NULL AND created_at >= \$5 AND created_at < \$6\s+`+
+		`ORDER BY priority DESC, created_at DESC, id DESC\s+LIMIT \$7 OFFSET \$8`).
+		WithArgs(userID, pq.Array([]string{"streak_reminder", "daily_reminder"}), pq.Array([]string{"delivered"}), pq.Array([]string{"in_app"}),
+			since, until, 20, 40).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	_, err = NewPostgresNotificationRepository(db).QueryUserNotifications(context.Background(), userID, filter)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountUserNotifications_IgnoresPaging(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	filter := models.NotificationFilter{
+		Channels:   []models.NotificationChannel{models.ChannelInApp},
+		UnreadOnly: true,
+		Limit:      20,
+		Offset:     40,
+	}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications WHERE user_id = \$1 AND channel = ANY\(\$2\) AND read_at IS NULL$`).
+		WithArgs(userID, pq.Array([]string{"in_app"})).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(45))
+
+	total, err := NewPostgresNotificationRepository(db).CountUserNotifications(context.Background(), userID, filter)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(45), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPreferences_InsertThenUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	createdAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "type", "channel", "enabled", "quiet_hours_start", "quiet_hours_end",
+		"max_per_day", "last_sent_at", "metadata", "created_at", "updated_at"}
+	upsert := `ON CONFLICT \(user_id, type, channel\)\s+DO UPDATE SET.*RETURNING id, user_id, type, channel`
+
+	// The first call inserts the row, the second hits the conflict target and updates it
+	mock.ExpectQuery(upsert).
+		WithArgs(userID, models.StreakReminder, models.ChannelPush, true, nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(7, userID, "streak_reminder", "push", true, nil, nil, nil, nil, []byte(`{}`), createdAt, createdAt))
+	mock.ExpectQuery(upsert).
+		WithArgs(userID, models.StreakReminder, models.ChannelPush, false, "22:00", "07:00", 3, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(7, userID, "streak_reminder", "push", false, "22:00", "07:00", 3, nil, []byte(`{}`), createdAt, createdAt.Add(time.Hour)))
+
+	inserted, err := repo.UpdateUserPreferences(context.Background(), userID,
+		&models.UserNotificationPreferences{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: true})
+	require.NoError(t, err)
+
+	start, end, limit := "22:00", "07:00", 3
+	updated, err := repo.UpdateUserPreferences(context.Background(), userID, &models.UserNotificationPreferences{
+		Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false,
+		QuietHoursStart: &start, QuietHoursEnd: &end, MaxPerDay: &limit,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(7), inserted.ID)
+	assert.Equal(t, inserted.ID, updated.ID, "the second call updates the same row")
+	assert.Equal(t, createdAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(inserted.UpdatedAt))
+	assert.False(t, updated.Enabled)
+	assert.Equal(t, 3, *updated.MaxPerDay)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPreferencesBatch_MixesInsertsAndUpdates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	createdAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "type", "channel", "enabled", "quiet_hours_start", "quiet_hours_end",
+		"max_per_day", "last_sent_at", "metadata", "created_at", "updated_at"}
+	limit := 2
+
+	// One statement upserts both rows, then the full set is read back in the same transaction
+	mock.ExpectBegin()
+	mock.ExpectExec(`VALUES \(\$1, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$2\), \(\$1, \$10, \$11, \$12, \$13, \$14, \$15, \$16, \$2\)\s+ON CONFLICT \(user_id, type, channel\)`).
+		WithArgs(userID, sqlmock.AnyArg(),
+			models.StreakReminder, models.ChannelPush, false, nil, nil, nil, sqlmock.AnyArg(),
+			models.DailyReminder, models.ChannelEmail, true, nil, nil, 2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`FROM user_notification_preferences\s+WHERE user_id = \$1\s+ORDER BY type, channel`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(8, userID, "daily_reminder", "email", true, nil, nil, 2, nil, []byte(`{}`), createdAt.Add(time.Hour), createdAt.Add(time.Hour)).
+			AddRow(7, userID, "streak_reminder", "push", false, nil, nil, nil, nil, []byte(`{}`), createdAt, createdAt.Add(time.Hour)))
+	mock.ExpectCommit()
+
+	prefs, err := repo.UpdateUserPreferencesBatch(context.Background(), userID, []models.UserNotificationPreferences{
+		{Type: models.StreakReminder, Channel: models.ChannelPush, Enabled: false},
+		{Type: models.DailyReminder, Channel: models.ChannelEmail, Enabled: true, MaxPerDay: &limit},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, prefs, 2)
+	assert.Equal(t, int64(8), prefs[0].ID)
+	assert.Equal(t, int64(7), prefs[1].ID)
+	assert.Equal(t, createdAt, prefs[1].CreatedAt, "the existing row keeps its creation time")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserPreferencesBatch_RollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ON CONFLICT \(user_id, type, channel\)`).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err = repo.UpdateUserPreferencesBatch(context.Background(), uuid.New(), []models.UserNotificationPreferences{
+		{Type: models.StreakReminder, Channel: models.ChannelPush},
+	})
+
+	assert.ErrorContains(t, err, "failed to update user preferences")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserPreference(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	createdAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "type", "channel", "enabled", "quiet_hours_start", "quiet_hours_end",
+		"max_per_day", "last_sent_at", "metadata", "created_at", "updated_at"}
+	lookup := `FROM user_notification_preferences\s+WHERE user_id = \$1 AND type = \$2 AND channel = \$3`
+
+	mock.ExpectQuery(lookup).
+		WithArgs(userID, models.DailyReminder, models.ChannelInApp).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(7, userID, "daily_reminder", "in_app", false, "22:00", "07:00", nil, nil, []byte(`{}`), createdAt, createdAt))
+	mock.ExpectQuery(lookup).
+		WithArgs(userID, models.DailyReminder, models.ChannelEmail).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	pref, err := repo.GetUserPreference(context.Background(), userID, models.DailyReminder, models.ChannelInApp)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), pref.ID)
+	assert.False(t, pref.Enabled)
+	assert.Equal(t, "22:00", *pref.QuietHoursStart)
+
+	_, err = repo.GetUserPreference(context.Background(), userID, models.DailyReminder, models.ChannelEmail)
+	assert.ErrorIs(t, err, ErrPreferenceNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUserPreference(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantErr      error
+	}{
+		{"deleted", 1, nil},
+		{"no such preference", 0, ErrPreferenceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			repo := NewPostgresNotificationRepository(db)
+			userID := uuid.New()
+			mock.ExpectExec(`DELETE FROM user_notification_preferences\s+WHERE user_id = \$1 AND type = \$2 AND channel = \$3`).
+				WithArgs(userID, models.DailyReminder, models.ChannelInApp).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			err = repo.DeleteUserPreference(context.Background(), userID, models.DailyReminder, models.ChannelInApp)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteUserPreferences(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	mock.ExpectExec(`DELETE FROM user_notification_preferences WHERE user_id = \$1$`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	deleted, err := repo.DeleteUserPreferences(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkPreferenceSent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	userID := uuid.New()
+	sentAt := time.Now()
+
+	mock.ExpectExec(`SET last_sent_at = \$4\s+WHERE user_id = \$1 AND type = \$2 AND channel = \$3`).
+		WithArgs(userID, models.StreakReminder, models.ChannelPush, sentAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkPreferenceSent(context.Background(), userID, models.StreakReminder, models.ChannelPush, sentAt)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOutboxStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	oldest := time.Now().Add(-10 * time.Minute)
+
+	mock.ExpectQuery(`(?s)WHERE published = false AND dead = false.*WHERE dead = true.*published_at >= \$1.*SELECT MIN\(created_at\)`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pending", "dead", "published_last_hour", "oldest"}).AddRow(12, 2, 340, oldest))
+
+	stats, err := repo.GetOutboxStats(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 12, stats.Pending)
+	assert.Equal(t, 2, stats.Dead)
+	assert.Equal(t, 340, stats.PublishedLastHour)
+	require.NotNil(t, stats.OldestPendingAt)
+	assert.InDelta(t, 600, stats.OldestPendingAgeSeconds, 5)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOutboxStats_EmptyOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+
+	mock.ExpectQuery(`SELECT MIN\(created_at\)`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pending", "dead", "published_last_hour", "oldest"}).AddRow(0, 0, 0, nil))
+
+	stats, err := repo.GetOutboxStats(context.Background())
+
+	require.NoError(t, err)
+	assert.Nil(t, stats.OldestPendingAt)
+	assert.Zero(t, stats.OldestPendingAgeSeconds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDeadOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "notification_id", "topic", "payload", "published", "created_at", "published_at",
+		"claimed_by", "claimed_at", "attempts", "last_error", "dead", "next_attempt_at",
+	}).AddRow(9, uuid.New(), "notifications", []byte(`{}`), false, now, nil, nil, nil, 5, "broker down", true, now)
+
+	mock.ExpectQuery(`WHERE dead = true\s+ORDER BY created_at ASC\s+LIMIT \$1 OFFSET \$2`).
+		WithArgs(50, 0).
+		WillReturnRows(rows)
+
+	items, err := repo.ListDeadOutbox(context.Background(), 50, 0)
+
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.True(t, items[0].Dead)
+	assert.Equal(t, 5, items[0].Attempts)
+	assert.Equal(t, "broker down", *items[0].LastError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequeueDeadOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+
+	mock.ExpectExec(`SET dead = false, attempts = 0, next_attempt_at = NULL,\s+claimed_by = NULL, claimed_at = NULL\s+WHERE id = \$1 AND dead = true`).
+		WithArgs(int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`WHERE id = \$1 AND dead = true`).
+		WithArgs(int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.RequeueDeadOutbox(context.Background(), 9))
+	assert.ErrorIs(t, repo.RequeueDeadOutbox(context.Background(), 10), ErrOutboxNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkOutboxBatchSent_SingleUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+
+	// The outbox rows and their still-queued notifications change in one statement
+	mock.ExpectExec(`(?s)UPDATE outbox_notifications.*WHERE id = ANY\(\$2\).*UPDATE notifications\s+SET sent_at = \$1, status = \$3.*status = \$4`).
+		WithArgs(sqlmock.AnyArg(), pq.Array([]int64{3, 5, 8}), "sent", "queued").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err = repo.MarkOutboxBatchSent(context.Background(), []int64{3, 5, 8})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkOutboxBatchSent_EmptyIsNoop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = NewPostgresNotificationRepository(db).MarkOutboxBatchSent(context.Background(), nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAsSent_SetsStatusAndSentAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID := uuid.New()
+	mock.ExpectExec(`UPDATE notifications\s+SET sent_at = \$1, status = \$2\s+WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), "sent", notificationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewPostgresNotificationRepository(db).MarkAsSent(context.Background(), notificationID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAsDelivered_SetsStatusAndDeliveredAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID := uuid.New()
+	mock.ExpectExec(`UPDATE notifications\s+SET delivered_at = \$1, status = \$2\s+WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), "delivered", notificationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewPostgresNotificationRepository(db).MarkAsDelivered(context.Background(), notificationID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAsFailed_SetsStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID := uuid.New()
+	mock.ExpectExec(`UPDATE notifications\s+SET status = \$1\s+WHERE id = \$2`).
+		WithArgs("failed", notificationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewPostgresNotificationRepository(db).MarkAsFailed(context.Background(), notificationID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetNotificationByID_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID := uuid.New()
+	mock.ExpectQuery(`SELECT .+ FROM notifications\s+WHERE id = \$1`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = NewPostgresNotificationRepository(db).GetNotificationByID(context.Background(), notificationID)
+
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const markAsReadQuery = `UPDATE notifications\s+SET read_at = COALESCE\(read_at, \$1\), status = \$2\s+WHERE id = \$3 AND user_id = \$4`
+
+func TestMarkAsRead_KeepsOriginalReadAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, userID := uuid.New(), uuid.New()
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(markAsReadQuery).
+			WithArgs(sqlmock.AnyArg(), models.StatusRead, notificationID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	repo := NewPostgresNotificationRepository(db)
+	require.NoError(t, repo.MarkAsRead(context.Background(), notificationID, userID))
+	require.NoError(t, repo.MarkAsRead(context.Background(), notificationID, userID), "reading twice succeeds")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAsRead_UnknownNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, userID := uuid.New(), uuid.New()
+	mock.ExpectExec(markAsReadQuery).
+		WithArgs(sqlmock.AnyArg(), models.StatusRead, notificationID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM notifications WHERE id = \$1\)`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	err = NewPostgresNotificationRepository(db).MarkAsRead(context.Background(), notificationID, userID)
+
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAsRead_OtherUsersNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, caller := uuid.New(), uuid.New()
+	mock.ExpectExec(markAsReadQuery).
+		WithArgs(sqlmock.AnyArg(), models.StatusRead, notificationID, caller).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM notifications WHERE id = \$1\)`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	err = NewPostgresNotificationRepository(db).MarkAsRead(context.Background(), notificationID, caller)
+
+	assert.ErrorIs(t, err, ErrNotificationNotOwned)
+	assert.ErrorIs(t, err, apperr.ErrForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const acknowledgeDeliveryQuery = `UPDATE notifications\s+SET delivered_at = COALESCE\(delivered_at, \$1\),\s+status = CASE WHEN status IN \('read', 'suppressed', 'cancelled'\) THEN status ELSE \$2 END\s+WHERE id = \$3 AND user_id = \$4`
+
+func TestAcknowledgeDelivery_KeepsFirstDeliveredAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, userID := uuid.New(), uuid.New()
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(acknowledgeDeliveryQuery).
+			WithArgs(sqlmock.AnyArg(), models.StatusDelivered, notificationID, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	repo := NewPostgresNotificationRepository(db)
+	require.NoError(t, repo.AcknowledgeDelivery(context.Background(), notificationID, userID))
+	require.NoError(t, repo.AcknowledgeDelivery(context.Background(), notificationID, userID), "acknowledging twice succeeds")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcknowledgeDelivery_OtherUsersNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, caller := uuid.New(), uuid.New()
+	mock.ExpectExec(acknowledgeDeliveryQuery).
+		WithArgs(sqlmock.AnyArg(), models.StatusDelivered, notificationID, caller).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM notifications WHERE id = \$1\)`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	err = NewPostgresNotificationRepository(db).AcknowledgeDelivery(context.Background(), notificationID, caller)
+
+	assert.ErrorIs(t, err, ErrNotificationNotOwned)
+	assert.ErrorIs(t, err, apperr.ErrForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcknowledgeDelivery_UnknownNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	notificationID, userID := uuid.New(), uuid.New()
+	mock.ExpectExec(acknowledgeDeliveryQuery).
+		WithArgs(sqlmock.AnyArg(), models.StatusDelivered, notificationID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM notifications WHERE id = \$1\)`).
+		WithArgs(notificationID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	err = NewPostgresNotificationRepository(db).AcknowledgeDelivery(context.Background(), notificationID, userID)
+
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasNotificationOfTypeToday(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`SELECT EXISTS \(\s+SELECT 1 FROM notifications`).
+		WithArgs(userID, models.DailyReminder, "Europe/Berlin", models.StatusSuppressed).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	exists, err := NewPostgresNotificationRepository(db).HasNotificationOfTypeToday(context.Background(), userID, models.DailyReminder, "Europe/Berlin")
+
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery(`FROM users\s+WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email", "total_xp", "created_at", "updated_at"}).
+			AddRow(userID, "Ada", "ada@example.com", 120, now, now))
+
+	user, err := NewPostgresNotificationRepository(db).GetUserByID(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+	assert.Equal(t, "Ada", user.Name)
+	assert.Equal(t, 120, user.TotalXP)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByID_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`FROM users`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	_, err = NewPostgresNotificationRepository(db).GetUserByID(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserEngagementStreak_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`FROM user_engagement_streaks`).
+		WithArgs(userID, "practice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = NewPostgresNotificationRepository(db).GetUserEngagementStreak(context.Background(), userID, "practice")
+
+	assert.ErrorIs(t, err, ErrStreakNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordStreakActivity_AppliesToLockedRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	last := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO user_engagement_streaks \(user_id, streak_type\)\s+VALUES \(\$1, \$2\)\s+ON CONFLICT \(user_id, streak_type\) DO NOTHING`).
+		WithArgs(userID, "practice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM user_engagement_streaks\s+WHERE user_id = \$1 AND streak_type = \$2\s+FOR UPDATE`).
+		WithArgs(userID, "practice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "streak_type", "current_streak", "longest_streak",
+			"last_activity_date", "streak_start_date", "total_activities", "timezone",
+			"typical_hour", "typical_hour_confidence", "created_at", "updated_at"}).
+			AddRow(7, userID, "practice", 3, 5, last, last.AddDate(0, 0, -2), 10, "UTC", nil, 0, now, now))
+	mock.ExpectExec(`UPDATE user_engagement_streaks\s+SET current_streak = \$1`).
+		WithArgs(4, 5, last, last.AddDate(0, 0, -2), 11, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	streak, err := NewPostgresNotificationRepository(db).RecordStreakActivity(context.Background(), userID, "practice",
+		func(s *models.UserEngagementStreak) {
+			s.CurrentStreak++
+			s.TotalActivities++
+		})
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, streak.CurrentStreak)
+	assert.Equal(t, 11, streak.TotalActivities)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersNeedingDailyReminders_PagesAfterLastUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	after := uuid.New()
+	userID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta("AND u.user_id > $1")).
+		WithArgs(after, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email"}).AddRow(userID, "Ada", "ada@example.com"))
+
+	users, err := NewPostgresNotificationRepository(db).GetUsersNeedingDailyReminders(context.Background(), 100, after)
+
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, models.User{ID: userID, Name: "Ada", Email: "ada@example.com"}, users[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersNeedingLastChanceAlerts_PassesWindowHours(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	after := uuid.New()
+	userID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta("EXTRACT(HOUR FROM tz.local_now) >= $3")).
+		WithArgs(after, 50, 20, 23).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email"}).AddRow(userID, "Ada", "ada@example.com"))
+
+	users, err := NewPostgresNotificationRepository(db).GetUsersNeedingLastChanceAlerts(context.Background(), 20, 23, 50, after)
+
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, userID, users[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLastEngagementNudgeTier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("COALESCE((metadata->>'nudge_tier')::int, 1)")).
+		WithArgs(userID, since).
+		WillReturnRows(sqlmock.NewRows([]string{"tier"}).AddRow(2))
+
+	tier, err := NewPostgresNotificationRepository(db).GetLastEngagementNudgeTier(context.Background(), userID, since)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, tier)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersNeedingXPGoalReminders_PassesHourAndThreshold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta("< $4 * g.target")).
+		WithArgs(uuid.Nil, 100, 18, 0.7).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email"}).AddRow(userID, "Ada", "ada@example.com"))
+
+	users, err := NewPostgresNotificationRepository(db).GetUsersNeedingXPGoalReminders(context.Background(), 18, 0.7, 100, uuid.Nil)
+
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, userID, users[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersNeedingXPGoalReminders_GuardsPointsCast(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// A client-supplied "points": "abc" must not fail the whole page
+	mock.ExpectQuery(regexp.QuoteMeta(`CASE WHEN n.metadata->>'points' ~ '^-?[0-9]{1,9}$' THEN (n.metadata->>'points')::int END`)).
+		WithArgs(uuid.Nil, 100, 18, 0.7).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email"}))
+
+	_, err = NewPostgresNotificationRepository(db).GetUsersNeedingXPGoalReminders(context.Background(), 18, 0.7, 100, uuid.Nil)
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPracticeStreaks_ScansTimezones(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`FROM user_engagement_streaks`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "streak_type", "timezone"}).AddRow(userID, "practice", "Europe/Berlin"))
+
+	streaks, err := NewPostgresNotificationRepository(db).GetPracticeStreaks(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, streaks, 1)
+	assert.Equal(t, "Europe/Berlin", streaks[0].Timezone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWeeklyActivitySummary_AggregatesDays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	weekStart := time.Date(2024, 3, 4, 0, 0, 0, 0, loc)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	// Active Monday, Tuesday, Wednesday, then Saturday and Sunday
+	mock.ExpectQuery(`metadata->>'event' = 'practice_completed'`).
+		WithArgs(userID, weekStart, weekStart.AddDate(0, 0, 7), "America/New_York").
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count", "sum"}).
+			AddRow(day(4), 2, 40).
+			AddRow(day(5), 1, 20).
+			AddRow(day(6), 1, 0).
+			AddRow(day(9), 3, 60).
+			AddRow(day(10), 1, 10))
+
+	summary, err := NewPostgresNotificationRepository(db).GetWeeklyActivitySummary(context.Background(), userID, weekStart)
+
+	require.NoError(t, err)
+	assert.Equal(t, 8, summary.SessionsCompleted)
+	assert.Equal(t, 130, summary.XPEarned)
+	assert.Equal(t, 5, summary.ActiveDays)
+	assert.Equal(t, 3, summary.LongestStreak)
+	assert.Equal(t, [7]int{2, 1, 1, 0, 0, 3, 1}, summary.DailySessions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePublishedOutboxBefore_DeletesInBatchesUntilShortBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+
+	mock.ExpectExec("DELETE FROM outbox_notifications").WithArgs(cutoff, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM outbox_notifications").WithArgs(cutoff, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM outbox_notifications").WithArgs(cutoff, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deleted, err := repo.deletePublishedOutboxBefore(context.Background(), cutoff, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePublishedOutboxBefore_StopsWhenNothingLeft(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cutoff := time.Now()
+	mock.ExpectExec("DELETE FROM outbox_notifications").
+		WithArgs(cutoff, OutboxPurgeBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	deleted, err := NewPostgresNotificationRepository(db).DeletePublishedOutboxBefore(context.Background(), cutoff)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePublishedOutboxBefore_ReturnsPartialCountOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	cutoff := time.Now()
+
+	mock.ExpectExec("DELETE FROM outbox_notifications").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM outbox_notifications").WillReturnError(errors.New("lock timeout"))
+
+	deleted, err := repo.deletePublishedOutboxBefore(context.Background(), cutoff, 3)
+
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequeueOutboxEntry_ResetsExistingRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	item := &models.OutboxNotification{NotificationID: uuid.New(), Topic: "notifications", Payload: models.JSONMap{"schema_version": 1}, CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET topic = \$2, payload = \$3, published = false, published_at = NULL`).
+		WithArgs(item.NotificationID, item.Topic, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RequeueOutboxEntry(context.Background(), item)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequeueOutboxEntry_InsertsWhenPurged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	item := &models.OutboxNotification{NotificationID: uuid.New(), Topic: "notifications", Payload: models.JSONMap{"schema_version": 1}, CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE outbox_notifications`).
+		WithArgs(item.NotificationID, item.Topic, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO outbox_notifications`).
+		WithArgs(item.NotificationID, item.Topic, sqlmock.AnyArg(), false, item.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.RequeueOutboxEntry(context.Background(), item)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequeueFailedNotification_RequeuesOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	item := &models.OutboxNotification{NotificationID: uuid.New(), Topic: "notifications", Payload: models.JSONMap{"schema_version": 1}, CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE notifications SET status = \$1 WHERE id = \$2 AND status = \$3`).
+		WithArgs(models.StatusQueued, item.NotificationID, models.StatusFailed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox_notifications`).
+		WithArgs(item.NotificationID, item.Topic, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	requeued, err := repo.RequeueFailedNotification(context.Background(), item)
+
+	assert.NoError(t, err)
+	assert.True(t, requeued)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequeueFailedNotification_NoLongerFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresNotificationRepository(db)
+	item := &models.OutboxNotification{NotificationID: uuid.New(), Topic: "notifications", Payload: models.JSONMap{"schema_version": 1}, CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE notifications SET status`).
+		WithArgs(models.StatusQueued, item.NotificationID, models.StatusFailed).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	requeued, err := repo.RequeueFailedNotification(context.Background(), item)
+
+	assert.NoError(t, err)
+	assert.False(t, requeued)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"kafka-notify/pkg/models"
+)
+
+// schedulerRunColumns is the column list read by scanSchedulerRun
+const schedulerRunColumns = `id, job_name, started_at, finished_at, users_processed, notifications_created, error`
+
+// SchedulerRunRepository stores the history of scheduler job runs
+type SchedulerRunRepository interface {
+	StartSchedulerRun(ctx context.Context, run *models.SchedulerRun) error
+	FinishSchedulerRun(ctx context.Context, run *models.SchedulerRun) error
+	ListSchedulerRuns(ctx context.Context, jobName string, limit int) ([]models.SchedulerRun, error)
+}
+
+// PostgresSchedulerRunRepository implements SchedulerRunRepository for PostgreSQL
+type PostgresSchedulerRunRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSchedulerRunRepository creates a new PostgreSQL scheduler run repository
+func NewPostgresSchedulerRunRepository(db *sql.DB) *PostgresSchedulerRunRepository {
+	return &PostgresSchedulerRunRepository{
+		db: db,
+	}
+}
+
+// scanSchedulerRun scans a row selected with schedulerRunColumns
+func scanSchedulerRun(row rowScanner, run *models.SchedulerRun) error {
+	return row.Scan(&run.ID, &run.JobName, &run.StartedAt, &run.FinishedAt,
+		&run.UsersProcessed, &run.NotificationsCreated, &run.Error)
+}
+
+// StartSchedulerRun inserts the run as started, setting its ID
+func (r *PostgresSchedulerRunRepository) StartSchedulerRun(ctx context.Context, run *models.SchedulerRun) error {
+	query := `INSERT INTO scheduler_runs (job_name, started_at) VALUES ($1, $2) RETURNING id`
+
+	if err := r.db.QueryRowContext(ctx, query, run.JobName, run.StartedAt).Scan(&run.ID); err != nil {
+		return fmt.Errorf("failed to start scheduler run: %w", err)
+	}
+	return nil
+}
+
+// FinishSchedulerRun records the run's outcome
+func (r *PostgresSchedulerRunRepository) FinishSchedulerRun(ctx context.Context, run *models.SchedulerRun) error {
+	query := `
+		UPDATE scheduler_runs
+		SET finished_at = $2, users_processed = $3, notifications_created = $4, error = $5
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, run.ID, run.FinishedAt, run.UsersProcessed, run.NotificationsCreated, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to finish scheduler run: %w", err)
+	}
+	return nil
+}
+
+// ListSchedulerRuns returns up to limit runs of the job, newest first; an
+// empty job name lists every job's runs
+func (r *PostgresSchedulerRunRepository) ListSchedulerRuns(ctx context.Context, jobName string, limit int) ([]models.SchedulerRun, error) {
+	query := `
+		SELECT ` + schedulerRunColumns + `
+		FROM scheduler_runs
+		WHERE $1 = '' OR job_name = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, jobName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduler runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []models.SchedulerRun{}
+	for rows.Next() {
+		var run models.SchedulerRun
+		if err := scanSchedulerRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduler run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate scheduler runs: %w", err)
+	}
+	return runs, nil
+}
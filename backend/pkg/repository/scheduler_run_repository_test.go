@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSchedulerRun_SetsID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	startedAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO scheduler_runs \(job_name, started_at\) VALUES \(\$1, \$2\) RETURNING id`).
+		WithArgs("daily", startedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+
+	run := &models.SchedulerRun{JobName: "daily", StartedAt: startedAt}
+	err = NewPostgresSchedulerRunRepository(db).StartSchedulerRun(context.Background(), run)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), run.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishSchedulerRun_RecordsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	finishedAt := time.Date(2024, 3, 12, 9, 1, 0, 0, time.UTC)
+	message := "failed to get users for daily reminders: connection refused"
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE scheduler_runs")).
+		WithArgs(int64(42), &finishedAt, 3, 0, &message).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewPostgresSchedulerRunRepository(db).FinishSchedulerRun(context.Background(), &models.SchedulerRun{
+		ID: 42, FinishedAt: &finishedAt, UsersProcessed: 3, Error: &message,
+	})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListSchedulerRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	startedAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	finishedAt := startedAt.Add(time.Minute)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE $1 = '' OR job_name = $1")).
+		WithArgs("daily", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_name", "started_at", "finished_at", "users_processed", "notifications_created", "error"}).
+			AddRow(2, "daily", startedAt, nil, 0, 0, nil).
+			AddRow(1, "daily", startedAt.Add(-time.Hour), finishedAt, 5, 4, nil))
+
+	runs, err := NewPostgresSchedulerRunRepository(db).ListSchedulerRuns(context.Background(), "daily", 20)
+
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Nil(t, runs[0].FinishedAt, "a run in progress has no finish time")
+	assert.Equal(t, 4, runs[1].NotificationsCreated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
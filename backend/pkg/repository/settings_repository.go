@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"kafka-notify/pkg/models"
+)
+
+// maintenanceModeKey is the system_settings row holding the maintenance flag
+const maintenanceModeKey = "maintenance_mode"
+
+// SystemSettingsRepository stores system-wide settings
+type SystemSettingsRepository interface {
+	GetMaintenanceMode(ctx context.Context) (*models.MaintenanceMode, error)
+	SetMaintenanceMode(ctx context.Context, mode *models.MaintenanceMode) error
+}
+
+// PostgresSystemSettingsRepository implements SystemSettingsRepository for PostgreSQL
+type PostgresSystemSettingsRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSystemSettingsRepository creates a new PostgreSQL system settings repository
+func NewPostgresSystemSettingsRepository(db *sql.DB) *PostgresSystemSettingsRepository {
+	return &PostgresSystemSettingsRepository{
+		db: db,
+	}
+}
+
+// GetMaintenanceMode returns the maintenance flag; it is off when never set
+func (r *PostgresSystemSettingsRepository) GetMaintenanceMode(ctx context.Context) (*models.MaintenanceMode, error) {
+	query := `SELECT value FROM system_settings WHERE key = $1`
+
+	var value []byte
+	err := r.db.QueryRowContext(ctx, query, maintenanceModeKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &models.MaintenanceMode{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+
+	var mode models.MaintenanceMode
+	if err := json.Unmarshal(value, &mode); err != nil {
+		return nil, fmt.Errorf("failed to decode maintenance mode: %w", err)
+	}
+	return &mode, nil
+}
+
+// SetMaintenanceMode stores the maintenance flag
+func (r *PostgresSystemSettingsRepository) SetMaintenanceMode(ctx context.Context, mode *models.MaintenanceMode) error {
+	query := `
+		INSERT INTO system_settings (key, value, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`
+
+	value, err := json.Marshal(mode)
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance mode: %w", err)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, maintenanceModeKey, value); err != nil {
+		return fmt.Errorf("failed to set maintenance mode: %w", err)
+	}
+	return nil
+}
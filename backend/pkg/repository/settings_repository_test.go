@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMaintenanceMode_DefaultsToOff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT value FROM system_settings").
+		WithArgs("maintenance_mode").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+
+	mode, err := NewPostgresSystemSettingsRepository(db).GetMaintenanceMode(context.Background())
+
+	require.NoError(t, err)
+	assert.False(t, mode.Enabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMaintenanceMode_RoundTrip(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresSystemSettingsRepository(db)
+	setAt := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	mode := &models.MaintenanceMode{Enabled: true, Reason: "kafka incident", SetBy: "oncall", SetAt: &setAt}
+	stored, err := json.Marshal(mode)
+	require.NoError(t, err)
+
+	mock.ExpectExec("INSERT INTO system_settings").
+		WithArgs("maintenance_mode", stored).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT value FROM system_settings").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(stored))
+
+	require.NoError(t, repo.SetMaintenanceMode(context.Background(), mode))
+	got, err := repo.GetMaintenanceMode(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, mode.Reason, got.Reason)
+	assert.Equal(t, mode.SetBy, got.SetBy)
+	assert.True(t, got.Enabled)
+	assert.True(t, setAt.Equal(*got.SetAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrWebhookNotFound is returned when a user has not registered a webhook
+var ErrWebhookNotFound = apperr.New(apperr.ErrNotFound, "webhook not found")
+
+// webhookColumns is the column list read by scanWebhook
+const webhookColumns = `user_id, url, secret, created_at, updated_at`
+
+// WebhookRepository stores the webhook endpoint of each user
+type WebhookRepository interface {
+	UpsertWebhook(ctx context.Context, webhook *models.UserWebhook) (*models.UserWebhook, error)
+	GetWebhook(ctx context.Context, userID uuid.UUID) (*models.UserWebhook, error)
+}
+
+// PostgresWebhookRepository implements WebhookRepository for PostgreSQL
+type PostgresWebhookRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresWebhookRepository creates a new PostgreSQL webhook repository
+func NewPostgresWebhookRepository(db *sql.DB) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{
+		db: db,
+	}
+}
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row rowScanner, webhook *models.UserWebhook) error {
+	return row.Scan(&webhook.UserID, &webhook.URL, &webhook.Secret, &webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+// UpsertWebhook stores the user's webhook, replacing the URL and secret of an
+// existing one
+func (r *PostgresWebhookRepository) UpsertWebhook(ctx context.Context, webhook *models.UserWebhook) (*models.UserWebhook, error) {
+	query := `
+		INSERT INTO user_webhooks (user_id, url, secret)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			url = EXCLUDED.url,
+			secret = EXCLUDED.secret,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + webhookColumns
+
+	var stored models.UserWebhook
+	if err := scanWebhook(r.db.QueryRowContext(ctx, query, webhook.UserID, webhook.URL, webhook.Secret), &stored); err != nil {
+		return nil, fmt.Errorf("failed to store webhook: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetWebhook returns the user's webhook
+func (r *PostgresWebhookRepository) GetWebhook(ctx context.Context, userID uuid.UUID) (*models.UserWebhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM user_webhooks WHERE user_id = $1`
+
+	var webhook models.UserWebhook
+	if err := scanWebhook(r.db.QueryRowContext(ctx, query, userID), &webhook); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w for user %s", ErrWebhookNotFound, userID)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &webhook, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-notify/pkg/apperr"
+	"kafka-notify/pkg/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var webhookRowColumns = []string{"user_id", "url", "secret", "created_at", "updated_at"}
+
+func TestUpsertWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID, now := uuid.New(), time.Now()
+	mock.ExpectQuery(`INSERT INTO user_webhooks .* ON CONFLICT \(user_id\) DO UPDATE SET\s+url = EXCLUDED.url`).
+		WithArgs(userID, "https://crm.example.com/hook", "0123456789abcdef").
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).AddRow(userID, "https://crm.example.com/hook", "0123456789abcdef", now, now))
+
+	webhook, err := NewPostgresWebhookRepository(db).UpsertWebhook(context.Background(),
+		&models.UserWebhook{UserID: userID, URL: "https://crm.example.com/hook", Secret: "0123456789abcdef"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://crm.example.com/hook", webhook.URL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhook_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectQuery(`FROM user_webhooks WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns))
+
+	_, err = NewPostgresWebhookRepository(db).GetWebhook(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.ErrorIs(t, err, apperr.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
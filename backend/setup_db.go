@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -51,30 +53,38 @@ func main() {
 	}
 	defer db.Close()
 
-	// Read and execute the migration file
-	fmt.Println("Reading migration file...")
-	migrationSQL, err := os.ReadFile("migrations/001_initial_schema.sql")
+	// Read and execute the migration files in order
+	fmt.Println("Reading migration files...")
+	migrationFiles, err := filepath.Glob("migrations/*.sql")
 	if err != nil {
-		log.Fatalf("Failed to read migration file: %v", err)
+		log.Fatalf("Failed to list migration files: %v", err)
 	}
+	sort.Strings(migrationFiles)
 
-	fmt.Println("Executing database migration...")
+	for _, migrationFile := range migrationFiles {
+		migrationSQL, err := os.ReadFile(migrationFile)
+		if err != nil {
+			log.Fatalf("Failed to read migration file %s: %v", migrationFile, err)
+		}
 
-	// Split SQL by semicolons and execute each statement separately
-	statements := strings.Split(string(migrationSQL), ";")
+		fmt.Printf("Executing database migration %s...\n", migrationFile)
 
-	for i, statement := range statements {
-		statement = strings.TrimSpace(statement)
-		if statement == "" {
-			continue
-		}
+		// Split SQL by semicolons and execute each statement separately
+		statements := strings.Split(string(migrationSQL), ";")
 
-		fmt.Printf("Executing statement %d/%d...\n", i+1, len(statements))
-		_, err := db.Exec(statement)
-		if err != nil {
-			log.Printf("Warning: Failed to execute statement %d: %v\n", i+1, err)
-			log.Printf("Statement: %s\n", statement)
-			// Continue with other statements
+		for i, statement := range statements {
+			statement = strings.TrimSpace(statement)
+			if statement == "" {
+				continue
+			}
+
+			fmt.Printf("Executing statement %d/%d...\n", i+1, len(statements))
+			_, err := db.Exec(statement)
+			if err != nil {
+				log.Printf("Warning: Failed to execute statement %d: %v\n", i+1, err)
+				log.Printf("Statement: %s\n", statement)
+				// Continue with other statements
+			}
 		}
 	}
 
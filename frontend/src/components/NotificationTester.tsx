@@ -106,7 +106,7 @@ export const NotificationTester: React.FC = () => {
   // Mark notification as read
   const markAsRead = async (notificationId: string) => {
     try {
-      const response = await fetch(`/api/v1/notifications/${notificationId}/read`, {
+      const response = await fetch(`/api/v1/notifications/${notificationId}/read?userID=${testUserId}`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',